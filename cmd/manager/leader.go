@@ -0,0 +1,56 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// leaderGauge is 1 on the operator replica running the controllers and 0 on the standby ones
+var leaderGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "redis_operator_leader",
+	Help: "Whether the operator replica is the elected leader running the controllers",
+})
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(leaderGauge)
+}
+
+// leaderRunnable sets leaderGauge while the replica is the leader. The Manager starts it once the leader
+// election is won, along with the controllers, and stops it once the leadership is lost.
+type leaderRunnable struct{}
+
+// strict implementation check
+var (
+	_ manager.Runnable               = leaderRunnable{}
+	_ manager.LeaderElectionRunnable = leaderRunnable{}
+)
+
+// Start implements manager.Runnable
+func (leaderRunnable) Start(stop <-chan struct{}) error {
+	log.Info("Became the leader, starting the controllers")
+	leaderGauge.Set(1)
+	<-stop
+	leaderGauge.Set(0)
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (leaderRunnable) NeedLeaderElection() bool {
+	return true
+}
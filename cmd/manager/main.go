@@ -24,6 +24,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	kubemetrics "github.com/operator-framework/operator-sdk/pkg/kube-metrics"
@@ -33,6 +35,7 @@ import (
 	"github.com/spf13/pflag"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -40,18 +43,60 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 
 	"github.com/amaizfinance/redis-operator/pkg/apis"
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
 	"github.com/amaizfinance/redis-operator/pkg/controller"
+	"github.com/amaizfinance/redis-operator/pkg/features"
+	"github.com/amaizfinance/redis-operator/pkg/fips"
+	"github.com/amaizfinance/redis-operator/pkg/webhook"
 	"github.com/amaizfinance/redis-operator/version"
 )
 
 // Change below variables to serve metrics on different host or port.
+// The empty host listens on all the interfaces of both IP families, so the metrics are served on IPv6-only clusters.
 var (
-	metricsHost               = "0.0.0.0"
+	metricsHost               = ""
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
 )
 var log = logf.Log.WithName("cmd")
 
+// webhook server settings
+var (
+	enableWebhooks bool
+	webhookPort    = 9443
+	webhookCertDir string
+	// the self-signed webhook certificate is issued for the Service and trusted by the configuration
+	webhookSelfSigned    bool
+	webhookService       = "redis-operator-webhook"
+	webhookConfiguration = "redis-operator"
+	webhookCertSecret    = "redis-operator-webhook-cert"
+	// the images of the Redis resources must be pinned by digest
+	requireImageDigests bool
+	// the redis containers must be of the Guaranteed QoS class
+	requireGuaranteedQoS bool
+)
+
+// watchNamespaces are the namespaces the Redis resources are watched in, all the namespaces if empty.
+// Defaults to the comma separated namespaces of the WATCH_NAMESPACE environment variable.
+var watchNamespaces []string
+
+// leader election settings. The replicas compete for the lease of the Manager with --leader-elect,
+// otherwise the operator-sdk leader-for-life lock is held until the leader Pod is deleted.
+var (
+	leaderElect             bool
+	leaderElectionID        = "redis-operator-lock"
+	leaderElectionNamespace string
+	leaseDuration           = 15 * time.Second
+	renewDeadline           = 10 * time.Second
+	retryPeriod             = 2 * time.Second
+)
+
+// Kubernetes API client rate limits, the client-go defaults are used if not positive
+var (
+	kubeAPIQPS   float32
+	kubeAPIBurst int
+)
+
 func printVersion() {
 	log.Info(fmt.Sprintf("Redis-Operator Version: %s", version.Version))
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
@@ -68,6 +113,51 @@ func main() {
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
+	pflag.BoolVar(&enableWebhooks, "enable-webhooks", enableWebhooks,
+		"Serve the admission webhooks. The serving certificate must be present in --webhook-cert-dir")
+	pflag.IntVar(&webhookPort, "webhook-port", webhookPort, "Port the admission webhooks are served at")
+	pflag.StringVar(&webhookCertDir, "webhook-cert-dir", webhookCertDir,
+		"Directory containing tls.crt and tls.key of the webhook serving certificate")
+	pflag.BoolVar(&webhookSelfSigned, "webhook-self-signed", webhookSelfSigned,
+		"Generate and rotate the self-signed webhook serving certificate in --webhook-cert-dir "+
+			"and patch the CA bundle of --webhook-configuration")
+	pflag.StringVar(&webhookService, "webhook-service", webhookService,
+		"Name of the webhook Service in the operator namespace the self-signed certificate is issued for")
+	pflag.StringVar(&webhookConfiguration, "webhook-configuration", webhookConfiguration,
+		"Name of the MutatingWebhookConfiguration and ValidatingWebhookConfiguration patched with the self-signed CA bundle")
+	pflag.StringVar(&webhookCertSecret, "webhook-cert-secret", webhookCertSecret,
+		"Name of the Secret in the operator namespace the replicas share the self-signed certificate in")
+	pflag.BoolVar(&requireImageDigests, "require-image-digests", requireImageDigests,
+		"Reject the Redis resources with container images not pinned by digest. Requires --enable-webhooks")
+	pflag.BoolVar(&requireGuaranteedQoS, "require-guaranteed-qos", requireGuaranteedQoS,
+		"Mirror the resource requests and limits of the redis containers and reject the ones not of the Guaranteed QoS class. "+
+			"Requires --enable-webhooks")
+	pflag.BoolVar(&fips.Enabled, "fips", fips.Enabled,
+		"Restrict the cryptography to the FIPS 140 approved algorithms. Enabled by default in the builds with the fips tag")
+	pflag.Var(features.DefaultGates, "feature-gates",
+		"Comma separated Name=true|false pairs setting the feature gates, overridden for a Redis with the "+
+			features.Annotation+" annotation. Known features: "+strings.Join(features.DefaultGates.Known(), ", "))
+	pflag.StringSliceVar(&watchNamespaces, "watch-namespaces", splitNamespaces(os.Getenv(k8sutil.WatchNamespaceEnvVar)),
+		"Comma separated namespaces the Redis resources are watched in, the cache is restricted to them. "+
+			"Empty value watches all the namespaces. Defaults to "+k8sutil.WatchNamespaceEnvVar)
+	pflag.BoolVar(&leaderElect, "leader-elect", leaderElect,
+		"Elect the leader running the controllers with a renewed lease, so the standby replicas take over "+
+			"within --leader-election-lease-duration. Otherwise the leader holds the lock until its Pod is deleted")
+	pflag.StringVar(&leaderElectionID, "leader-election-id", leaderElectionID,
+		"Name of the ConfigMap holding the leader lock")
+	pflag.StringVar(&leaderElectionNamespace, "leader-election-namespace", leaderElectionNamespace,
+		"Namespace of the ConfigMap holding the leader lock. Defaults to the namespace the operator runs in")
+	pflag.DurationVar(&leaseDuration, "leader-election-lease-duration", leaseDuration,
+		"Duration the standby replicas wait for before taking the leadership over since the last renewal. Requires --leader-elect")
+	pflag.DurationVar(&renewDeadline, "leader-election-renew-deadline", renewDeadline,
+		"Duration the leader retries renewing the lease for before giving the leadership up. Requires --leader-elect")
+	pflag.DurationVar(&retryPeriod, "leader-election-retry-period", retryPeriod,
+		"Interval the replicas retry acquiring and renewing the lease at. Requires --leader-elect")
+	pflag.Float32Var(&kubeAPIQPS, "kube-api-qps", kubeAPIQPS,
+		"Maximum QPS of the requests to the Kubernetes API. 0 keeps the client default")
+	pflag.IntVar(&kubeAPIBurst, "kube-api-burst", kubeAPIBurst,
+		"Maximum burst of the requests to the Kubernetes API. 0 keeps the client default")
+
 	pflag.Parse()
 
 	// Use a zap logr.Logger implementation. If none of the zap
@@ -81,11 +171,15 @@ func main() {
 	logf.SetLogger(zap.Logger())
 
 	printVersion()
+	if fips.Enabled {
+		log.Info("FIPS mode enabled, only the approved cryptographic algorithms are used")
+	}
 
-	namespace, err := k8sutil.GetWatchNamespace()
-	if err != nil {
-		log.Error(err, "Failed to get watch namespace")
-		os.Exit(1)
+	namespaces := splitNamespaces(strings.Join(watchNamespaces, ","))
+	if len(namespaces) == 0 {
+		log.Info("Watching all namespaces")
+	} else {
+		log.Info("Watching namespaces", "namespaces", namespaces)
 	}
 
 	// Get a config to talk to the apiserver
@@ -94,21 +188,51 @@ func main() {
 		log.Error(err, "")
 		os.Exit(1)
 	}
+	if kubeAPIQPS > 0 {
+		cfg.QPS = kubeAPIQPS
+	}
+	if kubeAPIBurst > 0 {
+		cfg.Burst = kubeAPIBurst
+	}
 
 	ctx := context.TODO()
-	// Become the leader before proceeding
-	err = leader.Become(ctx, "redis-operator-lock")
-	if err != nil {
-		log.Error(err, "")
-		os.Exit(1)
+	if leaderElect {
+		if leaseDuration <= renewDeadline || renewDeadline <= retryPeriod || retryPeriod <= 0 {
+			log.Error(nil, "--leader-election-lease-duration must exceed --leader-election-renew-deadline, "+
+				"which must exceed the positive --leader-election-retry-period")
+			os.Exit(1)
+		}
+	} else {
+		// Become the leader before proceeding
+		if err = leader.Become(ctx, leaderElectionID); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
 	}
 
 	// Create a new Cmd to provide shared dependencies and start components
-	mgr, err := manager.New(cfg, manager.Options{
-		Namespace:          namespace,
+	options := manager.Options{
 		MapperProvider:     apiutil.NewDiscoveryRESTMapper,
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
-	})
+		Port:               webhookPort,
+		CertDir:            webhookCertDir,
+	}
+	// the standby replicas serve the metrics and the webhooks, the controllers run on the leader only
+	if leaderElect {
+		options.LeaderElection = true
+		options.LeaderElectionID = leaderElectionID
+		options.LeaderElectionNamespace = leaderElectionNamespace
+		options.LeaseDuration = &leaseDuration
+		options.RenewDeadline = &renewDeadline
+		options.RetryPeriod = &retryPeriod
+	}
+	// a single namespace is watched by the namespaced informers, several ones by an informer per namespace
+	if len(namespaces) == 1 {
+		options.Namespace = namespaces[0]
+	} else if len(namespaces) > 1 {
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+	mgr, err := manager.New(cfg, options)
 	if err != nil {
 		log.Error(err, "")
 		os.Exit(1)
@@ -127,9 +251,37 @@ func main() {
 		log.Error(err, "")
 		os.Exit(1)
 	}
+	if err := mgr.Add(leaderRunnable{}); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
+	// Setup all Webhooks
+	if requireImageDigests && !enableWebhooks {
+		log.Error(nil, "--require-image-digests is enforced by the webhooks, set --enable-webhooks")
+		os.Exit(1)
+	}
+	if requireGuaranteedQoS && !enableWebhooks {
+		log.Error(nil, "--require-guaranteed-qos is enforced by the webhooks, set --enable-webhooks")
+		os.Exit(1)
+	}
+	k8sv1alpha1.RequireImageDigests = requireImageDigests
+	k8sv1alpha1.RequireGuaranteedQoS = requireGuaranteedQoS
+	if enableWebhooks {
+		if err := webhook.AddToManager(mgr); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+		if webhookSelfSigned {
+			if err := addCertRotator(ctx, mgr); err != nil {
+				log.Error(err, "Failed to issue webhook certificate")
+				os.Exit(1)
+			}
+		}
+	}
 
 	// Add the Metrics Service
-	addMetrics(ctx, cfg)
+	addMetrics(ctx, cfg, namespaces)
 
 	log.Info("Starting the Cmd.")
 
@@ -140,9 +292,40 @@ func main() {
 	}
 }
 
+// addCertRotator issues the self-signed webhook certificate shared by the replicas and adds its rotation
+// by the leader and its synchronization by every replica to the Manager. The certificate must be in place
+// before the webhook server is started, the webhooks must be registered to default the certificate directory.
+func addCertRotator(ctx context.Context, mgr manager.Manager) error {
+	operatorNs, err := k8sutil.GetOperatorNamespace()
+	if err != nil {
+		return err
+	}
+
+	rotator := &webhook.CertRotator{
+		Client:          mgr.GetClient(),
+		Reader:          mgr.GetAPIReader(),
+		SecretNamespace: operatorNs,
+		SecretName:      webhookCertSecret,
+		CertDir:         mgr.GetWebhookServer().CertDir,
+		DNSNames: []string{
+			fmt.Sprintf("%s.%s.svc", webhookService, operatorNs),
+			fmt.Sprintf("%s.%s.svc.cluster.local", webhookService, operatorNs),
+		},
+		MutatingWebhookConfigurations:   []string{webhookConfiguration},
+		ValidatingWebhookConfigurations: []string{webhookConfiguration},
+	}
+	if err := rotator.Ensure(ctx); err != nil {
+		return err
+	}
+	if err := mgr.Add(rotator.Syncer()); err != nil {
+		return err
+	}
+	return mgr.Add(rotator)
+}
+
 // addMetrics will create the Services and Service Monitors to allow the operator export the metrics by using
 // the Prometheus operator
-func addMetrics(ctx context.Context, cfg *rest.Config) {
+func addMetrics(ctx context.Context, cfg *rest.Config, namespaces []string) {
 	// Get the namespace the operator is currently deployed in.
 	operatorNs, err := k8sutil.GetOperatorNamespace()
 	if err != nil {
@@ -152,7 +335,7 @@ func addMetrics(ctx context.Context, cfg *rest.Config) {
 		}
 	}
 
-	if err := serveCRMetrics(cfg, operatorNs); err != nil {
+	if err := serveCRMetrics(cfg, operatorNs, namespaces); err != nil {
 		log.Info("Could not generate and serve custom resource metrics", "error", err.Error())
 	}
 
@@ -186,7 +369,8 @@ func addMetrics(ctx context.Context, cfg *rest.Config) {
 
 // serveCRMetrics gets the Operator/CustomResource GVKs and generates metrics based on those types.
 // It serves those metrics on "http://metricsHost:operatorMetricsPort".
-func serveCRMetrics(cfg *rest.Config, operatorNs string) error {
+// The metrics are generated from the watched namespaces, from the operator namespace if all are watched.
+func serveCRMetrics(cfg *rest.Config, operatorNs string, namespaces []string) error {
 	// The function below returns a list of filtered operator/CR specific GVKs. For more control, override the GVK list below
 	// with your own custom logic. Note that if you are adding third party API schemas, probably you will need to
 	// customize this implementation to avoid permissions issues.
@@ -195,11 +379,10 @@ func serveCRMetrics(cfg *rest.Config, operatorNs string) error {
 		return err
 	}
 
-	// The metrics will be generated from the namespaces which are returned here.
 	// NOTE that passing nil or an empty list of namespaces in GenerateAndServeCRMetrics will result in an error.
-	ns, err := kubemetrics.GetNamespacesForMetrics(operatorNs)
-	if err != nil {
-		return err
+	ns := namespaces
+	if len(ns) == 0 {
+		ns = []string{operatorNs}
 	}
 
 	// Generate and serve custom resource specific metrics.
@@ -209,3 +392,16 @@ func serveCRMetrics(cfg *rest.Config, operatorNs string) error {
 	}
 	return nil
 }
+
+// splitNamespaces returns the distinct non-empty namespaces of the comma separated list in order
+func splitNamespaces(list string) []string {
+	var namespaces []string
+	seen := make(map[string]bool)
+	for _, namespace := range strings.Split(list, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" && !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
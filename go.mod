@@ -7,9 +7,11 @@ require (
 	github.com/go-openapi/spec v0.19.4
 	github.com/go-redis/redis v6.15.5+incompatible
 	github.com/operator-framework/operator-sdk v0.18.2
+	github.com/prometheus/client_golang v1.5.1
 	github.com/spf13/cast v1.3.1
 	github.com/spf13/pflag v1.0.5
 	golang.org/x/crypto v0.0.0-20200414173820-0848c9571904
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 	k8s.io/api v0.18.2
 	k8s.io/apimachinery v0.18.2
 	k8s.io/client-go v12.0.0+incompatible
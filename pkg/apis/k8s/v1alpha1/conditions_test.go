@@ -0,0 +1,88 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRedisStatus_SetCondition(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	existing := Condition{
+		Type:               ConditionPersistenceFailing,
+		Status:             corev1.ConditionFalse,
+		Reason:             "PersistenceOK",
+		LastTransitionTime: past,
+	}
+
+	tests := []struct {
+		name               string
+		condition          Condition
+		wantChanged        bool
+		wantTransitionTime bool
+	}{
+		{"unchanged", *existing.DeepCopy(), false, false},
+		{"message changed", Condition{Type: existing.Type, Status: existing.Status, Reason: existing.Reason, Message: "ok"}, true, false},
+		{"status changed", Condition{Type: existing.Type, Status: corev1.ConditionTrue, Reason: "PersistenceError"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &RedisStatus{Conditions: []Condition{*existing.DeepCopy()}}
+			if changed := status.SetCondition(tt.condition); changed != tt.wantChanged {
+				t.Errorf("SetCondition() = %v, want %v", changed, tt.wantChanged)
+			}
+			if len(status.Conditions) != 1 {
+				t.Fatalf("SetCondition() produced %d conditions, want 1", len(status.Conditions))
+			}
+			got := status.GetCondition(tt.condition.Type)
+			if got.Status != tt.condition.Status || got.Message != tt.condition.Message {
+				t.Errorf("SetCondition() = %+v, want %+v", got, tt.condition)
+			}
+			if transitioned := !got.LastTransitionTime.Equal(&past); transitioned != tt.wantTransitionTime {
+				t.Errorf("SetCondition() transitioned = %v, want %v", transitioned, tt.wantTransitionTime)
+			}
+		})
+	}
+
+	t.Run("new", func(t *testing.T) {
+		status := new(RedisStatus)
+		if !status.SetCondition(Condition{Type: ConditionPersistenceFailing, Status: corev1.ConditionTrue}) {
+			t.Error("SetCondition() = false, want true")
+		}
+		if got := status.GetCondition(ConditionPersistenceFailing); got == nil || got.LastTransitionTime.IsZero() {
+			t.Errorf("SetCondition() = %+v, want condition with LastTransitionTime set", got)
+		}
+	})
+}
+
+func TestRedisStatus_RemoveCondition(t *testing.T) {
+	status := &RedisStatus{Conditions: []Condition{
+		{Type: ConditionReady, Status: corev1.ConditionTrue},
+		{Type: ConditionImagesVerified, Status: corev1.ConditionTrue},
+	}}
+	if !status.RemoveCondition(ConditionImagesVerified) {
+		t.Error("RemoveCondition() = false, want true")
+	}
+	if status.GetCondition(ConditionImagesVerified) != nil || status.GetCondition(ConditionReady) == nil {
+		t.Errorf("RemoveCondition() conditions = %+v", status.Conditions)
+	}
+	if status.RemoveCondition(ConditionImagesVerified) {
+		t.Error("RemoveCondition() of the missing condition = true, want false")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// configNameRegexp matches the names of the Redis configuration directives
+var configNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+// ValidateConfigDirective checks that the directive of spec.config is rendered into redis.conf as a single line
+// parsed by Redis into the name and the arguments as written. The arguments are separated by spaces and may be
+// quoted as in redis.conf, e.g. `save "900 1"` or `requirepass "p@ss word"`. The line breaks and the other control
+// characters are rejected, they would inject arbitrary directives. The quotes must be balanced.
+func ValidateConfigDirective(name, value string) error {
+	if !configNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid config: spec.config: invalid directive name %q", name)
+	}
+	for _, c := range value {
+		if c != '\t' && (c < ' ' || c == 0x7f) {
+			return fmt.Errorf("invalid config: spec.config.%s: control character %q is not allowed", name, c)
+		}
+	}
+	args, err := SplitConfigArgs(value)
+	if err != nil {
+		return fmt.Errorf("invalid config: spec.config.%s: %s", name, err)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("invalid config: spec.config.%s: the value is empty, use \"\" for the empty argument", name)
+	}
+	return nil
+}
+
+// SplitConfigArgs splits the line into the arguments the way Redis parses its configuration file (sdssplitargs):
+// the arguments are separated by spaces, the double quoted ones support the escape sequences, e.g. "\n" and "\x00",
+// the single quoted ones support the escaped single quote only. A closing quote must be followed by a space.
+func SplitConfigArgs(line string) ([]string, error) {
+	var args []string
+	for i := 0; ; {
+		for i < len(line) && isConfigSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var arg []byte
+		var doubleQuoted, singleQuoted bool
+		for done := false; !done; {
+			switch {
+			case doubleQuoted:
+				switch {
+				case i == len(line):
+					return nil, fmt.Errorf("unbalanced quotes")
+				case line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]):
+					b, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+					arg = append(arg, byte(b))
+					i += 3
+				case line[i] == '\\' && i+1 < len(line):
+					i++
+					switch c := line[i]; c {
+					case 'n':
+						arg = append(arg, '\n')
+					case 'r':
+						arg = append(arg, '\r')
+					case 't':
+						arg = append(arg, '\t')
+					case 'b':
+						arg = append(arg, '\b')
+					case 'a':
+						arg = append(arg, '\a')
+					default:
+						arg = append(arg, c)
+					}
+				case line[i] == '"':
+					if i+1 < len(line) && !isConfigSpace(line[i+1]) {
+						return nil, fmt.Errorf("closing quote must be followed by a space")
+					}
+					done = true
+				default:
+					arg = append(arg, line[i])
+				}
+			case singleQuoted:
+				switch {
+				case i == len(line):
+					return nil, fmt.Errorf("unbalanced quotes")
+				case line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					arg = append(arg, '\'')
+					i++
+				case line[i] == '\'':
+					if i+1 < len(line) && !isConfigSpace(line[i+1]) {
+						return nil, fmt.Errorf("closing quote must be followed by a space")
+					}
+					done = true
+				default:
+					arg = append(arg, line[i])
+				}
+			default:
+				switch {
+				case i == len(line) || isConfigSpace(line[i]):
+					done = true
+				case line[i] == '"':
+					doubleQuoted = true
+				case line[i] == '\'':
+					singleQuoted = true
+				default:
+					arg = append(arg, line[i])
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		args = append(args, string(arg))
+	}
+}
+
+// QuoteConfigArg returns the argument as written to the Redis configuration file so that it is parsed back as is.
+// The arguments of the safe characters are written as they are, the rest are double quoted with the quotes,
+// the backslashes and the non-printable bytes escaped, e.g. the passwords interpolated into auth.conf.
+func QuoteConfigArg(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(c rune) bool {
+		return c <= ' ' || c >= 0x7f || c == '"' || c == '\'' || c == '\\'
+	}) < 0 {
+		return arg
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		switch c := arg[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c >= 0x7f:
+			_, _ = fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// ValidatePassword checks the password the instances are protected with. The control characters are rejected:
+// besides the configuration, the password is passed to the probes and the exporter in the environment.
+// The password is quoted in the configuration file with QuoteConfigArg, any other character is allowed.
+func ValidatePassword(password string) error {
+	for _, c := range password {
+		if c < ' ' || c == 0x7f {
+			return fmt.Errorf("invalid password: control character %q is not allowed", c)
+		}
+	}
+	return nil
+}
+
+// isConfigSpace reports whether the byte separates the arguments, as isspace in the C locale
+func isConfigSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package v1alpha1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func FuzzQuoteConfigArg(f *testing.F) {
+	for _, seed := range []string{"", "secret", "p@ss word", `"\'`, "a\nb\x00"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, arg string) {
+		quoted := QuoteConfigArg(arg)
+		if strings.ContainsAny(quoted, "\r\n") {
+			t.Fatalf("QuoteConfigArg(%q) = %q spans multiple lines", arg, quoted)
+		}
+		if args, err := SplitConfigArgs(quoted); err != nil || !reflect.DeepEqual(args, []string{arg}) {
+			t.Fatalf("SplitConfigArgs(%q) = %q, %v, want %q", quoted, args, err, arg)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitConfigArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"spaces", "  \t ", nil, false},
+		{"single", "100mb", []string{"100mb"}, false},
+		{"multiple", "900 1  300\t10", []string{"900", "1", "300", "10"}, false},
+		{"empty quoted", `""`, []string{""}, false},
+		{"double quoted", `"p@ss word" x`, []string{"p@ss word", "x"}, false},
+		{"escapes", `"a\nb\x41\"\\"`, []string{"a\nbA\"\\"}, false},
+		{"single quoted", `'it\'s "raw" \n'`, []string{`it's "raw" \n`}, false},
+		{"quote in the middle", `ab"c d"`, []string{"abc d"}, false},
+		{"unbalanced double", `"abc`, nil, true},
+		{"unbalanced single", `'abc`, nil, true},
+		{"trailing backslash", `"abc\`, nil, true},
+		{"closing quote followed by text", `"abc"d`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitConfigArgs(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SplitConfigArgs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitConfigArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfigDirective(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{"plain", "maxmemory", "100mb", false},
+		{"multiple arguments", "save", "900 1 300 10", false},
+		{"empty argument", "save", `""`, false},
+		{"tab", "save", "900\t1", false},
+		{"empty", "maxmemory", "", true},
+		{"spaces only", "maxmemory", "  ", true},
+		{"newline", "maxmemory", "100mb\ndir /tmp", true},
+		{"carriage return", "maxmemory", "100mb\rdir /tmp", true},
+		{"nul", "maxmemory", "100mb\x00", true},
+		{"unbalanced quotes", "requirepass", `"secret`, true},
+		{"name with space", "maxmemory 1mb\ndir", "/tmp", true},
+		{"empty name", "", "100mb", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateConfigDirective(tt.key, tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfigDirective() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuoteConfigArg(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{"plain", "p@ss-w0rd!", "p@ss-w0rd!"},
+		{"empty", "", `""`},
+		{"space", "p@ss word", `"p@ss word"`},
+		{"quotes", `it's "quoted"`, `"it's \"quoted\""`},
+		{"backslash", `a\b`, `"a\\b"`},
+		{"injection", "secret\nrename-command CONFIG \"\"", `"secret\x0arename-command CONFIG \"\""`},
+		{"non-ASCII", "pässword", `"p\xc3\xa4ssword"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := QuoteConfigArg(tt.arg)
+			if got != tt.want {
+				t.Errorf("QuoteConfigArg() = %s, want %s", got, tt.want)
+			}
+			if args, err := SplitConfigArgs(got); err != nil || !reflect.DeepEqual(args, []string{tt.arg}) {
+				t.Errorf("SplitConfigArgs(QuoteConfigArg()) = %q, %v, want %q", args, err, tt.arg)
+			}
+		})
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{"plain", "secret", false},
+		{"special characters", `p@ss "word" 'x' \`, false},
+		{"newline", "secret\nrename-command CONFIG \"\"", true},
+		{"NUL", "secret\x00", true},
+		{"tab", "sec\tret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePassword(tt.password); (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
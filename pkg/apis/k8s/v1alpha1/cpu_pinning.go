@@ -0,0 +1,101 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GuaranteedQoS reports whether the requests and limits of the containers managed by the operator
+// are mirrored for the Guaranteed QoS class, either by the operator policy or for the CPU pinning
+func (r *Redis) GuaranteedQoS() bool {
+	return RequireGuaranteedQoS || r.Spec.CPUPinning
+}
+
+// PinnedCPUs returns the whole CPUs the redis container is allocated exclusively by the static CPU manager policy,
+// that is the CPU request of a Guaranteed container if it is an integer, or zero otherwise
+func PinnedCPUs(resources corev1.ResourceRequirements) int64 {
+	if validateGuaranteedResources(resources) != nil {
+		return 0
+	}
+	cpu := resources.Requests[corev1.ResourceCPU]
+	if cpu.MilliValue()%1000 != 0 {
+		return 0
+	}
+	return cpu.Value()
+}
+
+// validateCPUPinning checks that the Pods are Guaranteed with an integer CPU request of the redis container
+// if Spec.CPUPinning is set, and that io-threads does not exceed the pinned CPUs: the main thread counts
+// as one of the I/O threads, more threads than CPUs only contend for them.
+// The resources of the profile are mirrored the same way the operator does.
+func (r *Redis) validateCPUPinning() error {
+	if !r.Spec.CPUPinning {
+		return nil
+	}
+	redis := r.DeepCopy()
+	redis.ApplyProfile()
+
+	containers := map[string]*corev1.ResourceRequirements{"spec.redis": &redis.Spec.Redis.Resources}
+	if redis.Spec.Exporter.Image != "" {
+		containers["spec.exporter"] = &redis.Spec.Exporter.Resources
+	}
+	if redis.Spec.Metrics != nil {
+		containers["spec.metrics.container"] = &redis.Spec.Metrics.Container.Resources
+	}
+	// the requests of the containers the operator does not manage default to the limits as well
+	for i := range redis.Spec.InitContainers {
+		containers[fmt.Sprintf("spec.initContainers[%d]", i)] = &redis.Spec.InitContainers[i].Resources
+	}
+	for i := range redis.Spec.Sidecars {
+		containers[fmt.Sprintf("spec.sidecars[%d]", i)] = &redis.Spec.Sidecars[i].Resources
+	}
+	var errs []string
+	for path, resources := range containers {
+		MirrorResources(resources)
+		if err := validateGuaranteedResources(*resources); err != nil {
+			errs = append(errs, fmt.Sprintf("%s.resources: %s", path, err))
+		}
+	}
+	if len(errs) > 0 {
+		// map iteration order is random
+		sort.Strings(errs)
+		return fmt.Errorf("invalid CPU pinning: the Pods must be of the Guaranteed QoS class: %s", strings.Join(errs, "; "))
+	}
+
+	cpus := PinnedCPUs(redis.Spec.Redis.Resources)
+	if cpus == 0 {
+		cpu := redis.Spec.Redis.Resources.Requests[corev1.ResourceCPU]
+		return fmt.Errorf("invalid CPU pinning: spec.redis.resources: the CPU request %s is not a whole number of CPUs", cpu.String())
+	}
+	for name, value := range redis.Spec.Config {
+		if strings.ToLower(name) != "io-threads" {
+			continue
+		}
+		threads, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid CPU pinning: spec.config: io-threads %q is not a number", value)
+		}
+		if threads > cpus {
+			return fmt.Errorf("invalid CPU pinning: spec.config: io-threads %d exceeds the %d pinned CPUs", threads, cpus)
+		}
+	}
+	return nil
+}
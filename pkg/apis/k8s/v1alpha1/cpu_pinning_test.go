@@ -0,0 +1,88 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func guaranteedResources(cpu, memory string) corev1.ResourceRequirements {
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+	}
+	return corev1.ResourceRequirements{Requests: resources, Limits: resources.DeepCopy()}
+}
+
+func TestPinnedCPUs(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources corev1.ResourceRequirements
+		want      int64
+	}{
+		{"whole", guaranteedResources("2", "1Gi"), 2},
+		{"millicores", guaranteedResources("2000m", "1Gi"), 2},
+		{"fractional", guaranteedResources("1500m", "1Gi"), 0},
+		{"burstable", corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PinnedCPUs(tt.resources); got != tt.want {
+				t.Errorf("PinnedCPUs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedis_validateCPUPinning(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   Profile
+		resources corev1.ResourceRequirements
+		config    map[string]string
+		sidecars  []corev1.Container
+		wantErr   bool
+	}{
+		{"whole CPUs", "", guaranteedResources("2", "1Gi"), map[string]string{"io-threads": "2"}, nil, false},
+		{"mirrored", "", corev1.ResourceRequirements{Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("2"),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		}}, nil, nil, false},
+		{"large profile", ProfileLarge, corev1.ResourceRequirements{}, nil, nil, false},
+		{"small profile", ProfileSmall, corev1.ResourceRequirements{}, nil, nil, true},
+		{"fractional CPUs", "", guaranteedResources("1500m", "1Gi"), nil, nil, true},
+		{"too many io-threads", "", guaranteedResources("2", "1Gi"), map[string]string{"IO-THREADS": "4"}, nil, true},
+		{"burstable sidecar", "", guaranteedResources("2", "1Gi"), nil, []corev1.Container{{Name: "sidecar"}}, true},
+		{"guaranteed sidecar", "", guaranteedResources("2", "1Gi"), nil,
+			[]corev1.Container{{Name: "sidecar", Resources: guaranteedResources("100m", "64Mi")}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{
+				CPUPinning: true,
+				Profile:    tt.profile,
+				Config:     tt.config,
+				Redis:      ContainerSpec{Image: "redis", Resources: tt.resources},
+				Sidecars:   tt.sidecars,
+			}}
+			if err := r.validateCPUPinning(); (err != nil) != tt.wantErr {
+				t.Errorf("validateCPUPinning() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
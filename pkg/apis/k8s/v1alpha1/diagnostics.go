@@ -0,0 +1,20 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+// DiagnosticsAnnotation requests the diagnostics bundle of the Redis to be attached to the bug reports.
+// The bundle is collected into the redis-<name>-diagnostics ConfigMap once the annotation is set and again
+// whenever its value changes, e.g. set to the current time to collect a fresh bundle.
+const DiagnosticsAnnotation = "k8s.amaiz.com/diagnostics"
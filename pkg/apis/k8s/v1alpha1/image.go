@@ -0,0 +1,73 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"regexp"
+	"strings"
+)
+
+// RequireImageDigests makes the validating webhook reject the Redis resources with container images
+// not pinned by digest. It is the operator policy set by the --require-image-digests flag rather than a part of the API.
+var RequireImageDigests = false
+
+// AllowVersionChangeAnnotation set to true lets the operator roll out the Redis image downgrading the running
+// instances or skipping a major version. Such changes are refused otherwise, the RDB and AOF formats
+// are not backward compatible.
+const AllowVersionChangeAnnotation = "k8s.amaiz.com/allow-version-change"
+
+var (
+	// imageDigestRegexp matches the sha256 digest of an image manifest
+	imageDigestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	// imageVersionRegexp matches the version tags the image updates are tracked for, e.g. 6.0.9-alpine
+	imageVersionRegexp = regexp.MustCompile(`^v?\d+\.\d+\.\d+`)
+)
+
+// ImageReference returns the image the container is run with: the image pinned to ImageDigest if set.
+// ImageDigest takes precedence over the digest in Image.
+func (c ContainerSpec) ImageReference() string {
+	if c.ImageDigest == "" {
+		return c.Image
+	}
+	return strings.SplitN(c.Image, "@", 2)[0] + "@" + c.ImageDigest
+}
+
+// ImagePinned reports whether the container image is pinned by digest, either by ImageDigest or in Image
+func (c ContainerSpec) ImagePinned() bool {
+	return c.ImageDigest != "" || imagePinned(c.Image)
+}
+
+// imagePinned reports whether the image reference contains a digest
+func imagePinned(image string) bool {
+	return strings.Contains(image, "@")
+}
+
+// imageTag returns the tag of the image reference or an empty string if omitted
+func imageTag(image string) string {
+	name := strings.SplitN(image, "@", 2)[0]
+	if slash, colon := strings.LastIndex(name, "/"), strings.LastIndex(name, ":"); colon > slash {
+		return name[colon+1:]
+	}
+	return ""
+}
+
+// ImageDigestOf returns the digest of the image reference or of the image ID reported in the container status,
+// e.g. docker-pullable://redis@sha256:..., or an empty string if the reference is not pinned
+func ImageDigestOf(image string) string {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import "testing"
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestContainerSpec_ImageReference(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       ContainerSpec
+		want       string
+		wantPinned bool
+	}{
+		{"tag", ContainerSpec{Image: "redis:6.0"}, "redis:6.0", false},
+		{"digest in image", ContainerSpec{Image: "redis@" + testDigest}, "redis@" + testDigest, true},
+		{"image digest", ContainerSpec{Image: "redis:6.0", ImageDigest: testDigest}, "redis:6.0@" + testDigest, true},
+		{"image digest replaces digest", ContainerSpec{Image: "redis@sha256:00", ImageDigest: testDigest}, "redis@" + testDigest, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.spec.ImageReference(); got != tt.want {
+				t.Errorf("ImageReference() = %v, want %v", got, tt.want)
+			}
+			if got := tt.spec.ImagePinned(); got != tt.wantPinned {
+				t.Errorf("ImagePinned() = %v, want %v", got, tt.wantPinned)
+			}
+		})
+	}
+}
+
+func TestImageDigestOf(t *testing.T) {
+	tests := []struct {
+		name  string
+		image string
+		want  string
+	}{
+		{"docker", "docker-pullable://redis@" + testDigest, testDigest},
+		{"containerd", "docker.io/library/redis@" + testDigest, testDigest},
+		{"image id", "sha256:fedcba", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ImageDigestOf(tt.image); got != tt.want {
+				t.Errorf("ImageDigestOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// DefaultMetricsContainerName is the name of the metrics sidecar if omitted
+	DefaultMetricsContainerName = "metrics"
+	// DefaultMetricsPath is the path the metrics are scraped from if omitted
+	DefaultMetricsPath = "/metrics"
+)
+
+// ExporterProvider is the implementation run by the exporter container
+type ExporterProvider string
+
+const (
+	// ExporterProviderRedisExporter runs oliver006/redis_exporter
+	ExporterProviderRedisExporter ExporterProvider = "redis_exporter"
+	// ExporterProviderTelegraf runs Telegraf with the redis input and the prometheus_client output
+	ExporterProviderTelegraf ExporterProvider = "telegraf"
+)
+
+// DefaultImage returns the image the exporter of the provider runs if the exporter is configured without one
+func (p ExporterProvider) DefaultImage() string {
+	if p == ExporterProviderTelegraf {
+		return DefaultTelegrafImage
+	}
+	return DefaultExporterImage
+}
+
+// defaultResources returns the resources of the exporter of the provider if the exporter is configured without them.
+// Telegraf buffers the metrics of its agent and needs more memory than redis_exporter.
+func (p ExporterProvider) defaultResources() corev1.ResourceRequirements {
+	memoryRequest, memoryLimit := resource.MustParse("32Mi"), resource.MustParse("64Mi")
+	if p == ExporterProviderTelegraf {
+		memoryRequest, memoryLimit = resource.MustParse("64Mi"), resource.MustParse("128Mi")
+	}
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("50m"),
+			corev1.ResourceMemory: memoryRequest,
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceMemory: memoryLimit,
+		},
+	}
+}
+
+// validateExporterProvider checks that the exporter provider is known and is set along with the exporter only
+func (r *Redis) validateExporterProvider() error {
+	switch r.Spec.ExporterProvider {
+	case "":
+		return nil
+	case ExporterProviderRedisExporter, ExporterProviderTelegraf:
+	default:
+		return fmt.Errorf("invalid metrics: spec.exporterProvider: unknown provider %q", r.Spec.ExporterProvider)
+	}
+	if reflect.DeepEqual(r.Spec.Exporter, ContainerSpec{}) {
+		return fmt.Errorf("invalid metrics: spec.exporterProvider: must be set along with spec.exporter")
+	}
+	return nil
+}
+
+// ContainerName returns the name of the metrics sidecar, DefaultMetricsContainerName if omitted
+func (m *Metrics) ContainerName() string {
+	if m.Container.Name == "" {
+		return DefaultMetricsContainerName
+	}
+	return m.Container.Name
+}
+
+// MetricsPath returns the path the metrics are scraped from, DefaultMetricsPath if omitted
+func (m *Metrics) MetricsPath() string {
+	if m.Path == "" {
+		return DefaultMetricsPath
+	}
+	return m.Path
+}
+
+// validateMetrics checks that the metrics sidecar is not set along with the exporter, is not named as the redis
+// container and has an image and a port
+func (r *Redis) validateMetrics() error {
+	if r.Spec.Metrics == nil {
+		return nil
+	}
+	if !reflect.DeepEqual(r.Spec.Exporter, ContainerSpec{}) {
+		return fmt.Errorf("invalid metrics: spec.metrics: must not be set along with spec.exporter")
+	}
+	if name := r.Spec.Metrics.ContainerName(); name == redisContainerName {
+		return fmt.Errorf("invalid metrics: spec.metrics.container.name: %q is already used", name)
+	}
+	if r.Spec.Metrics.Container.Image == "" {
+		return fmt.Errorf("invalid metrics: spec.metrics.container.image: must be set")
+	}
+	if port := r.Spec.Metrics.Port; port < 1 || port > 65535 {
+		return fmt.Errorf("invalid metrics: spec.metrics.port: %d is not a valid port", port)
+	}
+	for _, port := range r.Spec.Metrics.Container.Ports {
+		if port.ContainerPort == r.Spec.Metrics.Port && port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+			return fmt.Errorf("invalid metrics: spec.metrics.container.ports: port %d must be TCP", port.ContainerPort)
+		}
+	}
+	return nil
+}
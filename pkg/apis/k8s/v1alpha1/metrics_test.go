@@ -0,0 +1,72 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRedis_validateMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter ContainerSpec
+		metrics  *Metrics
+		wantErr  bool
+	}{
+		{"none", ContainerSpec{}, nil, false},
+		{"metrics", ContainerSpec{}, &Metrics{Container: corev1.Container{Image: "exporter"}, Port: 9121}, false},
+		{"along with exporter", ContainerSpec{Image: DefaultExporterImage},
+			&Metrics{Container: corev1.Container{Image: "exporter"}, Port: 9121}, true},
+		{"no image", ContainerSpec{}, &Metrics{Port: 9121}, true},
+		{"no port", ContainerSpec{}, &Metrics{Container: corev1.Container{Image: "exporter"}}, true},
+		{"named redis", ContainerSpec{}, &Metrics{Container: corev1.Container{Name: "redis", Image: "exporter"}, Port: 9121}, true},
+		{"UDP port", ContainerSpec{}, &Metrics{Container: corev1.Container{Image: "exporter", Ports: []corev1.ContainerPort{
+			{ContainerPort: 9121, Protocol: corev1.ProtocolUDP},
+		}}, Port: 9121}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Exporter: tt.exporter, Metrics: tt.metrics}}
+			if err := r.validateMetrics(); (err != nil) != tt.wantErr {
+				t.Errorf("validateMetrics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validateExporterProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter ContainerSpec
+		provider ExporterProvider
+		wantErr  bool
+	}{
+		{"none", ContainerSpec{}, "", false},
+		{"redis_exporter", ContainerSpec{Image: DefaultExporterImage}, ExporterProviderRedisExporter, false},
+		{"telegraf", ContainerSpec{Image: DefaultTelegrafImage}, ExporterProviderTelegraf, false},
+		{"unknown", ContainerSpec{Image: DefaultExporterImage}, "collectd", true},
+		{"without exporter", ContainerSpec{}, ExporterProviderTelegraf, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Exporter: tt.exporter, ExporterProvider: tt.provider}}
+			if err := r.validateExporterProvider(); (err != nil) != tt.wantErr {
+				t.Errorf("validateExporterProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestOutputs_stable pins the serialized outputs: the external tools read them by the field paths,
+// so a change of this test is an incompatible change requiring a new OutputsVersion
+func TestOutputs_stable(t *testing.T) {
+	outputs := Outputs{
+		Version:                     OutputsVersion,
+		MasterHost:                  "redis-example-master.default.svc.cluster.local",
+		ReplicaHost:                 "redis-example-replica.default.svc.cluster.local",
+		Port:                        6379,
+		TLS:                         true,
+		PasswordSecretName:          "redis-password",
+		PasswordSecretKey:           "password",
+		ConnectionInfoConfigMapName: "redis-example-connection",
+		BindingSecretName:           "redis-example-binding",
+	}
+	want := `{"version":"v1",` +
+		`"masterHost":"redis-example-master.default.svc.cluster.local",` +
+		`"replicaHost":"redis-example-replica.default.svc.cluster.local",` +
+		`"port":6379,"tls":true,"passwordSecretName":"redis-password","passwordSecretKey":"password",` +
+		`"connectionInfoConfigMapName":"redis-example-connection","bindingSecretName":"redis-example-binding"}`
+
+	got, err := json.Marshal(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("json.Marshal() = %s, want %s", got, want)
+	}
+}
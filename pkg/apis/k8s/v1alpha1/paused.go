@@ -0,0 +1,30 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import "strconv"
+
+// PausedAnnotation pauses the reconciliation of the Redis the same way Spec.Paused does when set to true.
+// Unlike the spec it can be set on the resources managed by the GitOps tools without changing the manifests.
+const PausedAnnotation = "k8s.amaiz.com/paused"
+
+// Paused reports whether the reconciliation of the Redis is paused by Spec.Paused or the PausedAnnotation
+func (r *Redis) Paused() bool {
+	if r.Spec.Paused {
+		return true
+	}
+	paused, err := strconv.ParseBool(r.GetAnnotations()[PausedAnnotation])
+	return err == nil && paused
+}
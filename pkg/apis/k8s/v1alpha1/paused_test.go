@@ -0,0 +1,45 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRedis_Paused(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        bool
+		annotations map[string]string
+		want        bool
+	}{
+		{"not paused", false, nil, false},
+		{"spec", true, nil, true},
+		{"annotation", false, map[string]string{PausedAnnotation: "true"}, true},
+		{"annotation false", false, map[string]string{PausedAnnotation: "false"}, false},
+		{"annotation invalid", false, map[string]string{PausedAnnotation: "yes"}, false},
+		{"spec overrides annotation", true, map[string]string{PausedAnnotation: "false"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}, Spec: RedisSpec{Paused: tt.spec}}
+			if got := r.Paused(); got != tt.want {
+				t.Errorf("Paused() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Profile is a preset of the redis container resources and the configuration directives sized along with them
+type Profile string
+
+const (
+	// ProfileSmall fits caches and development environments
+	ProfileSmall Profile = "small"
+	// ProfileMedium fits the general production workloads
+	ProfileMedium Profile = "medium"
+	// ProfileLarge fits the write-heavy workloads and large datasets
+	ProfileLarge Profile = "large"
+)
+
+// profilePreset is what a Profile expands into. maxmemory leaves a quarter of the memory limit
+// to the copy-on-write of the background saves and to the replication buffers.
+type profilePreset struct {
+	cpu, memory string
+	config      map[string]string
+}
+
+var profilePresets = map[Profile]profilePreset{
+	ProfileSmall: {cpu: "250m", memory: "512Mi", config: map[string]string{
+		"maxmemory":         "384mb",
+		"repl-backlog-size": "16mb",
+	}},
+	ProfileMedium: {cpu: "1", memory: "2Gi", config: map[string]string{
+		"maxmemory":         "1536mb",
+		"repl-backlog-size": "64mb",
+		"io-threads":        "2",
+	}},
+	ProfileLarge: {cpu: "4", memory: "8Gi", config: map[string]string{
+		"maxmemory":         "6gb",
+		"repl-backlog-size": "256mb",
+		"io-threads":        "4",
+	}},
+}
+
+// ValidProfile reports whether the profile is one of the presets
+func ValidProfile(profile Profile) bool {
+	_, ok := profilePresets[profile]
+	return ok
+}
+
+// ApplyProfile expands Spec.Profile into the spec. The resources of the redis container are set unless
+// any are set in the spec, and the configuration directives are set unless present in Spec.Config.
+// maxmemory is sized for the resources of the profile, hence it is not set along with the resources of the spec.
+func (r *Redis) ApplyProfile() {
+	preset, ok := profilePresets[r.Spec.Profile]
+	if !ok {
+		return
+	}
+
+	profileResources := reflect.DeepEqual(r.Spec.Redis.Resources, corev1.ResourceRequirements{})
+	if profileResources {
+		r.Spec.Redis.Resources = corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(preset.cpu),
+				corev1.ResourceMemory: resource.MustParse(preset.memory),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse(preset.memory),
+			},
+		}
+	}
+
+	config := make(map[string]string, len(r.Spec.Config)+len(preset.config))
+	for k, v := range preset.config {
+		if k == "maxmemory" && !profileResources {
+			continue
+		}
+		config[k] = v
+	}
+	// the directive names are case-insensitive
+	for k := range r.Spec.Config {
+		delete(config, strings.ToLower(k))
+	}
+	for k, v := range r.Spec.Config {
+		config[k] = v
+	}
+	r.Spec.Config = config
+}
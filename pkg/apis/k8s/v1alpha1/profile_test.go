@@ -0,0 +1,74 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestRedis_ApplyProfile(t *testing.T) {
+	limits := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}}
+	tests := []struct {
+		name       string
+		profile    Profile
+		resources  corev1.ResourceRequirements
+		config     map[string]string
+		wantMemory string
+		wantConfig map[string]string
+	}{
+		{"no profile", "", corev1.ResourceRequirements{}, map[string]string{"maxmemory": "1gb"}, "",
+			map[string]string{"maxmemory": "1gb"}},
+		{"small", ProfileSmall, corev1.ResourceRequirements{}, nil, "512Mi",
+			map[string]string{"maxmemory": "384mb", "repl-backlog-size": "16mb"}},
+		{"overridden directive", ProfileMedium, corev1.ResourceRequirements{}, map[string]string{"IO-Threads": "3"}, "2Gi",
+			map[string]string{"maxmemory": "1536mb", "repl-backlog-size": "64mb", "IO-Threads": "3"}},
+		{"overridden resources", ProfileLarge, limits, nil, "1Gi",
+			map[string]string{"repl-backlog-size": "256mb", "io-threads": "4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Profile: tt.profile, Config: tt.config, Redis: ContainerSpec{Resources: tt.resources}}}
+			r.ApplyProfile()
+			if !reflect.DeepEqual(r.Spec.Config, tt.wantConfig) {
+				t.Errorf("ApplyProfile() config = %v, want %v", r.Spec.Config, tt.wantConfig)
+			}
+			memory := r.Spec.Redis.Resources.Limits[corev1.ResourceMemory]
+			if tt.wantMemory != "" && memory.String() != tt.wantMemory {
+				t.Errorf("ApplyProfile() memory limit = %s, want %s", memory.String(), tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestProfilePresets_valid(t *testing.T) {
+	for profile := range profilePresets {
+		r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, Profile: profile}}
+		if err := r.ValidateCreate(); err != nil {
+			t.Errorf("ValidateCreate() profile %s: %v", profile, err)
+		}
+		r.ApplyProfile()
+		if err := r.ValidateConfig(); err != nil {
+			t.Errorf("ValidateConfig() profile %s: %v", profile, err)
+		}
+	}
+	r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, Profile: "huge"}}
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("ValidateCreate() accepted an unknown profile")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// RequireGuaranteedQoS makes the webhooks enforce the Guaranteed QoS class of the redis container, so the masters
+// are neither throttled below their requests nor evicted first under the node pressure. The mutating webhook
+// and the operator mirror the missing requests and limits, and the validating webhook rejects the redis container
+// without equal CPU and memory requests and limits. It is the operator policy set by the --require-guaranteed-qos flag.
+var RequireGuaranteedQoS = false
+
+// guaranteedResourceNames are the resources the requests and limits of which must be equal for the Guaranteed QoS
+var guaranteedResourceNames = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}
+
+// MirrorResources sets the missing CPU and memory requests to the limits and the missing limits to the requests.
+// The requests and limits set to different values are kept as is.
+func MirrorResources(resources *corev1.ResourceRequirements) {
+	for _, name := range guaranteedResourceNames {
+		request, requested := resources.Requests[name]
+		limit, limited := resources.Limits[name]
+		switch {
+		case requested && !limited:
+			if resources.Limits == nil {
+				resources.Limits = make(corev1.ResourceList)
+			}
+			resources.Limits[name] = request.DeepCopy()
+		case limited && !requested:
+			if resources.Requests == nil {
+				resources.Requests = make(corev1.ResourceList)
+			}
+			resources.Requests[name] = limit.DeepCopy()
+		}
+	}
+}
+
+// validateGuaranteedResources checks that the CPU and memory requests and limits are set and equal
+func validateGuaranteedResources(resources corev1.ResourceRequirements) error {
+	for _, name := range guaranteedResourceNames {
+		request, requested := resources.Requests[name]
+		limit, limited := resources.Limits[name]
+		if !requested || !limited {
+			return fmt.Errorf("the %s request or limit is not set", name)
+		}
+		if request.Cmp(limit) != 0 {
+			return fmt.Errorf("the %s request %s is not equal to the limit %s", name, request.String(), limit.String())
+		}
+	}
+	return nil
+}
+
+// validateQoS checks that the redis container is of the Guaranteed QoS class if RequireGuaranteedQoS is set.
+// The resources of the profile are mirrored the same way the operator does.
+func (r *Redis) validateQoS() error {
+	if !RequireGuaranteedQoS {
+		return nil
+	}
+	redis := r.DeepCopy()
+	redis.ApplyProfile()
+	MirrorResources(&redis.Spec.Redis.Resources)
+	if err := validateGuaranteedResources(redis.Spec.Redis.Resources); err != nil {
+		return fmt.Errorf("invalid redis: spec.redis.resources: Guaranteed QoS is required: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestMirrorResources(t *testing.T) {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}
+	MirrorResources(&resources)
+	if err := validateGuaranteedResources(resources); err != nil {
+		t.Errorf("MirrorResources() = %+v, not guaranteed: %v", resources, err)
+	}
+
+	unequal := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+	}
+	MirrorResources(&unequal)
+	if got := unequal.Limits[corev1.ResourceCPU]; got.String() != "2" {
+		t.Errorf("MirrorResources() changed the CPU limit to %s", got.String())
+	}
+	if err := validateGuaranteedResources(unequal); err == nil {
+		t.Errorf("validateGuaranteedResources() accepted unequal CPU request and limit")
+	}
+}
+
+func TestRedis_validateQoS(t *testing.T) {
+	guaranteed := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}
+	burstable := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+	}
+	tests := []struct {
+		name      string
+		required  bool
+		profile   Profile
+		resources corev1.ResourceRequirements
+		wantErr   bool
+	}{
+		{"not required", false, "", corev1.ResourceRequirements{}, false},
+		{"mirrored", true, "", guaranteed, false},
+		{"profile", true, ProfileSmall, corev1.ResourceRequirements{}, false},
+		{"no resources", true, "", corev1.ResourceRequirements{}, true},
+		{"burstable", true, "", burstable, true},
+	}
+	defer func(required bool) { RequireGuaranteedQoS = required }(RequireGuaranteedQoS)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			RequireGuaranteedQoS = tt.required
+			r := &Redis{Spec: RedisSpec{Profile: tt.profile, Redis: ContainerSpec{Image: "redis", Resources: tt.resources}}}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_Default_guaranteedQoS(t *testing.T) {
+	defer func(required bool) { RequireGuaranteedQoS = required }(RequireGuaranteedQoS)
+	RequireGuaranteedQoS = true
+
+	r := &Redis{Spec: RedisSpec{
+		Redis: ContainerSpec{Image: "redis", Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("2"),
+			corev1.ResourceMemory: resource.MustParse("4Gi"),
+		}}},
+		Exporter: ContainerSpec{Image: "oliver006/redis_exporter"},
+	}}
+	r.Default()
+	for name, resources := range map[string]corev1.ResourceRequirements{
+		"redis":    r.Spec.Redis.Resources,
+		"exporter": r.Spec.Exporter.Resources,
+	} {
+		if err := validateGuaranteedResources(resources); err != nil {
+			t.Errorf("Default() %s resources = %+v, not guaranteed: %v", name, resources, err)
+		}
+	}
+}
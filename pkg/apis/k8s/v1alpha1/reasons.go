@@ -0,0 +1,164 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+// Reasons are the machine-readable identifiers of the Redis state transitions.
+// The same reason is used in the conditions, the Events, the operator logs and the metrics labels,
+// so alerts and runbooks can rely on them. The values are stable and must not be changed.
+const (
+	// ReasonReady means that the Redis is ready
+	ReasonReady = "Ready"
+	// ReasonNotReady means that the state of the Redis has not been observed yet
+	ReasonNotReady = "NotReady"
+
+	// ReasonConfigValid means that the spec and the referenced Secrets are valid
+	ReasonConfigValid = "ConfigValid"
+	// ReasonConfigInvalid means that the spec, an ACL user or the TLS certificate is invalid
+	ReasonConfigInvalid = "ConfigInvalid"
+	// ReasonSecretMissing means that a referenced Secret or its key can not be read
+	ReasonSecretMissing = "SecretMissing"
+	// ReasonClassMissing means that the RedisClass referenced by the Redis does not exist
+	ReasonClassMissing = "ClassMissing"
+	// ReasonRestoreSourceUnavailable means that the backup to restore the data from can not be resolved
+	ReasonRestoreSourceUnavailable = "RestoreSourceUnavailable"
+
+	// ReasonReplicationConfigured means that the instances replicate from the master
+	ReasonReplicationConfigured = "ReplicationConfigured"
+	// ReasonQuorumNotMet means that fewer instances than the minimum replication size are reachable
+	ReasonQuorumNotMet = "QuorumNotMet"
+	// ReasonQuorumMet means that at least the minimum replication size of instances are reachable
+	ReasonQuorumMet = "QuorumMet"
+	// ReasonInstancesNotReady means that some instances are reachable but loading the dataset or busy running a script
+	ReasonInstancesNotReady = "InstancesNotReady"
+	// ReasonReplicationFailed means that the instances can not be reconfigured as replicas of the master
+	ReasonReplicationFailed = "ReplicationFailed"
+	// ReasonPromotionFailed means that no replica could be promoted after the master has been lost
+	ReasonPromotionFailed = "PromotionFailed"
+	// ReasonReplicasReconfigured means that instances have been reconfigured as replicas of the master
+	ReasonReplicasReconfigured = "ReplicasReconfigured"
+	// ReasonFullSyncStuck means that a replica has been in the full synchronization with the master for too long
+	ReasonFullSyncStuck = "FullSyncStuck"
+	// ReasonPasswordRotated means that the changed password has been applied to the running instances
+	ReasonPasswordRotated = "PasswordRotated"
+	// ReasonPasswordRotationFailed means that the changed password could not be applied to the running instances
+	ReasonPasswordRotationFailed = "PasswordRotationFailed"
+	// ReasonConfigApplied means that the changed configuration has been applied to the running instances
+	ReasonConfigApplied = "ConfigApplied"
+	// ReasonConfigApplyFailed means that the changed configuration could not be applied to the running instances
+	ReasonConfigApplyFailed = "ConfigApplyFailed"
+	// ReasonRuntimeConfigReplayed means that the runtime configuration of the former master has been set on the new one
+	ReasonRuntimeConfigReplayed = "RuntimeConfigReplayed"
+
+	// ReasonMasterElected means that the master is elected
+	ReasonMasterElected = "MasterElected"
+	// ReasonMasterPromoted means that a replica has been promoted after the master has been lost
+	ReasonMasterPromoted = "MasterPromoted"
+	// ReasonNoMaster means that no master is discovered
+	ReasonNoMaster = "NoMaster"
+	// ReasonMasterHandedOver means that the master role has been handed over to a replica ahead of the scale-down
+	// or the restart of the master
+	ReasonMasterHandedOver = "MasterHandedOver"
+	// ReasonHandoverFailed means that the master role could not be handed over ahead of the scale-down
+	ReasonHandoverFailed = "HandoverFailed"
+	// ReasonPodRolledOut means that a Pod has been deleted by the operator to be recreated with the update revision
+	ReasonPodRolledOut = "PodRolledOut"
+	// ReasonPaused means that the reconciliation is paused
+	ReasonPaused = "Paused"
+	// ReasonResumed means that the reconciliation is resumed
+	ReasonResumed = "Resumed"
+	// ReasonPreDeleteHookCompleted means that the Job of the pre-delete hook has completed and the Redis is deleted
+	ReasonPreDeleteHookCompleted = "PreDeleteHookCompleted"
+	// ReasonPreDeleteHookFailed means that the Job of the pre-delete hook has failed
+	ReasonPreDeleteHookFailed = "PreDeleteHookFailed"
+
+	// ReasonAllInstancesReady means that all the desired instances are ready
+	ReasonAllInstancesReady = "AllInstancesReady"
+	// ReasonInstancesUnavailable means that fewer instances than desired are ready
+	ReasonInstancesUnavailable = "InstancesUnavailable"
+
+	// ReasonReconcileCompleted means that the reconciliation has completed within the deadline
+	ReasonReconcileCompleted = "ReconcileCompleted"
+	// ReasonReconcileTimeout means that the reconciliation has not completed within the deadline
+	ReasonReconcileTimeout = "ReconcileTimeout"
+
+	// ReasonPersistenceOK means that RDB and AOF writes succeed on all instances
+	ReasonPersistenceOK = "PersistenceOK"
+	// ReasonPersistenceError means that at least one instance reports failed RDB or AOF writes
+	ReasonPersistenceError = "PersistenceError"
+
+	// ReasonAOFFsyncOK means that no AOF writes have been delayed by fsync since the previous check
+	ReasonAOFFsyncOK = "AOFFsyncOK"
+	// ReasonAOFFsyncDelayed means that the storage does not keep up with the AOF fsync policy
+	ReasonAOFFsyncDelayed = "AOFFsyncDelayed"
+
+	// ReasonHostSettingsOK means that the preflight check found no node settings degrading Redis
+	ReasonHostSettingsOK = "HostSettingsOK"
+	// ReasonHostSettingsDegraded means that the preflight check found the node settings degrading Redis
+	ReasonHostSettingsDegraded = "HostSettingsDegraded"
+
+	// ReasonPubSubOK means that all the replicas have received the Pub/Sub canary message
+	ReasonPubSubOK = "PubSubOK"
+	// ReasonPubSubPropagationFailed means that at least one replica has not received the Pub/Sub canary message
+	ReasonPubSubPropagationFailed = "PubSubPropagationFailed"
+	// ReasonPubSubCheckFailed means that the Pub/Sub canary message could not be published or subscribed to
+	ReasonPubSubCheckFailed = "PubSubCheckFailed"
+
+	// ReasonEvictionRateOK means that no instance has evicted more keys per second than the threshold
+	ReasonEvictionRateOK = "EvictionRateOK"
+	// ReasonEvictionRateHigh means that the instances evict the keys to stay within maxmemory
+	ReasonEvictionRateHigh = "EvictionRateHigh"
+
+	// ReasonClassApplied means that the spec complies with the fields locked by the RedisClass
+	ReasonClassApplied = "ClassApplied"
+	// ReasonLockedFieldsOverridden means that the spec sets the fields locked by the RedisClass to other values
+	ReasonLockedFieldsOverridden = "LockedFieldsOverridden"
+
+	// ReasonBlueGreenFailed means that the blue/green deployment could not proceed, e.g. the blue Redis is not found
+	ReasonBlueGreenFailed = "BlueGreenFailed"
+	// ReasonCutOver means that the green master has taken the master role over from the blue master
+	ReasonCutOver = "CutOver"
+	// ReasonDemoted means that the blue instances have been reconfigured as replicas of the green master
+	ReasonDemoted = "Demoted"
+
+	// ReasonDataVolumeUsageOK means that the usage of all the data volumes is below the threshold
+	ReasonDataVolumeUsageOK = "DataVolumeUsageOK"
+	// ReasonDataVolumeUsageHigh means that the usage of at least one data volume exceeds the threshold
+	ReasonDataVolumeUsageHigh = "DataVolumeUsageHigh"
+	// ReasonDataVolumeStatsUnavailable means that the data volume usage can not be fetched from the kubelet
+	ReasonDataVolumeStatsUnavailable = "StatsUnavailable"
+	// ReasonVolumeExpanded means that the data volumes have been expanded to the grown storage request
+	ReasonVolumeExpanded = "VolumeExpanded"
+	// ReasonVolumeExpansionNotAllowed means that the StorageClass of a data volume does not allow the expansion
+	ReasonVolumeExpansionNotAllowed = "VolumeExpansionNotAllowed"
+
+	// ReasonImagesVerified means that the signatures of all the images have been verified
+	ReasonImagesVerified = "ImagesVerified"
+	// ReasonImageSignatureInvalid means that an image is not pinned by digest or is not signed by the configured keys
+	ReasonImageSignatureInvalid = "ImageSignatureInvalid"
+	// ReasonImageVerificationUnavailable means that the signatures can not be read from the registry
+	ReasonImageVerificationUnavailable = "ImageVerificationUnavailable"
+	// ReasonDowngradeRefused means that the image would downgrade the running instances
+	ReasonDowngradeRefused = "DowngradeRefused"
+	// ReasonMajorVersionJumpRefused means that the image would skip a major version of the running instances
+	ReasonMajorVersionJumpRefused = "MajorVersionJumpRefused"
+
+	// ReasonCreated and ReasonUpdated mean that the operator has created or updated an owned resource
+	ReasonCreated = "Created"
+	ReasonUpdated = "Updated"
+	// ReasonAdopted means that the operator has adopted an existing resource of the generated name without a controller
+	ReasonAdopted = "Adopted"
+	// ReasonDiagnosticsCollected means that the diagnostics bundle requested by the annotation has been collected
+	ReasonDiagnosticsCollected = "DiagnosticsCollected"
+)
@@ -15,15 +15,19 @@
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Redis is the Schema for the redis API
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description="Whether the Redis is ready"
 // +kubebuilder:printcolumn:name="Master",type="string",JSONPath=".status.master",description="Current master's Pod name"
 // +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.replicas",description="Current number of Redis instances"
 // +kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".spec.replicas",description="Desired number of Redis instances"
+// +kubebuilder:printcolumn:name="Class",type="string",JSONPath=".spec.className",description="RedisClass of the Redis",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:subresource:status
 // +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas
@@ -47,13 +51,63 @@ type RedisSpec struct {
 	// +kubebuilder:validation:Minimum=3
 	Replicas *int32 `json:"replicas"`
 
+	// ClassName is the name of the RedisClass the omitted fields default to, e.g. the images, the resources,
+	// the configuration, the backup and the Services
+	// +optional
+	ClassName string `json:"className,omitempty"`
+
+	// Port is the port Redis listens on and the Services expose, TLS is served on it if enabled.
+	// Defaults to 6379. Can not be changed.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Addressing selects how the instances address each other: by the Pod IPs, the default, or by the DNS names
+	// of the Pods under the headless Service. With Hostname the replicas follow the master by its DNS name
+	// in REPLICAOF and in the configuration, so the replication survives the Pod IP changes.
+	// +kubebuilder:validation:Enum=IP;Hostname
+	// +optional
+	Addressing Addressing `json:"addressing,omitempty"`
+
 	// Config allows to pass custom Redis configuration parameters
 	Config   map[string]string `json:"config,omitempty"`
 	Password Password          `json:"password,omitempty"`
 
+	// Profile is the preset of the redis container resources and of the maxmemory, io-threads
+	// and repl-backlog-size directives sized along with them. The resources and the directives set
+	// in the spec take precedence, maxmemory is not set along with the resources of the spec.
+	// The medium and large profiles set io-threads requiring Redis 6+.
+	// +kubebuilder:validation:Enum=small;medium;large
+	// +optional
+	Profile Profile `json:"profile,omitempty"`
+
+	// CPUPinning makes the redis container eligible for the exclusive CPUs on the nodes with the static CPU manager
+	// policy: the requests and limits of the containers managed by the operator are mirrored, the Pods must be
+	// of the Guaranteed QoS class with a whole number of CPUs requested by the redis container, and io-threads
+	// must not exceed the CPUs. The Pods are annotated with the number of the pinned CPUs.
+	// +optional
+	CPUPinning bool `json:"cpuPinning,omitempty"`
+
+	// HostPreflight runs the preflight init container checking the node settings silently degrading Redis:
+	// the transparent huge pages, the memory overcommit and the NUMA zone reclaim. The findings are reported
+	// with the HostSettingsDegraded condition and a Warning Event, the Pods are started regardless.
+	// +optional
+	HostPreflight bool `json:"hostPreflight,omitempty"`
+
+	// PubSubCheck publishes a canary message to a reserved channel on the master every reconciliation and checks
+	// that the subscriptions on the replicas receive it, detecting the broken Pub/Sub propagation INFO does not show.
+	// The findings are reported with the PubSubDegraded condition and a Warning Event.
+	// +optional
+	PubSubCheck bool `json:"pubSubCheck,omitempty"`
+
+	// ACL allows to manage Redis 6+ ACL users
+	ACL *ACL `json:"acl,omitempty"`
+
 	// Pod annotations
 	Annotations map[string]string `json:"annotations,omitempty"`
-	// Pod securityContext
+	// Pod securityContext. The sysctls must be namespaced, e.g. net.core.somaxconn, the unsafe ones
+	// must be allowed by the kubelets. net.core.somaxconn must not be lower than tcp-backlog.
 	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
 	// Pod affinity
 	Affinity *corev1.Affinity `json:"affinity,omitempty"`
@@ -69,46 +123,617 @@ type RedisSpec struct {
 	// Pod ImagePullSecrets
 	// More info: https://kubernetes.io/docs/concepts/containers/images#specifying-imagepullsecrets-on-a-pod
 	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// ImageVerification requires the images of the Redis Pods to be signed by cosign.
+	// The StatefulSet is not updated until all the images are verified.
+	// +optional
+	ImageVerification *ImageVerification `json:"imageVerification,omitempty"`
+	// ImageUpdatePolicy makes the operator update the Redis image to the newest release
+	// within the version track of the spec.redis.image tag
+	// +optional
+	ImageUpdatePolicy *ImageUpdatePolicy `json:"imageUpdatePolicy,omitempty"`
 	// Pod priorityClassName
 	PriorityClassName string `json:"priorityClassName,omitempty"`
 	// DataVolumeClaimTemplate for StatefulSet
 	DataVolumeClaimTemplate corev1.PersistentVolumeClaim `json:"dataVolumeClaimTemplate,omitempty"`
+	// DataVolumeUsageThreshold is the data volume usage in percents above which
+	// the DataVolumeUsageHigh condition is raised. Usage is collected from kubelet stats.
+	// Monitoring is disabled if omitted or if DataVolumeClaimTemplate is not defined.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	DataVolumeUsageThreshold *int32 `json:"dataVolumeUsageThreshold,omitempty"`
+	// EvictionRateThreshold enables sampling the keyspace of the instances every minute: the number of keys,
+	// the keys with a TTL and the rates of the expired and evicted keys are exported as the operator metrics.
+	// The EvictionRateHigh condition is raised once any instance evicts more keys per second than the threshold.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	EvictionRateThreshold *int32 `json:"evictionRateThreshold,omitempty"`
+	// MaxFullSyncDuration is how long the replicas are allowed to stay in the full synchronization with the master,
+	// i.e. to report master_sync_in_progress, e.g. 30m. The replicas syncing for longer are never promoted to master
+	// and their Pods are restarted with a Warning Event. Disabled if omitted.
+	// +optional
+	MaxFullSyncDuration *metav1.Duration `json:"maxFullSyncDuration,omitempty"`
+	// VolumeExpansion configures the expansion of the data volumes. The PersistentVolumeClaims are expanded
+	// once the storage request of DataVolumeClaimTemplate grows if their StorageClass allows the expansion.
+	// +optional
+	VolumeExpansion *VolumeExpansion `json:"volumeExpansion,omitempty"`
 	// Volumes for StatefulSet
 	Volumes []corev1.Volume `json:"volumes,omitempty"`
+	// UpdateStrategy of the StatefulSet. The RollingUpdate partition rolls out the Pods with the ordinals
+	// greater or equal to it only, e.g. as a canary. OnDelete updates the Pods once they are deleted.
+	// Defaults to RollingUpdate of all the Pods.
+	// +optional
+	UpdateStrategy *appsv1.StatefulSetUpdateStrategy `json:"updateStrategy,omitempty"`
+	// OrchestratedUpdate rolls the Pods out by the operator instead of the StatefulSet controller: the replicas
+	// are restarted one by one once the previous one has caught up with the master, the master is restarted last
+	// after handing its role over to an updated replica. The StatefulSet is updated with the OnDelete strategy,
+	// hence UpdateStrategy must not be set along with it.
+	// +optional
+	OrchestratedUpdate *OrchestratedUpdate `json:"orchestratedUpdate,omitempty"`
+	// Handover configures the handovers of the master role ahead of the scale-downs and the orchestrated updates
+	// +optional
+	Handover *Handover `json:"handover,omitempty"`
+	// PersistentVolumeClaimRetentionPolicy of the StatefulSet controls whether the data volume claims are deleted
+	// along with the Redis or with the Pods removed by scaling down. The claims are retained by default.
+	// +optional
+	PersistentVolumeClaimRetentionPolicy *PersistentVolumeClaimRetentionPolicy `json:"persistentVolumeClaimRetentionPolicy,omitempty"`
 
-	// Redis container specification
-	Redis ContainerSpec `json:"redis"`
+	// Redis container specification. The image is required unless ClassName is set.
+	Redis ContainerSpec `json:"redis,omitempty"`
 
-	// Exporter container specification
+	// Exporter container specification. Superseded by Metrics, the exporter is run with the flags and the port
+	// of oliver006/redis_exporter.
 	Exporter ContainerSpec `json:"exporter,omitempty"`
+	// ExporterProvider is the implementation run by the Exporter container: redis_exporter, the default,
+	// or telegraf, the Telegraf redis input served by the prometheus_client output. The image and the resources
+	// of the Exporter default to the ones of the provider.
+	// +kubebuilder:validation:Enum=redis_exporter;telegraf
+	// +optional
+	ExporterProvider ExporterProvider `json:"exporterProvider,omitempty"`
+	// Metrics is the generic metrics sidecar run instead of the Exporter, e.g. with other exporter images.
+	// Can not be set along with Exporter.
+	// +optional
+	Metrics *Metrics `json:"metrics,omitempty"`
 
 	// Pod initContainers
 	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// Sidecars are the Pod containers run next to Redis and the exporter, e.g. log shippers
+	// +optional
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+	// SidecarMounts mounts the generated configuration into the sidecars
+	// +optional
+	SidecarMounts *SidecarMounts `json:"sidecarMounts,omitempty"`
+
+	// TLS enables encryption of client and replication connections
+	TLS *TLS `json:"tls,omitempty"`
+
+	// Backup configures RDB snapshots uploaded to object storage.
+	// Snapshots are taken by creating RedisBackup resources referring to this Redis.
+	Backup *Backup `json:"backup,omitempty"`
+
+	// Restore initializes the data of new instances from a snapshot
+	Restore *Restore `json:"restore,omitempty"`
+
+	// BlueGreen makes the Redis the green deployment replacing the blue Redis, e.g. running a new major version.
+	// The instances replicate from the blue master until the cutover.
+	// +optional
+	BlueGreen *BlueGreen `json:"blueGreen,omitempty"`
+
+	// Service configures the master Service clients connect to
+	// +optional
+	Service *Service `json:"service,omitempty"`
+
+	// ReplicaService configures the Service selecting the replicas for the read/write splitting
+	// +optional
+	ReplicaService *ReplicaService `json:"replicaService,omitempty"`
+
+	// ExternalAccess exposes every Redis instance outside of the cluster with a Service of its own
+	// +optional
+	ExternalAccess *ExternalAccess `json:"externalAccess,omitempty"`
+
+	// NodeLocalCache runs the read-only replicas of the master as a DaemonSet on the selected nodes
+	// along with the Service routing the clients to the replica on their own node
+	// +optional
+	NodeLocalCache *NodeLocalCache `json:"nodeLocalCache,omitempty"`
+
+	// NetworkPolicy generates the NetworkPolicy restricting the ingress traffic of the Redis Pods
+	// +optional
+	NetworkPolicy *NetworkPolicy `json:"networkPolicy,omitempty"`
+
+	// ConnectionInfo generates the redis-<name>-connection ConfigMap with the DNS names of the master and
+	// the replica Services, the port and whether TLS is enabled, e.g. for the application charts to consume
+	// +optional
+	ConnectionInfo bool `json:"connectionInfo,omitempty"`
+
+	// Paused stops the reconciliation of the owned resources and of the replication, e.g. for the manual maintenance.
+	// The Redis is paused by the PausedAnnotation as well.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// PreDeleteHook is the Job run when the Redis is deleted, e.g. to take the final dump or to deregister it.
+	// The Redis and the owned resources are kept until the Job has completed.
+	// +optional
+	PreDeleteHook *PreDeleteHook `json:"preDeleteHook,omitempty"`
+
+	// ServiceBinding generates the redis-<name>-binding Secret of the Service Binding specification with the type,
+	// host, port, password and uri of the master, and exposes it in Status.Binding for the workloads to bind to
+	// +optional
+	ServiceBinding bool `json:"serviceBinding,omitempty"`
+}
+
+// Metrics is the metrics sidecar. Unlike the Exporter the container is run as specified: the image, the args,
+// the env, the ports and the probes are up to the spec. The generated files are mounted as into the sidecars.
+type Metrics struct {
+	// Container is the metrics sidecar. The name defaults to metrics.
+	Container corev1.Container `json:"container"`
+	// Port is the port the container serves the metrics on, exposed by the Service scraped by the ServiceMonitor
+	// and allowed by the NetworkPolicy to the monitoring peers
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+	// Path the metrics are scraped from. Defaults to /metrics.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// SidecarMounts selects the generated files mounted read-only into every sidecar
+// at the same paths as into the Redis container
+type SidecarMounts struct {
+	// Config mounts the Redis configuration at /config/redis.conf
+	// +optional
+	Config bool `json:"config,omitempty"`
+	// Secret mounts the authentication configuration at /secret/auth.conf along with the ACL file
+	// if it is enabled. The files contain the password.
+	// +optional
+	Secret bool `json:"secret,omitempty"`
+}
+
+// NetworkPolicy restricts the ingress traffic of the Redis Pods, e.g. in the namespaces denying it by default.
+// The Operator is allowed to reach all the ports of the Pods, the Redis Pods and the backup Jobs
+// are allowed to reach the Redis port.
+type NetworkPolicy struct {
+	// Clients are the peers allowed to connect to the Redis port. Any peer is allowed if empty.
+	// +optional
+	Clients []networkingv1.NetworkPolicyPeer `json:"clients,omitempty"`
+	// Monitoring are the peers allowed to scrape the exporter, e.g. Prometheus. Only the Operator is allowed if empty.
+	// +optional
+	Monitoring []networkingv1.NetworkPolicyPeer `json:"monitoring,omitempty"`
+}
+
+// VolumeExpansion configures the expansion of the data volumes
+type VolumeExpansion struct {
+	// RecreateStatefulSet deletes the StatefulSet leaving its Pods and PersistentVolumeClaims behind
+	// once the claims are expanded. The StatefulSet is recreated with the grown volume claim template
+	// and adopts the Pods without restarting them. The volume claim template is left intact otherwise,
+	// it is immutable, and the claims of the scaled up Pods are expanded after they are created.
+	// +optional
+	RecreateStatefulSet bool `json:"recreateStatefulSet,omitempty"`
+}
+
+// OrchestratedUpdate configures the Pod rollouts carried out by the operator
+type OrchestratedUpdate struct {
+	// MaxReplicationLag is the replication lag in bytes the replicas are allowed to fall behind the master
+	// before the next Pod is restarted. Defaults to 0: every replica has to catch up with the master.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxReplicationLag int64 `json:"maxReplicationLag,omitempty"`
+}
+
+// Handover configures the handovers of the master role carried out by the operator. The writes to the master are
+// paused, the kept replica with the highest replication offset is chosen as the successor and promoted once it
+// lags behind the master by at most MaxLag bytes. The handover is retried later if the successor does not catch up
+// within SyncTimeout, the writes are paused for SyncTimeout and 3 more seconds at most.
+type Handover struct {
+	// MaxLag is the replication lag in bytes the successor is allowed to fall behind the master when it is promoted,
+	// the writes within the lag are lost. Defaults to 0: the successor has to catch up with the master.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxLag int64 `json:"maxLag,omitempty"`
+	// SyncTimeout is the time the successor is given to catch up with the master. Defaults to 2s.
+	// +optional
+	SyncTimeout *metav1.Duration `json:"syncTimeout,omitempty"`
+}
+
+// PreDeleteHook configures the Job run before the Redis is deleted
+type PreDeleteHook struct {
+	// Template of the Pods run by the Job. The restart policy defaults to Never.
+	Template corev1.PodTemplateSpec `json:"template"`
+	// BackoffLimit is the number of retries before the Job is considered failed. Defaults to 0.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+	// IgnoreFailure lets the deletion proceed once the Job has failed. Otherwise the Redis is kept
+	// until the failed Job is deleted to be retried or the hook is removed from the spec.
+	// +optional
+	IgnoreFailure bool `json:"ignoreFailure,omitempty"`
+}
+
+// Addressing is the way the instances address each other
+type Addressing string
+
+const (
+	// AddressingIP addresses the instances by the Pod IPs
+	AddressingIP Addressing = "IP"
+	// AddressingHostname addresses the instances by the DNS names of the Pods under the headless Service
+	AddressingHostname Addressing = "Hostname"
+)
+
+// PersistentVolumeClaimRetentionPolicyType is the action taken on the data volume claims
+type PersistentVolumeClaimRetentionPolicyType string
+
+const (
+	// RetainPersistentVolumeClaimRetentionPolicyType keeps the claims
+	RetainPersistentVolumeClaimRetentionPolicyType PersistentVolumeClaimRetentionPolicyType = "Retain"
+	// DeletePersistentVolumeClaimRetentionPolicyType deletes the claims
+	DeletePersistentVolumeClaimRetentionPolicyType PersistentVolumeClaimRetentionPolicyType = "Delete"
+)
+
+// PersistentVolumeClaimRetentionPolicy is the persistentVolumeClaimRetentionPolicy of the StatefulSet.
+// The field is introduced in Kubernetes 1.23 behind the StatefulSetAutoDeletePVC feature gate, enabled by default
+// since 1.27. It is ignored by the API servers not supporting it.
+type PersistentVolumeClaimRetentionPolicy struct {
+	// WhenDeleted is the action taken on the claims when the Redis, and hence the StatefulSet, is deleted.
+	// Defaults to Retain.
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +optional
+	WhenDeleted PersistentVolumeClaimRetentionPolicyType `json:"whenDeleted,omitempty"`
+	// WhenScaled is the action taken on the claims of the Pods removed when spec.replicas is decreased.
+	// Defaults to Retain.
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +optional
+	WhenScaled PersistentVolumeClaimRetentionPolicyType `json:"whenScaled,omitempty"`
+}
+
+// Service configures how the master Service is exposed. The other Services are always ClusterIP.
+type Service struct {
+	// Type of the master Service. Defaults to ClusterIP.
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +optional
+	Type corev1.ServiceType `json:"type,omitempty"`
+	// LoadBalancerClass of the LoadBalancer Service, requires Kubernetes 1.21 or newer.
+	// The class can only be set when the Service becomes a LoadBalancer and cannot be changed afterwards.
+	// +optional
+	LoadBalancerClass *string `json:"loadBalancerClass,omitempty"`
+}
+
+// ReplicaService configures the redis-<name>-replica Service. It is always ClusterIP.
+type ReplicaService struct {
+	// ExcludeMaster makes the Service select the replicas only. Defaults to true.
+	// The master is selected along with the replicas if false, e.g. to serve the reads while no replica is available.
+	// +optional
+	ExcludeMaster *bool `json:"excludeMaster,omitempty"`
+}
+
+// ImageVerification configures the verification of the cosign image signatures.
+// The images must be pinned by digest, the signatures are read from the registry
+// with the credentials of the image pull Secrets. Keyless signatures are not supported.
+// More info: https://github.com/sigstore/cosign
+type ImageVerification struct {
+	// PublicKeys are the PEM encoded ECDSA, RSA or Ed25519 public keys, e.g. the content of cosign.pub.
+	// An image must be signed by any of the keys.
+	// +kubebuilder:validation:MinItems=1
+	PublicKeys []string `json:"publicKeys"`
+}
+
+// ImageUpdateTrack is the part of the image version the updates are tracked within
+type ImageUpdateTrack string
+
+const (
+	// ImageUpdateTrackPatch tracks the patch releases of the minor version, e.g. 6.0.9 is updated to 6.0.10
+	ImageUpdateTrackPatch ImageUpdateTrack = "Patch"
+	// ImageUpdateTrackMinor tracks the minor and patch releases of the major version, e.g. 6.0.9 is updated to 6.2.1
+	ImageUpdateTrackMinor ImageUpdateTrack = "Minor"
+)
+
+// ImageUpdatePolicy configures the automated updates of the Redis image.
+// The spec.redis.image tag must be a version like 6.0.9 or 6.0.9-alpine, the tags with the same suffix
+// are considered. The newest matching tag is resolved to the digest periodically
+// and the StatefulSet is updated to it, the image in the spec is left intact.
+// The updates are never downgrading the spec.redis.image version.
+type ImageUpdatePolicy struct {
+	// Track is the part of the version the updates are tracked within
+	// +kubebuilder:validation:Enum=Patch;Minor
+	Track ImageUpdateTrack `json:"track"`
+	// Interval between the registry checks. Defaults to 1h.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// ExternalAccess creates a NodePort or LoadBalancer Service per Redis instance named after the Pod.
+// The instances announce their external addresses with replica-announce-ip and replica-announce-port,
+// so the replicas listed by the master, e.g. in INFO replication, are reachable from outside of the cluster.
+// NodePort instances announce the external IP of the node, or the internal IP if the node has none.
+type ExternalAccess struct {
+	// Type of the per-instance Services
+	// +kubebuilder:validation:Enum=NodePort;LoadBalancer
+	Type corev1.ServiceType `json:"type"`
+	// Annotations of the per-instance Services, e.g. the cloud load balancer settings
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// NodeLocalCache configures the node-local cache replicas. They replicate from the master and are
+// never promoted, the data is not persisted. The clients read from the cache on their own node
+// through the redis-<name>-cache Service.
+type NodeLocalCache struct {
+	// NodeSelector selects the nodes the cache replicas are run on, all the nodes if empty
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations of the cache replicas, e.g. of the tainted nodes dedicated to the clients
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Resources of the cache redis container, the resources of the redis container by default
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// TLS allows to refer to a Secret containing the TLS certificate, key and CA bundle.
+// When TLS is enabled Redis serves TLS connections only: the plaintext port is disabled,
+// replication runs over TLS and the Operator connects to instances using TLS as well.
+// The certificate is used both as the server and the client certificate,
+// hence it should be valid for both purposes.
+// Pods are restarted whenever the certificate in the Secret changes.
+type TLS struct {
+	// SecretName is the name of the Secret in the same namespace containing
+	// tls.crt, tls.key and ca.crt keys, e.g. a Secret of type kubernetes.io/tls.
+	SecretName string `json:"secretName"`
+	// IssuerRef is a reference to the cert-manager Issuer or ClusterIssuer.
+	// If set, a cert-manager Certificate storing the certificate in SecretName is created
+	// with DNS names of the generated Services and Pods.
+	// +optional
+	IssuerRef *IssuerReference `json:"issuerRef,omitempty"`
+}
+
+// IssuerReference is a reference to a cert-manager issuer
+type IssuerReference struct {
+	// Name of the issuer
+	Name string `json:"name"`
+	// Kind of the issuer, Issuer or ClusterIssuer. Defaults to Issuer.
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Group of the issuer. Defaults to cert-manager.io.
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
+// Backup describes where and how RDB snapshots are stored.
+// A snapshot is streamed from a replica by redis-cli --rdb, which makes the replica run BGSAVE
+// and transfer the resulting RDB file, and is uploaded to the object storage by the agent.
+type Backup struct {
+	// Storage is the object storage the snapshots are uploaded to
+	Storage BackupStorage `json:"storage"`
+	// Retention defines which snapshots are deleted from the storage after a successful upload.
+	// Snapshots are kept forever if omitted.
+	// +optional
+	Retention *BackupRetention `json:"retention,omitempty"`
+	// Agent container specification.
+	// The image must provide rclone and a POSIX shell, e.g. rclone/rclone.
+	// More info: https://rclone.org
+	Agent ContainerSpec `json:"agent"`
+	// RestoreDrill periodically restores the latest snapshot into a temporary Redis
+	// to verify that the snapshots are usable and to measure the recovery point and time.
+	// +optional
+	RestoreDrill *RestoreDrill `json:"restoreDrill,omitempty"`
+	// Schedule takes snapshots periodically by a CronJob in addition to the on-demand RedisBackup resources
+	// +optional
+	Schedule *BackupSchedule `json:"schedule,omitempty"`
+}
+
+// BackupTarget selects the instance the scheduled snapshots are taken from
+type BackupTarget string
+
+const (
+	// BackupTargetReplica takes the snapshot from a replica, the backup fails if no replica is available
+	BackupTargetReplica BackupTarget = "Replica"
+	// BackupTargetPreferReplica takes the snapshot from a replica and falls back to the master
+	BackupTargetPreferReplica BackupTarget = "PreferReplica"
+	// BackupTargetMaster takes the snapshot from the master
+	BackupTargetMaster BackupTarget = "Master"
+)
+
+// BackupSchedule defines the periodic backups.
+// Snapshots are uploaded next to the RedisBackup snapshots as <timestamp>-scheduled.rdb
+// and are subject to the same retention.
+type BackupSchedule struct {
+	// Cron is the schedule in Cron format, e.g. "0 3 * * *".
+	// More info: https://en.wikipedia.org/wiki/Cron
+	Cron string `json:"cron"`
+	// Target selects the instance the snapshots are taken from. Defaults to Replica.
+	// +kubebuilder:validation:Enum=Replica;PreferReplica;Master
+	// +optional
+	Target BackupTarget `json:"target,omitempty"`
+	// Suspend stops scheduling new backups, the running backup is not affected
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// RestoreDrill defines the restore drill schedule.
+// The latest succeeded RedisBackup is restored into the temporary Redis named <name>-restore-drill
+// without persistence and TLS. The drill succeeds once the temporary Redis elects a master,
+// the temporary Redis is deleted afterwards.
+type RestoreDrill struct {
+	// Interval between the drills, e.g. 24h
+	Interval metav1.Duration `json:"interval"`
+	// Timeout of a drill. Defaults to 30m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// BlueGreen configures the green deployment replacing the blue Redis of the same namespace.
+// The green instances replicate from the blue master, none of them is the master until the cutover.
+// The cutover hands the master role over to the best green instance once all of them are linked to the blue master,
+// points the master Service of the blue Redis at the green master and demotes the blue instances to its replicas.
+// Both Redis must authenticate with the same password and ACL users.
+type BlueGreen struct {
+	// Blue is the name of the Redis replaced
+	Blue string `json:"blue"`
+	// Cutover starts the cutover once the green instances are in sync. It is not reverted.
+	// +optional
+	Cutover bool `json:"cutover,omitempty"`
+}
+
+// Restore refers to the snapshot the data is initialized from. Exactly one of BackupName and Artifact must be set.
+// The snapshot is downloaded to the data volume of an instance before Redis starts
+// unless the volume already contains the file, hence all the instances start with the same data
+// before the master is elected.
+// The downloaded file is checked by redis-check-rdb or redis-check-aof.
+// Please note that Redis ignores RDB files on startup when appendonly is enabled.
+// Restore can be removed once the master is elected.
+type Restore struct {
+	// BackupName is the name of the succeeded RedisBackup in the same namespace
+	// +optional
+	BackupName string `json:"backupName,omitempty"`
+	// Artifact refers to the file in the object storage directly,
+	// e.g. when RedisBackup resources have been lost along with the cluster.
+	// +optional
+	Artifact *RestoreArtifact `json:"artifact,omitempty"`
+}
+
+// RestoreArtifact is an RDB or AOF file in the object storage
+type RestoreArtifact struct {
+	// Storage the file is downloaded from. Prefix is ignored.
+	Storage BackupStorage `json:"storage"`
+	// Path of the file relative to the bucket.
+	// Files with the .aof extension are restored as the append only file.
+	Path string `json:"path"`
+	// Agent container specification.
+	// The image must provide rclone and a POSIX shell, e.g. rclone/rclone.
+	Agent ContainerSpec `json:"agent"`
+}
+
+// BackupStorage is the object storage location. Exactly one of S3, GCS and Azure must be set.
+type BackupStorage struct {
+	// S3 or S3-compatible storage
+	// +optional
+	S3 *S3Storage `json:"s3,omitempty"`
+	// GCS is Google Cloud Storage
+	// +optional
+	GCS *GCSStorage `json:"gcs,omitempty"`
+	// Azure is Azure Blob Storage
+	// +optional
+	Azure *AzureStorage `json:"azure,omitempty"`
+	// Prefix is prepended to the object names. Snapshots are stored as
+	// <prefix>/<namespace>/<redis name>/<timestamp>-<backup name>.rdb
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// CredentialsSecretName is the name of the Secret in the same namespace
+	// exposed to the agent as environment variables, e.g. AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+	// Credentials are taken from the agent environment, workload identity and metadata services if omitted.
+	// +optional
+	CredentialsSecretName string `json:"credentialsSecretName,omitempty"`
+	// ServiceAccountName is the name of the ServiceAccount bound to a cloud identity
+	// via IRSA, GKE Workload Identity or Azure Workload Identity.
+	// The backup Jobs run as this ServiceAccount. Redis Pods restoring the data run as this
+	// ServiceAccount unless serviceAccountName is set in the Redis spec.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+}
+
+// S3Storage is an S3 bucket
+type S3Storage struct {
+	// Bucket name
+	Bucket string `json:"bucket"`
+	// Region of the bucket
+	// +optional
+	Region string `json:"region,omitempty"`
+	// Endpoint of an S3-compatible storage, e.g. MinIO
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// GCSStorage is a Google Cloud Storage bucket
+type GCSStorage struct {
+	// Bucket name
+	Bucket string `json:"bucket"`
+}
+
+// AzureStorage is an Azure Blob Storage container
+type AzureStorage struct {
+	// Account is the storage account name
+	Account string `json:"account"`
+	// Container name
+	Container string `json:"container"`
+}
+
+// BackupRetention defines which snapshots are kept in the storage
+type BackupRetention struct {
+	// KeepLast is the number of the most recent snapshots to keep
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	KeepLast *int32 `json:"keepLast,omitempty"`
+	// MaxAge is the maximum age of the snapshots to keep, e.g. 168h
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
 }
 
 // Password allows to refer to a Secret containing password for Redis
 // Password should be strong enough. Passwords shorter than 8 characters
 // composed of ASCII alphanumeric symbols will lead to a mild warning logged by the Operator.
-// Please note that password hashes are added as annotations to Pods to enable
-// password rotation. Hashes are generated using argon2id KDF.
-// Changing the password in the referenced Secret will not trigger
-// the rolling Statefulset upgrade automatically.
-// However an event in regard to any objects owned by the Redis resource
-// fired afterwards will trigger the rolling upgrade.
-// Redis operator does not store the password internally and reads it
-// from the Secret any time the Reconcile is called.
-// Hence it will not be able to connect to Pods with the ``old'' password.
-// In scenarios when persistence is turned off all the data will be lost
-// during password rotation.
+// The changed password is applied to the running instances with CONFIG SET, the replicas first,
+// and to the generated authentication Secret afterwards, so the Pods are not restarted.
+// The referenced Secret is watched for the changes.
 type Password struct {
 	// SecretKeyRef is a reference to the Secret in the same namespace containing the password.
 	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef"`
+	// PreviousSecretKeyRef is a reference to the previous password accepted along with the password of SecretKeyRef
+	// during the rotation, so the clients authenticate with either of them until they are switched to the new one.
+	// The default user, or the redis-operator user once the default user is disabled, is defined by both passwords
+	// and the replicas authenticate with the new one. It is removed once the rotation is over. Requires Redis 6.2+.
+	// +optional
+	PreviousSecretKeyRef *corev1.SecretKeySelector `json:"previousSecretKeyRef,omitempty"`
+	// SecretProviderClass is the name of the SecretProviderClass of the Secrets Store CSI Driver, e.g. of the Vault
+	// provider, mounted into the Redis Pods. The driver syncs the password to the Secret of SecretKeyRef declared
+	// in the secretObjects of the class and keeps it updated once the secret is rotated in the store.
+	// +optional
+	SecretProviderClass string `json:"secretProviderClass,omitempty"`
+}
+
+// ACL defines Redis 6+ access control lists.
+// Users are written to the configuration to survive restarts and are applied
+// to every instance via ACL SETUSER during every reconcile to keep them in sync.
+// The default user is controlled by Password and can not be declared here.
+type ACL struct {
+	// Users is a list of ACL users managed by the Operator
+	Users []ACLUser `json:"users,omitempty"`
+	// DisableDefaultUser turns the default user off once the operator-managed users are in place.
+	// The Operator, the probes, the exporter and the replicas authenticate as the redis-operator user
+	// with the Password instead, hence the Password is required and redis-operator is a reserved user name.
+	// The default user is disabled after the Pods are rolled out with the probes and the exporter
+	// authenticating as redis-operator. Requires Redis 6+.
+	// +optional
+	DisableDefaultUser bool `json:"disableDefaultUser,omitempty"`
+	// ACLFile moves the users including the default one from the configuration to an ACL file.
+	// The default user is defined by the password hash instead of requirepass, so the password
+	// is not revealed by CONFIG GET requirepass. Requires Redis 6.2+.
+	// +optional
+	ACLFile bool `json:"aclFile,omitempty"`
+}
+
+// ACLUser is a Redis ACL user
+type ACLUser struct {
+	// Name of the user
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	Name string `json:"name"`
+	// Rules as accepted by ACL SETUSER, e.g. "on", "~cache:*", "+@read".
+	// The user is reset before the rules are applied.
+	// Passwords can not be set by rules, use PasswordSecretKeyRefs instead.
+	// More info: https://redis.io/commands/acl-setuser
+	Rules []string `json:"rules,omitempty"`
+	// PasswordSecretKeyRefs refer to the Secrets in the same namespace containing passwords of the user
+	PasswordSecretKeyRefs []corev1.SecretKeySelector `json:"passwordSecretKeyRefs,omitempty"`
 }
 
 // ContainerSpec allows to set some container-specific attributes
 type ContainerSpec struct {
 	// Image is a standard path for a Container image
 	Image string `json:"image"`
+	// ImageDigest pins the image to the manifest digest, e.g. sha256:0123...
+	// The container is run with Image@ImageDigest, any digest in Image is replaced.
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	// +optional
+	ImageDigest string `json:"imageDigest,omitempty"`
 	// Resources describes the compute resource requirements
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 	// SecurityContext holds security configuration that will be applied to a container
@@ -117,14 +742,373 @@ type ContainerSpec struct {
 	// More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#container-probes
 	// +optional
 	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+	// LivenessProbe overrides the generated liveness probe of the redis and exporter containers.
+	// The generated check is kept if no handler is set, so the timings can be tuned alone.
+	// +optional
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+	// ReadinessProbe overrides the generated readiness probe of the redis and exporter containers.
+	// The generated check is kept if no handler is set.
+	// +optional
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+	// StartupProbe of the redis and exporter containers, e.g. for the instances loading large datasets.
+	// The check of the generated readiness probe is used if no handler is set.
+	// +optional
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
 }
 
 // RedisStatus contains the observed state of Redis
 type RedisStatus struct {
-	// Replicas is the number of active Redis instances in the replication
+	// Replicas is the number of active Redis instances in the replication.
+	// Kept for the scale subresource, the Degraded condition tells why instances are missing
 	Replicas int `json:"replicas"`
-	// Master is the current master's Pod name
+	// Master is the current master's Pod name.
+	// Kept for the failover, the MasterElected condition tells why the master is missing
 	Master string `json:"master"`
+	// Conditions represent the latest available observations of the Redis state:
+	// Ready, ReplicationConfigured, MasterElected, Degraded, ConfigInvalid, ReconcileTimedOut,
+	// PersistenceFailing, DataVolumeUsageHigh and ImagesVerified
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// RestoreDrill is the state of the latest restore drill
+	// +optional
+	RestoreDrill *RestoreDrillStatus `json:"restoreDrill,omitempty"`
+	// BlueGreen is the state of the blue/green deployment the Redis takes part in
+	// +optional
+	BlueGreen *BlueGreenStatus `json:"blueGreen,omitempty"`
+	// ScheduledBackup is the state of the scheduled backups
+	// +optional
+	ScheduledBackup *ScheduledBackupStatus `json:"scheduledBackup,omitempty"`
+	// DataProtection are the latest succeeded and failed backups and restores of the Redis
+	// +optional
+	DataProtection *DataProtectionStatus `json:"dataProtection,omitempty"`
+	// ImageUpdate is the state of the automated image updates
+	// +optional
+	ImageUpdate *ImageUpdateStatus `json:"imageUpdate,omitempty"`
+	// Images are the digests of the images the master Pod containers are running,
+	// as resolved by the container runtime
+	// +optional
+	Images []ImageStatus `json:"images,omitempty"`
+	// ExternalAddresses are the addresses the instances announce when the external access is enabled
+	// +optional
+	ExternalAddresses []ExternalAddress `json:"externalAddresses,omitempty"`
+	// DefaultUserDisabled is true once the default user is disabled on the instances
+	// +optional
+	DefaultUserDisabled bool `json:"defaultUserDisabled,omitempty"`
+	// ACLUsers are the names of spec.acl.users applied to the instances. The users removed from spec.acl.users
+	// are deleted from the running instances by these names.
+	// +optional
+	ACLUsers []string `json:"aclUsers,omitempty"`
+	// Instances are the stable identities of the instances ordered by the Pod ordinals,
+	// e.g. for the clients pinned to specific replicas
+	// +optional
+	Instances []InstanceStatus `json:"instances,omitempty"`
+	// UpdateRevision is the StatefulSet revision the Pods are updated to
+	// +optional
+	UpdateRevision string `json:"updateRevision,omitempty"`
+	// ConfigRevision is the revision of the configuration the Pods are restarted for
+	// once it can not be applied to the running instances
+	// +optional
+	ConfigRevision string `json:"configRevision,omitempty"`
+	// Binding is the Secret the workloads bind to as defined by the Service Binding specification.
+	// Set only if Spec.ServiceBinding is enabled.
+	// +optional
+	Binding *corev1.LocalObjectReference `json:"binding,omitempty"`
+	// Outputs are the endpoints and the names of the generated resources for the external tools,
+	// e.g. Crossplane compositions or Terraform. Their structure is stable within the OutputsVersion.
+	// +optional
+	Outputs *Outputs `json:"outputs,omitempty"`
+}
+
+// OutputsVersion is the version of the Outputs structure. The fields are neither renamed nor removed
+// and keep their meaning within the version, new optional fields may be added.
+const OutputsVersion = "v1"
+
+// Outputs are the stable outputs of the Redis for the external tools
+type Outputs struct {
+	// Version of the structure, the OutputsVersion the outputs are generated with
+	Version string `json:"version"`
+	// MasterHost is the DNS name of the master Service
+	MasterHost string `json:"masterHost"`
+	// ReplicaHost is the DNS name of the replica Service
+	ReplicaHost string `json:"replicaHost"`
+	// Port is the port of the master and the replica Services
+	Port int32 `json:"port"`
+	// TLS is true if the clients have to connect with TLS
+	TLS bool `json:"tls"`
+	// PasswordSecretName is the name of the Secret holding the password, empty if the password is not set
+	// +optional
+	PasswordSecretName string `json:"passwordSecretName,omitempty"`
+	// PasswordSecretKey is the key of the password in the Secret
+	// +optional
+	PasswordSecretKey string `json:"passwordSecretKey,omitempty"`
+	// ConnectionInfoConfigMapName is the name of the connection info ConfigMap if it is generated
+	// +optional
+	ConnectionInfoConfigMapName string `json:"connectionInfoConfigMapName,omitempty"`
+	// BindingSecretName is the name of the Service Binding Secret if it is generated
+	// +optional
+	BindingSecretName string `json:"bindingSecretName,omitempty"`
+}
+
+// InstanceStatus is the stable identity of a Redis instance. The hostname and the subdomain of the Pod are set
+// by the StatefulSet: the Pod name and the headless Service respectively.
+type InstanceStatus struct {
+	// Pod is the name of the instance Pod
+	Pod string `json:"pod"`
+	// Hostname of the Pod
+	Hostname string `json:"hostname"`
+	// Subdomain of the Pod, the headless Service the Pod DNS records are published by
+	Subdomain string `json:"subdomain"`
+	// FQDN is the stable DNS name of the instance, <hostname>.<subdomain>.<namespace>.svc.<cluster domain>
+	FQDN string `json:"fqdn"`
+	// Role is either master or replica, empty while the instance is not ready
+	// +optional
+	Role string `json:"role,omitempty"`
+	// Revision is the StatefulSet revision the Pod runs, the controller-revision-hash label of the Pod
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// ConfigRevision is the revision of the configuration the Pod has been started with,
+	// empty if the configuration has never required a restart
+	// +optional
+	ConfigRevision string `json:"configRevision,omitempty"`
+	// Updated is true once the Pod runs the UpdateRevision and the ConfigRevision of the Redis
+	// +optional
+	Updated bool `json:"updated,omitempty"`
+}
+
+// ExternalAddress is the address a Redis instance is reachable at from outside of the cluster
+type ExternalAddress struct {
+	// Pod is the name of the instance Pod
+	Pod string `json:"pod"`
+	// Address is the announced host:port
+	Address string `json:"address"`
+}
+
+// ImageUpdateStatus is the state of the automated image updates
+type ImageUpdateStatus struct {
+	// AvailableVersion is the newest version matching the update policy
+	// +optional
+	AvailableVersion string `json:"availableVersion,omitempty"`
+	// AvailableImage is the image of AvailableVersion pinned by digest, the Redis Pods are updated to
+	// +optional
+	AvailableImage string `json:"availableImage,omitempty"`
+	// RunningVersion is the version the master is running
+	// +optional
+	RunningVersion string `json:"runningVersion,omitempty"`
+	// LastCheckTime is the last time the registry was checked for updates
+	// +optional
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+	// Message is a human readable message indicating details about the failure of the latest check
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ImageStatus is the image a container is running
+type ImageStatus struct {
+	// Container is the container name
+	Container string `json:"container"`
+	// Image is the image reference the container is run with
+	Image string `json:"image"`
+	// Digest is the resolved digest of the image
+	// +optional
+	Digest string `json:"digest,omitempty"`
+}
+
+// ScheduledBackupStatus is the state of the scheduled backups
+type ScheduledBackupStatus struct {
+	// LastScheduleTime is the last time a backup Job was scheduled
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// LastJobName is the name of the latest backup Job
+	// +optional
+	LastJobName string `json:"lastJobName,omitempty"`
+	// LastPhase is the phase of the latest backup Job
+	// +optional
+	LastPhase BackupPhase `json:"lastPhase,omitempty"`
+	// LastSuccessTime is the completion time of the latest succeeded backup
+	// +optional
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+	// LastFailureTime is the completion time of the latest failed backup
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+	// Message is a human readable message indicating details about the latest failure
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// BlueGreenPhase is the phase of a blue/green deployment
+type BlueGreenPhase string
+
+const (
+	// BlueGreenReplicating means that the green instances are replicating from the blue master
+	BlueGreenReplicating BlueGreenPhase = "Replicating"
+	// BlueGreenInSync means that all the green instances are linked to the blue master
+	BlueGreenInSync BlueGreenPhase = "InSync"
+	// BlueGreenCutOver means that the green master has taken the master role over from the blue master
+	BlueGreenCutOver BlueGreenPhase = "CutOver"
+	// BlueGreenDemoted is the phase of the blue Redis replicating from the green master after the cutover
+	BlueGreenDemoted BlueGreenPhase = "Demoted"
+)
+
+// BlueGreenStatus is the state of a blue/green deployment
+type BlueGreenStatus struct {
+	// Phase of the deployment
+	Phase BlueGreenPhase `json:"phase"`
+	// Peer is the name of the other Redis: the blue one of the green Redis and vice versa
+	Peer string `json:"peer"`
+	// Master is the Pod of the peer the instances replicate from: the blue master of the green Redis,
+	// kept after the cutover, or the green master of the demoted blue Redis
+	// +optional
+	Master string `json:"master,omitempty"`
+}
+
+// RestoreDrillPhase is the phase of a restore drill
+type RestoreDrillPhase string
+
+const (
+	// RestoreDrillRunning means that the temporary Redis is being restored
+	RestoreDrillRunning RestoreDrillPhase = "Running"
+	// RestoreDrillSucceeded means that the temporary Redis has been restored
+	RestoreDrillSucceeded RestoreDrillPhase = "Succeeded"
+	// RestoreDrillFailed means that the temporary Redis has not been restored in time
+	RestoreDrillFailed RestoreDrillPhase = "Failed"
+)
+
+// RestoreDrillStatus is the state of a restore drill
+type RestoreDrillStatus struct {
+	// Phase of the drill
+	Phase RestoreDrillPhase `json:"phase"`
+	// BackupName is the name of the restored RedisBackup
+	BackupName string `json:"backupName"`
+	// StartTime is the time the drill started
+	StartTime metav1.Time `json:"startTime"`
+	// CompletionTime is the time the drill succeeded or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// RPO is the measured recovery point: the age of the restored snapshot at the start of the drill
+	// +optional
+	RPO *metav1.Duration `json:"rpo,omitempty"`
+	// RTO is the measured recovery time: the time it took to restore the temporary Redis
+	// +optional
+	RTO *metav1.Duration `json:"rto,omitempty"`
+	// Message is a human readable message indicating details about the failure
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// DataProtectionStatus cross-links the latest outcomes of the backups and the restores of the Redis,
+// so the data protection posture is seen without listing the RedisBackups and the Jobs.
+// The entries are kept once the referred objects are deleted.
+type DataProtectionStatus struct {
+	// LastSucceededBackup is the latest succeeded RedisBackup or scheduled backup
+	// +optional
+	LastSucceededBackup *BackupReference `json:"lastSucceededBackup,omitempty"`
+	// LastFailedBackup is the latest failed RedisBackup or scheduled backup
+	// +optional
+	LastFailedBackup *BackupReference `json:"lastFailedBackup,omitempty"`
+	// LastSucceededRestore is the latest succeeded restore: the one of spec.restore or a restore drill
+	// +optional
+	LastSucceededRestore *RestoreReference `json:"lastSucceededRestore,omitempty"`
+	// LastFailedRestore is the latest failed restore drill
+	// +optional
+	LastFailedRestore *RestoreReference `json:"lastFailedRestore,omitempty"`
+}
+
+// BackupReference refers to a finished backup
+type BackupReference struct {
+	// Kind is RedisBackup or Job, the latter for the scheduled backups
+	Kind string `json:"kind"`
+	// Name of the RedisBackup or the Job
+	Name string `json:"name"`
+	// CompletionTime is the time the backup succeeded or failed
+	CompletionTime metav1.Time `json:"completionTime"`
+	// Location is the URL of the snapshot in the object storage
+	// +optional
+	Location string `json:"location,omitempty"`
+	// Size is the size of the snapshot in bytes, reported by the RedisBackups only
+	// +optional
+	Size int64 `json:"size,omitempty"`
+	// Message is a human readable message indicating details about the failure
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RestoreReference refers to a finished restore
+type RestoreReference struct {
+	// RedisName is the name of the Redis the data has been restored into: this one or the temporary one of the drill
+	RedisName string `json:"redisName"`
+	// BackupName is the name of the restored RedisBackup, empty if the file has been restored from spec.restore.artifact
+	// +optional
+	BackupName string `json:"backupName,omitempty"`
+	// CompletionTime is the time the restore succeeded or failed
+	CompletionTime metav1.Time `json:"completionTime"`
+	// Message is a human readable message indicating details about the failure
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ConditionType is a valid value for Condition.Type
+type ConditionType string
+
+const (
+	// ConditionReady means that the replication is configured, the master is elected and all the instances are ready
+	ConditionReady ConditionType = "Ready"
+	// ConditionReplicationConfigured means that the ready instances have been configured as master and replicas
+	ConditionReplicationConfigured ConditionType = "ReplicationConfigured"
+	// ConditionMasterElected means that the master is elected and labeled
+	ConditionMasterElected ConditionType = "MasterElected"
+	// ConditionDegraded means that fewer instances than desired are ready
+	ConditionDegraded ConditionType = "Degraded"
+	// ConditionQuorumLost means that fewer instances than the minimum replication size reply to PING,
+	// so the replication can be neither configured nor failed over
+	ConditionQuorumLost ConditionType = "QuorumLost"
+	// ConditionConfigInvalid means that the Redis can not be configured because of the invalid spec or referenced Secrets
+	ConditionConfigInvalid ConditionType = "ConfigInvalid"
+	// ConditionReconcileTimedOut means that the latest reconciliation has not completed within the deadline
+	ConditionReconcileTimedOut ConditionType = "ReconcileTimedOut"
+	// ConditionPersistenceFailing means that at least one instance reports failed RDB or AOF writes
+	ConditionPersistenceFailing ConditionType = "PersistenceFailing"
+	// ConditionDataVolumeUsageHigh means that the usage of at least one data volume exceeds the threshold
+	ConditionDataVolumeUsageHigh ConditionType = "DataVolumeUsageHigh"
+	// ConditionAOFFsyncDelayed means that the AOF writes of at least one instance have been delayed by the slow fsync
+	// since the previous check. Present only if AOF is enabled.
+	ConditionAOFFsyncDelayed ConditionType = "AOFFsyncDelayed"
+	// ConditionHostSettingsDegraded means that the preflight check found the node settings degrading Redis
+	// on the node of at least one Pod. Present only if the host preflight is enabled.
+	ConditionHostSettingsDegraded ConditionType = "HostSettingsDegraded"
+	// ConditionPubSubDegraded means that the Pub/Sub canary message published on the master has not been received
+	// on at least one replica. Present only if the Pub/Sub check is enabled.
+	ConditionPubSubDegraded ConditionType = "PubSubDegraded"
+	// ConditionEvictionRateHigh means that at least one instance has evicted more keys per second than the threshold
+	// since the previous sample. Present only if the eviction rate threshold is set.
+	ConditionEvictionRateHigh ConditionType = "EvictionRateHigh"
+	// ConditionImagesVerified means that the signatures of all the images have been verified.
+	// Present only if the image verification is configured.
+	ConditionImagesVerified ConditionType = "ImagesVerified"
+	// ConditionPaused means that the reconciliation is paused by Spec.Paused or the PausedAnnotation.
+	// Present only while paused.
+	ConditionPaused ConditionType = "Paused"
+	// ConditionClassConflict means that the spec sets the fields locked by the RedisClass to other values,
+	// the values of the class are used. Present only if the Redis refers to a RedisClass.
+	ConditionClassConflict ConditionType = "ClassConflict"
+)
+
+// Condition describes the state of a Redis resource at a certain point
+type Condition struct {
+	// Type of the condition
+	Type ConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown
+	Status corev1.ConditionStatus `json:"status"`
+	// Last time the condition transitioned from one status to another
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// The reason for the condition's last transition in CamelCase
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// A human readable message indicating details about the transition
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // RedisList is a list of Redis resources
@@ -0,0 +1,540 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amaizfinance/redis-operator/pkg/features"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// DefaultReplicas is the number of Redis instances if spec.replicas is omitted
+	DefaultReplicas = int32(3)
+
+	// DefaultExporterImage is the exporter image if the exporter is configured without one
+	DefaultExporterImage = "oliver006/redis_exporter:v1.11.1"
+	// DefaultTelegrafImage is the exporter image of the telegraf provider if the exporter is configured without one
+	DefaultTelegrafImage = "telegraf:1.17"
+
+	// defaultPort is the port Redis listens on if spec.port is omitted
+	defaultPort = int32(6379)
+
+	// redisNameLabelKey is the label key the operator sets to the Redis name on all the Redis Pods
+	redisNameLabelKey = "redis"
+	// antiAffinityWeight is the weight of the default preferred Pod anti-affinity term
+	antiAffinityWeight = 100
+
+	// names of the containers generated by the operator the sidecars must not use
+	redisContainerName    = "redis"
+	exporterContainerName = "exporter"
+
+	// maxHandoverSyncTimeout bounds the time the writes to the master are paused for during the handover
+	maxHandoverSyncTimeout = 30 * time.Second
+)
+
+// +kubebuilder:webhook:path=/mutate-k8s-amaiz-com-v1alpha1-redis,mutating=true,failurePolicy=fail,groups=k8s.amaiz.com,resources=redis,verbs=create;update,versions=v1alpha1,name=mredis.k8s.amaiz.com
+
+// +kubebuilder:webhook:path=/validate-k8s-amaiz-com-v1alpha1-redis,mutating=false,failurePolicy=fail,groups=k8s.amaiz.com,resources=redis,verbs=create;update,versions=v1alpha1,name=vredis.k8s.amaiz.com
+
+// strict implementation check
+var (
+	_ admission.Defaulter = (*Redis)(nil)
+	_ admission.Validator = (*Redis)(nil)
+)
+
+// Default sets the defaults of the fields omitted in the Redis resource:
+//   - spec.replicas defaults to DefaultReplicas,
+//   - nil metadata and Pod annotations, labels, config and node selector maps are initialized,
+//   - the exporter image is set to the one of the provider if the exporter is configured without it,
+//   - on creation the exporter resources are set to the ones of the provider if omitted
+//     and the Pods prefer to be scheduled to different nodes if spec.affinity is omitted.
+//
+// The Redis being created has no creation timestamp yet. The exporter resources and the affinity
+// of the existing resources are left as stored, so upgrading the operator does not roll their Pods.
+func (r *Redis) Default() {
+	if r.Spec.Replicas == nil {
+		replicas := DefaultReplicas
+		r.Spec.Replicas = &replicas
+	}
+
+	if r.Labels == nil {
+		r.Labels = make(map[string]string)
+	}
+	if r.Annotations == nil {
+		r.Annotations = make(map[string]string)
+	}
+	if r.Spec.Annotations == nil {
+		r.Spec.Annotations = make(map[string]string)
+	}
+	if r.Spec.Config == nil {
+		r.Spec.Config = make(map[string]string)
+	}
+	if r.Spec.NodeSelector == nil {
+		r.Spec.NodeSelector = make(map[string]string)
+	}
+
+	creating := r.CreationTimestamp.IsZero()
+	if creating {
+		r.defaultExporter()
+	} else {
+		r.defaultExporterImage()
+	}
+
+	// the requests and limits of the containers managed by the operator are equal for the Guaranteed QoS
+	if r.GuaranteedQoS() {
+		MirrorResources(&r.Spec.Redis.Resources)
+		if r.Spec.Exporter.Image != "" {
+			MirrorResources(&r.Spec.Exporter.Resources)
+		}
+		if r.Spec.Metrics != nil {
+			MirrorResources(&r.Spec.Metrics.Container.Resources)
+		}
+		if r.Spec.NodeLocalCache != nil {
+			MirrorResources(&r.Spec.NodeLocalCache.Resources)
+		}
+	}
+
+	if creating && r.Spec.Affinity == nil {
+		r.Spec.Affinity = &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: antiAffinityWeight,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{redisNameLabelKey: r.GetName()}},
+					TopologyKey:   corev1.LabelHostname,
+				},
+			}},
+		}}
+	}
+}
+
+// defaultExporter sets the image and the resources of the exporter to the ones of the provider if omitted
+func (r *Redis) defaultExporter() {
+	r.defaultExporterImage()
+	if r.Spec.Exporter.Image != "" && r.Spec.Exporter.Resources.Requests == nil && r.Spec.Exporter.Resources.Limits == nil {
+		r.Spec.Exporter.Resources = r.Spec.ExporterProvider.defaultResources()
+		if r.GuaranteedQoS() {
+			r.Spec.Exporter.Resources.Requests[corev1.ResourceMemory] = r.Spec.Exporter.Resources.Limits[corev1.ResourceMemory]
+		}
+	}
+}
+
+// defaultExporterImage sets the image of the exporter to the one of the provider if omitted
+func (r *Redis) defaultExporterImage() {
+	// the exporter is disabled if omitted completely
+	if r.Spec.Exporter.Image == "" && !reflect.DeepEqual(r.Spec.Exporter, ContainerSpec{}) {
+		r.Spec.Exporter.Image = r.Spec.ExporterProvider.DefaultImage()
+	}
+}
+
+// ValidateCreate implements admission.Validator
+func (r *Redis) ValidateCreate() error {
+	if err := r.validateFeatureGates(); err != nil {
+		return err
+	}
+	if err := r.validateClassName(); err != nil {
+		return err
+	}
+	if err := r.ValidateConfig(); err != nil {
+		return err
+	}
+	if err := r.validateImages(); err != nil {
+		return err
+	}
+	if err := r.validateSidecars(); err != nil {
+		return err
+	}
+	if err := r.validateMetrics(); err != nil {
+		return err
+	}
+	if err := r.validateExporterProvider(); err != nil {
+		return err
+	}
+	if err := r.validateACL(); err != nil {
+		return err
+	}
+	if err := r.validatePassword(); err != nil {
+		return err
+	}
+	if err := r.validateProfile(); err != nil {
+		return err
+	}
+	if err := r.validateQoS(); err != nil {
+		return err
+	}
+	if err := r.validateCPUPinning(); err != nil {
+		return err
+	}
+	if err := r.validateSysctls(); err != nil {
+		return err
+	}
+	if err := r.validateOrchestratedUpdate(); err != nil {
+		return err
+	}
+	if err := r.validateHandover(); err != nil {
+		return err
+	}
+	if err := r.validateMaxFullSyncDuration(); err != nil {
+		return err
+	}
+	if err := r.validatePreDeleteHook(); err != nil {
+		return err
+	}
+	if err := r.validateBlueGreen(nil); err != nil {
+		return err
+	}
+	return r.validateService(nil)
+}
+
+// ValidateUpdate implements admission.Validator
+func (r *Redis) ValidateUpdate(old runtime.Object) error {
+	if err := r.validateFeatureGates(); err != nil {
+		return err
+	}
+	if err := r.validateClassName(); err != nil {
+		return err
+	}
+	if err := r.ValidateConfig(); err != nil {
+		return err
+	}
+	if err := r.validateImages(); err != nil {
+		return err
+	}
+	if err := r.validateSidecars(); err != nil {
+		return err
+	}
+	if err := r.validateMetrics(); err != nil {
+		return err
+	}
+	if err := r.validateExporterProvider(); err != nil {
+		return err
+	}
+	if err := r.validateACL(); err != nil {
+		return err
+	}
+	if err := r.validatePassword(); err != nil {
+		return err
+	}
+	if err := r.validateProfile(); err != nil {
+		return err
+	}
+	if err := r.validateQoS(); err != nil {
+		return err
+	}
+	if err := r.validateCPUPinning(); err != nil {
+		return err
+	}
+	if err := r.validateSysctls(); err != nil {
+		return err
+	}
+	if err := r.validateOrchestratedUpdate(); err != nil {
+		return err
+	}
+	if err := r.validateHandover(); err != nil {
+		return err
+	}
+	if err := r.validateMaxFullSyncDuration(); err != nil {
+		return err
+	}
+	if err := r.validatePreDeleteHook(); err != nil {
+		return err
+	}
+	oldRedis, _ := old.(*Redis)
+	if err := r.validatePort(oldRedis); err != nil {
+		return err
+	}
+	if err := r.validateDataVolumeStorage(oldRedis); err != nil {
+		return err
+	}
+	if err := r.validateBlueGreen(oldRedis); err != nil {
+		return err
+	}
+	return r.validateService(oldRedis)
+}
+
+// ValidateDelete implements admission.Validator
+func (r *Redis) ValidateDelete() error {
+	return nil
+}
+
+// validateFeatureGates checks the feature gates annotation
+func (r *Redis) validateFeatureGates() error {
+	value, ok := r.GetAnnotations()[features.Annotation]
+	if !ok {
+		return nil
+	}
+	if _, err := features.DefaultGates.Parse(value); err != nil {
+		return fmt.Errorf("invalid feature gates: metadata.annotations[%s]: %s", features.Annotation, err)
+	}
+	return nil
+}
+
+// validateClassName requires the Redis image unless it is set by the RedisClass.
+// The RedisClass is not available to the webhook, the fields it sets are validated once expanded by the operator.
+func (r *Redis) validateClassName() error {
+	if r.Spec.ClassName == "" && r.Spec.Redis.Image == "" {
+		return fmt.Errorf("spec.redis.image: required unless spec.className is set")
+	}
+	return nil
+}
+
+// validateImages checks the image digests and, if RequireImageDigests is set,
+// that all the container images including the defaulted exporter image are pinned by digest.
+// The Redis image updated by the operator must be tagged with a version and is pinned by the operator.
+func (r *Redis) validateImages() error {
+	// the image set by the RedisClass is validated once expanded
+	classImage := r.Spec.ClassName != "" && r.Spec.Redis.Image == ""
+	var errs []string
+	if r.Spec.ImageUpdatePolicy != nil && !classImage {
+		if r.Spec.Redis.ImagePinned() {
+			errs = append(errs, "spec.redis.image: must not be pinned by digest when spec.imageUpdatePolicy is set")
+		}
+		if tag := imageTag(r.Spec.Redis.Image); !imageVersionRegexp.MatchString(tag) {
+			errs = append(errs, fmt.Sprintf("spec.redis.image: tag %q must be a version like 6.0.9 when spec.imageUpdatePolicy is set", tag))
+		}
+	}
+
+	containers := make(map[string]ContainerSpec)
+	if !classImage {
+		containers["spec.redis"] = r.Spec.Redis
+	}
+	if r.Spec.Exporter.Image != "" {
+		containers["spec.exporter"] = r.Spec.Exporter
+	}
+	if r.Spec.Metrics != nil {
+		containers["spec.metrics.container"] = ContainerSpec{Image: r.Spec.Metrics.Container.Image}
+	}
+	if r.Spec.Backup != nil {
+		containers["spec.backup.agent"] = r.Spec.Backup.Agent
+	}
+	if r.Spec.Restore != nil && r.Spec.Restore.Artifact != nil {
+		containers["spec.restore.artifact.agent"] = r.Spec.Restore.Artifact.Agent
+	}
+	for i, container := range r.Spec.InitContainers {
+		containers[fmt.Sprintf("spec.initContainers[%d]", i)] = ContainerSpec{Image: container.Image}
+	}
+	for i, container := range r.Spec.Sidecars {
+		containers[fmt.Sprintf("spec.sidecars[%d]", i)] = ContainerSpec{Image: container.Image}
+	}
+
+	for path, container := range containers {
+		if container.ImageDigest != "" && !imageDigestRegexp.MatchString(container.ImageDigest) {
+			errs = append(errs, fmt.Sprintf("%s.imageDigest: %q is not a sha256 digest", path, container.ImageDigest))
+		}
+		if path == "spec.redis" && r.Spec.ImageUpdatePolicy != nil {
+			continue
+		}
+		if RequireImageDigests && !container.ImagePinned() {
+			errs = append(errs, fmt.Sprintf("%s.image: %q must be pinned by digest", path, container.Image))
+		}
+	}
+	if len(errs) > 0 {
+		// map iteration order is random
+		sort.Strings(errs)
+		return fmt.Errorf("invalid images: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ValidateConfig checks the directives of spec.config with ValidateConfigDirective in the order of their names
+func (r *Redis) ValidateConfig() error {
+	names := make([]string, 0, len(r.Spec.Config))
+	for name := range r.Spec.Config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := ValidateConfigDirective(name, r.Spec.Config[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSidecars checks that the sidecar names are unique and differ from the names of the generated containers
+func (r *Redis) validateSidecars() error {
+	names := map[string]bool{redisContainerName: true, exporterContainerName: true}
+	if r.Spec.Metrics != nil {
+		names[r.Spec.Metrics.ContainerName()] = true
+	}
+	for i, sidecar := range r.Spec.Sidecars {
+		if names[sidecar.Name] {
+			return fmt.Errorf("invalid sidecars: spec.sidecars[%d].name: %q is already used", i, sidecar.Name)
+		}
+		names[sidecar.Name] = true
+	}
+	return nil
+}
+
+// validateACL checks that the Password the operator authenticates with is set when the default user is disabled
+func (r *Redis) validateACL() error {
+	if r.Spec.ACL == nil || !r.Spec.ACL.DisableDefaultUser || r.Spec.Password.SecretKeyRef != nil {
+		return nil
+	}
+	return fmt.Errorf("invalid acl: spec.acl.disableDefaultUser: requires spec.password")
+}
+
+// validateProfile checks that the profile is one of the presets
+func (r *Redis) validateProfile() error {
+	if r.Spec.Profile == "" || ValidProfile(r.Spec.Profile) {
+		return nil
+	}
+	return fmt.Errorf("invalid profile: spec.profile: %q is not one of small, medium, large", r.Spec.Profile)
+}
+
+// validatePassword checks that the Secret the CSI driver syncs the password to
+// and the password replacing the previous one are referenced
+func (r *Redis) validatePassword() error {
+	if r.Spec.Password.SecretKeyRef != nil {
+		return nil
+	}
+	if r.Spec.Password.SecretProviderClass != "" {
+		return fmt.Errorf("invalid password: spec.password.secretProviderClass: requires spec.password.secretKeyRef")
+	}
+	if r.Spec.Password.PreviousSecretKeyRef != nil {
+		return fmt.Errorf("invalid password: spec.password.previousSecretKeyRef: requires spec.password.secretKeyRef")
+	}
+	return nil
+}
+
+// validateOrchestratedUpdate checks that the rollout is not configured for both the operator and the StatefulSet
+func (r *Redis) validateOrchestratedUpdate() error {
+	if r.Spec.OrchestratedUpdate == nil || r.Spec.UpdateStrategy == nil {
+		return nil
+	}
+	return fmt.Errorf("invalid orchestratedUpdate: spec.updateStrategy: must not be set along with spec.orchestratedUpdate")
+}
+
+// validateHandover checks that the writes are not paused for too long during the handover
+func (r *Redis) validateHandover() error {
+	if r.Spec.Handover == nil || r.Spec.Handover.SyncTimeout == nil {
+		return nil
+	}
+	if timeout := r.Spec.Handover.SyncTimeout.Duration; timeout <= 0 || timeout > maxHandoverSyncTimeout {
+		return fmt.Errorf("invalid handover: spec.handover.syncTimeout: must be positive and at most %s", maxHandoverSyncTimeout)
+	}
+	return nil
+}
+
+// validateMaxFullSyncDuration checks that the replicas are given time to complete the full synchronization
+func (r *Redis) validateMaxFullSyncDuration() error {
+	if r.Spec.MaxFullSyncDuration != nil && r.Spec.MaxFullSyncDuration.Duration <= 0 {
+		return fmt.Errorf("invalid maxFullSyncDuration: spec.maxFullSyncDuration: must be positive")
+	}
+	return nil
+}
+
+// validatePreDeleteHook checks that the Job of the hook runs a container and terminates
+func (r *Redis) validatePreDeleteHook() error {
+	if r.Spec.PreDeleteHook == nil {
+		return nil
+	}
+	spec := r.Spec.PreDeleteHook.Template.Spec
+	if len(spec.Containers) == 0 {
+		return fmt.Errorf("invalid preDeleteHook: spec.preDeleteHook.template.spec.containers: must not be empty")
+	}
+	switch spec.RestartPolicy {
+	case "", corev1.RestartPolicyNever, corev1.RestartPolicyOnFailure:
+		return nil
+	}
+	return fmt.Errorf("invalid preDeleteHook: spec.preDeleteHook.template.spec.restartPolicy: %q is not supported by Jobs",
+		spec.RestartPolicy)
+}
+
+// validateBlueGreen checks that the blue Redis is another one and is not changed, and that the cutover is not reverted
+func (r *Redis) validateBlueGreen(old *Redis) error {
+	blueGreen := r.Spec.BlueGreen
+	if blueGreen == nil {
+		return nil
+	}
+	if blueGreen.Blue == "" || blueGreen.Blue == r.GetName() {
+		return fmt.Errorf("invalid blueGreen: spec.blueGreen.blue: must be the name of another Redis")
+	}
+	if old == nil || old.Spec.BlueGreen == nil {
+		return nil
+	}
+	if blueGreen.Blue != old.Spec.BlueGreen.Blue {
+		return fmt.Errorf("invalid blueGreen: spec.blueGreen.blue: may not be changed")
+	}
+	if old.Spec.BlueGreen.Cutover && !blueGreen.Cutover {
+		return fmt.Errorf("invalid blueGreen: spec.blueGreen.cutover: may not be reverted")
+	}
+	return nil
+}
+
+// validatePort checks that the port is not changed: the instances restarted on the new port
+// would not be able to replicate from the master until it is restarted too
+func (r *Redis) validatePort(old *Redis) error {
+	if old == nil {
+		return nil
+	}
+	port, oldPort := r.Spec.Port, old.Spec.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	if oldPort == 0 {
+		oldPort = defaultPort
+	}
+	if port != oldPort {
+		return fmt.Errorf("invalid port: spec.port: may not be changed")
+	}
+	return nil
+}
+
+// validateDataVolumeStorage checks that the storage request of the data volumes is not decreased,
+// the PersistentVolumeClaims can only be expanded
+func (r *Redis) validateDataVolumeStorage(old *Redis) error {
+	if old == nil {
+		return nil
+	}
+	storage := r.Spec.DataVolumeClaimTemplate.Spec.Resources.Requests[corev1.ResourceStorage]
+	oldStorage, ok := old.Spec.DataVolumeClaimTemplate.Spec.Resources.Requests[corev1.ResourceStorage]
+	if ok && storage.Cmp(oldStorage) < 0 {
+		return fmt.Errorf("invalid dataVolumeClaimTemplate: spec.dataVolumeClaimTemplate.spec.resources.requests.storage: "+
+			"may not be decreased from %s", oldStorage.String())
+	}
+	return nil
+}
+
+// validateService checks that the load balancer class is set only for the LoadBalancer master Service
+// and is not set or changed while the Service stays a LoadBalancer, the field is immutable.
+func (r *Redis) validateService(old *Redis) error {
+	service := r.Spec.Service
+	if service == nil || service.LoadBalancerClass == nil {
+		return nil
+	}
+	if service.Type != corev1.ServiceTypeLoadBalancer {
+		return fmt.Errorf("invalid service: spec.service.loadBalancerClass: may only be set when spec.service.type is %s",
+			corev1.ServiceTypeLoadBalancer)
+	}
+	if old == nil || old.Spec.Service == nil || old.Spec.Service.Type != corev1.ServiceTypeLoadBalancer {
+		return nil
+	}
+	if oldClass := old.Spec.Service.LoadBalancerClass; oldClass == nil || *oldClass != *service.LoadBalancerClass {
+		return fmt.Errorf("invalid service: spec.service.loadBalancerClass: may not be changed while spec.service.type is %s",
+			corev1.ServiceTypeLoadBalancer)
+	}
+	return nil
+}
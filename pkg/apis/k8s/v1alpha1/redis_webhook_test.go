@@ -0,0 +1,564 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amaizfinance/redis-operator/pkg/features"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRedis_Default(t *testing.T) {
+	replicas := int32(5)
+	affinity := &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}}
+
+	tests := []struct {
+		name              string
+		spec              RedisSpec
+		wantReplicas      int32
+		wantExporterImage string
+		wantAffinity      *corev1.Affinity
+	}{
+		{
+			name:         "empty",
+			wantReplicas: DefaultReplicas,
+			wantAffinity: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+					Weight: antiAffinityWeight,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{redisNameLabelKey: "example"}},
+						TopologyKey:   corev1.LabelHostname,
+					},
+				}},
+			}},
+		},
+		{
+			name: "exporter without image",
+			spec: RedisSpec{
+				Replicas: &replicas,
+				Affinity: affinity,
+				Exporter: ContainerSpec{InitialDelaySeconds: 10},
+			},
+			wantReplicas:      replicas,
+			wantExporterImage: DefaultExporterImage,
+			wantAffinity:      affinity,
+		},
+		{
+			name: "exporter with image",
+			spec: RedisSpec{
+				Affinity: affinity,
+				Exporter: ContainerSpec{Image: "exporter"},
+			},
+			wantReplicas:      DefaultReplicas,
+			wantExporterImage: "exporter",
+			wantAffinity:      affinity,
+		},
+		{
+			name: "telegraf without image",
+			spec: RedisSpec{
+				Affinity:         affinity,
+				Exporter:         ContainerSpec{InitialDelaySeconds: 10},
+				ExporterProvider: ExporterProviderTelegraf,
+			},
+			wantReplicas:      DefaultReplicas,
+			wantExporterImage: DefaultTelegrafImage,
+			wantAffinity:      affinity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: tt.spec}
+			r.Default()
+
+			if *r.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("Default() replicas = %v, want %v", *r.Spec.Replicas, tt.wantReplicas)
+			}
+			if r.Labels == nil || r.Annotations == nil || r.Spec.Annotations == nil || r.Spec.Config == nil || r.Spec.NodeSelector == nil {
+				t.Errorf("Default() left nil maps: %+v", r)
+			}
+			if r.Spec.Exporter.Image != tt.wantExporterImage {
+				t.Errorf("Default() exporter image = %v, want %v", r.Spec.Exporter.Image, tt.wantExporterImage)
+			}
+			if (r.Spec.Exporter.Resources.Requests != nil) != (tt.wantExporterImage != "") {
+				t.Errorf("Default() exporter resources = %v", r.Spec.Exporter.Resources)
+			}
+			if !reflect.DeepEqual(r.Spec.Affinity, tt.wantAffinity) {
+				t.Errorf("Default() affinity = %v, want %v", r.Spec.Affinity, tt.wantAffinity)
+			}
+		})
+	}
+}
+
+func TestRedis_Default_update(t *testing.T) {
+	r := &Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", CreationTimestamp: metav1.Now()},
+		Spec:       RedisSpec{Exporter: ContainerSpec{InitialDelaySeconds: 10}},
+	}
+	r.Default()
+
+	if r.Spec.Exporter.Image != DefaultExporterImage {
+		t.Errorf("Default() exporter image = %v, want %v", r.Spec.Exporter.Image, DefaultExporterImage)
+	}
+	if r.Spec.Exporter.Resources.Requests != nil || r.Spec.Exporter.Resources.Limits != nil {
+		t.Errorf("Default() exporter resources = %v, want them left out on update", r.Spec.Exporter.Resources)
+	}
+	if r.Spec.Affinity != nil {
+		t.Errorf("Default() affinity = %v, want it left out on update", r.Spec.Affinity)
+	}
+}
+
+func TestRedis_validateImages(t *testing.T) {
+	tests := []struct {
+		name           string
+		spec           RedisSpec
+		requireDigests bool
+		wantErr        bool
+	}{
+		{"tag", RedisSpec{Redis: ContainerSpec{Image: "redis:6.0"}}, false, false},
+		{"invalid digest", RedisSpec{Redis: ContainerSpec{Image: "redis", ImageDigest: "sha256:00"}}, false, true},
+		{"digests required", RedisSpec{Redis: ContainerSpec{Image: "redis:6.0"}}, true, true},
+		{"no image", RedisSpec{}, false, true},
+		{"class image", RedisSpec{ClassName: "default"}, true, false},
+		{
+			name: "class image with update policy",
+			spec: RedisSpec{
+				ClassName:         "default",
+				ImageUpdatePolicy: &ImageUpdatePolicy{Track: ImageUpdateTrackPatch},
+			},
+		},
+		{
+			name: "all pinned",
+			spec: RedisSpec{
+				Redis:          ContainerSpec{Image: "redis", ImageDigest: testDigest},
+				Exporter:       ContainerSpec{Image: "exporter@" + testDigest},
+				InitContainers: []corev1.Container{{Image: "busybox@" + testDigest}},
+			},
+			requireDigests: true,
+		},
+		{
+			name: "init container not pinned",
+			spec: RedisSpec{
+				Redis:          ContainerSpec{Image: "redis", ImageDigest: testDigest},
+				InitContainers: []corev1.Container{{Image: "busybox"}},
+			},
+			requireDigests: true,
+			wantErr:        true,
+		},
+		{
+			name: "update policy",
+			spec: RedisSpec{
+				Redis:             ContainerSpec{Image: "redis:6.0.9-alpine"},
+				ImageUpdatePolicy: &ImageUpdatePolicy{Track: ImageUpdateTrackPatch},
+			},
+			requireDigests: true,
+		},
+		{
+			name: "update policy without version",
+			spec: RedisSpec{
+				Redis:             ContainerSpec{Image: "redis:6-alpine"},
+				ImageUpdatePolicy: &ImageUpdatePolicy{Track: ImageUpdateTrackPatch},
+			},
+			wantErr: true,
+		},
+		{
+			name: "update policy with digest",
+			spec: RedisSpec{
+				Redis:             ContainerSpec{Image: "redis:6.0.9", ImageDigest: testDigest},
+				ImageUpdatePolicy: &ImageUpdatePolicy{Track: ImageUpdateTrackMinor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sidecar not pinned",
+			spec: RedisSpec{
+				Redis:    ContainerSpec{Image: "redis", ImageDigest: testDigest},
+				Sidecars: []corev1.Container{{Name: "fluent-bit", Image: "fluent/fluent-bit"}},
+			},
+			requireDigests: true,
+			wantErr:        true,
+		},
+		{
+			name: "backup agent not pinned",
+			spec: RedisSpec{
+				Redis:  ContainerSpec{Image: "redis", ImageDigest: testDigest},
+				Backup: &Backup{Agent: ContainerSpec{Image: "rclone/rclone"}},
+			},
+			requireDigests: true,
+			wantErr:        true,
+		},
+	}
+	defer func(required bool) { RequireImageDigests = required }(RequireImageDigests)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			RequireImageDigests = tt.requireDigests
+			r := &Redis{Spec: tt.spec}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validateService(t *testing.T) {
+	class, otherClass := "example.com/internal", "example.com/external"
+	tests := []struct {
+		name    string
+		service *Service
+		old     *Service
+		wantErr bool
+	}{
+		{"omitted", nil, nil, false},
+		{"node port", &Service{Type: corev1.ServiceTypeNodePort}, nil, false},
+		{"load balancer class", &Service{Type: corev1.ServiceTypeLoadBalancer, LoadBalancerClass: &class}, nil, false},
+		{"class without load balancer", &Service{Type: corev1.ServiceTypeNodePort, LoadBalancerClass: &class}, nil, true},
+		{
+			"class set on load balancer",
+			&Service{Type: corev1.ServiceTypeLoadBalancer, LoadBalancerClass: &class},
+			&Service{Type: corev1.ServiceTypeLoadBalancer},
+			true,
+		},
+		{
+			"class unchanged",
+			&Service{Type: corev1.ServiceTypeLoadBalancer, LoadBalancerClass: &class},
+			&Service{Type: corev1.ServiceTypeLoadBalancer, LoadBalancerClass: &class},
+			false,
+		},
+		{
+			"class changed",
+			&Service{Type: corev1.ServiceTypeLoadBalancer, LoadBalancerClass: &otherClass},
+			&Service{Type: corev1.ServiceTypeLoadBalancer, LoadBalancerClass: &class},
+			true,
+		},
+		{
+			"class changed with type",
+			&Service{Type: corev1.ServiceTypeLoadBalancer, LoadBalancerClass: &otherClass},
+			&Service{Type: corev1.ServiceTypeClusterIP},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, Service: tt.service}}
+			old := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, Service: tt.old}}
+			if err := r.ValidateUpdate(old); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validateACL(t *testing.T) {
+	password := Password{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "redis-password-secret"},
+		Key:                  "password",
+	}}
+	tests := []struct {
+		name     string
+		acl      *ACL
+		password Password
+		wantErr  bool
+	}{
+		{"omitted", nil, Password{}, false},
+		{"default user enabled", &ACL{Users: []ACLUser{{Name: "app"}}}, Password{}, false},
+		{"default user disabled", &ACL{DisableDefaultUser: true}, password, false},
+		{"default user disabled without password", &ACL{DisableDefaultUser: true}, Password{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, ACL: tt.acl, Password: tt.password}}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validatePassword(t *testing.T) {
+	secretKeyRef := &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "redis-password-secret"},
+		Key:                  "password",
+	}
+	tests := []struct {
+		name     string
+		password Password
+		wantErr  bool
+	}{
+		{"omitted", Password{}, false},
+		{"secret", Password{SecretKeyRef: secretKeyRef}, false},
+		{"secret provider class", Password{SecretKeyRef: secretKeyRef, SecretProviderClass: "vault-redis"}, false},
+		{"secret provider class without secret", Password{SecretProviderClass: "vault-redis"}, true},
+		{"previous", Password{SecretKeyRef: secretKeyRef, PreviousSecretKeyRef: secretKeyRef}, false},
+		{"previous without secret", Password{PreviousSecretKeyRef: secretKeyRef}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, Password: tt.password}}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validateOrchestratedUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		update   *OrchestratedUpdate
+		strategy *appsv1.StatefulSetUpdateStrategy
+		wantErr  bool
+	}{
+		{"omitted", nil, nil, false},
+		{"StatefulSet strategy", nil, &appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}, false},
+		{"orchestrated", &OrchestratedUpdate{MaxReplicationLag: 1024}, nil, false},
+		{"both", &OrchestratedUpdate{}, &appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, OrchestratedUpdate: tt.update,
+				UpdateStrategy: tt.strategy}}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validateHandover(t *testing.T) {
+	tests := []struct {
+		name     string
+		handover *Handover
+		wantErr  bool
+	}{
+		{"omitted", nil, false},
+		{"lag only", &Handover{MaxLag: 1024}, false},
+		{"timeout", &Handover{SyncTimeout: &metav1.Duration{Duration: 10 * time.Second}}, false},
+		{"zero timeout", &Handover{SyncTimeout: &metav1.Duration{}}, true},
+		{"long timeout", &Handover{SyncTimeout: &metav1.Duration{Duration: time.Minute}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, Handover: tt.handover}}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validateMaxFullSyncDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration *metav1.Duration
+		wantErr  bool
+	}{
+		{"omitted", nil, false},
+		{"positive", &metav1.Duration{Duration: 30 * time.Minute}, false},
+		{"zero", &metav1.Duration{}, true},
+		{"negative", &metav1.Duration{Duration: -time.Minute}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, MaxFullSyncDuration: tt.duration}}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validatePreDeleteHook(t *testing.T) {
+	containers := []corev1.Container{{Name: "dump", Image: "redis"}}
+	tests := []struct {
+		name    string
+		hook    *PreDeleteHook
+		wantErr bool
+	}{
+		{"omitted", nil, false},
+		{"default restart policy", &PreDeleteHook{Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: containers}}}, false},
+		{"OnFailure", &PreDeleteHook{Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: containers, RestartPolicy: corev1.RestartPolicyOnFailure}}}, false},
+		{"Always", &PreDeleteHook{Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: containers, RestartPolicy: corev1.RestartPolicyAlways}}}, true},
+		{"no containers", &PreDeleteHook{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, PreDeleteHook: tt.hook}}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validatePort(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    int32
+		old     int32
+		wantErr bool
+	}{
+		{"default", 0, 0, false},
+		{"default set explicitly", 6379, 0, false},
+		{"unchanged", 7000, 7000, false},
+		{"changed", 7000, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, Port: tt.port}}
+			old := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, Port: tt.old}}
+			if err := r.ValidateUpdate(old); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validateBlueGreen(t *testing.T) {
+	tests := []struct {
+		name      string
+		blueGreen *BlueGreen
+		old       *BlueGreen
+		wantErr   bool
+	}{
+		{"unset", nil, nil, false},
+		{"set", &BlueGreen{Blue: "blue"}, nil, false},
+		{"no blue", &BlueGreen{}, nil, true},
+		{"itself", &BlueGreen{Blue: "green"}, nil, true},
+		{"cut over", &BlueGreen{Blue: "blue", Cutover: true}, &BlueGreen{Blue: "blue"}, false},
+		{"blue changed", &BlueGreen{Blue: "another"}, &BlueGreen{Blue: "blue"}, true},
+		{"cutover reverted", &BlueGreen{Blue: "blue"}, &BlueGreen{Blue: "blue", Cutover: true}, true},
+		{"removed", nil, &BlueGreen{Blue: "blue", Cutover: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{ObjectMeta: metav1.ObjectMeta{Name: "green"}, Spec: RedisSpec{BlueGreen: tt.blueGreen}}
+			old := &Redis{ObjectMeta: metav1.ObjectMeta{Name: "green"}, Spec: RedisSpec{BlueGreen: tt.old}}
+			if err := r.validateBlueGreen(old); (err != nil) != tt.wantErr {
+				t.Errorf("validateBlueGreen() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validateDataVolumeStorage(t *testing.T) {
+	claim := func(storage string) corev1.PersistentVolumeClaim {
+		if storage == "" {
+			return corev1.PersistentVolumeClaim{}
+		}
+		return corev1.PersistentVolumeClaim{Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(storage)},
+		}}}
+	}
+	tests := []struct {
+		name    string
+		storage string
+		old     string
+		wantErr bool
+	}{
+		{"no volumes", "", "", false},
+		{"added", "1Gi", "", false},
+		{"unchanged", "1Gi", "1024Mi", false},
+		{"expanded", "2Gi", "1Gi", false},
+		{"decreased", "512Mi", "1Gi", true},
+		{"removed", "", "1Gi", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, DataVolumeClaimTemplate: claim(tt.storage)}}
+			old := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, DataVolumeClaimTemplate: claim(tt.old)}}
+			if err := r.ValidateUpdate(old); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_ValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]string
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"valid", map[string]string{"maxmemory": "100mb", "save": "900 1 300 10"}, false},
+		{"injected directive", map[string]string{"maxmemory": "100mb\ninclude /tmp/evil.conf"}, true},
+		{"injected by name", map[string]string{"maxmemory 100mb\nport": "6380"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, Config: tt.config}}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := r.ValidateUpdate(r.DeepCopy()); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validateSidecars(t *testing.T) {
+	tests := []struct {
+		name     string
+		sidecars []corev1.Container
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"unique", []corev1.Container{{Name: "fluent-bit"}, {Name: "backup-agent"}}, false},
+		{"redis", []corev1.Container{{Name: "redis"}}, true},
+		{"exporter", []corev1.Container{{Name: "exporter"}}, true},
+		{"duplicate", []corev1.Container{{Name: "fluent-bit"}, {Name: "fluent-bit"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Redis: ContainerSpec{Image: "redis"}, Sidecars: tt.sidecars}}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedis_validateFeatureGates(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{"none", nil, false},
+		{"empty", map[string]string{features.Annotation: ""}, false},
+		{"unknown", map[string]string{features.Annotation: "Unknown=true"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+				Spec:       RedisSpec{Redis: ContainerSpec{Image: "redis"}},
+			}
+			if err := r.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RedisBackup is a request to take a single RDB snapshot of Redis and upload it to the object storage
+// configured in the Redis spec.backup. The snapshot is taken by a Job owned by the RedisBackup.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:printcolumn:name="Redis",type="string",JSONPath=".spec.redisName",description="Name of the Redis"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Phase of the backup"
+// +kubebuilder:printcolumn:name="Location",type="string",JSONPath=".status.location",description="Location of the snapshot"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+type RedisBackup struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata.
+	// More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#metadata
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RedisBackupSpec   `json:"spec"`
+	Status RedisBackupStatus `json:"status,omitempty"`
+}
+
+// RedisBackupSpec defines the desired state of RedisBackup
+type RedisBackupSpec struct {
+	// RedisName is the name of the Redis in the same namespace to back up
+	RedisName string `json:"redisName"`
+}
+
+// BackupPhase is the phase of a RedisBackup
+type BackupPhase string
+
+const (
+	// BackupPhasePending means that the backup Job has not been created yet
+	BackupPhasePending BackupPhase = "Pending"
+	// BackupPhaseRunning means that the backup Job is running
+	BackupPhaseRunning BackupPhase = "Running"
+	// BackupPhaseSucceeded means that the snapshot has been uploaded
+	BackupPhaseSucceeded BackupPhase = "Succeeded"
+	// BackupPhaseFailed means that the backup has failed and will not be retried
+	BackupPhaseFailed BackupPhase = "Failed"
+)
+
+// RedisBackupStatus contains the observed state of RedisBackup
+type RedisBackupStatus struct {
+	// Phase of the backup
+	// +optional
+	Phase BackupPhase `json:"phase,omitempty"`
+	// SourcePod is the name of the replica Pod the snapshot is taken from
+	// +optional
+	SourcePod string `json:"sourcePod,omitempty"`
+	// Location is the URL of the snapshot in the object storage
+	// +optional
+	Location string `json:"location,omitempty"`
+	// StartTime is the time the backup Job was created
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time the backup succeeded or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Size is the size of the uploaded snapshot in bytes
+	// +optional
+	Size int64 `json:"size,omitempty"`
+	// Message is a human readable message indicating details about the failure
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RedisBackupList is a list of RedisBackup resources
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type RedisBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata. More info:
+	// https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	// +k8s:openapi-gen=false
+	metav1.ListMeta `json:"metadata,omitempty"`
+	// List of RedisBackup resources
+	Items []RedisBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RedisBackup{}, &RedisBackupList{})
+}
@@ -0,0 +1,249 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// lockableFields are the fields of the RedisClass that can be locked, along with config.<directive>
+var lockableFields = map[string]bool{
+	"redis":                     true,
+	"redis.image":               true,
+	"redis.resources":           true,
+	"redis.securityContext":     true,
+	"redis.initialDelaySeconds": true,
+	"redis.livenessProbe":       true,
+	"redis.readinessProbe":      true,
+	"redis.startupProbe":        true,
+	"exporter":                  true,
+	"exporterProvider":          true,
+	"imagePullSecrets":          true,
+	"profile":                   true,
+	"config":                    true,
+	"backup":                    true,
+	"service":                   true,
+	"replicaService":            true,
+	"externalAccess":            true,
+}
+
+// ValidateLockedFields checks that the locked fields are the fields of the class or config.<directive>
+func (c *RedisClassSpec) ValidateLockedFields() error {
+	var invalid []string
+	for _, field := range c.LockedFields {
+		if directive := strings.TrimPrefix(field, "config."); lockableFields[field] || directive != field && directive != "" {
+			continue
+		}
+		invalid = append(invalid, field)
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid locked fields: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// classMerge merges the RedisClass into the spec collecting the conflicts with the locked fields
+type classMerge struct {
+	locked    map[string]bool
+	conflicts []string
+}
+
+// isLocked reports whether the field or the field it belongs to, e.g. redis for redis.image, is locked
+func (m *classMerge) isLocked(field string) bool {
+	if m.locked[field] {
+		return true
+	}
+	i := strings.IndexByte(field, '.')
+	return i > 0 && m.locked[field[:i]]
+}
+
+// useClass reports whether the value of the class replaces the one of the spec: the field is omitted in the spec
+// or locked by the class. The locked field set to another value in the spec is a conflict.
+func (m *classMerge) useClass(field string, omitted, equal bool) bool {
+	if omitted {
+		return true
+	}
+	if !m.isLocked(field) {
+		return false
+	}
+	if !equal {
+		m.conflicts = append(m.conflicts, field)
+	}
+	return true
+}
+
+// ApplyClass expands the RedisClass into the spec and returns the locked fields the spec sets to other values.
+// The merge strategy is:
+//   - the fields omitted in the spec are set to the ones of the class,
+//   - the fields set in the spec take precedence over the class unless locked by Spec.LockedFields of the class,
+//   - the locked fields are always set to the ones of the class, even if omitted in the class;
+//     the spec setting them to other values is a conflict, reported by the operator with the ClassConflict condition.
+//
+// The configuration directives of the class are set unless present in Spec.Config, a locked directive replaces
+// the one of the spec and the config field locks all the directives of the class. The exporter of the class is run
+// unless the spec configures the exporter or the metrics sidecar, the image and the resources are defaulted
+// as by the webhook. The resources of the redis container are not set along with the profile of the spec
+// unless locked. ApplyClass is followed by ApplyProfile, so the profile of the class is expanded as well.
+func (r *Redis) ApplyClass(class *RedisClassSpec) []string {
+	m := &classMerge{locked: make(map[string]bool, len(class.LockedFields))}
+	for _, field := range class.LockedFields {
+		if directive := strings.TrimPrefix(field, "config."); directive != field {
+			field = "config." + strings.ToLower(directive)
+		}
+		m.locked[field] = true
+	}
+
+	m.applyContainer("redis", &r.Spec.Redis, class.Redis, r.Spec.Profile == "")
+
+	if m.useClass("exporterProvider", r.Spec.ExporterProvider == "", r.Spec.ExporterProvider == class.ExporterProvider) {
+		r.Spec.ExporterProvider = class.ExporterProvider
+	}
+	exporterOmitted := reflect.DeepEqual(r.Spec.Exporter, ContainerSpec{}) && r.Spec.Metrics == nil
+	if m.useClass("exporter", exporterOmitted, r.Spec.Metrics == nil &&
+		(equality.Semantic.DeepEqual(r.Spec.Exporter, class.Exporter) ||
+			equality.Semantic.DeepEqual(r.Spec.Exporter, r.defaultedExporter(class.Exporter)))) {
+		r.Spec.Exporter = *class.Exporter.DeepCopy()
+		// the metrics sidecar can not be run along with the exporter
+		r.Spec.Metrics = nil
+	}
+	r.defaultExporter()
+
+	if m.useClass("imagePullSecrets", r.Spec.ImagePullSecrets == nil,
+		equality.Semantic.DeepEqual(r.Spec.ImagePullSecrets, class.ImagePullSecrets)) {
+		r.Spec.ImagePullSecrets = append([]corev1.LocalObjectReference(nil), class.ImagePullSecrets...)
+	}
+	if m.useClass("profile", r.Spec.Profile == "", r.Spec.Profile == class.Profile) {
+		r.Spec.Profile = class.Profile
+	}
+
+	m.applyConfig(r, class.Config)
+
+	if m.useClass("backup", r.Spec.Backup == nil, equality.Semantic.DeepEqual(r.Spec.Backup, class.Backup)) {
+		r.Spec.Backup = class.Backup.DeepCopy()
+	}
+	if m.useClass("service", r.Spec.Service == nil, equality.Semantic.DeepEqual(r.Spec.Service, class.Service)) {
+		r.Spec.Service = class.Service.DeepCopy()
+	}
+	if m.useClass("replicaService", r.Spec.ReplicaService == nil,
+		equality.Semantic.DeepEqual(r.Spec.ReplicaService, class.ReplicaService)) {
+		r.Spec.ReplicaService = class.ReplicaService.DeepCopy()
+	}
+	if m.useClass("externalAccess", r.Spec.ExternalAccess == nil,
+		equality.Semantic.DeepEqual(r.Spec.ExternalAccess, class.ExternalAccess)) {
+		r.Spec.ExternalAccess = class.ExternalAccess.DeepCopy()
+	}
+	// the directives are merged in the random order
+	sort.Strings(m.conflicts)
+	return m.conflicts
+}
+
+// defaultedExporter returns the exporter defaulted by the webhook for the Redis, so the exporter of the class
+// the webhook has defaulted in the spec is not a conflict
+func (r *Redis) defaultedExporter(exporter ContainerSpec) ContainerSpec {
+	defaulted := &Redis{Spec: RedisSpec{
+		Exporter:         *exporter.DeepCopy(),
+		ExporterProvider: r.Spec.ExporterProvider,
+		CPUPinning:       r.Spec.CPUPinning,
+	}}
+	defaulted.defaultExporter()
+	return defaulted.Spec.Exporter
+}
+
+// applyContainer merges the container of the class into the one of the spec field by field.
+// The digest is set along with the image.
+func (m *classMerge) applyContainer(field string, spec *ContainerSpec, class ContainerSpec, resources bool) {
+	if m.useClass(field+".image", spec.Image == "", spec.Image == class.Image && spec.ImageDigest == class.ImageDigest) {
+		spec.Image, spec.ImageDigest = class.Image, class.ImageDigest
+	}
+	omitted := resources && reflect.DeepEqual(spec.Resources, corev1.ResourceRequirements{})
+	if m.useClass(field+".resources", omitted, equality.Semantic.DeepEqual(spec.Resources, class.Resources)) {
+		spec.Resources = *class.Resources.DeepCopy()
+	}
+	if m.useClass(field+".securityContext", spec.SecurityContext == nil,
+		equality.Semantic.DeepEqual(spec.SecurityContext, class.SecurityContext)) {
+		spec.SecurityContext = class.SecurityContext.DeepCopy()
+	}
+	if m.useClass(field+".initialDelaySeconds", spec.InitialDelaySeconds == 0,
+		spec.InitialDelaySeconds == class.InitialDelaySeconds) {
+		spec.InitialDelaySeconds = class.InitialDelaySeconds
+	}
+	if m.useClass(field+".livenessProbe", spec.LivenessProbe == nil,
+		equality.Semantic.DeepEqual(spec.LivenessProbe, class.LivenessProbe)) {
+		spec.LivenessProbe = class.LivenessProbe.DeepCopy()
+	}
+	if m.useClass(field+".readinessProbe", spec.ReadinessProbe == nil,
+		equality.Semantic.DeepEqual(spec.ReadinessProbe, class.ReadinessProbe)) {
+		spec.ReadinessProbe = class.ReadinessProbe.DeepCopy()
+	}
+	if m.useClass(field+".startupProbe", spec.StartupProbe == nil,
+		equality.Semantic.DeepEqual(spec.StartupProbe, class.StartupProbe)) {
+		spec.StartupProbe = class.StartupProbe.DeepCopy()
+	}
+}
+
+// applyConfig merges the directives of the class into Spec.Config. The directive names are case-insensitive,
+// the locked directives the class omits are removed from the spec.
+func (m *classMerge) applyConfig(r *Redis, class map[string]string) {
+	specNames := make(map[string]string, len(r.Spec.Config))
+	for k := range r.Spec.Config {
+		specNames[strings.ToLower(k)] = k
+	}
+	// nil is the value of the locked directive the class omits
+	directives := make(map[string]*string, len(class))
+	for k, v := range class {
+		v := v
+		directives[strings.ToLower(k)] = &v
+	}
+	for field := range m.locked {
+		if directive := strings.TrimPrefix(field, "config."); directive != field {
+			if _, ok := directives[directive]; !ok {
+				directives[directive] = nil
+			}
+		}
+	}
+	// locking the whole config removes the directives the class omits as well
+	if m.locked["config"] {
+		for directive := range specNames {
+			if _, ok := directives[directive]; !ok {
+				directives[directive] = nil
+			}
+		}
+	}
+	if len(r.Spec.Config) == 0 && len(class) == 0 {
+		return
+	}
+
+	config := make(map[string]string, len(r.Spec.Config)+len(directives))
+	for k, v := range r.Spec.Config {
+		config[k] = v
+	}
+	for directive, value := range directives {
+		name, set := specNames[directive]
+		if !m.useClass("config."+directive, !set, value != nil && r.Spec.Config[name] == *value) {
+			continue
+		}
+		delete(config, name)
+		if value != nil {
+			config[directive] = *value
+		}
+	}
+	r.Spec.Config = config
+}
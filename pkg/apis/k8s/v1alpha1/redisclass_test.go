@@ -0,0 +1,167 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestRedis_ApplyClass(t *testing.T) {
+	classResources := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}}
+	class := &RedisClassSpec{
+		Redis:    ContainerSpec{Image: "redis:6.0.9", Resources: classResources},
+		Exporter: ContainerSpec{Image: "exporter"},
+		Config:   map[string]string{"MaxMemory-Policy": "allkeys-lru", "appendonly": "yes"},
+		Backup:   &Backup{Agent: ContainerSpec{Image: "agent"}},
+		Service:  &Service{Type: corev1.ServiceTypeLoadBalancer},
+	}
+	tests := []struct {
+		name         string
+		spec         RedisSpec
+		wantImage    string
+		wantMemory   string
+		wantExporter string
+		wantConfig   map[string]string
+		wantService  corev1.ServiceType
+	}{
+		{"class defaults", RedisSpec{}, "redis:6.0.9", "1Gi", "exporter",
+			map[string]string{"maxmemory-policy": "allkeys-lru", "appendonly": "yes"}, corev1.ServiceTypeLoadBalancer},
+		{"overridden", RedisSpec{
+			Redis:   ContainerSpec{Image: "redis:6.2.1"},
+			Metrics: &Metrics{Container: corev1.Container{Image: "metrics"}},
+			Config:  map[string]string{"maxmemory-POLICY": "noeviction"},
+			Service: &Service{Type: corev1.ServiceTypeNodePort},
+		}, "redis:6.2.1", "1Gi", "", map[string]string{"maxmemory-POLICY": "noeviction", "appendonly": "yes"},
+			corev1.ServiceTypeNodePort},
+		{"spec profile", RedisSpec{Profile: ProfileSmall}, "redis:6.0.9", "", "exporter",
+			map[string]string{"maxmemory-policy": "allkeys-lru", "appendonly": "yes"}, corev1.ServiceTypeLoadBalancer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: tt.spec}
+			r.ApplyClass(class)
+			if r.Spec.Redis.Image != tt.wantImage {
+				t.Errorf("ApplyClass() image = %s, want %s", r.Spec.Redis.Image, tt.wantImage)
+			}
+			memory, ok := r.Spec.Redis.Resources.Limits[corev1.ResourceMemory]
+			if tt.wantMemory == "" && ok || tt.wantMemory != "" && memory.String() != tt.wantMemory {
+				t.Errorf("ApplyClass() memory limit = %s, want %s", memory.String(), tt.wantMemory)
+			}
+			if r.Spec.Exporter.Image != tt.wantExporter {
+				t.Errorf("ApplyClass() exporter image = %s, want %s", r.Spec.Exporter.Image, tt.wantExporter)
+			}
+			if tt.wantExporter != "" && r.Spec.Exporter.Resources.Limits == nil {
+				t.Errorf("ApplyClass() exporter resources are not defaulted")
+			}
+			if !reflect.DeepEqual(r.Spec.Config, tt.wantConfig) {
+				t.Errorf("ApplyClass() config = %v, want %v", r.Spec.Config, tt.wantConfig)
+			}
+			if r.Spec.Service.Type != tt.wantService {
+				t.Errorf("ApplyClass() service type = %s, want %s", r.Spec.Service.Type, tt.wantService)
+			}
+			if r.Spec.Backup == class.Backup || r.Spec.Backup == nil {
+				t.Errorf("ApplyClass() backup = %v, want a copy of the class backup", r.Spec.Backup)
+			}
+		})
+	}
+}
+
+func TestRedis_ApplyClassLocked(t *testing.T) {
+	tests := []struct {
+		name          string
+		class         RedisClassSpec
+		spec          RedisSpec
+		wantImage     string
+		wantConfig    map[string]string
+		wantMetrics   bool
+		wantConflicts []string
+	}{
+		{"unlocked", RedisClassSpec{Redis: ContainerSpec{Image: "redis:6.0.9"}},
+			RedisSpec{Redis: ContainerSpec{Image: "redis:6.2.1"}}, "redis:6.2.1", nil, false, nil},
+		{"locked image", RedisClassSpec{Redis: ContainerSpec{Image: "redis:6.0.9"}, LockedFields: []string{"redis.image"}},
+			RedisSpec{Redis: ContainerSpec{Image: "redis:6.2.1"}}, "redis:6.0.9", nil, false, []string{"redis.image"}},
+		{"locked container", RedisClassSpec{Redis: ContainerSpec{Image: "redis:6.0.9"}, LockedFields: []string{"redis"}},
+			RedisSpec{Redis: ContainerSpec{Image: "redis:6.2.1"}}, "redis:6.0.9", nil, false, []string{"redis.image"}},
+		{"locked image without conflict", RedisClassSpec{Redis: ContainerSpec{Image: "redis:6.0.9"}, LockedFields: []string{"redis.image"}},
+			RedisSpec{Redis: ContainerSpec{Image: "redis:6.0.9"}}, "redis:6.0.9", nil, false, nil},
+		{"locked directive", RedisClassSpec{Config: map[string]string{"maxmemory": "1gb"}, LockedFields: []string{"config.MaxMemory"}},
+			RedisSpec{Config: map[string]string{"MAXMEMORY": "2gb", "appendonly": "yes"}}, "",
+			map[string]string{"maxmemory": "1gb", "appendonly": "yes"}, false, []string{"config.maxmemory"}},
+		{"locked omitted directive", RedisClassSpec{LockedFields: []string{"config.maxmemory"}},
+			RedisSpec{Config: map[string]string{"maxmemory": "2gb", "appendonly": "yes"}}, "",
+			map[string]string{"appendonly": "yes"}, false, []string{"config.maxmemory"}},
+		{"locked config", RedisClassSpec{Config: map[string]string{"maxmemory": "1gb"}, LockedFields: []string{"config"}},
+			RedisSpec{Config: map[string]string{"maxmemory": "1gb", "appendonly": "yes"}}, "",
+			map[string]string{"maxmemory": "1gb"}, false, []string{"config.appendonly"}},
+		{"locked exporter", RedisClassSpec{Exporter: ContainerSpec{Image: "exporter"}, LockedFields: []string{"exporter"}},
+			RedisSpec{Metrics: &Metrics{Container: corev1.Container{Image: "metrics"}}}, "", nil, false,
+			[]string{"exporter"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: tt.spec}
+			conflicts := r.ApplyClass(&tt.class)
+			if r.Spec.Redis.Image != tt.wantImage {
+				t.Errorf("ApplyClass() image = %s, want %s", r.Spec.Redis.Image, tt.wantImage)
+			}
+			if !reflect.DeepEqual(r.Spec.Config, tt.wantConfig) {
+				t.Errorf("ApplyClass() config = %v, want %v", r.Spec.Config, tt.wantConfig)
+			}
+			if (r.Spec.Metrics != nil) != tt.wantMetrics {
+				t.Errorf("ApplyClass() metrics = %v, want %v", r.Spec.Metrics, tt.wantMetrics)
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("ApplyClass() conflicts = %v, want %v", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestRedis_ApplyClassDefaultedExporter(t *testing.T) {
+	class := &RedisClassSpec{Exporter: ContainerSpec{Image: "exporter"}, LockedFields: []string{"exporter"}}
+	r := &Redis{}
+	r.ApplyClass(class)
+	// the webhook persists the defaulted exporter, so applying the class again must not report a conflict
+	r = &Redis{Spec: RedisSpec{Exporter: r.Spec.Exporter}}
+	if conflicts := r.ApplyClass(class); len(conflicts) > 0 {
+		t.Errorf("ApplyClass() conflicts = %v, want none", conflicts)
+	}
+}
+
+func TestRedisClassSpec_ValidateLockedFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []string
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"valid", []string{"redis.image", "exporter", "config", "config.maxmemory", "service"}, false},
+		{"unknown field", []string{"replicas"}, true},
+		{"unknown container field", []string{"redis.command"}, true},
+		{"empty directive", []string{"config."}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &RedisClassSpec{LockedFields: tt.fields}
+			if err := c.ValidateLockedFields(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLockedFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RedisClass holds the defaults of the Redis resources referring to it by spec.className, e.g. the images,
+// the resources, the configuration, the backup policy and the exposure approved by the platform team.
+// The class is expanded into the Redis by the operator on every reconciliation, hence the changes of the class
+// are rolled out to all the Redis resources referring to it.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.redis.image",description="Redis image"
+// +kubebuilder:printcolumn:name="Profile",type="string",JSONPath=".spec.profile",description="Profile of the Redis resources"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type RedisClass struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata.
+	// More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#metadata
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RedisClassSpec `json:"spec"`
+}
+
+// RedisClassSpec defines the defaults of the Redis resources of the class. The fields have the meaning
+// of the namesake fields of RedisSpec, the fields set in the Redis take precedence unless locked by LockedFields.
+type RedisClassSpec struct {
+	// Redis container defaults: the image, the digest, the resources, the security context and the probes
+	// are set unless set in the spec. The resources are not set along with the spec.profile.
+	// +optional
+	Redis ContainerSpec `json:"redis,omitempty"`
+	// Exporter is the exporter container of the Redis resources omitting both spec.exporter and spec.metrics
+	// +optional
+	Exporter ContainerSpec `json:"exporter,omitempty"`
+	// ExporterProvider is the implementation run by the exporter container unless set in the spec
+	// +kubebuilder:validation:Enum=redis_exporter;telegraf
+	// +optional
+	ExporterProvider ExporterProvider `json:"exporterProvider,omitempty"`
+	// ImagePullSecrets are used unless set in the spec
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Profile is the preset of the resources and the directives unless set in the spec
+	// +kubebuilder:validation:Enum=small;medium;large
+	// +optional
+	Profile Profile `json:"profile,omitempty"`
+	// Config directives are set unless present in spec.config
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+	// Backup is the backup configuration unless set in the spec
+	// +optional
+	Backup *Backup `json:"backup,omitempty"`
+	// Service is the master Service configuration unless set in the spec
+	// +optional
+	Service *Service `json:"service,omitempty"`
+	// ReplicaService is the replica Service configuration unless set in the spec
+	// +optional
+	ReplicaService *ReplicaService `json:"replicaService,omitempty"`
+	// ExternalAccess is the per-instance Services configuration unless set in the spec
+	// +optional
+	ExternalAccess *ExternalAccess `json:"externalAccess,omitempty"`
+	// LockedFields are the fields always set to the ones of the class, the Redis resources setting them
+	// to other values are reported with the ClassConflict condition. The fields are named as in the class,
+	// e.g. redis.image, redis.resources or backup; redis locks all the fields of the container,
+	// config locks all the directives of the class and config.<directive> locks a single directive.
+	// +optional
+	LockedFields []string `json:"lockedFields,omitempty"`
+}
+
+// RedisClassList is a list of RedisClass resources
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type RedisClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata. More info:
+	// https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	// +k8s:openapi-gen=false
+	metav1.ListMeta `json:"metadata,omitempty"`
+	// List of RedisClass resources
+	Items []RedisClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RedisClass{}, &RedisClassList{})
+}
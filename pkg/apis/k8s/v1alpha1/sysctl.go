@@ -0,0 +1,86 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// somaxconnSysctl caps the backlog of the listening sockets, Redis caps tcp-backlog to it
+const somaxconnSysctl = "net.core.somaxconn"
+
+var (
+	// sysctlNameRegexp matches the sysctl names separated by dots or slashes, e.g. net.core.somaxconn
+	sysctlNameRegexp = regexp.MustCompile(`^[a-z0-9]([-_a-z0-9]*[a-z0-9])?([./][a-z0-9]([-_a-z0-9]*[a-z0-9])?)*$`)
+	// namespacedSysctlPrefixes are the prefixes of the sysctls isolated by the Pod namespaces,
+	// the rest are node-level and can not be set per Pod
+	namespacedSysctlPrefixes = []string{"kernel.shm", "kernel.msg", "kernel.sem", "fs.mqueue.", "net."}
+)
+
+// validateSysctls checks the sysctls of spec.securityContext: the names must be valid and namespaced,
+// e.g. vm.overcommit_memory is node-level and is rejected, and net.core.somaxconn must be a positive
+// number not lower than tcp-backlog of spec.config, since Redis silently caps the backlog to it.
+func (r *Redis) validateSysctls() error {
+	if r.Spec.SecurityContext == nil {
+		return nil
+	}
+	names := make(map[string]bool, len(r.Spec.SecurityContext.Sysctls))
+	for i, sysctl := range r.Spec.SecurityContext.Sysctls {
+		path := fmt.Sprintf("invalid sysctls: spec.securityContext.sysctls[%d]", i)
+		name := strings.Replace(sysctl.Name, "/", ".", -1)
+		if !sysctlNameRegexp.MatchString(sysctl.Name) {
+			return fmt.Errorf("%s.name: %q is not a sysctl name", path, sysctl.Name)
+		}
+		if names[name] {
+			return fmt.Errorf("%s.name: %q is duplicated", path, sysctl.Name)
+		}
+		names[name] = true
+		if !namespacedSysctl(name) {
+			return fmt.Errorf("%s.name: %s is a node-level sysctl that can not be set per Pod, "+
+				"set it on the nodes instead, spec.hostPreflight reports the settings degrading Redis", path, sysctl.Name)
+		}
+		if name != somaxconnSysctl {
+			continue
+		}
+
+		somaxconn, err := strconv.Atoi(sysctl.Value)
+		if err != nil || somaxconn <= 0 {
+			return fmt.Errorf("%s.value: %s must be a positive number, got %q", path, sysctl.Name, sysctl.Value)
+		}
+		for directive, value := range r.Spec.Config {
+			if !strings.EqualFold(directive, "tcp-backlog") {
+				continue
+			}
+			if backlog, err := strconv.Atoi(value); err == nil && backlog > somaxconn {
+				return fmt.Errorf("%s.value: %s %d is lower than tcp-backlog %d of spec.config, "+
+					"Redis would cap the backlog to it", path, sysctl.Name, somaxconn, backlog)
+			}
+		}
+	}
+	return nil
+}
+
+// namespacedSysctl reports whether the sysctl is isolated by the Pod namespaces
+func namespacedSysctl(name string) bool {
+	for _, prefix := range namespacedSysctlPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRedis_validateSysctls(t *testing.T) {
+	tests := []struct {
+		name    string
+		sysctls []corev1.Sysctl
+		config  map[string]string
+		wantErr bool
+	}{
+		{"none", nil, nil, false},
+		{"somaxconn", []corev1.Sysctl{{Name: "net.core.somaxconn", Value: "1024"}}, map[string]string{"tcp-backlog": "511"}, false},
+		{"slashes", []corev1.Sysctl{{Name: "net/core/somaxconn", Value: "1024"}}, nil, false},
+		{"kernel.shm", []corev1.Sysctl{{Name: "kernel.shm_rmid_forced", Value: "1"}}, nil, false},
+		{"node-level", []corev1.Sysctl{{Name: "vm.overcommit_memory", Value: "1"}}, nil, true},
+		{"invalid name", []corev1.Sysctl{{Name: "net..core", Value: "1"}}, nil, true},
+		{"duplicated", []corev1.Sysctl{{Name: "net.core.somaxconn", Value: "1024"}, {Name: "net/core/somaxconn", Value: "1024"}}, nil, true},
+		{"somaxconn not a number", []corev1.Sysctl{{Name: "net.core.somaxconn", Value: "max"}}, nil, true},
+		{"somaxconn below backlog", []corev1.Sysctl{{Name: "net.core.somaxconn", Value: "128"}}, map[string]string{"TCP-BACKLOG": "511"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redis{Spec: RedisSpec{Config: tt.config, SecurityContext: &corev1.PodSecurityContext{Sysctls: tt.sysctls}}}
+			if err := r.validateSysctls(); (err != nil) != tt.wantErr {
+				t.Errorf("validateSysctls() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
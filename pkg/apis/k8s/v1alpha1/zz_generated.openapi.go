@@ -47,6 +47,13 @@ func schema_pkg_apis_k8s_v1alpha1_ContainerSpec(ref common.ReferenceCallback) co
 							Format:      "",
 						},
 					},
+					"imageDigest": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ImageDigest pins the image to the manifest digest, e.g. sha256:0123... The container is run with Image@ImageDigest, any digest in Image is replaced.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 					"resources": {
 						SchemaProps: spec.SchemaProps{
 							Description: "Resources describes the compute resource requirements",
@@ -79,7 +86,7 @@ func schema_pkg_apis_k8s_v1alpha1_Password(ref common.ReferenceCallback) common.
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "Password allows to refer to a Secret containing password for Redis Password should be strong enough. Passwords shorter than 8 characters composed of ASCII alphanumeric symbols will lead to a mild warning logged by the Operator. Please note that password hashes are added as annotations to Pods to enable password rotation. Hashes are generated using argon2id KDF. Changing the password in the referenced Secret will not trigger the rolling Statefulset upgrade automatically. However an event in regard to any objects owned by the Redis resource fired afterwards will trigger the rolling upgrade. Redis operator does not store the password internally and reads it from the Secret any time the Reconcile is called. Hence it will not be able to connect to Pods with the ``old'' password. In scenarios when persistence is turned off all the data will be lost during password rotation.",
+				Description: "Password allows to refer to a Secret containing password for Redis Password should be strong enough. Passwords shorter than 8 characters composed of ASCII alphanumeric symbols will lead to a mild warning logged by the Operator. The changed password is applied to the running instances with CONFIG SET, the replicas first, and to the generated authentication Secret afterwards, so the Pods are not restarted. The referenced Secret is watched for the changes.",
 				Properties: map[string]spec.Schema{
 					"secretKeyRef": {
 						SchemaProps: spec.SchemaProps{
@@ -87,6 +94,19 @@ func schema_pkg_apis_k8s_v1alpha1_Password(ref common.ReferenceCallback) common.
 							Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
 						},
 					},
+					"previousSecretKeyRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PreviousSecretKeyRef is a reference to the previous password accepted along with the password of SecretKeyRef during the rotation, so the clients authenticate with either of them until they are switched to the new one. The default user, or the redis-operator user once the default user is disabled, is defined by both passwords and the replicas authenticate with the new one. It is removed once the rotation is over. Requires Redis 6.2+.",
+							Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+						},
+					},
+					"secretProviderClass": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SecretProviderClass is the name of the SecretProviderClass of the Secrets Store CSI Driver, e.g. of the Vault provider, mounted into the Redis Pods. The driver syncs the password to the Secret of SecretKeyRef declared in the secretObjects of the class and keeps it updated once the secret is rotated in the store.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 				},
 				Required: []string{"secretKeyRef"},
 			},
@@ -342,14 +362,14 @@ func schema_pkg_apis_k8s_v1alpha1_RedisStatus(ref common.ReferenceCallback) comm
 				Properties: map[string]spec.Schema{
 					"replicas": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Replicas is the number of active Redis instances in the replication",
+							Description: "Replicas is the number of active Redis instances in the replication. Kept for the scale subresource, the Degraded condition tells why instances are missing",
 							Type:        []string{"integer"},
 							Format:      "int32",
 						},
 					},
 					"master": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Master is the current master's Pod name",
+							Description: "Master is the current master's Pod name. Kept for the failover, the MasterElected condition tells why the master is missing",
 							Type:        []string{"string"},
 							Format:      "",
 						},
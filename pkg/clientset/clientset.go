@@ -0,0 +1,106 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clientset is the typed client of the k8s.amaiz.com API group built on top of the controller-runtime client.
+// It allows to build the tooling against the Redis and RedisBackup resources without handling the scheme
+// and the untyped objects, e.g.
+//
+//	clients, err := clientset.New(config)
+//	redis, err := clients.Redises("default").Get(ctx, "example")
+package clientset
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+// Interface is the typed client of the k8s.amaiz.com API group
+type Interface interface {
+	// Redises returns the client of the Redis resources of the namespace
+	Redises(namespace string) RedisInterface
+	// RedisBackups returns the client of the RedisBackup resources of the namespace
+	RedisBackups(namespace string) RedisBackupInterface
+}
+
+// Clientset implements Interface
+type Clientset struct {
+	client client.Client
+}
+
+// NewScheme returns the scheme with the k8s.amaiz.com types registered
+func NewScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := k8sv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("could not register the %s types: %s", k8sv1alpha1.SchemeGroupVersion, err)
+	}
+	return scheme, nil
+}
+
+// New returns the Clientset of the cluster the config points to
+func New(config *rest.Config) (*Clientset, error) {
+	scheme, err := NewScheme()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("could not create the client: %s", err)
+	}
+	return NewForClient(c), nil
+}
+
+// NewForClient returns the Clientset wrapping the existing client, e.g. the one of the manager.
+// The scheme of the client must have the k8s.amaiz.com types registered.
+func NewForClient(c client.Client) *Clientset {
+	return &Clientset{client: c}
+}
+
+// Redises returns the client of the Redis resources of the namespace
+func (c *Clientset) Redises(namespace string) RedisInterface {
+	return &redises{client: c.client, namespace: namespace}
+}
+
+// RedisBackups returns the client of the RedisBackup resources of the namespace
+func (c *Clientset) RedisBackups(namespace string) RedisBackupInterface {
+	return &redisBackups{client: c.client, namespace: namespace}
+}
+
+// inNamespace sets the namespace of the object being created or updated if it is empty
+// and refuses the objects of the other namespaces
+func inNamespace(object interface {
+	GetNamespace() string
+	SetNamespace(string)
+}, namespace string) error {
+	switch object.GetNamespace() {
+	case namespace:
+	case "":
+		object.SetNamespace(namespace)
+	default:
+		return fmt.Errorf("the namespace %q does not match the client namespace %q", object.GetNamespace(), namespace)
+	}
+	return nil
+}
+
+// strict implementation check
+var (
+	_ Interface = (*Clientset)(nil)
+
+	_ RedisInterface       = (*redises)(nil)
+	_ RedisBackupInterface = (*redisBackups)(nil)
+)
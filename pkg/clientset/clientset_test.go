@@ -0,0 +1,130 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientset
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+// recorder records the objects and the options passed to the client,
+// the methods not overridden panic on the nil embedded client
+type recorder struct {
+	client.Client
+	key         client.ObjectKey
+	listOptions client.ListOptions
+	created     runtime.Object
+	deleted     runtime.Object
+}
+
+func (r *recorder) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	r.key = key
+	obj.(*k8sv1alpha1.Redis).Name = key.Name
+	return nil
+}
+
+func (r *recorder) List(_ context.Context, _ runtime.Object, opts ...client.ListOption) error {
+	r.listOptions.ApplyOptions(opts)
+	return nil
+}
+
+func (r *recorder) Create(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+	r.created = obj
+	return nil
+}
+
+func (r *recorder) Delete(_ context.Context, obj runtime.Object, _ ...client.DeleteOption) error {
+	r.deleted = obj
+	return nil
+}
+
+func TestNewScheme(t *testing.T) {
+	scheme, err := NewScheme()
+	if err != nil {
+		t.Fatalf("NewScheme() error = %v", err)
+	}
+	for _, kind := range []string{"Redis", "RedisList", "RedisBackup", "RedisBackupList"} {
+		if !scheme.Recognizes(k8sv1alpha1.SchemeGroupVersion.WithKind(kind)) {
+			t.Errorf("NewScheme() does not recognize %s", kind)
+		}
+	}
+}
+
+func TestRedises(t *testing.T) {
+	ctx := context.TODO()
+	stub := new(recorder)
+	redises := NewForClient(stub).Redises("default")
+
+	redis, err := redises.Get(ctx, "example")
+	if err != nil || redis.Name != "example" {
+		t.Errorf("Get() = %v, %v, want example", redis, err)
+	}
+	if want := (client.ObjectKey{Namespace: "default", Name: "example"}); stub.key != want {
+		t.Errorf("Get() key = %v, want %v", stub.key, want)
+	}
+
+	if _, err := redises.List(ctx, client.MatchingLabels{"app": "redis"}); err != nil {
+		t.Errorf("List() error = %v", err)
+	}
+	if stub.listOptions.Namespace != "default" || stub.listOptions.LabelSelector.String() != "app=redis" {
+		t.Errorf("List() options = %+v, want the default namespace and app=redis", stub.listOptions)
+	}
+
+	if err := redises.Delete(ctx, "example"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if deleted := stub.deleted.(*k8sv1alpha1.Redis); deleted.Namespace != "default" || deleted.Name != "example" {
+		t.Errorf("Delete() = %s/%s, want default/example", deleted.Namespace, deleted.Name)
+	}
+}
+
+func TestRedises_Create(t *testing.T) {
+	tests := []struct {
+		name          string
+		namespace     string
+		wantNamespace string
+		wantErr       bool
+	}{
+		{"namespace is defaulted", "", "default", false},
+		{"same namespace", "default", "default", false},
+		{"other namespace", "other", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := new(recorder)
+			redis := &k8sv1alpha1.Redis{}
+			redis.Name, redis.Namespace = "example", tt.namespace
+
+			err := NewForClient(stub).Redises("default").Create(context.TODO(), redis)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if stub.created != nil {
+					t.Error("Create() has reached the client")
+				}
+				return
+			}
+			if got := stub.created.(*k8sv1alpha1.Redis).Namespace; got != tt.wantNamespace {
+				t.Errorf("Create() namespace = %q, want %q", got, tt.wantNamespace)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientset
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+// RedisInterface manages the Redis resources of a namespace
+type RedisInterface interface {
+	Get(ctx context.Context, name string) (*k8sv1alpha1.Redis, error)
+	List(ctx context.Context, opts ...client.ListOption) (*k8sv1alpha1.RedisList, error)
+	Create(ctx context.Context, redis *k8sv1alpha1.Redis, opts ...client.CreateOption) error
+	Update(ctx context.Context, redis *k8sv1alpha1.Redis, opts ...client.UpdateOption) error
+	// UpdateStatus updates the status subresource, the changes of the spec are ignored
+	UpdateStatus(ctx context.Context, redis *k8sv1alpha1.Redis, opts ...client.UpdateOption) error
+	Patch(ctx context.Context, redis *k8sv1alpha1.Redis, patch client.Patch, opts ...client.PatchOption) error
+	Delete(ctx context.Context, name string, opts ...client.DeleteOption) error
+}
+
+// redises implements RedisInterface
+type redises struct {
+	client    client.Client
+	namespace string
+}
+
+func (r *redises) Get(ctx context.Context, name string) (*k8sv1alpha1.Redis, error) {
+	redis := new(k8sv1alpha1.Redis)
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: r.namespace, Name: name}, redis); err != nil {
+		return nil, err
+	}
+	return redis, nil
+}
+
+func (r *redises) List(ctx context.Context, opts ...client.ListOption) (*k8sv1alpha1.RedisList, error) {
+	list := new(k8sv1alpha1.RedisList)
+	if err := r.client.List(ctx, list, append(opts, client.InNamespace(r.namespace))...); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *redises) Create(ctx context.Context, redis *k8sv1alpha1.Redis, opts ...client.CreateOption) error {
+	if err := inNamespace(redis, r.namespace); err != nil {
+		return err
+	}
+	return r.client.Create(ctx, redis, opts...)
+}
+
+func (r *redises) Update(ctx context.Context, redis *k8sv1alpha1.Redis, opts ...client.UpdateOption) error {
+	if err := inNamespace(redis, r.namespace); err != nil {
+		return err
+	}
+	return r.client.Update(ctx, redis, opts...)
+}
+
+func (r *redises) UpdateStatus(ctx context.Context, redis *k8sv1alpha1.Redis, opts ...client.UpdateOption) error {
+	if err := inNamespace(redis, r.namespace); err != nil {
+		return err
+	}
+	return r.client.Status().Update(ctx, redis, opts...)
+}
+
+func (r *redises) Patch(ctx context.Context, redis *k8sv1alpha1.Redis, patch client.Patch, opts ...client.PatchOption) error {
+	if err := inNamespace(redis, r.namespace); err != nil {
+		return err
+	}
+	return r.client.Patch(ctx, redis, patch, opts...)
+}
+
+func (r *redises) Delete(ctx context.Context, name string, opts ...client.DeleteOption) error {
+	redis := &k8sv1alpha1.Redis{}
+	redis.Name, redis.Namespace = name, r.namespace
+	return r.client.Delete(ctx, redis, opts...)
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The backup-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientset
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+// RedisBackupInterface manages the RedisBackup resources of a namespace
+type RedisBackupInterface interface {
+	Get(ctx context.Context, name string) (*k8sv1alpha1.RedisBackup, error)
+	List(ctx context.Context, opts ...client.ListOption) (*k8sv1alpha1.RedisBackupList, error)
+	Create(ctx context.Context, backup *k8sv1alpha1.RedisBackup, opts ...client.CreateOption) error
+	Update(ctx context.Context, backup *k8sv1alpha1.RedisBackup, opts ...client.UpdateOption) error
+	// UpdateStatus updates the status subresource, the changes of the spec are ignored
+	UpdateStatus(ctx context.Context, backup *k8sv1alpha1.RedisBackup, opts ...client.UpdateOption) error
+	Patch(ctx context.Context, backup *k8sv1alpha1.RedisBackup, patch client.Patch, opts ...client.PatchOption) error
+	Delete(ctx context.Context, name string, opts ...client.DeleteOption) error
+}
+
+// redisBackups implements RedisBackupInterface
+type redisBackups struct {
+	client    client.Client
+	namespace string
+}
+
+func (b *redisBackups) Get(ctx context.Context, name string) (*k8sv1alpha1.RedisBackup, error) {
+	backup := new(k8sv1alpha1.RedisBackup)
+	if err := b.client.Get(ctx, types.NamespacedName{Namespace: b.namespace, Name: name}, backup); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+func (b *redisBackups) List(ctx context.Context, opts ...client.ListOption) (*k8sv1alpha1.RedisBackupList, error) {
+	list := new(k8sv1alpha1.RedisBackupList)
+	if err := b.client.List(ctx, list, append(opts, client.InNamespace(b.namespace))...); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (b *redisBackups) Create(ctx context.Context, backup *k8sv1alpha1.RedisBackup, opts ...client.CreateOption) error {
+	if err := inNamespace(backup, b.namespace); err != nil {
+		return err
+	}
+	return b.client.Create(ctx, backup, opts...)
+}
+
+func (b *redisBackups) Update(ctx context.Context, backup *k8sv1alpha1.RedisBackup, opts ...client.UpdateOption) error {
+	if err := inNamespace(backup, b.namespace); err != nil {
+		return err
+	}
+	return b.client.Update(ctx, backup, opts...)
+}
+
+func (b *redisBackups) UpdateStatus(ctx context.Context, backup *k8sv1alpha1.RedisBackup, opts ...client.UpdateOption) error {
+	if err := inNamespace(backup, b.namespace); err != nil {
+		return err
+	}
+	return b.client.Status().Update(ctx, backup, opts...)
+}
+
+func (b *redisBackups) Patch(ctx context.Context, backup *k8sv1alpha1.RedisBackup, patch client.Patch, opts ...client.PatchOption) error {
+	if err := inNamespace(backup, b.namespace); err != nil {
+		return err
+	}
+	return b.client.Patch(ctx, backup, patch, opts...)
+}
+
+func (b *redisBackups) Delete(ctx context.Context, name string, opts ...client.DeleteOption) error {
+	backup := &k8sv1alpha1.RedisBackup{}
+	backup.Name, backup.Namespace = name, b.namespace
+	return b.client.Delete(ctx, backup, opts...)
+}
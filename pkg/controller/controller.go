@@ -15,6 +15,8 @@
 package controller
 
 import (
+	"github.com/amaizfinance/redis-operator/pkg/controller/redis"
+
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
@@ -30,3 +32,17 @@ func AddToManager(m manager.Manager) error {
 	}
 	return nil
 }
+
+// SetupAllWithManager adds all Controllers configured with the options to the Manager.
+// It allows embedding the controllers into other operators instead of configuring them with the flags.
+// The scheme of the Manager must include the k8s.amaiz.com API, see apis.AddToScheme.
+func SetupAllWithManager(m manager.Manager, options redis.Options) error {
+	reconciler, err := redis.NewRedisReconciler(m, options)
+	if err != nil {
+		return err
+	}
+	if err := reconciler.SetupWithManager(m); err != nil {
+		return err
+	}
+	return redis.NewRedisBackupReconciler(m, options).SetupWithManager(m)
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// aclUserNames returns the sorted names of the declared ACL users recorded in the status, the operator user is
+// managed along with the default user and is left out
+func aclUserNames(users []redis.User) []string {
+	var names []string
+	for _, user := range users {
+		if user.Name != redis.OperatorUser {
+			names = append(names, user.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// removedACLUsers returns the names of the ACL users applied previously, see aclUserNames,
+// that are no longer declared and have to be deleted from the running instances
+func removedACLUsers(applied []string, users []redis.User) []string {
+	declared := make(map[string]bool, len(users))
+	for _, user := range users {
+		declared[user.Name] = true
+	}
+	var removed []string
+	for _, name := range applied {
+		if !declared[name] {
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
+
+// configuredACLUsers returns the sorted names of the ACL users defined by the authentication configuration
+// of the Secret, see aclUserNames. The default user and the operator user are left out.
+func configuredACLUsers(secret *corev1.Secret) []string {
+	var names []string
+	for _, key := range []string{secretFileName, aclFileName} {
+		for _, line := range strings.Split(string(secret.Data[key]), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || fields[0] != "user" || fields[1] == redis.DefaultUser || fields[1] == redis.OperatorUser {
+				continue
+			}
+			names = append(names, fields[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// appliedACLUsers returns the names of the ACL users applied previously. These are recorded in the status, the users
+// applied before, e.g. by the releases not recording them, are read from the Secret of the last applied configuration.
+func (reconciler *ReconcileRedis) appliedACLUsers(ctx context.Context, r *k8sv1alpha1.Redis) ([]string, error) {
+	if len(r.Status.ACLUsers) > 0 {
+		return r.Status.ACLUsers, nil
+	}
+	secret := new(corev1.Secret)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateName(r)}, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch Secret: %s", err)
+	}
+	return configuredACLUsers(secret), nil
+}
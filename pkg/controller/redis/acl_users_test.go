@@ -0,0 +1,73 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_aclUserNames(t *testing.T) {
+	users := []redis.User{{Name: "reports"}, redis.NewOperatorUser("secret"), {Name: "app"}}
+	if got, want := aclUserNames(users), []string{"app", "reports"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("aclUserNames() = %v, want %v", got, want)
+	}
+}
+
+func Test_removedACLUsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied []string
+		users   []redis.User
+		want    []string
+	}{
+		{"nothing applied", nil, []redis.User{{Name: "app"}}, nil},
+		{"unchanged", []string{"app"}, []redis.User{{Name: "app"}}, nil},
+		{"removed", []string{"app", "reports"}, []redis.User{{Name: "app"}}, []string{"reports"}},
+		{"all removed", []string{"app", "reports"}, nil, []string{"app", "reports"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removedACLUsers(tt.applied, tt.users); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("removedACLUsers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_configuredACLUsers(t *testing.T) {
+	replicas := int32(1)
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: k8sv1alpha1.RedisSpec{
+			Replicas: &replicas,
+			ACL:      &k8sv1alpha1.ACL{DisableDefaultUser: true},
+		},
+	}
+	users := []redis.User{{Name: "reports", Rules: []string{"on", "~*", "+@read"}}, redis.NewOperatorUser("secret"), {Name: "app"}}
+	secret := generateSecret(r, objectGeneratorOptions{password: "secret", aclUsers: users, defaultUserDisabled: true})
+	if got, want := configuredACLUsers(secret), []string{"app", "reports"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("configuredACLUsers() = %v, want %v", got, want)
+	}
+	if got := configuredACLUsers(new(corev1.Secret)); got != nil {
+		t.Errorf("configuredACLUsers() = %v, want none", got)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"strconv"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// hostnameAddressing reports whether the instances address each other by the DNS names of the Pods
+func hostnameAddressing(r *k8sv1alpha1.Redis) bool {
+	return r.Spec.Addressing == k8sv1alpha1.AddressingHostname
+}
+
+// podHostname returns the DNS name of the StatefulSet Pod of the Redis under the headless Service.
+// The StatefulSet sets the hostname of the Pods to their names.
+func podHostname(r *k8sv1alpha1.Redis, pod, clusterDomain string) string {
+	return fmt.Sprintf("%s.%s.%s.svc.%s", pod, generateHeadlessServiceName(r), r.GetNamespace(), clusterDomain)
+}
+
+// hostnames returns the addresses the instances are replicated from by the Pod addresses,
+// nil unless the instances are addressed by hostname. The Pods without assigned IPs are omitted.
+func hostnames(r *k8sv1alpha1.Redis, pods []corev1.Pod, clusterDomain string) map[redis.Address]redis.Address {
+	if !hostnameAddressing(r) {
+		return nil
+	}
+	port := strconv.Itoa(redisPort(r))
+	addresses := make(map[redis.Address]redis.Address, len(pods))
+	for i := range pods {
+		if pods[i].Status.PodIP != "" {
+			addresses[redis.Address{Host: pods[i].Status.PodIP, Port: port}] =
+				redis.Address{Host: podHostname(r, pods[i].Name, clusterDomain), Port: port}
+		}
+	}
+	return addresses
+}
+
+// withPeerHostname returns the hostnames along with the DNS name of the Pod of the blue/green peer at the address,
+// so the instances replicate from the master of the peer by its DNS name as well
+func withPeerHostname(
+	r, peer *k8sv1alpha1.Redis,
+	hostnames map[redis.Address]redis.Address,
+	pod string,
+	address redis.Address,
+	clusterDomain string,
+) map[redis.Address]redis.Address {
+	if !hostnameAddressing(r) || pod == "" || address == (redis.Address{}) {
+		return hostnames
+	}
+	peerHostnames := make(map[redis.Address]redis.Address, len(hostnames)+1)
+	for k, v := range hostnames {
+		peerHostnames[k] = v
+	}
+	peerHostnames[address] = redis.Address{Host: podHostname(peer, pod, clusterDomain), Port: address.Port}
+	return peerHostnames
+}
+
+// replicationTarget returns the address the instances replicate from the master at, see redis.Options.Hostnames
+func replicationTarget(hostnames map[redis.Address]redis.Address, master redis.Address) redis.Address {
+	if hostname, ok := hostnames[master]; ok {
+		return hostname
+	}
+	return master
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+)
+
+func Test_hostnames(t *testing.T) {
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "redis-example-0"}, Status: corev1.PodStatus{PodIP: "10.0.0.1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "redis-example-1"}},
+	}
+	tests := []struct {
+		name       string
+		addressing k8sv1alpha1.Addressing
+		want       map[redis.Address]redis.Address
+	}{
+		{"default", "", nil},
+		{"IP", k8sv1alpha1.AddressingIP, nil},
+		{
+			"Hostname",
+			k8sv1alpha1.AddressingHostname,
+			map[redis.Address]redis.Address{
+				{Host: "10.0.0.1", Port: "6379"}: {Host: "redis-example-0.redis-example-headless.default.svc.cluster.local", Port: "6379"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{
+				ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+				Spec:       k8sv1alpha1.RedisSpec{Addressing: tt.addressing},
+			}
+			if got := hostnames(r, pods, defaultClusterDomain); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hostnames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_withPeerHostname(t *testing.T) {
+	green := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "green", Namespace: "default"},
+		Spec:       k8sv1alpha1.RedisSpec{Addressing: k8sv1alpha1.AddressingHostname},
+	}
+	blue := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "blue", Namespace: "default"}}
+	own := redis.Address{Host: "10.0.0.1", Port: "6379"}
+	hostnames := map[redis.Address]redis.Address{
+		own: {Host: "redis-green-0.redis-green-headless.default.svc.cluster.local", Port: "6379"},
+	}
+	blueMaster := redis.Address{Host: "10.0.1.1", Port: "6379"}
+
+	got := withPeerHostname(green, blue, hostnames, "redis-blue-1", blueMaster, defaultClusterDomain)
+	want := redis.Address{Host: "redis-blue-1.redis-blue-headless.default.svc.cluster.local", Port: "6379"}
+	if target := replicationTarget(got, blueMaster); target != want {
+		t.Errorf("replicationTarget() = %v, want %v", target, want)
+	}
+	if got[own] != hostnames[own] {
+		t.Errorf("withPeerHostname() lost the hostname of %v", own)
+	}
+	if _, ok := hostnames[blueMaster]; ok {
+		t.Error("withPeerHostname() modified the hostnames")
+	}
+
+	// the Redis addressing the instances by IP replicates from the peer by IP
+	green.Spec.Addressing = k8sv1alpha1.AddressingIP
+	if target := replicationTarget(withPeerHostname(green, blue, nil, "redis-blue-1", blueMaster, defaultClusterDomain), blueMaster); target != blueMaster {
+		t.Errorf("replicationTarget() = %v, want %v", target, blueMaster)
+	}
+}
+
+func Test_generateService_publishNotReadyAddresses(t *testing.T) {
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		Spec:       k8sv1alpha1.RedisSpec{Addressing: k8sv1alpha1.AddressingHostname},
+	}
+	for serviceType, want := range map[int]bool{serviceTypeHeadless: true, serviceTypeMaster: false, serviceTypeAll: false} {
+		if got := generateService(r, serviceType).Spec.PublishNotReadyAddresses; got != want {
+			t.Errorf("generateService(%d) PublishNotReadyAddresses = %v, want %v", serviceType, got, want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// adoptable reports whether the existing object of the generated name is to be adopted by the Redis:
+// it has no controller, e.g. restored from the backup of the manifests or created ahead of the Redis.
+// The objects controlled by another owner are not adopted, the Redis can not be reconciled then.
+func adoptable(object metav1.Object, r *k8sv1alpha1.Redis) (bool, error) {
+	owner := metav1.GetControllerOf(object)
+	switch {
+	case owner == nil:
+		return true, nil
+	case owner.UID == r.GetUID():
+		return false, nil
+	}
+	return false, fmt.Errorf("%s is controlled by %s %s", object.GetName(), owner.Kind, owner.Name)
+}
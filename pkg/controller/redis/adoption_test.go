@@ -0,0 +1,58 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_adoptable(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example", UID: types.UID("redis")}}
+	controller := true
+	ownerReference := func(kind, name string, uid types.UID, controller *bool) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{Kind: kind, Name: name, UID: uid, Controller: controller}}
+	}
+
+	tests := []struct {
+		name    string
+		owners  []metav1.OwnerReference
+		want    bool
+		wantErr bool
+	}{
+		{"orphan", nil, true, false},
+		{"not controlled", ownerReference("Redis", "other", "other", nil), true, false},
+		{"controlled", ownerReference("Redis", "example", "redis", &controller), false, false},
+		{"controlled by another Redis", ownerReference("Redis", "example", "previous", &controller), false, true},
+		{"controlled by another kind", ownerReference("Deployment", "app", "app", &controller), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "redis-example", OwnerReferences: tt.owners}}
+			got, err := adoptable(object, r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("adoptable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("adoptable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// aofFsyncCheckInterval is the minimum period the growth of the aof_delayed_fsync counters is measured over
+const aofFsyncCheckInterval = 5 * time.Minute
+
+// aofFsyncSample holds the aof_delayed_fsync counters by Pod at the time they were sampled
+type aofFsyncSample struct {
+	counters map[string]int
+	at       time.Time
+}
+
+// aofFsyncTracker keeps the latest samples of the aof_delayed_fsync counters by Redis, so the counters
+// are compared over aofFsyncCheckInterval however often the Redis is reconciled. It is safe for concurrent use.
+type aofFsyncTracker struct {
+	mu      sync.Mutex
+	samples map[types.NamespacedName]aofFsyncSample
+}
+
+func newAOFFsyncTracker() *aofFsyncTracker {
+	return &aofFsyncTracker{samples: make(map[types.NamespacedName]aofFsyncSample)}
+}
+
+// growth records the counters and returns their growth by Pod since the previous sample.
+// checked is false if the counters have not been sampled before or aofFsyncCheckInterval has not passed yet.
+// The counters reset by the restarts are not compared.
+func (t *aofFsyncTracker) growth(key types.NamespacedName, counters map[string]int, now time.Time) (map[string]int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous, ok := t.samples[key]
+	if ok && now.Sub(previous.at) < aofFsyncCheckInterval {
+		return nil, false
+	}
+	t.samples[key] = aofFsyncSample{counters: counters, at: now}
+	if !ok {
+		return nil, false
+	}
+
+	growth := make(map[string]int)
+	for pod, counter := range counters {
+		if before, ok := previous.counters[pod]; ok && counter > before {
+			growth[pod] = counter - before
+		}
+	}
+	return growth, true
+}
+
+// forget drops the samples of the deleted Redis
+func (t *aofFsyncTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.samples, key)
+}
+
+// aofFsyncCondition builds the AOFFsyncDelayed condition out of the growth of the aof_delayed_fsync counters by Pod
+func aofFsyncCondition(growth map[string]int) k8sv1alpha1.Condition {
+	if len(growth) == 0 {
+		return k8sv1alpha1.Condition{
+			Type:    k8sv1alpha1.ConditionAOFFsyncDelayed,
+			Status:  corev1.ConditionFalse,
+			Reason:  k8sv1alpha1.ReasonAOFFsyncOK,
+			Message: "no AOF writes have been delayed by fsync since the previous check",
+		}
+	}
+
+	delayed := make([]string, 0, len(growth))
+	for pod, count := range growth {
+		delayed = append(delayed, fmt.Sprintf("%s: %d", pod, count))
+	}
+	sort.Strings(delayed)
+	return k8sv1alpha1.Condition{
+		Type:   k8sv1alpha1.ConditionAOFFsyncDelayed,
+		Status: corev1.ConditionTrue,
+		Reason: k8sv1alpha1.ReasonAOFFsyncDelayed,
+		Message: fmt.Sprintf("AOF writes delayed by fsync since the previous check: %s. The storage does not keep up "+
+			"with appendfsync everysec: use a faster StorageClass, or set appendfsync no leaving the fsync to the kernel "+
+			"at the risk of losing up to 30 seconds of writes on a crash instead of one", strings.Join(delayed, ", ")),
+	}
+}
+
+// checkAOFFsync sets the AOFFsyncDelayed condition once per aofFsyncCheckInterval and emits a Warning Event
+// when the writes start being delayed. The condition is removed if AOF is disabled on all the instances.
+func (reconciler *ReconcileRedis) checkAOFFsync(
+	r *k8sv1alpha1.Redis,
+	status *k8sv1alpha1.RedisStatus,
+	counters map[redis.Address]int,
+	podNames map[string]string,
+) {
+	key := types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()}
+	if len(counters) == 0 {
+		reconciler.aofFsync.forget(key)
+		status.RemoveCondition(k8sv1alpha1.ConditionAOFFsyncDelayed)
+		return
+	}
+
+	byPod := make(map[string]int, len(counters))
+	for address, counter := range counters {
+		name, ok := podNames[address.Host]
+		if !ok {
+			name = address.String()
+		}
+		byPod[name] = counter
+	}
+	growth, checked := reconciler.aofFsync.growth(key, byPod, time.Now())
+	if !checked {
+		return
+	}
+
+	condition := aofFsyncCondition(growth)
+	if previous := status.GetCondition(condition.Type); condition.Status == corev1.ConditionTrue &&
+		(previous == nil || previous.Status != corev1.ConditionTrue) {
+		reconciler.recorder.Event(r, corev1.EventTypeWarning, condition.Reason, condition.Message)
+	}
+	status.SetCondition(condition)
+}
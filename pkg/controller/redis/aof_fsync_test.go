@@ -0,0 +1,74 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_aofFsyncTracker_growth(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "example"}
+	start := time.Now()
+	tracker := newAOFFsyncTracker()
+
+	steps := []struct {
+		name        string
+		counters    map[string]int
+		after       time.Duration
+		wantGrowth  map[string]int
+		wantChecked bool
+	}{
+		{"first sample", map[string]int{"redis-example-0": 1, "redis-example-1": 5}, 0, nil, false},
+		{"throttled", map[string]int{"redis-example-0": 9, "redis-example-1": 5}, time.Minute, nil, false},
+		{"grown", map[string]int{"redis-example-0": 4, "redis-example-1": 5}, aofFsyncCheckInterval, map[string]int{"redis-example-0": 3}, true},
+		{"restarted", map[string]int{"redis-example-0": 0, "redis-example-1": 5, "redis-example-2": 2}, 2 * aofFsyncCheckInterval,
+			map[string]int{}, true},
+	}
+	for _, step := range steps {
+		growth, checked := tracker.growth(key, step.counters, start.Add(step.after))
+		if checked != step.wantChecked || !reflect.DeepEqual(growth, step.wantGrowth) {
+			t.Errorf("%s: growth() = %v, %v, want %v, %v", step.name, growth, checked, step.wantGrowth, step.wantChecked)
+		}
+	}
+
+	tracker.forget(key)
+	if _, checked := tracker.growth(key, map[string]int{"redis-example-0": 7}, start.Add(3*aofFsyncCheckInterval)); checked {
+		t.Errorf("growth() checked the forgotten Redis")
+	}
+}
+
+func Test_aofFsyncCondition(t *testing.T) {
+	if got := aofFsyncCondition(nil); got.Status != corev1.ConditionFalse || got.Reason != k8sv1alpha1.ReasonAOFFsyncOK {
+		t.Errorf("aofFsyncCondition() = %+v, want %s", got, k8sv1alpha1.ReasonAOFFsyncOK)
+	}
+
+	got := aofFsyncCondition(map[string]int{"redis-example-1": 2, "redis-example-0": 7})
+	if got.Status != corev1.ConditionTrue || got.Reason != k8sv1alpha1.ReasonAOFFsyncDelayed {
+		t.Errorf("aofFsyncCondition() = %+v, want %s", got, k8sv1alpha1.ReasonAOFFsyncDelayed)
+	}
+	for _, want := range []string{"redis-example-0: 7, redis-example-1: 2", "appendfsync everysec", "appendfsync no"} {
+		if !strings.Contains(got.Message, want) {
+			t.Errorf("aofFsyncCondition() message = %q, want it to contain %q", got.Message, want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// backupSourceRequeueDelay is the delay before the next attempt to find a ready replica to take the snapshot from
+	backupSourceRequeueDelay = 10 * time.Second
+)
+
+// AddBackup creates a new RedisBackup Controller configured by the flags and adds it to the Manager.
+// The Manager will set fields on the Controller and Start it when the Manager is Started.
+func AddBackup(mgr manager.Manager) error {
+	return NewRedisBackupReconciler(mgr, flagOptions).SetupWithManager(mgr)
+}
+
+// NewRedisBackupReconciler returns the RedisBackup reconciler using the clients of the Manager
+func NewRedisBackupReconciler(mgr manager.Manager, options Options) *ReconcileRedisBackup {
+	return &ReconcileRedisBackup{
+		client:    mgr.GetClient(),
+		apiReader: mgr.GetAPIReader(),
+		scheme:    mgr.GetScheme(),
+		options:   options,
+	}
+}
+
+// SetupWithManager adds a new RedisBackup Controller reconciled by the reconciler to the Manager
+func (reconciler *ReconcileRedisBackup) SetupWithManager(mgr manager.Manager) error {
+	c, err := controller.New("redisbackup-controller", mgr, controller.Options{
+		Reconciler:              reconciler,
+		MaxConcurrentReconciles: reconciler.options.MaxConcurrentReconciles,
+		RateLimiter:             reconciler.options.rateLimiter(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource RedisBackup
+	if err := c.Watch(
+		&source.Kind{Type: new(k8sv1alpha1.RedisBackup)},
+		new(handler.EnqueueRequestForObject),
+	); err != nil {
+		return err
+	}
+
+	// Watch for changes to the backup Jobs
+	return c.Watch(
+		&source.Kind{Type: new(batchv1.Job)},
+		&handler.EnqueueRequestForOwner{OwnerType: new(k8sv1alpha1.RedisBackup), IsController: true},
+	)
+}
+
+// ReconcileRedisBackup reconciles a RedisBackup object
+type ReconcileRedisBackup struct {
+	client client.Client
+	// apiReader reads the RedisClasses the backup configuration may be set by
+	apiReader client.Reader
+	scheme    *runtime.Scheme
+	options   Options
+}
+
+// strict implementation check
+var _ reconcile.Reconciler = (*ReconcileRedisBackup)(nil)
+
+// Reconcile creates the Job taking the snapshot and reflects the Job state in the RedisBackup status.
+// Finished backups are never retried.
+func (reconciler *ReconcileRedisBackup) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	budget := newBudgetedClient(reconciler.client, "redisbackup", reconciler.options.ReconcileAPIBudget)
+	budgeted := *reconciler
+	budgeted.client = budget
+
+	ctx, cancel := reconcileContext(reconciler.options.ReconcileTimeout)
+	defer cancel()
+
+	result, err := budgeted.reconcile(ctx, request)
+	if budget.done() {
+		log.Info("API request budget exceeded, postponing reconciliation",
+			"Namespace", request.Namespace, "RedisBackup", request.Name)
+		return reconcile.Result{RequeueAfter: apiBudgetRequeueDelay}, nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Info("Reconciliation timed out, requeue", "Namespace", request.Namespace, "RedisBackup", request.Name,
+			"timeout", reconciler.options.ReconcileTimeout, "error", err)
+		return reconcile.Result{Requeue: true}, nil
+	}
+	return result, err
+}
+
+func (reconciler *ReconcileRedisBackup) reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	logger := log.WithValues("Namespace", request.Namespace, "RedisBackup", request.Name)
+	logger.V(1).Info("Reconciling RedisBackup")
+
+	backup := new(k8sv1alpha1.RedisBackup)
+	if err := reconciler.client.Get(ctx, request.NamespacedName, backup); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if backup.Status.Phase == k8sv1alpha1.BackupPhaseSucceeded || backup.Status.Phase == k8sv1alpha1.BackupPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+
+	job := new(batchv1.Job)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{
+		Namespace: request.Namespace,
+		Name:      generateBackupJobName(backup),
+	}, job); err != nil {
+		if errors.IsNotFound(err) {
+			return reconciler.startBackup(ctx, backup)
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to fetch Job: %s", err)
+	}
+
+	status := backup.Status.DeepCopy()
+	var message string
+	status.Phase, status.CompletionTime, message = jobPhase(job)
+	switch status.Phase {
+	case k8sv1alpha1.BackupPhaseFailed:
+		status.Message = message
+	case k8sv1alpha1.BackupPhaseSucceeded:
+		podList := new(corev1.PodList)
+		if err := reconciler.client.List(ctx, podList,
+			client.InNamespace(request.Namespace),
+			client.MatchingLabels{backupLabelKey: backup.GetName()},
+		); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to list Pods: %s", err)
+		}
+		status.Size = snapshotSize(podList.Items)
+	}
+
+	if status.Phase != backup.Status.Phase {
+		logger.Info("Backup " + string(status.Phase))
+	}
+	return reconciler.updateBackupStatus(ctx, backup, status)
+}
+
+// startBackup selects a ready replica and creates the Job taking the snapshot from it
+func (reconciler *ReconcileRedisBackup) startBackup(
+	ctx context.Context,
+	backup *k8sv1alpha1.RedisBackup,
+) (reconcile.Result, error) {
+	status := backup.Status.DeepCopy()
+
+	redisObject := new(k8sv1alpha1.Redis)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{
+		Namespace: backup.GetNamespace(),
+		Name:      backup.Spec.RedisName,
+	}, redisObject); err != nil {
+		if errors.IsNotFound(err) {
+			return reconciler.failBackup(ctx, backup, fmt.Sprintf("Redis %s is not found", backup.Spec.RedisName))
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to fetch Redis: %s", err)
+	}
+	if _, err := applyClass(ctx, reconciler.apiReader, redisObject); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to fetch RedisClass %s: %s", redisObject.Spec.ClassName, err)
+	}
+
+	if redisObject.Spec.Backup == nil {
+		return reconciler.failBackup(ctx, backup, fmt.Sprintf("backup is not configured in Redis %s", redisObject.GetName()))
+	}
+	if err := validateBackupStorage(redisObject.Spec.Backup.Storage); err != nil {
+		return reconciler.failBackup(ctx, backup, err.Error())
+	}
+
+	podList := new(corev1.PodList)
+	if err := reconciler.client.List(ctx, podList,
+		client.InNamespace(backup.GetNamespace()),
+		client.MatchingLabels{redisName: redisObject.GetName(), roleLabelKey: replicaLabel},
+	); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to list Pods: %s", err)
+	}
+
+	var sourcePod *corev1.Pod
+	for i := range podList.Items {
+		if podReady(&podList.Items[i]) {
+			sourcePod = &podList.Items[i]
+			break
+		}
+	}
+	if sourcePod == nil {
+		status.Phase = k8sv1alpha1.BackupPhasePending
+		status.Message = "waiting for a ready replica"
+		if _, err := reconciler.updateBackupStatus(ctx, backup, status); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{RequeueAfter: backupSourceRequeueDelay}, nil
+	}
+
+	// Pods are addressed by their DNS names to match the certificate SANs when TLS is enabled
+	job := generateBackupJob(redisObject, backup,
+		fmt.Sprintf("%s.%s", sourcePod.GetName(), generateHeadlessServiceName(redisObject)),
+	)
+	if err := controllerutil.SetControllerReference(backup, job, reconciler.scheme); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to set owner for Job: %s", err)
+	}
+	if err := reconciler.client.Create(ctx, job); err != nil && !errors.IsAlreadyExists(err) {
+		return reconcile.Result{}, fmt.Errorf("failed to create Job: %s", err)
+	}
+
+	startTime := metav1.Now()
+	status.Phase = k8sv1alpha1.BackupPhaseRunning
+	status.SourcePod = sourcePod.GetName()
+	status.Location = backupLocation(redisObject, backup)
+	status.StartTime = &startTime
+	status.Message = ""
+	return reconciler.updateBackupStatus(ctx, backup, status)
+}
+
+// failBackup marks the backup as failed
+func (reconciler *ReconcileRedisBackup) failBackup(
+	ctx context.Context,
+	backup *k8sv1alpha1.RedisBackup,
+	message string,
+) (reconcile.Result, error) {
+	completionTime := metav1.Now()
+	status := backup.Status.DeepCopy()
+	status.Phase = k8sv1alpha1.BackupPhaseFailed
+	status.Message = message
+	status.CompletionTime = &completionTime
+	return reconciler.updateBackupStatus(ctx, backup, status)
+}
+
+// updateBackupStatus updates the RedisBackup status if it has changed
+func (reconciler *ReconcileRedisBackup) updateBackupStatus(
+	ctx context.Context,
+	backup *k8sv1alpha1.RedisBackup,
+	status *k8sv1alpha1.RedisBackupStatus,
+) (reconcile.Result, error) {
+	if reflect.DeepEqual(status, &backup.Status) {
+		return reconcile.Result{}, nil
+	}
+
+	backup.Status = *status
+	if err := reconciler.client.Status().Update(ctx, backup); err != nil {
+		if errors.IsConflict(err) {
+			return reconcile.Result{Requeue: true}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to update RedisBackup status: %s", err)
+	}
+	return reconcile.Result{}, nil
+}
+
+// jobPhase returns the backup phase of the Job, the completion time and the failure message if the Job has finished
+func jobPhase(job *batchv1.Job) (phase k8sv1alpha1.BackupPhase, completionTime *metav1.Time, message string) {
+	phase = k8sv1alpha1.BackupPhaseRunning
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			phase = k8sv1alpha1.BackupPhaseSucceeded
+		case batchv1.JobFailed:
+			phase, message = k8sv1alpha1.BackupPhaseFailed, condition.Message
+		default:
+			continue
+		}
+		lastTransitionTime := condition.LastTransitionTime
+		completionTime = &lastTransitionTime
+	}
+	return
+}
+
+// podReady reports whether the Pod is running and all its containers are ready
+func podReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+		return false
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if !status.Ready {
+			return false
+		}
+	}
+	return true
+}
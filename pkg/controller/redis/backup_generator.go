@@ -0,0 +1,558 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+const (
+	// label key of the backup Jobs and Pods. Backup Pods must not match the Redis Pods selector.
+	backupLabelKey = "redis-backup"
+
+	// Pods using Azure Workload Identity must be labeled explicitly
+	azureWorkloadIdentityLabelKey = "azure.workload.identity/use"
+
+	// backup Job containers
+	snapshotContainerName = "snapshot"
+	uploadContainerName   = "upload"
+
+	// restore init containers
+	restoreContainerName      = "restore"
+	restoreCheckContainerName = "restore-check"
+
+	backupMountPath = "/backup"
+	backupFilePath  = backupMountPath + "/dump.rdb"
+
+	// the rclone remote is configured by RCLONE_CONFIG_STORAGE_* environment variables
+	rcloneRemote          = "storage"
+	rcloneConfigEnvPrefix = "RCLONE_CONFIG_STORAGE_"
+
+	// environment variables of the backup and restore containers
+	backupDirectoryEnvName = "BACKUP_DIRECTORY"
+	backupObjectEnvName    = "BACKUP_OBJECT"
+	restorePathEnvName     = "RESTORE_PATH"
+
+	defaultRDBFileName = "dump.rdb"
+	defaultAOFFileName = "appendonly.aof"
+
+	// snapshot object names start with the timestamp to keep them sorted chronologically
+	backupTimestampLayout = "20060102T150405Z"
+	// backupTimestampShellFormat is backupTimestampLayout in the date(1) format
+	backupTimestampShellFormat = "%Y%m%dT%H%M%SZ"
+
+	// label key of the scheduled backup Jobs and Pods, the value is the Redis name
+	scheduledBackupLabelKey = "redis-scheduled-backup"
+	// scheduledBackupName takes the place of the RedisBackup name in the scheduled snapshot object names
+	scheduledBackupName = "scheduled"
+
+	backupJobBackoffLimit = int32(2)
+
+	// snapshotSizeKey is the name of the size of the uploaded snapshot in the termination message of the upload container
+	snapshotSizeKey = "size"
+)
+
+// validateBackupStorage checks that exactly one storage provider is set
+func validateBackupStorage(storage k8sv1alpha1.BackupStorage) error {
+	providers := 0
+	for _, set := range []bool{storage.S3 != nil, storage.GCS != nil, storage.Azure != nil} {
+		if set {
+			providers++
+		}
+	}
+	if providers != 1 {
+		return errors.New("exactly one of s3, gcs and azure storage must be set")
+	}
+	return nil
+}
+
+// generateBackupJobName returns the name of the Job taking the snapshot
+func generateBackupJobName(backup *k8sv1alpha1.RedisBackup) string {
+	return fmt.Sprintf(namePrefixTemplate, "backup-"+backup.GetName())
+}
+
+// backupBucket returns the bucket or the container name of the storage
+func backupBucket(storage k8sv1alpha1.BackupStorage) string {
+	switch {
+	case storage.S3 != nil:
+		return storage.S3.Bucket
+	case storage.GCS != nil:
+		return storage.GCS.Bucket
+	case storage.Azure != nil:
+		return storage.Azure.Container
+	}
+	return ""
+}
+
+// backupDirectory returns the path of the Redis snapshots relative to the bucket
+func backupDirectory(r *k8sv1alpha1.Redis) string {
+	return path.Join(r.Spec.Backup.Storage.Prefix, r.GetNamespace(), r.GetName())
+}
+
+// backupObjectName returns the snapshot object name
+func backupObjectName(backup *k8sv1alpha1.RedisBackup) string {
+	return fmt.Sprintf("%s-%s.rdb", backup.CreationTimestamp.UTC().Format(backupTimestampLayout), backup.GetName())
+}
+
+// backupLocation returns the URL of the snapshot in the storage
+func backupLocation(r *k8sv1alpha1.Redis, backup *k8sv1alpha1.RedisBackup) string {
+	storage := r.Spec.Backup.Storage
+	objectPath := path.Join(backupBucket(storage), backupDirectory(r), backupObjectName(backup))
+	switch {
+	case storage.S3 != nil:
+		return "s3://" + objectPath
+	case storage.GCS != nil:
+		return "gs://" + objectPath
+	case storage.Azure != nil:
+		return fmt.Sprintf("https://%s.blob.core.windows.net/%s", storage.Azure.Account, objectPath)
+	}
+	return ""
+}
+
+// rcloneEnv returns the environment variables configuring the rclone remote.
+// Credentials are taken from the environment unless they are set explicitly in the credentials Secret.
+func rcloneEnv(storage k8sv1alpha1.BackupStorage) []corev1.EnvVar {
+	config := [][2]string{{"ENV_AUTH", "true"}}
+	switch {
+	case storage.S3 != nil:
+		provider := "AWS"
+		if storage.S3.Endpoint != "" {
+			provider = "Other"
+		}
+		config = append(config, [2]string{"TYPE", "s3"}, [2]string{"PROVIDER", provider})
+		if storage.S3.Region != "" {
+			config = append(config, [2]string{"REGION", storage.S3.Region})
+		}
+		if storage.S3.Endpoint != "" {
+			config = append(config, [2]string{"ENDPOINT", storage.S3.Endpoint})
+		}
+	case storage.GCS != nil:
+		config = append(config, [2]string{"TYPE", "google cloud storage"})
+	case storage.Azure != nil:
+		config = append(config, [2]string{"TYPE", "azureblob"}, [2]string{"ACCOUNT", storage.Azure.Account})
+	}
+
+	env := make([]corev1.EnvVar, 0, len(config))
+	for _, option := range config {
+		env = append(env, corev1.EnvVar{Name: rcloneConfigEnvPrefix + option[0], Value: option[1]})
+	}
+	return env
+}
+
+// workloadIdentityLabels returns labels with the labels required by the workload identity of the storage added.
+// labels are returned as is if no labels are required.
+func workloadIdentityLabels(labels map[string]string, storage k8sv1alpha1.BackupStorage) map[string]string {
+	if storage.Azure == nil || storage.ServiceAccountName == "" {
+		return labels
+	}
+	return withWorkloadIdentityLabel(labels)
+}
+
+// withWorkloadIdentityLabel returns a copy of labels with the Azure Workload Identity label added
+func withWorkloadIdentityLabel(labels map[string]string) map[string]string {
+	identityLabels := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		identityLabels[k] = v
+	}
+	identityLabels[azureWorkloadIdentityLabelKey] = "true"
+	return identityLabels
+}
+
+// backupUploadScript returns the shell script uploading the snapshot and applying the retention
+func backupUploadScript(r *k8sv1alpha1.Redis) string {
+	remoteDirectory := fmt.Sprintf(`"%s:$%s"`, rcloneRemote, backupDirectoryEnvName)
+	lines := []string{
+		"set -eu",
+		// scheduled snapshots are named when the Job runs
+		fmt.Sprintf(`: "${%s:=$(date -u +%s)-%s.rdb}"`, backupObjectEnvName, backupTimestampShellFormat, scheduledBackupName),
+		fmt.Sprintf(`rclone copyto %s "%s:$%s/$%s"`, backupFilePath, rcloneRemote, backupDirectoryEnvName, backupObjectEnvName),
+		// the size is read by the operator from the termination message
+		fmt.Sprintf(`echo "%s=$(wc -c < %s | tr -d ' ')" > /dev/termination-log`, snapshotSizeKey, backupFilePath),
+	}
+
+	if retention := r.Spec.Backup.Retention; retention != nil {
+		if retention.MaxAge != nil {
+			lines = append(lines, fmt.Sprintf(
+				"rclone delete --min-age %ds --include '*.rdb' %s",
+				int64(retention.MaxAge.Seconds()), remoteDirectory,
+			))
+		}
+		if retention.KeepLast != nil {
+			lines = append(lines, fmt.Sprintf(
+				`rclone lsf --files-only --include '*.rdb' %s | sort -r | tail -n +%d | `+
+					`while read -r object; do rclone deletefile "%s:$%s/$object"; done`,
+				remoteDirectory, *retention.KeepLast+1, rcloneRemote, backupDirectoryEnvName,
+			))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// generateBackupJob returns the Job streaming the RDB snapshot from the instance at sourceHost
+// and uploading it to the storage.
+// redis-cli --rdb makes the instance run BGSAVE and transfer the resulting file the same way a replica does.
+func generateBackupJob(r *k8sv1alpha1.Redis, backup *k8sv1alpha1.RedisBackup, sourceHost string) *batchv1.Job {
+	labels := map[string]string{backupLabelKey: backup.GetName()}
+
+	backoffLimit := backupJobBackoffLimit
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generateBackupJobName(backup),
+			Namespace: backup.GetNamespace(),
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: generateBackupPodTemplate(r, labels,
+				redisCliCommand(r, "-h", sourceHost, "-p", strconv.Itoa(redisPort(r)), "--rdb", backupFilePath),
+				backupObjectName(backup),
+			),
+		},
+	}
+}
+
+// generateBackupPodTemplate returns the Pod template running snapshotCommand to save the snapshot to backupFilePath
+// and uploading it as objectName. The object name is generated when the Pod runs if objectName is empty.
+func generateBackupPodTemplate(
+	r *k8sv1alpha1.Redis,
+	labels map[string]string,
+	snapshotCommand []string,
+	objectName string,
+) corev1.PodTemplateSpec {
+	backupVolumeName := fmt.Sprintf("%s-backup", generateName(r))
+	tlsVolumeName := fmt.Sprintf("%s-tls", generateName(r))
+
+	volumes := []corev1.Volume{{
+		Name:         backupVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	}}
+	backupVolumeMount := corev1.VolumeMount{Name: backupVolumeName, MountPath: backupMountPath}
+
+	snapshot := corev1.Container{
+		Name:            snapshotContainerName,
+		Image:           r.Spec.Redis.ImageReference(),
+		Command:         snapshotCommand,
+		VolumeMounts:    []corev1.VolumeMount{backupVolumeMount},
+		SecurityContext: r.Spec.Redis.SecurityContext,
+	}
+
+	if r.Spec.Password.SecretKeyRef != nil {
+		snapshot.Env = []corev1.EnvVar{{
+			Name:      rediscliAuthEnvName,
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: r.Spec.Password.SecretKeyRef},
+		}}
+	}
+
+	if r.Spec.TLS != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name: tlsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: r.Spec.TLS.SecretName},
+			},
+		})
+		snapshot.VolumeMounts = append(snapshot.VolumeMounts, corev1.VolumeMount{
+			Name:      tlsVolumeName,
+			ReadOnly:  true,
+			MountPath: tlsMountPath,
+		})
+	}
+
+	upload := corev1.Container{
+		Name:    uploadContainerName,
+		Image:   r.Spec.Backup.Agent.ImageReference(),
+		Command: []string{"/bin/sh", "-c", backupUploadScript(r)},
+		Env: append(rcloneEnv(r.Spec.Backup.Storage),
+			corev1.EnvVar{
+				Name:  backupDirectoryEnvName,
+				Value: path.Join(backupBucket(r.Spec.Backup.Storage), backupDirectory(r)),
+			},
+		),
+		Resources:       r.Spec.Backup.Agent.Resources,
+		VolumeMounts:    []corev1.VolumeMount{backupVolumeMount},
+		SecurityContext: r.Spec.Backup.Agent.SecurityContext,
+	}
+	if objectName != "" {
+		upload.Env = append(upload.Env, corev1.EnvVar{Name: backupObjectEnvName, Value: objectName})
+	}
+
+	if r.Spec.Backup.Storage.CredentialsSecretName != "" {
+		upload.EnvFrom = []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: r.Spec.Backup.Storage.CredentialsSecretName},
+			},
+		}}
+	}
+
+	// the sysctls tune the Redis Pods, the kubelets not allowing the unsafe ones would reject the Job Pods
+	securityContext := r.Spec.SecurityContext
+	if securityContext != nil && len(securityContext.Sysctls) > 0 {
+		securityContext = securityContext.DeepCopy()
+		securityContext.Sysctls = nil
+	}
+
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: workloadIdentityLabels(labels, r.Spec.Backup.Storage)},
+		Spec: corev1.PodSpec{
+			RestartPolicy:      corev1.RestartPolicyNever,
+			Volumes:            volumes,
+			InitContainers:     []corev1.Container{snapshot},
+			Containers:         []corev1.Container{upload},
+			ServiceAccountName: r.Spec.Backup.Storage.ServiceAccountName,
+			SecurityContext:    securityContext,
+			ImagePullSecrets:   r.Spec.ImagePullSecrets,
+		},
+	}
+}
+
+// backupScheduled reports whether the backup CronJob is needed
+func backupScheduled(r *k8sv1alpha1.Redis) bool {
+	return r.Spec.Backup != nil && r.Spec.Backup.Schedule != nil
+}
+
+// generateBackupCronJobName returns the name of the CronJob taking the scheduled snapshots
+func generateBackupCronJobName(r *k8sv1alpha1.Redis) string {
+	return fmt.Sprintf("%s-backup", generateName(r))
+}
+
+// backupSnapshotScript returns the shell script selecting the instance according to the schedule target
+// and streaming the snapshot from it. The roles are checked when the Job runs since the master may change any time.
+// Instances are addressed by the Pod DNS names to match the certificate SANs when TLS is enabled.
+func backupSnapshotScript(r *k8sv1alpha1.Redis) string {
+	// roles as seen in the ROLE output, in order of preference
+	var roles []string
+	switch r.Spec.Backup.Schedule.Target {
+	case k8sv1alpha1.BackupTargetMaster:
+		roles = []string{"master"}
+	case k8sv1alpha1.BackupTargetPreferReplica:
+		roles = []string{"slave", "master"}
+	default:
+		roles = []string{"slave"}
+	}
+
+	hosts := make([]string, *r.Spec.Replicas)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("%s-%d.%s", generateName(r), i, generateHeadlessServiceName(r))
+	}
+	cli := strings.Join(redisCliCommand(r, "-h", `"$host"`, "-p", strconv.Itoa(redisPort(r))), " ")
+
+	lines := []string{"set -u"}
+	for _, role := range roles {
+		lines = append(lines,
+			fmt.Sprintf("for host in %s; do", strings.Join(hosts, " ")),
+			fmt.Sprintf(`  if [ "$(%s role | head -n 1)" = "%s" ]; then exec %[1]s --rdb %[3]s; fi`, cli, role, backupFilePath),
+			"done",
+		)
+	}
+	return strings.Join(append(lines,
+		fmt.Sprintf(`echo "no %s instance is available" >&2`, strings.Join(roles, " or ")),
+		"exit 1",
+	), "\n")
+}
+
+// generateBackupCronJob returns the CronJob taking the scheduled snapshots.
+// Concurrent backups are forbidden, a missed schedule is skipped while the previous backup is running.
+func generateBackupCronJob(r *k8sv1alpha1.Redis) *batchv1beta1.CronJob {
+	labels := map[string]string{scheduledBackupLabelKey: r.GetName()}
+	suspend := r.Spec.Backup.Schedule.Suspend
+	backoffLimit := backupJobBackoffLimit
+
+	return &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generateBackupCronJobName(r),
+			Namespace: r.GetNamespace(),
+			Labels:    r.GetLabels(),
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:          r.Spec.Backup.Schedule.Cron,
+			ConcurrencyPolicy: batchv1beta1.ForbidConcurrent,
+			Suspend:           &suspend,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: batchv1.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template: generateBackupPodTemplate(r, labels,
+						[]string{"/bin/sh", "-c", backupSnapshotScript(r)},
+						"",
+					),
+				},
+			},
+		},
+	}
+}
+
+// generateUnstructuredBackupCronJob returns the backup CronJob as a batch/v1 unstructured object, see cronJobGVK
+func generateUnstructuredBackupCronJob(r *k8sv1alpha1.Redis) *unstructured.Unstructured {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(generateBackupCronJob(r))
+	if err != nil {
+		// the typed CronJob is always converted
+		return nil
+	}
+	u := &unstructured.Unstructured{Object: object}
+	u.SetGroupVersionKind(cronJobGVK)
+	// the status of the typed CronJob is not managed
+	unstructured.RemoveNestedField(u.Object, "status")
+	return u
+}
+
+// restoreSource is the location of the snapshot the data is restored from
+type restoreSource struct {
+	storage k8sv1alpha1.BackupStorage
+	agent   k8sv1alpha1.ContainerSpec
+	// path of the file including the bucket
+	path string
+}
+
+// rdbFileName returns the name of the RDB file in the data directory
+func rdbFileName(r *k8sv1alpha1.Redis) string {
+	if name, ok := r.Spec.Config["dbfilename"]; ok && name != "" {
+		return name
+	}
+	return defaultRDBFileName
+}
+
+// aofFileName returns the name of the append only file in the data directory
+func aofFileName(r *k8sv1alpha1.Redis) string {
+	if name, ok := r.Spec.Config["appendfilename"]; ok && name != "" {
+		return name
+	}
+	return defaultAOFFileName
+}
+
+// generateRestoreContainers returns the init containers downloading the snapshot to the data volume and checking it.
+// Files with the .aof extension are restored as the append only file.
+func generateRestoreContainers(
+	r *k8sv1alpha1.Redis,
+	source *restoreSource,
+	dataVolumeMount corev1.VolumeMount,
+) []corev1.Container {
+	filePath, checkCommand := path.Join(dataMountPath, rdbFileName(r)), "redis-check-rdb"
+	if path.Ext(source.path) == ".aof" {
+		filePath, checkCommand = path.Join(dataMountPath, aofFileName(r)), "redis-check-aof"
+	}
+
+	script := strings.Join([]string{
+		"set -eu",
+		fmt.Sprintf(`if [ -e "%s" ]; then echo "%[1]s exists, skipping restore"; exit 0; fi`, filePath),
+		fmt.Sprintf(`rclone copyto "%s:$%s" "%s.restore"`, rcloneRemote, restorePathEnvName, filePath),
+		fmt.Sprintf(`mv "%s.restore" "%[1]s"`, filePath),
+	}, "\n")
+
+	download := corev1.Container{
+		Name:    restoreContainerName,
+		Image:   source.agent.ImageReference(),
+		Command: []string{"/bin/sh", "-c", script},
+		Env: append(rcloneEnv(source.storage),
+			corev1.EnvVar{Name: restorePathEnvName, Value: source.path},
+		),
+		Resources:       source.agent.Resources,
+		VolumeMounts:    []corev1.VolumeMount{dataVolumeMount},
+		SecurityContext: source.agent.SecurityContext,
+	}
+
+	if source.storage.CredentialsSecretName != "" {
+		download.EnvFrom = []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: source.storage.CredentialsSecretName},
+			},
+		}}
+	}
+
+	check := corev1.Container{
+		Name:            restoreCheckContainerName,
+		Image:           r.Spec.Redis.ImageReference(),
+		Command:         []string{checkCommand, filePath},
+		VolumeMounts:    []corev1.VolumeMount{dataVolumeMount},
+		SecurityContext: r.Spec.Redis.SecurityContext,
+	}
+
+	return []corev1.Container{download, check}
+}
+
+// keptRestore is the restore part of the Pod template kept once the data has been restored,
+// so the template does not change when the restore source becomes unavailable
+type keptRestore struct {
+	containers         []corev1.Container
+	serviceAccountName string
+	workloadIdentity   bool
+}
+
+// keptRestoreOf returns the restore part of the Pod template of the StatefulSet, nil if it has no restore containers
+func keptRestoreOf(statefulSet *appsv1.StatefulSet) *keptRestore {
+	template := statefulSet.Spec.Template
+	var containers []corev1.Container
+	for _, container := range template.Spec.InitContainers {
+		if container.Name == restoreContainerName || container.Name == restoreCheckContainerName {
+			containers = append(containers, container)
+		}
+	}
+	if containers == nil {
+		return nil
+	}
+	return &keptRestore{
+		containers:         containers,
+		serviceAccountName: template.Spec.ServiceAccountName,
+		workloadIdentity:   template.Labels[azureWorkloadIdentityLabelKey] == "true",
+	}
+}
+
+// generateRestoreDrillName returns the name of the temporary Redis restored during the drill
+func generateRestoreDrillName(r *k8sv1alpha1.Redis) string {
+	return r.GetName() + "-restore-drill"
+}
+
+// generateRestoreDrill returns the temporary Redis restoring the snapshot of the backup.
+// Persistence, TLS, ACL and backups are stripped off: the drill verifies the snapshot only.
+func generateRestoreDrill(r *k8sv1alpha1.Redis, backup *k8sv1alpha1.RedisBackup) *k8sv1alpha1.Redis {
+	drill := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generateRestoreDrillName(r),
+			Namespace: r.GetNamespace(),
+		},
+		Spec: *r.Spec.DeepCopy(),
+	}
+
+	drill.Spec.Config = make(map[string]string)
+	for key, value := range r.Spec.Config {
+		// RDB files are ignored on startup when AOF is enabled
+		if key != "appendonly" {
+			drill.Spec.Config[key] = value
+		}
+	}
+	drill.Spec.DataVolumeClaimTemplate = corev1.PersistentVolumeClaim{}
+	drill.Spec.DataVolumeUsageThreshold = nil
+	drill.Spec.TLS = nil
+	drill.Spec.ACL = nil
+	drill.Spec.Backup = nil
+	// the class is expanded into the spec, the backup of the class must not be restored along with it
+	drill.Spec.ClassName = ""
+	drill.Spec.Restore = &k8sv1alpha1.Restore{BackupName: backup.GetName()}
+
+	return drill
+}
@@ -0,0 +1,424 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_validateBackupStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage k8sv1alpha1.BackupStorage
+		wantErr bool
+	}{
+		{"none", k8sv1alpha1.BackupStorage{}, true},
+		{"s3", k8sv1alpha1.BackupStorage{S3: &k8sv1alpha1.S3Storage{Bucket: "b"}}, false},
+		{"gcs", k8sv1alpha1.BackupStorage{GCS: &k8sv1alpha1.GCSStorage{Bucket: "b"}}, false},
+		{"both", k8sv1alpha1.BackupStorage{
+			S3:  &k8sv1alpha1.S3Storage{Bucket: "b"},
+			GCS: &k8sv1alpha1.GCSStorage{Bucket: "b"},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateBackupStorage(tt.storage); (err != nil) != tt.wantErr {
+				t.Errorf("validateBackupStorage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_backupLocation(t *testing.T) {
+	backup := &k8sv1alpha1.RedisBackup{ObjectMeta: metav1.ObjectMeta{
+		Name:              "nightly",
+		Namespace:         "ns",
+		CreationTimestamp: metav1.NewTime(time.Date(2020, 5, 4, 3, 2, 1, 0, time.UTC)),
+	}}
+	tests := []struct {
+		name    string
+		storage k8sv1alpha1.BackupStorage
+		want    string
+	}{
+		{"s3", k8sv1alpha1.BackupStorage{S3: &k8sv1alpha1.S3Storage{Bucket: "bucket"}},
+			"s3://bucket/ns/example/20200504T030201Z-nightly.rdb"},
+		{"gcs with prefix", k8sv1alpha1.BackupStorage{GCS: &k8sv1alpha1.GCSStorage{Bucket: "bucket"}, Prefix: "/redis/"},
+			"gs://bucket/redis/ns/example/20200504T030201Z-nightly.rdb"},
+		{"azure", k8sv1alpha1.BackupStorage{Azure: &k8sv1alpha1.AzureStorage{Account: "acc", Container: "backups"}},
+			"https://acc.blob.core.windows.net/backups/ns/example/20200504T030201Z-nightly.rdb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{
+				ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "ns"},
+				Spec:       k8sv1alpha1.RedisSpec{Backup: &k8sv1alpha1.Backup{Storage: tt.storage}},
+			}
+			if got := backupLocation(r, backup); got != tt.want {
+				t.Errorf("backupLocation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_backupUploadScript(t *testing.T) {
+	keepLast := int32(7)
+	tests := []struct {
+		name      string
+		retention *k8sv1alpha1.BackupRetention
+		want      []string
+		wantNot   []string
+	}{
+		{"no retention", nil, []string{"rclone copyto /backup/dump.rdb", `echo "size=$(wc -c < /backup/dump.rdb`}, []string{"delete"}},
+		{"max age", &k8sv1alpha1.BackupRetention{MaxAge: &metav1.Duration{Duration: 48 * time.Hour}},
+			[]string{"rclone delete --min-age 172800s"}, []string{"deletefile"}},
+		{"keep last", &k8sv1alpha1.BackupRetention{KeepLast: &keepLast},
+			[]string{"sort -r | tail -n +8 |", "rclone deletefile"}, []string{"--min-age"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{Backup: &k8sv1alpha1.Backup{Retention: tt.retention}}}
+			got := backupUploadScript(r)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("backupUploadScript() = %q, want %q", got, want)
+				}
+			}
+			for _, wantNot := range tt.wantNot {
+				if strings.Contains(got, wantNot) {
+					t.Errorf("backupUploadScript() = %q, must not contain %q", got, wantNot)
+				}
+			}
+		})
+	}
+}
+
+func Test_generateBackupJob(t *testing.T) {
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "ns", Labels: map[string]string{redisName: "example"}},
+		Spec: k8sv1alpha1.RedisSpec{
+			Redis: k8sv1alpha1.ContainerSpec{Image: "redis:6"},
+			TLS:   &k8sv1alpha1.TLS{SecretName: "tls"},
+			Backup: &k8sv1alpha1.Backup{
+				Storage: k8sv1alpha1.BackupStorage{
+					S3:                    &k8sv1alpha1.S3Storage{Bucket: "bucket", Endpoint: "http://minio:9000"},
+					CredentialsSecretName: "s3-credentials",
+				},
+				Agent: k8sv1alpha1.ContainerSpec{Image: "rclone/rclone"},
+			},
+		},
+	}
+	backup := &k8sv1alpha1.RedisBackup{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "ns"}}
+
+	job := generateBackupJob(r, backup, "redis-example-1.redis-example-headless")
+	if job.GetName() != "redis-backup-nightly" {
+		t.Errorf("generateBackupJob() name = %v", job.GetName())
+	}
+	if _, ok := job.Spec.Template.Labels[redisName]; ok {
+		t.Errorf("generateBackupJob() Pod labels %v must not match the Redis Pods", job.Spec.Template.Labels)
+	}
+
+	snapshot := job.Spec.Template.Spec.InitContainers[0]
+	if got := strings.Join(snapshot.Command, " "); !strings.HasPrefix(got, "redis-cli --tls") ||
+		!strings.HasSuffix(got, "-h redis-example-1.redis-example-headless -p 6379 --rdb /backup/dump.rdb") {
+		t.Errorf("generateBackupJob() snapshot command = %v", got)
+	}
+	if len(snapshot.VolumeMounts) != 2 {
+		t.Errorf("generateBackupJob() snapshot volume mounts = %v, want the backup and TLS volumes", snapshot.VolumeMounts)
+	}
+
+	upload := job.Spec.Template.Spec.Containers[0]
+	env := make(map[string]string)
+	for _, e := range upload.Env {
+		env[e.Name] = e.Value
+	}
+	for name, want := range map[string]string{
+		"RCLONE_CONFIG_STORAGE_TYPE":     "s3",
+		"RCLONE_CONFIG_STORAGE_PROVIDER": "Other",
+		"RCLONE_CONFIG_STORAGE_ENDPOINT": "http://minio:9000",
+		backupDirectoryEnvName:           "bucket/ns/example",
+	} {
+		if env[name] != want {
+			t.Errorf("generateBackupJob() env %s = %v, want %v", name, env[name], want)
+		}
+	}
+	if len(upload.EnvFrom) != 1 || upload.EnvFrom[0].SecretRef.Name != "s3-credentials" {
+		t.Errorf("generateBackupJob() envFrom = %v", upload.EnvFrom)
+	}
+}
+
+func Test_backupSnapshotScript(t *testing.T) {
+	replicas := int32(2)
+	tests := []struct {
+		name   string
+		target k8sv1alpha1.BackupTarget
+		want   []string
+	}{
+		{"default", "", []string{`= "slave"`}},
+		{"master", k8sv1alpha1.BackupTargetMaster, []string{`= "master"`}},
+		{"prefer replica", k8sv1alpha1.BackupTargetPreferReplica, []string{`= "slave"`, `= "master"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{
+				ObjectMeta: metav1.ObjectMeta{Name: "example"},
+				Spec: k8sv1alpha1.RedisSpec{
+					Replicas: &replicas,
+					Backup:   &k8sv1alpha1.Backup{Schedule: &k8sv1alpha1.BackupSchedule{Target: tt.target}},
+				},
+			}
+			got := backupSnapshotScript(r)
+			if !strings.Contains(got, "for host in redis-example-0.redis-example-headless redis-example-1.redis-example-headless; do") {
+				t.Errorf("backupSnapshotScript() = %q, want all the instances checked", got)
+			}
+			if !strings.Contains(got, `exec redis-cli -h "$host" -p 6379 --rdb /backup/dump.rdb`) {
+				t.Errorf("backupSnapshotScript() = %q, want the snapshot streamed", got)
+			}
+			if strings.Count(got, "for host in") != len(tt.want) {
+				t.Errorf("backupSnapshotScript() = %q, want %d loops", got, len(tt.want))
+			}
+			// the roles must be checked in order of preference
+			last := -1
+			for _, want := range tt.want {
+				i := strings.Index(got, want)
+				if i <= last {
+					t.Errorf("backupSnapshotScript() = %q, want %q after the previous role", got, want)
+				}
+				last = i
+			}
+		})
+	}
+}
+
+func Test_generateUnstructuredBackupCronJob(t *testing.T) {
+	replicas := int32(2)
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "ns"},
+		Spec: k8sv1alpha1.RedisSpec{
+			Replicas: &replicas,
+			Redis:    k8sv1alpha1.ContainerSpec{Image: "redis:6"},
+			Backup: &k8sv1alpha1.Backup{
+				Storage:  k8sv1alpha1.BackupStorage{S3: &k8sv1alpha1.S3Storage{Bucket: "bucket"}},
+				Agent:    k8sv1alpha1.ContainerSpec{Image: "rclone/rclone"},
+				Schedule: &k8sv1alpha1.BackupSchedule{Cron: "0 3 * * *"},
+			},
+		},
+	}
+	u := generateUnstructuredBackupCronJob(r)
+	if u.GetAPIVersion() != "batch/v1" || u.GetKind() != "CronJob" || u.GetName() != generateBackupCronJobName(r) {
+		t.Errorf("generateUnstructuredBackupCronJob() = %s %s %s", u.GetAPIVersion(), u.GetKind(), u.GetName())
+	}
+	if _, ok := u.Object["status"]; ok {
+		t.Errorf("generateUnstructuredBackupCronJob() status = %v", u.Object["status"])
+	}
+
+	lastScheduleTime := metav1.NewTime(time.Date(2021, 6, 1, 3, 0, 0, 0, time.UTC))
+	u.Object["status"] = map[string]interface{}{"lastScheduleTime": lastScheduleTime.Format(time.RFC3339)}
+	cronJob, err := typedCronJob(u)
+	if err != nil {
+		t.Fatalf("typedCronJob() error = %v", err)
+	}
+	want := generateBackupCronJob(r)
+	if !reflect.DeepEqual(cronJob.Spec, want.Spec) {
+		t.Errorf("typedCronJob() spec = %v, want %v", cronJob.Spec, want.Spec)
+	}
+	if cronJob.Status.LastScheduleTime == nil || !cronJob.Status.LastScheduleTime.Equal(&lastScheduleTime) {
+		t.Errorf("typedCronJob() lastScheduleTime = %v, want %v", cronJob.Status.LastScheduleTime, lastScheduleTime)
+	}
+}
+
+func Test_updateScheduledBackupStatus(t *testing.T) {
+	now := time.Now()
+	job := func(name string, created time.Duration, condition batchv1.JobConditionType, completed time.Duration) batchv1.Job {
+		j := batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(now.Add(created)),
+		}}
+		if condition != "" {
+			j.Status.Conditions = []batchv1.JobCondition{{
+				Type:               condition,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(now.Add(completed)),
+				Message:            name,
+			}}
+		}
+		return j
+	}
+	previousSuccess := metav1.NewTime(now.Add(-48 * time.Hour))
+
+	status := &k8sv1alpha1.ScheduledBackupStatus{LastSuccessTime: &previousSuccess}
+	updateScheduledBackupStatus(status, []batchv1.Job{
+		job("succeeded", -3*time.Hour, batchv1.JobComplete, -170*time.Minute),
+		job("running", -time.Hour, "", 0),
+		job("failed", -2*time.Hour, batchv1.JobFailed, -110*time.Minute),
+	})
+
+	if status.LastJobName != "running" || status.LastPhase != k8sv1alpha1.BackupPhaseRunning {
+		t.Errorf("updateScheduledBackupStatus() last job = %s %s, want running Running", status.LastJobName, status.LastPhase)
+	}
+	if status.LastSuccessTime == nil || !status.LastSuccessTime.Equal(&metav1.Time{Time: now.Add(-170 * time.Minute)}) {
+		t.Errorf("updateScheduledBackupStatus() LastSuccessTime = %v", status.LastSuccessTime)
+	}
+	if status.LastFailureTime == nil || status.Message != "failed" {
+		t.Errorf("updateScheduledBackupStatus() LastFailureTime = %v, Message = %q", status.LastFailureTime, status.Message)
+	}
+
+	// the times of the backups removed from the history are preserved
+	updateScheduledBackupStatus(status, nil)
+	if status.LastSuccessTime == nil || status.LastJobName != "running" {
+		t.Errorf("updateScheduledBackupStatus() = %+v, want the previous state preserved", status)
+	}
+}
+
+func Test_generateRestoreContainers(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    map[string]string
+		path      string
+		want      string
+		wantCheck string
+	}{
+		{"default", nil, "bucket/ns/example/20200504T030201Z-nightly.rdb", "/data/dump.rdb", "redis-check-rdb"},
+		{"dbfilename", map[string]string{"dbfilename": "redis.rdb"}, "bucket/dump.rdb", "/data/redis.rdb", "redis-check-rdb"},
+		{"aof", map[string]string{"appendonly": "yes"}, "bucket/appendonly.aof", "/data/appendonly.aof", "redis-check-aof"},
+		{"appendfilename", map[string]string{"appendfilename": "redis.aof"}, "bucket/appendonly.aof", "/data/redis.aof", "redis-check-aof"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{Config: tt.config, Redis: k8sv1alpha1.ContainerSpec{Image: "redis:6"}}}
+			source := &restoreSource{
+				storage: k8sv1alpha1.BackupStorage{GCS: &k8sv1alpha1.GCSStorage{Bucket: "bucket"}},
+				agent:   k8sv1alpha1.ContainerSpec{Image: "rclone/rclone"},
+				path:    tt.path,
+			}
+			containers := generateRestoreContainers(r, source, corev1.VolumeMount{Name: "data", MountPath: dataMountPath})
+			if len(containers) != 2 {
+				t.Fatalf("generateRestoreContainers() = %v, want the restore and check containers", containers)
+			}
+			if script := containers[0].Command[2]; !strings.Contains(script, `if [ -e "`+tt.want+`" ]`) ||
+				!strings.Contains(script, `rclone copyto "storage:$RESTORE_PATH" "`+tt.want+`.restore"`) {
+				t.Errorf("generateRestoreContainers() restore script = %v", script)
+			}
+			if got := strings.Join(containers[1].Command, " "); got != tt.wantCheck+" "+tt.want {
+				t.Errorf("generateRestoreContainers() check command = %v", got)
+			}
+		})
+	}
+}
+
+func Test_keptRestoreOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage k8sv1alpha1.BackupStorage
+	}{
+		{"credentials", k8sv1alpha1.BackupStorage{GCS: &k8sv1alpha1.GCSStorage{Bucket: "bucket"}, CredentialsSecretName: "gcs"}},
+		{"workload identity", k8sv1alpha1.BackupStorage{
+			Azure:              &k8sv1alpha1.AzureStorage{Account: "account", Container: "container"},
+			ServiceAccountName: "storage",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example", Labels: map[string]string{"app": "redis"}},
+				Spec: k8sv1alpha1.RedisSpec{Redis: k8sv1alpha1.ContainerSpec{Image: "redis:6"}}}
+			restored := generateStatefulSet(r, objectGeneratorOptions{restore: &restoreSource{
+				storage: tt.storage,
+				agent:   k8sv1alpha1.ContainerSpec{Image: "rclone/rclone"},
+				path:    "bucket/dump.rdb",
+			}})
+			kept := keptRestoreOf(restored)
+			if kept == nil {
+				t.Fatalf("keptRestoreOf() = nil, want the restore containers")
+			}
+			// the template is kept once the restore source is no longer available
+			if got := generateStatefulSet(r, objectGeneratorOptions{keptRestore: kept}); !reflect.DeepEqual(got.Spec.Template, restored.Spec.Template) {
+				t.Errorf("generateStatefulSet() template = %+v, want %+v", got.Spec.Template, restored.Spec.Template)
+			}
+			if kept := keptRestoreOf(generateStatefulSet(r, objectGeneratorOptions{})); kept != nil {
+				t.Errorf("keptRestoreOf() = %+v, want nil without the restore containers", kept)
+			}
+		})
+	}
+}
+
+func Test_generateRestoreDrill(t *testing.T) {
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "ns"},
+		Spec: k8sv1alpha1.RedisSpec{
+			Config:                  map[string]string{"appendonly": "yes", "maxmemory": "1gb"},
+			DataVolumeClaimTemplate: corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data"}},
+			TLS:                     &k8sv1alpha1.TLS{SecretName: "tls"},
+			Backup:                  &k8sv1alpha1.Backup{Agent: k8sv1alpha1.ContainerSpec{Image: "rclone/rclone"}},
+		},
+	}
+	backup := &k8sv1alpha1.RedisBackup{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "ns"}}
+
+	drill := generateRestoreDrill(r, backup)
+	if drill.GetName() != "example-restore-drill" || drill.GetNamespace() != "ns" {
+		t.Errorf("generateRestoreDrill() = %s/%s", drill.GetNamespace(), drill.GetName())
+	}
+	if _, ok := drill.Spec.Config["appendonly"]; ok || drill.Spec.Config["maxmemory"] != "1gb" {
+		t.Errorf("generateRestoreDrill() config = %v", drill.Spec.Config)
+	}
+	if drill.Spec.TLS != nil || drill.Spec.Backup != nil || drill.Spec.DataVolumeClaimTemplate.Name != "" {
+		t.Errorf("generateRestoreDrill() spec = %+v", drill.Spec)
+	}
+	if drill.Spec.Restore == nil || drill.Spec.Restore.BackupName != "nightly" {
+		t.Errorf("generateRestoreDrill() restore = %v", drill.Spec.Restore)
+	}
+	if r.Spec.Config["appendonly"] != "yes" || r.Spec.Backup == nil {
+		t.Errorf("generateRestoreDrill() modified the source Redis")
+	}
+}
+
+func Test_workloadIdentityLabels(t *testing.T) {
+	labels := map[string]string{redisName: "example"}
+	tests := []struct {
+		name    string
+		storage k8sv1alpha1.BackupStorage
+		want    map[string]string
+	}{
+		{"static credentials", k8sv1alpha1.BackupStorage{
+			Azure:                 &k8sv1alpha1.AzureStorage{Account: "acc", Container: "backups"},
+			CredentialsSecretName: "azure-credentials",
+		}, labels},
+		{"s3 identity", k8sv1alpha1.BackupStorage{
+			S3:                 &k8sv1alpha1.S3Storage{Bucket: "bucket"},
+			ServiceAccountName: "backup",
+		}, labels},
+		{"azure identity", k8sv1alpha1.BackupStorage{
+			Azure:              &k8sv1alpha1.AzureStorage{Account: "acc", Container: "backups"},
+			ServiceAccountName: "backup",
+		}, map[string]string{redisName: "example", azureWorkloadIdentityLabelKey: "true"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := workloadIdentityLabels(labels, tt.storage); !mapsEqual(got, tt.want) {
+				t.Errorf("workloadIdentityLabels() = %v, want %v", got, tt.want)
+			}
+			if len(labels) != 1 {
+				t.Errorf("workloadIdentityLabels() modified the labels: %v", labels)
+			}
+		})
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// blueGreenRequeueDelay is the interval the instances replicating from the peer are checked at
+const blueGreenRequeueDelay = 10 * time.Second
+
+// blueGreenToRequests maps the Redis of a blue/green deployment to its peers:
+// the green Redis follows the blue master and the blue Redis follows the cutover
+func blueGreenToRequests(c client.Client) handler.ToRequestsFunc {
+	return func(object handler.MapObject) []reconcile.Request {
+		r, ok := object.Object.(*k8sv1alpha1.Redis)
+		if !ok {
+			return nil
+		}
+		var requests []reconcile.Request
+		if r.Spec.BlueGreen != nil {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: r.GetNamespace(),
+				Name:      r.Spec.BlueGreen.Blue,
+			}})
+		}
+
+		redisList := new(k8sv1alpha1.RedisList)
+		if err := c.List(context.TODO(), redisList, client.InNamespace(r.GetNamespace())); err != nil {
+			log.Error(err, "failed to list Redis", "Namespace", r.GetNamespace())
+			return requests
+		}
+		for i := range redisList.Items {
+			if blueGreen := redisList.Items[i].Spec.BlueGreen; blueGreen != nil && blueGreen.Blue == r.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Namespace: redisList.Items[i].GetNamespace(),
+					Name:      redisList.Items[i].GetName(),
+				}})
+			}
+		}
+		return requests
+	}
+}
+
+// blueGreenPeer returns the other Redis of the blue/green deployment: the blue Redis of the green one
+// or the green Redis replacing the Redis. nil is returned if there is none.
+func (reconciler *ReconcileRedis) blueGreenPeer(ctx context.Context, r *k8sv1alpha1.Redis) (*k8sv1alpha1.Redis, error) {
+	if r.Spec.BlueGreen != nil {
+		blue := new(k8sv1alpha1.Redis)
+		err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: r.Spec.BlueGreen.Blue}, blue)
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch blue Redis: %s", err)
+		}
+		return blue, nil
+	}
+
+	redisList := new(k8sv1alpha1.RedisList)
+	if err := reconciler.client.List(ctx, redisList, client.InNamespace(r.GetNamespace())); err != nil {
+		return nil, fmt.Errorf("failed to list Redis: %s", err)
+	}
+	for i := range redisList.Items {
+		if blueGreen := redisList.Items[i].Spec.BlueGreen; blueGreen != nil && blueGreen.Blue == r.GetName() {
+			return &redisList.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// demotedFor returns the green Redis the blue one is demoted for once the green master has taken over, nil otherwise
+func demotedFor(r, peer *k8sv1alpha1.Redis) *k8sv1alpha1.Redis {
+	if r.Spec.BlueGreen != nil || peer == nil || peer.Status.BlueGreen == nil ||
+		peer.Status.BlueGreen.Phase != k8sv1alpha1.BlueGreenCutOver {
+		return nil
+	}
+	return peer
+}
+
+// blueGreenPeerLabels select the Pods of the peer
+func blueGreenPeerLabels(peer string) map[string]string {
+	return map[string]string{redisName: peer}
+}
+
+// redirectMasterService points the master Service of the demoted blue Redis at the green master
+func redirectMasterService(service *corev1.Service, green *k8sv1alpha1.Redis) {
+	service.Spec.Selector = blueGreenPeerLabels(green.GetName())
+	service.Spec.Selector[roleLabelKey] = masterLabel
+	for i := range service.Spec.Ports {
+		if service.Spec.Ports[i].Name == redisName {
+			service.Spec.Ports[i].TargetPort = intstr.FromInt(redisPort(green))
+		}
+	}
+}
+
+// peerPodAddress returns the address of the ready Pod of the peer, the empty address otherwise
+func (reconciler *ReconcileRedis) peerPodAddress(ctx context.Context, peer *k8sv1alpha1.Redis, name string) (redis.Address, error) {
+	if name == "" {
+		return redis.Address{}, nil
+	}
+	pod := new(corev1.Pod)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: peer.GetNamespace(), Name: name}, pod); err != nil {
+		if errors.IsNotFound(err) {
+			return redis.Address{}, nil
+		}
+		return redis.Address{}, fmt.Errorf("failed to fetch Pod: %s", err)
+	}
+	if !podReady(pod) {
+		return redis.Address{}, nil
+	}
+	return redis.Address{Host: pod.Status.PodIP, Port: strconv.Itoa(redisPort(peer))}, nil
+}
+
+// peerAddresses returns the addresses of the ready Pods of the peer.
+// They replicate from the master along with the instances and are left out of the replication.
+func (reconciler *ReconcileRedis) peerAddresses(ctx context.Context, peer *k8sv1alpha1.Redis) ([]redis.Address, error) {
+	podList := new(corev1.PodList)
+	if err := reconciler.client.List(ctx, podList, client.InNamespace(peer.GetNamespace()),
+		client.MatchingLabels(blueGreenPeerLabels(peer.GetName()))); err != nil {
+		return nil, fmt.Errorf("failed to list Pods: %s", err)
+	}
+	var addresses []redis.Address
+	for i := range podList.Items {
+		if podReady(&podList.Items[i]) && memberOf(peer, &podList.Items[i]) {
+			addresses = append(addresses, redis.Address{Host: podList.Items[i].Status.PodIP, Port: strconv.Itoa(redisPort(peer))})
+		}
+	}
+	return addresses, nil
+}
+
+// blueGreenPhase returns the phase of the green Redis replicating from the blue master: InSync once all
+// the desired instances are linked to it
+func blueGreenPhase(linked, desired int) k8sv1alpha1.BlueGreenPhase {
+	if linked >= desired {
+		return k8sv1alpha1.BlueGreenInSync
+	}
+	return k8sv1alpha1.BlueGreenReplicating
+}
+
+// follow makes the instances replicas of the upstream master outside of the replication managed by the operator,
+// labels the Pods as replicas and points the configuration at the upstream master.
+// It returns the number of instances linked to the upstream master before the reconfiguration.
+func (reconciler *ReconcileRedis) follow(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	pods []corev1.Pod,
+	addresses []redis.Address,
+	options objectGeneratorOptions,
+	redisOptions redis.Options,
+	upstream redis.Address,
+	reason string,
+) (int, error) {
+	instances, err := redis.NewCaches(ctx, redisOptions, addresses...)
+	if err != nil {
+		return 0, err
+	}
+	defer instances.Disconnect()
+
+	if err := instances.ApplyUsers(options.aclUsers...); err != nil {
+		return 0, fmt.Errorf("error applying ACL users: %s", err)
+	}
+	if err := instances.DeleteUsers(options.removedACLUsers...); err != nil {
+		return 0, fmt.Errorf("error deleting ACL users: %s", err)
+	}
+	linked := len(instances.Linked(upstream))
+	reconfigured, err := instances.ReplicateFrom(ctx, upstream)
+	if len(reconfigured) > 0 {
+		names := make(map[string]string)
+		for i := range pods {
+			names[pods[i].Status.PodIP] = pods[i].Name
+		}
+		reconciler.recorder.Eventf(r, corev1.EventTypeNormal, reason,
+			"Reconfigured %s as replicas of %s", podNamesOf(reconfigured, names), upstream)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range pods {
+		if pods[i].Labels[roleLabelKey] == replicaLabel {
+			continue
+		}
+		pod := pods[i].DeepCopy()
+		podPatch := client.MergeFrom(pods[i].DeepCopy())
+		if pod.Labels == nil {
+			pod.Labels = make(map[string]string)
+		}
+		pod.Labels[roleLabelKey] = replicaLabel
+		if err := reconciler.client.Patch(ctx, pod, podPatch); err != nil && !errors.IsConflict(err) {
+			return 0, fmt.Errorf("failed to update Pod: %s", err)
+		}
+	}
+
+	// the restarted instances replicate from the upstream master right away
+	options.master = replicationTarget(redisOptions.Hostnames, upstream)
+	if _, err := reconciler.createOrUpdate(ctx, new(corev1.ConfigMap), r, options); err != nil {
+		return 0, err
+	}
+	return linked, nil
+}
+
+// reconcileBlueGreen runs the replication of the Redis taking part in a blue/green deployment until the Redis
+// runs the replication of its own: the green Redis replicates from the blue master until the cutover, then
+// hands the master role over to the green master. The demoted blue Redis replicates from the green master.
+// done is false if the replication is left to the caller.
+func (reconciler *ReconcileRedis) reconcileBlueGreen(
+	ctx context.Context,
+	fetchedRedis, r, peer *k8sv1alpha1.Redis,
+	pods []corev1.Pod,
+	addresses []redis.Address,
+	options objectGeneratorOptions,
+	redisOptions redis.Options,
+) (result reconcile.Result, done bool, err error) {
+	status := fetchedRedis.Status.DeepCopy()
+	current := status.BlueGreen
+
+	switch {
+	case r.Spec.BlueGreen != nil && current != nil && current.Phase == k8sv1alpha1.BlueGreenCutOver:
+		if peer == nil {
+			return reconcile.Result{}, false, nil
+		}
+		blueMaster, err := reconciler.peerPodAddress(ctx, peer, current.Master)
+		if err != nil || blueMaster == (redis.Address{}) {
+			return reconcile.Result{}, false, err
+		}
+		redisOptions.Hostnames = withPeerHostname(r, peer, redisOptions.Hostnames, current.Master, blueMaster,
+			reconciler.options.ClusterDomain)
+		greenMaster, err := redis.Cutover(ctx, redisOptions, blueMaster, addresses...)
+		if err == redis.ErrNotInSync {
+			log.Info("Waiting for the green instances to catch up with the blue master", "Namespace", r.GetNamespace(),
+				"Redis", r.GetName())
+			return reconcile.Result{RequeueAfter: handoverRequeueDelay}, true, nil
+		}
+		if err != nil {
+			return reconcile.Result{}, true, fmt.Errorf("error cutting over from the blue master: %s", err)
+		}
+		if greenMaster == (redis.Address{}) {
+			return reconcile.Result{}, false, nil
+		}
+		names := make(map[string]string)
+		for i := range pods {
+			names[pods[i].Status.PodIP] = pods[i].Name
+		}
+		reconciler.recorder.Eventf(fetchedRedis, corev1.EventTypeNormal, k8sv1alpha1.ReasonCutOver,
+			"Handed the master role over from %s of %s to %s", current.Master, peer.GetName(),
+			podNamesOf([]redis.Address{greenMaster}, names))
+		// the replication is settled by the next reconciliation
+		return reconcile.Result{Requeue: true}, true, nil
+
+	case r.Spec.BlueGreen != nil:
+		if peer == nil {
+			return reconcile.Result{}, true, fmt.Errorf("blue Redis %s is not found", r.Spec.BlueGreen.Blue)
+		}
+		blueMaster, err := reconciler.peerPodAddress(ctx, peer, peer.Status.Master)
+		if err != nil {
+			return reconcile.Result{}, true, err
+		}
+		if blueMaster == (redis.Address{}) {
+			return reconcile.Result{}, true, fmt.Errorf("the master of the blue Redis %s is not ready", peer.GetName())
+		}
+
+		if r.Spec.BlueGreen.Cutover && current != nil && current.Phase == k8sv1alpha1.BlueGreenInSync {
+			// the cutover is recorded ahead, so the green instances never return to the blue master
+			status.BlueGreen = &k8sv1alpha1.BlueGreenStatus{
+				Phase:  k8sv1alpha1.BlueGreenCutOver,
+				Peer:   peer.GetName(),
+				Master: peer.Status.Master,
+			}
+			result = reconcile.Result{Requeue: true}
+		} else {
+			redisOptions.Hostnames = withPeerHostname(r, peer, redisOptions.Hostnames, peer.Status.Master, blueMaster,
+				reconciler.options.ClusterDomain)
+			linked, err := reconciler.follow(ctx, r, pods, addresses, options, redisOptions, blueMaster,
+				k8sv1alpha1.ReasonReplicasReconfigured)
+			if err != nil {
+				return reconcile.Result{}, true, err
+			}
+			status.Replicas = len(addresses)
+			status.Master = ""
+			status.BlueGreen = &k8sv1alpha1.BlueGreenStatus{
+				Phase:  blueGreenPhase(linked, int(*r.Spec.Replicas)),
+				Peer:   peer.GetName(),
+				Master: peer.Status.Master,
+			}
+			status.SetCondition(newCondition(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionTrue,
+				k8sv1alpha1.ReasonReplicationConfigured, fmt.Sprintf("%d instances are replicating from the master %s of %s",
+					linked, peer.Status.Master, peer.GetName())))
+		}
+
+	case demotedFor(r, peer) != nil:
+		greenMaster, err := reconciler.peerPodAddress(ctx, peer, peer.Status.Master)
+		if err != nil {
+			return reconcile.Result{}, true, err
+		}
+		if greenMaster == (redis.Address{}) {
+			// the green master is elected once the cutover is over
+			return reconcile.Result{RequeueAfter: blueGreenRequeueDelay}, true, nil
+		}
+		redisOptions.Hostnames = withPeerHostname(r, peer, redisOptions.Hostnames, peer.Status.Master, greenMaster,
+			reconciler.options.ClusterDomain)
+		linked, err := reconciler.follow(ctx, r, pods, addresses, options, redisOptions, greenMaster, k8sv1alpha1.ReasonDemoted)
+		if err != nil {
+			return reconcile.Result{}, true, err
+		}
+		status.Replicas = len(addresses)
+		status.Master = ""
+		status.BlueGreen = &k8sv1alpha1.BlueGreenStatus{
+			Phase:  k8sv1alpha1.BlueGreenDemoted,
+			Peer:   peer.GetName(),
+			Master: peer.Status.Master,
+		}
+		status.SetCondition(newCondition(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionTrue,
+			k8sv1alpha1.ReasonReplicationConfigured, fmt.Sprintf("%d instances are replicating from the master %s of %s",
+				linked, peer.Status.Master, peer.GetName())))
+
+	default:
+		return reconcile.Result{}, false, nil
+	}
+
+	if result == (reconcile.Result{}) {
+		result = reconcile.Result{RequeueAfter: blueGreenRequeueDelay}
+	}
+	if reflect.DeepEqual(status, &fetchedRedis.Status) {
+		return result, true, nil
+	}
+	fetchedRedis.Status = *status
+	if err := reconciler.client.Status().Update(ctx, fetchedRedis); err != nil {
+		if errors.IsConflict(err) {
+			return reconcile.Result{Requeue: true}, true, nil
+		}
+		return reconcile.Result{}, true, fmt.Errorf("failed to update Redis status: %s", err)
+	}
+	return result, true, nil
+}
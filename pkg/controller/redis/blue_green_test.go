@@ -0,0 +1,99 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func Test_blueGreenPhase(t *testing.T) {
+	tests := []struct {
+		name    string
+		linked  int
+		desired int
+		want    k8sv1alpha1.BlueGreenPhase
+	}{
+		{"none linked", 0, 3, k8sv1alpha1.BlueGreenReplicating},
+		{"some linked", 2, 3, k8sv1alpha1.BlueGreenReplicating},
+		{"all linked", 3, 3, k8sv1alpha1.BlueGreenInSync},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blueGreenPhase(tt.linked, tt.desired); got != tt.want {
+				t.Errorf("blueGreenPhase() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_demotedFor(t *testing.T) {
+	blue := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "blue"}}
+	green := func(phase k8sv1alpha1.BlueGreenPhase) *k8sv1alpha1.Redis {
+		r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "green"}}
+		r.Spec.BlueGreen = &k8sv1alpha1.BlueGreen{Blue: "blue", Cutover: true}
+		if phase != "" {
+			r.Status.BlueGreen = &k8sv1alpha1.BlueGreenStatus{Phase: phase, Peer: "blue"}
+		}
+		return r
+	}
+	tests := []struct {
+		name string
+		r    *k8sv1alpha1.Redis
+		peer *k8sv1alpha1.Redis
+		want bool
+	}{
+		{"no peer", blue, nil, false},
+		{"green not started", blue, green(""), false},
+		{"green in sync", blue, green(k8sv1alpha1.BlueGreenInSync), false},
+		{"green cut over", blue, green(k8sv1alpha1.BlueGreenCutOver), true},
+		{"green itself", green(k8sv1alpha1.BlueGreenCutOver), green(k8sv1alpha1.BlueGreenCutOver), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := demotedFor(tt.r, tt.peer) != nil; got != tt.want {
+				t.Errorf("demotedFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_redirectMasterService(t *testing.T) {
+	blue := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{
+		Name:   "blue",
+		Labels: map[string]string{redisName: "blue"},
+	}}
+	green := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "green"}}
+	green.Spec.Port = 6380
+
+	service := generateObject(blue, new(corev1.Service), objectGeneratorOptions{
+		serviceType:    serviceTypeMaster,
+		masterRedirect: green,
+	}).(*corev1.Service)
+	if got := service.Spec.Selector; len(got) != 2 || got[redisName] != "green" || got[roleLabelKey] != masterLabel {
+		t.Errorf("selector = %v, want the green master", got)
+	}
+	if got := service.Spec.Ports[0].TargetPort; got != intstr.FromInt(6380) {
+		t.Errorf("target port = %v, want %d", got.String(), 6380)
+	}
+	if got := service.Labels[redisName]; got != "blue" {
+		t.Errorf("label %s = %q, want blue", redisName, got)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// apiBudgetRequeueDelay is the delay before the reconciliation that ran out of the API request budget is resumed
+	apiBudgetRequeueDelay = 5 * time.Second
+)
+
+var (
+	// errAPIBudgetExceeded is returned by the budgeted client once the reconciliation has used up its budget
+	errAPIBudgetExceeded = errors.New("API request budget of the reconciliation is exceeded")
+
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_operator_api_requests_total",
+		Help: "Total number of write requests to the Kubernetes API by controller and verb",
+	}, []string{"controller", "verb"})
+	reconcileAPIRequests = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_operator_reconcile_api_requests",
+		Help:    "Number of write requests to the Kubernetes API per reconciliation",
+		Buckets: []float64{0, 1, 2, 5, 10, 20, 50, 100},
+	}, []string{"controller"})
+	apiBudgetExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_operator_api_budget_exceeded_total",
+		Help: "Total number of reconciliations postponed because of the exceeded API request budget",
+	}, []string{"controller"})
+	reconcileFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_operator_reconcile_failures_total",
+		Help: "Total number of failed reconciliations by controller and reason",
+	}, []string{"controller", "reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(apiRequests, reconcileAPIRequests, apiBudgetExceeded, reconcileFailures)
+}
+
+// budgetedClient counts the write requests made during a single reconciliation and refuses
+// the requests above the budget. Reads are served from the cache and are not counted.
+type budgetedClient struct {
+	client.Client
+	controller string
+	// budget is the maximum number of write requests, unlimited if not positive
+	budget int
+
+	// Pods are patched concurrently
+	mu       sync.Mutex
+	used     int
+	exceeded bool
+}
+
+// newBudgetedClient wraps c with the budget of the write requests for a single reconciliation
+func newBudgetedClient(c client.Client, controller string, budget int) *budgetedClient {
+	return &budgetedClient{Client: c, controller: controller, budget: budget}
+}
+
+// spend accounts a request and reports errAPIBudgetExceeded if the budget has been used up
+func (c *budgetedClient) spend(verb string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.budget > 0 && c.used >= c.budget {
+		c.exceeded = true
+		return errAPIBudgetExceeded
+	}
+	c.used++
+	apiRequests.WithLabelValues(c.controller, verb).Inc()
+	return nil
+}
+
+// done records the number of requests made during the reconciliation
+// and reports whether any request has been refused because of the exceeded budget
+func (c *budgetedClient) done() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reconcileAPIRequests.WithLabelValues(c.controller).Observe(float64(c.used))
+	if c.exceeded {
+		apiBudgetExceeded.WithLabelValues(c.controller).Inc()
+	}
+	return c.exceeded
+}
+
+func (c *budgetedClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if err := c.spend("create"); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *budgetedClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	if err := c.spend("delete"); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *budgetedClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if err := c.spend("update"); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *budgetedClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.spend("patch"); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *budgetedClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	if err := c.spend("deletecollection"); err != nil {
+		return err
+	}
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *budgetedClient) Status() client.StatusWriter {
+	return &budgetedStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+// budgetedStatusWriter spends the budget of the client on the status updates
+type budgetedStatusWriter struct {
+	client.StatusWriter
+	client *budgetedClient
+}
+
+func (w *budgetedStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if err := w.client.spend("update-status"); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *budgetedStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.client.spend("patch-status"); err != nil {
+		return err
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import "testing"
+
+func Test_budgetedClient_spend(t *testing.T) {
+	tests := []struct {
+		name         string
+		budget       int
+		requests     int
+		wantRefused  int
+		wantExceeded bool
+	}{
+		{"unlimited", 0, 100, 0, false},
+		{"within", 5, 5, 0, false},
+		{"exceeded", 5, 8, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newBudgetedClient(nil, "test", tt.budget)
+			refused := 0
+			for i := 0; i < tt.requests; i++ {
+				if err := c.spend("update"); err != nil {
+					if err != errAPIBudgetExceeded {
+						t.Fatalf("spend() unexpected error = %v", err)
+					}
+					refused++
+				}
+			}
+			if refused != tt.wantRefused {
+				t.Errorf("spend() refused = %v, want %v", refused, tt.wantRefused)
+			}
+			if got := c.done(); got != tt.wantExceeded {
+				t.Errorf("done() = %v, want %v", got, tt.wantExceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// the values the API server defaults the Pod template fields to
+const (
+	defaultTerminationGracePeriodSeconds = int64(corev1.DefaultTerminationGracePeriodSeconds)
+	defaultVolumeMode                    = corev1.SecretVolumeSourceDefaultMode
+	defaultProbeTimeoutSeconds           = 1
+	defaultProbePeriodSeconds            = 10
+	defaultProbeSuccessThreshold         = 1
+	defaultProbeFailureThreshold         = 3
+)
+
+// canonicalPodTemplate returns a copy of the generated Pod template with the fields the API server defaults
+// filled in with their defaults, i.e. the intent of the template regardless of whether the defaults are spelled out.
+// The Pod templates differing in the spelled out defaults only are canonically the same, so they neither change
+// the revision hash nor count as a drift of the StatefulSet.
+func canonicalPodTemplate(template corev1.PodTemplateSpec) corev1.PodTemplateSpec {
+	canonical := *template.DeepCopy()
+	spec := &canonical.Spec
+	if spec.RestartPolicy == "" {
+		spec.RestartPolicy = corev1.RestartPolicyAlways
+	}
+	if spec.DNSPolicy == "" {
+		spec.DNSPolicy = corev1.DNSClusterFirst
+	}
+	if spec.SchedulerName == "" {
+		spec.SchedulerName = corev1.DefaultSchedulerName
+	}
+	if spec.TerminationGracePeriodSeconds == nil {
+		spec.TerminationGracePeriodSeconds = &[]int64{defaultTerminationGracePeriodSeconds}[0]
+	}
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = new(corev1.PodSecurityContext)
+	}
+	for i := range spec.InitContainers {
+		canonicalContainer(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		canonicalContainer(&spec.Containers[i])
+	}
+	for i := range spec.Volumes {
+		canonicalVolume(&spec.Volumes[i])
+	}
+	return canonical
+}
+
+// canonicalContainer fills in the defaults of the container fields
+func canonicalContainer(container *corev1.Container) {
+	if container.TerminationMessagePath == "" {
+		container.TerminationMessagePath = corev1.TerminationMessagePathDefault
+	}
+	if container.TerminationMessagePolicy == "" {
+		container.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+	}
+	if container.ImagePullPolicy == "" {
+		container.ImagePullPolicy = defaultPullPolicy(container.Image)
+	}
+	for i := range container.Ports {
+		if container.Ports[i].Protocol == "" {
+			container.Ports[i].Protocol = corev1.ProtocolTCP
+		}
+	}
+	for i := range container.Env {
+		if from := container.Env[i].ValueFrom; from != nil && from.FieldRef != nil && from.FieldRef.APIVersion == "" {
+			from.FieldRef.APIVersion = "v1"
+		}
+	}
+	for _, probe := range []*corev1.Probe{container.LivenessProbe, container.ReadinessProbe, container.StartupProbe} {
+		canonicalProbe(probe)
+	}
+}
+
+// canonicalProbe fills in the defaults of the probe fields
+func canonicalProbe(probe *corev1.Probe) {
+	if probe == nil {
+		return
+	}
+	if probe.TimeoutSeconds == 0 {
+		probe.TimeoutSeconds = defaultProbeTimeoutSeconds
+	}
+	if probe.PeriodSeconds == 0 {
+		probe.PeriodSeconds = defaultProbePeriodSeconds
+	}
+	if probe.SuccessThreshold == 0 {
+		probe.SuccessThreshold = defaultProbeSuccessThreshold
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = defaultProbeFailureThreshold
+	}
+	if probe.HTTPGet != nil && probe.HTTPGet.Scheme == "" {
+		probe.HTTPGet.Scheme = corev1.URISchemeHTTP
+	}
+}
+
+// canonicalVolume fills in the default mode of the files projected into the volume
+func canonicalVolume(volume *corev1.Volume) {
+	mode := func(defaultMode **int32) {
+		if *defaultMode == nil {
+			*defaultMode = &[]int32{defaultVolumeMode}[0]
+		}
+	}
+	switch {
+	case volume.Secret != nil:
+		mode(&volume.Secret.DefaultMode)
+	case volume.ConfigMap != nil:
+		mode(&volume.ConfigMap.DefaultMode)
+	case volume.Projected != nil:
+		mode(&volume.Projected.DefaultMode)
+	case volume.DownwardAPI != nil:
+		mode(&volume.DownwardAPI.DefaultMode)
+	}
+}
+
+// defaultPullPolicy returns the image pull policy the API server defaults the container of the image to:
+// Always for the latest tag, implied by the image with neither a tag nor a digest, IfNotPresent otherwise
+func defaultPullPolicy(image string) corev1.PullPolicy {
+	name := strings.SplitN(image, "@", 2)[0]
+	var tag string
+	if slash, colon := strings.LastIndex(name, "/"), strings.LastIndex(name, ":"); colon > slash {
+		tag = name[colon+1:]
+	}
+	if tag == "latest" || tag == "" && name == image {
+		return corev1.PullAlways
+	}
+	return corev1.PullIfNotPresent
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_defaultPullPolicy(t *testing.T) {
+	tests := []struct {
+		image string
+		want  corev1.PullPolicy
+	}{
+		{"redis", corev1.PullAlways},
+		{"redis:latest", corev1.PullAlways},
+		{"redis:6.2", corev1.PullIfNotPresent},
+		{"registry:5000/redis", corev1.PullAlways},
+		{"registry:5000/redis:6.2", corev1.PullIfNotPresent},
+		{"redis@sha256:0123", corev1.PullIfNotPresent},
+		{"redis:latest@sha256:0123", corev1.PullAlways},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := defaultPullPolicy(tt.image); got != tt.want {
+				t.Errorf("defaultPullPolicy() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_canonicalPodTemplate(t *testing.T) {
+	template := generateStatefulSet(&k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"},
+		Spec: k8sv1alpha1.RedisSpec{Redis: k8sv1alpha1.ContainerSpec{Image: "redis:6.2"}}},
+		objectGeneratorOptions{}).Spec.Template
+	original := template.DeepCopy()
+
+	canonical := canonicalPodTemplate(template)
+	if !reflect.DeepEqual(&template, original) {
+		t.Errorf("canonicalPodTemplate() changed the template")
+	}
+	if again := canonicalPodTemplate(canonical); !reflect.DeepEqual(again, canonical) {
+		t.Errorf("canonicalPodTemplate() is not idempotent:\nhave: %+v\nwant: %+v", again, canonical)
+	}
+	container := canonical.Spec.Containers[0]
+	if container.ImagePullPolicy != corev1.PullIfNotPresent ||
+		container.TerminationMessagePolicy != corev1.TerminationMessageReadFile {
+		t.Errorf("canonicalPodTemplate() container = %+v, want the defaults filled in", container)
+	}
+	if canonical.Spec.SecurityContext == nil || canonical.Spec.TerminationGracePeriodSeconds == nil {
+		t.Errorf("canonicalPodTemplate() spec = %+v, want the defaults filled in", canonical.Spec)
+	}
+}
+
+func Test_statefulSetUpdateNeeded_canonical(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: k8sv1alpha1.RedisSpec{
+		Replicas: &[]int32{3}[0],
+		Redis: k8sv1alpha1.ContainerSpec{
+			Image:     "redis:6.2",
+			Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+		},
+	}}
+	// got is the StatefulSet stored by the API server: the Pod template defaulted and the quantities canonical
+	stored := func() *appsv1.StatefulSet {
+		s := generateStatefulSet(r, objectGeneratorOptions{})
+		s.Spec.Template = canonicalPodTemplate(s.Spec.Template)
+		return s
+	}
+	tests := []struct {
+		name   string
+		got    func() *appsv1.StatefulSet
+		want   func() *appsv1.StatefulSet
+		needed bool
+	}{
+		{"unchanged", stored, func() *appsv1.StatefulSet { return generateStatefulSet(r, objectGeneratorOptions{}) }, false},
+		{
+			"defaults spelled out",
+			stored,
+			func() *appsv1.StatefulSet {
+				s := generateStatefulSet(r, objectGeneratorOptions{})
+				s.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+				s.Spec.Template.Spec.DNSPolicy = corev1.DNSClusterFirst
+				setRevisionHash(s)
+				return s
+			},
+			false,
+		},
+		{
+			"quantity spelled differently",
+			stored,
+			func() *appsv1.StatefulSet {
+				s := generateStatefulSet(r, objectGeneratorOptions{})
+				s.Spec.Template.Spec.Containers[0].Resources.Limits[corev1.ResourceCPU] = resource.MustParse("1000m")
+				return s
+			},
+			false,
+		},
+		{
+			"default edited",
+			func() *appsv1.StatefulSet {
+				s := stored()
+				s.Spec.Template.Spec.Containers[0].TerminationMessagePolicy = corev1.TerminationMessageFallbackToLogsOnError
+				return s
+			},
+			func() *appsv1.StatefulSet { return generateStatefulSet(r, objectGeneratorOptions{}) },
+			true,
+		},
+		{
+			"Pod template changed",
+			stored,
+			func() *appsv1.StatefulSet {
+				s := generateStatefulSet(r, objectGeneratorOptions{})
+				s.Spec.Template.Spec.Containers[0].Args = append(s.Spec.Template.Spec.Containers[0].Args, "--loglevel", "debug")
+				setRevisionHash(s)
+				return s
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if needed := statefulSetUpdateNeeded(tt.got(), tt.want()); needed != tt.needed {
+				t.Errorf("statefulSetUpdateNeeded() = %v, want %v", needed, tt.needed)
+			}
+		})
+	}
+}
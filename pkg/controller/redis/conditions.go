@@ -0,0 +1,178 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+)
+
+// statusUpdateTimeout bounds the status update of the reconciliation that has run out of time
+const statusUpdateTimeout = 10 * time.Second
+
+// persistenceCondition builds the PersistenceFailing condition out of the failed persistence statuses.
+// podNames maps instance hosts to the corresponding Pod names.
+func persistenceCondition(failures map[redis.Address][]string, podNames map[string]string) k8sv1alpha1.Condition {
+	if len(failures) == 0 {
+		return k8sv1alpha1.Condition{
+			Type:    k8sv1alpha1.ConditionPersistenceFailing,
+			Status:  corev1.ConditionFalse,
+			Reason:  k8sv1alpha1.ReasonPersistenceOK,
+			Message: "RDB and AOF writes succeed on all instances",
+		}
+	}
+
+	messages := make([]string, 0, len(failures))
+	for address, statuses := range failures {
+		name, ok := podNames[address.Host]
+		if !ok {
+			name = address.String()
+		}
+		messages = append(messages, fmt.Sprintf("%s: %s", name, strings.Join(statuses, ",")))
+	}
+	sort.Strings(messages)
+
+	return k8sv1alpha1.Condition{
+		Type:    k8sv1alpha1.ConditionPersistenceFailing,
+		Status:  corev1.ConditionTrue,
+		Reason:  k8sv1alpha1.ReasonPersistenceError,
+		Message: strings.Join(messages, "; "),
+	}
+}
+
+// newCondition is a shorthand for the condition with the status and the reason
+func newCondition(
+	conditionType k8sv1alpha1.ConditionType,
+	status corev1.ConditionStatus,
+	reason, message string,
+) k8sv1alpha1.Condition {
+	return k8sv1alpha1.Condition{Type: conditionType, Status: status, Reason: reason, Message: message}
+}
+
+// degradedCondition builds the Degraded condition out of the number of ready and desired instances.
+// The instances loading the dataset or busy are reported apart from the unavailable ones.
+func degradedCondition(ready int, notReady []redis.Address, podNames map[string]string, desired *int32) k8sv1alpha1.Condition {
+	// the StatefulSet defaults to a single replica
+	want := 1
+	if desired != nil {
+		want = int(*desired)
+	}
+	if ready >= want {
+		return newCondition(k8sv1alpha1.ConditionDegraded, corev1.ConditionFalse, k8sv1alpha1.ReasonAllInstancesReady,
+			fmt.Sprintf("%d of %d instances are ready", ready, want))
+	}
+	if len(notReady) > 0 {
+		return newCondition(k8sv1alpha1.ConditionDegraded, corev1.ConditionTrue, k8sv1alpha1.ReasonInstancesNotReady,
+			fmt.Sprintf("%d of %d instances are ready, %s loading the dataset or busy", ready, want,
+				podNamesOf(notReady, podNames)))
+	}
+	return newCondition(k8sv1alpha1.ConditionDegraded, corev1.ConditionTrue, k8sv1alpha1.ReasonInstancesUnavailable,
+		fmt.Sprintf("%d of %d instances are ready", ready, want))
+}
+
+// readyCondition derives the Ready condition from the other conditions in status.
+// The Redis is ready once the config is valid, the replication is configured, the master is elected
+// and all the instances are ready. Otherwise the first unmet condition is reported as the reason.
+func readyCondition(status *k8sv1alpha1.RedisStatus) k8sv1alpha1.Condition {
+	for _, required := range []struct {
+		conditionType k8sv1alpha1.ConditionType
+		status        corev1.ConditionStatus
+	}{
+		{k8sv1alpha1.ConditionConfigInvalid, corev1.ConditionFalse},
+		{k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionTrue},
+		{k8sv1alpha1.ConditionMasterElected, corev1.ConditionTrue},
+		{k8sv1alpha1.ConditionDegraded, corev1.ConditionFalse},
+	} {
+		condition := status.GetCondition(required.conditionType)
+		if condition == nil {
+			return newCondition(k8sv1alpha1.ConditionReady, corev1.ConditionFalse, k8sv1alpha1.ReasonNotReady,
+				fmt.Sprintf("%s is not observed yet", required.conditionType))
+		}
+		if condition.Status != required.status {
+			return newCondition(k8sv1alpha1.ConditionReady, corev1.ConditionFalse, condition.Reason, condition.Message)
+		}
+	}
+	return newCondition(k8sv1alpha1.ConditionReady, corev1.ConditionTrue, k8sv1alpha1.ReasonReady, "Redis is ready")
+}
+
+// reportFailure reports the failed reconciliation with the reason of the condition: the condition
+// along with the derived Ready condition is set in the Redis status, the Warning Event is emitted,
+// the failure is logged and counted. The related conditions are set along without being reported.
+// The failure to update the status is only logged since the reconciliation error is more relevant.
+func (reconciler *ReconcileRedis) reportFailure(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	condition k8sv1alpha1.Condition,
+	related ...k8sv1alpha1.Condition,
+) {
+	log.Info("Reconciliation failed", "Namespace", r.GetNamespace(), "Redis", r.GetName(),
+		"reason", condition.Reason, "message", condition.Message)
+	reconcileFailures.WithLabelValues("redis", condition.Reason).Inc()
+	reconciler.recorder.Event(r, corev1.EventTypeWarning, condition.Reason, condition.Message)
+
+	status := r.Status.DeepCopy()
+	status.SetCondition(condition)
+	for _, relatedCondition := range related {
+		status.SetCondition(relatedCondition)
+	}
+	status.SetCondition(readyCondition(status))
+	if reflect.DeepEqual(status, &r.Status) {
+		return
+	}
+
+	updated := r.DeepCopy()
+	updated.Status = *status
+	if err := reconciler.client.Status().Update(ctx, updated); err != nil {
+		log.V(1).Info("Failed to update Redis conditions", "Namespace", r.GetNamespace(), "Redis", r.GetName(), "error", err)
+	}
+}
+
+// reconcileContext returns the context of a single reconciliation bounded by the timeout
+func reconcileContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// reportTimeout sets the ReconcileTimedOut condition of the Redis whose reconciliation has run out of time.
+// The context of the reconciliation is expired, the status is updated within a fresh one.
+func (reconciler *ReconcileRedis) reportTimeout(request reconcile.Request, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), statusUpdateTimeout)
+	defer cancel()
+
+	fetchedRedis := new(k8sv1alpha1.Redis)
+	if err := reconciler.client.Get(ctx, request.NamespacedName, fetchedRedis); err != nil {
+		return
+	}
+
+	message := fmt.Sprintf("reconciliation has not completed within %s", reconciler.options.ReconcileTimeout)
+	if err != nil {
+		message = fmt.Sprintf("%s: %s", message, err)
+	}
+	reconciler.reportFailure(ctx, fetchedRedis,
+		newCondition(k8sv1alpha1.ConditionReconcileTimedOut, corev1.ConditionTrue, k8sv1alpha1.ReasonReconcileTimeout, message))
+}
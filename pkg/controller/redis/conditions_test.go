@@ -0,0 +1,116 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+)
+
+func Test_readyCondition(t *testing.T) {
+	healthy := []k8sv1alpha1.Condition{
+		newCondition(k8sv1alpha1.ConditionConfigInvalid, corev1.ConditionFalse, k8sv1alpha1.ReasonConfigValid, ""),
+		newCondition(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionTrue, k8sv1alpha1.ReasonReplicationConfigured, ""),
+		newCondition(k8sv1alpha1.ConditionMasterElected, corev1.ConditionTrue, k8sv1alpha1.ReasonMasterElected, ""),
+		newCondition(k8sv1alpha1.ConditionDegraded, corev1.ConditionFalse, k8sv1alpha1.ReasonAllInstancesReady, ""),
+	}
+
+	tests := []struct {
+		name       string
+		conditions []k8sv1alpha1.Condition
+		wantStatus corev1.ConditionStatus
+		wantReason string
+	}{
+		{"ready", nil, corev1.ConditionTrue, k8sv1alpha1.ReasonReady},
+		{"config invalid", []k8sv1alpha1.Condition{
+			newCondition(k8sv1alpha1.ConditionConfigInvalid, corev1.ConditionTrue, k8sv1alpha1.ReasonSecretMissing, ""),
+		}, corev1.ConditionFalse, k8sv1alpha1.ReasonSecretMissing},
+		{"no master", []k8sv1alpha1.Condition{
+			newCondition(k8sv1alpha1.ConditionMasterElected, corev1.ConditionFalse, k8sv1alpha1.ReasonNoMaster, ""),
+			newCondition(k8sv1alpha1.ConditionDegraded, corev1.ConditionTrue, k8sv1alpha1.ReasonInstancesUnavailable, ""),
+		}, corev1.ConditionFalse, k8sv1alpha1.ReasonNoMaster},
+		{"degraded", []k8sv1alpha1.Condition{
+			newCondition(k8sv1alpha1.ConditionDegraded, corev1.ConditionTrue, k8sv1alpha1.ReasonInstancesUnavailable, ""),
+		}, corev1.ConditionFalse, k8sv1alpha1.ReasonInstancesUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := new(k8sv1alpha1.RedisStatus)
+			for _, condition := range append(healthy, tt.conditions...) {
+				status.SetCondition(condition)
+			}
+			if got := readyCondition(status); got.Status != tt.wantStatus || got.Reason != tt.wantReason {
+				t.Errorf("readyCondition() = %+v, want %s %s", got, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+
+	t.Run("not observed", func(t *testing.T) {
+		if got := readyCondition(new(k8sv1alpha1.RedisStatus)); got.Status != corev1.ConditionFalse || got.Reason != k8sv1alpha1.ReasonNotReady {
+			t.Errorf("readyCondition() = %+v, want %s %s", got, corev1.ConditionFalse, k8sv1alpha1.ReasonNotReady)
+		}
+	})
+}
+
+func Test_degradedCondition(t *testing.T) {
+	three := int32(3)
+	loading := []redis.Address{{Host: "10.0.0.3", Port: "6379"}}
+	podNames := map[string]string{"10.0.0.3": "redis-example-2"}
+	tests := []struct {
+		name       string
+		ready      int
+		notReady   []redis.Address
+		desired    *int32
+		wantStatus corev1.ConditionStatus
+		wantReason string
+	}{
+		{"all ready", 3, nil, &three, corev1.ConditionFalse, k8sv1alpha1.ReasonAllInstancesReady},
+		{"missing", 2, nil, &three, corev1.ConditionTrue, k8sv1alpha1.ReasonInstancesUnavailable},
+		{"loading", 2, loading, &three, corev1.ConditionTrue, k8sv1alpha1.ReasonInstancesNotReady},
+		{"default replicas", 1, nil, nil, corev1.ConditionFalse, k8sv1alpha1.ReasonAllInstancesReady},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := degradedCondition(tt.ready, tt.notReady, podNames, tt.desired)
+			if got.Status != tt.wantStatus || got.Reason != tt.wantReason {
+				t.Errorf("degradedCondition() = %+v, want %s %s", got, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func Test_reconcileContext(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		timeout      time.Duration
+		wantDeadline bool
+	}{
+		{"disabled", 0, false},
+		{"enabled", time.Minute, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := reconcileContext(tt.timeout)
+			defer cancel()
+			if _, got := ctx.Deadline(); got != tt.wantDeadline {
+				t.Errorf("reconcileContext() deadline set = %v, want %v", got, tt.wantDeadline)
+			}
+		})
+	}
+}
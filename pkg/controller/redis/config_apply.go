@@ -0,0 +1,253 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// configRevisionAnnotationKey is the Pod template annotation changed to restart the Pods once
+// the configuration changes in a way that can not be applied to the running instances
+const configRevisionAnnotationKey = "redis-config-revision"
+
+// configChecksumAnnotationKey is the ConfigMap annotation holding the configRevision of the rendered directives.
+// The ConfigMap is updated once the checksum of its directives differs, the replicaof directive is not a part of it.
+const configChecksumAnnotationKey = "redis-config-checksum"
+
+// staticConfigDirectives can not be changed with CONFIG SET, the instances read them on start only.
+// The directives controlled by the operator are in excludedConfigDirectives.
+var staticConfigDirectives = map[string]struct{}{
+	"supervised":               {},
+	"pidfile":                  {},
+	"unixsocket":               {},
+	"unixsocketperm":           {},
+	"tcp-backlog":              {},
+	"logfile":                  {},
+	"syslog-enabled":           {},
+	"syslog-ident":             {},
+	"syslog-facility":          {},
+	"databases":                {},
+	"always-show-logo":         {},
+	"set-proc-title":           {},
+	"proc-title-template":      {},
+	"io-threads":               {},
+	"io-threads-do-reads":      {},
+	"appendfilename":           {},
+	"appenddirname":            {},
+	"disable-thp":              {},
+	"cluster-enabled":          {},
+	"cluster-config-file":      {},
+	"cluster-port":             {},
+	"enable-protected-configs": {},
+	"enable-debug-command":     {},
+	"enable-module-command":    {},
+	"loadmodule":               {},
+}
+
+// parseConfig returns the directives of redis.conf by their lower case names along with the arguments
+// joined by spaces the way CONFIG SET takes them. replicaof is omitted, it is set by the operator at runtime.
+func parseConfig(config string) map[string]string {
+	directives := make(map[string]string)
+	for _, line := range strings.Split(config, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		args, err := k8sv1alpha1.SplitConfigArgs(line)
+		if err != nil || len(args) == 0 {
+			continue
+		}
+		if name := strings.ToLower(args[0]); name != "replicaof" {
+			directives[name] = strings.Join(args[1:], " ")
+		}
+	}
+	return directives
+}
+
+// configChanges returns the directives of the wanted configuration that differ from the applied one
+// and whether the changes require the restart: the static directives and those controlled by the operator
+// are read on start only, and the removed directives are reset to their defaults on restart only.
+func configChanges(applied, wanted map[string]string) (changed map[string]string, restart bool) {
+	changed = make(map[string]string)
+	for name, value := range wanted {
+		if current, ok := applied[name]; ok && current == value {
+			continue
+		}
+		changed[name] = value
+		if _, ok := staticConfigDirectives[name]; ok {
+			restart = true
+		}
+		if _, ok := excludedConfigDirectives[name]; ok {
+			restart = true
+		}
+	}
+	for name := range applied {
+		if _, ok := wanted[name]; !ok {
+			restart = true
+		}
+	}
+	return changed, restart
+}
+
+// configRevision identifies the configuration the Pods are restarted with
+func configRevision(config map[string]string) string {
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	for _, name := range names {
+		_, _ = fmt.Fprintf(hash, "%s %s\n", name, config[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// liveAuthDirectives of the authentication configuration are applied to the running instances:
+// the password is rotated and the ACL users are set without restarting the Pods
+var liveAuthDirectives = map[string]struct{}{
+	"requirepass": {},
+	"masterauth":  {},
+	"masteruser":  {},
+	"user":        {},
+}
+
+// configHash identifies the configuration the Pods have to be restarted for: the configRevision of the directives
+// read on start only and the directives of the auth Secret read on start only, e.g. aclfile. The password and
+// the ACL users are applied to the running instances and are not a part of it. Empty if there is neither.
+func configHash(configRevision string, secret *corev1.Secret) string {
+	var names []string
+	var directives map[string]string
+	if secret != nil {
+		directives = parseConfig(string(secret.Data[secretFileName]))
+		for name := range directives {
+			if _, ok := liveAuthDirectives[name]; !ok {
+				names = append(names, name)
+			}
+		}
+	}
+	if configRevision == "" && len(names) == 0 {
+		return ""
+	}
+
+	sort.Strings(names)
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "%s\n", configRevision)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(hash, "%s %s\n", name, directives[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// applyConfig applies the changed configuration directives to the running instances with CONFIG SET
+// before the ConfigMap is updated, so the interrupted apply is retried until the ConfigMap is updated.
+// It returns the configuration revision the ConfigMap and the Pod template are annotated with: the current
+// one unless the changes require the restart, in which case the Pods are rolled out the way the StatefulSet
+// is updated. The revision is kept in the ConfigMap along with the configuration, so the restart is not lost
+// if the StatefulSet is not updated right away. The Pods of a new Redis start with the revision of the initial
+// configuration, those created before the revisions were introduced have none until the first such change.
+// The auth Secret is not a part of the revision, the password changes are applied to the running instances.
+func (reconciler *ReconcileRedis) applyConfig(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	options objectGeneratorOptions,
+	tlsConfig *tls.Config,
+) (string, error) {
+	configMap := new(corev1.ConfigMap)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateName(r)}, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return configRevision(parseConfig(generateConfigMap(r, options.master).Data[configFileName])), nil
+		}
+		return "", fmt.Errorf("failed to fetch ConfigMap: %s", err)
+	}
+	revision := configMap.GetAnnotations()[configRevisionAnnotationKey]
+	applied := parseConfig(configMap.Data[configFileName])
+	wanted := parseConfig(generateConfigMap(r, options.master).Data[configFileName])
+	changed, restart := configChanges(applied, wanted)
+	// the changed directives are not replayed with the values of the former master once the master changes
+	reconciler.runtimeConfig.update(types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()}, applied, wanted)
+	if restart {
+		return configRevision(wanted), nil
+	}
+	if len(changed) == 0 {
+		return revision, nil
+	}
+
+	addresses, err := reconciler.readyAddresses(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	// the operator user is authenticated with the password once the default user is disabled
+	var username string
+	if defaultUserDisabled(r) || options.defaultUserDisabled {
+		username = redis.OperatorUser
+	}
+	if err := redis.ApplyConfig(ctx, redis.Options{
+		Password:   options.password,
+		Username:   username,
+		TLSConfig:  tlsConfig,
+		ClientName: reconciler.options.RedisClientName,
+		Protocol:   reconciler.options.RedisProtocol,
+	}, changed, addresses...); err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	reconciler.recorder.Eventf(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonConfigApplied,
+		"%s applied to the running instances", strings.Join(names, ", "))
+	return revision, nil
+}
+
+// readyAddresses returns the addresses of the ready Pods of the Redis along with the node-local caches
+func (reconciler *ReconcileRedis) readyAddresses(ctx context.Context, r *k8sv1alpha1.Redis) ([]redis.Address, error) {
+	podList := new(corev1.PodList)
+	if err := reconciler.client.List(ctx, podList, client.InNamespace(r.GetNamespace()),
+		client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(r.Labels)}); err != nil {
+		return nil, fmt.Errorf("failed to list Pods: %s", err)
+	}
+	cachePods, err := reconciler.nodeLocalCachePods(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	members, _ := partitionMembers(r, podList.Items)
+	pods := append(members, cachePods...)
+
+	var addresses []redis.Address
+	for i := range pods {
+		if podReady(&pods[i]) {
+			addresses = append(addresses, redis.Address{Host: pods[i].Status.PodIP, Port: strconv.Itoa(redisPort(r))})
+		}
+	}
+	return addresses, nil
+}
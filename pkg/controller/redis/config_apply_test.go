@@ -0,0 +1,61 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseConfig(t *testing.T) {
+	config := "# Generated by redis-operator for redis.k8s.amaiz.com/test\ndir /data\n" +
+		"SAVE \"900 1\" 300 10\nmaxmemory-policy allkeys-lru\nreplicaof 172.18.0.2 6379\n"
+	want := map[string]string{"dir": "/data", "save": "900 1 300 10", "maxmemory-policy": "allkeys-lru"}
+	if got := parseConfig(config); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfig() = %v, want %v", got, want)
+	}
+}
+
+func Test_configChanges(t *testing.T) {
+	applied := map[string]string{"dir": "/data", "maxmemory": "1gb", "io-threads": "2"}
+	tests := []struct {
+		name        string
+		wanted      map[string]string
+		wantChanged map[string]string
+		wantRestart bool
+	}{
+		{"unchanged", map[string]string{"dir": "/data", "maxmemory": "1gb", "io-threads": "2"}, map[string]string{}, false},
+		{"dynamic changed", map[string]string{"dir": "/data", "maxmemory": "2gb", "io-threads": "2"},
+			map[string]string{"maxmemory": "2gb"}, false},
+		{"dynamic added", map[string]string{"dir": "/data", "maxmemory": "1gb", "io-threads": "2", "hz": "20"},
+			map[string]string{"hz": "20"}, false},
+		{"static changed", map[string]string{"dir": "/data", "maxmemory": "1gb", "io-threads": "4"},
+			map[string]string{"io-threads": "4"}, true},
+		{"operator changed", map[string]string{"dir": "/var/lib/redis", "maxmemory": "1gb", "io-threads": "2"},
+			map[string]string{"dir": "/var/lib/redis"}, true},
+		{"removed", map[string]string{"dir": "/data", "io-threads": "2"}, map[string]string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, restart := configChanges(applied, tt.wanted)
+			if !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("configChanges() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if restart != tt.wantRestart {
+				t.Errorf("configChanges() restart = %v, want %v", restart, tt.wantRestart)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"strconv"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the keys of the connection info ConfigMap
+const (
+	connectionInfoMasterHostKey  = "masterHost"
+	connectionInfoReplicaHostKey = "replicaHost"
+	connectionInfoPortKey        = "port"
+	connectionInfoTLSKey         = "tls"
+)
+
+// generateConnectionInfoName returns the name of the connection info ConfigMap
+func generateConnectionInfoName(r *k8sv1alpha1.Redis) string {
+	return fmt.Sprintf("%s-connection", generateName(r))
+}
+
+// serviceFQDN returns the DNS name of the Service in the namespace of the Redis
+func serviceFQDN(r *k8sv1alpha1.Redis, service, clusterDomain string) string {
+	return fmt.Sprintf("%s.%s.svc.%s", service, r.GetNamespace(), clusterDomain)
+}
+
+// generateConnectionInfo returns the ConfigMap the clients connect to the Redis by: the DNS names of the master
+// and the replica Services, the port and whether TLS is enabled. It is regenerated along with the Services.
+func generateConnectionInfo(r *k8sv1alpha1.Redis, clusterDomain string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generateConnectionInfoName(r),
+			Namespace: r.GetNamespace(),
+			Labels:    r.GetLabels(),
+		},
+		Data: map[string]string{
+			connectionInfoMasterHostKey:  serviceFQDN(r, generateMasterServiceName(r), clusterDomain),
+			connectionInfoReplicaHostKey: serviceFQDN(r, generateReplicaServiceName(r), clusterDomain),
+			connectionInfoPortKey:        strconv.Itoa(redisPort(r)),
+			connectionInfoTLSKey:         strconv.FormatBool(r.Spec.TLS != nil),
+		},
+	}
+}
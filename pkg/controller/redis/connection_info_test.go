@@ -0,0 +1,79 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_generateConnectionInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		spec k8sv1alpha1.RedisSpec
+		want map[string]string
+	}{
+		{
+			"default",
+			k8sv1alpha1.RedisSpec{},
+			map[string]string{
+				"masterHost":  "redis-example-master.default.svc.cluster.local",
+				"replicaHost": "redis-example-replica.default.svc.cluster.local",
+				"port":        "6379",
+				"tls":         "false",
+			},
+		},
+		{
+			"TLS on a custom port",
+			k8sv1alpha1.RedisSpec{Port: 7000, TLS: &k8sv1alpha1.TLS{SecretName: "redis-tls"}},
+			map[string]string{
+				"masterHost":  "redis-example-master.default.svc.cluster.local",
+				"replicaHost": "redis-example-replica.default.svc.cluster.local",
+				"port":        "7000",
+				"tls":         "true",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}, Spec: tt.spec}
+			got := generateConnectionInfo(r, defaultClusterDomain)
+			if got.Name != "redis-example-connection" {
+				t.Errorf("generateConnectionInfo() name = %s, want redis-example-connection", got.Name)
+			}
+			if !reflect.DeepEqual(got.Data, tt.want) {
+				t.Errorf("generateConnectionInfo() = %v, want %v", got.Data, tt.want)
+			}
+		})
+	}
+}
+
+func Test_configMapUpdateNeeded_connectionInfo(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}}
+	got := generateConnectionInfo(r, defaultClusterDomain)
+	if configMapUpdateNeeded(got, generateConnectionInfo(r, defaultClusterDomain)) {
+		t.Error("configMapUpdateNeeded() = true for the same connection info")
+	}
+
+	r.Spec.Port = 7000
+	want := generateConnectionInfo(r, defaultClusterDomain)
+	if !configMapUpdateNeeded(got, want) || !reflect.DeepEqual(got.Data, want.Data) {
+		t.Errorf("configMapUpdateNeeded() did not update the port: %v", got.Data)
+	}
+}
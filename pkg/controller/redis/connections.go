@@ -0,0 +1,124 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	goredis "github.com/go-redis/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// connectionKey identifies the pooled client of an instance. The Pods recreated at the same address
+// get new clients, the changed settings, e.g. the rotated password or the renewed certificate, as well.
+type connectionKey struct {
+	uid      types.UID
+	address  string
+	settings string
+}
+
+// connectionPool keeps the clients of the instances across the reconciliations by Redis, so the connections
+// are neither dialed nor authenticated by every reconciliation. The clients of the Pods that are gone and
+// the ones created with the previous settings are closed by prune. It is safe for concurrent use.
+type connectionPool struct {
+	mu      sync.Mutex
+	clients map[types.NamespacedName]map[connectionKey]redis.Client
+}
+
+func newConnectionPool() *connectionPool {
+	return &connectionPool{clients: make(map[types.NamespacedName]map[connectionKey]redis.Client)}
+}
+
+// pooledClient is the client shared by the reconciliations, it is closed by the pool only
+type pooledClient struct {
+	redis.Client
+}
+
+// Close leaves the connections open for the next reconciliation
+func (pooledClient) Close() error { return nil }
+
+// connectionSettings returns the fingerprint of the settings the connections are established with
+func connectionSettings(options redis.Options, tlsCertificate []byte) string {
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "%s\x00%s\x00%s\x00%d\x00", options.Password, options.Username, options.ClientName, options.Protocol)
+	_, _ = hash.Write(tlsCertificate)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// newClient returns the redis.Options.NewClient reusing the clients of the Pods by their IPs.
+// The addresses not belonging to the Pods, e.g. the ones of the node-local caches, get the clients closed as usual.
+func (p *connectionPool) newClient(key types.NamespacedName, pods []corev1.Pod, settings string,
+) func(*goredis.Options) redis.Client {
+	uids := make(map[string]types.UID, len(pods))
+	for i := range pods {
+		uids[pods[i].Status.PodIP] = pods[i].UID
+	}
+
+	return func(options *goredis.Options) redis.Client {
+		host, _, err := net.SplitHostPort(options.Addr)
+		uid, ok := uids[host]
+		if err != nil || !ok {
+			return goredis.NewClient(options)
+		}
+		connection := connectionKey{uid: uid, address: options.Addr, settings: settings}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.clients[key] == nil {
+			p.clients[key] = make(map[connectionKey]redis.Client)
+		}
+		client, ok := p.clients[key][connection]
+		if !ok {
+			client = goredis.NewClient(options)
+			p.clients[key][connection] = client
+		}
+		return pooledClient{Client: client}
+	}
+}
+
+// prune closes the clients of the Redis except the ones of the Pods created with the settings
+func (p *connectionPool) prune(key types.NamespacedName, pods []corev1.Pod, settings string) {
+	current := make(map[types.UID]bool, len(pods))
+	for i := range pods {
+		current[pods[i].UID] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for connection, client := range p.clients[key] {
+		if !current[connection.uid] || connection.settings != settings {
+			_ = client.Close()
+			delete(p.clients[key], connection)
+		}
+	}
+}
+
+// forget closes all the clients of the Redis, e.g. once it is deleted
+func (p *connectionPool) forget(key types.NamespacedName) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, client := range p.clients[key] {
+		_ = client.Close()
+	}
+	delete(p.clients, key)
+}
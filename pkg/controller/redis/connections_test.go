@@ -0,0 +1,102 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	goredis "github.com/go-redis/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// clientClosed reports whether the client has been closed. The port is closed, so the open client fails to dial.
+func clientClosed(client redis.Client) bool {
+	if pooled, ok := client.(pooledClient); ok {
+		client = pooled.Client
+	}
+	err := client.Ping().Err()
+	return err != nil && err.Error() == "redis: client is closed"
+}
+
+func Test_connectionPool(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "redis"}
+	pod := func(uid types.UID) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{UID: uid}, Status: corev1.PodStatus{PodIP: "127.0.0.1"}}
+	}
+	options := func() *goredis.Options { return &goredis.Options{Addr: "127.0.0.1:1"} }
+	pods := []corev1.Pod{pod("a")}
+	pool := newConnectionPool()
+
+	first := pool.newClient(key, pods, "settings")(options())
+	_ = first.Close()
+	second := pool.newClient(key, pods, "settings")(options())
+	if first.(pooledClient).Client != second.(pooledClient).Client {
+		t.Error("newClient() has not reused the client of the Pod")
+	}
+	if clientClosed(first) {
+		t.Error("Close() has closed the pooled client")
+	}
+
+	other := pool.newClient(key, pods, "settings")(&goredis.Options{Addr: "127.0.0.2:1"})
+	if _, ok := other.(pooledClient); ok {
+		t.Error("newClient() has pooled the client of the address not belonging to the Pods")
+	}
+	_ = other.Close()
+
+	pool.prune(key, pods, "settings")
+	if clientClosed(first) {
+		t.Error("prune() has closed the client of the current Pod")
+	}
+
+	rotated := pool.newClient(key, pods, "rotated")(options())
+	pool.prune(key, pods, "rotated")
+	if !clientClosed(first) {
+		t.Error("prune() has not closed the client created with the previous settings")
+	}
+
+	pool.prune(key, []corev1.Pod{pod("b")}, "rotated")
+	if !clientClosed(rotated) {
+		t.Error("prune() has not closed the client of the recreated Pod")
+	}
+
+	recreated := pool.newClient(key, []corev1.Pod{pod("b")}, "rotated")(options())
+	pool.forget(key)
+	if !clientClosed(recreated) {
+		t.Error("forget() has not closed the client")
+	}
+	if len(pool.clients) != 0 {
+		t.Errorf("forget() has left %d Redis", len(pool.clients))
+	}
+}
+
+func Test_connectionSettings(t *testing.T) {
+	options := redis.Options{Password: "secret", Username: "operator"}
+	if connectionSettings(options, nil) != connectionSettings(options, nil) {
+		t.Error("connectionSettings() is not deterministic")
+	}
+	rotated := options
+	rotated.Password = "rotated"
+	if connectionSettings(options, nil) == connectionSettings(rotated, nil) {
+		t.Error("connectionSettings() has not changed with the password")
+	}
+	if connectionSettings(options, nil) == connectionSettings(options, []byte("certificate")) {
+		t.Error("connectionSettings() has not changed with the certificate")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kinds of the backups referred by the status
+const (
+	redisBackupKind = "RedisBackup"
+	jobKind         = "Job"
+)
+
+// snapshotSize returns the size of the snapshot the upload container of the backup Pods terminated with,
+// 0 if none of the Pods has uploaded it
+func snapshotSize(pods []corev1.Pod) int64 {
+	for i := range pods {
+		for _, status := range pods[i].Status.ContainerStatuses {
+			if status.Name != uploadContainerName || status.State.Terminated == nil || status.State.Terminated.ExitCode != 0 {
+				continue
+			}
+			for _, line := range strings.Split(status.State.Terminated.Message, "\n") {
+				kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+				if len(kv) != 2 || kv[0] != snapshotSizeKey {
+					continue
+				}
+				if size, err := strconv.ParseInt(kv[1], 10, 64); err == nil {
+					return size
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// checkDataProtection cross-links the latest finished backups and restores into the status.
+// The status is left intact unless the backups or the restore are configured,
+// hence the restore is still reported once spec.restore is removed.
+// restored is set once the data of spec.restore has been loaded, i.e. when the first master is elected.
+func (reconciler *ReconcileRedis) checkDataProtection(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	status *k8sv1alpha1.RedisStatus,
+	restored bool,
+) error {
+	if r.Spec.Backup == nil && r.Spec.Restore == nil {
+		return nil
+	}
+
+	dataProtection := new(k8sv1alpha1.DataProtectionStatus)
+	if status.DataProtection != nil {
+		dataProtection = status.DataProtection.DeepCopy()
+	}
+
+	backupList := new(k8sv1alpha1.RedisBackupList)
+	if err := reconciler.client.List(ctx, backupList, client.InNamespace(r.GetNamespace())); err != nil {
+		return fmt.Errorf("failed to list RedisBackups: %s", err)
+	}
+	for i := range backupList.Items {
+		backup := &backupList.Items[i]
+		if backup.Spec.RedisName != r.GetName() || backup.Status.CompletionTime == nil {
+			continue
+		}
+		recordBackup(dataProtection, backup.Status.Phase, k8sv1alpha1.BackupReference{
+			Kind:           redisBackupKind,
+			Name:           backup.GetName(),
+			CompletionTime: *backup.Status.CompletionTime,
+			Location:       backup.Status.Location,
+			Size:           backup.Status.Size,
+			Message:        backup.Status.Message,
+		})
+	}
+
+	if backupScheduled(r) {
+		jobList := new(batchv1.JobList)
+		if err := reconciler.client.List(ctx, jobList,
+			client.InNamespace(r.GetNamespace()),
+			client.MatchingLabels{scheduledBackupLabelKey: r.GetName()},
+		); err != nil {
+			return fmt.Errorf("failed to list Jobs: %s", err)
+		}
+		for i := range jobList.Items {
+			phase, completionTime, message := jobPhase(&jobList.Items[i])
+			if completionTime == nil {
+				continue
+			}
+			recordBackup(dataProtection, phase, k8sv1alpha1.BackupReference{
+				Kind:           jobKind,
+				Name:           jobList.Items[i].GetName(),
+				CompletionTime: *completionTime,
+				Message:        message,
+			})
+		}
+	}
+
+	if restore := r.Spec.Restore; restore != nil && restored {
+		dataProtection.LastSucceededRestore = &k8sv1alpha1.RestoreReference{
+			RedisName:      r.GetName(),
+			BackupName:     restore.BackupName,
+			CompletionTime: metav1.Now(),
+		}
+	}
+	if drill := status.RestoreDrill; drill != nil && drill.CompletionTime != nil {
+		recordRestore(dataProtection, drill.Phase == k8sv1alpha1.RestoreDrillSucceeded, k8sv1alpha1.RestoreReference{
+			RedisName:      generateRestoreDrillName(r),
+			BackupName:     drill.BackupName,
+			CompletionTime: *drill.CompletionTime,
+			Message:        drill.Message,
+		})
+	}
+
+	if *dataProtection == (k8sv1alpha1.DataProtectionStatus{}) {
+		dataProtection = nil
+	}
+	status.DataProtection = dataProtection
+	return nil
+}
+
+// recordBackup replaces the latest succeeded or failed backup if the backup has completed later
+func recordBackup(status *k8sv1alpha1.DataProtectionStatus, phase k8sv1alpha1.BackupPhase, backup k8sv1alpha1.BackupReference) {
+	latest := &status.LastSucceededBackup
+	switch phase {
+	case k8sv1alpha1.BackupPhaseSucceeded:
+	case k8sv1alpha1.BackupPhaseFailed:
+		latest = &status.LastFailedBackup
+	default:
+		return
+	}
+	if *latest == nil || (*latest).CompletionTime.Before(&backup.CompletionTime) ||
+		(*latest).Kind == backup.Kind && (*latest).Name == backup.Name {
+		*latest = &backup
+	}
+}
+
+// recordRestore replaces the latest succeeded or failed restore if the restore has completed later
+func recordRestore(status *k8sv1alpha1.DataProtectionStatus, succeeded bool, restore k8sv1alpha1.RestoreReference) {
+	latest := &status.LastFailedRestore
+	if succeeded {
+		latest = &status.LastSucceededRestore
+	}
+	if *latest == nil || (*latest).CompletionTime.Before(&restore.CompletionTime) {
+		*latest = &restore
+	}
+}
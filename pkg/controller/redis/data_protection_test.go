@@ -0,0 +1,128 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_snapshotSize(t *testing.T) {
+	pod := func(name string, exitCode int32, message string) corev1.Pod {
+		return corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  name,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode, Message: message}},
+		}}}}
+	}
+	tests := []struct {
+		name string
+		pods []corev1.Pod
+		want int64
+	}{
+		{"uploaded", []corev1.Pod{pod(uploadContainerName, 0, "size=1048576\n")}, 1048576},
+		{"retried", []corev1.Pod{pod(uploadContainerName, 1, ""), pod(uploadContainerName, 0, "size=42")}, 42},
+		{"failed", []corev1.Pod{pod(uploadContainerName, 1, "size=42")}, 0},
+		{"other container", []corev1.Pod{pod(snapshotContainerName, 0, "size=42")}, 0},
+		{"malformed", []corev1.Pod{pod(uploadContainerName, 0, "size=unknown")}, 0},
+		{"no Pods", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snapshotSize(tt.pods); got != tt.want {
+				t.Errorf("snapshotSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_recordBackup(t *testing.T) {
+	now := time.Now()
+	reference := func(name string, completed time.Time) *k8sv1alpha1.BackupReference {
+		return &k8sv1alpha1.BackupReference{Kind: redisBackupKind, Name: name, CompletionTime: metav1.NewTime(completed)}
+	}
+	tests := []struct {
+		name   string
+		status k8sv1alpha1.DataProtectionStatus
+		phase  k8sv1alpha1.BackupPhase
+		backup *k8sv1alpha1.BackupReference
+		want   k8sv1alpha1.DataProtectionStatus
+	}{
+		{
+			name:   "first success",
+			phase:  k8sv1alpha1.BackupPhaseSucceeded,
+			backup: reference("new", now),
+			want:   k8sv1alpha1.DataProtectionStatus{LastSucceededBackup: reference("new", now)},
+		},
+		{
+			name:   "later failure",
+			status: k8sv1alpha1.DataProtectionStatus{LastFailedBackup: reference("old", now.Add(-time.Hour))},
+			phase:  k8sv1alpha1.BackupPhaseFailed,
+			backup: reference("new", now),
+			want:   k8sv1alpha1.DataProtectionStatus{LastFailedBackup: reference("new", now)},
+		},
+		{
+			name:   "earlier success",
+			status: k8sv1alpha1.DataProtectionStatus{LastSucceededBackup: reference("new", now)},
+			phase:  k8sv1alpha1.BackupPhaseSucceeded,
+			backup: reference("old", now.Add(-time.Hour)),
+			want:   k8sv1alpha1.DataProtectionStatus{LastSucceededBackup: reference("new", now)},
+		},
+		{
+			name:   "same backup updated",
+			status: k8sv1alpha1.DataProtectionStatus{LastSucceededBackup: reference("new", now)},
+			phase:  k8sv1alpha1.BackupPhaseSucceeded,
+			backup: &k8sv1alpha1.BackupReference{Kind: redisBackupKind, Name: "new", CompletionTime: metav1.NewTime(now), Size: 42},
+			want: k8sv1alpha1.DataProtectionStatus{LastSucceededBackup: &k8sv1alpha1.BackupReference{
+				Kind: redisBackupKind, Name: "new", CompletionTime: metav1.NewTime(now), Size: 42,
+			}},
+		},
+		{
+			name:   "running",
+			phase:  k8sv1alpha1.BackupPhaseRunning,
+			backup: reference("new", now),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recordBackup(&tt.status, tt.phase, *tt.backup)
+			if !reflect.DeepEqual(tt.status, tt.want) {
+				t.Errorf("recordBackup() = %+v, want %+v", tt.status, tt.want)
+			}
+		})
+	}
+}
+
+func Test_recordRestore(t *testing.T) {
+	now := metav1.Now()
+	earlier := metav1.NewTime(now.Add(-time.Hour))
+	status := k8sv1alpha1.DataProtectionStatus{
+		LastSucceededRestore: &k8sv1alpha1.RestoreReference{RedisName: "redis", CompletionTime: now},
+	}
+
+	recordRestore(&status, true, k8sv1alpha1.RestoreReference{RedisName: "drill", CompletionTime: earlier})
+	if status.LastSucceededRestore.RedisName != "redis" {
+		t.Errorf("recordRestore() has replaced the later restore with %+v", status.LastSucceededRestore)
+	}
+	recordRestore(&status, false, k8sv1alpha1.RestoreReference{RedisName: "drill", CompletionTime: earlier, Message: "timed out"})
+	if status.LastFailedRestore == nil || status.LastFailedRestore.Message != "timed out" {
+		t.Errorf("recordRestore() = %+v, want the failed drill", status.LastFailedRestore)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// maxDecisions is the number of the recent Events kept by Redis for the diagnostics bundle
+	maxDecisions = 100
+	// maxObjectDiffs is the number of the recent updates of the owned objects kept by Redis
+	maxObjectDiffs = 20
+	// maxObjectDiffSize is the size the changes of an update are truncated to, the bundle has to fit in a ConfigMap
+	maxObjectDiffSize = 8 << 10
+)
+
+// decision is an Event emitted on the Redis
+type decision struct {
+	at        time.Time
+	eventType string
+	reason    string
+	message   string
+}
+
+func (d decision) String() string {
+	return fmt.Sprintf("%s %s %s: %s", d.at.UTC().Format(time.RFC3339), d.eventType, d.reason, d.message)
+}
+
+// objectDiff is an update of an owned object with the changed fields
+type objectDiff struct {
+	at      time.Time
+	object  string
+	changes string
+}
+
+func (d objectDiff) String() string {
+	return fmt.Sprintf("%s %s\n%s", d.at.UTC().Format(time.RFC3339), d.object, d.changes)
+}
+
+// decisionLog keeps the recent decisions of the operator by Redis: the Events and the updates of the owned objects.
+// Only the latest maxDecisions Events and maxObjectDiffs updates are kept. It is safe for concurrent use.
+type decisionLog struct {
+	mu        sync.Mutex
+	decisions map[types.NamespacedName][]decision
+	diffs     map[types.NamespacedName][]objectDiff
+}
+
+func newDecisionLog() *decisionLog {
+	return &decisionLog{
+		decisions: make(map[types.NamespacedName][]decision),
+		diffs:     make(map[types.NamespacedName][]objectDiff),
+	}
+}
+
+// record keeps the Event emitted on the Redis
+func (l *decisionLog) record(key types.NamespacedName, d decision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	decisions := append(l.decisions[key], d)
+	if len(decisions) > maxDecisions {
+		decisions = decisions[len(decisions)-maxDecisions:]
+	}
+	l.decisions[key] = decisions
+}
+
+// recordDiff keeps the update of the object owned by the Redis
+func (l *decisionLog) recordDiff(key types.NamespacedName, d objectDiff) {
+	if len(d.changes) > maxObjectDiffSize {
+		d.changes = d.changes[:maxObjectDiffSize] + "\n... truncated"
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	diffs := append(l.diffs[key], d)
+	if len(diffs) > maxObjectDiffs {
+		diffs = diffs[len(diffs)-maxObjectDiffs:]
+	}
+	l.diffs[key] = diffs
+}
+
+// recent returns the copies of the kept Events and updates of the Redis, the oldest first
+func (l *decisionLog) recent(key types.NamespacedName) ([]decision, []objectDiff) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]decision(nil), l.decisions[key]...), append([]objectDiff(nil), l.diffs[key]...)
+}
+
+// forget drops the decisions of the deleted Redis
+func (l *decisionLog) forget(key types.NamespacedName) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.decisions, key)
+	delete(l.diffs, key)
+}
+
+// decisionRecorder keeps the Events emitted on the objects in the decision log along with emitting them,
+// and sends them to the external event services if the notifier is set
+type decisionRecorder struct {
+	record.EventRecorder
+	decisions *decisionLog
+	notifier  *eventNotifier
+}
+
+func (r decisionRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.record(object, eventtype, reason, message)
+	r.EventRecorder.Event(object, eventtype, reason, message)
+}
+
+func (r decisionRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r decisionRecorder) AnnotatedEventf(
+	object runtime.Object,
+	annotations map[string]string,
+	eventtype, reason, messageFmt string,
+	args ...interface{},
+) {
+	r.record(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+	r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+}
+
+func (r decisionRecorder) record(object runtime.Object, eventtype, reason, message string) {
+	r.notifier.notify(object, eventtype, reason, message)
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return
+	}
+	r.decisions.record(types.NamespacedName{Namespace: accessor.GetNamespace(), Name: accessor.GetName()},
+		decision{at: time.Now(), eventType: eventtype, reason: reason, message: message})
+}
+
+// objectChanges lists the fields changed by the update of the object, one per line as path: before -> after.
+// The objects are compared in their JSON form, so the changes read the same way as the manifests.
+// The data of the Secrets is reported by the keys only, the values are never a part of the changes.
+func objectChanges(before, after runtime.Object) (string, error) {
+	beforeValue, err := jsonOf(before)
+	if err != nil {
+		return "", err
+	}
+	afterValue, err := jsonOf(after)
+	if err != nil {
+		return "", err
+	}
+
+	var changes []string
+	if _, ok := after.(*corev1.Secret); ok {
+		for _, field := range secretDataFields {
+			diffKeys("."+field, popField(beforeValue, field), popField(afterValue, field), &changes)
+		}
+	}
+	diffValues("", beforeValue, afterValue, &changes)
+	return strings.Join(changes, "\n"), nil
+}
+
+// secretDataFields are the fields of the Secret whose values never make it into the changes
+var secretDataFields = []string{"data", "stringData"}
+
+// popField removes the field from the JSON object and returns its value
+func popField(value interface{}, field string) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	fieldValue := object[field]
+	delete(object, field)
+	return fieldValue
+}
+
+// diffKeys appends the keys added, removed or changed between the before and after JSON objects to changes.
+// Unlike diffValues it never renders the values themselves.
+func diffKeys(path string, before, after interface{}, changes *[]string) {
+	beforeObject, _ := before.(map[string]interface{})
+	afterObject, _ := after.(map[string]interface{})
+	var keyChanges []string
+	for key, beforeValue := range beforeObject {
+		afterValue, ok := afterObject[key]
+		switch {
+		case !ok:
+			keyChanges = append(keyChanges, path+"."+key+": removed")
+		case !reflect.DeepEqual(beforeValue, afterValue):
+			keyChanges = append(keyChanges, path+"."+key+": changed")
+		}
+	}
+	for key := range afterObject {
+		if _, ok := beforeObject[key]; !ok {
+			keyChanges = append(keyChanges, path+"."+key+": added")
+		}
+	}
+	sort.Strings(keyChanges)
+	*changes = append(*changes, keyChanges...)
+}
+
+// jsonOf returns the object decoded from its JSON form into the generic values
+func jsonOf(object runtime.Object) (interface{}, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	err = json.Unmarshal(data, &value)
+	return value, err
+}
+
+// diffValues appends the paths of the JSON values differing between before and after to changes.
+// The objects are compared by their keys and the arrays of the same length by their items.
+func diffValues(path string, before, after interface{}, changes *[]string) {
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+
+	beforeObject, beforeIsObject := before.(map[string]interface{})
+	afterObject, afterIsObject := after.(map[string]interface{})
+	if beforeIsObject && afterIsObject {
+		keys := make(map[string]bool, len(beforeObject)+len(afterObject))
+		for key := range beforeObject {
+			keys[key] = true
+		}
+		for key := range afterObject {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			diffValues(path+"."+key, beforeObject[key], afterObject[key], changes)
+		}
+		return
+	}
+
+	beforeArray, beforeIsArray := before.([]interface{})
+	afterArray, afterIsArray := after.([]interface{})
+	if beforeIsArray && afterIsArray && len(beforeArray) == len(afterArray) {
+		for i := range beforeArray {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), beforeArray[i], afterArray[i], changes)
+		}
+		return
+	}
+
+	*changes = append(*changes, fmt.Sprintf("%s: %s -> %s", path, jsonValue(before), jsonValue(after)))
+}
+
+// jsonValue renders the JSON value, the missing value is rendered as null
+func jsonValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
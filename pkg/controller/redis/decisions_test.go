@@ -0,0 +1,163 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_decisionLog(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	l := newDecisionLog()
+	for i := 0; i < maxDecisions+5; i++ {
+		l.record(key, decision{eventType: corev1.EventTypeNormal, reason: "Updated", message: fmt.Sprint(i)})
+	}
+	for i := 0; i < maxObjectDiffs+5; i++ {
+		l.recordDiff(key, objectDiff{object: fmt.Sprint(i)})
+	}
+	l.recordDiff(types.NamespacedName{Namespace: "default", Name: "other"}, objectDiff{object: "other"})
+
+	decisions, diffs := l.recent(key)
+	if len(decisions) != maxDecisions || decisions[0].message != "5" {
+		t.Errorf("recent() decisions = %d starting with %q, want %d starting with 5",
+			len(decisions), decisions[0].message, maxDecisions)
+	}
+	if len(diffs) != maxObjectDiffs || diffs[0].object != "5" {
+		t.Errorf("recent() diffs = %d starting with %q, want %d starting with 5", len(diffs), diffs[0].object, maxObjectDiffs)
+	}
+
+	l.forget(key)
+	if decisions, diffs := l.recent(key); len(decisions) > 0 || len(diffs) > 0 {
+		t.Errorf("recent() after forget() = %v, %v, want none", decisions, diffs)
+	}
+	if _, diffs := l.recent(types.NamespacedName{Namespace: "default", Name: "other"}); len(diffs) != 1 {
+		t.Errorf("recent() of the other Redis = %v, want 1 diff", diffs)
+	}
+}
+
+func Test_decision_String(t *testing.T) {
+	d := decision{
+		at:        time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+		eventType: corev1.EventTypeWarning,
+		reason:    "QuorumNotMet",
+		message:   "minimum replication size is not met, only 1 are healthy",
+	}
+	want := "2021-03-01T12:00:00Z Warning QuorumNotMet: minimum replication size is not met, only 1 are healthy"
+	if got := d.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func Test_objectChanges(t *testing.T) {
+	replicas, updated := int32(3), int32(5)
+	statefulSet := func(replicas *int32, image string, labels map[string]string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "redis-test", Labels: labels},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: replicas,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "redis", Image: image}},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		before *appsv1.StatefulSet
+		after  *appsv1.StatefulSet
+		want   string
+	}{
+		{
+			"unchanged",
+			statefulSet(&replicas, "redis:6.0.9", nil),
+			statefulSet(&replicas, "redis:6.0.9", nil),
+			"",
+		},
+		{
+			"changed",
+			statefulSet(&replicas, "redis:6.0.9", nil),
+			statefulSet(&updated, "redis:6.0.10", map[string]string{"app": "redis"}),
+			`.metadata.labels: null -> {"app":"redis"}` + "\n" +
+				".spec.replicas: 3 -> 5\n" +
+				`.spec.template.spec.containers[0].image: "redis:6.0.9" -> "redis:6.0.10"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := objectChanges(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("objectChanges() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("objectChanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_objectChangesSecret(t *testing.T) {
+	before := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "redis-test"},
+		Data: map[string][]byte{
+			"password": []byte("old-password"),
+			"users":    []byte("user alice on >alice-password"),
+			"unused":   []byte("unused-value"),
+		},
+	}
+	after := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "redis-test", Labels: map[string]string{"app": "redis"}},
+		Data: map[string][]byte{
+			"password": []byte("new-password"),
+			"users":    []byte("user alice on >alice-password"),
+		},
+		StringData: map[string]string{"token": "plain-token"},
+	}
+
+	got, err := objectChanges(before, after)
+	if err != nil {
+		t.Fatalf("objectChanges() error = %v", err)
+	}
+	want := ".data.password: changed\n" +
+		".data.unused: removed\n" +
+		".stringData.token: added\n" +
+		`.metadata.labels: null -> {"app":"redis"}`
+	if got != want {
+		t.Errorf("objectChanges() = %q, want %q", got, want)
+	}
+	for _, secret := range []*corev1.Secret{before, after} {
+		for _, value := range secret.Data {
+			for _, rendered := range []string{string(value), base64.StdEncoding.EncodeToString(value)} {
+				if strings.Contains(got, rendered) {
+					t.Errorf("objectChanges() = %q, contains the data value %q", got, rendered)
+				}
+			}
+		}
+		for _, value := range secret.StringData {
+			if strings.Contains(got, value) {
+				t.Errorf("objectChanges() = %q, contains the data value %q", got, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"strings"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// operatorUserRolledOut reports whether all the instances are up and running with the probes
+// and the exporter authenticating as the operator user, i.e. the default user can be safely disabled
+func operatorUserRolledOut(r *k8sv1alpha1.Redis, pods []corev1.Pod) bool {
+	var ready int32
+	for i := range pods {
+		if !podReady(&pods[i]) {
+			continue
+		}
+		for _, container := range pods[i].Spec.Containers {
+			switch container.Name {
+			case redisName:
+				if !probeAuthenticatesAsOperator(container.ReadinessProbe) || !probeAuthenticatesAsOperator(container.LivenessProbe) ||
+					!probeAuthenticatesAsOperator(container.StartupProbe) {
+					return false
+				}
+			case exporterName:
+				if !containerEnvSet(container, exporterUserEnvName, redis.OperatorUser) {
+					return false
+				}
+			}
+		}
+		ready++
+	}
+	return ready == *r.Spec.Replicas
+}
+
+// probeAuthenticatesAsOperator reports whether the redis-cli probe is run as the operator user
+func probeAuthenticatesAsOperator(probe *corev1.Probe) bool {
+	if probe == nil || probe.Exec == nil {
+		return true
+	}
+	// the probes authenticating with the password run redis-cli in a shell
+	command := strings.Fields(strings.Join(probe.Exec.Command, " "))
+	for i := 0; i+1 < len(command); i++ {
+		if command[i] == "--user" && command[i+1] == redis.OperatorUser {
+			return true
+		}
+	}
+	return false
+}
+
+// containerEnvSet reports whether the environment variable of the container is set to the value
+func containerEnvSet(container corev1.Container, name, value string) bool {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return env.Value == value
+		}
+	}
+	return false
+}
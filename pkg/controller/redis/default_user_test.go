@@ -0,0 +1,102 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"strings"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newDefaultUserRedis(disabled bool) *k8sv1alpha1.Redis {
+	replicas := int32(2)
+	return &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: k8sv1alpha1.RedisSpec{
+			Replicas: &replicas,
+			Redis:    k8sv1alpha1.ContainerSpec{Image: "redis:6.0.9-alpine"},
+			Exporter: k8sv1alpha1.ContainerSpec{Image: "oliver006/redis_exporter:v1.11.1"},
+			Password: k8sv1alpha1.Password{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "redis-password-secret"},
+				Key:                  "password",
+			}},
+			ACL: &k8sv1alpha1.ACL{DisableDefaultUser: disabled},
+		},
+	}
+}
+
+func Test_operatorUserRolledOut(t *testing.T) {
+	pod := func(r *k8sv1alpha1.Redis) corev1.Pod {
+		spec := generateStatefulSet(r, objectGeneratorOptions{password: "secret"}).Spec.Template.Spec
+		return corev1.Pod{Spec: spec, Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"}}
+	}
+	updated, outdated := pod(newDefaultUserRedis(true)), pod(newDefaultUserRedis(false))
+	notReady := updated
+	notReady.Status.Phase = corev1.PodPending
+
+	tests := []struct {
+		name string
+		pods []corev1.Pod
+		want bool
+	}{
+		{"rolled out", []corev1.Pod{updated, updated}, true},
+		{"outdated Pod", []corev1.Pod{updated, outdated}, false},
+		{"missing Pod", []corev1.Pod{updated}, false},
+		{"Pod not ready", []corev1.Pod{updated, notReady}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := operatorUserRolledOut(newDefaultUserRedis(true), tt.pods); got != tt.want {
+				t.Errorf("operatorUserRolledOut() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generateSecret_defaultUser(t *testing.T) {
+	options := objectGeneratorOptions{password: "secret", aclUsers: []redis.User{redis.NewOperatorUser("secret")}}
+	operatorUser := "user redis-operator reset on ~* +@all #" + redis.PasswordHash("secret") + "\n"
+
+	tests := []struct {
+		name     string
+		disabled bool
+		want     []string
+		wantNot  []string
+	}{
+		{"enabled", false, []string{"requirepass secret\n", "masterauth secret\n", operatorUser}, []string{"masteruser", "user default"}},
+		{"disabled", true, []string{"masteruser redis-operator\n", "masterauth secret\n", "user default reset off\n", operatorUser}, []string{"requirepass"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options.defaultUserDisabled = tt.disabled
+			conf := string(generateSecret(newDefaultUserRedis(true), options).Data[secretFileName])
+			for _, want := range tt.want {
+				if !strings.Contains(conf, want) {
+					t.Errorf("generateSecret() = %q, want %q", conf, want)
+				}
+			}
+			for _, wantNot := range tt.wantNot {
+				if strings.Contains(conf, wantNot) {
+					t.Errorf("generateSecret() = %q, must not contain %q", conf, wantNot)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// the keys of the diagnostics bundle ConfigMap, the instances are keyed by the Pod names with the suffixes
+const (
+	diagnosticsRedisKey          = "redis.json"
+	diagnosticsReconciliationKey = "reconciliation"
+	diagnosticsPodsKey           = "pods"
+	diagnosticsDecisionsKey      = "decisions"
+	diagnosticsDiffsKey          = "diffs"
+	diagnosticsErrorsKey         = "errors"
+	diagnosticsInfoSuffix        = ".info"
+	diagnosticsConfigSuffix      = ".config"
+	diagnosticsErrorSuffix       = ".error"
+)
+
+// generateDiagnosticsName returns the name of the diagnostics bundle ConfigMap
+func generateDiagnosticsName(r *k8sv1alpha1.Redis) string {
+	return fmt.Sprintf("%s-diagnostics", generateName(r))
+}
+
+// collectDiagnostics collects the diagnostics bundle of the Redis into the ConfigMap once requested by
+// the DiagnosticsAnnotation. It runs after the reconciliation whatever its outcome, so the bundle is collected
+// when the reconciliation fails early, e.g. on a missing Secret, and carries the reconciliation error.
+// The value of the annotation the bundle is collected for is recorded on the ConfigMap.
+func (reconciler *ReconcileRedis) collectDiagnostics(ctx context.Context, request reconcile.Request, reconcileErr error) error {
+	r := new(k8sv1alpha1.Redis)
+	if err := reconciler.client.Get(ctx, request.NamespacedName, r); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to fetch Redis: %s", err)
+	}
+	requested := r.GetAnnotations()[k8sv1alpha1.DiagnosticsAnnotation]
+	if requested == "" || r.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	found := new(corev1.ConfigMap)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{
+		Namespace: r.GetNamespace(),
+		Name:      generateDiagnosticsName(r),
+	}, found); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to fetch ConfigMap: %s", err)
+		}
+		found = nil
+	} else if found.GetAnnotations()[k8sv1alpha1.DiagnosticsAnnotation] == requested {
+		return nil
+	}
+
+	configMap := generateDiagnostics(r, requested, reconciler.diagnosticsBundle(ctx, r, reconcileErr, time.Now()))
+	if found == nil {
+		if err := controllerutil.SetControllerReference(r, configMap, reconciler.scheme); err != nil {
+			return fmt.Errorf("failed to set owner for ConfigMap: %s", err)
+		}
+		if err := reconciler.client.Create(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to create ConfigMap: %s", err)
+		}
+	} else {
+		found.Labels, found.Annotations, found.Data = configMap.Labels, configMap.Annotations, configMap.Data
+		if err := reconciler.client.Update(ctx, found); err != nil {
+			return fmt.Errorf("failed to update ConfigMap: %s", err)
+		}
+	}
+	reconciler.recorder.Eventf(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonDiagnosticsCollected,
+		"Collected the diagnostics into ConfigMap %s", configMap.GetName())
+	return nil
+}
+
+// generateDiagnostics returns the ConfigMap of the diagnostics bundle collected for the value of the annotation
+func generateDiagnostics(r *k8sv1alpha1.Redis, requested string, bundle map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        generateDiagnosticsName(r),
+			Namespace:   r.GetNamespace(),
+			Labels:      map[string]string{redisName: r.GetName()},
+			Annotations: map[string]string{k8sv1alpha1.DiagnosticsAnnotation: requested},
+		},
+		Data: bundle,
+	}
+}
+
+// diagnosticsBundle collects the Redis with its status, the outcome of the reconciliation, the Pods,
+// the recent Events and updates of the owned objects, and INFO all and CONFIG GET * of the ready instances.
+// The instances are reached the way the operator reaches them, with the password applied to them.
+// The errors of collecting a part are reported in the bundle, the rest is collected regardless.
+func (reconciler *ReconcileRedis) diagnosticsBundle(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	reconcileErr error,
+	now time.Time,
+) map[string]string {
+	bundle := make(map[string]string)
+	var errs []string
+
+	if data, err := json.MarshalIndent(r, "", "  "); err != nil {
+		errs = append(errs, fmt.Sprintf("error encoding Redis: %s", err))
+	} else {
+		bundle[diagnosticsRedisKey] = string(data)
+	}
+
+	reconciliation := "succeeded"
+	if reconcileErr != nil {
+		reconciliation = fmt.Sprintf("failed: %s", reconcileErr)
+	}
+	bundle[diagnosticsReconciliationKey] = fmt.Sprintf("%s %s", now.UTC().Format(time.RFC3339), reconciliation)
+
+	decisions, diffs := reconciler.decisions.recent(types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()})
+	lines := make([]string, len(decisions))
+	for i := range decisions {
+		lines[i] = decisions[i].String()
+	}
+	bundle[diagnosticsDecisionsKey] = strings.Join(lines, "\n")
+	lines = make([]string, len(diffs))
+	for i := range diffs {
+		lines[i] = diffs[i].String()
+	}
+	bundle[diagnosticsDiffsKey] = strings.Join(lines, "\n\n")
+
+	podList := new(corev1.PodList)
+	if err := reconciler.client.List(ctx, podList, client.InNamespace(r.GetNamespace()),
+		client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(map[string]string{redisName: r.GetName()})}); err != nil {
+		errs = append(errs, fmt.Sprintf("failed to list Pods: %s", err))
+	}
+	pods, _ := partitionMembers(r, podList.Items)
+	bundle[diagnosticsPodsKey] = podsSummary(pods)
+
+	options, err := reconciler.diagnosticsOptions(ctx, r)
+	if err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		podNames := make(map[redis.Address]string)
+		var addresses []redis.Address
+		for i := range pods {
+			if !podReady(&pods[i]) {
+				continue
+			}
+			address := redis.Address{Host: pods[i].Status.PodIP, Port: strconv.Itoa(redisPort(r))}
+			addresses = append(addresses, address)
+			podNames[address] = pods[i].Name
+		}
+		instances, err := redis.Diagnose(ctx, options, addresses...)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("error diagnosing the instances: %s", err))
+		}
+		for address, diagnostics := range instances {
+			addInstanceDiagnostics(bundle, podNames[address], diagnostics)
+		}
+	}
+
+	if len(errs) > 0 {
+		bundle[diagnosticsErrorsKey] = strings.Join(errs, "\n")
+	}
+	return bundle
+}
+
+// diagnosticsOptions returns the options the instances of the Redis are diagnosed with: the password applied
+// to the instances by the operator, so the bundle is collected when the referenced Secret is missing,
+// and the TLS certificate
+func (reconciler *ReconcileRedis) diagnosticsOptions(ctx context.Context, r *k8sv1alpha1.Redis) (redis.Options, error) {
+	options := redis.Options{
+		ClientName: reconciler.options.RedisClientName,
+		Protocol:   reconciler.options.RedisProtocol,
+	}
+	if r.Status.DefaultUserDisabled {
+		options.Username = redis.OperatorUser
+	}
+
+	secret := new(corev1.Secret)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateName(r)},
+		secret); err == nil {
+		options.Password = appliedPassword(secret)
+	} else if !errors.IsNotFound(err) {
+		return redis.Options{}, fmt.Errorf("failed to fetch Secret: %s", err)
+	}
+
+	if r.Spec.TLS != nil {
+		tlsSecret := new(corev1.Secret)
+		if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: r.Spec.TLS.SecretName},
+			tlsSecret); err != nil {
+			return redis.Options{}, fmt.Errorf("failed to fetch TLS certificate from Secret %s: %s", r.Spec.TLS.SecretName, err)
+		}
+		tlsConfig, err := redis.NewTLSConfig(
+			tlsSecret.Data[corev1.TLSCertKey],
+			tlsSecret.Data[corev1.TLSPrivateKeyKey],
+			tlsSecret.Data[tlsCAKey],
+		)
+		if err != nil {
+			return redis.Options{}, fmt.Errorf("invalid TLS certificate in Secret %s: %s", tlsSecret.Name, err)
+		}
+		options.TLSConfig = tlsConfig
+	}
+	return options, nil
+}
+
+// addInstanceDiagnostics adds INFO all and CONFIG GET * of the instance to the bundle by the Pod name
+func addInstanceDiagnostics(bundle map[string]string, podName string, diagnostics redis.Diagnostics) {
+	if diagnostics.Info != "" {
+		bundle[podName+diagnosticsInfoSuffix] = diagnostics.Info
+	}
+	if len(diagnostics.Config) > 0 {
+		names := make([]string, 0, len(diagnostics.Config))
+		for name := range diagnostics.Config {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		lines := make([]string, len(names))
+		for i, name := range names {
+			lines[i] = fmt.Sprintf("%s %s", name, diagnostics.Config[name])
+		}
+		bundle[podName+diagnosticsConfigSuffix] = strings.Join(lines, "\n")
+	}
+	if diagnostics.Err != nil {
+		bundle[podName+diagnosticsErrorSuffix] = diagnostics.Err.Error()
+	}
+}
+
+// podsSummary lists the Pods one per line with their phase, readiness, IP, node and the restarts of the containers
+func podsSummary(pods []corev1.Pod) string {
+	lines := make([]string, len(pods))
+	for i := range pods {
+		var restarts int32
+		for _, status := range pods[i].Status.ContainerStatuses {
+			restarts += status.RestartCount
+		}
+		lines[i] = fmt.Sprintf("%s phase=%s ready=%t ip=%s node=%s restarts=%d", pods[i].Name, pods[i].Status.Phase,
+			podReady(&pods[i]), pods[i].Status.PodIP, pods[i].Spec.NodeName, restarts)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_addInstanceDiagnostics(t *testing.T) {
+	tests := []struct {
+		name        string
+		diagnostics redis.Diagnostics
+		want        map[string]string
+	}{
+		{
+			"collected",
+			redis.Diagnostics{
+				Info:   "# Server\r\nredis_version:6.0.9\r\n",
+				Config: map[string]string{"save": "", "maxmemory": "0", "requirepass": "<redacted>"},
+			},
+			map[string]string{
+				"redis-test-0.info":   "# Server\r\nredis_version:6.0.9\r\n",
+				"redis-test-0.config": "maxmemory 0\nrequirepass <redacted>\nsave ",
+			},
+		},
+		{
+			"config failed",
+			redis.Diagnostics{Info: "# Server\r\n", Err: errors.New("error getting config: NOPERM")},
+			map[string]string{
+				"redis-test-0.info":  "# Server\r\n",
+				"redis-test-0.error": "error getting config: NOPERM",
+			},
+		},
+		{
+			"unreachable",
+			redis.Diagnostics{Err: errors.New("error getting info: connection refused")},
+			map[string]string{"redis-test-0.error": "error getting info: connection refused"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := make(map[string]string)
+			addInstanceDiagnostics(bundle, "redis-test-0", tt.diagnostics)
+			if !reflect.DeepEqual(bundle, tt.want) {
+				t.Errorf("addInstanceDiagnostics() = %v, want %v", bundle, tt.want)
+			}
+		})
+	}
+}
+
+func Test_podsSummary(t *testing.T) {
+	pods := []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "redis-test-1"},
+			Spec:       corev1.PodSpec{NodeName: "node-b"},
+			Status:     corev1.PodStatus{Phase: corev1.PodPending},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "redis-test-0"},
+			Spec:       corev1.PodSpec{NodeName: "node-a"},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				PodIP:             "10.0.0.1",
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true, RestartCount: 2}, {Ready: true, RestartCount: 1}},
+			},
+		},
+	}
+	want := "redis-test-0 phase=Running ready=true ip=10.0.0.1 node=node-a restarts=3\n" +
+		"redis-test-1 phase=Pending ready=false ip= node=node-b restarts=0"
+	if got := podsSummary(pods); got != want {
+		t.Errorf("podsSummary() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import corev1 "k8s.io/api/core/v1"
+
+// downwardAPIEnv are the standard variables of the Pod identity the redis container and the sidecars are provided
+// with by the downward API, e.g. for the custom entrypoints or templating replica-announce-ip
+var downwardAPIEnv = []struct{ name, fieldPath string }{
+	{"POD_NAME", "metadata.name"},
+	{"POD_NAMESPACE", "metadata.namespace"},
+	{"POD_IP", "status.podIP"},
+	{"NODE_NAME", "spec.nodeName"},
+}
+
+// withDownwardAPIEnv prepends the downward API variables to the environment of a container, so the variables
+// of the container can refer to them, e.g. $(POD_IP). The variables the container defines itself are kept as is.
+func withDownwardAPIEnv(env []corev1.EnvVar) []corev1.EnvVar {
+	defined := make(map[string]bool, len(env))
+	for _, variable := range env {
+		defined[variable.Name] = true
+	}
+
+	merged := make([]corev1.EnvVar, 0, len(downwardAPIEnv)+len(env))
+	for _, variable := range downwardAPIEnv {
+		if defined[variable.name] {
+			continue
+		}
+		merged = append(merged, corev1.EnvVar{
+			Name: variable.name,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: variable.fieldPath,
+				},
+			},
+		})
+	}
+	return append(merged, env...)
+}
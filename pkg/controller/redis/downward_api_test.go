@@ -0,0 +1,89 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_withDownwardAPIEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []corev1.EnvVar
+		want []string
+	}{
+		{"empty", nil, []string{"POD_NAME", "POD_NAMESPACE", "POD_IP", "NODE_NAME"}},
+		{
+			"appended",
+			[]corev1.EnvVar{{Name: "ANNOUNCE_IP", Value: "$(POD_IP)"}},
+			[]string{"POD_NAME", "POD_NAMESPACE", "POD_IP", "NODE_NAME", "ANNOUNCE_IP"},
+		},
+		{
+			"defined by the container",
+			[]corev1.EnvVar{{Name: "POD_IP", Value: "10.0.0.1"}},
+			[]string{"POD_NAME", "POD_NAMESPACE", "NODE_NAME", "POD_IP"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, variable := range withDownwardAPIEnv(tt.env) {
+				got = append(got, variable.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withDownwardAPIEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generateStatefulSet_downwardAPIEnv(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: k8sv1alpha1.RedisSpec{
+		Redis:    k8sv1alpha1.ContainerSpec{Image: "redis"},
+		Exporter: k8sv1alpha1.ContainerSpec{Image: "oliver006/redis_exporter"},
+		Sidecars: []corev1.Container{{Name: "agent", Env: []corev1.EnvVar{{Name: "NODE_NAME", Value: "local"}}}},
+	}}
+	env := func(container corev1.Container) map[string]string {
+		values := make(map[string]string)
+		for _, variable := range container.Env {
+			if variable.ValueFrom != nil && variable.ValueFrom.FieldRef != nil {
+				values[variable.Name] = variable.ValueFrom.FieldRef.FieldPath
+				continue
+			}
+			values[variable.Name] = variable.Value
+		}
+		return values
+	}
+
+	containers := generateStatefulSet(r, objectGeneratorOptions{}).Spec.Template.Spec.Containers
+	if got := env(containers[0]); got["POD_IP"] != "status.podIP" || got["NODE_NAME"] != "spec.nodeName" {
+		t.Errorf("generateStatefulSet() redis env = %v, want the downward API variables", got)
+	}
+	if _, ok := env(containers[1])["POD_IP"]; ok {
+		t.Errorf("generateStatefulSet() exporter env = %v, want no downward API variables", containers[1].Env)
+	}
+	if got := env(containers[2]); got["POD_NAME"] != "metadata.name" || got["NODE_NAME"] != "local" {
+		t.Errorf("generateStatefulSet() sidecar env = %v, want the downward API variables along with its own", got)
+	}
+	if len(r.Spec.Sidecars[0].Env) != 1 {
+		t.Errorf("generateStatefulSet() changed the sidecar spec: %+v", r.Spec.Sidecars[0].Env)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+)
+
+// eventRecorderName is the source of the Events emitted by the Redis controller
+const eventRecorderName = "redis-operator"
+
+// objectKind returns the kind of the object for the Event messages.
+// The generated objects have no TypeMeta set, the Go type name is used then.
+func objectKind(object runtime.Object) string {
+	if kind := object.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	return reflect.Indirect(reflect.ValueOf(object)).Type().Name()
+}
+
+// podNamesOf maps the instance addresses to the Pod names, the unknown addresses are kept as is
+func podNamesOf(addresses []redis.Address, podNames map[string]string) string {
+	names := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		name, ok := podNames[address.Host]
+		if !ok {
+			name = address.String()
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
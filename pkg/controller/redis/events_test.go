@@ -0,0 +1,54 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+)
+
+func Test_objectKind(t *testing.T) {
+	certificate := new(unstructured.Unstructured)
+	certificate.SetGroupVersionKind(certificateGVK)
+
+	tests := []struct {
+		name   string
+		object runtime.Object
+		want   string
+	}{
+		{"generated", new(corev1.Service), "Service"},
+		{"unstructured", certificate, "Certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := objectKind(tt.object); got != tt.want {
+				t.Errorf("objectKind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_podNamesOf(t *testing.T) {
+	podNames := map[string]string{"10.0.0.2": "redis-example-1", "10.0.0.1": "redis-example-0"}
+	addresses := []redis.Address{{Host: "10.0.0.2", Port: "6379"}, {Host: "10.0.0.3", Port: "6379"}, {Host: "10.0.0.1", Port: "6379"}}
+	if got, want := podNamesOf(addresses, podNames), "10.0.0.3:6379, redis-example-0, redis-example-1"; got != want {
+		t.Errorf("podNamesOf() = %v, want %v", got, want)
+	}
+}
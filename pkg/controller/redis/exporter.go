@@ -0,0 +1,184 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"strings"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// exporterPasswordEnvName is the environment variable of the password the exporter authenticates with
+	exporterPasswordEnvName = "REDIS_PASSWORD"
+
+	// telegrafPort is the port of the prometheus_client output of Telegraf
+	telegrafPort = 9273
+	// telegrafConfigEnvName is the environment variable of the Telegraf configuration read from the standard input
+	telegrafConfigEnvName = "TELEGRAF_CONFIG"
+	// telegrafPodNameEnvName is the environment variable of the Pod name the metrics of Telegraf are tagged with
+	telegrafPodNameEnvName = "POD_NAME"
+)
+
+// exporterProvider is an implementation of the exporter sidecar
+type exporterProvider interface {
+	// port returns the port the metrics are served on
+	port() int
+	// probePath returns the path of the HTTP probes of the exporter
+	probePath() string
+	// configure sets the command, the args and the environment of the provider on the exporter container
+	// generated with the credentials and the TLS files of the instance
+	configure(r *k8sv1alpha1.Redis, container *corev1.Container)
+}
+
+// exporterProviders are the implementations of the exporter by provider, redis_exporter if omitted
+var exporterProviders = map[k8sv1alpha1.ExporterProvider]exporterProvider{
+	"": redisExporter{},
+	k8sv1alpha1.ExporterProviderRedisExporter: redisExporter{},
+	k8sv1alpha1.ExporterProviderTelegraf:      telegraf{},
+}
+
+// exporterProviderOf returns the implementation of the exporter of the Redis, redis_exporter if unknown
+func exporterProviderOf(r *k8sv1alpha1.Redis) exporterProvider {
+	if provider, ok := exporterProviders[r.Spec.ExporterProvider]; ok {
+		return provider
+	}
+	return redisExporter{}
+}
+
+// generateExporterContainer returns the exporter container of the provider of the Redis.
+// The exporter connects to the redis container over localhost.
+func generateExporterContainer(r *k8sv1alpha1.Redis) corev1.Container {
+	provider := exporterProviderOf(r)
+	handler := corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: provider.probePath(), Port: intstr.FromInt(provider.port())}}
+	container := corev1.Container{
+		Name:            exporterName,
+		Image:           r.Spec.Exporter.ImageReference(),
+		Resources:       r.Spec.Exporter.Resources,
+		LivenessProbe:   containerProbe(&corev1.Probe{Handler: handler}, r.Spec.Exporter.LivenessProbe),
+		ReadinessProbe:  containerProbe(&corev1.Probe{Handler: handler}, r.Spec.Exporter.ReadinessProbe),
+		StartupProbe:    startupProbe(handler, r.Spec.Exporter.StartupProbe),
+		SecurityContext: r.Spec.Exporter.SecurityContext,
+	}
+
+	if r.Spec.Password.SecretKeyRef != nil {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:      exporterPasswordEnvName,
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: r.Spec.Password.SecretKeyRef},
+		})
+	}
+	if defaultUserDisabled(r) {
+		container.Env = append(container.Env, corev1.EnvVar{Name: exporterUserEnvName, Value: redis.OperatorUser})
+	}
+	if r.Spec.TLS != nil {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      fmt.Sprintf("%s-tls", generateName(r)),
+			ReadOnly:  true,
+			MountPath: tlsMountPath,
+		})
+	}
+
+	provider.configure(r, &container)
+	return container
+}
+
+// redisExporter runs oliver006/redis_exporter
+type redisExporter struct{}
+
+func (redisExporter) port() int { return exporterPort }
+
+func (redisExporter) probePath() string { return "/" }
+
+func (e redisExporter) configure(r *k8sv1alpha1.Redis, container *corev1.Container) {
+	container.Args = []string{fmt.Sprintf("--web.listen-address=:%d", e.port())}
+	container.Env = append([]corev1.EnvVar{{
+		Name: "REDIS_ALIAS",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.name",
+			},
+		},
+	}}, container.Env...)
+
+	// the exporter defaults to redis://localhost:6379, the port is always set
+	scheme := "redis"
+	if r.Spec.TLS != nil {
+		scheme = "rediss"
+	}
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: "REDIS_ADDR", Value: fmt.Sprintf("%s://localhost:%d", scheme, redisPort(r))})
+
+	// the certificate is verified by the CA against localhost, see generateCertificate
+	if r.Spec.TLS != nil {
+		container.Env = append(container.Env,
+			corev1.EnvVar{Name: "REDIS_EXPORTER_TLS_CLIENT_CERT_FILE", Value: tlsCertFilePath},
+			corev1.EnvVar{Name: "REDIS_EXPORTER_TLS_CLIENT_KEY_FILE", Value: tlsKeyFilePath},
+			corev1.EnvVar{Name: "REDIS_EXPORTER_TLS_CA_CERT_FILE", Value: tlsCAFilePath},
+		)
+	}
+}
+
+// telegraf runs Telegraf with the redis input and the prometheus_client output. The configuration is passed
+// in the environment and read from the standard input, so no file has to be mounted into the container.
+type telegraf struct{}
+
+func (telegraf) port() int { return telegrafPort }
+
+func (telegraf) probePath() string { return k8sv1alpha1.DefaultMetricsPath }
+
+func (t telegraf) configure(r *k8sv1alpha1.Redis, container *corev1.Container) {
+	container.Command = []string{"/bin/sh", "-c",
+		fmt.Sprintf("exec telegraf --config /dev/stdin <<EOF\n$%s\nEOF\n", telegrafConfigEnvName)}
+	container.Env = append([]corev1.EnvVar{
+		{Name: telegrafConfigEnvName, Value: t.config(r)},
+		{
+			Name: telegrafPodNameEnvName,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "metadata.name",
+				},
+			},
+		},
+	}, container.Env...)
+}
+
+// config returns the Telegraf configuration. The credentials are substituted by Telegraf from the environment.
+func (t telegraf) config(r *k8sv1alpha1.Redis) string {
+	var b strings.Builder
+	defer b.Reset()
+	_, _ = fmt.Fprintf(&b, "[global_tags]\n  pod = \"${%s}\"\n", telegrafPodNameEnvName)
+	_, _ = fmt.Fprint(&b, "[agent]\n  omit_hostname = true\n")
+
+	_, _ = fmt.Fprintf(&b, "[[inputs.redis]]\n  servers = [\"tcp://localhost:%d\"]\n", redisPort(r))
+	if r.Spec.Password.SecretKeyRef != nil {
+		_, _ = fmt.Fprintf(&b, "  password = \"${%s}\"\n", exporterPasswordEnvName)
+	}
+	if defaultUserDisabled(r) {
+		_, _ = fmt.Fprintf(&b, "  username = \"${%s}\"\n", exporterUserEnvName)
+	}
+	// the certificate is verified by the CA against localhost as well
+	if r.Spec.TLS != nil {
+		_, _ = fmt.Fprintf(&b, "  tls_ca = %q\n  tls_cert = %q\n  tls_key = %q\n", tlsCAFilePath, tlsCertFilePath, tlsKeyFilePath)
+	}
+
+	_, _ = fmt.Fprintf(&b, "[[outputs.prometheus_client]]\n  listen = \":%d\"\n  path = %q\n  metric_version = 2\n",
+		t.port(), k8sv1alpha1.DefaultMetricsPath)
+	return b.String()
+}
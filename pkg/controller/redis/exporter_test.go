@@ -0,0 +1,124 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"strings"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_generateExporterContainer(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    k8sv1alpha1.ExporterProvider
+		tls         *k8sv1alpha1.TLS
+		wantPort    int
+		wantEnv     []string
+		wantCommand bool
+		wantConfig  []string
+	}{
+		{"redis_exporter by default", "", nil, exporterPort, []string{"REDIS_ALIAS", exporterPasswordEnvName, "REDIS_ADDR"}, false, nil},
+		{"redis_exporter with TLS", k8sv1alpha1.ExporterProviderRedisExporter, &k8sv1alpha1.TLS{SecretName: "tls"}, exporterPort,
+			[]string{"REDIS_ALIAS", exporterPasswordEnvName, "REDIS_ADDR", "REDIS_EXPORTER_TLS_CLIENT_CERT_FILE",
+				"REDIS_EXPORTER_TLS_CLIENT_KEY_FILE", "REDIS_EXPORTER_TLS_CA_CERT_FILE"}, false, nil},
+		{"telegraf", k8sv1alpha1.ExporterProviderTelegraf, nil, telegrafPort,
+			[]string{telegrafConfigEnvName, telegrafPodNameEnvName, exporterPasswordEnvName}, true,
+			[]string{`servers = ["tcp://localhost:6379"]`, `password = "${REDIS_PASSWORD}"`, `listen = ":9273"`}},
+		{"telegraf with TLS", k8sv1alpha1.ExporterProviderTelegraf, &k8sv1alpha1.TLS{SecretName: "tls"}, telegrafPort,
+			[]string{telegrafConfigEnvName, telegrafPodNameEnvName, exporterPasswordEnvName}, true,
+			[]string{`tls_ca = "` + tlsCAFilePath + `"`, `tls_cert = "` + tlsCertFilePath + `"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: k8sv1alpha1.RedisSpec{
+				Exporter:         k8sv1alpha1.ContainerSpec{Image: "exporter"},
+				ExporterProvider: tt.provider,
+				Password:         k8sv1alpha1.Password{SecretKeyRef: &corev1.SecretKeySelector{Key: "password"}},
+				TLS:              tt.tls,
+			}}
+			container := generateExporterContainer(r)
+
+			if got := container.ReadinessProbe.HTTPGet.Port.IntValue(); got != tt.wantPort {
+				t.Errorf("generateExporterContainer() probe port = %d, want %d", got, tt.wantPort)
+			}
+			if got := metricsPort(r); got != tt.wantPort {
+				t.Errorf("metricsPort() = %d, want %d", got, tt.wantPort)
+			}
+			var env []string
+			var config string
+			for _, e := range container.Env {
+				env = append(env, e.Name)
+				if e.Name == telegrafConfigEnvName {
+					config = e.Value
+				}
+			}
+			if strings.Join(env, ",") != strings.Join(tt.wantEnv, ",") {
+				t.Errorf("generateExporterContainer() env = %v, want %v", env, tt.wantEnv)
+			}
+			if (len(container.Command) > 0) != tt.wantCommand {
+				t.Errorf("generateExporterContainer() command = %v", container.Command)
+			}
+			for _, want := range tt.wantConfig {
+				if !strings.Contains(config, want) {
+					t.Errorf("generateExporterContainer() config = %s, want %s in it", config, want)
+				}
+			}
+			if strings.Contains(config, "insecure_skip_verify") {
+				t.Errorf("generateExporterContainer() config = %s, want the certificate verified", config)
+			}
+			if (len(container.VolumeMounts) > 0) != (tt.tls != nil) {
+				t.Errorf("generateExporterContainer() volume mounts = %v", container.VolumeMounts)
+			}
+		})
+	}
+}
+
+func Test_generateExporterContainer_port(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider k8sv1alpha1.ExporterProvider
+		tls      *k8sv1alpha1.TLS
+		want     string
+	}{
+		{"redis_exporter", k8sv1alpha1.ExporterProviderRedisExporter, nil, "redis://localhost:7000"},
+		{"redis_exporter with TLS", k8sv1alpha1.ExporterProviderRedisExporter, &k8sv1alpha1.TLS{SecretName: "tls"},
+			"rediss://localhost:7000"},
+		{"telegraf", k8sv1alpha1.ExporterProviderTelegraf, nil, `servers = ["tcp://localhost:7000"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: k8sv1alpha1.RedisSpec{
+				Exporter:         k8sv1alpha1.ContainerSpec{Image: "exporter"},
+				ExporterProvider: tt.provider,
+				Port:             7000,
+				TLS:              tt.tls,
+			}}
+			var got []string
+			for _, e := range generateExporterContainer(r).Env {
+				if e.Name == "REDIS_ADDR" || e.Name == telegrafConfigEnvName {
+					got = append(got, e.Value)
+				}
+			}
+			if len(got) != 1 || !strings.Contains(got[0], tt.want) {
+				t.Errorf("generateExporterContainer() address = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
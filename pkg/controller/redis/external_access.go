@@ -0,0 +1,229 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// announceMountPath is the directory the ConfigMap is mounted to for the announce configurations of the instances
+const announceMountPath = "/config/announce"
+
+// generateExternalService returns the Service exposing the Pod outside of the cluster.
+// The traffic is not forwarded between the nodes, so a NodePort instance is reachable at the node it runs on.
+func generateExternalService(r *k8sv1alpha1.Redis, pod string) *corev1.Service {
+	labels := make(map[string]string)
+	for k, v := range r.GetLabels() {
+		labels[k] = v
+	}
+	labels[headlessServiceTypeLabelKey] = externalServiceTypeLabel
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pod,
+			Namespace:   r.GetNamespace(),
+			Labels:      labels,
+			Annotations: r.Spec.ExternalAccess.Annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:       redisName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       int32(redisPort(r)),
+				TargetPort: intstr.FromInt(redisPort(r)),
+			}},
+			Selector:              map[string]string{appsv1.StatefulSetPodNameLabel: pod},
+			Type:                  r.Spec.ExternalAccess.Type,
+			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+		},
+	}
+}
+
+// externalServiceSelector selects the per-instance Services of the Redis
+func externalServiceSelector(r *k8sv1alpha1.Redis) labels.Selector {
+	set := labels.Set{headlessServiceTypeLabelKey: externalServiceTypeLabel}
+	for k, v := range r.GetLabels() {
+		set[k] = v
+	}
+	return labels.SelectorFromSet(set)
+}
+
+// reconcileExternalAccess creates or updates the Services of the instances, deletes the Services
+// of the removed instances and returns the external addresses of the ready Pods by the Pod addresses
+// along with their status. The Pods whose addresses are not assigned yet are omitted.
+func (reconciler *ReconcileRedis) reconcileExternalAccess(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	pods []corev1.Pod,
+	options objectGeneratorOptions,
+) (map[redis.Address]redis.Address, []k8sv1alpha1.ExternalAddress, error) {
+	wanted := make(map[string]bool)
+	if r.Spec.ExternalAccess != nil {
+		for i := 0; i < int(*r.Spec.Replicas); i++ {
+			wanted[fmt.Sprintf("%s-%d", generateName(r), i)] = true
+		}
+	}
+
+	serviceList := new(corev1.ServiceList)
+	if err := reconciler.client.List(ctx, serviceList, client.InNamespace(r.GetNamespace()),
+		client.MatchingLabelsSelector{Selector: externalServiceSelector(r)}); err != nil {
+		return nil, nil, fmt.Errorf("failed to list Services: %s", err)
+	}
+	services := make(map[string]*corev1.Service)
+	for i := range serviceList.Items {
+		service := &serviceList.Items[i]
+		if wanted[service.Name] {
+			services[service.Name] = service
+			continue
+		}
+		if !metav1.IsControlledBy(service, r) {
+			continue
+		}
+		if err := reconciler.client.Delete(ctx, service); err != nil && !errors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("failed to delete Service: %s", err)
+		}
+	}
+
+	options.serviceType = serviceTypeExternal
+	for pod := range wanted {
+		options.pod = pod
+		// the addresses of the created Services are assigned later, the Service changes are watched
+		if _, err := reconciler.createOrUpdate(ctx, new(corev1.Service), r, options); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	announced := make(map[redis.Address]redis.Address)
+	var statuses []k8sv1alpha1.ExternalAddress
+	nodes := make(map[string]*corev1.Node)
+	for i := range pods {
+		service, ok := services[pods[i].Name]
+		if !ok || !podReady(&pods[i]) {
+			continue
+		}
+
+		var address redis.Address
+		switch service.Spec.Type {
+		case corev1.ServiceTypeLoadBalancer:
+			address = loadBalancerAddress(service, redisPort(r))
+		case corev1.ServiceTypeNodePort:
+			node, ok := nodes[pods[i].Spec.NodeName]
+			if !ok {
+				// the Nodes of the Pods are read through the cache
+				node = new(corev1.Node)
+				if err := reconciler.clusterCache.Get(ctx, types.NamespacedName{Name: pods[i].Spec.NodeName}, node); err != nil {
+					return nil, nil, fmt.Errorf("failed to fetch Node: %s", err)
+				}
+				nodes[node.Name] = node
+			}
+			address = nodePortAddress(service, node)
+		}
+		if address == (redis.Address{}) {
+			continue
+		}
+
+		announced[redis.Address{Host: pods[i].Status.PodIP, Port: strconv.Itoa(redisPort(r))}] = address
+		statuses = append(statuses, k8sv1alpha1.ExternalAddress{Pod: pods[i].Name, Address: address.String()})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Pod < statuses[j].Pod })
+	return announced, statuses, nil
+}
+
+// loadBalancerAddress returns the ingress IP of the LoadBalancer Service and the port, empty until it is provisioned.
+// The ingress hostnames are not used, the instances can only announce IP addresses.
+func loadBalancerAddress(service *corev1.Service, port int) redis.Address {
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return redis.Address{Host: ingress.IP, Port: strconv.Itoa(port)}
+		}
+	}
+	return redis.Address{}
+}
+
+// nodePortAddress returns the external IP of the node, or the internal IP if the node has none, and the node port
+func nodePortAddress(service *corev1.Service, node *corev1.Node) redis.Address {
+	var nodePort int32
+	for _, port := range service.Spec.Ports {
+		if port.Name == redisName {
+			nodePort = port.NodePort
+		}
+	}
+	if nodePort == 0 {
+		return redis.Address{}
+	}
+
+	var host string
+	for _, address := range node.Status.Addresses {
+		switch {
+		case address.Type == corev1.NodeExternalIP:
+			return redis.Address{Host: address.Address, Port: strconv.Itoa(int(nodePort))}
+		case address.Type == corev1.NodeInternalIP && host == "":
+			host = address.Address
+		}
+	}
+	if host == "" {
+		return redis.Address{}
+	}
+	return redis.Address{Host: host, Port: strconv.Itoa(int(nodePort))}
+}
+
+// announceConfigName returns the ConfigMap key of the announce configuration of the Pod
+func announceConfigName(pod string) string {
+	return fmt.Sprintf("announce-%s.conf", pod)
+}
+
+// announceConfigs returns the configurations announcing the external addresses of the instances by the ConfigMap keys,
+// so the restarted instances announce them before the operator sets them. The configuration of an instance whose
+// address is not known is empty: the instances include their configuration on start, it must exist.
+func announceConfigs(r *k8sv1alpha1.Redis, announced map[string]redis.Address) map[string]string {
+	configs := make(map[string]string, int(*r.Spec.Replicas))
+	for i := 0; i < int(*r.Spec.Replicas); i++ {
+		pod := fmt.Sprintf("%s-%d", generateName(r), i)
+		var config string
+		if address, ok := announced[pod]; ok {
+			config = fmt.Sprintf("replica-announce-ip %s\nreplica-announce-port %s\n", address.Host, address.Port)
+		}
+		configs[announceConfigName(pod)] = config
+	}
+	return configs
+}
+
+// announcedAddresses returns the external addresses of the instances by the Pod names
+func announcedAddresses(statuses []k8sv1alpha1.ExternalAddress) map[string]redis.Address {
+	announced := make(map[string]redis.Address, len(statuses))
+	for _, status := range statuses {
+		if host, port, err := net.SplitHostPort(status.Address); err == nil {
+			announced[status.Pod] = redis.Address{Host: host, Port: port}
+		}
+	}
+	return announced
+}
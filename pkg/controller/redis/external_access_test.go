@@ -0,0 +1,176 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func Test_generateExternalService(t *testing.T) {
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: map[string]string{redisName: "test"}},
+		Spec:       k8sv1alpha1.RedisSpec{ExternalAccess: &k8sv1alpha1.ExternalAccess{Type: corev1.ServiceTypeNodePort}},
+	}
+	service := generateExternalService(r, "redis-test-1")
+	if service.Name != "redis-test-1" || service.Spec.Type != corev1.ServiceTypeNodePort {
+		t.Errorf("generateExternalService() = %s of type %s", service.Name, service.Spec.Type)
+	}
+	if service.Spec.Selector["statefulset.kubernetes.io/pod-name"] != "redis-test-1" {
+		t.Errorf("generateExternalService() selector = %v", service.Spec.Selector)
+	}
+	if !externalServiceSelector(r).Matches(labels.Set(service.Labels)) {
+		t.Errorf("externalServiceSelector() does not match %v", service.Labels)
+	}
+	// the ServiceMonitor and the other Services do not select the per-instance Services
+	if externalServiceSelector(r).Matches(labels.Set(generateService(r, serviceTypeAll).Labels)) {
+		t.Error("externalServiceSelector() matches the Service of all the instances")
+	}
+}
+
+func Test_nodePortAddress(t *testing.T) {
+	service := &corev1.Service{Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: redisName, NodePort: 30379}}}}
+	internal := corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.7"}
+	external := corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "198.51.100.7"}
+	hostname := corev1.NodeAddress{Type: corev1.NodeHostName, Address: "node-7"}
+
+	tests := []struct {
+		name      string
+		service   *corev1.Service
+		addresses []corev1.NodeAddress
+		want      redis.Address
+	}{
+		{"external", service, []corev1.NodeAddress{hostname, internal, external}, redis.Address{Host: "198.51.100.7", Port: "30379"}},
+		{"internal", service, []corev1.NodeAddress{hostname, internal}, redis.Address{Host: "10.0.0.7", Port: "30379"}},
+		{"no IP", service, []corev1.NodeAddress{hostname}, redis.Address{}},
+		{"no node port", new(corev1.Service), []corev1.NodeAddress{external}, redis.Address{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{Status: corev1.NodeStatus{Addresses: tt.addresses}}
+			if got := nodePortAddress(tt.service, node); got != tt.want {
+				t.Errorf("nodePortAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_loadBalancerAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		ingress []corev1.LoadBalancerIngress
+		want    redis.Address
+	}{
+		{"pending", nil, redis.Address{}},
+		{"hostname", []corev1.LoadBalancerIngress{{Hostname: "lb.example.com"}}, redis.Address{}},
+		{
+			"IP",
+			[]corev1.LoadBalancerIngress{{Hostname: "lb.example.com"}, {IP: "203.0.113.9"}},
+			redis.Address{Host: "203.0.113.9", Port: "6379"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &corev1.Service{Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: tt.ingress}}}
+			if got := loadBalancerAddress(service, redis.DefaultPort); got != tt.want {
+				t.Errorf("loadBalancerAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_announceConfigs(t *testing.T) {
+	replicas := int32(2)
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec:       k8sv1alpha1.RedisSpec{Replicas: &replicas},
+	}
+	statuses := []k8sv1alpha1.ExternalAddress{
+		{Pod: "redis-test-0", Address: "198.51.100.7:30379"},
+		{Pod: "redis-test-2", Address: "198.51.100.9:30381"},
+		{Pod: "redis-test-3", Address: "invalid"},
+	}
+	announced := announcedAddresses(statuses)
+	if len(announced) != 2 || announced["redis-test-0"] != (redis.Address{Host: "198.51.100.7", Port: "30379"}) {
+		t.Errorf("announcedAddresses() = %v", announced)
+	}
+
+	want := map[string]string{
+		"announce-redis-test-0.conf": "replica-announce-ip 198.51.100.7\nreplica-announce-port 30379\n",
+		"announce-redis-test-1.conf": "",
+	}
+	if got := announceConfigs(r, announced); !reflect.DeepEqual(got, want) {
+		t.Errorf("announceConfigs() = %v, want %v", got, want)
+	}
+}
+
+func Test_configMapUpdateNeeded_announce(t *testing.T) {
+	replicas := int32(2)
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: k8sv1alpha1.RedisSpec{
+			Replicas:       &replicas,
+			ExternalAccess: &k8sv1alpha1.ExternalAccess{Type: corev1.ServiceTypeNodePort},
+		},
+	}
+	first := map[string]redis.Address{"redis-test-0": {Host: "198.51.100.7", Port: "30379"}}
+	got := generateObject(r, new(corev1.ConfigMap), objectGeneratorOptions{announced: first}).(*corev1.ConfigMap)
+
+	// the addresses not known on the first run are kept
+	want := generateObject(r, new(corev1.ConfigMap), objectGeneratorOptions{}).(*corev1.ConfigMap)
+	if configMapUpdateNeeded(got, want) {
+		t.Error("configMapUpdateNeeded() = true, want the announced addresses kept")
+	}
+
+	second := map[string]redis.Address{"redis-test-1": {Host: "198.51.100.8", Port: "30380"}}
+	want = generateObject(r, new(corev1.ConfigMap), objectGeneratorOptions{announced: second}).(*corev1.ConfigMap)
+	if !configMapUpdateNeeded(got, want) {
+		t.Error("configMapUpdateNeeded() = false, want the announced address set")
+	}
+	if got.Data["announce-redis-test-0.conf"] == "" ||
+		got.Data["announce-redis-test-1.conf"] != "replica-announce-ip 198.51.100.8\nreplica-announce-port 30380\n" {
+		t.Errorf("configMapUpdateNeeded() data = %v", got.Data)
+	}
+}
+
+func Test_generateStatefulSet_announce(t *testing.T) {
+	replicas := int32(1)
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: k8sv1alpha1.RedisSpec{
+			Replicas:       &replicas,
+			ExternalAccess: &k8sv1alpha1.ExternalAccess{Type: corev1.ServiceTypeNodePort},
+		},
+	}
+	container := generateStatefulSet(r, objectGeneratorOptions{}).Spec.Template.Spec.Containers[0]
+	wantArgs := []string{configMapMountPath, "--include", "/config/announce/announce-$(POD_NAME).conf"}
+	if !reflect.DeepEqual(container.Args, wantArgs) {
+		t.Errorf("generateStatefulSet() args = %v, want %v", container.Args, wantArgs)
+	}
+	var mounted bool
+	for _, mount := range container.VolumeMounts {
+		mounted = mounted || mount.MountPath == announceMountPath && mount.Name == "redis-test-config"
+	}
+	if !mounted {
+		t.Errorf("generateStatefulSet() mounts = %v, want the ConfigMap at %s", container.VolumeMounts, announceMountPath)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"flag"
+)
+
+// flagOptions are the Options set by the command line flags
+var flagOptions = DefaultOptions()
+
+func init() {
+	flag.StringVar(&flagOptions.RedisClientName, "redis-client-name", flagOptions.RedisClientName,
+		"Client name set on the operator connections to Redis. Empty value disables CLIENT SETNAME")
+	flag.IntVar(&flagOptions.RedisProtocol, "redis-protocol", flagOptions.RedisProtocol,
+		"RESP protocol version negotiated on the operator connections to Redis with HELLO. 0 keeps the server default")
+	flag.IntVar(&flagOptions.ReconcileAPIBudget, "reconcile-api-budget", flagOptions.ReconcileAPIBudget,
+		"Maximum number of write requests to the Kubernetes API per reconciliation. "+
+			"The reconciliation running out of the budget is resumed later. 0 disables the limit")
+	flag.IntVar(&flagOptions.MaxConcurrentReconciles, "max-concurrent-reconciles", flagOptions.MaxConcurrentReconciles,
+		"Number of the Redis and the RedisBackup resources reconciled concurrently")
+	flag.DurationVar(&flagOptions.RateLimiterBaseDelay, "rate-limiter-base-delay", flagOptions.RateLimiterBaseDelay,
+		"Delay the first failed reconciliation of a resource is requeued with, doubled on every consecutive failure")
+	flag.DurationVar(&flagOptions.RateLimiterMaxDelay, "rate-limiter-max-delay", flagOptions.RateLimiterMaxDelay,
+		"Maximum delay the failed reconciliations of a resource are requeued with")
+	flag.Float64Var(&flagOptions.RateLimiterQPS, "rate-limiter-qps", flagOptions.RateLimiterQPS,
+		"Maximum rate the resources are requeued at overall, per second")
+	flag.IntVar(&flagOptions.RateLimiterBurst, "rate-limiter-burst", flagOptions.RateLimiterBurst,
+		"Maximum burst of the resources requeued at once over --rate-limiter-qps")
+	flag.BoolVar(&flagOptions.ServiceMonitors, "service-monitors", flagOptions.ServiceMonitors,
+		"Generate a ServiceMonitor for every Redis with the exporter if the Prometheus Operator is installed")
+	flag.DurationVar(&flagOptions.ReconcileTimeout, "reconcile-timeout", flagOptions.ReconcileTimeout,
+		"Deadline of a single reconciliation. The reconciliation running out of time is requeued with backoff. "+
+			"0 disables the deadline")
+	flag.StringVar(&flagOptions.OperatorNamespace, "operator-namespace", flagOptions.OperatorNamespace,
+		"Namespace of the operator Pods allowed to reach Redis by the generated NetworkPolicies. "+
+			"Defaults to the namespace the operator runs in")
+	flag.StringVar(&flagOptions.OperatorPodLabels, "operator-pod-labels", flagOptions.OperatorPodLabels,
+		"Labels of the operator Pods allowed to reach Redis by the generated NetworkPolicies, e.g. app=redis-operator")
+	flag.StringVar(&flagOptions.NotificationSecret, "notification-secret", flagOptions.NotificationSecret,
+		"Secret holding the Datadog API key or the AWS credentials the failover and health Events are sent with "+
+			"to the Datadog Events API or Amazon EventBridge, namespace/name or a name in the operator namespace")
+	flag.StringVar(&flagOptions.ClusterDomain, "cluster-domain", flagOptions.ClusterDomain,
+		"DNS domain of the cluster used in the stable DNS names of the instances reported in the Redis status")
+}
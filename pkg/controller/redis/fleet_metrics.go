@@ -0,0 +1,136 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/registry"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// fleetListTimeout bounds listing the Redis resources on a scrape
+	fleetListTimeout = 10 * time.Second
+	// unknownVersion is the version of the Redis the image tag of which is unknown
+	unknownVersion = "unknown"
+)
+
+var (
+	fleetRedis = prometheus.NewDesc("redis_operator_fleet_redis",
+		"Number of the Redis resources by namespace", []string{"namespace"}, nil)
+	fleetRedisNotReady = prometheus.NewDesc("redis_operator_fleet_redis_not_ready",
+		"Number of the Redis resources without the Ready condition by namespace", []string{"namespace"}, nil)
+	fleetRedisFailingOver = prometheus.NewDesc("redis_operator_fleet_redis_failing_over",
+		"Number of the Redis resources without an elected master by namespace", []string{"namespace"}, nil)
+	fleetRedisVersions = prometheus.NewDesc("redis_operator_fleet_redis_versions",
+		"Number of the Redis resources by the Redis version", []string{"version"}, nil)
+)
+
+// fleet is the summary of the Redis resources by namespace and by version
+type fleet struct {
+	total, notReady, failingOver map[string]int
+	versions                     map[string]int
+}
+
+// summarizeFleet counts the Redis resources. The Redis is not ready unless the Ready condition is true,
+// and is failing over while the MasterElected condition is false.
+func summarizeFleet(items []k8sv1alpha1.Redis) fleet {
+	f := fleet{
+		total:       make(map[string]int),
+		notReady:    make(map[string]int),
+		failingOver: make(map[string]int),
+		versions:    make(map[string]int),
+	}
+	for i := range items {
+		r := &items[i]
+		namespace := r.GetNamespace()
+		f.total[namespace]++
+		if condition := r.Status.GetCondition(k8sv1alpha1.ConditionReady); condition == nil ||
+			condition.Status != corev1.ConditionTrue {
+			f.notReady[namespace]++
+		}
+		if condition := r.Status.GetCondition(k8sv1alpha1.ConditionMasterElected); condition != nil &&
+			condition.Status == corev1.ConditionFalse {
+			f.failingOver[namespace]++
+		}
+		f.versions[redisVersion(r)]++
+	}
+	return f
+}
+
+// redisVersion returns the tag of the Redis image the instances run, reported in the status,
+// or the tag of the image in the spec until the image is reported
+func redisVersion(r *k8sv1alpha1.Redis) string {
+	image := r.Spec.Redis.Image
+	for _, status := range r.Status.Images {
+		if status.Container == redisName {
+			image = status.Image
+		}
+	}
+	if reference, err := registry.ParseReference(image); err == nil && reference.Tag != "" {
+		return reference.Tag
+	}
+	return unknownVersion
+}
+
+// fleetCollector exports the metrics aggregated over the Redis resources watched by the operator,
+// so the dashboards of the fleet do not scrape every instance. The resources are listed from the cache on scrape.
+type fleetCollector struct {
+	reader client.Reader
+}
+
+// newFleetCollector returns the collector listing the Redis resources with the reader
+func newFleetCollector(reader client.Reader) *fleetCollector {
+	return &fleetCollector{reader: reader}
+}
+
+// Describe implements prometheus.Collector
+func (c *fleetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- fleetRedis
+	ch <- fleetRedisNotReady
+	ch <- fleetRedisFailingOver
+	ch <- fleetRedisVersions
+}
+
+// Collect implements prometheus.Collector
+func (c *fleetCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), fleetListTimeout)
+	defer cancel()
+
+	list := new(k8sv1alpha1.RedisList)
+	if err := c.reader.List(ctx, list); err != nil {
+		ch <- prometheus.NewInvalidMetric(fleetRedis, err)
+		return
+	}
+	f := summarizeFleet(list.Items)
+	// the namespaces without the not ready or failing over Redis are exported with zeros
+	for namespace, total := range f.total {
+		ch <- prometheus.MustNewConstMetric(fleetRedis, prometheus.GaugeValue, float64(total), namespace)
+		ch <- prometheus.MustNewConstMetric(fleetRedisNotReady, prometheus.GaugeValue, float64(f.notReady[namespace]), namespace)
+		ch <- prometheus.MustNewConstMetric(fleetRedisFailingOver, prometheus.GaugeValue,
+			float64(f.failingOver[namespace]), namespace)
+	}
+	for version, count := range f.versions {
+		ch <- prometheus.MustNewConstMetric(fleetRedisVersions, prometheus.GaugeValue, float64(count), version)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fleetReader lists the Redis resources
+type fleetReader []k8sv1alpha1.Redis
+
+func (f fleetReader) Get(context.Context, client.ObjectKey, runtime.Object) error {
+	return nil
+}
+
+func (f fleetReader) List(_ context.Context, list runtime.Object, _ ...client.ListOption) error {
+	list.(*k8sv1alpha1.RedisList).Items = f
+	return nil
+}
+
+// fleetRedisResource returns the Redis in the namespace with the image reported and the conditions set
+func fleetRedisResource(namespace, image string, conditions ...k8sv1alpha1.Condition) k8sv1alpha1.Redis {
+	r := k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Namespace: namespace}}
+	if image != "" {
+		r.Status.Images = []k8sv1alpha1.ImageStatus{{Container: redisName, Image: image}}
+	}
+	r.Status.Conditions = conditions
+	return r
+}
+
+func Test_summarizeFleet(t *testing.T) {
+	ready := newCondition(k8sv1alpha1.ConditionReady, corev1.ConditionTrue, k8sv1alpha1.ReasonReady, "")
+	notReady := newCondition(k8sv1alpha1.ConditionReady, corev1.ConditionFalse, k8sv1alpha1.ReasonNotReady, "")
+	noMaster := newCondition(k8sv1alpha1.ConditionMasterElected, corev1.ConditionFalse, k8sv1alpha1.ReasonNoMaster, "")
+
+	got := summarizeFleet([]k8sv1alpha1.Redis{
+		fleetRedisResource("a", "redis:6.0.9", ready),
+		fleetRedisResource("a", "redis:6.2.1@sha256:"+strings.Repeat("0", 64), notReady, noMaster),
+		fleetRedisResource("b", "redis:6.0.9"),
+		fleetRedisResource("b", ""),
+	})
+	want := fleet{
+		total:       map[string]int{"a": 2, "b": 2},
+		notReady:    map[string]int{"a": 1, "b": 2},
+		failingOver: map[string]int{"a": 1},
+		versions:    map[string]int{"6.0.9": 2, "6.2.1": 1, unknownVersion: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summarizeFleet() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_redisVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		image  string
+		status []k8sv1alpha1.ImageStatus
+		want   string
+	}{
+		{"spec", "redis:6.0.9", nil, "6.0.9"},
+		{"status", "redis:6.0.9", []k8sv1alpha1.ImageStatus{
+			{Container: "exporter", Image: "oliver006/redis_exporter:v1.11.1"},
+			{Container: redisName, Image: "redis:6.2.1"},
+		}, "6.2.1"},
+		{"class", "", nil, unknownVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{
+				Spec:   k8sv1alpha1.RedisSpec{Redis: k8sv1alpha1.ContainerSpec{Image: tt.image}},
+				Status: k8sv1alpha1.RedisStatus{Images: tt.status},
+			}
+			if got := redisVersion(r); got != tt.want {
+				t.Errorf("redisVersion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fleetCollector(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(newFleetCollector(fleetReader{
+		fleetRedisResource("a", "redis:6.0.9"),
+		fleetRedisResource("b", "redis:6.0.9",
+			newCondition(k8sv1alpha1.ConditionReady, corev1.ConditionTrue, k8sv1alpha1.ReasonReady, "")),
+	}))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			got[family.GetName()+"/"+labelValues(metric)] = metric.GetGauge().GetValue()
+		}
+	}
+	want := map[string]float64{
+		"redis_operator_fleet_redis/a":              1,
+		"redis_operator_fleet_redis/b":              1,
+		"redis_operator_fleet_redis_not_ready/a":    1,
+		"redis_operator_fleet_redis_not_ready/b":    0,
+		"redis_operator_fleet_redis_failing_over/a": 0,
+		"redis_operator_fleet_redis_failing_over/b": 0,
+		"redis_operator_fleet_redis_versions/6.0.9": 2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Collect() = %v, want %v", got, want)
+	}
+}
+
+// labelValues joins the label values of the metric
+func labelValues(metric *dto.Metric) string {
+	var values string
+	for _, label := range metric.GetLabel() {
+		values += label.GetValue()
+	}
+	return values
+}
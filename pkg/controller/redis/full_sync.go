@@ -0,0 +1,237 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxFullSyncRestartBackoff caps the time a Pod restarted out of the full synchronization repeatedly waits
+// for the next restart
+const maxFullSyncRestartBackoff = 24 * time.Hour
+
+// fullSyncTracker keeps the time the replicas were first seen in the full synchronization with the master by Redis,
+// so the replicas stuck in it are told apart however often the Redis is reconciled, along with their restarts.
+// It is safe for concurrent use.
+type fullSyncTracker struct {
+	mu       sync.Mutex
+	since    map[types.NamespacedName]map[string]time.Time
+	restarts map[types.NamespacedName]map[string]fullSyncRestart
+}
+
+// fullSyncRestart is the number of the restarts of a Pod stuck in the full synchronization and the time of the last one
+type fullSyncRestart struct {
+	count int
+	at    time.Time
+}
+
+// backoff returns the time the Pod waits after the last restart before it is restarted again:
+// maxDuration doubled with every restart up to maxFullSyncRestartBackoff
+func (r fullSyncRestart) backoff(maxDuration time.Duration) time.Duration {
+	backoff := maxDuration
+	for i := 0; i < r.count && backoff < maxFullSyncRestartBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxFullSyncRestartBackoff {
+		return maxFullSyncRestartBackoff
+	}
+	return backoff
+}
+
+func newFullSyncTracker() *fullSyncTracker {
+	return &fullSyncTracker{
+		since:    make(map[types.NamespacedName]map[string]time.Time),
+		restarts: make(map[types.NamespacedName]map[string]fullSyncRestart),
+	}
+}
+
+// record keeps the time the syncing Pods were first seen syncing and drops the Pods no longer syncing.
+// The restarts of the Pods out of the synchronization for the whole backoff are dropped as well.
+// The Pods syncing for longer than maxDuration are returned sorted.
+func (t *fullSyncTracker) record(key types.NamespacedName, syncing []string, now time.Time,
+	maxDuration time.Duration) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since := make(map[string]time.Time, len(syncing))
+	for _, pod := range syncing {
+		if at, ok := t.since[key][pod]; ok {
+			since[pod] = at
+			continue
+		}
+		since[pod] = now
+	}
+	t.since[key] = since
+	for pod, restart := range t.restarts[key] {
+		if _, ok := since[pod]; !ok && now.Sub(restart.at) > restart.backoff(maxDuration) {
+			delete(t.restarts[key], pod)
+		}
+	}
+	return stuckSince(since, now, maxDuration)
+}
+
+// stuck returns the Pods syncing for longer than maxDuration as of the latest record sorted
+func (t *fullSyncTracker) stuck(key types.NamespacedName, now time.Time, maxDuration time.Duration) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return stuckSince(t.since[key], now, maxDuration)
+}
+
+// backingOff reports whether the Pod has been restarted out of the full synchronization too recently to be
+// restarted again
+func (t *fullSyncTracker) backingOff(key types.NamespacedName, pod string, now time.Time,
+	maxDuration time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	restart, ok := t.restarts[key][pod]
+	return ok && now.Sub(restart.at) < restart.backoff(maxDuration)
+}
+
+// restarted drops the restarted Pod, its next full synchronization is timed anew, and counts the restart
+func (t *fullSyncTracker) restarted(key types.NamespacedName, pod string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.since[key], pod)
+	if t.restarts[key] == nil {
+		t.restarts[key] = make(map[string]fullSyncRestart)
+	}
+	t.restarts[key][pod] = fullSyncRestart{count: t.restarts[key][pod].count + 1, at: now}
+}
+
+// forget drops the Pods of the deleted Redis or the Redis the detection is disabled for
+func (t *fullSyncTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.since, key)
+	delete(t.restarts, key)
+}
+
+func stuckSince(since map[string]time.Time, now time.Time, maxDuration time.Duration) []string {
+	var stuck []string
+	for pod, at := range since {
+		if now.Sub(at) > maxDuration {
+			stuck = append(stuck, pod)
+		}
+	}
+	sort.Strings(stuck)
+	return stuck
+}
+
+// unpromotableReplicas returns the addresses of the replicas stuck in the full synchronization as of the previous
+// reconciliation, they are never promoted to master. None is returned unless spec.maxFullSyncDuration is set.
+func (reconciler *ReconcileRedis) unpromotableReplicas(r *k8sv1alpha1.Redis, addresses []redis.Address,
+	podNames map[string]string) []redis.Address {
+	if r.Spec.MaxFullSyncDuration == nil {
+		return nil
+	}
+	key := types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()}
+	stuck := make(map[string]bool)
+	for _, pod := range reconciler.fullSync.stuck(key, time.Now(), r.Spec.MaxFullSyncDuration.Duration) {
+		stuck[pod] = true
+	}
+
+	var unpromotable []redis.Address
+	for _, address := range addresses {
+		if name, ok := podNames[address.Host]; ok && stuck[name] {
+			unpromotable = append(unpromotable, address)
+		}
+	}
+	return unpromotable
+}
+
+// restartStuckReplicas records the replicas in the full synchronization with the master and deletes a Pod
+// syncing for longer than spec.maxFullSyncDuration to be recreated: a hung full synchronization is not resumed.
+// At most one Pod is deleted per reconciliation and only as long as the ready Pods keep the quorum, and
+// a Pod restarted repeatedly waits for twice as long before every next restart.
+func (reconciler *ReconcileRedis) restartStuckReplicas(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	syncing []redis.Address,
+	pods []corev1.Pod,
+	podNames map[string]string,
+) error {
+	key := types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()}
+	if r.Spec.MaxFullSyncDuration == nil {
+		reconciler.fullSync.forget(key)
+		return nil
+	}
+
+	names := make([]string, 0, len(syncing))
+	for _, address := range syncing {
+		if name, ok := podNames[address.Host]; ok {
+			names = append(names, name)
+		}
+	}
+	now := time.Now()
+	maxDuration := r.Spec.MaxFullSyncDuration.Duration
+	stuck := make(map[string]bool)
+	for _, pod := range reconciler.fullSync.record(key, names, now, maxDuration) {
+		stuck[pod] = true
+	}
+	if len(stuck) == 0 {
+		return nil
+	}
+
+	var ready int
+	for i := range pods {
+		if pods[i].DeletionTimestamp == nil && podReady(&pods[i]) {
+			ready++
+		}
+	}
+	pod := stuckReplicaToRestart(pods, stuck, ready, func(name string) bool {
+		return reconciler.fullSync.backingOff(key, name, now, maxDuration)
+	})
+	if pod == nil {
+		return nil
+	}
+	if err := reconciler.client.Delete(ctx, pod, client.Preconditions{UID: &pod.UID}); err != nil &&
+		!errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete Pod %s: %s", pod.Name, err)
+	}
+	reconciler.fullSync.restarted(key, pod.Name, now)
+	reconciler.recorder.Eventf(r, corev1.EventTypeWarning, k8sv1alpha1.ReasonFullSyncStuck,
+		"Restarted Pod %s stuck in the full synchronization with the master for more than %s",
+		pod.Name, maxDuration)
+	return nil
+}
+
+// stuckReplicaToRestart returns the first stuck Pod neither being deleted nor backing off from the previous restarts,
+// nil if none or if its deletion would leave fewer than redis.MinimumFailoverSize of the ready Pods.
+func stuckReplicaToRestart(pods []corev1.Pod, stuck map[string]bool, ready int,
+	backingOff func(name string) bool) *corev1.Pod {
+	for i := range pods {
+		if !stuck[pods[i].Name] || pods[i].DeletionTimestamp != nil || backingOff(pods[i].Name) {
+			continue
+		}
+		if podReady(&pods[i]) && ready-1 < redis.MinimumFailoverSize {
+			return nil
+		}
+		return &pods[i]
+	}
+	return nil
+}
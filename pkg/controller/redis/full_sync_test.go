@@ -0,0 +1,143 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_fullSyncTracker(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxDuration := 10 * time.Minute
+	tracker := newFullSyncTracker()
+
+	if stuck := tracker.record(key, []string{"redis-test-1", "redis-test-2"}, now, maxDuration); len(stuck) > 0 {
+		t.Errorf("record() = %v, want none", stuck)
+	}
+	// redis-test-2 completes the synchronization, redis-test-3 starts it
+	now = now.Add(5 * time.Minute)
+	if stuck := tracker.record(key, []string{"redis-test-1", "redis-test-3"}, now, maxDuration); len(stuck) > 0 {
+		t.Errorf("record() = %v, want none", stuck)
+	}
+	now = now.Add(6 * time.Minute)
+	if stuck, want := tracker.stuck(key, now, maxDuration), []string{"redis-test-1"}; !reflect.DeepEqual(stuck, want) {
+		t.Errorf("stuck() = %v, want %v", stuck, want)
+	}
+	now = now.Add(5 * time.Minute)
+	if stuck, want := tracker.record(key, []string{"redis-test-3", "redis-test-1"}, now, maxDuration),
+		[]string{"redis-test-1", "redis-test-3"}; !reflect.DeepEqual(stuck, want) {
+		t.Errorf("record() = %v, want %v", stuck, want)
+	}
+
+	// the restarted Pod is timed anew
+	tracker.restarted(key, "redis-test-1", now)
+	if stuck, want := tracker.record(key, []string{"redis-test-1", "redis-test-3"}, now, maxDuration),
+		[]string{"redis-test-3"}; !reflect.DeepEqual(stuck, want) {
+		t.Errorf("record() after restart = %v, want %v", stuck, want)
+	}
+
+	tracker.forget(key)
+	if len(tracker.since) > 0 || len(tracker.restarts) > 0 {
+		t.Errorf("forget() since = %v, restarts = %v, want none", tracker.since, tracker.restarts)
+	}
+}
+
+func Test_fullSyncTracker_backoff(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxDuration := 10 * time.Minute
+	tracker := newFullSyncTracker()
+
+	if tracker.backingOff(key, "redis-test-1", now, maxDuration) {
+		t.Error("backingOff() before the restart = true, want false")
+	}
+	// the backoff doubles with every restart
+	for _, want := range []time.Duration{20 * time.Minute, 40 * time.Minute, 80 * time.Minute} {
+		tracker.restarted(key, "redis-test-1", now)
+		if !tracker.backingOff(key, "redis-test-1", now.Add(want-time.Second), maxDuration) {
+			t.Errorf("backingOff() %s after the restart = false, want true", want-time.Second)
+		}
+		if tracker.backingOff(key, "redis-test-1", now.Add(want), maxDuration) {
+			t.Errorf("backingOff() %s after the restart = true, want false", want)
+		}
+	}
+	if backoff := (fullSyncRestart{count: 20}).backoff(maxDuration); backoff != maxFullSyncRestartBackoff {
+		t.Errorf("backoff() = %s, want %s", backoff, maxFullSyncRestartBackoff)
+	}
+
+	// the restarts are dropped once the Pod stays out of the synchronization for the whole backoff
+	tracker.record(key, []string{"redis-test-1"}, now.Add(time.Hour), maxDuration)
+	if _, ok := tracker.restarts[key]["redis-test-1"]; !ok {
+		t.Error("record() dropped the restarts of the syncing Pod")
+	}
+	tracker.record(key, nil, now.Add(2*time.Hour), maxDuration)
+	if _, ok := tracker.restarts[key]["redis-test-1"]; ok {
+		t.Error("record() kept the restarts of the Pod out of the synchronization")
+	}
+}
+
+func Test_stuckReplicaToRestart(t *testing.T) {
+	pod := func(name string, ready, deleted bool) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if ready {
+			p.Status = corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"}
+		}
+		if deleted {
+			p.DeletionTimestamp = &metav1.Time{}
+		}
+		return p
+	}
+	pods := []corev1.Pod{pod("redis-test-0", true, false), pod("redis-test-1", true, false), pod("redis-test-2", true, false)}
+	notBackingOff := func(string) bool { return false }
+
+	tests := []struct {
+		name       string
+		pods       []corev1.Pod
+		stuck      map[string]bool
+		ready      int
+		backingOff func(string) bool
+		want       string
+	}{
+		{"none stuck", pods, nil, 3, notBackingOff, ""},
+		{"one at a time", pods, map[string]bool{"redis-test-1": true, "redis-test-2": true}, 3, notBackingOff,
+			"redis-test-1"},
+		{"backing off", pods, map[string]bool{"redis-test-1": true, "redis-test-2": true}, 3,
+			func(name string) bool { return name == "redis-test-1" }, "redis-test-2"},
+		{"being deleted", []corev1.Pod{pod("redis-test-0", true, false), pod("redis-test-1", true, true),
+			pod("redis-test-2", true, false)}, map[string]bool{"redis-test-1": true}, 2, notBackingOff, ""},
+		{"quorum kept", pods, map[string]bool{"redis-test-1": true}, 3, notBackingOff, "redis-test-1"},
+		{"quorum lost", pods, map[string]bool{"redis-test-1": true}, 2, notBackingOff, ""},
+		{"not ready", []corev1.Pod{pod("redis-test-0", true, false), pod("redis-test-1", false, false)},
+			map[string]bool{"redis-test-1": true}, 1, notBackingOff, "redis-test-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if pod := stuckReplicaToRestart(tt.pods, tt.stuck, tt.ready, tt.backingOff); pod != nil {
+				got = pod.Name
+			}
+			if got != tt.want {
+				t.Errorf("stuckReplicaToRestart() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
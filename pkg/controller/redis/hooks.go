@@ -0,0 +1,81 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// StatefulSetHook mutates the StatefulSet generated for the Redis before it is applied.
+// The Redis must not be modified.
+type StatefulSetHook func(*appsv1.StatefulSet, *k8sv1alpha1.Redis) error
+
+// ServiceHook mutates the Services generated for the Redis before they are applied, including the per-instance
+// Services. The Redis must not be modified.
+type ServiceHook func(*corev1.Service, *k8sv1alpha1.Redis) error
+
+// ConfigMapHook mutates the ConfigMap generated for the Redis before it is applied.
+// The Redis must not be modified.
+type ConfigMapHook func(*corev1.ConfigMap, *k8sv1alpha1.Redis) error
+
+// Hooks let the embedding operators inject their defaults, e.g. labels, annotations or sidecars,
+// into the generated objects. The hooks are run in order on every reconciliation, a failing hook
+// fails the reconciliation. The objects mutated by the hooks are compared to the existing ones
+// the same way as the generated objects, so the hooks must be deterministic.
+type Hooks struct {
+	StatefulSet []StatefulSetHook
+	Service     []ServiceHook
+	ConfigMap   []ConfigMapHook
+}
+
+// apply runs the hooks of the object type on the generated object
+func (h Hooks) apply(object runtime.Object, r *k8sv1alpha1.Redis) error {
+	switch object := object.(type) {
+	case *appsv1.StatefulSet:
+		if len(h.StatefulSet) == 0 {
+			return nil
+		}
+		// the generated objects share the maps with the Redis
+		*object = *object.DeepCopy()
+		for _, hook := range h.StatefulSet {
+			if err := hook(object, r); err != nil {
+				return fmt.Errorf("StatefulSet hook failed: %s", err)
+			}
+		}
+		// the Pods are restarted on the changes of the hooks too
+		setRevisionHash(object)
+	case *corev1.Service:
+		*object = *object.DeepCopy()
+		for _, hook := range h.Service {
+			if err := hook(object, r); err != nil {
+				return fmt.Errorf("Service hook failed: %s", err)
+			}
+		}
+	case *corev1.ConfigMap:
+		*object = *object.DeepCopy()
+		for _, hook := range h.ConfigMap {
+			if err := hook(object, r); err != nil {
+				return fmt.Errorf("ConfigMap hook failed: %s", err)
+			}
+		}
+	}
+	return nil
+}
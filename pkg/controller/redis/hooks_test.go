@@ -0,0 +1,70 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+)
+
+func TestHooks_apply(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example", Labels: map[string]string{redisName: "example"}}}
+	r.Spec.Redis.Image = "redis"
+
+	hooks := Hooks{
+		StatefulSet: []StatefulSetHook{func(s *appsv1.StatefulSet, _ *k8sv1alpha1.Redis) error {
+			s.Spec.Template.Labels["team"] = "cache"
+			return nil
+		}},
+		Service: []ServiceHook{func(*corev1.Service, *k8sv1alpha1.Redis) error {
+			return errors.New("denied")
+		}},
+	}
+
+	statefulSet := generateStatefulSet(r, objectGeneratorOptions{})
+	hash := statefulSet.Annotations[hashAnnotationKey]
+	if err := hooks.apply(statefulSet, r); err != nil {
+		t.Fatalf("Hooks.apply() error = %v", err)
+	}
+	if statefulSet.Spec.Template.Labels["team"] != "cache" {
+		t.Errorf("Hooks.apply() Pod labels = %v, want the label set by the hook", statefulSet.Spec.Template.Labels)
+	}
+	if _, ok := r.Labels["team"]; ok {
+		t.Errorf("Hooks.apply() changed the Redis labels: %v", r.Labels)
+	}
+	if statefulSet.Annotations[hashAnnotationKey] == hash {
+		t.Errorf("Hooks.apply() revision hash is not updated")
+	}
+
+	// the hash of the unchanged StatefulSet is the same, so the Pods are not restarted
+	unhooked := generateStatefulSet(r, objectGeneratorOptions{})
+	if setRevisionHash(unhooked); unhooked.Annotations[hashAnnotationKey] != hash {
+		t.Errorf("setRevisionHash() = %s, want %s", unhooked.Annotations[hashAnnotationKey], hash)
+	}
+
+	if err := hooks.apply(generateService(r, serviceTypeMaster), r); err == nil {
+		t.Errorf("Hooks.apply() error = nil, want the Service hook error")
+	}
+	if err := hooks.apply(generateConfigMap(r, redis.Address{}), r); err != nil {
+		t.Errorf("Hooks.apply() error = %v for the ConfigMap without hooks", err)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"sort"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultClusterDomain is the DNS domain of the cluster unless set by the --cluster-domain flag
+const defaultClusterDomain = "cluster.local"
+
+// instanceStatuses returns the stable identities of the StatefulSet Pods ordered by the ordinals.
+// The hostname and the subdomain are set by the StatefulSet, they are defaulted the same way if missing.
+func instanceStatuses(r *k8sv1alpha1.Redis, pods []corev1.Pod, master, clusterDomain string) []k8sv1alpha1.InstanceStatus {
+	ordinals := make(map[string]int32)
+	var statuses []k8sv1alpha1.InstanceStatus
+	for i := range pods {
+		ordinal, ok := podOrdinal(r, pods[i].Name)
+		if !ok {
+			continue
+		}
+		ordinals[pods[i].Name] = ordinal
+
+		status := k8sv1alpha1.InstanceStatus{
+			Pod:       pods[i].Name,
+			Hostname:  pods[i].Spec.Hostname,
+			Subdomain: pods[i].Spec.Subdomain,
+		}
+		if status.Hostname == "" {
+			status.Hostname = pods[i].Name
+		}
+		if status.Subdomain == "" {
+			status.Subdomain = generateHeadlessServiceName(r)
+		}
+		status.FQDN = fmt.Sprintf("%s.%s.%s.svc.%s", status.Hostname, status.Subdomain, r.GetNamespace(), clusterDomain)
+		switch {
+		case pods[i].Name == master:
+			status.Role = masterLabel
+		case podReady(&pods[i]):
+			status.Role = replicaLabel
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return ordinals[statuses[i].Pod] < ordinals[statuses[j].Pod] })
+	return statuses
+}
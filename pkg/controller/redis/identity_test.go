@@ -0,0 +1,83 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_instanceStatuses(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}}
+	pod := func(name string, ready bool) corev1.Pod {
+		pod := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.PodSpec{Hostname: name, Subdomain: "redis-example-headless"},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				PodIP:             "10.0.0.1",
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: ready}},
+			},
+		}
+		return pod
+	}
+	unset := pod("redis-example-2", true)
+	unset.Spec = corev1.PodSpec{}
+
+	got := instanceStatuses(r, []corev1.Pod{
+		pod("redis-example-10", true),
+		unset,
+		pod("redis-example-backup-1", true),
+		pod("redis-example-0", true),
+		pod("redis-example-1", false),
+	}, "redis-example-0", "example.org")
+	want := []k8sv1alpha1.InstanceStatus{
+		{
+			Pod:       "redis-example-0",
+			Hostname:  "redis-example-0",
+			Subdomain: "redis-example-headless",
+			FQDN:      "redis-example-0.redis-example-headless.default.svc.example.org",
+			Role:      "master",
+		},
+		{
+			Pod:       "redis-example-1",
+			Hostname:  "redis-example-1",
+			Subdomain: "redis-example-headless",
+			FQDN:      "redis-example-1.redis-example-headless.default.svc.example.org",
+		},
+		{
+			Pod:       "redis-example-2",
+			Hostname:  "redis-example-2",
+			Subdomain: "redis-example-headless",
+			FQDN:      "redis-example-2.redis-example-headless.default.svc.example.org",
+			Role:      "replica",
+		},
+		{
+			Pod:       "redis-example-10",
+			Hostname:  "redis-example-10",
+			Subdomain: "redis-example-headless",
+			FQDN:      "redis-example-10.redis-example-headless.default.svc.example.org",
+			Role:      "replica",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("instanceStatuses() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/registry"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultImageUpdateInterval is the default interval of the registry checks for the image updates
+	defaultImageUpdateInterval = time.Hour
+)
+
+// checkImageUpdate resolves the newest image matching the update policy once the check interval has passed
+// and returns the updated state along with the time left until the next check.
+// The failed check keeps the previously resolved image.
+func (reconciler *ReconcileRedis) checkImageUpdate(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	previous *k8sv1alpha1.ImageUpdateStatus,
+) (*k8sv1alpha1.ImageUpdateStatus, time.Duration) {
+	interval := defaultImageUpdateInterval
+	if r.Spec.ImageUpdatePolicy.Interval != nil && r.Spec.ImageUpdatePolicy.Interval.Duration > 0 {
+		interval = r.Spec.ImageUpdatePolicy.Interval.Duration
+	}
+
+	status := new(k8sv1alpha1.ImageUpdateStatus)
+	if previous != nil {
+		status = previous.DeepCopy()
+	}
+	// the spec image or the track might have changed since the latest check
+	if !imageUpdateApplicable(r, status.AvailableImage) {
+		status.AvailableImage, status.AvailableVersion, status.LastCheckTime = "", "", nil
+	}
+	if status.LastCheckTime != nil {
+		if left := interval - time.Since(status.LastCheckTime.Time); left > 0 {
+			return status, left
+		}
+	}
+
+	now := metav1.Now()
+	status.LastCheckTime = &now
+	image, version, err := reconciler.resolveImageUpdate(ctx, r)
+	if err != nil {
+		log.Info("Failed to check for image updates", "Namespace", r.GetNamespace(), "Redis", r.GetName(), "error", err)
+		status.Message = err.Error()
+		return status, interval
+	}
+	status.AvailableImage, status.AvailableVersion, status.Message = image, version, ""
+	return status, interval
+}
+
+// resolveImageUpdate returns the image of the newest version matching the update policy pinned by digest
+func (reconciler *ReconcileRedis) resolveImageUpdate(ctx context.Context, r *k8sv1alpha1.Redis) (image, version string, err error) {
+	reference, err := registry.ParseReference(r.Spec.Redis.Image)
+	if err != nil {
+		return "", "", err
+	}
+	current, ok := registry.ParseVersion(reference.Tag)
+	if !ok {
+		return "", "", fmt.Errorf("image tag %q is not a version", reference.Tag)
+	}
+
+	client := registry.NewClient(nil, reference, reconciler.imagePullCredentials(ctx, r))
+	tags, err := client.Tags(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list tags of %s: %s", r.Spec.Redis.Image, err)
+	}
+	newest := registry.Newest(current, tags, r.Spec.ImageUpdatePolicy.Track == k8sv1alpha1.ImageUpdateTrackPatch)
+	digest, err := client.Digest(ctx, newest.Tag)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve digest of %s: %s", newest.Tag, err)
+	}
+	return imageWithTag(r.Spec.Redis.Image, newest.Tag, digest), newest.Tag, nil
+}
+
+// imageUpdateApplicable reports whether the resolved image is an update of the spec image within the track
+func imageUpdateApplicable(r *k8sv1alpha1.Redis, image string) bool {
+	if image == "" {
+		return false
+	}
+	specReference, err := registry.ParseReference(r.Spec.Redis.Image)
+	if err != nil {
+		return false
+	}
+	reference, err := registry.ParseReference(image)
+	if err != nil || reference.Registry != specReference.Registry || reference.Repository != specReference.Repository {
+		return false
+	}
+	current, ok := registry.ParseVersion(specReference.Tag)
+	if !ok {
+		return false
+	}
+	available, ok := registry.ParseVersion(reference.Tag)
+	if !ok {
+		return false
+	}
+	sameMinor := r.Spec.ImageUpdatePolicy.Track == k8sv1alpha1.ImageUpdateTrackPatch
+	return registry.Newest(current, []string{available.Tag}, sameMinor) == available
+}
+
+// imageWithTag replaces the tag and the digest of the image keeping the repository as written
+func imageWithTag(image, tag, digest string) string {
+	name := strings.SplitN(image, "@", 2)[0]
+	if slash, colon := strings.LastIndex(name, "/"), strings.LastIndex(name, ":"); colon > slash {
+		name = name[:colon]
+	}
+	return name + ":" + tag + "@" + digest
+}
+
+// runningVersion returns the version of the Redis image tag the named Pod is running
+func runningVersion(pods []corev1.Pod, name string) string {
+	for i := range pods {
+		if pods[i].Name != name {
+			continue
+		}
+		for _, container := range pods[i].Spec.Containers {
+			if container.Name != redisName {
+				continue
+			}
+			if reference, err := registry.ParseReference(container.Image); err == nil {
+				return reference.Tag
+			}
+		}
+	}
+	return ""
+}
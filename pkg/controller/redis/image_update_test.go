@@ -0,0 +1,111 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func Test_imageWithTag(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"redis:6.0.9", "redis:6.0.10@" + testDigest},
+		{"registry:5000/redis:6.0.9@sha256:00", "registry:5000/redis:6.0.10@" + testDigest},
+		{"registry:5000/redis", "registry:5000/redis:6.0.10@" + testDigest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := imageWithTag(tt.image, "6.0.10", testDigest); got != tt.want {
+				t.Errorf("imageWithTag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_imageUpdateApplicable(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  string
+		track k8sv1alpha1.ImageUpdateTrack
+		image string
+		want  bool
+	}{
+		{"patch", "redis:6.0.9", k8sv1alpha1.ImageUpdateTrackPatch, "redis:6.0.10@" + testDigest, true},
+		{"same version", "redis:6.0.9", k8sv1alpha1.ImageUpdateTrackPatch, "redis:6.0.9@" + testDigest, true},
+		{"minor on patch track", "redis:6.0.9", k8sv1alpha1.ImageUpdateTrackPatch, "redis:6.2.1@" + testDigest, false},
+		{"minor", "redis:6.0.9", k8sv1alpha1.ImageUpdateTrackMinor, "redis:6.2.1@" + testDigest, true},
+		{"downgrade", "redis:6.2.1", k8sv1alpha1.ImageUpdateTrackMinor, "redis:6.0.9@" + testDigest, false},
+		{"other variant", "redis:6.0.9-alpine", k8sv1alpha1.ImageUpdateTrackPatch, "redis:6.0.10@" + testDigest, false},
+		{"other repository", "bitnami/redis:6.0.9", k8sv1alpha1.ImageUpdateTrackPatch, "redis:6.0.10@" + testDigest, false},
+		{"not resolved", "redis:6.0.9", k8sv1alpha1.ImageUpdateTrackPatch, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{
+				Redis:             k8sv1alpha1.ContainerSpec{Image: tt.spec},
+				ImageUpdatePolicy: &k8sv1alpha1.ImageUpdatePolicy{Track: tt.track},
+			}}
+			if got := imageUpdateApplicable(r, tt.image); got != tt.want {
+				t.Errorf("imageUpdateApplicable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileRedis_checkImageUpdate(t *testing.T) {
+	checked := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{
+		Redis:             k8sv1alpha1.ContainerSpec{Image: "redis:6.0.9"},
+		ImageUpdatePolicy: &k8sv1alpha1.ImageUpdatePolicy{Track: k8sv1alpha1.ImageUpdateTrackPatch},
+	}}
+	previous := &k8sv1alpha1.ImageUpdateStatus{
+		AvailableImage:   "redis:6.0.10@" + testDigest,
+		AvailableVersion: "6.0.10",
+		LastCheckTime:    &checked,
+	}
+
+	// the registry is not checked within the interval
+	status, left := new(ReconcileRedis).checkImageUpdate(context.Background(), r, previous)
+	if status.AvailableImage != previous.AvailableImage {
+		t.Errorf("checkImageUpdate() image = %v, want %v", status.AvailableImage, previous.AvailableImage)
+	}
+	if left <= 0 || left > defaultImageUpdateInterval-10*time.Minute {
+		t.Errorf("checkImageUpdate() next check in %v", left)
+	}
+}
+
+func Test_runningVersion(t *testing.T) {
+	pods := []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{Name: "redis-example-0"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: exporterName, Image: "oliver006/redis_exporter:v1.11.1"},
+			{Name: redisName, Image: "redis:6.0.10@" + testDigest},
+		}},
+	}}
+	if got := runningVersion(pods, "redis-example-0"); got != "6.0.10" {
+		t.Errorf("runningVersion() = %v, want 6.0.10", got)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"sort"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/cosign"
+	"github.com/amaizfinance/redis-operator/pkg/registry"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// podImages returns the images the containers of the named Pod are running along with the digests
+// resolved by the container runtime, sorted by the container name. Nil is returned if the Pod is not found.
+func podImages(pods []corev1.Pod, name string) []k8sv1alpha1.ImageStatus {
+	for i := range pods {
+		if pods[i].Name != name {
+			continue
+		}
+
+		var images []k8sv1alpha1.ImageStatus
+		for _, statuses := range [][]corev1.ContainerStatus{pods[i].Status.InitContainerStatuses, pods[i].Status.ContainerStatuses} {
+			for _, status := range statuses {
+				images = append(images, k8sv1alpha1.ImageStatus{
+					Container: status.Name,
+					Image:     status.Image,
+					Digest:    k8sv1alpha1.ImageDigestOf(status.ImageID),
+				})
+			}
+		}
+		sort.Slice(images, func(i, j int) bool { return images[i].Container < images[j].Container })
+		return images
+	}
+	return nil
+}
+
+// reportUnverifiedImages reports the images failing the verification with the Warning Event, the log and
+// the metrics the way the failed reconciliation is reported. The returned condition is set along with the status.
+func (reconciler *ReconcileRedis) reportUnverifiedImages(r *k8sv1alpha1.Redis, reason string, err error) *k8sv1alpha1.Condition {
+	log.Info("Image verification failed", "Namespace", r.GetNamespace(), "Redis", r.GetName(),
+		"reason", reason, "error", err)
+	reconcileFailures.WithLabelValues("redis", reason).Inc()
+	reconciler.recorder.Event(r, corev1.EventTypeWarning, reason, err.Error())
+	condition := newCondition(k8sv1alpha1.ConditionImagesVerified, corev1.ConditionFalse, reason, err.Error())
+	return &condition
+}
+
+// heldByImageVerification reports whether the object running the images is not updated since the verification
+// has failed: the StatefulSet and the node-local cache DaemonSet. The rest of the objects, the ConfigMap and
+// the Services following the master among them, are reconciled regardless along with the replication, so
+// the failover of the running Pods is not blocked, e.g. by a registry outage.
+func heldByImageVerification(object runtime.Object, imagesVerified *k8sv1alpha1.Condition) bool {
+	if imagesVerified == nil || imagesVerified.Status == corev1.ConditionTrue {
+		return false
+	}
+	switch object.(type) {
+	case *appsv1.StatefulSet, *appsv1.DaemonSet:
+		return true
+	}
+	return false
+}
+
+// verifyImages verifies the cosign signatures of all the images of the Redis Pods.
+// The returned reason tells the invalid signature from the failure to read the signatures.
+func (reconciler *ReconcileRedis) verifyImages(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	options objectGeneratorOptions,
+	keys []cosign.PublicKey,
+) (string, error) {
+	// the hooks might add containers
+	statefulSet := generateStatefulSet(r, options)
+	if err := reconciler.options.Hooks.apply(statefulSet, r); err != nil {
+		return k8sv1alpha1.ReasonImageVerificationUnavailable, err
+	}
+	credentials := reconciler.imagePullCredentials(ctx, r)
+	for _, image := range podTemplateImages(statefulSet.Spec.Template.Spec) {
+		if err := reconciler.imageVerifier.Verify(ctx, image, keys, credentials); err != nil {
+			if _, ok := err.(*cosign.VerificationError); ok {
+				return k8sv1alpha1.ReasonImageSignatureInvalid, err
+			}
+			return k8sv1alpha1.ReasonImageVerificationUnavailable, err
+		}
+	}
+	return k8sv1alpha1.ReasonImagesVerified, nil
+}
+
+// imagePullCredentials returns the registry credentials of the image pull Secrets of the Redis.
+// The Secrets failing to be read are skipped, the registries are accessed anonymously then.
+func (reconciler *ReconcileRedis) imagePullCredentials(ctx context.Context, r *k8sv1alpha1.Redis) map[string]registry.Credentials {
+	credentials := make(map[string]registry.Credentials)
+	for _, reference := range r.Spec.ImagePullSecrets {
+		secret := new(corev1.Secret)
+		if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: reference.Name}, secret); err != nil {
+			log.V(1).Info("Failed to fetch image pull Secret", "Namespace", r.GetNamespace(), "Secret", reference.Name, "error", err)
+			continue
+		}
+		parsed, err := registry.ParseDockerConfig(secret.Data[corev1.DockerConfigJsonKey])
+		if err != nil {
+			log.V(1).Info("Invalid image pull Secret", "Namespace", r.GetNamespace(), "Secret", reference.Name, "error", err)
+			continue
+		}
+		for registry, c := range parsed {
+			credentials[registry] = c
+		}
+	}
+	return credentials
+}
+
+// podTemplateImages returns the distinct images of the init containers and the containers in order
+func podTemplateImages(spec corev1.PodSpec) []string {
+	var images []string
+	seen := make(map[string]bool)
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			if !seen[container.Image] {
+				seen[container.Image] = true
+				images = append(images, container.Image)
+			}
+		}
+	}
+	return images
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_podImages(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "redis-example-0"}},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "redis-example-1"},
+			Status: corev1.PodStatus{
+				InitContainerStatuses: []corev1.ContainerStatus{
+					{Name: "restore", Image: "rclone/rclone", ImageID: "docker-pullable://rclone/rclone@" + digest},
+				},
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "redis", Image: "redis:6.0", ImageID: "docker.io/library/redis@" + digest},
+					{Name: "exporter", Image: "exporter", ImageID: "sha256:fedcba"},
+				},
+			},
+		},
+	}
+
+	want := []k8sv1alpha1.ImageStatus{
+		{Container: "exporter", Image: "exporter"},
+		{Container: "redis", Image: "redis:6.0", Digest: digest},
+		{Container: "restore", Image: "rclone/rclone", Digest: digest},
+	}
+	if got := podImages(pods, "redis-example-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("podImages() = %v, want %v", got, want)
+	}
+	if got := podImages(pods, "redis-example-2"); got != nil {
+		t.Errorf("podImages() = %v, want nil", got)
+	}
+}
+
+func Test_podTemplateImages(t *testing.T) {
+	spec := corev1.PodSpec{
+		InitContainers: []corev1.Container{{Image: "redis"}, {Image: "rclone"}},
+		Containers:     []corev1.Container{{Image: "redis"}, {Image: "exporter"}},
+	}
+	if got, want := podTemplateImages(spec), []string{"redis", "rclone", "exporter"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("podTemplateImages() = %v, want %v", got, want)
+	}
+}
+
+func Test_heldByImageVerification(t *testing.T) {
+	unavailable := newCondition(k8sv1alpha1.ConditionImagesVerified, corev1.ConditionFalse,
+		k8sv1alpha1.ReasonImageVerificationUnavailable, "registry is not reachable")
+	verified := newCondition(k8sv1alpha1.ConditionImagesVerified, corev1.ConditionTrue,
+		k8sv1alpha1.ReasonImagesVerified, "signatures of all the images are verified")
+
+	tests := []struct {
+		name           string
+		object         runtime.Object
+		imagesVerified *k8sv1alpha1.Condition
+		want           bool
+	}{
+		{"verification disabled", new(appsv1.StatefulSet), nil, false},
+		{"verified", new(appsv1.StatefulSet), &verified, false},
+		{"StatefulSet while unavailable", new(appsv1.StatefulSet), &unavailable, true},
+		{"node-local cache while unavailable", new(appsv1.DaemonSet), &unavailable, true},
+		// the failover is reflected in the ConfigMap and the master Service
+		{"ConfigMap while unavailable", new(corev1.ConfigMap), &unavailable, false},
+		{"Service while unavailable", new(corev1.Service), &unavailable, false},
+		{"Secret while unavailable", new(corev1.Secret), &unavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := heldByImageVerification(tt.object, tt.imagesVerified); got != tt.want {
+				t.Errorf("heldByImageVerification() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// keyspaceSampleInterval is the minimum period the rates of the expired and evicted keys are measured over
+const keyspaceSampleInterval = time.Minute
+
+var (
+	keyspaceLabels = []string{"namespace", "redis", "pod"}
+
+	keyspaceKeys = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_operator_keyspace_keys",
+		Help: "Number of keys of the Redis instance",
+	}, keyspaceLabels)
+	keyspaceExpires = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_operator_keyspace_keys_with_ttl",
+		Help: "Number of keys with a TTL of the Redis instance",
+	}, keyspaceLabels)
+	keyspaceExpiredRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_operator_keyspace_expired_keys_per_second",
+		Help: "Rate of the keys expired by the Redis instance since the previous sample",
+	}, keyspaceLabels)
+	keyspaceEvictedRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_operator_keyspace_evicted_keys_per_second",
+		Help: "Rate of the keys evicted by the Redis instance since the previous sample",
+	}, keyspaceLabels)
+)
+
+func init() {
+	metrics.Registry.MustRegister(keyspaceKeys, keyspaceExpires, keyspaceExpiredRate, keyspaceEvictedRate)
+}
+
+// keyspaceSample holds the keyspace stats by Pod at the time they were sampled
+type keyspaceSample struct {
+	stats map[string]redis.KeyspaceStats
+	at    time.Time
+}
+
+// keyspaceRates are the rates of the keys expired and evicted by a Pod per second
+type keyspaceRates struct {
+	expired float64
+	evicted float64
+}
+
+// keyspaceRatesOf returns the rates by Pod between the samples. The counters reset by the restarts are not compared.
+func keyspaceRatesOf(previous, current keyspaceSample) map[string]keyspaceRates {
+	seconds := current.at.Sub(previous.at).Seconds()
+	rates := make(map[string]keyspaceRates)
+	if seconds <= 0 {
+		return rates
+	}
+	for pod, stats := range current.stats {
+		before, ok := previous.stats[pod]
+		if !ok || stats.ExpiredKeys < before.ExpiredKeys || stats.EvictedKeys < before.EvictedKeys {
+			continue
+		}
+		rates[pod] = keyspaceRates{
+			expired: float64(stats.ExpiredKeys-before.ExpiredKeys) / seconds,
+			evicted: float64(stats.EvictedKeys-before.EvictedKeys) / seconds,
+		}
+	}
+	return rates
+}
+
+// keyspaceTracker keeps the latest keyspace samples by Redis, so the rates are measured over
+// keyspaceSampleInterval however often the Redis is reconciled. It is safe for concurrent use.
+type keyspaceTracker struct {
+	mu      sync.Mutex
+	samples map[types.NamespacedName]keyspaceSample
+}
+
+func newKeyspaceTracker() *keyspaceTracker {
+	return &keyspaceTracker{samples: make(map[types.NamespacedName]keyspaceSample)}
+}
+
+// sample records the sample and returns the previous one. sampled is false and the sample is not recorded
+// if keyspaceSampleInterval has not passed since the previous sample; it is false for the first sample as well.
+func (t *keyspaceTracker) sample(key types.NamespacedName, current keyspaceSample) (keyspaceSample, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous, ok := t.samples[key]
+	if ok && current.at.Sub(previous.at) < keyspaceSampleInterval {
+		return keyspaceSample{}, false
+	}
+	t.samples[key] = current
+	return previous, ok
+}
+
+// forget drops the samples of the Redis and deletes the metrics of its Pods
+func (t *keyspaceTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for pod := range t.samples[key].stats {
+		deleteKeyspaceMetrics(key, pod)
+	}
+	delete(t.samples, key)
+}
+
+func deleteKeyspaceMetrics(key types.NamespacedName, pod string) {
+	for _, gauge := range []*prometheus.GaugeVec{keyspaceKeys, keyspaceExpires, keyspaceExpiredRate, keyspaceEvictedRate} {
+		gauge.DeleteLabelValues(key.Namespace, key.Name, pod)
+	}
+}
+
+// evictionCondition builds the EvictionRateHigh condition out of the eviction rates by Pod and the threshold
+func evictionCondition(rates map[string]keyspaceRates, threshold int32) k8sv1alpha1.Condition {
+	var high []string
+	for pod, rate := range rates {
+		if rate.evicted > float64(threshold) {
+			high = append(high, fmt.Sprintf("%s: %.1f/s", pod, rate.evicted))
+		}
+	}
+	if len(high) == 0 {
+		return k8sv1alpha1.Condition{
+			Type:    k8sv1alpha1.ConditionEvictionRateHigh,
+			Status:  corev1.ConditionFalse,
+			Reason:  k8sv1alpha1.ReasonEvictionRateOK,
+			Message: fmt.Sprintf("no instance has evicted more than %d keys per second since the previous sample", threshold),
+		}
+	}
+
+	sort.Strings(high)
+	return k8sv1alpha1.Condition{
+		Type:   k8sv1alpha1.ConditionEvictionRateHigh,
+		Status: corev1.ConditionTrue,
+		Reason: k8sv1alpha1.ReasonEvictionRateHigh,
+		Message: fmt.Sprintf("keys evicted above %d per second since the previous sample: %s. The dataset does not fit "+
+			"in maxmemory: raise maxmemory along with the memory limit, or review the maxmemory-policy and the TTLs "+
+			"of the keys", threshold, strings.Join(high, ", ")),
+	}
+}
+
+// checkEvictions samples the keyspace of the instances, exports the keyspace metrics and sets
+// the EvictionRateHigh condition once per keyspaceSampleInterval. A Warning Event is emitted when
+// the evictions start exceeding the threshold. The condition is removed if the threshold is unset.
+func (reconciler *ReconcileRedis) checkEvictions(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	status *k8sv1alpha1.RedisStatus,
+	replication redis.Replication,
+	podNames map[string]string,
+) error {
+	key := types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()}
+	if r.Spec.EvictionRateThreshold == nil {
+		reconciler.keyspace.forget(key)
+		status.RemoveCondition(k8sv1alpha1.ConditionEvictionRateHigh)
+		return nil
+	}
+
+	stats, err := replication.GetKeyspaceStats(ctx)
+	if err != nil {
+		return err
+	}
+	current := keyspaceSample{stats: make(map[string]redis.KeyspaceStats, len(stats)), at: time.Now()}
+	for address, s := range stats {
+		name, ok := podNames[address.Host]
+		if !ok {
+			name = address.String()
+		}
+		current.stats[name] = s
+		keyspaceKeys.WithLabelValues(key.Namespace, key.Name, name).Set(float64(s.Keys))
+		keyspaceExpires.WithLabelValues(key.Namespace, key.Name, name).Set(float64(s.Expires))
+	}
+
+	previous, sampled := reconciler.keyspace.sample(key, current)
+	if !sampled {
+		return nil
+	}
+	for pod := range previous.stats {
+		if _, ok := current.stats[pod]; !ok {
+			deleteKeyspaceMetrics(key, pod)
+		}
+	}
+	rates := keyspaceRatesOf(previous, current)
+	for pod, rate := range rates {
+		keyspaceExpiredRate.WithLabelValues(key.Namespace, key.Name, pod).Set(rate.expired)
+		keyspaceEvictedRate.WithLabelValues(key.Namespace, key.Name, pod).Set(rate.evicted)
+	}
+
+	condition := evictionCondition(rates, *r.Spec.EvictionRateThreshold)
+	if previous := status.GetCondition(condition.Type); condition.Status == corev1.ConditionTrue &&
+		(previous == nil || previous.Status != corev1.ConditionTrue) {
+		reconciler.recorder.Event(r, corev1.EventTypeWarning, condition.Reason, condition.Message)
+	}
+	status.SetCondition(condition)
+	return nil
+}
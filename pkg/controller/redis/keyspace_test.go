@@ -0,0 +1,95 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_keyspaceRatesOf(t *testing.T) {
+	now := time.Now()
+	previous := keyspaceSample{at: now.Add(-time.Minute), stats: map[string]redis.KeyspaceStats{
+		"redis-example-0": {ExpiredKeys: 100, EvictedKeys: 0},
+		"redis-example-1": {ExpiredKeys: 600, EvictedKeys: 60},
+		"redis-example-2": {ExpiredKeys: 100},
+	}}
+	current := keyspaceSample{at: now, stats: map[string]redis.KeyspaceStats{
+		"redis-example-0": {ExpiredKeys: 160, EvictedKeys: 0},
+		"redis-example-1": {ExpiredKeys: 600, EvictedKeys: 6060},
+		// restarted
+		"redis-example-2": {ExpiredKeys: 10},
+		// added
+		"redis-example-3": {ExpiredKeys: 10},
+	}}
+
+	want := map[string]keyspaceRates{
+		"redis-example-0": {expired: 1},
+		"redis-example-1": {evicted: 100},
+	}
+	if got := keyspaceRatesOf(previous, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("keyspaceRatesOf() = %v, want %v", got, want)
+	}
+}
+
+func Test_keyspaceTracker_sample(t *testing.T) {
+	tracker := newKeyspaceTracker()
+	key := types.NamespacedName{Namespace: "default", Name: "example"}
+	now := time.Now()
+
+	if _, sampled := tracker.sample(key, keyspaceSample{at: now}); sampled {
+		t.Errorf("keyspaceTracker.sample() first sample sampled = true, want false")
+	}
+	if _, sampled := tracker.sample(key, keyspaceSample{at: now.Add(time.Second)}); sampled {
+		t.Errorf("keyspaceTracker.sample() within the interval sampled = true, want false")
+	}
+	previous, sampled := tracker.sample(key, keyspaceSample{at: now.Add(keyspaceSampleInterval)})
+	if !sampled || !previous.at.Equal(now) {
+		t.Errorf("keyspaceTracker.sample() = %v, %v, want the first sample", previous.at, sampled)
+	}
+
+	tracker.forget(key)
+	if _, sampled := tracker.sample(key, keyspaceSample{at: now.Add(2 * keyspaceSampleInterval)}); sampled {
+		t.Errorf("keyspaceTracker.sample() after forget sampled = true, want false")
+	}
+}
+
+func Test_evictionCondition(t *testing.T) {
+	rates := map[string]keyspaceRates{"redis-example-0": {evicted: 0.5}, "redis-example-1": {evicted: 120}}
+	if got := evictionCondition(rates, 200); got.Status != corev1.ConditionFalse || got.Reason != k8sv1alpha1.ReasonEvictionRateOK {
+		t.Errorf("evictionCondition() = %+v, want %s", got, k8sv1alpha1.ReasonEvictionRateOK)
+	}
+
+	got := evictionCondition(rates, 100)
+	if got.Status != corev1.ConditionTrue || got.Reason != k8sv1alpha1.ReasonEvictionRateHigh {
+		t.Errorf("evictionCondition() = %+v, want %s", got, k8sv1alpha1.ReasonEvictionRateHigh)
+	}
+	for _, want := range []string{"redis-example-1: 120.0/s", "maxmemory-policy"} {
+		if !strings.Contains(got.Message, want) {
+			t.Errorf("evictionCondition() message = %q, want it to contain %q", got.Message, want)
+		}
+	}
+	if strings.Contains(got.Message, "redis-example-0") {
+		t.Errorf("evictionCondition() message = %q, want it to omit redis-example-0", got.Message)
+	}
+}
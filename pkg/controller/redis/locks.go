@@ -0,0 +1,101 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// replicationLocks serialize the replication operations of a Redis and its blue/green peer. The workqueue never
+// reconciles the same Redis concurrently, but the peers reconfigure the instances of each other and are reconciled
+// by different workers once MaxConcurrentReconciles is above 1. It is safe for concurrent use.
+type replicationLocks struct {
+	mu    sync.Mutex
+	locks map[types.NamespacedName]*replicationLock
+}
+
+// replicationLock is held by sending to the channel. waiters counts the reconciliations holding
+// or waiting for the lock, so that it is deleted once unused.
+type replicationLock struct {
+	held    chan struct{}
+	waiters int
+}
+
+func newReplicationLocks() *replicationLocks {
+	return &replicationLocks{locks: make(map[types.NamespacedName]*replicationLock)}
+}
+
+// lock acquires the locks of the Redis in the order of their names, so the peers locking each other never deadlock.
+// It returns the function releasing the locks, or the context error if the context is done first.
+func (l *replicationLocks) lock(ctx context.Context, keys ...types.NamespacedName) (func(), error) {
+	unique := make(map[types.NamespacedName]bool, len(keys))
+	sorted := make([]types.NamespacedName, 0, len(keys))
+	for _, key := range keys {
+		if !unique[key] {
+			unique[key] = true
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+
+	var acquired []types.NamespacedName
+	unlock := func() {
+		for i := len(acquired) - 1; i >= 0; i-- {
+			l.release(acquired[i], true)
+		}
+	}
+	for _, key := range sorted {
+		lock := l.acquire(key)
+		select {
+		case lock.held <- struct{}{}:
+			acquired = append(acquired, key)
+		case <-ctx.Done():
+			l.release(key, false)
+			unlock()
+			return nil, ctx.Err()
+		}
+	}
+	return unlock, nil
+}
+
+// acquire returns the lock of the Redis counting the caller as a waiter
+func (l *replicationLocks) acquire(key types.NamespacedName) *replicationLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &replicationLock{held: make(chan struct{}, 1)}
+		l.locks[key] = lock
+	}
+	lock.waiters++
+	return lock
+}
+
+// release releases the lock of the Redis if held and deletes the lock once nobody waits for it
+func (l *replicationLocks) release(key types.NamespacedName, held bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock := l.locks[key]
+	if held {
+		<-lock.held
+	}
+	if lock.waiters--; lock.waiters == 0 {
+		delete(l.locks, key)
+	}
+}
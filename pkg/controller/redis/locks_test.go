@@ -0,0 +1,74 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_replicationLocks(t *testing.T) {
+	blue := types.NamespacedName{Namespace: "default", Name: "blue"}
+	green := types.NamespacedName{Namespace: "default", Name: "green"}
+	other := types.NamespacedName{Namespace: "default", Name: "other"}
+	locks := newReplicationLocks()
+
+	// the peers lock each other in the opposite order
+	unlock, err := locks.lock(context.TODO(), green, blue)
+	if err != nil {
+		t.Fatalf("lock() error = %v", err)
+	}
+
+	unlockOther, err := locks.lock(context.TODO(), other, other)
+	if err != nil {
+		t.Fatalf("lock() of the unrelated Redis error = %v", err)
+	}
+	unlockOther()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := locks.lock(ctx, blue, green); err != context.DeadlineExceeded {
+		t.Fatalf("lock() of the locked peers error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		unlock, err := locks.lock(context.TODO(), blue, green)
+		if err != nil {
+			t.Errorf("lock() error = %v", err)
+		}
+		acquired <- unlock
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("lock() has acquired the locks held")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case unlock := <-acquired:
+		unlock()
+	case <-time.After(time.Second):
+		t.Fatal("lock() has not acquired the released locks")
+	}
+
+	if len(locks.locks) != 0 {
+		t.Errorf("replicationLocks have kept %d unused locks", len(locks.locks))
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// memberOf reports whether the Pod is an instance of the Redis: a Pod of its StatefulSet within the replicas.
+// The Pods merely matching the labels of the Redis, e.g. created by kubectl debug --copy-to or by hand,
+// and those left over by a scale-down are not a part of the replication.
+func memberOf(r *k8sv1alpha1.Redis, pod *corev1.Pod) bool {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.Kind != "StatefulSet" || owner.Name != generateName(r) {
+		return false
+	}
+	ordinal, ok := podOrdinal(r, pod.Name)
+	return ok && ordinal < *r.Spec.Replicas
+}
+
+// partitionMembers splits the Pods matching the labels of the Redis into its instances and the others
+func partitionMembers(r *k8sv1alpha1.Redis, pods []corev1.Pod) (members, others []corev1.Pod) {
+	for i := range pods {
+		if memberOf(r, &pods[i]) {
+			members = append(members, pods[i])
+		} else {
+			others = append(others, pods[i])
+		}
+	}
+	return members, others
+}
+
+// stripRoleLabels removes the role label from the Pods that are not the instances of the Redis,
+// so that neither the master nor the replica Service routes to them. It returns the names of the changed Pods.
+func (reconciler *ReconcileRedis) stripRoleLabels(ctx context.Context, others []corev1.Pod) ([]string, error) {
+	var stripped []string
+	for i := range others {
+		if _, ok := others[i].Labels[roleLabelKey]; !ok {
+			continue
+		}
+		pod := others[i].DeepCopy()
+		delete(pod.Labels, roleLabelKey)
+		if err := reconciler.client.Patch(ctx, pod, client.MergeFrom(&others[i])); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return stripped, fmt.Errorf("failed to remove the role label of Pod %s: %s", pod.Name, err)
+		}
+		stripped = append(stripped, pod.Name)
+	}
+	return stripped, nil
+}
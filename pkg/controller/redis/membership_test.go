@@ -0,0 +1,106 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_partitionMembers(t *testing.T) {
+	replicas, controller := int32(3), true
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Labels: map[string]string{"app": "cache", redisName: "example"}},
+		Spec:       k8sv1alpha1.RedisSpec{Replicas: &replicas},
+	}
+	controlledBy := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+	}
+	pod := func(name string, owners []metav1.OwnerReference) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Labels:          map[string]string{"app": "cache", redisName: "example", roleLabelKey: masterLabel},
+			OwnerReferences: owners,
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		pods        []corev1.Pod
+		wantMembers []string
+		wantOthers  []string
+	}{
+		{
+			"instances",
+			[]corev1.Pod{pod("redis-example-0", controlledBy("StatefulSet", "redis-example")),
+				pod("redis-example-2", controlledBy("StatefulSet", "redis-example"))},
+			[]string{"redis-example-0", "redis-example-2"},
+			nil,
+		},
+		{
+			"copied by kubectl debug",
+			[]corev1.Pod{pod("redis-example-0", controlledBy("StatefulSet", "redis-example")), pod("redis-example-0-debug", nil)},
+			[]string{"redis-example-0"},
+			[]string{"redis-example-0-debug"},
+		},
+		{
+			"orphaned with the name of an instance",
+			[]corev1.Pod{pod("redis-example-1", []metav1.OwnerReference{{Kind: "StatefulSet", Name: "redis-example"}})},
+			nil,
+			[]string{"redis-example-1"},
+		},
+		{
+			"another StatefulSet",
+			[]corev1.Pod{pod("redis-example-1", controlledBy("StatefulSet", "redis-other"))},
+			nil,
+			[]string{"redis-example-1"},
+		},
+		{
+			"another controller",
+			[]corev1.Pod{pod("redis-example-5f7b9c-x2x7q", controlledBy("ReplicaSet", "redis-example-5f7b9c"))},
+			nil,
+			[]string{"redis-example-5f7b9c-x2x7q"},
+		},
+		{
+			"scale-down remnant",
+			[]corev1.Pod{pod("redis-example-2", controlledBy("StatefulSet", "redis-example")),
+				pod("redis-example-3", controlledBy("StatefulSet", "redis-example"))},
+			[]string{"redis-example-2"},
+			[]string{"redis-example-3"},
+		},
+	}
+	names := func(pods []corev1.Pod) (names []string) {
+		for i := range pods {
+			names = append(names, pods[i].Name)
+		}
+		return names
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			members, others := partitionMembers(r, tt.pods)
+			if !reflect.DeepEqual(names(members), tt.wantMembers) {
+				t.Errorf("partitionMembers() members = %v, want %v", names(members), tt.wantMembers)
+			}
+			if !reflect.DeepEqual(names(others), tt.wantOthers) {
+				t.Errorf("partitionMembers() others = %v, want %v", names(others), tt.wantOthers)
+			}
+		})
+	}
+}
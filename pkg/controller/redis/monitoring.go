@@ -0,0 +1,160 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	// apiDiscoveryInterval is the interval the optional APIs are rediscovered at,
+	// so the Prometheus Operator installed after the operator is picked up
+	apiDiscoveryInterval = 5 * time.Minute
+)
+
+var (
+	// serviceMonitorGVK is the Prometheus Operator ServiceMonitor kind.
+	// Prometheus Operator types are not vendored, ServiceMonitors are managed as unstructured objects.
+	serviceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+)
+
+// apiDiscovery caches whether the optional kinds are served by the cluster
+type apiDiscovery struct {
+	client discovery.DiscoveryInterface
+
+	mu         sync.Mutex
+	discovered map[schema.GroupVersionKind]time.Time
+	served     map[schema.GroupVersionKind]bool
+}
+
+func newAPIDiscovery(client discovery.DiscoveryInterface) *apiDiscovery {
+	return &apiDiscovery{
+		client:     client,
+		discovered: make(map[schema.GroupVersionKind]time.Time),
+		served:     make(map[schema.GroupVersionKind]bool),
+	}
+}
+
+// serves reports whether the kind is served by the cluster, the discovery failures are reported as not served
+func (d *apiDiscovery) serves(gvk schema.GroupVersionKind) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if time.Since(d.discovered[gvk]) < apiDiscoveryInterval {
+		return d.served[gvk]
+	}
+
+	served := false
+	if resources, err := d.client.ServerResourcesForGroupVersion(gvk.GroupVersion().String()); err == nil {
+		for _, resource := range resources.APIResources {
+			if resource.Kind == gvk.Kind {
+				served = true
+				break
+			}
+		}
+	}
+	d.discovered[gvk], d.served[gvk] = time.Now(), served
+	return served
+}
+
+// newServiceMonitor returns an empty ServiceMonitor to be filled in by createOrUpdate
+func newServiceMonitor() *unstructured.Unstructured {
+	serviceMonitor := new(unstructured.Unstructured)
+	serviceMonitor.SetGroupVersionKind(serviceMonitorGVK)
+	return serviceMonitor
+}
+
+// exporterEnabled reports whether the exporter or the metrics sidecar is configured
+func exporterEnabled(r *k8sv1alpha1.Redis) bool {
+	return r.Spec.Metrics != nil || !reflect.DeepEqual(r.Spec.Exporter, k8sv1alpha1.ContainerSpec{})
+}
+
+// metricsPort returns the port the metrics are served on: the port of the metrics sidecar or of the exporter provider
+func metricsPort(r *k8sv1alpha1.Redis) int {
+	if r.Spec.Metrics != nil {
+		return int(r.Spec.Metrics.Port)
+	}
+	return exporterProviderOf(r).port()
+}
+
+// generateServiceMonitor returns the ServiceMonitor scraping the exporter port of the Service selecting all the Pods.
+// The headless and master Services carry the same labels and are excluded, so every instance is scraped once.
+func generateServiceMonitor(r *k8sv1alpha1.Redis) *unstructured.Unstructured {
+	matchLabels := make(map[string]interface{}, len(r.GetLabels()))
+	for k, v := range r.GetLabels() {
+		matchLabels[k] = v
+	}
+
+	endpoint := map[string]interface{}{"port": exporterName}
+	// the exporter serves the default path
+	if r.Spec.Metrics != nil {
+		endpoint["path"] = r.Spec.Metrics.MetricsPath()
+	}
+
+	serviceMonitor := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": matchLabels,
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": headlessServiceTypeLabelKey, "operator": string(metav1.LabelSelectorOpDoesNotExist)},
+					map[string]interface{}{"key": roleLabelKey, "operator": string(metav1.LabelSelectorOpDoesNotExist)},
+				},
+			},
+			"endpoints": []interface{}{endpoint},
+		},
+	}}
+	serviceMonitor.SetGroupVersionKind(serviceMonitorGVK)
+	serviceMonitor.SetName(generateName(r))
+	serviceMonitor.SetNamespace(r.GetNamespace())
+	serviceMonitor.SetLabels(r.GetLabels())
+
+	return serviceMonitor
+}
+
+// deleteServiceMonitor deletes the ServiceMonitor once the exporter is removed
+func (reconciler *ReconcileRedis) deleteServiceMonitor(ctx context.Context, r *k8sv1alpha1.Redis) error {
+	serviceMonitor := newServiceMonitor()
+	if err := reconciler.client.Get(ctx, types.NamespacedName{
+		Namespace: r.GetNamespace(),
+		Name:      generateName(r),
+	}, serviceMonitor); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to fetch ServiceMonitor: %s", err)
+	}
+	// the ServiceMonitor of the same name created by the user is left intact
+	if !metav1.IsControlledBy(serviceMonitor, r) {
+		return nil
+	}
+
+	if err := reconciler.client.Delete(ctx, serviceMonitor); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ServiceMonitor: %s", err)
+	}
+	return nil
+}
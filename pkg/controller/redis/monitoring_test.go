@@ -0,0 +1,68 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_generateServiceMonitor(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{
+		Name:      "example",
+		Namespace: "default",
+		Labels:    map[string]string{redisName: "example"},
+	}}
+	r.Spec.Exporter.Image = "oliver006/redis_exporter"
+
+	serviceMonitor := generateServiceMonitor(r)
+	if got := serviceMonitor.GroupVersionKind(); got != serviceMonitorGVK {
+		t.Fatalf("generateServiceMonitor() kind = %v, want %v", got, serviceMonitorGVK)
+	}
+
+	labelSelector := new(metav1.LabelSelector)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(
+		serviceMonitor.Object["spec"].(map[string]interface{})["selector"].(map[string]interface{}),
+		labelSelector,
+	); err != nil {
+		t.Fatalf("invalid ServiceMonitor selector: %v", err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		t.Fatalf("invalid ServiceMonitor selector: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name        string
+		serviceType int
+		want        bool
+	}{
+		{"all", serviceTypeAll, true},
+		{"headless", serviceTypeHeadless, false},
+		{"master", serviceTypeMaster, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			service := generateService(r, tt.serviceType)
+			if got := selector.Matches(labels.Set(service.Labels)); got != tt.want {
+				t.Errorf("ServiceMonitor selects %s Service = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
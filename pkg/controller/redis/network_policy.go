@@ -0,0 +1,121 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// namespaceNameLabelKey is set on every Namespace by Kubernetes 1.21 and later
+const namespaceNameLabelKey = "kubernetes.io/metadata.name"
+
+// networkPolicyPorts returns the TCP ports of the NetworkPolicy rule
+func networkPolicyPorts(ports ...int) []networkingv1.NetworkPolicyPort {
+	policyPorts := make([]networkingv1.NetworkPolicyPort, len(ports))
+	for i, port := range ports {
+		protocol, port := corev1.ProtocolTCP, intstr.FromInt(port)
+		policyPorts[i] = networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port}
+	}
+	return policyPorts
+}
+
+// generateNetworkPolicy returns the NetworkPolicy of the Redis Pods. The operator reaches all the ports,
+// the instances and the node-local caches replicate from each other and the backup Pods take the snapshots
+// over the Redis port, the clients reach the Redis port only and the monitoring reaches the exporter.
+// The instances of the blue/green peer, if any, replicate from the instances as well.
+func generateNetworkPolicy(r *k8sv1alpha1.Redis, operator networkingv1.NetworkPolicyPeer, blueGreenPeer string) *networkingv1.NetworkPolicy {
+	peers := []networkingv1.NetworkPolicyPeer{
+		{PodSelector: &metav1.LabelSelector{MatchLabels: r.GetLabels()}},
+		{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{scheduledBackupLabelKey: r.GetName()}}},
+		// the RedisBackup Pods are labeled with the name of the backup rather than the Redis
+		{PodSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      backupLabelKey,
+			Operator: metav1.LabelSelectorOpExists,
+		}}}},
+	}
+	// the node-local caches replicate from the master
+	if r.Spec.NodeLocalCache != nil {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: nodeLocalCacheLabels(r)}})
+	}
+	if blueGreenPeer != "" {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: blueGreenPeerLabels(blueGreenPeer)}})
+	}
+	ingress := []networkingv1.NetworkPolicyIngressRule{
+		{From: []networkingv1.NetworkPolicyPeer{operator}},
+		{From: peers, Ports: networkPolicyPorts(redisPort(r))},
+		// no peers allow all the sources
+		{From: r.Spec.NetworkPolicy.Clients, Ports: networkPolicyPorts(redisPort(r))},
+	}
+	if exporterEnabled(r) && len(r.Spec.NetworkPolicy.Monitoring) > 0 {
+		ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{
+			From:  r.Spec.NetworkPolicy.Monitoring,
+			Ports: networkPolicyPorts(metricsPort(r)),
+		})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generateName(r),
+			Namespace: r.GetNamespace(),
+			Labels:    r.GetLabels(),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: r.GetLabels()},
+			Ingress:     ingress,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+func networkPolicyUpdateNeeded(got, want *networkingv1.NetworkPolicy) (needed bool) {
+	if !isSubset(got.GetLabels(), want.GetLabels()) {
+		got.SetLabels(mergeMaps(got.GetLabels(), want.GetLabels()))
+		needed = true
+	}
+	if !deepContains(got.Spec, want.Spec) {
+		got.Spec = want.Spec
+		needed = true
+	}
+	return
+}
+
+// deleteNetworkPolicy deletes the NetworkPolicy once it is removed from the spec
+func (reconciler *ReconcileRedis) deleteNetworkPolicy(ctx context.Context, r *k8sv1alpha1.Redis) error {
+	networkPolicy := new(networkingv1.NetworkPolicy)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{
+		Namespace: r.GetNamespace(),
+		Name:      generateName(r),
+	}, networkPolicy); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to fetch NetworkPolicy: %s", err)
+	}
+
+	if err := reconciler.client.Delete(ctx, networkPolicy); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete NetworkPolicy: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+)
+
+// selectorMatches reports whether the nil or the label selector matches the labels
+func selectorMatches(t *testing.T, selector *metav1.LabelSelector, set map[string]string) bool {
+	if selector == nil {
+		return true
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		t.Fatalf("invalid selector: %v", err)
+	}
+	return s.Matches(labels.Set(set))
+}
+
+// networkPolicyAllows reports whether the policy allows the Pod from the namespace to reach the port
+func networkPolicyAllows(t *testing.T, policy *networkingv1.NetworkPolicy, namespace, pod map[string]string, port int) bool {
+	for _, rule := range policy.Spec.Ingress {
+		portAllowed := len(rule.Ports) == 0
+		for _, p := range rule.Ports {
+			portAllowed = portAllowed || p.Port.IntValue() == port
+		}
+		peerAllowed := len(rule.From) == 0
+		for _, peer := range rule.From {
+			sameNamespace := peer.NamespaceSelector == nil && namespace[namespaceNameLabelKey] == policy.Namespace
+			peerAllowed = peerAllowed || (sameNamespace || peer.NamespaceSelector != nil &&
+				selectorMatches(t, peer.NamespaceSelector, namespace)) && selectorMatches(t, peer.PodSelector, pod)
+		}
+		if portAllowed && peerAllowed {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_generateNetworkPolicy(t *testing.T) {
+	operator := Options{OperatorNamespace: "redis-operator", OperatorPodLabels: "app=redis-operator"}.operatorPeer()
+
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{
+		Name:      "example",
+		Namespace: "default",
+		Labels:    map[string]string{redisName: "example"},
+	}}
+	r.Spec.Exporter.Image = "oliver006/redis_exporter"
+	r.Spec.NetworkPolicy = &k8sv1alpha1.NetworkPolicy{
+		Clients: []networkingv1.NetworkPolicyPeer{
+			{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+		},
+		Monitoring: []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabelKey: "monitoring"}},
+			PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}},
+		}},
+	}
+	policy := generateNetworkPolicy(r, operator, "")
+
+	if !selectorMatches(t, &policy.Spec.PodSelector, r.GetLabels()) {
+		t.Fatalf("NetworkPolicy does not select the Redis Pods")
+	}
+
+	defaultNamespace := map[string]string{namespaceNameLabelKey: "default"}
+	for _, tt := range []struct {
+		name      string
+		namespace map[string]string
+		pod       map[string]string
+		port      int
+		want      bool
+	}{
+		{"operator to redis", map[string]string{namespaceNameLabelKey: "redis-operator"}, map[string]string{"app": "redis-operator"}, redis.DefaultPort, true},
+		{"operator to exporter", map[string]string{namespaceNameLabelKey: "redis-operator"}, map[string]string{"app": "redis-operator"}, exporterPort, true},
+		{"operator of other namespace", defaultNamespace, map[string]string{"app": "redis-operator"}, redis.DefaultPort, false},
+		{"replica to redis", defaultNamespace, r.GetLabels(), redis.DefaultPort, true},
+		{"scheduled backup to redis", defaultNamespace, map[string]string{scheduledBackupLabelKey: "example"}, redis.DefaultPort, true},
+		{"backup to redis", defaultNamespace, map[string]string{backupLabelKey: "example-backup"}, redis.DefaultPort, true},
+		{"client to redis", defaultNamespace, map[string]string{"app": "web"}, redis.DefaultPort, true},
+		{"client to exporter", defaultNamespace, map[string]string{"app": "web"}, exporterPort, false},
+		{"other Pod to redis", defaultNamespace, map[string]string{"app": "other"}, redis.DefaultPort, false},
+		{"monitoring to exporter", map[string]string{namespaceNameLabelKey: "monitoring"}, map[string]string{"app": "prometheus"}, exporterPort, true},
+		{"monitoring to redis", map[string]string{namespaceNameLabelKey: "monitoring"}, map[string]string{"app": "prometheus"}, redis.DefaultPort, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := networkPolicyAllows(t, policy, tt.namespace, tt.pod, tt.port); got != tt.want {
+				t.Errorf("NetworkPolicy allows %s = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	// any client is allowed to reach the Redis port if no clients are set
+	r.Spec.NetworkPolicy.Clients = nil
+	if !networkPolicyAllows(t, generateNetworkPolicy(r, operator, ""), defaultNamespace, map[string]string{"app": "other"}, redis.DefaultPort) {
+		t.Errorf("NetworkPolicy without clients denies the Redis port")
+	}
+
+	// the instances of the blue/green peer replicate from the instances
+	r.Spec.NetworkPolicy.Clients = []networkingv1.NetworkPolicyPeer{
+		{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+	}
+	green := map[string]string{redisName: "example-green"}
+	if networkPolicyAllows(t, generateNetworkPolicy(r, operator, ""), defaultNamespace, green, redis.DefaultPort) {
+		t.Errorf("NetworkPolicy without blue/green peer allows the peer instances")
+	}
+	if !networkPolicyAllows(t, generateNetworkPolicy(r, operator, "example-green"), defaultNamespace, green, redis.DefaultPort) {
+		t.Errorf("NetworkPolicy denies the blue/green peer instances")
+	}
+}
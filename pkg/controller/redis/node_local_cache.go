@@ -0,0 +1,251 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// nodeLocalCacheLabelKey labels the cache Pods with the name of the Redis. The cache Pods are not labeled
+// with the labels of the Redis, so they are selected neither as instances nor by the Services of the Redis.
+const nodeLocalCacheLabelKey = "redis-node-local-cache"
+
+// internalTrafficPolicyLocal routes the traffic of the clients to the endpoints on their own node
+const internalTrafficPolicyLocal = "Local"
+
+var (
+	// serviceGVK is the Service kind. The node-local Service is managed as an unstructured object:
+	// the vendored core/v1 API predates the internal traffic policy introduced in Kubernetes 1.21.
+	serviceGVK = corev1.SchemeGroupVersion.WithKind("Service")
+	// internalTrafficPolicyField is the path of the internal traffic policy of the Service
+	internalTrafficPolicyField = []string{"spec", "internalTrafficPolicy"}
+)
+
+// generateNodeLocalCacheName returns the name of the cache DaemonSet and of the node-local Service
+func generateNodeLocalCacheName(r *k8sv1alpha1.Redis) string {
+	return fmt.Sprintf("%s-cache", generateName(r))
+}
+
+// nodeLocalCacheLabels returns the labels of the cache Pods
+func nodeLocalCacheLabels(r *k8sv1alpha1.Redis) map[string]string {
+	return map[string]string{nodeLocalCacheLabelKey: r.GetName()}
+}
+
+// generateNodeLocalCache returns the DaemonSet of the cache replicas. The Pods run the redis container
+// of the instances with the same configuration, the authentication and the TLS certificates, the master is
+// set by the operator. The data is not persisted, neither the snapshots nor the AOF are written.
+func generateNodeLocalCache(r *k8sv1alpha1.Redis, options objectGeneratorOptions) *appsv1.DaemonSet {
+	// the caches start empty and replicate the data from the master
+	options.restore = nil
+	template := generateStatefulSet(r, options).Spec.Template.DeepCopy()
+
+	redisContainer := template.Spec.Containers[0]
+	// the caches do not include the announce configurations of the instances
+	redisContainer.Args = []string{configMapMountPath, "--save", "", "--appendonly", "no"}
+	if r.Spec.NodeLocalCache.Resources.Limits != nil || r.Spec.NodeLocalCache.Resources.Requests != nil {
+		redisContainer.Resources = r.Spec.NodeLocalCache.Resources
+	}
+	template.Spec.Containers = []corev1.Container{redisContainer}
+
+	// the data volume claims are made by the StatefulSet only
+	for _, mount := range redisContainer.VolumeMounts {
+		if mount.MountPath == dataMountPath && mount.Name != fmt.Sprintf("%s-data", generateName(r)) {
+			template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+				Name:         mount.Name,
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			})
+		}
+	}
+
+	template.Labels = nodeLocalCacheLabels(r)
+	delete(template.Annotations, cpuPinningAnnotationKey)
+	template.Spec.Affinity = nil
+	template.Spec.NodeSelector = r.Spec.NodeLocalCache.NodeSelector
+	template.Spec.Tolerations = r.Spec.NodeLocalCache.Tolerations
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generateNodeLocalCacheName(r),
+			Namespace: r.GetNamespace(),
+			Labels:    r.GetLabels(),
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: nodeLocalCacheLabels(r)},
+			Template: *template,
+		},
+	}
+}
+
+// generateNodeLocalCacheService returns the Service routing the clients to the cache on their own node
+// with the Local internal traffic policy. The clients on the nodes without a cache are not served.
+func generateNodeLocalCacheService(r *k8sv1alpha1.Redis) *unstructured.Unstructured {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generateNodeLocalCacheName(r),
+			Namespace: r.GetNamespace(),
+			Labels:    r.GetLabels(),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:       redisName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       int32(redisPort(r)),
+				TargetPort: intstr.FromInt(redisPort(r)),
+			}},
+			Selector: nodeLocalCacheLabels(r),
+			Type:     corev1.ServiceTypeClusterIP,
+		},
+	}
+	object, err := k8sruntime.DefaultUnstructuredConverter.ToUnstructured(service)
+	if err != nil {
+		// the typed Service is always converted
+		return nil
+	}
+	u := &unstructured.Unstructured{Object: object}
+	u.SetGroupVersionKind(serviceGVK)
+	// the status of the typed Service is not managed
+	unstructured.RemoveNestedField(u.Object, "status")
+	_ = unstructured.SetNestedField(u.Object, internalTrafficPolicyLocal, internalTrafficPolicyField...)
+	return u
+}
+
+func daemonSetUpdateNeeded(got, want *appsv1.DaemonSet) (needed bool) {
+	if !isSubset(got.GetLabels(), want.GetLabels()) {
+		got.SetLabels(mergeMaps(got.GetLabels(), want.GetLabels()))
+		needed = true
+	}
+	// compare container resources explicitly. They escape the deepContains comparison because of private fields.
+	if !deepContains(got.Spec.Template, want.Spec.Template) ||
+		!resourceRequirementsEqual(got.Spec.Template.Spec.Containers, want.Spec.Template.Spec.Containers) {
+		got.Spec.Template = want.Spec.Template
+		needed = true
+	}
+	return
+}
+
+// reconcileNodeLocalCache creates or updates the cache DaemonSet and the node-local Service,
+// or deletes them once the cache is disabled
+func (reconciler *ReconcileRedis) reconcileNodeLocalCache(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	options objectGeneratorOptions,
+) (reconcile.Result, error) {
+	service := new(unstructured.Unstructured)
+	service.SetGroupVersionKind(serviceGVK)
+
+	if r.Spec.NodeLocalCache == nil {
+		if err := reconciler.deleteControlled(ctx, r, new(appsv1.DaemonSet), generateNodeLocalCacheName(r)); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, reconciler.deleteControlled(ctx, r, service, generateNodeLocalCacheName(r))
+	}
+
+	for _, object := range []k8sruntime.Object{new(appsv1.DaemonSet), service} {
+		if result, err := reconciler.createOrUpdate(ctx, object, r, options); err != nil || result.Requeue {
+			return result, err
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// nodeLocalCachePods returns the cache Pods with the addresses assigned, none unless the cache is enabled
+func (reconciler *ReconcileRedis) nodeLocalCachePods(ctx context.Context, r *k8sv1alpha1.Redis) ([]corev1.Pod, error) {
+	if r.Spec.NodeLocalCache == nil {
+		return nil, nil
+	}
+	podList := new(corev1.PodList)
+	if err := reconciler.client.List(ctx, podList, client.InNamespace(r.GetNamespace()),
+		client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(nodeLocalCacheLabels(r))}); err != nil {
+		return nil, fmt.Errorf("failed to list cache Pods: %s", err)
+	}
+	var pods []corev1.Pod
+	for i := range podList.Items {
+		if podList.Items[i].Status.PodIP != "" {
+			pods = append(pods, podList.Items[i])
+		}
+	}
+	return pods, nil
+}
+
+// cacheAddress returns the address of the cache Pod
+func cacheAddress(r *k8sv1alpha1.Redis, pod *corev1.Pod) redis.Address {
+	return redis.Address{Host: pod.Status.PodIP, Port: strconv.Itoa(redisPort(r))}
+}
+
+// replicateNodeLocalCaches makes the ready caches replicas of the master and applies the ACL users to them,
+// the removed users are deleted.
+// The default user is enabled or disabled as on the instances unless defaultUserEnabled is nil.
+func (reconciler *ReconcileRedis) replicateNodeLocalCaches(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	options redis.Options,
+	master redis.Address,
+	pods []corev1.Pod,
+	users []redis.User,
+	removedUsers []string,
+	defaultUserEnabled *bool,
+) error {
+	var addresses []redis.Address
+	names := make(map[string]string)
+	for i := range pods {
+		if podReady(&pods[i]) {
+			addresses = append(addresses, cacheAddress(r, &pods[i]))
+			names[pods[i].Status.PodIP] = pods[i].Name
+		}
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	caches, err := redis.NewCaches(ctx, options, addresses...)
+	if err != nil {
+		return err
+	}
+	defer caches.Disconnect()
+
+	if err := caches.ApplyUsers(users...); err != nil {
+		return fmt.Errorf("error applying ACL users to the caches: %s", err)
+	}
+	if err := caches.DeleteUsers(removedUsers...); err != nil {
+		return fmt.Errorf("error deleting ACL users from the caches: %s", err)
+	}
+	if defaultUserEnabled != nil {
+		if err := caches.SetDefaultUser(*defaultUserEnabled); err != nil {
+			return fmt.Errorf("error configuring the default user of the caches: %s", err)
+		}
+	}
+	reconfigured, err := caches.ReplicateFrom(ctx, master)
+	if len(reconfigured) > 0 {
+		reconciler.recorder.Eventf(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonReplicasReconfigured,
+			"Reconfigured the node-local caches %s as replicas of the master", podNamesOf(reconfigured, names))
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func Test_generateNodeLocalCache(t *testing.T) {
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: map[string]string{redisName: "test"}},
+		Spec: k8sv1alpha1.RedisSpec{
+			Redis:    k8sv1alpha1.ContainerSpec{Image: "redis"},
+			Exporter: k8sv1alpha1.ContainerSpec{Image: k8sv1alpha1.DefaultExporterImage},
+			DataVolumeClaimTemplate: corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "data"},
+			},
+			NodeLocalCache: &k8sv1alpha1.NodeLocalCache{NodeSelector: map[string]string{"pool": "clients"}},
+		},
+	}
+	daemonSet := generateNodeLocalCache(r, objectGeneratorOptions{})
+	template := daemonSet.Spec.Template
+
+	if labels.SelectorFromSet(r.GetLabels()).Matches(labels.Set(template.Labels)) {
+		t.Errorf("generateNodeLocalCache() Pod labels %v are selected as instances", template.Labels)
+	}
+	if !reflect.DeepEqual(daemonSet.Spec.Selector.MatchLabels, template.Labels) {
+		t.Errorf("generateNodeLocalCache() selector = %v, want %v", daemonSet.Spec.Selector.MatchLabels, template.Labels)
+	}
+	if len(template.Spec.Containers) != 1 || template.Spec.Containers[0].Name != redisName {
+		t.Fatalf("generateNodeLocalCache() containers = %+v, want redis only", template.Spec.Containers)
+	}
+	if args := template.Spec.Containers[0].Args; !reflect.DeepEqual(args,
+		[]string{configMapMountPath, "--save", "", "--appendonly", "no"}) {
+		t.Errorf("generateNodeLocalCache() args = %q, want the persistence disabled", args)
+	}
+	if !reflect.DeepEqual(template.Spec.NodeSelector, map[string]string{"pool": "clients"}) || template.Spec.Affinity != nil {
+		t.Errorf("generateNodeLocalCache() node selector = %v, affinity = %v", template.Spec.NodeSelector, template.Spec.Affinity)
+	}
+	var dataVolume *corev1.Volume
+	for i := range template.Spec.Volumes {
+		if template.Spec.Volumes[i].Name == "data" {
+			dataVolume = &template.Spec.Volumes[i]
+		}
+	}
+	if dataVolume == nil || dataVolume.EmptyDir == nil {
+		t.Errorf("generateNodeLocalCache() data volume = %+v, want an emptyDir", dataVolume)
+	}
+}
+
+func Test_generateNodeLocalCacheService(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: map[string]string{redisName: "test"}}}
+	service := generateNodeLocalCacheService(r)
+	if service.GetName() != "redis-test-cache" || service.GroupVersionKind() != serviceGVK {
+		t.Errorf("generateNodeLocalCacheService() = %s %s", service.GroupVersionKind(), service.GetName())
+	}
+	if policy, _, _ := unstructured.NestedString(service.Object, internalTrafficPolicyField...); policy != internalTrafficPolicyLocal {
+		t.Errorf("generateNodeLocalCacheService() internal traffic policy = %q, want %q", policy, internalTrafficPolicyLocal)
+	}
+	selector, _, _ := unstructured.NestedStringMap(service.Object, "spec", "selector")
+	if !reflect.DeepEqual(selector, nodeLocalCacheLabels(r)) {
+		t.Errorf("generateNodeLocalCacheService() selector = %v, want %v", selector, nodeLocalCacheLabels(r))
+	}
+}
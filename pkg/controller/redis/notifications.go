@@ -0,0 +1,247 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/notify"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// the keys of the notification Secret, the sinks are enabled by the keys present
+const (
+	datadogAPIKeyKey       = "datadog-api-key"
+	datadogSiteKey         = "datadog-site"
+	awsRegionKey           = "aws-region"
+	awsAccessKeyIDKey      = "aws-access-key-id"
+	awsSecretAccessKeyKey  = "aws-secret-access-key"
+	awsSessionTokenKey     = "aws-session-token"
+	eventBridgeEventBusKey = "eventbridge-event-bus"
+)
+
+const (
+	// notificationTimeout bounds the time an Event is sent to all the sinks for
+	notificationTimeout = 10 * time.Second
+	// notificationSecretTTL is the time the notification Secret is cached for
+	notificationSecretTTL = time.Minute
+	// notificationDedupWindow is the time the repeated Events of a Redis are not sent again within
+	notificationDedupWindow = 10 * time.Minute
+	// notificationQueueSize bounds the Events waiting to be sent, the Events over it are dropped
+	notificationQueueSize = 100
+	// notificationSecretSink labels the failures to read the notification Secret
+	notificationSecretSink = "secret"
+	// notificationQueueSink labels the Events dropped by the full queue
+	notificationQueueSink = "queue"
+)
+
+var (
+	// notifiedReasons are the Normal Events sent along with all the Warning ones: the master changes and the recoveries
+	notifiedReasons = map[string]bool{
+		k8sv1alpha1.ReasonMasterPromoted:    true,
+		k8sv1alpha1.ReasonMasterHandedOver:  true,
+		k8sv1alpha1.ReasonCutOver:           true,
+		k8sv1alpha1.ReasonAllInstancesReady: true,
+	}
+
+	notificationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_operator_notification_failures_total",
+		Help: "Number of the Events failed to be sent to the external event services by sink",
+	}, []string{"sink"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(notificationFailures)
+}
+
+// notified reports whether the Event is sent to the external event services
+func notified(eventType, reason string) bool {
+	return eventType == corev1.EventTypeWarning || notifiedReasons[reason]
+}
+
+// notificationSinks returns the sinks enabled by the keys of the notification Secret
+func notificationSinks(data map[string][]byte) []notify.Sink {
+	var sinks []notify.Sink
+	if apiKey := string(data[datadogAPIKeyKey]); apiKey != "" {
+		sinks = append(sinks, &notify.Datadog{APIKey: apiKey, Site: string(data[datadogSiteKey])})
+	}
+	if region := string(data[awsRegionKey]); region != "" {
+		sinks = append(sinks, &notify.EventBridge{
+			Region:          region,
+			EventBus:        string(data[eventBridgeEventBusKey]),
+			AccessKeyID:     string(data[awsAccessKeyIDKey]),
+			SecretAccessKey: string(data[awsSecretAccessKeyKey]),
+			SessionToken:    string(data[awsSessionTokenKey]),
+		})
+	}
+	return sinks
+}
+
+// eventNotifier sends the failover and health Events of the Redis resources to the sinks configured
+// by the notification Secret. The Events are queued and sent one by one by a single worker without blocking
+// the reconciliation, the same Event of a Redis is sent once within notificationDedupWindow.
+// The Secret is cached for notificationSecretTTL, so the credentials are rotated without restarting the operator.
+type eventNotifier struct {
+	// secret reads the data of the notification Secret
+	secret func(ctx context.Context) (map[string][]byte, error)
+	// queue holds the Events waiting to be sent
+	queue chan notify.Event
+	// now returns the current time
+	now func() time.Time
+
+	mu sync.Mutex
+	// sent holds the time the Events were last queued at by notificationKey
+	sent map[notificationKey]time.Time
+	// data is the cached data of the notification Secret read at the time of fetched
+	data    map[string][]byte
+	fetched time.Time
+}
+
+// notificationKey identifies the repeated Events
+type notificationKey struct {
+	namespace, name, reason, message string
+}
+
+// newEventNotifier returns the notifier reading the Secret, namespace/name or the name of a Secret in namespace,
+// and starts its worker. The Secret is read bypassing the cache: the operator namespace might be not watched.
+func newEventNotifier(kubeClient kubernetes.Interface, secret, namespace string) (*eventNotifier, error) {
+	secretNamespace, name, err := cache.SplitMetaNamespaceKey(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification Secret %q: %s", secret, err)
+	}
+	if secretNamespace == "" {
+		secretNamespace = namespace
+	}
+	if secretNamespace == "" {
+		return nil, fmt.Errorf("no namespace of the notification Secret %q", secret)
+	}
+	n := &eventNotifier{
+		secret: func(ctx context.Context) (map[string][]byte, error) {
+			found, err := kubeClient.CoreV1().Secrets(secretNamespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return found.Data, nil
+		},
+		queue: make(chan notify.Event, notificationQueueSize),
+		now:   time.Now,
+		sent:  make(map[notificationKey]time.Time),
+	}
+	go n.run()
+	return n, nil
+}
+
+// notify queues the Event emitted on the Redis if it is to be notified of and was not queued within
+// notificationDedupWindow. The Event is dropped if the queue is full. The nil notifier sends nothing.
+func (n *eventNotifier) notify(object runtime.Object, eventType, reason, message string) {
+	if n == nil || !notified(eventType, reason) {
+		return
+	}
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return
+	}
+	now := n.now()
+	key := notificationKey{namespace: accessor.GetNamespace(), name: accessor.GetName(), reason: reason, message: message}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for k, sent := range n.sent {
+		if now.Sub(sent) >= notificationDedupWindow {
+			delete(n.sent, k)
+		}
+	}
+	if _, ok := n.sent[key]; ok {
+		return
+	}
+	select {
+	case n.queue <- notify.Event{
+		Time:      now,
+		Namespace: key.namespace,
+		Name:      key.name,
+		Type:      eventType,
+		Reason:    reason,
+		Message:   message,
+	}:
+		n.sent[key] = now
+	default:
+		log.Info("Dropped the Event, the notification queue is full", "Reason", reason,
+			"Request.Namespace", key.namespace, "Request.Name", key.name)
+		notificationFailures.WithLabelValues(notificationQueueSink).Inc()
+	}
+}
+
+// run sends the queued Events
+func (n *eventNotifier) run() {
+	for event := range n.queue {
+		n.send(event)
+	}
+}
+
+// secretData returns the data of the notification Secret cached for notificationSecretTTL
+func (n *eventNotifier) secretData(ctx context.Context) (map[string][]byte, error) {
+	n.mu.Lock()
+	if n.data != nil && n.now().Sub(n.fetched) < notificationSecretTTL {
+		defer n.mu.Unlock()
+		return n.data, nil
+	}
+	n.mu.Unlock()
+
+	data, err := n.secret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = map[string][]byte{}
+	}
+	n.mu.Lock()
+	n.data, n.fetched = data, n.now()
+	n.mu.Unlock()
+	return data, nil
+}
+
+// send delivers the Event to every sink of the notification Secret, the failures are logged and counted
+func (n *eventNotifier) send(event notify.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+
+	data, err := n.secretData(ctx)
+	if err != nil {
+		log.Error(err, "Failed to read the notification Secret", "Reason", event.Reason)
+		notificationFailures.WithLabelValues(notificationSecretSink).Inc()
+		return
+	}
+	for _, sink := range notificationSinks(data) {
+		if err := sink.Send(ctx, event); err != nil {
+			log.Error(err, "Failed to send the Event", "Sink", sink.Name(), "Reason", event.Reason,
+				"Request.Namespace", event.Namespace, "Request.Name", event.Name)
+			notificationFailures.WithLabelValues(sink.Name()).Inc()
+		}
+	}
+}
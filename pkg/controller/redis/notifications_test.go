@@ -0,0 +1,184 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/notify"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_notified(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		reason    string
+		want      bool
+	}{
+		{"warning", corev1.EventTypeWarning, k8sv1alpha1.ReasonQuorumNotMet, true},
+		{"failover", corev1.EventTypeNormal, k8sv1alpha1.ReasonMasterPromoted, true},
+		{"recovery", corev1.EventTypeNormal, k8sv1alpha1.ReasonAllInstancesReady, true},
+		{"routine", corev1.EventTypeNormal, k8sv1alpha1.ReasonConfigApplied, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := notified(tt.eventType, tt.reason); got != tt.want {
+				t.Errorf("notified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_notificationSinks(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string][]byte
+		want []notify.Sink
+	}{
+		{"empty", nil, nil},
+		{"datadog", map[string][]byte{datadogAPIKeyKey: []byte("key"), datadogSiteKey: []byte("datadoghq.eu")},
+			[]notify.Sink{&notify.Datadog{APIKey: "key", Site: "datadoghq.eu"}}},
+		{"eventbridge", map[string][]byte{
+			awsRegionKey:          []byte("eu-west-1"),
+			awsAccessKeyIDKey:     []byte("AKID"),
+			awsSecretAccessKeyKey: []byte("secret"),
+		}, []notify.Sink{&notify.EventBridge{Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret"}}},
+		{"both", map[string][]byte{
+			datadogAPIKeyKey:       []byte("key"),
+			awsRegionKey:           []byte("eu-west-1"),
+			eventBridgeEventBusKey: []byte("alerts"),
+		}, []notify.Sink{&notify.Datadog{APIKey: "key"}, &notify.EventBridge{Region: "eu-west-1", EventBus: "alerts"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := notificationSinks(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("notificationSinks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newEventNotifier(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		namespace string
+		wantErr   bool
+	}{
+		{"name", "notifications", "redis-operator", false},
+		{"namespace and name", "monitoring/notifications", "", false},
+		{"no namespace", "notifications", "", true},
+		{"invalid", "a/b/c", "redis-operator", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newEventNotifier(nil, tt.secret, tt.namespace); (err != nil) != tt.wantErr {
+				t.Errorf("newEventNotifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_eventNotifier_notify(t *testing.T) {
+	redis := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "redis"}}
+	other := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"}}
+	type event struct {
+		object  *k8sv1alpha1.Redis
+		after   time.Duration
+		reason  string
+		message string
+	}
+	tests := []struct {
+		name      string
+		queueSize int
+		events    []event
+		want      []string
+	}{
+		{"repeated", 10, []event{
+			{redis, 0, k8sv1alpha1.ReasonQuorumNotMet, "1 of 3"},
+			{redis, time.Minute, k8sv1alpha1.ReasonQuorumNotMet, "1 of 3"},
+		}, []string{"redis/QuorumNotMet/1 of 3"}},
+		{"other message and object", 10, []event{
+			{redis, 0, k8sv1alpha1.ReasonQuorumNotMet, "1 of 3"},
+			{redis, 0, k8sv1alpha1.ReasonQuorumNotMet, "2 of 3"},
+			{other, 0, k8sv1alpha1.ReasonQuorumNotMet, "1 of 3"},
+		}, []string{"redis/QuorumNotMet/1 of 3", "redis/QuorumNotMet/2 of 3", "other/QuorumNotMet/1 of 3"}},
+		{"after the window", 10, []event{
+			{redis, 0, k8sv1alpha1.ReasonQuorumNotMet, "1 of 3"},
+			{redis, notificationDedupWindow, k8sv1alpha1.ReasonQuorumNotMet, "1 of 3"},
+		}, []string{"redis/QuorumNotMet/1 of 3", "redis/QuorumNotMet/1 of 3"}},
+		{"not notified", 10, []event{
+			{redis, 0, k8sv1alpha1.ReasonConfigApplied, ""},
+		}, nil},
+		{"full queue", 1, []event{
+			{redis, 0, k8sv1alpha1.ReasonQuorumNotMet, "1 of 3"},
+			{redis, 0, k8sv1alpha1.ReasonQuorumNotMet, "2 of 3"},
+		}, []string{"redis/QuorumNotMet/1 of 3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			n := &eventNotifier{
+				queue: make(chan notify.Event, tt.queueSize),
+				now:   func() time.Time { return now },
+				sent:  make(map[notificationKey]time.Time),
+			}
+			for _, e := range tt.events {
+				now = now.Add(e.after)
+				eventType := corev1.EventTypeWarning
+				if e.reason == k8sv1alpha1.ReasonConfigApplied {
+					eventType = corev1.EventTypeNormal
+				}
+				n.notify(e.object, eventType, e.reason, e.message)
+			}
+			close(n.queue)
+			var got []string
+			for event := range n.queue {
+				got = append(got, event.Name+"/"+event.Reason+"/"+event.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("notify() queued %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_eventNotifier_secretData(t *testing.T) {
+	now := time.Now()
+	reads := 0
+	n := &eventNotifier{
+		secret: func(context.Context) (map[string][]byte, error) {
+			reads++
+			return map[string][]byte{datadogAPIKeyKey: []byte("key")}, nil
+		},
+		now: func() time.Time { return now },
+	}
+	for _, after := range []time.Duration{0, time.Second, notificationSecretTTL - 2*time.Second, time.Second} {
+		now = now.Add(after)
+		if _, err := n.secretData(context.Background()); err != nil {
+			t.Fatalf("secretData() error = %v", err)
+		}
+	}
+	if reads != 2 {
+		t.Errorf("secretData() read the Secret %d times, want 2", reads)
+	}
+}
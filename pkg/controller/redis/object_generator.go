@@ -20,15 +20,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 
-	"golang.org/x/crypto/argon2"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
@@ -37,7 +42,6 @@ import (
 
 const (
 	redisName = "redis"
-	redisPort = redis.Port
 
 	exporterName = "exporter"
 	exporterPort = 9121
@@ -52,37 +56,64 @@ const (
 
 	// templates
 	namePrefixTemplate = `redis-%s`
-	authConfTemplate   = "requirepass %[1]s\nmasterauth %[1]s\n"
+	// the passwords are quoted with k8sv1alpha1.QuoteConfigArg
+	authConfTemplate = "requirepass %[1]s\nmasterauth %[1]s\n"
+	// replace authConfTemplate once the default user is disabled or defined in the ACL file
+	masterUserConfTemplate = "masteruser %s\n"
+	masterAuthConfTemplate = "masterauth %s\n"
 
 	// paths and file paths
 	configFileName     = "redis.conf"
 	configMapMountPath = "/config/" + configFileName
+	secretMountDir     = "/secret"
 	secretFileName     = "auth.conf"
-	secretMountPath    = "/secret/" + secretFileName
+	secretMountPath    = secretMountDir + "/" + secretFileName
+	aclFileName        = "users.acl"
+	aclFileMountPath   = secretMountDir + "/" + aclFileName
+	passwordFileName   = "password"
+	passwordFilePath   = secretMountDir + "/" + passwordFileName
 	dataMountPath      = "/data"
 	workingDir         = dataMountPath
+	tlsMountPath       = "/tls"
+	tlsCertFilePath    = tlsMountPath + "/" + corev1.TLSCertKey
+	tlsKeyFilePath     = tlsMountPath + "/" + corev1.TLSPrivateKeyKey
+	tlsCAFilePath      = tlsMountPath + "/" + tlsCAKey
+
+	// the previous password is kept in the Secret to tell when it is removed
+	previousPasswordFileName = "previous-password"
+
+	// key of the CA certificate in the TLS Secret
+	tlsCAKey = "ca.crt"
 
 	// environment variables
 	rediscliAuthEnvName = "REDISCLI_AUTH"
+	// exporterUserEnvName is the environment variable of the user the exporter authenticates as
+	exporterUserEnvName = "REDIS_USER"
+
+	// Annotation key for TLS certificate hash
+	tlsCertificateHashKey = "redis-tls-certificate-hash"
+	// Annotation key for the hash of the configuration the Pods are restarted for
+	configHashKey = "redis-config-hash"
 
-	// argon2id parameters.
-	// Recommended parameters are time = 1, Memory = 65536.
-	// Below parameters are equivalent(time-wise) to time = 4, Memory = 65536.
-	// Time is set to 64 to compensate low Memory = 4096
-	argonTime   = 1 << 6
-	argonMemory = 1 << 12
-	hashLen     = 1 << 6
+	// Annotation key for the number of CPUs pinned to the redis container, a hint for the node tuning
+	cpuPinningAnnotationKey = "redis-pinned-cpus"
 
-	// Annotation key for password hash
-	passwordHashKey = "redis-password-hash"
+	// cert-manager defaults
+	certManagerGroup          = "cert-manager.io"
+	certManagerIssuer         = "Issuer"
+	certificateNameAnnotation = "cert-manager.io/certificate-name"
 
 	headlessServiceTypeLabelKey = "service-type"
 	headlessServiceTypeLabel    = "headless"
+	externalServiceTypeLabel    = "external"
 
 	// types of services created
 	serviceTypeAll = iota
 	serviceTypeHeadless
 	serviceTypeMaster
+	serviceTypeReplica
+	// serviceTypeExternal is the Service of the Pod named by objectGeneratorOptions.pod
+	serviceTypeExternal
 )
 
 var (
@@ -95,38 +126,115 @@ var (
 		"bind":                  {},
 		"protected-mode":        {},
 		"port":                  {},
+		"tls-port":              {},
+		"tls-cert-file":         {},
+		"tls-key-file":          {},
+		"tls-ca-cert-file":      {},
+		"tls-replication":       {},
 		"daemonize":             {},
 		"dir":                   {},
 		"replica-announce-ip":   {},
 		"replica-announce-port": {},
 		"replicaof":             {},
+		"masteruser":            {},
 		"masterauth":            {},
 		"requirepass":           {},
+		"aclfile":               {},
+		"user":                  {},
 		"rename-command":        {},
 	}
-	argonThreads = uint8(runtime.NumCPU())
+
+	// certificateGVK is the cert-manager Certificate kind.
+	// cert-manager types are not vendored, Certificates are managed as unstructured objects.
+	certificateGVK = schema.GroupVersionKind{Group: certManagerGroup, Version: "v1", Kind: "Certificate"}
 )
 
 // objectGeneratorOptions is needed to be passed to a generic object generator
 type objectGeneratorOptions struct {
-	password    string
-	master      redis.Address
-	serviceType int
+	password       string
+	aclUsers       []redis.User
+	tlsCertificate []byte
+	restore        *restoreSource
+	keptRestore    *keptRestore
+	master         redis.Address
+	announced      map[string]redis.Address
+	serviceType    int
+	pod            string
+
+	// previousPassword is accepted along with the password during the rotation
+	previousPassword string
+	// defaultUserDisabled is set once the default user is disabled on the instances
+	defaultUserDisabled bool
+	// removedACLUsers are the names of the ACL users applied previously and no longer declared
+	removedACLUsers []string
+	// operatorPeer matches the operator Pods in the NetworkPolicy
+	operatorPeer networkingv1.NetworkPolicyPeer
+	// connectionInfo selects the connection info ConfigMap rather than the configuration
+	connectionInfo bool
+	// serviceBinding selects the binding Secret rather than the authentication Secret
+	serviceBinding bool
+	// clusterDomain is the DNS domain of the cluster the Service DNS names are generated in
+	clusterDomain string
+	// configRevision restarts the Pods once changed, the Pods are not annotated if empty
+	configRevision string
+	// blueGreenPeer is the name of the other Redis of the blue/green deployment
+	blueGreenPeer string
+	// masterRedirect points the master Service at the master of the green Redis once the Redis is demoted
+	masterRedirect *k8sv1alpha1.Redis
 }
 
 // generateObject is a Kubernetes object factory, returns the name of the object and the object itself
 func generateObject(r *k8sv1alpha1.Redis, object k8sruntime.Object, options objectGeneratorOptions) k8sruntime.Object {
 	switch object.(type) {
 	case *corev1.Secret:
-		return generateSecret(r, options.password)
+		if options.serviceBinding {
+			return generateServiceBinding(r, options.password, options.clusterDomain)
+		}
+		return generateSecret(r, options)
 	case *corev1.ConfigMap:
-		return generateConfigMap(r, options.master)
+		if options.connectionInfo {
+			return generateConnectionInfo(r, options.clusterDomain)
+		}
+		configMap := generateConfigMap(r, options.master)
+		if r.Spec.ExternalAccess != nil {
+			for key, config := range announceConfigs(r, options.announced) {
+				configMap.Data[key] = config
+			}
+		}
+		if options.configRevision != "" {
+			configMap.Annotations[configRevisionAnnotationKey] = options.configRevision
+		}
+		return configMap
 	case *corev1.Service:
-		return generateService(r, options.serviceType)
+		if options.serviceType == serviceTypeExternal {
+			return generateExternalService(r, options.pod)
+		}
+		service := generateService(r, options.serviceType)
+		if options.serviceType == serviceTypeMaster && options.masterRedirect != nil {
+			redirectMasterService(service, options.masterRedirect)
+		}
+		return service
 	case *policyv1beta1.PodDisruptionBudget:
 		return generatePodDisruptionBudget(r)
 	case *appsv1.StatefulSet:
-		return generateStatefulSet(r, options.password)
+		return generateStatefulSet(r, options)
+	case *appsv1.DaemonSet:
+		return generateNodeLocalCache(r, options)
+	case *batchv1beta1.CronJob:
+		return generateBackupCronJob(r)
+	case *networkingv1.NetworkPolicy:
+		return generateNetworkPolicy(r, options.operatorPeer, options.blueGreenPeer)
+	case *unstructured.Unstructured:
+		switch object.GetObjectKind().GroupVersionKind() {
+		case certificateGVK:
+			return generateCertificate(r)
+		case serviceMonitorGVK:
+			return generateServiceMonitor(r)
+		case serviceGVK:
+			return generateNodeLocalCacheService(r)
+		case cronJobGVK:
+			return generateUnstructuredBackupCronJob(r)
+		}
 	}
 	return nil
 }
@@ -145,19 +253,70 @@ func objectUpdateNeeded(got, want k8sruntime.Object) (needed bool) {
 		return podDisruptionBudgetUpdateNeeded(got.(*policyv1beta1.PodDisruptionBudget), want.(*policyv1beta1.PodDisruptionBudget))
 	case *appsv1.StatefulSet:
 		return statefulSetUpdateNeeded(got.(*appsv1.StatefulSet), want.(*appsv1.StatefulSet))
+	case *appsv1.DaemonSet:
+		return daemonSetUpdateNeeded(got.(*appsv1.DaemonSet), want.(*appsv1.DaemonSet))
+	case *batchv1beta1.CronJob:
+		return cronJobUpdateNeeded(got.(*batchv1beta1.CronJob), want.(*batchv1beta1.CronJob))
+	case *networkingv1.NetworkPolicy:
+		return networkPolicyUpdateNeeded(got.(*networkingv1.NetworkPolicy), want.(*networkingv1.NetworkPolicy))
+	case *unstructured.Unstructured:
+		return unstructuredUpdateNeeded(got.(*unstructured.Unstructured), want.(*unstructured.Unstructured))
 	}
 	return
 }
 
 // resource generators
-func generateSecret(r *k8sv1alpha1.Redis, password string) *corev1.Secret {
-	var b strings.Builder
-	defer b.Reset()
-	_, _ = fmt.Fprintf(&b, authConfTemplate, password)
+func generateSecret(r *k8sv1alpha1.Redis, options objectGeneratorOptions) *corev1.Secret {
+	var conf, acl strings.Builder
+	defer conf.Reset()
+	defer acl.Reset()
+
+	// ACL users are persisted to survive restarts, the default user is defined along with them if needed
+	users := options.aclUsers
+	switch {
+	case options.defaultUserDisabled:
+		// the replicas authenticate to the master as the operator user
+		_, _ = fmt.Fprintf(&conf, masterUserConfTemplate, redis.OperatorUser)
+		_, _ = fmt.Fprintf(&conf, masterAuthConfTemplate, k8sv1alpha1.QuoteConfigArg(options.password))
+		users = append([]redis.User{{Name: redis.DefaultUser, Rules: []string{"off"}}}, users...)
+	case aclFileEnabled(r) || len(options.password) > 0 && len(options.previousPassword) > 0:
+		// the default user is defined by the password hashes instead of requirepass accepting a single password
+		if len(options.password) > 0 {
+			_, _ = fmt.Fprintf(&conf, masterAuthConfTemplate, k8sv1alpha1.QuoteConfigArg(options.password))
+		}
+		defaultUser := redis.NewDefaultUser(options.password)
+		if len(options.password) > 0 && len(options.previousPassword) > 0 {
+			defaultUser.Passwords = append(defaultUser.Passwords, options.previousPassword)
+		}
+		users = append([]redis.User{defaultUser}, users...)
+	case len(options.password) > 0:
+		_, _ = fmt.Fprintf(&conf, authConfTemplate, k8sv1alpha1.QuoteConfigArg(options.password))
+	}
+
+	// the users can not be defined in both the configuration and the ACL file
+	w := &conf
+	if aclFileEnabled(r) {
+		_, _ = fmt.Fprintf(&conf, "aclfile %s\n", aclFileMountPath)
+		w = &acl
+	}
+	for _, user := range users {
+		_, _ = fmt.Fprintf(w, "user %s %s\n", user.Name, strings.Join(user.Args(), " "))
+	}
 
+	data := map[string][]byte{secretFileName: []byte(conf.String())}
+	if aclFileEnabled(r) {
+		data[aclFileName] = []byte(acl.String())
+	}
+	// the probes read the password from the mounted file which follows the rotation unlike the environment
+	if len(options.password) > 0 {
+		data[passwordFileName] = []byte(options.password)
+		if len(options.previousPassword) > 0 {
+			data[previousPasswordFileName] = []byte(options.previousPassword)
+		}
+	}
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{Name: generateName(r), Namespace: r.GetNamespace(), Labels: r.GetLabels()},
-		Data:       map[string][]byte{secretFileName: []byte(b.String())},
+		Data:       data,
 	}
 }
 
@@ -167,28 +326,51 @@ func generateConfigMap(r *k8sv1alpha1.Redis, master redis.Address) *corev1.Confi
 	// explicitly set the working directory
 	_, _ = fmt.Fprintf(&b, "# Generated by redis-operator for redis.k8s.amaiz.com/%s\ndir %s\n", r.GetName(), workingDir)
 
-	if r.Spec.Password.SecretKeyRef != nil {
+	if authConfigured(r) {
 		_, _ = fmt.Fprintf(&b, "include %s\n", secretMountPath)
 	}
 
-	for k, v := range r.Spec.Config {
-		if _, ok := excludedConfigDirectives[k]; !ok {
-			_, _ = fmt.Fprintf(&b, "%s %s\n", k, v)
+	// serve TLS connections only and replicate over TLS
+	switch port := redisPort(r); {
+	case r.Spec.TLS != nil:
+		_, _ = fmt.Fprintf(&b, "port 0\ntls-port %d\n", port)
+		_, _ = fmt.Fprintf(&b, "tls-cert-file %s\ntls-key-file %s\ntls-ca-cert-file %s\n", tlsCertFilePath, tlsKeyFilePath, tlsCAFilePath)
+		_, _ = fmt.Fprint(&b, "tls-replication yes\n")
+	case port != redis.DefaultPort:
+		_, _ = fmt.Fprintf(&b, "port %d\n", port)
+	}
+
+	// the directives are rendered in order, so the same configuration renders the same ConfigMap
+	names := make([]string, 0, len(r.Spec.Config))
+	for k := range r.Spec.Config {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		// the directive names are case-insensitive, the invalid directives could inject arbitrary ones
+		if _, ok := excludedConfigDirectives[strings.ToLower(k)]; !ok && k8sv1alpha1.ValidateConfigDirective(k, r.Spec.Config[k]) == nil {
+			_, _ = fmt.Fprintf(&b, "%s %s\n", k, r.Spec.Config[k])
 		}
 	}
 
 	if master != (redis.Address{}) {
-		_, _ = fmt.Fprintf(&b, "replicaof %s %d\n", master.Host, redis.Port)
+		_, _ = fmt.Fprintf(&b, "replicaof %s %s\n", master.Host, master.Port)
 	}
 
 	return &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{Name: generateName(r), Namespace: r.GetNamespace(), Labels: r.GetLabels()},
-		Data:       map[string]string{configFileName: b.String()}}
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        generateName(r),
+			Namespace:   r.GetNamespace(),
+			Labels:      r.GetLabels(),
+			Annotations: map[string]string{configChecksumAnnotationKey: configRevision(parseConfig(b.String()))},
+		},
+		Data: map[string]string{configFileName: b.String()}}
 }
 
 func generateService(r *k8sv1alpha1.Redis, serviceType int) *corev1.Service {
 	var name, clusterIP string
 	var selector map[string]string
+	kind := corev1.ServiceTypeClusterIP
 	labels := make(map[string]string)
 	for k, v := range r.GetLabels() {
 		labels[k] = v
@@ -199,29 +381,41 @@ func generateService(r *k8sv1alpha1.Redis, serviceType int) *corev1.Service {
 		name = generateName(r)
 		selector = r.GetLabels()
 	case serviceTypeHeadless:
-		name = fmt.Sprintf("%s-%s", generateName(r), headlessServiceTypeLabel)
+		name = generateHeadlessServiceName(r)
 		selector = r.GetLabels()
 		labels[headlessServiceTypeLabelKey] = headlessServiceTypeLabel
 		clusterIP = corev1.ClusterIPNone
 	case serviceTypeMaster:
-		name = fmt.Sprintf("%s-%s", generateName(r), masterLabel)
+		name = generateMasterServiceName(r)
 		selector = labels
 		labels[roleLabelKey] = masterLabel
+		if r.Spec.Service != nil && r.Spec.Service.Type != "" {
+			kind = r.Spec.Service.Type
+		}
+	case serviceTypeReplica:
+		name = generateReplicaServiceName(r)
+		selector = labels
+		labels[roleLabelKey] = replicaLabel
+		// the master is selected along with the replicas by the labels of the Redis
+		if r.Spec.ReplicaService != nil && r.Spec.ReplicaService.ExcludeMaster != nil && !*r.Spec.ReplicaService.ExcludeMaster {
+			selector = r.GetLabels()
+		}
 	}
 
 	ports := []corev1.ServicePort{{
 		Name:       redisName,
 		Protocol:   corev1.ProtocolTCP,
-		Port:       redisPort,
-		TargetPort: intstr.FromInt(redisPort),
+		Port:       int32(redisPort(r)),
+		TargetPort: intstr.FromInt(redisPort(r)),
 	}}
 
-	if !reflect.DeepEqual(r.Spec.Exporter, k8sv1alpha1.ContainerSpec{}) {
+	// the port of the metrics sidecar is named as the exporter port, so the ServiceMonitor scrapes either
+	if exporterEnabled(r) {
 		ports = append(ports, corev1.ServicePort{
 			Name:       exporterName,
 			Protocol:   corev1.ProtocolTCP,
-			Port:       exporterPort,
-			TargetPort: intstr.FromInt(exporterPort),
+			Port:       int32(metricsPort(r)),
+			TargetPort: intstr.FromInt(metricsPort(r)),
 		})
 	}
 
@@ -231,7 +425,9 @@ func generateService(r *k8sv1alpha1.Redis, serviceType int) *corev1.Service {
 			Ports:     ports,
 			Selector:  selector,
 			ClusterIP: clusterIP,
-			Type:      corev1.ServiceTypeClusterIP,
+			Type:      kind,
+			// the replicas resolve the DNS name of the master while it is loading the dataset
+			PublishNotReadyAddresses: serviceType == serviceTypeHeadless && hostnameAddressing(r),
 		},
 	}
 }
@@ -246,11 +442,57 @@ func generatePodDisruptionBudget(r *k8sv1alpha1.Redis) *policyv1beta1.PodDisrupt
 	}
 }
 
-func generateStatefulSet(r *k8sv1alpha1.Redis, password string) *appsv1.StatefulSet {
+// generateCertificate returns the cert-manager Certificate valid for all Services and Pods
+func generateCertificate(r *k8sv1alpha1.Redis) *unstructured.Unstructured {
+	issuerRef := r.Spec.TLS.IssuerRef
+	kind, group := issuerRef.Kind, issuerRef.Group
+	if kind == "" {
+		kind = certManagerIssuer
+	}
+	if group == "" {
+		group = certManagerGroup
+	}
+
+	var dnsNames []interface{}
+	for _, service := range []string{generateName(r), generateHeadlessServiceName(r), generateMasterServiceName(r)} {
+		dnsNames = append(dnsNames,
+			service,
+			fmt.Sprintf("%s.%s", service, r.GetNamespace()),
+			fmt.Sprintf("%s.%s.svc", service, r.GetNamespace()),
+		)
+	}
+	// Pods are resolvable as <pod>.<headless service>.<namespace>.svc
+	dnsNames = append(dnsNames, fmt.Sprintf("*.%s.%s.svc", generateHeadlessServiceName(r), r.GetNamespace()))
+	// the exporter connects to the instance of its Pod over localhost
+	dnsNames = append(dnsNames, "localhost")
+
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"secretName": r.Spec.TLS.SecretName,
+			"dnsNames":   dnsNames,
+			"issuerRef": map[string]interface{}{
+				"name":  issuerRef.Name,
+				"kind":  kind,
+				"group": group,
+			},
+			// the certificate is used by both Redis servers and clients
+			"usages": []interface{}{"digital signature", "key encipherment", "server auth", "client auth"},
+		},
+	}}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetName(generateName(r))
+	certificate.SetNamespace(r.GetNamespace())
+	certificate.SetLabels(r.GetLabels())
+
+	return certificate
+}
+
+func generateStatefulSet(r *k8sv1alpha1.Redis, options objectGeneratorOptions) *appsv1.StatefulSet {
 	// VolumeMount names
 	configMapMountName := fmt.Sprintf("%s-config", generateName(r))
 	secretMountName := fmt.Sprintf("%s-secret", generateName(r))
 	dataMountName := fmt.Sprintf("%s-data", generateName(r))
+	tlsMountName := fmt.Sprintf("%s-tls", generateName(r))
 
 	volumes := []corev1.Volume{{
 		Name: configMapMountName,
@@ -271,7 +513,7 @@ func generateStatefulSet(r *k8sv1alpha1.Redis, password string) *appsv1.Stateful
 	// redis container goes first
 	containers := []corev1.Container{{
 		Name:       redisName,
-		Image:      r.Spec.Redis.Image,
+		Image:      r.Spec.Redis.ImageReference(),
 		Args:       []string{configMapMountPath},
 		WorkingDir: workingDir,
 		Resources:  r.Spec.Redis.Resources,
@@ -281,28 +523,65 @@ func generateStatefulSet(r *k8sv1alpha1.Redis, password string) *appsv1.Stateful
 			MountPath: configMapMountPath,
 			SubPath:   configFileName,
 		}},
-		LivenessProbe: &corev1.Probe{
-			Handler:             corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"redis-cli", "ping"}}},
+		LivenessProbe: containerProbe(&corev1.Probe{
+			Handler:             corev1.Handler{Exec: &corev1.ExecAction{Command: pingCommand(r)}},
 			InitialDelaySeconds: r.Spec.Redis.InitialDelaySeconds,
-		},
-		ReadinessProbe: &corev1.Probe{
-			Handler:             corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"redis-cli", "ping"}}},
+		}, r.Spec.Redis.LivenessProbe),
+		ReadinessProbe: containerProbe(&corev1.Probe{
+			Handler:             corev1.Handler{Exec: &corev1.ExecAction{Command: pingCommand(r)}},
 			InitialDelaySeconds: r.Spec.Redis.InitialDelaySeconds,
-		},
+		}, r.Spec.Redis.ReadinessProbe),
+		StartupProbe:    startupProbe(corev1.Handler{Exec: &corev1.ExecAction{Command: pingCommand(r)}}, r.Spec.Redis.StartupProbe),
 		SecurityContext: r.Spec.Redis.SecurityContext,
 	}}
 
+	// the instances exposed outside of the cluster announce the external addresses of the ConfigMap on start
+	if r.Spec.ExternalAccess != nil {
+		containers[0].Args = append(containers[0].Args, "--include", announceMountPath+"/"+announceConfigName("$(POD_NAME)"))
+		containers[0].VolumeMounts = append(containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      configMapMountName,
+			ReadOnly:  true,
+			MountPath: announceMountPath,
+		})
+	}
+
+	if r.Spec.Annotations == nil {
+		r.Spec.Annotations = make(map[string]string)
+	}
+
+	if options.configRevision != "" {
+		r.Spec.Annotations[configRevisionAnnotationKey] = options.configRevision
+	}
+
+	// the hash of the configuration read on start only rolls the Pods out once it changes
+	var secret *corev1.Secret
+	if authConfigured(r) {
+		secret = generateSecret(r, options)
+	}
+	if hash := configHash(options.configRevision, secret); hash != "" {
+		r.Spec.Annotations[configHashKey] = hash
+	}
+
+	// the static CPU manager policy allocates the exclusive CPUs to the whole CPU requests of the Guaranteed Pods
+	if cpus := k8sv1alpha1.PinnedCPUs(r.Spec.Redis.Resources); r.Spec.CPUPinning && cpus > 0 {
+		r.Spec.Annotations[cpuPinningAnnotationKey] = strconv.FormatInt(cpus, 10)
+	}
+
 	// if Redis is protected by password:
-	// - add the password hash as the annotation to pod,
 	// - add the volume with auth.conf
 	// - mount the volume
+	// the password changes are applied to the running instances by the operator, the Pods are not restarted
 	if r.Spec.Password.SecretKeyRef != nil {
-		// rotating passwords requires Pod restarts.
-		// adding password hash as the pod annotation will automatically trigger rolling pod restarts.
-		r.Spec.Annotations[passwordHashKey] = hex.EncodeToString(argon2.IDKey(
-			[]byte(password), []byte(r.UID), argonTime, argonMemory, argonThreads, hashLen,
-		))
+		containers[0].Env = []corev1.EnvVar{{
+			Name: rediscliAuthEnvName,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: r.Spec.Password.SecretKeyRef,
+			},
+		}}
+	}
 
+	// ACL users are applied live, there's no need to restart pods when they change
+	if authConfigured(r) {
 		volumes = append(volumes, corev1.Volume{
 			Name: secretMountName,
 			VolumeSource: corev1.VolumeSource{
@@ -312,66 +591,117 @@ func generateStatefulSet(r *k8sv1alpha1.Redis, password string) *appsv1.Stateful
 			},
 		})
 
-		containers[0].Env = []corev1.EnvVar{{
-			Name: rediscliAuthEnvName,
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: r.Spec.Password.SecretKeyRef,
+		// the whole Secret is mounted to keep the files updated once the password is rotated
+		containers[0].VolumeMounts = append(containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      secretMountName,
+			ReadOnly:  true,
+			MountPath: secretMountDir,
+		})
+	}
+
+	// the password Secret is synced by the CSI driver while the SecretProviderClass is mounted
+	if r.Spec.Password.SecretProviderClass != "" {
+		volume := generateSecretsStoreVolume(r)
+		volumes = append(volumes, volume)
+		containers[0].VolumeMounts = append(containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      volume.Name,
+			ReadOnly:  true,
+			MountPath: secretsStoreMountPath,
+		})
+	}
+
+	// if TLS is enabled:
+	// - add the certificate hash as the annotation to pod to restart pods once the certificate is renewed,
+	// - add the volume with the certificates
+	// - mount the volume
+	if r.Spec.TLS != nil {
+		certificateHash := sha256.Sum256(options.tlsCertificate)
+		r.Spec.Annotations[tlsCertificateHashKey] = hex.EncodeToString(certificateHash[:])
+
+		volumes = append(volumes, corev1.Volume{
+			Name: tlsMountName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: r.Spec.TLS.SecretName,
+				},
 			},
-		}}
+		})
 
 		containers[0].VolumeMounts = append(containers[0].VolumeMounts, corev1.VolumeMount{
-			Name:      secretMountName,
+			Name:      tlsMountName,
 			ReadOnly:  true,
-			MountPath: secretMountPath,
-			SubPath:   secretFileName,
+			MountPath: tlsMountPath,
 		})
 	}
 
 	var volumeClaimTemplates []corev1.PersistentVolumeClaim
+	dataVolumeMount := corev1.VolumeMount{Name: dataMountName, MountPath: dataMountPath}
 	if !reflect.DeepEqual(r.Spec.DataVolumeClaimTemplate, corev1.PersistentVolumeClaim{}) {
 		volumeClaimTemplates = append(volumeClaimTemplates, r.Spec.DataVolumeClaimTemplate)
-		containers[0].VolumeMounts = append(containers[0].VolumeMounts, corev1.VolumeMount{
-			Name:      r.Spec.DataVolumeClaimTemplate.Name,
-			MountPath: dataMountPath,
-		})
+		dataVolumeMount.Name = r.Spec.DataVolumeClaimTemplate.Name
 	} else {
 		volumes = append(volumes, corev1.Volume{
 			Name:         dataMountName,
 			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
 		})
-		containers[0].VolumeMounts = append(containers[0].VolumeMounts, corev1.VolumeMount{
-			Name:      dataMountName,
-			MountPath: dataMountPath,
-		})
+	}
+	containers[0].VolumeMounts = append(containers[0].VolumeMounts, dataVolumeMount)
+	containers[0].Env = withDownwardAPIEnv(containers[0].Env)
+
+	// the snapshot is restored before any user-defined init containers run,
+	// the restore containers are kept once the data is restored even if the source is no longer available
+	initContainers := r.Spec.InitContainers
+	podLabels := r.GetLabels()
+	serviceAccountName := r.Spec.ServiceAccountName
+	if options.restore != nil {
+		initContainers = append(generateRestoreContainers(r, options.restore, dataVolumeMount), initContainers...)
+		// the storage identity is used unless the Pods have an identity of their own
+		if serviceAccountName == "" {
+			serviceAccountName = options.restore.storage.ServiceAccountName
+		}
+		podLabels = workloadIdentityLabels(podLabels, options.restore.storage)
+	} else if kept := options.keptRestore; kept != nil {
+		initContainers = append(append([]corev1.Container(nil), kept.containers...), initContainers...)
+		if serviceAccountName == "" {
+			serviceAccountName = kept.serviceAccountName
+		}
+		if kept.workloadIdentity {
+			podLabels = withWorkloadIdentityLabel(podLabels)
+		}
+	}
+	// the node settings are checked first
+	if r.Spec.HostPreflight {
+		initContainers = append([]corev1.Container{generatePreflightContainer(r)}, initContainers...)
 	}
 
 	// exporter goes next if it is defined
 	if !reflect.DeepEqual(r.Spec.Exporter, k8sv1alpha1.ContainerSpec{}) {
-		containers = append(containers, corev1.Container{
-			Name:  exporterName,
-			Image: r.Spec.Exporter.Image,
-			Args:  []string{fmt.Sprintf("--web.listen-address=:%d", exporterPort)},
-			Env: []corev1.EnvVar{{
-				Name: "REDIS_ALIAS",
-				ValueFrom: &corev1.EnvVarSource{
-					FieldRef: &corev1.ObjectFieldSelector{
-						FieldPath: "metadata.name",
-					},
-				},
-			}},
-			Resources:       r.Spec.Exporter.Resources,
-			LivenessProbe:   &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(exporterPort)}}},
-			ReadinessProbe:  &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(exporterPort)}}},
-			SecurityContext: r.Spec.Exporter.SecurityContext,
-		})
+		containers = append(containers, generateExporterContainer(r))
+	}
 
-		if r.Spec.Password.SecretKeyRef != nil {
-			containers[1].Env = append(containers[1].Env, corev1.EnvVar{
-				Name:      "REDIS_PASSWORD",
-				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: r.Spec.Password.SecretKeyRef},
-			})
+	// sidecars go last along with the generated files mounted on request
+	var sidecarMounts []corev1.VolumeMount
+	if r.Spec.SidecarMounts != nil {
+		for _, mount := range containers[0].VolumeMounts {
+			if mount.Name == configMapMountName && r.Spec.SidecarMounts.Config ||
+				mount.Name == secretMountName && r.Spec.SidecarMounts.Secret {
+				sidecarMounts = append(sidecarMounts, mount)
+			}
 		}
 	}
+	// the metrics sidecar is run as specified, unlike the exporter
+	if r.Spec.Metrics != nil {
+		metrics := r.Spec.Metrics.Container.DeepCopy()
+		metrics.Name = r.Spec.Metrics.ContainerName()
+		metrics.VolumeMounts = append(metrics.VolumeMounts, sidecarMounts...)
+		containers = append(containers, *metrics)
+	}
+	for i := range r.Spec.Sidecars {
+		sidecar := r.Spec.Sidecars[i].DeepCopy()
+		sidecar.VolumeMounts = append(sidecar.VolumeMounts, sidecarMounts...)
+		sidecar.Env = withDownwardAPIEnv(sidecar.Env)
+		containers = append(containers, *sidecar)
+	}
 
 	s := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -385,14 +715,14 @@ func generateStatefulSet(r *k8sv1alpha1.Redis, password string) *appsv1.Stateful
 			Selector: &metav1.LabelSelector{MatchLabels: r.GetLabels()},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels:      r.GetLabels(),
+					Labels:      podLabels,
 					Annotations: r.Spec.Annotations,
 				},
 				Spec: corev1.PodSpec{
 					Volumes:            volumes,
 					Containers:         containers,
-					InitContainers:     r.Spec.InitContainers,
-					ServiceAccountName: r.Spec.ServiceAccountName,
+					InitContainers:     initContainers,
+					ServiceAccountName: serviceAccountName,
 					SecurityContext:    r.Spec.SecurityContext,
 					ImagePullSecrets:   r.Spec.ImagePullSecrets,
 					Affinity:           r.Spec.Affinity,
@@ -402,26 +732,57 @@ func generateStatefulSet(r *k8sv1alpha1.Redis, password string) *appsv1.Stateful
 				},
 			},
 			VolumeClaimTemplates: volumeClaimTemplates,
-			ServiceName:          fmt.Sprintf("%s-%s", generateName(r), headlessServiceTypeLabel),
+			ServiceName:          generateHeadlessServiceName(r),
 		},
 	}
 
-	// compute the hash of the generated Statefulset and add it as the annotation
-	hash, err := hashObject(s)
+	switch {
+	case r.Spec.OrchestratedUpdate != nil:
+		// the Pods are deleted by the operator to be recreated with the update revision
+		s.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+	case r.Spec.UpdateStrategy != nil:
+		s.Spec.UpdateStrategy = *r.Spec.UpdateStrategy.DeepCopy()
+	}
+	if policy := retentionPolicy(r); policy != nil {
+		s.Annotations[retentionPolicyAnnotationKey] = retentionPolicyAnnotation(policy)
+	}
+
+	setRevisionHash(s)
+	return s
+}
+
+// setRevisionHash computes the hash of the canonical Pod template of the Statefulset and adds it as the annotation.
+// Only the Pod template is hashed with the defaults filled in: the changes of the other fields and the spelled out
+// defaults, e.g. by another release of the operator, do not replace the Pod template.
+func setRevisionHash(s *appsv1.StatefulSet) {
+	if s.Annotations == nil {
+		s.Annotations = make(map[string]string)
+	}
+	hash, err := hashObject(canonicalPodTemplate(s.Spec.Template))
 	if err != nil {
 		// Failing to calculate the hash should not prevent normal operation.
 		// The risk is next to zero anyway.
 		hash = fmt.Sprintf("failed to calculate revision hash: %s", err)
 	}
 	s.Annotations[hashAnnotationKey] = hash
-
-	return s
 }
 
 // state checkers
+func unstructuredUpdateNeeded(got, want *unstructured.Unstructured) (needed bool) {
+	if !isSubset(got.GetLabels(), want.GetLabels()) {
+		got.SetLabels(mergeMaps(got.GetLabels(), want.GetLabels()))
+		needed = true
+	}
+	if !deepContains(got.Object["spec"], want.Object["spec"]) {
+		got.Object["spec"] = want.Object["spec"]
+		needed = true
+	}
+	return
+}
+
 func secretUpdateNeeded(got, want *corev1.Secret) (needed bool) {
-	if !mapsEqual(got.GetLabels(), want.GetLabels()) {
-		got.SetLabels(want.GetLabels())
+	if !isSubset(got.GetLabels(), want.GetLabels()) {
+		got.SetLabels(mergeMaps(got.GetLabels(), want.GetLabels()))
 		needed = true
 	}
 	if !reflect.DeepEqual(got.Data, want.Data) {
@@ -432,28 +793,86 @@ func secretUpdateNeeded(got, want *corev1.Secret) (needed bool) {
 }
 
 func configMapUpdateNeeded(got, want *corev1.ConfigMap) (needed bool) {
-	if !mapsEqual(got.GetLabels(), want.GetLabels()) {
-		got.SetLabels(want.GetLabels())
+	if !isSubset(got.GetLabels(), want.GetLabels()) {
+		got.SetLabels(mergeMaps(got.GetLabels(), want.GetLabels()))
 		needed = true
 	}
-	if !strings.Contains(got.Data[configFileName], want.Data[configFileName]) {
-		got.Data = want.Data
+	for _, key := range []string{configRevisionAnnotationKey, configChecksumAnnotationKey} {
+		if value, ok := want.Annotations[key]; ok && got.Annotations[key] != value {
+			if got.Annotations == nil {
+				got.Annotations = make(map[string]string)
+			}
+			got.Annotations[key] = value
+			needed = true
+		}
+	}
+	// the connection info is compared as a whole
+	if _, ok := want.Data[configFileName]; !ok {
+		if !reflect.DeepEqual(got.Data, want.Data) {
+			got.Data = want.Data
+			needed = true
+		}
+		return
+	}
+	// the configuration is compared by the checksum of the directives regardless of their order and the comments.
+	// The master address is followed once known and kept otherwise, the instances are restarted replicating from it.
+	gotMaster, wantMaster := replicaOf(got.Data[configFileName]), replicaOf(want.Data[configFileName])
+	config := got.Data[configFileName]
+	if configRevision(parseConfig(config)) != want.Annotations[configChecksumAnnotationKey] ||
+		wantMaster != "" && gotMaster != wantMaster {
+		config = want.Data[configFileName]
+		if wantMaster == "" && gotMaster != "" {
+			config += gotMaster + "\n"
+		}
+	}
+	data := map[string]string{configFileName: config}
+	// the announced addresses are followed once known and kept otherwise as well
+	for key, value := range want.Data {
+		if key == configFileName {
+			continue
+		}
+		if current, ok := got.Data[key]; ok && value == "" {
+			value = current
+		}
+		data[key] = value
+	}
+	if !reflect.DeepEqual(got.Data, data) {
+		got.Data = data
 		needed = true
 	}
 	return
 }
 
+// replicaOf returns the replicaof directive of the configuration or an empty string if it replicates from none
+func replicaOf(config string) string {
+	for _, line := range strings.Split(config, "\n") {
+		if strings.HasPrefix(strings.ToLower(line), "replicaof ") {
+			return line
+		}
+	}
+	return ""
+}
+
 func serviceUpdateNeeded(got, want *corev1.Service) (needed bool) {
-	if !mapsEqual(got.GetLabels(), want.GetLabels()) {
-		got.SetLabels(want.GetLabels())
+	if !isSubset(got.GetLabels(), want.GetLabels()) {
+		got.SetLabels(mergeMaps(got.GetLabels(), want.GetLabels()))
 		needed = true
 	}
-	if !mapsEqual(got.Spec.Selector, want.Spec.Selector) {
-		got.Spec.Selector = want.Spec.Selector
+	// the annotations added by the load balancer controllers are kept
+	if !deepContains(got.GetAnnotations(), want.GetAnnotations()) {
+		annotations := got.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		for k, v := range want.GetAnnotations() {
+			annotations[k] = v
+		}
+		got.SetAnnotations(annotations)
 		needed = true
 	}
-	if !deepContains(got.Spec.Ports, want.Spec.Ports) {
-		got.Spec.Ports = want.Spec.Ports
+	// the whole spec is compared, the fields assigned by the cluster are kept
+	if spec := desiredServiceSpec(got.Spec, want.Spec); !equality.Semantic.DeepEqual(got.Spec, spec) {
+		got.Spec = spec
 		needed = true
 	}
 	return
@@ -463,8 +882,8 @@ func podDisruptionBudgetUpdateNeeded(got, want *policyv1beta1.PodDisruptionBudge
 	// updating PDB spec is forbidden
 	// TODO: keep an eye on https://github.com/kubernetes/kubernetes/issues/45398
 	// bring back PDB spec comparison once the minimum supported k8s version is 1.15
-	if !mapsEqual(got.GetLabels(), want.GetLabels()) {
-		got.SetLabels(want.GetLabels())
+	if !isSubset(got.GetLabels(), want.GetLabels()) {
+		got.SetLabels(mergeMaps(got.GetLabels(), want.GetLabels()))
 		return true
 	}
 	return
@@ -477,32 +896,158 @@ func statefulSetUpdateNeeded(got, want *appsv1.StatefulSet) (needed bool) {
 	}
 
 	// compare container resources explicitly. They escape the deepContains comparison because of private fields.
-	if !deepContains(got.Spec.Template, want.Spec.Template) ||
+	// The defaults are compared too, so the Pod template edited to other values than the defaults is reverted.
+	if !deepContains(got.Spec.Template, canonicalPodTemplate(want.Spec.Template)) ||
 		got.Annotations[hashAnnotationKey] != want.Annotations[hashAnnotationKey] ||
 		!resourceRequirementsEqual(got.Spec.Template.Spec.Containers, want.Spec.Template.Spec.Containers) {
 		got.Spec.Template = want.Spec.Template
 		needed = true
 	}
 
-	if !mapsEqual(got.GetLabels(), want.GetLabels()) {
-		got.SetLabels(want.GetLabels())
+	if !updateStrategyEqual(got.Spec.UpdateStrategy, want.Spec.UpdateStrategy) {
+		got.Spec.UpdateStrategy = want.Spec.UpdateStrategy
+		needed = true
+	}
+
+	if !isSubset(got.GetLabels(), want.GetLabels()) {
+		got.SetLabels(mergeMaps(got.GetLabels(), want.GetLabels()))
+		needed = true
+	}
+
+	if !isSubset(got.Annotations, want.Annotations) {
+		got.SetAnnotations(mergeMaps(got.Annotations, want.Annotations))
 		needed = true
 	}
 
-	if !mapsEqual(got.Annotations, want.Annotations) {
-		got.SetAnnotations(want.Annotations)
+	return
+}
+
+// updateStrategyEqual compares the StatefulSet update strategies along with the defaults set by the API server:
+// RollingUpdate with the zero partition
+func updateStrategyEqual(got, want appsv1.StatefulSetUpdateStrategy) bool {
+	strategyType := func(strategy appsv1.StatefulSetUpdateStrategy) appsv1.StatefulSetUpdateStrategyType {
+		if strategy.Type == "" {
+			return appsv1.RollingUpdateStatefulSetStrategyType
+		}
+		return strategy.Type
+	}
+	partition := func(strategy appsv1.StatefulSetUpdateStrategy) int32 {
+		if strategy.RollingUpdate == nil || strategy.RollingUpdate.Partition == nil {
+			return 0
+		}
+		return *strategy.RollingUpdate.Partition
+	}
+	return strategyType(got) == strategyType(want) && partition(got) == partition(want)
+}
+
+func cronJobUpdateNeeded(got, want *batchv1beta1.CronJob) (needed bool) {
+	if !isSubset(got.GetLabels(), want.GetLabels()) {
+		got.SetLabels(mergeMaps(got.GetLabels(), want.GetLabels()))
 		needed = true
 	}
 
+	// compare container resources explicitly. They escape the deepContains comparison because of private fields.
+	if !deepContains(got.Spec, want.Spec) ||
+		!resourceRequirementsEqual(got.Spec.JobTemplate.Spec.Template.Spec.Containers,
+			want.Spec.JobTemplate.Spec.Template.Spec.Containers) {
+		got.Spec.Schedule = want.Spec.Schedule
+		got.Spec.ConcurrencyPolicy = want.Spec.ConcurrencyPolicy
+		got.Spec.Suspend = want.Spec.Suspend
+		got.Spec.JobTemplate = want.Spec.JobTemplate
+		needed = true
+	}
 	return
 }
 
+// containerProbe returns the probe overriding the generated one, the generated handler is kept if the override has none
+func containerProbe(generated, override *corev1.Probe) *corev1.Probe {
+	if override == nil {
+		return generated
+	}
+	probe := override.DeepCopy()
+	if reflect.DeepEqual(probe.Handler, corev1.Handler{}) {
+		probe.Handler = generated.Handler
+	}
+	return probe
+}
+
+// startupProbe returns the startup probe if one is configured, the handler is used unless the probe has one
+func startupProbe(handler corev1.Handler, probe *corev1.Probe) *corev1.Probe {
+	if probe == nil {
+		return nil
+	}
+	return containerProbe(&corev1.Probe{Handler: handler}, probe)
+}
+
+// redisCliCommand returns the redis-cli invocation running the command against the local instance
+func redisCliCommand(r *k8sv1alpha1.Redis, command ...string) []string {
+	cli := []string{"redis-cli"}
+	if r.Spec.TLS != nil {
+		cli = append(cli, "--tls", "--cert", tlsCertFilePath, "--key", tlsKeyFilePath, "--cacert", tlsCAFilePath)
+	}
+	if defaultUserDisabled(r) {
+		cli = append(cli, "--user", redis.OperatorUser)
+	}
+	return append(cli, command...)
+}
+
+// pingCommand returns the probe command pinging the local instance.
+// The password is read from the mounted file, the environment keeps the password the container is started with.
+func pingCommand(r *k8sv1alpha1.Redis) []string {
+	command := redisCliCommand(r, "ping")
+	if port := redisPort(r); port != redis.DefaultPort {
+		command = redisCliCommand(r, "-p", strconv.Itoa(port), "ping")
+	}
+	if r.Spec.Password.SecretKeyRef == nil {
+		return command
+	}
+	return []string{"sh", "-c", fmt.Sprintf(`%s="$(cat %s)" exec %s`, rediscliAuthEnvName, passwordFilePath, strings.Join(command, " "))}
+}
+
+// redisPort returns the port the instances listen on
+func redisPort(r *k8sv1alpha1.Redis) int {
+	if r.Spec.Port != 0 {
+		return int(r.Spec.Port)
+	}
+	return redis.DefaultPort
+}
+
 // generateName returns generic name for all owned resources.
 // It should be used as a prefix for all resources requiring more specific naming scheme.
 func generateName(r *k8sv1alpha1.Redis) string {
 	return fmt.Sprintf(namePrefixTemplate, r.GetName())
 }
 
+// authConfigured reports whether the auth Secret is needed
+func authConfigured(r *k8sv1alpha1.Redis) bool {
+	return r.Spec.Password.SecretKeyRef != nil || (r.Spec.ACL != nil && len(r.Spec.ACL.Users) > 0)
+}
+
+// aclFileEnabled reports whether the users are defined in the ACL file rather than in the configuration
+func aclFileEnabled(r *k8sv1alpha1.Redis) bool {
+	return r.Spec.ACL != nil && r.Spec.ACL.ACLFile
+}
+
+// defaultUserDisabled reports whether the default user is to be disabled and the operator user is to be used instead
+func defaultUserDisabled(r *k8sv1alpha1.Redis) bool {
+	return r.Spec.ACL != nil && r.Spec.ACL.DisableDefaultUser
+}
+
+// generateHeadlessServiceName returns the name of the headless Service governing the StatefulSet
+func generateHeadlessServiceName(r *k8sv1alpha1.Redis) string {
+	return fmt.Sprintf("%s-%s", generateName(r), headlessServiceTypeLabel)
+}
+
+// generateMasterServiceName returns the name of the Service pointing to the master
+func generateMasterServiceName(r *k8sv1alpha1.Redis) string {
+	return fmt.Sprintf("%s-%s", generateName(r), masterLabel)
+}
+
+// generateReplicaServiceName returns the name of the Service pointing to the replicas
+func generateReplicaServiceName(r *k8sv1alpha1.Redis) string {
+	return fmt.Sprintf("%s-%s", generateName(r), replicaLabel)
+}
+
 // mapsEqual compares two plain map[string]string values
 func mapsEqual(a, b map[string]string) bool {
 	return len(a) == len(b) && isSubset(a, b)
@@ -519,8 +1064,21 @@ func isSubset(a, b map[string]string) bool {
 	return true
 }
 
-// hashObject calculates sha256 value of a kubernetes runtime.Object encoded as a JSON string
-func hashObject(object k8sruntime.Object) (string, error) {
+// mergeMaps returns a copy of a with the entries of b set.
+// The labels and the annotations added by the other controllers are kept by the server-side apply.
+func mergeMaps(a, b map[string]string) map[string]string {
+	merged := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+// hashObject calculates sha256 value of an object encoded as a JSON string
+func hashObject(object interface{}) (string, error) {
 	hash := sha256.New()
 	defer hash.Reset()
 
@@ -537,7 +1095,7 @@ func resourceRequirementsEqual(got, want []corev1.Container) bool {
 	}
 
 	for i := range want {
-		if !reflect.DeepEqual(got[i].Resources, want[i].Resources) {
+		if !equality.Semantic.DeepEqual(got[i].Resources, want[i].Resources) {
 			return false
 		}
 	}
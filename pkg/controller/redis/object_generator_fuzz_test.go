@@ -0,0 +1,65 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package redis
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+)
+
+func Fuzz_generateConfigMap(f *testing.F) {
+	for _, directive := range [][2]string{
+		{"maxmemory", "100mb"},
+		{"save", "900 1 300 10"},
+		{"port", "6380"},
+		{"dir", "/tmp"},
+		{"maxmemory", "100mb\ndir /tmp"},
+		{"PORT", "6380"},
+		{"requirepass", `"unbalanced`},
+		{"", ""},
+	} {
+		f.Add(directive[0], directive[1])
+	}
+	f.Fuzz(func(t *testing.T, key, value string) {
+		plain := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+		r := plain.DeepCopy()
+		r.Spec.Config = map[string]string{key: value}
+
+		want := generateConfigMap(plain, redis.Address{}).Data[configFileName]
+		got := generateConfigMap(r, redis.Address{}).Data[configFileName]
+		if !strings.HasPrefix(got, want) {
+			t.Fatalf("generateConfigMap() does not start with the generated directives\nhave: %q\nwant: %q", got, want)
+		}
+		_, excluded := excludedConfigDirectives[strings.ToLower(key)]
+		if excluded || k8sv1alpha1.ValidateConfigDirective(key, value) != nil {
+			if got != want {
+				t.Errorf("generateConfigMap() renders the excluded or invalid %q\nhave: %q\nwant: %q", key, got, want)
+			}
+			return
+		}
+		rendered := strings.TrimPrefix(got, want)
+		if rendered != key+" "+value+"\n" || strings.Count(rendered, "\n") != 1 || strings.ContainsRune(rendered, '\r') {
+			t.Errorf("generateConfigMap() renders %q as %q", key, rendered)
+		}
+	})
+}
@@ -15,7 +15,17 @@
 package redis
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
 )
 
 func Test_mapsEqual(t *testing.T) {
@@ -66,3 +76,468 @@ func Test_isSubset(t *testing.T) {
 		})
 	}
 }
+
+func Test_mergeMaps(t *testing.T) {
+	a := map[string]string{"injected": "true", "app": "redis"}
+	got := mergeMaps(a, map[string]string{"app": "cache", "tier": "backend"})
+	want := map[string]string{"injected": "true", "app": "cache", "tier": "backend"}
+	if !mapsEqual(got, want) {
+		t.Errorf("mergeMaps() = %v, want %v", got, want)
+	}
+	if a["app"] != "redis" {
+		t.Errorf("mergeMaps() modified the map: %v", a)
+	}
+}
+
+func Test_generateConfigMap(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   k8sv1alpha1.RedisSpec
+		master redis.Address
+		want   []string
+	}{
+		{
+			"plain",
+			k8sv1alpha1.RedisSpec{Config: map[string]string{"maxmemory": "100mb", "port": "6380"}},
+			redis.Address{},
+			[]string{"dir /data", "maxmemory 100mb"},
+		},
+		{
+			"sorted",
+			k8sv1alpha1.RedisSpec{Config: map[string]string{"maxmemory": "100mb", "appendonly": "yes", "hz": "20"}},
+			redis.Address{},
+			[]string{"dir /data", "appendonly yes", "hz 20", "maxmemory 100mb"},
+		},
+		{
+			"injection",
+			k8sv1alpha1.RedisSpec{Config: map[string]string{"maxmemory": "100mb\ndir /tmp", "PORT": "6380"}},
+			redis.Address{},
+			[]string{"dir /data"},
+		},
+		{
+			"replica",
+			k8sv1alpha1.RedisSpec{},
+			redis.Address{Host: "10.0.0.1", Port: "6379"},
+			[]string{"dir /data", "replicaof 10.0.0.1 6379"},
+		},
+		{
+			"custom port",
+			k8sv1alpha1.RedisSpec{Port: 7000},
+			redis.Address{Host: "10.0.0.1", Port: "7000"},
+			[]string{"dir /data", "port 7000", "replicaof 10.0.0.1 7000"},
+		},
+		{
+			"tls on custom port",
+			k8sv1alpha1.RedisSpec{Port: 7000, TLS: &k8sv1alpha1.TLS{SecretName: "tls"}},
+			redis.Address{},
+			[]string{
+				"dir /data",
+				"port 0",
+				"tls-port 7000",
+				"tls-cert-file /tls/tls.crt",
+				"tls-key-file /tls/tls.key",
+				"tls-ca-cert-file /tls/ca.crt",
+				"tls-replication yes",
+			},
+		},
+		{
+			"tls",
+			k8sv1alpha1.RedisSpec{TLS: &k8sv1alpha1.TLS{SecretName: "tls"}, Config: map[string]string{"tls-port": "6380"}},
+			redis.Address{},
+			[]string{
+				"dir /data",
+				"port 0",
+				"tls-port 6379",
+				"tls-cert-file /tls/tls.crt",
+				"tls-key-file /tls/tls.key",
+				"tls-ca-cert-file /tls/ca.crt",
+				"tls-replication yes",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: tt.spec}
+			got := generateConfigMap(r, tt.master).Data[configFileName]
+			lines := strings.Split(strings.TrimSpace(got), "\n")[1:]
+			if !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("generateConfigMap()\nhave: %q\nwant: %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func Test_configMapUpdateNeeded(t *testing.T) {
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "example"},
+		Spec:       k8sv1alpha1.RedisSpec{Config: map[string]string{"maxmemory": "100mb", "hz": "20"}},
+	}
+	master := redis.Address{Host: "10.0.0.1", Port: "6379"}
+	withMaster := generateConfigMap(r, master)
+
+	tests := []struct {
+		name       string
+		got        func() *corev1.ConfigMap
+		master     redis.Address
+		want       bool
+		wantMaster string
+	}{
+		{"same", func() *corev1.ConfigMap { return generateConfigMap(r, master) }, master, false, "replicaof 10.0.0.1 6379"},
+		{
+			"master kept",
+			func() *corev1.ConfigMap { return generateConfigMap(r, master) },
+			redis.Address{},
+			false,
+			"replicaof 10.0.0.1 6379",
+		},
+		{
+			"master changed",
+			func() *corev1.ConfigMap { return generateConfigMap(r, master) },
+			redis.Address{Host: "10.0.0.2", Port: "6379"},
+			true,
+			"replicaof 10.0.0.2 6379",
+		},
+		{
+			"reordered",
+			func() *corev1.ConfigMap {
+				configMap := withMaster.DeepCopy()
+				configMap.Data[configFileName] = "dir /data\nreplicaof 10.0.0.1 6379\nmaxmemory 100mb\nhz 20\n"
+				return configMap
+			},
+			master,
+			false,
+			"replicaof 10.0.0.1 6379",
+		},
+		{
+			"edited",
+			func() *corev1.ConfigMap {
+				configMap := withMaster.DeepCopy()
+				configMap.Data[configFileName] = strings.Replace(configMap.Data[configFileName], "hz 20", "hz 10", 1)
+				return configMap
+			},
+			redis.Address{},
+			true,
+			"replicaof 10.0.0.1 6379",
+		},
+		{
+			"no checksum",
+			func() *corev1.ConfigMap {
+				configMap := withMaster.DeepCopy()
+				configMap.Annotations = nil
+				return configMap
+			},
+			master,
+			true,
+			"replicaof 10.0.0.1 6379",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, want := tt.got(), generateConfigMap(r, tt.master)
+			if needed := configMapUpdateNeeded(got, want); needed != tt.want {
+				t.Errorf("configMapUpdateNeeded() = %v, want %v", needed, tt.want)
+			}
+			if replicaOf(got.Data[configFileName]) != tt.wantMaster {
+				t.Errorf("replicaOf() = %q, want %q", replicaOf(got.Data[configFileName]), tt.wantMaster)
+			}
+			if checksum := configRevision(parseConfig(got.Data[configFileName])); got.Annotations[configChecksumAnnotationKey] != checksum {
+				t.Errorf("checksum annotation = %q, want %q", got.Annotations[configChecksumAnnotationKey], checksum)
+			}
+		})
+	}
+}
+
+func Test_generateSecret(t *testing.T) {
+	hash := redis.PasswordHash("secret")
+	options := objectGeneratorOptions{
+		password: "secret",
+		aclUsers: []redis.User{{Name: "app", Rules: []string{"on", "+@read"}, Passwords: []string{"app-secret"}}},
+	}
+	appUser := "user app reset on +@read #" + redis.PasswordHash("app-secret") + "\n"
+
+	tests := []struct {
+		name     string
+		acl      *k8sv1alpha1.ACL
+		wantConf string
+		wantACL  string
+	}{
+		{
+			"configuration",
+			&k8sv1alpha1.ACL{},
+			"requirepass secret\nmasterauth secret\n" + appUser,
+			"",
+		},
+		{
+			"ACL file",
+			&k8sv1alpha1.ACL{ACLFile: true},
+			"masterauth secret\naclfile /secret/users.acl\n",
+			"user default reset on ~* allchannels +@all #" + hash + "\n" + appUser,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: k8sv1alpha1.RedisSpec{ACL: tt.acl}}
+			data := generateSecret(r, options).Data
+			if got := string(data[secretFileName]); got != tt.wantConf {
+				t.Errorf("generateSecret() %s = %q, want %q", secretFileName, got, tt.wantConf)
+			}
+			if got := string(data[aclFileName]); got != tt.wantACL {
+				t.Errorf("generateSecret() %s = %q, want %q", aclFileName, got, tt.wantACL)
+			}
+		})
+	}
+}
+
+func Test_generateSecret_quoting(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+	got := string(generateSecret(r, objectGeneratorOptions{password: "p@ss \"word\"\nrename-command CONFIG \"\""}).Data[secretFileName])
+	want := `requirepass "p@ss \"word\"\x0arename-command CONFIG \"\""` + "\n" +
+		`masterauth "p@ss \"word\"\x0arename-command CONFIG \"\""` + "\n"
+	if got != want {
+		t.Errorf("generateSecret() %s = %q, want %q", secretFileName, got, want)
+	}
+}
+
+func Test_generateStatefulSet_sidecars(t *testing.T) {
+	tests := []struct {
+		name       string
+		mounts     *k8sv1alpha1.SidecarMounts
+		wantMounts []string
+	}{
+		{"no mounts", nil, []string{"logs"}},
+		{"config", &k8sv1alpha1.SidecarMounts{Config: true}, []string{"logs", configMapMountPath}},
+		{"config and secret", &k8sv1alpha1.SidecarMounts{Config: true, Secret: true},
+			[]string{"logs", configMapMountPath, secretMountDir}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: k8sv1alpha1.RedisSpec{
+				Redis:    k8sv1alpha1.ContainerSpec{Image: "redis"},
+				Exporter: k8sv1alpha1.ContainerSpec{Image: "oliver006/redis_exporter"},
+				Password: k8sv1alpha1.Password{SecretKeyRef: &corev1.SecretKeySelector{Key: "password"}},
+				Sidecars: []corev1.Container{{
+					Name:         "fluent-bit",
+					VolumeMounts: []corev1.VolumeMount{{Name: "logs", MountPath: "logs"}},
+				}},
+				SidecarMounts: tt.mounts,
+			}}
+			containers := generateStatefulSet(r, objectGeneratorOptions{password: "secret"}).Spec.Template.Spec.Containers
+			if len(containers) != 3 || containers[2].Name != "fluent-bit" {
+				t.Fatalf("generateStatefulSet() containers = %+v, want the sidecar after redis and exporter", containers)
+			}
+
+			var got []string
+			for _, mount := range containers[2].VolumeMounts {
+				got = append(got, mount.MountPath)
+			}
+			if !reflect.DeepEqual(got, tt.wantMounts) {
+				t.Errorf("generateStatefulSet() sidecar mounts = %v, want %v", got, tt.wantMounts)
+			}
+			if len(r.Spec.Sidecars[0].VolumeMounts) != 1 {
+				t.Errorf("generateStatefulSet() changed the sidecar spec: %+v", r.Spec.Sidecars[0].VolumeMounts)
+			}
+		})
+	}
+}
+
+func Test_generateStatefulSet_metrics(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: k8sv1alpha1.RedisSpec{
+		Redis: k8sv1alpha1.ContainerSpec{Image: "redis"},
+		Metrics: &k8sv1alpha1.Metrics{
+			Container: corev1.Container{Image: "exporter:v2", Args: []string{"--listen=:9500"}},
+			Port:      9500,
+		},
+		SidecarMounts: &k8sv1alpha1.SidecarMounts{Config: true},
+	}}
+	containers := generateStatefulSet(r, objectGeneratorOptions{}).Spec.Template.Spec.Containers
+	if len(containers) != 2 || containers[1].Name != k8sv1alpha1.DefaultMetricsContainerName {
+		t.Fatalf("generateStatefulSet() containers = %+v, want the metrics sidecar after redis", containers)
+	}
+	if !reflect.DeepEqual(containers[1].Args, []string{"--listen=:9500"}) || containers[1].Env != nil {
+		t.Errorf("generateStatefulSet() metrics sidecar = %+v, want it run as specified", containers[1])
+	}
+	if len(containers[1].VolumeMounts) != 1 || containers[1].VolumeMounts[0].MountPath != configMapMountPath {
+		t.Errorf("generateStatefulSet() metrics sidecar mounts = %+v, want the config", containers[1].VolumeMounts)
+	}
+
+	ports := generateService(r, serviceTypeAll).Spec.Ports
+	if len(ports) != 2 || ports[1].Name != exporterName || ports[1].Port != 9500 {
+		t.Errorf("generateService() ports = %+v, want the metrics port named %s", ports, exporterName)
+	}
+}
+
+func Test_generateStatefulSet_configHash(t *testing.T) {
+	spec := func(acl *k8sv1alpha1.ACL) k8sv1alpha1.RedisSpec {
+		return k8sv1alpha1.RedisSpec{
+			Redis:    k8sv1alpha1.ContainerSpec{Image: "redis"},
+			Password: k8sv1alpha1.Password{SecretKeyRef: &corev1.SecretKeySelector{Key: "password"}},
+			ACL:      acl,
+		}
+	}
+	hash := func(spec k8sv1alpha1.RedisSpec, options objectGeneratorOptions) string {
+		r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: spec}
+		return generateStatefulSet(r, options).Spec.Template.Annotations[configHashKey]
+	}
+	options := objectGeneratorOptions{password: "secret", configRevision: "1"}
+	want := hash(spec(nil), options)
+	if want == "" {
+		t.Fatalf("generateStatefulSet() %s is not set", configHashKey)
+	}
+
+	tests := []struct {
+		name    string
+		spec    k8sv1alpha1.RedisSpec
+		options objectGeneratorOptions
+		changed bool
+	}{
+		{"same config", spec(nil), options, false},
+		{"master changed", spec(nil),
+			objectGeneratorOptions{password: "secret", configRevision: "1", master: redis.Address{Host: "10.0.0.1", Port: "6379"}},
+			false},
+		{"password changed", spec(nil), objectGeneratorOptions{password: "rotated", configRevision: "1"}, false},
+		{"ACL users changed", spec(nil), objectGeneratorOptions{
+			password:       "secret",
+			configRevision: "1",
+			aclUsers:       []redis.User{{Name: "app", Rules: []string{"on", "+@read"}, Passwords: []string{"app-secret"}}},
+		}, false},
+		{"revision changed", spec(nil), objectGeneratorOptions{password: "secret", configRevision: "2"}, true},
+		{"ACL file enabled", spec(&k8sv1alpha1.ACL{ACLFile: true}), options, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hash(tt.spec, tt.options); (got != want) != tt.changed {
+				t.Errorf("generateStatefulSet() %s = %q, was %q, want changed %v", configHashKey, got, want, tt.changed)
+			}
+		})
+	}
+
+	if got := hash(spec(nil), objectGeneratorOptions{password: "secret"}); got != "" {
+		t.Errorf("generateStatefulSet() %s = %q without the revision, want none", configHashKey, got)
+	}
+}
+
+func Test_containerProbe(t *testing.T) {
+	handler := corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"redis-cli", "ping"}}}
+	tcpHandler := corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(6379)}}
+	generated := &corev1.Probe{Handler: handler, InitialDelaySeconds: 10}
+
+	tests := []struct {
+		name     string
+		override *corev1.Probe
+		want     *corev1.Probe
+	}{
+		{"generated", nil, generated},
+		{"timings", &corev1.Probe{TimeoutSeconds: 5, PeriodSeconds: 30},
+			&corev1.Probe{Handler: handler, TimeoutSeconds: 5, PeriodSeconds: 30}},
+		{"handler", &corev1.Probe{Handler: tcpHandler, TimeoutSeconds: 5},
+			&corev1.Probe{Handler: tcpHandler, TimeoutSeconds: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containerProbe(generated, tt.override); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("containerProbe() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := startupProbe(handler, nil); got != nil {
+		t.Errorf("startupProbe() = %+v, want nil", got)
+	}
+	want := &corev1.Probe{Handler: handler, FailureThreshold: 60}
+	if got := startupProbe(handler, &corev1.Probe{FailureThreshold: 60}); !reflect.DeepEqual(got, want) {
+		t.Errorf("startupProbe() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_updateStrategyEqual(t *testing.T) {
+	partition := func(partition int32) appsv1.StatefulSetUpdateStrategy {
+		return appsv1.StatefulSetUpdateStrategy{
+			Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+		}
+	}
+	onDelete := appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+
+	tests := []struct {
+		name      string
+		got, want appsv1.StatefulSetUpdateStrategy
+		equal     bool
+	}{
+		{"defaulted", partition(0), appsv1.StatefulSetUpdateStrategy{}, true},
+		{"same partition", partition(2), partition(2), true},
+		{"partition changed", partition(2), partition(1), false},
+		{"partition removed", partition(2), appsv1.StatefulSetUpdateStrategy{}, false},
+		{"on delete", partition(0), onDelete, false},
+		{"rolling update", onDelete, appsv1.StatefulSetUpdateStrategy{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := updateStrategyEqual(tt.got, tt.want); got != tt.equal {
+				t.Errorf("updateStrategyEqual() = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
+
+func Test_pingCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     int32
+		password bool
+		want     []string
+	}{
+		// the probes of the existing Pods are not changed
+		{"default port", 0, false, []string{"redis-cli", "ping"}},
+		{"custom port", 7000, false, []string{"redis-cli", "-p", "7000", "ping"}},
+		{"password", 7000, true, []string{"sh", "-c", `REDISCLI_AUTH="$(cat /secret/password)" exec redis-cli -p 7000 ping`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{Port: tt.port}}
+			if tt.password {
+				r.Spec.Password.SecretKeyRef = &corev1.SecretKeySelector{Key: "password"}
+			}
+			if got := pingCommand(r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pingCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generateCertificate(t *testing.T) {
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "ns"},
+		Spec: k8sv1alpha1.RedisSpec{TLS: &k8sv1alpha1.TLS{
+			SecretName: "example-tls",
+			IssuerRef:  &k8sv1alpha1.IssuerReference{Name: "ca"},
+		}},
+	}
+	certificate := generateCertificate(r)
+
+	if certificate.GroupVersionKind() != certificateGVK || certificate.GetName() != "redis-example" {
+		t.Errorf("generateCertificate() = %s %s", certificate.GroupVersionKind(), certificate.GetName())
+	}
+	spec := certificate.Object["spec"].(map[string]interface{})
+	if spec["secretName"] != "example-tls" {
+		t.Errorf("secretName = %v, want example-tls", spec["secretName"])
+	}
+	if want := map[string]interface{}{"name": "ca", "kind": "Issuer", "group": "cert-manager.io"}; !reflect.DeepEqual(spec["issuerRef"], want) {
+		t.Errorf("issuerRef = %v, want %v", spec["issuerRef"], want)
+	}
+
+	dnsNames := make(map[interface{}]bool)
+	for _, name := range spec["dnsNames"].([]interface{}) {
+		dnsNames[name] = true
+	}
+	for _, name := range []string{
+		"redis-example.ns.svc",
+		"redis-example-master.ns.svc",
+		"redis-example-headless.ns.svc",
+		"*.redis-example-headless.ns.svc",
+		"localhost",
+	} {
+		if !dnsNames[name] {
+			t.Errorf("dnsNames = %v, missing %s", spec["dnsNames"], name)
+		}
+	}
+}
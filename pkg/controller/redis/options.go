@@ -0,0 +1,159 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amaizfinance/redis-operator/pkg/features"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+
+	"golang.org/x/time/rate"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Options configure the Redis and RedisBackup controllers. The operator sets them with the command line flags,
+// the managers embedding the controllers start with DefaultOptions.
+type Options struct {
+	// RedisClientName is set with CLIENT SETNAME on the operator connections to Redis, empty disables it
+	RedisClientName string
+	// RedisProtocol is the RESP protocol version negotiated on the operator connections to Redis.
+	// 0 keeps the server default.
+	RedisProtocol int
+	// ReconcileAPIBudget is the maximum number of write requests to the Kubernetes API per reconciliation.
+	// 0 disables the limit.
+	ReconcileAPIBudget int
+	// ReconcileTimeout is the deadline of a single reconciliation, 0 disables the deadline
+	ReconcileTimeout time.Duration
+	// MaxConcurrentReconciles is the number of the Redis and the RedisBackup resources reconciled concurrently.
+	// A Redis is never reconciled by more than one worker at a time.
+	MaxConcurrentReconciles int
+	// RateLimiterBaseDelay and RateLimiterMaxDelay bound the exponential backoff the failed reconciliations
+	// of a resource are requeued with. 0 keeps the default.
+	RateLimiterBaseDelay time.Duration
+	RateLimiterMaxDelay  time.Duration
+	// RateLimiterQPS and RateLimiterBurst limit the rate the resources are requeued at overall. 0 keeps the default.
+	RateLimiterQPS   float64
+	RateLimiterBurst int
+	// ServiceMonitors enables the ServiceMonitors generation for the Redis with the exporter
+	ServiceMonitors bool
+	// OperatorNamespace is the namespace the NetworkPolicies allow the operator Pods from.
+	// Defaults to the namespace the operator runs in.
+	OperatorNamespace string
+	// OperatorPodLabels select the operator Pods allowed by the NetworkPolicies, e.g. app=redis-operator
+	OperatorPodLabels string
+	// ClusterDomain is the DNS domain of the cluster the stable DNS names of the instances are reported in
+	ClusterDomain string
+	// NotificationSecret is the Secret, namespace/name or a name in OperatorNamespace, holding the Datadog API key
+	// or the AWS credentials the failover and health Events are sent with. Empty disables the notifications.
+	NotificationSecret string
+	// Hooks mutate the generated objects, they can not be set with the flags
+	Hooks Hooks
+	// FeatureGates enable the features shipped disabled, overridden for a Redis with the features.Annotation
+	FeatureGates *features.Gates
+}
+
+// DefaultOptions returns the Options the operator runs with unless changed by the flags
+func DefaultOptions() Options {
+	return Options{
+		RedisClientName:         redis.DefaultClientName,
+		ReconcileTimeout:        2 * time.Minute,
+		MaxConcurrentReconciles: 1,
+		RateLimiterBaseDelay:    5 * time.Millisecond,
+		RateLimiterMaxDelay:     1000 * time.Second,
+		RateLimiterQPS:          10,
+		RateLimiterBurst:        100,
+		ServiceMonitors:         true,
+		OperatorPodLabels:       "app=redis-operator",
+		ClusterDomain:           defaultClusterDomain,
+		FeatureGates:            features.DefaultGates,
+	}
+}
+
+// validate checks the options and returns them with the operator namespace and the feature gates defaulted
+func (o Options) validate() (Options, error) {
+	if err := (redis.Options{ClientName: o.RedisClientName, Protocol: o.RedisProtocol}).Validate(); err != nil {
+		return o, fmt.Errorf("invalid Redis connection options: %s", err)
+	}
+	if _, err := labels.ConvertSelectorToLabelsMap(o.OperatorPodLabels); err != nil {
+		return o, fmt.Errorf("invalid operator Pod labels: %s", err)
+	}
+	if o.MaxConcurrentReconciles < 0 {
+		return o, fmt.Errorf("invalid maximum number of concurrent reconciles: %d", o.MaxConcurrentReconciles)
+	}
+	if o.MaxConcurrentReconciles == 0 {
+		o.MaxConcurrentReconciles = 1
+	}
+	if o.RateLimiterBaseDelay < 0 || o.RateLimiterMaxDelay < 0 || o.RateLimiterQPS < 0 || o.RateLimiterBurst < 0 {
+		return o, fmt.Errorf("invalid rate limiter: the delays, the QPS and the burst must not be negative")
+	}
+	if o.RateLimiterBaseDelay > 0 && o.RateLimiterMaxDelay > 0 && o.RateLimiterMaxDelay < o.RateLimiterBaseDelay {
+		return o, fmt.Errorf("invalid rate limiter: maximum delay %s is below the base delay %s",
+			o.RateLimiterMaxDelay, o.RateLimiterBaseDelay)
+	}
+	if o.ClusterDomain == "" {
+		o.ClusterDomain = defaultClusterDomain
+	}
+	if o.FeatureGates == nil {
+		o.FeatureGates = features.DefaultGates
+	}
+	if o.OperatorNamespace == "" {
+		// the operator running locally is not matched by the NetworkPolicy namespace
+		o.OperatorNamespace, _ = k8sutil.GetOperatorNamespace()
+	}
+	return o, nil
+}
+
+// operatorPeer returns the peer matching the operator Pods. The operator running out of the cluster
+// has no namespace, the operator Pods are looked up in the namespace of the Redis then.
+func (o Options) operatorPeer() networkingv1.NetworkPolicyPeer {
+	// the labels are validated along with the options
+	podLabels, _ := labels.ConvertSelectorToLabelsMap(o.OperatorPodLabels)
+	peer := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: podLabels}}
+	if o.OperatorNamespace != "" {
+		peer.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabelKey: o.OperatorNamespace}}
+	}
+	return peer
+}
+
+// rateLimiter returns the rate limiter of the controller work queue: the larger of the exponential backoff
+// of the failed reconciliations of a resource and the delay of the token bucket shared by all the resources.
+// Every controller needs a rate limiter of its own, the backoff is tracked per resource.
+func (o Options) rateLimiter() workqueue.RateLimiter {
+	defaults := DefaultOptions()
+	if o.RateLimiterBaseDelay == 0 {
+		o.RateLimiterBaseDelay = defaults.RateLimiterBaseDelay
+	}
+	if o.RateLimiterMaxDelay == 0 {
+		o.RateLimiterMaxDelay = defaults.RateLimiterMaxDelay
+	}
+	if o.RateLimiterQPS == 0 {
+		o.RateLimiterQPS = defaults.RateLimiterQPS
+	}
+	if o.RateLimiterBurst == 0 {
+		o.RateLimiterBurst = defaults.RateLimiterBurst
+	}
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(o.RateLimiterBaseDelay, o.RateLimiterMaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(o.RateLimiterQPS), o.RateLimiterBurst)},
+	)
+}
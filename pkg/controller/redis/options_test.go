@@ -0,0 +1,74 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOptions_validate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		options func(*Options)
+		wantErr bool
+	}{
+		{"default", func(*Options) {}, false},
+		{"invalid protocol", func(o *Options) { o.RedisProtocol = 4 }, true},
+		{"invalid operator Pod labels", func(o *Options) { o.OperatorPodLabels = "app in (redis-operator)" }, true},
+		{"empty cluster domain", func(o *Options) { o.ClusterDomain = "" }, false},
+		{"concurrent reconciles not set", func(o *Options) { o.MaxConcurrentReconciles = 0 }, false},
+		{"negative concurrent reconciles", func(o *Options) { o.MaxConcurrentReconciles = -1 }, true},
+		{"rate limiter not set", func(o *Options) { o.RateLimiterQPS, o.RateLimiterBaseDelay = 0, 0 }, false},
+		{"negative rate limiter burst", func(o *Options) { o.RateLimiterBurst = -1 }, true},
+		{"rate limiter max delay below base delay", func(o *Options) {
+			o.RateLimiterBaseDelay, o.RateLimiterMaxDelay = time.Second, time.Millisecond
+		}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultOptions()
+			options.OperatorNamespace = "redis-operator"
+			tt.options(&options)
+
+			got, err := options.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Options.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.OperatorNamespace != options.OperatorNamespace {
+				t.Errorf("Options.validate() OperatorNamespace = %s, want %s", got.OperatorNamespace, options.OperatorNamespace)
+			}
+			if err == nil && got.ClusterDomain == "" {
+				t.Error("Options.validate() ClusterDomain is not defaulted")
+			}
+			if err == nil && got.MaxConcurrentReconciles < 1 {
+				t.Error("Options.validate() MaxConcurrentReconciles is not defaulted")
+			}
+		})
+	}
+}
+
+func TestOptions_rateLimiter(t *testing.T) {
+	options := Options{RateLimiterBaseDelay: 10 * time.Millisecond, RateLimiterMaxDelay: 40 * time.Millisecond}
+	limiter := options.rateLimiter()
+	for i, want := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond} {
+		if got := limiter.When("redis"); got != want {
+			t.Errorf("rateLimiter().When() #%d = %s, want %s", i, got, want)
+		}
+	}
+	limiter.Forget("redis")
+	if got := limiter.When("redis"); got != 10*time.Millisecond {
+		t.Errorf("rateLimiter().When() after Forget() = %s, want %s", got, 10*time.Millisecond)
+	}
+}
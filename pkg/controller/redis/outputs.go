@@ -0,0 +1,40 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+// generateOutputs returns the stable outputs of the Redis reported in the status
+func generateOutputs(r *k8sv1alpha1.Redis, clusterDomain string) *k8sv1alpha1.Outputs {
+	outputs := &k8sv1alpha1.Outputs{
+		Version:     k8sv1alpha1.OutputsVersion,
+		MasterHost:  serviceFQDN(r, generateMasterServiceName(r), clusterDomain),
+		ReplicaHost: serviceFQDN(r, generateReplicaServiceName(r), clusterDomain),
+		Port:        int32(redisPort(r)),
+		TLS:         r.Spec.TLS != nil,
+	}
+	if ref := r.Spec.Password.SecretKeyRef; ref != nil {
+		outputs.PasswordSecretName, outputs.PasswordSecretKey = ref.Name, ref.Key
+	}
+	if r.Spec.ConnectionInfo {
+		outputs.ConnectionInfoConfigMapName = generateConnectionInfoName(r)
+	}
+	if r.Spec.ServiceBinding {
+		outputs.BindingSecretName = generateServiceBindingName(r)
+	}
+	return outputs
+}
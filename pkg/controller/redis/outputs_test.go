@@ -0,0 +1,76 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_generateOutputs(t *testing.T) {
+	tests := []struct {
+		name string
+		spec k8sv1alpha1.RedisSpec
+		want *k8sv1alpha1.Outputs
+	}{
+		{
+			"default",
+			k8sv1alpha1.RedisSpec{},
+			&k8sv1alpha1.Outputs{
+				Version:     "v1",
+				MasterHost:  "redis-example-master.default.svc.cluster.local",
+				ReplicaHost: "redis-example-replica.default.svc.cluster.local",
+				Port:        6379,
+			},
+		},
+		{
+			"all",
+			k8sv1alpha1.RedisSpec{
+				Port: 7000,
+				TLS:  &k8sv1alpha1.TLS{SecretName: "redis-tls"},
+				Password: k8sv1alpha1.Password{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "redis-password"},
+					Key:                  "password",
+				}},
+				ConnectionInfo: true,
+				ServiceBinding: true,
+			},
+			&k8sv1alpha1.Outputs{
+				Version:                     "v1",
+				MasterHost:                  "redis-example-master.default.svc.cluster.local",
+				ReplicaHost:                 "redis-example-replica.default.svc.cluster.local",
+				Port:                        7000,
+				TLS:                         true,
+				PasswordSecretName:          "redis-password",
+				PasswordSecretKey:           "password",
+				ConnectionInfoConfigMapName: "redis-example-connection",
+				BindingSecretName:           "redis-example-binding",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}, Spec: tt.spec}
+			if got := generateOutputs(r, defaultClusterDomain); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generateOutputs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
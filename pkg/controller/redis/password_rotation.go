@@ -0,0 +1,97 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// appliedPasswords returns the password in the authentication configuration of the Secret, empty if the password
+// is not set, and the previous password accepted along with it
+func appliedPasswords(secret *corev1.Secret) (password, previous string) {
+	return appliedPassword(secret), string(secret.Data[previousPasswordFileName])
+}
+
+// appliedPassword returns the password in the authentication configuration of the Secret,
+// empty if the password is not set
+func appliedPassword(secret *corev1.Secret) string {
+	for _, line := range strings.Split(string(secret.Data[secretFileName]), "\n") {
+		args, err := k8sv1alpha1.SplitConfigArgs(line)
+		if err != nil || len(args) != 2 {
+			continue
+		}
+		// masterauth is set along with the password in every authentication configuration
+		if strings.EqualFold(args[0], "masterauth") {
+			return args[1]
+		}
+	}
+	return ""
+}
+
+// rotatePassword applies the changed password to the running instances before the authentication Secret
+// is updated, the Pods are not restarted. The applied password is read from the Secret, so the interrupted
+// rotation is retried until the Secret is updated. Setting or removing the password restarts the Pods instead.
+// The previous password accepted along with the password is added and removed the same way.
+func (reconciler *ReconcileRedis) rotatePassword(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	options objectGeneratorOptions,
+	tlsConfig *tls.Config,
+) error {
+	secret := new(corev1.Secret)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateName(r)}, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to fetch Secret: %s", err)
+	}
+	applied, appliedPrevious := appliedPasswords(secret)
+	if applied == "" || options.password == "" || applied == options.password && appliedPrevious == options.previousPassword {
+		return nil
+	}
+
+	addresses, err := reconciler.readyAddresses(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	// the operator user is authenticated with the password once the default user is disabled
+	var username string
+	if defaultUserDisabled(r) || options.defaultUserDisabled {
+		username = redis.OperatorUser
+	}
+	if err := redis.RotatePassword(ctx, redis.Options{
+		Password:   applied,
+		Username:   username,
+		TLSConfig:  tlsConfig,
+		ClientName: reconciler.options.RedisClientName,
+		Protocol:   reconciler.options.RedisProtocol,
+	}, options.password, options.previousPassword, addresses...); err != nil {
+		return err
+	}
+	reconciler.recorder.Event(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonPasswordRotated,
+		"password rotated on the running instances")
+	return nil
+}
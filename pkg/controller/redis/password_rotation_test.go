@@ -0,0 +1,65 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"strings"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_appliedPassword(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+	disabled := r.DeepCopy()
+	disabled.Spec.ACL = &k8sv1alpha1.ACL{DisableDefaultUser: true}
+
+	tests := []struct {
+		name   string
+		secret *corev1.Secret
+		want   string
+	}{
+		{"empty", new(corev1.Secret), ""},
+		{"auth", generateSecret(r, objectGeneratorOptions{password: "secret"}), "secret"},
+		{"quoted", generateSecret(r, objectGeneratorOptions{password: "p@ss \"word\"\nx"}), "p@ss \"word\"\nx"},
+		{"default user disabled", generateSecret(disabled, objectGeneratorOptions{password: "secret", defaultUserDisabled: true}), "secret"},
+		{"no password", generateSecret(r, objectGeneratorOptions{}), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appliedPassword(tt.secret); got != tt.want {
+				t.Errorf("appliedPassword() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generateSecret_previousPassword(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+	secret := generateSecret(r, objectGeneratorOptions{password: "new", previousPassword: "old"})
+
+	conf := string(secret.Data[secretFileName])
+	defaultUser := "user default reset on ~* allchannels +@all #" + redis.PasswordHash("new") + " #" + redis.PasswordHash("old") + "\n"
+	if strings.Contains(conf, "requirepass") || !strings.Contains(conf, "masterauth new\n") || !strings.Contains(conf, defaultUser) {
+		t.Errorf("generateSecret() %s = %q, want masterauth and the default user with both passwords", secretFileName, conf)
+	}
+	if password, previous := appliedPasswords(secret); password != "new" || previous != "old" {
+		t.Errorf("appliedPasswords() = %q, %q, want %q, %q", password, previous, "new", "old")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// secretsStoreDriver is the name of the Secrets Store CSI Driver
+	secretsStoreDriver    = "secrets-store.csi.k8s.io"
+	secretsStoreMountPath = "/mnt/secrets-store"
+)
+
+// generateSecretsStoreVolume returns the CSI volume of the SecretProviderClass. The driver syncs the Secrets
+// declared in the class only while it is mounted by a Pod, hence it is mounted into the Redis Pods.
+func generateSecretsStoreVolume(r *k8sv1alpha1.Redis) corev1.Volume {
+	readOnly := true
+	return corev1.Volume{
+		Name: generateName(r) + "-secrets-store",
+		VolumeSource: corev1.VolumeSource{CSI: &corev1.CSIVolumeSource{
+			Driver:           secretsStoreDriver,
+			ReadOnly:         &readOnly,
+			VolumeAttributes: map[string]string{"secretProviderClass": r.Spec.Password.SecretProviderClass},
+		}},
+	}
+}
+
+// passwordSecretReferenced reports whether the password of the Redis is read from the Secret
+func passwordSecretReferenced(r *k8sv1alpha1.Redis, secret string) bool {
+	return r.Spec.Password.SecretKeyRef != nil && r.Spec.Password.SecretKeyRef.Name == secret
+}
+
+// passwordSecretToRequests maps the password Secrets to the Redis resources reading the password from them,
+// so the changed password is rotated whether it is updated by the user, the CSI driver or a secrets operator
+func passwordSecretToRequests(c client.Client) handler.ToRequestsFunc {
+	return func(object handler.MapObject) []reconcile.Request {
+		redisList := new(k8sv1alpha1.RedisList)
+		if err := c.List(context.TODO(), redisList, client.InNamespace(object.Meta.GetNamespace())); err != nil {
+			log.Error(err, "failed to list Redis", "Namespace", object.Meta.GetNamespace())
+			return nil
+		}
+		var requests []reconcile.Request
+		for i := range redisList.Items {
+			if passwordSecretReferenced(&redisList.Items[i], object.Meta.GetName()) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Namespace: redisList.Items[i].GetNamespace(),
+					Name:      redisList.Items[i].GetName(),
+				}})
+			}
+		}
+		return requests
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_passwordSecretReferenced(t *testing.T) {
+	tests := []struct {
+		name     string
+		password k8sv1alpha1.Password
+		secret   string
+		want     bool
+	}{
+		{"no password", k8sv1alpha1.Password{}, "redis-password", false},
+		{"referenced", k8sv1alpha1.Password{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "redis-password"}, Key: "password"}}, "redis-password", true},
+		{"other Secret", k8sv1alpha1.Password{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "redis-password"}, Key: "password"}}, "redis-tls", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{Password: tt.password}}
+			if got := passwordSecretReferenced(r, tt.secret); got != tt.want {
+				t.Errorf("passwordSecretReferenced() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generateStatefulSet_secretProviderClass(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Spec: k8sv1alpha1.RedisSpec{
+		Redis: k8sv1alpha1.ContainerSpec{Image: "redis"},
+		Password: k8sv1alpha1.Password{
+			SecretKeyRef:        &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "redis-password"}},
+			SecretProviderClass: "vault-redis",
+		},
+	}}
+	spec := generateStatefulSet(r, objectGeneratorOptions{password: "secret"}).Spec.Template.Spec
+
+	var volume *corev1.Volume
+	for i := range spec.Volumes {
+		if spec.Volumes[i].CSI != nil {
+			volume = &spec.Volumes[i]
+		}
+	}
+	if volume == nil || volume.CSI.Driver != secretsStoreDriver || volume.CSI.VolumeAttributes["secretProviderClass"] != "vault-redis" {
+		t.Fatalf("generateStatefulSet() volumes = %+v, want the CSI volume of the SecretProviderClass", spec.Volumes)
+	}
+	for _, mount := range spec.Containers[0].VolumeMounts {
+		if mount.Name == volume.Name && mount.MountPath == secretsStoreMountPath {
+			return
+		}
+	}
+	t.Errorf("generateStatefulSet() redis mounts = %+v, want %s mounted", spec.Containers[0].VolumeMounts, volume.Name)
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// pausedMessage is the message of the Paused condition and Event
+const pausedMessage = "reconciliation is paused, the resources and the replication are not managed"
+
+// reconcilePaused sets the Paused condition of the Redis whose reconciliation is paused.
+// Neither the owned resources nor the instances are touched until the Redis is resumed.
+func (reconciler *ReconcileRedis) reconcilePaused(ctx context.Context, r *k8sv1alpha1.Redis) (reconcile.Result, error) {
+	status := r.Status.DeepCopy()
+	if !status.SetCondition(newCondition(k8sv1alpha1.ConditionPaused, corev1.ConditionTrue,
+		k8sv1alpha1.ReasonPaused, pausedMessage)) {
+		return reconcile.Result{}, nil
+	}
+
+	updated := r.DeepCopy()
+	updated.Status = *status
+	if err := reconciler.client.Status().Update(ctx, updated); err != nil {
+		if errors.IsConflict(err) {
+			return reconcile.Result{Requeue: true}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to update Redis status: %s", err)
+	}
+	log.Info("Reconciliation paused", "Namespace", r.GetNamespace(), "Redis", r.GetName())
+	reconciler.recorder.Event(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonPaused, pausedMessage)
+	return reconcile.Result{}, nil
+}
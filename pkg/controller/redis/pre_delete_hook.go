@@ -0,0 +1,163 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// preDeleteHookFinalizer keeps the deleted Redis and the owned resources until the pre-delete hook has completed
+const preDeleteHookFinalizer = "k8s.amaiz.com/pre-delete-hook"
+
+// generatePreDeleteJobName returns the name of the Job running the pre-delete hook
+func generatePreDeleteJobName(r *k8sv1alpha1.Redis) string {
+	return fmt.Sprintf("%s-pre-delete", generateName(r))
+}
+
+// generatePreDeleteJob returns the Job running the pre-delete hook of the Redis
+func generatePreDeleteJob(r *k8sv1alpha1.Redis) *batchv1.Job {
+	labels := make(map[string]string)
+	for k, v := range r.GetLabels() {
+		labels[k] = v
+	}
+	labels[redisName] = r.GetName()
+
+	template := r.Spec.PreDeleteHook.Template.DeepCopy()
+	if template.Spec.RestartPolicy == "" {
+		template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	}
+	var backoffLimit int32
+	if r.Spec.PreDeleteHook.BackoffLimit != nil {
+		backoffLimit = *r.Spec.PreDeleteHook.BackoffLimit
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generatePreDeleteJobName(r),
+			Namespace: r.GetNamespace(),
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     *template,
+		},
+	}
+}
+
+// hasFinalizer reports whether the object carries the finalizer
+func hasFinalizer(object metav1.Object, finalizer string) bool {
+	for _, f := range object.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileFinalizer adds the finalizer to the Redis with the pre-delete hook and removes it once the hook is unset.
+// Reports whether the Redis has been updated.
+func (reconciler *ReconcileRedis) reconcileFinalizer(ctx context.Context, r *k8sv1alpha1.Redis) (bool, error) {
+	wanted := r.Spec.PreDeleteHook != nil
+	if wanted == hasFinalizer(r, preDeleteHookFinalizer) {
+		return false, nil
+	}
+
+	updated := r.DeepCopy()
+	if wanted {
+		controllerutil.AddFinalizer(updated, preDeleteHookFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(updated, preDeleteHookFinalizer)
+	}
+	if err := reconciler.client.Update(ctx, updated); err != nil {
+		return false, fmt.Errorf("failed to update Redis finalizers: %s", err)
+	}
+	return true, nil
+}
+
+// runPreDeleteHook runs the Job of the pre-delete hook of the deleted Redis and removes the finalizer
+// once the Job has completed. The changes of the Job are watched.
+func (reconciler *ReconcileRedis) runPreDeleteHook(ctx context.Context, r *k8sv1alpha1.Redis) (reconcile.Result, error) {
+	if !hasFinalizer(r, preDeleteHookFinalizer) {
+		return reconcile.Result{}, nil
+	}
+	logger := log.WithValues("Namespace", r.GetNamespace(), "Redis", r.GetName())
+	if r.Spec.PreDeleteHook == nil {
+		return reconcile.Result{}, reconciler.removePreDeleteHookFinalizer(ctx, r)
+	}
+
+	job := new(batchv1.Job)
+	err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generatePreDeleteJobName(r)}, job)
+	if errors.IsNotFound(err) {
+		job = generatePreDeleteJob(r)
+		if err := controllerutil.SetControllerReference(r, job, reconciler.scheme); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to set owner for Job: %s", err)
+		}
+		if err := reconciler.client.Create(ctx, job); err != nil {
+			if errors.HasStatusCause(err, corev1.NamespaceTerminatingCause) {
+				// nothing can be run in the deleted namespace, the Redis must not block its deletion
+				logger.Info("Namespace is terminating, skipping the pre-delete hook")
+				return reconcile.Result{}, reconciler.removePreDeleteHookFinalizer(ctx, r)
+			}
+			if !errors.IsAlreadyExists(err) {
+				return reconcile.Result{}, fmt.Errorf("failed to create Job: %s", err)
+			}
+		}
+		logger.Info("Pre-delete hook started")
+		return reconcile.Result{}, nil
+	}
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to fetch Job: %s", err)
+	}
+
+	phase, _, message := jobPhase(job)
+	switch phase {
+	case k8sv1alpha1.BackupPhaseSucceeded:
+		reconciler.recorder.Event(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonPreDeleteHookCompleted,
+			"pre-delete hook has completed")
+	case k8sv1alpha1.BackupPhaseFailed:
+		logger.Info("Pre-delete hook failed", "message", message)
+		reconciler.recorder.Eventf(r, corev1.EventTypeWarning, k8sv1alpha1.ReasonPreDeleteHookFailed,
+			"pre-delete hook has failed: %s", message)
+		if !r.Spec.PreDeleteHook.IgnoreFailure {
+			return reconcile.Result{}, nil
+		}
+	default:
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{}, reconciler.removePreDeleteHookFinalizer(ctx, r)
+}
+
+// removePreDeleteHookFinalizer lets the deletion of the Redis proceed
+func (reconciler *ReconcileRedis) removePreDeleteHookFinalizer(ctx context.Context, r *k8sv1alpha1.Redis) error {
+	updated := r.DeepCopy()
+	controllerutil.RemoveFinalizer(updated, preDeleteHookFinalizer)
+	if err := reconciler.client.Update(ctx, updated); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove Redis finalizer: %s", err)
+	}
+	log.Info("Pre-delete hook finalizer removed", "Namespace", r.GetNamespace(), "Redis", r.GetName())
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_generatePreDeleteJob(t *testing.T) {
+	three := int32(3)
+	tests := []struct {
+		name              string
+		hook              k8sv1alpha1.PreDeleteHook
+		wantRestartPolicy corev1.RestartPolicy
+		wantBackoffLimit  int32
+	}{
+		{"defaults", k8sv1alpha1.PreDeleteHook{}, corev1.RestartPolicyNever, 0},
+		{
+			"set",
+			k8sv1alpha1.PreDeleteHook{
+				Template:     corev1.PodTemplateSpec{Spec: corev1.PodSpec{RestartPolicy: corev1.RestartPolicyOnFailure}},
+				BackoffLimit: &three,
+			},
+			corev1.RestartPolicyOnFailure,
+			3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{
+				ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default", Labels: map[string]string{"app": "cache"}},
+				Spec:       k8sv1alpha1.RedisSpec{PreDeleteHook: &tt.hook},
+			}
+			got := generatePreDeleteJob(r)
+			if got.Name != "redis-example-pre-delete" || got.Namespace != "default" {
+				t.Errorf("generatePreDeleteJob() = %s/%s, want default/redis-example-pre-delete", got.Namespace, got.Name)
+			}
+			if got.Labels["app"] != "cache" || got.Labels[redisName] != "example" {
+				t.Errorf("generatePreDeleteJob() labels = %v", got.Labels)
+			}
+			if got.Spec.Template.Spec.RestartPolicy != tt.wantRestartPolicy {
+				t.Errorf("generatePreDeleteJob() restartPolicy = %s, want %s",
+					got.Spec.Template.Spec.RestartPolicy, tt.wantRestartPolicy)
+			}
+			if *got.Spec.BackoffLimit != tt.wantBackoffLimit {
+				t.Errorf("generatePreDeleteJob() backoffLimit = %d, want %d", *got.Spec.BackoffLimit, tt.wantBackoffLimit)
+			}
+			if tt.hook.Template.Spec.RestartPolicy == "" && r.Spec.PreDeleteHook.Template.Spec.RestartPolicy != "" {
+				t.Error("generatePreDeleteJob() modified the Redis")
+			}
+		})
+	}
+}
+
+func Test_hasFinalizer(t *testing.T) {
+	tests := []struct {
+		name       string
+		finalizers []string
+		want       bool
+	}{
+		{"none", nil, false},
+		{"other", []string{"foregroundDeletion"}, false},
+		{"present", []string{"foregroundDeletion", preDeleteHookFinalizer}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Finalizers: tt.finalizers}}
+			if got := hasFinalizer(r, preDeleteHookFinalizer); got != tt.want {
+				t.Errorf("hasFinalizer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// preflightContainerName is the name of the init container checking the node settings
+const preflightContainerName = "preflight"
+
+// preflightScript prints the node settings degrading Redis as name=value lines to the termination message
+// the operator reads from the Pod status. The settings are not namespaced and are readable in any container,
+// hence the check needs no privileges. It never fails, the Pods are started regardless.
+var preflightScript = strings.Join([]string{
+	`thp="$(cat /sys/kernel/mm/transparent_hugepage/enabled 2>/dev/null)"`,
+	`overcommit="$(cat /proc/sys/vm/overcommit_memory 2>/dev/null)"`,
+	`zone_reclaim="$(cat /proc/sys/vm/zone_reclaim_mode 2>/dev/null)"`,
+	`{`,
+	`  case "$thp" in *"[always]"*) echo "transparent_hugepage=always" ;; esac`,
+	`  if [ -n "$overcommit" ] && [ "$overcommit" != 1 ]; then echo "overcommit_memory=$overcommit"; fi`,
+	`  if [ -n "$zone_reclaim" ] && [ "$zone_reclaim" != 0 ]; then echo "zone_reclaim_mode=$zone_reclaim"; fi`,
+	`} | tee /dev/termination-log`,
+	`exit 0`,
+}, "\n")
+
+// preflightAdvice explains the findings of the preflight check
+var preflightAdvice = map[string]string{
+	"transparent_hugepage": "the transparent huge pages slow down the forks and bloat the copy-on-write memory, " +
+		"set /sys/kernel/mm/transparent_hugepage/enabled to madvise or never",
+	"overcommit_memory": "the background saves and the full resynchronizations may fail to fork under " +
+		"the memory pressure, set vm.overcommit_memory to 1",
+	"zone_reclaim_mode": "the allocations reclaim the local NUMA node memory instead of using the remote one, " +
+		"set vm.zone_reclaim_mode to 0",
+}
+
+// generatePreflightContainer returns the init container checking the node settings. It is run with the Redis image
+// and the resources of the redis container, so it neither needs another image nor changes the QoS class of the Pod.
+func generatePreflightContainer(r *k8sv1alpha1.Redis) corev1.Container {
+	return corev1.Container{
+		Name:                     preflightContainerName,
+		Image:                    r.Spec.Redis.ImageReference(),
+		Command:                  []string{"/bin/sh", "-c", preflightScript},
+		Resources:                r.Spec.Redis.Resources,
+		TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+		SecurityContext:          r.Spec.Redis.SecurityContext,
+	}
+}
+
+// preflightFindings returns the findings the preflight container of the Pod terminated with,
+// nil if it has not terminated yet
+func preflightFindings(pod *corev1.Pod) []string {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name != preflightContainerName {
+			continue
+		}
+		terminated := status.State.Terminated
+		if terminated == nil {
+			terminated = status.LastTerminationState.Terminated
+		}
+		if terminated == nil {
+			return nil
+		}
+		var findings []string
+		for _, line := range strings.Split(terminated.Message, "\n") {
+			if name := strings.SplitN(line, "=", 2)[0]; preflightAdvice[name] != "" {
+				findings = append(findings, strings.TrimSpace(line))
+			}
+		}
+		return findings
+	}
+	return nil
+}
+
+// hostSettingsCondition builds the HostSettingsDegraded condition out of the preflight findings of the Pods
+func hostSettingsCondition(pods []corev1.Pod) k8sv1alpha1.Condition {
+	var degraded []string
+	advice := make(map[string]bool)
+	for i := range pods {
+		findings := preflightFindings(&pods[i])
+		if len(findings) == 0 {
+			continue
+		}
+		degraded = append(degraded, fmt.Sprintf("%s on %s: %s", pods[i].Name, pods[i].Spec.NodeName, strings.Join(findings, ", ")))
+		for _, finding := range findings {
+			advice[preflightAdvice[strings.SplitN(finding, "=", 2)[0]]] = true
+		}
+	}
+	if len(degraded) == 0 {
+		return k8sv1alpha1.Condition{
+			Type:    k8sv1alpha1.ConditionHostSettingsDegraded,
+			Status:  corev1.ConditionFalse,
+			Reason:  k8sv1alpha1.ReasonHostSettingsOK,
+			Message: "no node settings degrading Redis found by the preflight check",
+		}
+	}
+
+	sort.Strings(degraded)
+	advices := make([]string, 0, len(advice))
+	for a := range advice {
+		advices = append(advices, a)
+	}
+	sort.Strings(advices)
+	return k8sv1alpha1.Condition{
+		Type:   k8sv1alpha1.ConditionHostSettingsDegraded,
+		Status: corev1.ConditionTrue,
+		Reason: k8sv1alpha1.ReasonHostSettingsDegraded,
+		Message: fmt.Sprintf("node settings degrading Redis found by the preflight check: %s. %s",
+			strings.Join(degraded, "; "), strings.Join(advices, "; ")),
+	}
+}
+
+// checkHostSettings sets the HostSettingsDegraded condition and emits a Warning Event once the node settings
+// of the Pods start degrading Redis. The condition is removed if the host preflight is disabled.
+func (reconciler *ReconcileRedis) checkHostSettings(r *k8sv1alpha1.Redis, status *k8sv1alpha1.RedisStatus, pods []corev1.Pod) {
+	if !r.Spec.HostPreflight {
+		status.RemoveCondition(k8sv1alpha1.ConditionHostSettingsDegraded)
+		return
+	}
+
+	condition := hostSettingsCondition(pods)
+	if previous := status.GetCondition(condition.Type); condition.Status == corev1.ConditionTrue &&
+		(previous == nil || previous.Status != corev1.ConditionTrue || previous.Message != condition.Message) {
+		reconciler.recorder.Event(r, corev1.EventTypeWarning, condition.Reason, condition.Message)
+	}
+	status.SetCondition(condition)
+}
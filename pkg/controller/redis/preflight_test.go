@@ -0,0 +1,70 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func preflightPod(name, message string) corev1.Pod {
+	pod := corev1.Pod{Spec: corev1.PodSpec{NodeName: "node-" + name}}
+	pod.Name = name
+	pod.Status.InitContainerStatuses = []corev1.ContainerStatus{{
+		Name:  preflightContainerName,
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}},
+	}}
+	return pod
+}
+
+func Test_preflightFindings(t *testing.T) {
+	pod := preflightPod("redis-test-0", "transparent_hugepage=always\noverride=1\novercommit_memory=0\n")
+	want := []string{"transparent_hugepage=always", "overcommit_memory=0"}
+	if got := preflightFindings(&pod); !reflect.DeepEqual(got, want) {
+		t.Errorf("preflightFindings() = %v, want %v", got, want)
+	}
+
+	running := corev1.Pod{Status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{{Name: preflightContainerName}}}}
+	if got := preflightFindings(&running); got != nil {
+		t.Errorf("preflightFindings() = %v, want nil", got)
+	}
+}
+
+func Test_hostSettingsCondition(t *testing.T) {
+	condition := hostSettingsCondition([]corev1.Pod{preflightPod("redis-test-0", ""), preflightPod("redis-test-1", "")})
+	if condition.Status != corev1.ConditionFalse {
+		t.Errorf("hostSettingsCondition() status = %v, want False", condition.Status)
+	}
+
+	condition = hostSettingsCondition([]corev1.Pod{
+		preflightPod("redis-test-1", "zone_reclaim_mode=1\n"),
+		preflightPod("redis-test-0", "transparent_hugepage=always\n"),
+	})
+	if condition.Status != corev1.ConditionTrue {
+		t.Fatalf("hostSettingsCondition() status = %v, want True", condition.Status)
+	}
+	for _, want := range []string{
+		"redis-test-0 on node-redis-test-0: transparent_hugepage=always; redis-test-1 on node-redis-test-1: zone_reclaim_mode=1",
+		"madvise or never",
+		"vm.zone_reclaim_mode to 0",
+	} {
+		if !strings.Contains(condition.Message, want) {
+			t.Errorf("hostSettingsCondition() message = %q, want it to contain %q", condition.Message, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// pubSubCondition builds the PubSubDegraded condition out of the replicas that have not received
+// the canary message or the error of the check
+func pubSubCondition(missed []redis.Address, podNames map[string]string, err error) k8sv1alpha1.Condition {
+	switch {
+	case err != nil:
+		return k8sv1alpha1.Condition{
+			Type:    k8sv1alpha1.ConditionPubSubDegraded,
+			Status:  corev1.ConditionUnknown,
+			Reason:  k8sv1alpha1.ReasonPubSubCheckFailed,
+			Message: fmt.Sprintf("failed to check the Pub/Sub propagation: %s", err),
+		}
+	case len(missed) > 0:
+		return k8sv1alpha1.Condition{
+			Type:   k8sv1alpha1.ConditionPubSubDegraded,
+			Status: corev1.ConditionTrue,
+			Reason: k8sv1alpha1.ReasonPubSubPropagationFailed,
+			Message: fmt.Sprintf("the subscribers of %s have not received the canary message published on the master "+
+				"to %s", podNamesOf(missed, podNames), redis.CanaryChannel),
+		}
+	}
+	return k8sv1alpha1.Condition{
+		Type:    k8sv1alpha1.ConditionPubSubDegraded,
+		Status:  corev1.ConditionFalse,
+		Reason:  k8sv1alpha1.ReasonPubSubOK,
+		Message: "the subscribers of all the replicas have received the canary message published on the master",
+	}
+}
+
+// checkPubSub sets the PubSubDegraded condition and emits a Warning Event when the propagation starts failing.
+// The condition is removed if the check is disabled.
+func (reconciler *ReconcileRedis) checkPubSub(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	status *k8sv1alpha1.RedisStatus,
+	replication redis.Replication,
+	podNames map[string]string,
+) {
+	if !r.Spec.PubSubCheck {
+		status.RemoveCondition(k8sv1alpha1.ConditionPubSubDegraded)
+		return
+	}
+
+	missed, err := replication.CheckPubSub(ctx)
+	condition := pubSubCondition(missed, podNames, err)
+	if previous := status.GetCondition(condition.Type); condition.Status == corev1.ConditionTrue &&
+		(previous == nil || previous.Status != corev1.ConditionTrue) {
+		reconciler.recorder.Event(r, corev1.EventTypeWarning, condition.Reason, condition.Message)
+	}
+	status.SetCondition(condition)
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_pubSubCondition(t *testing.T) {
+	podNames := map[string]string{"10.0.0.1": "redis-example-0", "10.0.0.2": "redis-example-1"}
+	tests := []struct {
+		name       string
+		missed     []redis.Address
+		err        error
+		wantStatus corev1.ConditionStatus
+		wantReason string
+		wantInMsg  string
+	}{
+		{"received", nil, nil, corev1.ConditionFalse, k8sv1alpha1.ReasonPubSubOK, "all the replicas"},
+		{"missed", []redis.Address{{Host: "10.0.0.2", Port: "6379"}}, nil,
+			corev1.ConditionTrue, k8sv1alpha1.ReasonPubSubPropagationFailed, "redis-example-1"},
+		{"failed", nil, errors.New("NOPERM"),
+			corev1.ConditionUnknown, k8sv1alpha1.ReasonPubSubCheckFailed, "NOPERM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pubSubCondition(tt.missed, podNames, tt.err)
+			if got.Type != k8sv1alpha1.ConditionPubSubDegraded || got.Status != tt.wantStatus || got.Reason != tt.wantReason {
+				t.Errorf("pubSubCondition() = %+v, want %s %s", got, tt.wantStatus, tt.wantReason)
+			}
+			if !strings.Contains(got.Message, tt.wantInMsg) {
+				t.Errorf("pubSubCondition() message = %q, want it to contain %q", got.Message, tt.wantInMsg)
+			}
+		})
+	}
+}
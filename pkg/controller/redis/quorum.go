@@ -0,0 +1,99 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	quorumLabels = []string{"namespace", "redis"}
+
+	quorumLost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_operator_quorum_lost",
+		Help: "Whether fewer instances of the Redis than the minimum replication size reply to PING",
+	}, quorumLabels)
+	healthyInstances = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_operator_healthy_instances",
+		Help: "Number of the instances of the Redis replying to PING",
+	}, quorumLabels)
+)
+
+func init() {
+	metrics.Registry.MustRegister(quorumLost, healthyInstances)
+}
+
+// quorumLostCondition returns the QuorumLost condition listing the result of pinging every instance by Pod
+func quorumLostCondition(e *redis.QuorumError, podNames map[string]string) k8sv1alpha1.Condition {
+	podName := func(address redis.Address) string {
+		if name, ok := podNames[address.Host]; ok {
+			return name
+		}
+		return address.String()
+	}
+
+	results := make([]string, 0, len(e.Healthy)+len(e.NotReady)+len(e.Unreachable))
+	for _, address := range e.Healthy {
+		results = append(results, fmt.Sprintf("%s: PONG", podName(address)))
+	}
+	for _, address := range e.NotReady {
+		results = append(results, fmt.Sprintf("%s: loading the dataset or busy", podName(address)))
+	}
+	for address, err := range e.Unreachable {
+		results = append(results, fmt.Sprintf("%s: %s", podName(address), err))
+	}
+	sort.Strings(results)
+
+	return newCondition(k8sv1alpha1.ConditionQuorumLost, corev1.ConditionTrue, k8sv1alpha1.ReasonQuorumNotMet,
+		fmt.Sprintf("%s: %s", e.Error(), strings.Join(results, "; ")))
+}
+
+// quorumMetCondition returns the QuorumLost condition of the replication with healthy instances replying to PING
+// and notReady instances loading the dataset or busy, the quorum of which is met
+func quorumMetCondition(healthy, notReady int) k8sv1alpha1.Condition {
+	message := fmt.Sprintf("%d instances reply to PING", healthy)
+	if notReady > 0 {
+		message = fmt.Sprintf("%s, %d are loading the dataset or busy", message, notReady)
+	}
+	return newCondition(k8sv1alpha1.ConditionQuorumLost, corev1.ConditionFalse, k8sv1alpha1.ReasonQuorumMet, message)
+}
+
+// recordQuorum sets the quorum metrics of the Redis by the number of the instances replying to PING
+func recordQuorum(key types.NamespacedName, healthy int, lost bool) {
+	healthyInstances.WithLabelValues(key.Namespace, key.Name).Set(float64(healthy))
+	value := 0.0
+	if lost {
+		value = 1
+	}
+	quorumLost.WithLabelValues(key.Namespace, key.Name).Set(value)
+}
+
+// forgetQuorum deletes the quorum metrics of the deleted Redis
+func forgetQuorum(key types.NamespacedName) {
+	quorumLost.DeleteLabelValues(key.Namespace, key.Name)
+	healthyInstances.DeleteLabelValues(key.Namespace, key.Name)
+}
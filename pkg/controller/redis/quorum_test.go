@@ -0,0 +1,92 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+)
+
+func Test_quorumLostCondition(t *testing.T) {
+	redis0, redis1, redis2 := redis.Address{Host: "10.0.0.1", Port: "6379"},
+		redis.Address{Host: "10.0.0.2", Port: "6379"}, redis.Address{Host: "10.0.0.3", Port: "6379"}
+	podNames := map[string]string{"10.0.0.1": "redis-test-0", "10.0.0.2": "redis-test-1"}
+
+	tests := []struct {
+		name        string
+		err         *redis.QuorumError
+		wantMessage string
+	}{
+		{
+			name: "unreachable",
+			err: &redis.QuorumError{
+				Healthy:     []redis.Address{redis0},
+				Unreachable: map[redis.Address]error{redis1: errors.New("i/o timeout"), redis2: errors.New("connection refused")},
+			},
+			wantMessage: "minimum replication size is not met, only 1 are healthy: " +
+				"10.0.0.3:6379: connection refused; redis-test-0: PONG; redis-test-1: i/o timeout",
+		},
+		{
+			name: "none healthy",
+			err: &redis.QuorumError{
+				NotReady:    []redis.Address{redis0},
+				Unreachable: map[redis.Address]error{redis1: errors.New("i/o timeout")},
+			},
+			wantMessage: "minimum replication size is not met, only 0 are healthy: " +
+				"redis-test-0: loading the dataset or busy; redis-test-1: i/o timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := quorumLostCondition(tt.err, podNames)
+			if got.Type != k8sv1alpha1.ConditionQuorumLost || got.Status != corev1.ConditionTrue ||
+				got.Reason != k8sv1alpha1.ReasonQuorumNotMet {
+				t.Errorf("quorumLostCondition() = %+v, want QuorumLost True %s", got, k8sv1alpha1.ReasonQuorumNotMet)
+			}
+			if got.Message != tt.wantMessage {
+				t.Errorf("quorumLostCondition().Message = %q, want %q", got.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_quorumMetCondition(t *testing.T) {
+	tests := []struct {
+		name        string
+		healthy     int
+		notReady    int
+		wantMessage string
+	}{
+		{"healthy", 3, 0, "3 instances reply to PING"},
+		{"loading", 1, 2, "1 instances reply to PING, 2 are loading the dataset or busy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := quorumMetCondition(tt.healthy, tt.notReady)
+			if got.Type != k8sv1alpha1.ConditionQuorumLost || got.Status != corev1.ConditionFalse ||
+				got.Reason != k8sv1alpha1.ReasonQuorumMet {
+				t.Errorf("quorumMetCondition() = %+v, want QuorumLost False %s", got, k8sv1alpha1.ReasonQuorumMet)
+			}
+			if got.Message != tt.wantMessage {
+				t.Errorf("quorumMetCondition().Message = %q, want %q", got.Message, tt.wantMessage)
+			}
+		})
+	}
+}
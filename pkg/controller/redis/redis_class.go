@@ -0,0 +1,111 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// classInvalidError means that the locked fields of the RedisClass are invalid
+type classInvalidError struct {
+	class string
+	err   error
+}
+
+func (e *classInvalidError) Error() string {
+	return fmt.Sprintf("RedisClass %s: %s", e.class, e.err)
+}
+
+// applyClass expands the RedisClass referenced by the Redis into it and returns the locked fields the spec
+// sets to other values. The RedisClasses are cluster-scoped while the cache may be restricted
+// to the watched namespaces, hence the class is read by the API reader.
+func applyClass(ctx context.Context, reader client.Reader, r *k8sv1alpha1.Redis) ([]string, error) {
+	if r.Spec.ClassName == "" {
+		return nil, nil
+	}
+	class := new(k8sv1alpha1.RedisClass)
+	if err := reader.Get(ctx, types.NamespacedName{Name: r.Spec.ClassName}, class); err != nil {
+		return nil, err
+	}
+	if err := class.Spec.ValidateLockedFields(); err != nil {
+		return nil, &classInvalidError{class: r.Spec.ClassName, err: err}
+	}
+	return r.ApplyClass(&class.Spec), nil
+}
+
+// classCondition builds the ClassConflict condition out of the locked fields the spec sets to other values
+func classCondition(class string, conflicts []string) k8sv1alpha1.Condition {
+	if len(conflicts) > 0 {
+		return newCondition(k8sv1alpha1.ConditionClassConflict, corev1.ConditionTrue,
+			k8sv1alpha1.ReasonLockedFieldsOverridden, fmt.Sprintf("%s locked by RedisClass %s overridden in the spec, "+
+				"the values of the class are used", strings.Join(conflicts, ", "), class))
+	}
+	return newCondition(k8sv1alpha1.ConditionClassConflict, corev1.ConditionFalse, k8sv1alpha1.ReasonClassApplied,
+		fmt.Sprintf("spec complies with the fields locked by RedisClass %s", class))
+}
+
+// checkClass sets the ClassConflict condition and emits a Warning Event once the spec overrides the locked fields.
+// The condition is removed if the Redis refers to no class.
+func (reconciler *ReconcileRedis) checkClass(r *k8sv1alpha1.Redis, status *k8sv1alpha1.RedisStatus, conflicts []string) {
+	if r.Spec.ClassName == "" {
+		status.RemoveCondition(k8sv1alpha1.ConditionClassConflict)
+		return
+	}
+
+	condition := classCondition(r.Spec.ClassName, conflicts)
+	if previous := status.GetCondition(condition.Type); condition.Status == corev1.ConditionTrue &&
+		(previous == nil || previous.Status != corev1.ConditionTrue || previous.Message != condition.Message) {
+		reconciler.recorder.Event(r, corev1.EventTypeWarning, condition.Reason, condition.Message)
+	}
+	status.SetCondition(condition)
+}
+
+// classReferenced reports whether the Redis refers to the RedisClass
+func classReferenced(r *k8sv1alpha1.Redis, class string) bool {
+	return r.Spec.ClassName != "" && r.Spec.ClassName == class
+}
+
+// classToRequests maps the RedisClass to the Redis resources referring to it, so the changes of the class
+// are rolled out to all of them
+func classToRequests(c client.Client) handler.ToRequestsFunc {
+	return func(object handler.MapObject) []reconcile.Request {
+		redisList := new(k8sv1alpha1.RedisList)
+		if err := c.List(context.TODO(), redisList); err != nil {
+			log.Error(err, "failed to list Redis", "RedisClass", object.Meta.GetName())
+			return nil
+		}
+		var requests []reconcile.Request
+		for i := range redisList.Items {
+			if classReferenced(&redisList.Items[i], object.Meta.GetName()) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Namespace: redisList.Items[i].GetNamespace(),
+					Name:      redisList.Items[i].GetName(),
+				}})
+			}
+		}
+		return requests
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// classReader serves the RedisClasses by name
+type classReader map[string]k8sv1alpha1.RedisClassSpec
+
+func (c classReader) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	spec, ok := c[key.Name]
+	if !ok || key.Namespace != "" {
+		return errors.NewNotFound(schema.GroupResource{Group: "k8s.amaiz.com", Resource: "redisclasses"}, key.Name)
+	}
+	obj.(*k8sv1alpha1.RedisClass).Spec = *spec.DeepCopy()
+	return nil
+}
+
+func (c classReader) List(context.Context, runtime.Object, ...client.ListOption) error {
+	return nil
+}
+
+func Test_applyClass(t *testing.T) {
+	reader := classReader{
+		"default": {Redis: k8sv1alpha1.ContainerSpec{Image: "redis:6.0.9"}},
+		"locked":  {Redis: k8sv1alpha1.ContainerSpec{Image: "redis:6.0.9"}, LockedFields: []string{"redis.image"}},
+		"invalid": {LockedFields: []string{"replicas"}},
+	}
+	tests := []struct {
+		name          string
+		className     string
+		image         string
+		wantImage     string
+		wantConflicts []string
+		wantNotFound  bool
+		wantInvalid   bool
+	}{
+		{"no class", "", "redis:6.2.1", "redis:6.2.1", nil, false, false},
+		{"class", "default", "", "redis:6.0.9", nil, false, false},
+		{"overridden", "default", "redis:6.2.1", "redis:6.2.1", nil, false, false},
+		{"locked", "locked", "redis:6.2.1", "redis:6.0.9", []string{"redis.image"}, false, false},
+		{"missing class", "missing", "", "", nil, true, false},
+		{"invalid locked fields", "invalid", "", "", nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{
+				ClassName: tt.className,
+				Redis:     k8sv1alpha1.ContainerSpec{Image: tt.image},
+			}}
+			conflicts, err := applyClass(context.TODO(), reader, r)
+			if errors.IsNotFound(err) != tt.wantNotFound {
+				t.Fatalf("applyClass() error = %v, wantNotFound %v", err, tt.wantNotFound)
+			}
+			if _, invalid := err.(*classInvalidError); invalid != tt.wantInvalid {
+				t.Fatalf("applyClass() error = %v, wantInvalid %v", err, tt.wantInvalid)
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("applyClass() conflicts = %v, want %v", conflicts, tt.wantConflicts)
+			}
+			if r.Spec.Redis.Image != tt.wantImage {
+				t.Errorf("applyClass() image = %s, want %s", r.Spec.Redis.Image, tt.wantImage)
+			}
+		})
+	}
+}
+
+func Test_classReferenced(t *testing.T) {
+	tests := []struct {
+		name      string
+		className string
+		class     string
+		want      bool
+	}{
+		{"referenced", "default", "default", true},
+		{"other class", "default", "large", false},
+		{"no class", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{ClassName: tt.className}}
+			if got := classReferenced(r, tt.class); got != tt.want {
+				t.Errorf("classReferenced() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_classCondition(t *testing.T) {
+	tests := []struct {
+		name       string
+		conflicts  []string
+		wantStatus corev1.ConditionStatus
+		wantReason string
+	}{
+		{"no conflicts", nil, corev1.ConditionFalse, k8sv1alpha1.ReasonClassApplied},
+		{"conflicts", []string{"config.maxmemory", "redis.image"}, corev1.ConditionTrue,
+			k8sv1alpha1.ReasonLockedFieldsOverridden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classCondition("default", tt.conflicts)
+			if got.Type != k8sv1alpha1.ConditionClassConflict || got.Status != tt.wantStatus || got.Reason != tt.wantReason {
+				t.Errorf("classCondition() = %+v, want status %s reason %s", got, tt.wantStatus, tt.wantReason)
+			}
+			for _, field := range tt.conflicts {
+				if !strings.Contains(got.Message, field) {
+					t.Errorf("classCondition() message %q does not mention %s", got.Message, field)
+				}
+			}
+		})
+	}
+}
@@ -16,58 +16,144 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/cosign"
+	"github.com/amaizfinance/redis-operator/pkg/features"
 	"github.com/amaizfinance/redis-operator/pkg/redis"
 
-	"github.com/cenkalti/backoff/v3"
-
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+const (
+	// tlsSecretRequeueDelay is the delay before the next check of the TLS Secret issued by cert-manager
+	tlsSecretRequeueDelay = 5 * time.Second
+	// notReadyRequeueDelay is the delay before the instances loading the dataset or busy are checked again
+	notReadyRequeueDelay = 10 * time.Second
+)
+
 var (
 	log = logf.Log.WithName("controller_redis")
+	// notReadyBackoff is the policy the instances loading the dataset or busy are waited for with
+	notReadyBackoff = redis.Backoff{Steps: 4, Duration: 500 * time.Millisecond, Factor: 2}
 	// used to check if the password is a simple alphanumeric string
 	isAlphaNumeric = regexp.MustCompile(`^[[:alnum:]]+$`).MatchString
 )
 
-// Add creates a new Redis Controller and adds it to the Manager. The Manager will set fields on the Controller
-// and Start it when the Manager is Started.
+// Add creates a new Redis Controller configured by the flags and adds it to the Manager. The Manager will set fields
+// on the Controller and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+	r, err := NewRedisReconciler(mgr, flagOptions)
+	if err != nil {
+		return err
+	}
+	return r.SetupWithManager(mgr)
 }
 
-// newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileRedis{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+// NewRedisReconciler returns the Redis reconciler using the clients of the Manager.
+// It is added to the Manager with SetupWithManager.
+func NewRedisReconciler(mgr manager.Manager, options Options) (*ReconcileRedis, error) {
+	options, err := options.validate()
+	if err != nil {
+		return nil, err
+	}
+	kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+	var notifier *eventNotifier
+	if options.NotificationSecret != "" {
+		if notifier, err = newEventNotifier(kubeClient, options.NotificationSecret, options.OperatorNamespace); err != nil {
+			return nil, err
+		}
+	}
+	// the cache of the manager is namespaced once the namespaces to watch are set
+	clusterCache, err := cache.New(mgr.GetConfig(), cache.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return nil, err
+	}
+	if err := mgr.Add(clusterCache); err != nil {
+		return nil, err
+	}
+	decisions := newDecisionLog()
+	discovery := newAPIDiscovery(kubeClient.Discovery())
+	return &ReconcileRedis{
+		client:       mgr.GetClient(),
+		apiReader:    mgr.GetAPIReader(),
+		clusterCache: clusterCache,
+		kubeClient:   kubeClient,
+		scheme:       mgr.GetScheme(),
+		recorder: decisionRecorder{
+			EventRecorder: mgr.GetEventRecorderFor(eventRecorderName),
+			decisions:     decisions,
+			notifier:      notifier,
+		},
+		decisions:        decisions,
+		connections:      newConnectionPool(),
+		replicationLocks: newReplicationLocks(),
+		discovery:        discovery,
+		batchV1CronJob:   discovery.serves(cronJobGVK),
+		aofFsync:         newAOFFsyncTracker(),
+		keyspace:         newKeyspaceTracker(),
+		replicationLag:   newReplicationLagTracker(),
+		runtimeConfig:    newRuntimeConfigTracker(),
+		fullSync:         newFullSyncTracker(),
+		imageVerifier:    new(cosign.Verifier),
+		options:          options,
+	}, nil
+}
+
+// SetupWithManager adds a new Redis Controller reconciled by the reconciler to the Manager
+func (reconciler *ReconcileRedis) SetupWithManager(mgr manager.Manager) error {
+	if err := metrics.Registry.Register(newFleetCollector(mgr.GetClient())); err != nil {
+		return err
+	}
+	return add(mgr, reconciler, reconciler.options)
 }
 
-// add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+// add adds a new Controller to mgr with r as the reconcile.Reconciler run by up to MaxConcurrentReconciles workers
+// of the options, the requests are requeued with the rate limiter of the options
+func add(mgr manager.Manager, r *ReconcileRedis, options Options) error {
 	// Create a new controller
-	c, err := controller.New("redis-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("redis-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: options.MaxConcurrentReconciles,
+		RateLimiter:             options.rateLimiter(),
+	})
 	if err != nil {
 		return err
 	}
@@ -86,6 +172,14 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		new(corev1.ConfigMap),
 		new(policyv1beta1.PodDisruptionBudget),
 		new(appsv1.StatefulSet),
+		// node-local cache
+		new(appsv1.DaemonSet),
+		r.newBackupCronJob(),
+		new(networkingv1.NetworkPolicy),
+		// pre-delete hook
+		new(batchv1.Job),
+		// temporary Redis restored during the restore drill
+		new(k8sv1alpha1.Redis),
 	} {
 		if err := c.Watch(
 			&source.Kind{Type: object},
@@ -95,33 +189,148 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		}
 	}
 
+	// Watch for changes to Secrets issued by cert-manager to restart Pods once certificates are renewed
+	if err := c.Watch(
+		&source.Kind{Type: new(corev1.Secret)},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(certificateSecretToRequests)},
+	); err != nil {
+		return err
+	}
+
+	// Watch for changes to the password Secrets to rotate the password once it is changed
+	if err := c.Watch(
+		&source.Kind{Type: new(corev1.Secret)},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: passwordSecretToRequests(mgr.GetClient())},
+	); err != nil {
+		return err
+	}
+
+	// Watch for changes to Jobs created by the backup CronJob to report the scheduled backups in the status
+	if err := c.Watch(
+		&source.Kind{Type: new(batchv1.Job)},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(scheduledBackupJobToRequests)},
+	); err != nil {
+		return err
+	}
+
+	// Watch for changes to the RedisClasses to roll them out to the Redis resources of the class
+	if err := c.Watch(
+		&source.Kind{Type: new(k8sv1alpha1.RedisClass)},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: classToRequests(mgr.GetClient())},
+	); err != nil {
+		return err
+	}
+
+	// Watch for changes to the Redis of blue/green deployments to follow the master and the cutover of the peer
+	if err := c.Watch(
+		&source.Kind{Type: new(k8sv1alpha1.Redis)},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: blueGreenToRequests(mgr.GetClient())},
+	); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// certificateSecretToRequests maps Secrets issued for Certificates generated by the operator to the owning Redis
+func certificateSecretToRequests(object handler.MapObject) []reconcile.Request {
+	certificateName, ok := object.Meta.GetAnnotations()[certificateNameAnnotation]
+	if !ok || !strings.HasPrefix(certificateName, fmt.Sprintf(namePrefixTemplate, "")) {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Namespace: object.Meta.GetNamespace(),
+		Name:      strings.TrimPrefix(certificateName, fmt.Sprintf(namePrefixTemplate, "")),
+	}}}
+}
+
 // ReconcileRedis reconciles a Redis object
 type ReconcileRedis struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
-	scheme *runtime.Scheme
+	// apiReader reads the objects from the apiserver, e.g. the cluster-scoped ones out of the watched namespaces
+	apiReader client.Reader
+	// clusterCache reads the cluster-scoped objects watched regardless of the watched namespaces, e.g. the Nodes
+	clusterCache client.Reader
+	// kubeClient is used for the requests not supported by client, e.g. kubelet stats
+	kubeClient kubernetes.Interface
+	scheme     *runtime.Scheme
+	// recorder emits the Events on the Redis, so they are shown by kubectl describe
+	recorder record.EventRecorder
+	// discovery tells whether the optional APIs, e.g. the Prometheus Operator, are served
+	discovery *apiDiscovery
+	// batchV1CronJob tells whether the backup CronJob is managed as batch/v1, see newBackupCronJob
+	batchV1CronJob bool
+	// imageVerifier verifies the image signatures and caches the verified images
+	imageVerifier *cosign.Verifier
+	// aofFsync throttles the checks of the delayed AOF fsyncs
+	aofFsync *aofFsyncTracker
+	// keyspace throttles the samples of the expired and evicted keys
+	keyspace *keyspaceTracker
+	// replicationLag tracks the Pods the replication lag metrics are exported for
+	replicationLag *replicationLagTracker
+	// runtimeConfig tracks the runtime configuration of the masters replayed on their successors
+	runtimeConfig *runtimeConfigTracker
+	// fullSync times the full synchronizations of the replicas with the master
+	fullSync *fullSyncTracker
+	// decisions keeps the recent Events and updates of the owned objects for the diagnostics bundle
+	decisions *decisionLog
+	// connections keeps the clients of the instances across the reconciliations
+	connections *connectionPool
+	// replicationLocks serialize the replication operations of the blue/green peers
+	replicationLocks *replicationLocks
+	// options are validated by NewRedisReconciler
+	options Options
 }
 
 // strict implementation check
 var _ reconcile.Reconciler = (*ReconcileRedis)(nil)
 
+// featureEnabled tells whether the feature is enabled for the Redis
+func (reconciler *ReconcileRedis) featureEnabled(r *k8sv1alpha1.Redis, feature features.Feature) bool {
+	return reconciler.options.FeatureGates.EnabledFor(feature, r.GetAnnotations())
+}
+
 // Reconcile reads that state of the cluster for a Redis object and makes changes based on the state read
 // and what is in the Redis.Spec
 // Note:
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
+// The reconciliation running out of the API request budget is postponed and resumed from the start.
+// The reconciliation running out of time is reported with the ReconcileTimedOut condition and requeued with backoff.
 func (reconciler *ReconcileRedis) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	budget := newBudgetedClient(reconciler.client, "redis", reconciler.options.ReconcileAPIBudget)
+	budgeted := *reconciler
+	budgeted.client = budget
+
+	ctx, cancel := reconcileContext(reconciler.options.ReconcileTimeout)
+	defer cancel()
+
+	result, err := budgeted.reconcile(ctx, request)
+	// the diagnostics are collected out of the budget whatever the outcome of the reconciliation
+	if err := reconciler.collectDiagnostics(ctx, request, err); err != nil {
+		log.Info("Error collecting diagnostics", "Namespace", request.Namespace, "Redis", request.Name, "error", err)
+	}
+	if budget.done() {
+		log.Info("API request budget exceeded, postponing reconciliation",
+			"Namespace", request.Namespace, "Redis", request.Name)
+		return reconcile.Result{RequeueAfter: apiBudgetRequeueDelay}, nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Info("Reconciliation timed out, requeue", "Namespace", request.Namespace, "Redis", request.Name,
+			"timeout", reconciler.options.ReconcileTimeout, "error", err)
+		budgeted.reportTimeout(request, err)
+		return reconcile.Result{Requeue: true}, nil
+	}
+	return result, err
+}
+
+func (reconciler *ReconcileRedis) reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	logger := log.WithValues("Namespace", request.Namespace, "Redis", request.Name)
 	loggerDebug := logger.V(1).Info
 	loggerDebug("Reconciling Redis")
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	// Fetch the Redis instance
 	fetchedRedis := new(k8sv1alpha1.Redis)
 	if err := reconciler.client.Get(ctx, request.NamespacedName, fetchedRedis); err != nil {
@@ -129,33 +338,118 @@ func (reconciler *ReconcileRedis) Reconcile(request reconcile.Request) (reconcil
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			reconciler.aofFsync.forget(request.NamespacedName)
+			reconciler.keyspace.forget(request.NamespacedName)
+			reconciler.replicationLag.forget(request.NamespacedName)
+			reconciler.runtimeConfig.forget(request.NamespacedName)
+			reconciler.fullSync.forget(request.NamespacedName)
+			forgetQuorum(request.NamespacedName)
+			reconciler.decisions.forget(request.NamespacedName)
+			reconciler.connections.forget(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
+	if fetchedRedis.GetDeletionTimestamp() != nil {
+		return reconciler.runPreDeleteHook(ctx, fetchedRedis)
+	}
+	if updated, err := reconciler.reconcileFinalizer(ctx, fetchedRedis); err != nil || updated {
+		// the update of the Redis is watched
+		return reconcile.Result{}, err
+	}
+
+	if fetchedRedis.Paused() {
+		loggerDebug("Reconciliation is paused")
+		return reconciler.reconcilePaused(ctx, fetchedRedis)
+	}
+
+	// quorumMet is the QuorumLost condition set along with the failures once the quorum is known to be met
+	var quorumMet []k8sv1alpha1.Condition
+	// failed reports the reason of the failed reconciliation in the status conditions, Events, logs and metrics
+	failed := func(
+		conditionType k8sv1alpha1.ConditionType,
+		status corev1.ConditionStatus,
+		reason string,
+		err error,
+	) {
+		reconciler.reportFailure(ctx, fetchedRedis, newCondition(conditionType, status, reason, err.Error()), quorumMet...)
+	}
+	configInvalid := func(reason string, err error) (reconcile.Result, error) {
+		failed(k8sv1alpha1.ConditionConfigInvalid, corev1.ConditionTrue, reason, err)
+		return reconcile.Result{}, err
+	}
+
 	// work with the copy
 	redisObject := fetchedRedis.DeepCopy()
+	// the class is expanded into the copy ahead of the profile, so the profile of the class is expanded as well
+	classConflicts, err := applyClass(ctx, reconciler.apiReader, redisObject)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return configInvalid(k8sv1alpha1.ReasonClassMissing, fmt.Errorf("RedisClass %s not found", redisObject.Spec.ClassName))
+		}
+		if _, ok := err.(*classInvalidError); ok {
+			return configInvalid(k8sv1alpha1.ReasonConfigInvalid, err)
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to fetch RedisClass %s: %s", redisObject.Spec.ClassName, err)
+	}
+	// the fields set by the class are not available to the webhook
+	if redisObject.Spec.ClassName != "" {
+		if err := redisObject.ValidateCreate(); err != nil {
+			return configInvalid(k8sv1alpha1.ReasonConfigInvalid, fmt.Errorf("%s with RedisClass %s", err, redisObject.Spec.ClassName))
+		}
+	}
+	// the profile presets are expanded into the copy, the spec keeps the profile only
+	redisObject.ApplyProfile()
+	// the Redis resources created before the policy or without the webhooks are mirrored as well
+	if redisObject.GuaranteedQoS() {
+		k8sv1alpha1.MirrorResources(&redisObject.Spec.Redis.Resources)
+		if redisObject.Spec.Exporter.Image != "" {
+			k8sv1alpha1.MirrorResources(&redisObject.Spec.Exporter.Resources)
+		}
+		if redisObject.Spec.Metrics != nil {
+			k8sv1alpha1.MirrorResources(&redisObject.Spec.Metrics.Container.Resources)
+		}
+		if redisObject.Spec.NodeLocalCache != nil {
+			k8sv1alpha1.MirrorResources(&redisObject.Spec.NodeLocalCache.Resources)
+		}
+	}
 	// initialize options
-	options := objectGeneratorOptions{serviceType: serviceTypeAll}
+	options := objectGeneratorOptions{
+		serviceType:   serviceTypeAll,
+		operatorPeer:  reconciler.options.operatorPeer(),
+		clusterDomain: reconciler.options.ClusterDomain,
+	}
 	// adding some default labels on top of user-defined
 	if redisObject.Labels == nil {
 		redisObject.Labels = make(map[string]string)
 	}
 	redisObject.Labels[redisName] = redisObject.GetName()
 
+	// the invalid directives are not rendered into the configuration, e.g. if the webhook is not deployed
+	if err := redisObject.ValidateConfig(); err != nil {
+		return configInvalid(k8sv1alpha1.ReasonConfigInvalid, err)
+	}
+
 	// read password from Secret
 	if redisObject.Spec.Password.SecretKeyRef != nil {
-		passwordSecret := new(corev1.Secret)
-		if err := reconciler.client.Get(ctx, types.NamespacedName{
-			Namespace: request.Namespace,
-			Name:      redisObject.Spec.Password.SecretKeyRef.Name,
-		}, passwordSecret); err != nil {
-			return reconcile.Result{}, fmt.Errorf("failed to fetch password: %s", err)
+		password, err := reconciler.readSecretKey(ctx, request.Namespace, redisObject.Spec.Password.SecretKeyRef)
+		if err != nil {
+			// the Secret is synced by the CSI driver once the SecretProviderClass is mounted by any Pod
+			if redisObject.Spec.Password.SecretProviderClass != "" {
+				err = fmt.Errorf("%s, synced from SecretProviderClass %s", err, redisObject.Spec.Password.SecretProviderClass)
+			}
+			return configInvalid(k8sv1alpha1.ReasonSecretMissing, fmt.Errorf("failed to fetch password from Secret %s: %s",
+				redisObject.Spec.Password.SecretKeyRef.Name, err))
+		}
+		// the Secret is not available to the webhook, the password is checked once read
+		if err := k8sv1alpha1.ValidatePassword(password); err != nil {
+			return configInvalid(k8sv1alpha1.ReasonConfigInvalid, fmt.Errorf("%s in Secret %s",
+				err, redisObject.Spec.Password.SecretKeyRef.Name))
 		}
 
-		options.password = string(passwordSecret.Data[redisObject.Spec.Password.SecretKeyRef.Key])
+		options.password = password
 		// Warning: since Redis is pretty fast an outside user can try up to
 		// 150k passwords per second against a good box. This means that you should
 		// use a very strong password otherwise it will be very easy to break.
@@ -164,29 +458,250 @@ func (reconciler *ReconcileRedis) Reconcile(request reconcile.Request) (reconcil
 		}
 	}
 
+	// the previous password is accepted along with the password until it is removed
+	if selector := redisObject.Spec.Password.PreviousSecretKeyRef; selector != nil && options.password != "" {
+		password, err := reconciler.readSecretKey(ctx, request.Namespace, selector)
+		if err != nil {
+			return configInvalid(k8sv1alpha1.ReasonSecretMissing, fmt.Errorf("failed to fetch previous password from Secret %s: %s",
+				selector.Name, err))
+		}
+		if err := k8sv1alpha1.ValidatePassword(password); err != nil {
+			return configInvalid(k8sv1alpha1.ReasonConfigInvalid, fmt.Errorf("%s in Secret %s of the previous password", err, selector.Name))
+		}
+		if password != options.password {
+			options.previousPassword = password
+		}
+	}
+
+	// read ACL users passwords from Secrets
+	if redisObject.Spec.ACL != nil {
+		for _, aclUser := range redisObject.Spec.ACL.Users {
+			user := redis.User{Name: aclUser.Name, Rules: aclUser.Rules}
+			for i := range aclUser.PasswordSecretKeyRefs {
+				password, err := reconciler.readSecretKey(ctx, request.Namespace, &aclUser.PasswordSecretKeyRefs[i])
+				if err != nil {
+					return configInvalid(k8sv1alpha1.ReasonSecretMissing,
+						fmt.Errorf("failed to fetch password of ACL user %s from Secret %s: %s",
+							aclUser.Name, aclUser.PasswordSecretKeyRefs[i].Name, err))
+				}
+				user.Passwords = append(user.Passwords, password)
+			}
+			if err := user.Validate(); err != nil {
+				return configInvalid(k8sv1alpha1.ReasonConfigInvalid, err)
+			}
+			options.aclUsers = append(options.aclUsers, user)
+		}
+	}
+	appliedACLUsers, err := reconciler.appliedACLUsers(ctx, fetchedRedis)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	options.removedACLUsers = removedACLUsers(appliedACLUsers, options.aclUsers)
+
+	// the operator user replaces the default user once it is disabled,
+	// it is created beforehand to authenticate the rolled out Pods and the operator
+	if defaultUserDisabled(redisObject) {
+		if options.password == "" {
+			return configInvalid(k8sv1alpha1.ReasonConfigInvalid, fmt.Errorf("the password is required to disable the default user"))
+		}
+		operatorUser := redis.NewOperatorUser(options.password)
+		if options.previousPassword != "" {
+			operatorUser.Passwords = append(operatorUser.Passwords, options.previousPassword)
+		}
+		options.aclUsers = append(options.aclUsers, operatorUser)
+		// the configuration follows the instances, the default user is disabled live first
+		options.defaultUserDisabled = fetchedRedis.Status.DefaultUserDisabled
+	}
+
+	// read TLS certificates from Secret
+	var tlsConfig *tls.Config
+	if redisObject.Spec.TLS != nil {
+		// request the certificate from cert-manager
+		if redisObject.Spec.TLS.IssuerRef != nil {
+			certificate := new(unstructured.Unstructured)
+			certificate.SetGroupVersionKind(certificateGVK)
+			if _, err := reconciler.createOrUpdate(ctx, certificate, redisObject, options); err != nil {
+				return reconcile.Result{}, fmt.Errorf("failed to apply Certificate: %s", err)
+			}
+		}
+
+		tlsSecret := new(corev1.Secret)
+		if err := reconciler.client.Get(ctx, types.NamespacedName{
+			Namespace: request.Namespace,
+			Name:      redisObject.Spec.TLS.SecretName,
+		}, tlsSecret); err != nil {
+			if errors.IsNotFound(err) && redisObject.Spec.TLS.IssuerRef != nil {
+				logger.Info("Waiting for the certificate to be issued", "Secret", redisObject.Spec.TLS.SecretName)
+				return reconcile.Result{RequeueAfter: tlsSecretRequeueDelay}, nil
+			}
+			return configInvalid(k8sv1alpha1.ReasonSecretMissing, fmt.Errorf("failed to fetch TLS certificate from Secret %s: %s",
+				redisObject.Spec.TLS.SecretName, err))
+		}
+		options.tlsCertificate = tlsSecret.Data[corev1.TLSCertKey]
+
+		var err error
+		if tlsConfig, err = redis.NewTLSConfig(
+			tlsSecret.Data[corev1.TLSCertKey],
+			tlsSecret.Data[corev1.TLSPrivateKeyKey],
+			tlsSecret.Data[tlsCAKey],
+		); err != nil {
+			return configInvalid(k8sv1alpha1.ReasonConfigInvalid,
+				fmt.Errorf("invalid TLS certificate in Secret %s: %s", tlsSecret.Name, err))
+		}
+	}
+
+	// resolve the file to restore the data from
+	var restoreErr error
+	if redisObject.Spec.Restore != nil {
+		source, err := reconciler.fetchRestoreSource(ctx, redisObject)
+		switch {
+		case err == nil:
+			options.restore = source
+		case fetchedRedis.Status.Master != "":
+			// the data has been restored already, the restarted instances will be resynchronized with the master.
+			// The restore containers are kept as is, so the Pods are not rolled, and the source is reported by the condition.
+			logger.Info("Restore source is not available, keeping the restore containers", "error", err)
+			restoreErr = fmt.Errorf("failed to resolve the restore source: %s", err)
+			if options.keptRestore, err = reconciler.fetchKeptRestore(ctx, redisObject); err != nil {
+				return reconcile.Result{}, err
+			}
+		default:
+			return configInvalid(k8sv1alpha1.ReasonRestoreSourceUnavailable, fmt.Errorf("failed to resolve the restore source: %s", err))
+		}
+	}
+
+	// the Pods are updated to the newest image matching the update policy, the spec image is left intact
+	var imageUpdate *k8sv1alpha1.ImageUpdateStatus
+	var imageUpdateRequeue time.Duration
+	if redisObject.Spec.ImageUpdatePolicy != nil {
+		imageUpdate, imageUpdateRequeue = reconciler.checkImageUpdate(ctx, redisObject, fetchedRedis.Status.ImageUpdate)
+		if imageUpdate.AvailableImage != "" {
+			redisObject.Spec.Redis.Image, redisObject.Spec.Redis.ImageDigest = imageUpdate.AvailableImage, ""
+		}
+	}
+
+	// the scale-down removing the master is held until the master role is handed over to a kept instance
+	desiredReplicas := *redisObject.Spec.Replicas
+	replicas, err := reconciler.scaleDownReplicas(ctx, redisObject, fetchedRedis.Status.Master)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	redisObject.Spec.Replicas = &replicas
+
+	// the changed password is applied to the running instances ahead of the authentication Secret
+	if err := reconciler.rotatePassword(ctx, redisObject, options, tlsConfig); err != nil {
+		err = fmt.Errorf("error rotating password: %s", err)
+		failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonPasswordRotationFailed, err)
+		return reconcile.Result{}, err
+	}
+
+	// the changed configuration is applied to the running instances ahead of the ConfigMap,
+	// the Pods are restarted only for the changes that can not be applied
+	if options.configRevision, err = reconciler.applyConfig(ctx, redisObject, options, tlsConfig); err != nil {
+		err = fmt.Errorf("error applying config: %s", err)
+		failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonConfigApplyFailed, err)
+		return reconcile.Result{}, err
+	}
+
+	// the Redis of a blue/green deployment replicate from each other,
+	// the master Service of the demoted blue Redis points at the green master
+	peer, err := reconciler.blueGreenPeer(ctx, redisObject)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if peer != nil {
+		options.blueGreenPeer = peer.GetName()
+		options.masterRedirect = demotedFor(redisObject, peer)
+	}
+
+	// imagesVerified is the outcome of the image verification, nil unless it is enabled.
+	// verificationErr fails the reconciliation once it is otherwise done, so the verification is retried.
+	var imagesVerified *k8sv1alpha1.Condition
+	var verificationErr error
+
+	// the restarted instances announce the addresses last set, the Services keep them
+	options.announced = announcedAddresses(fetchedRedis.Status.ExternalAddresses)
+
 	// create or update resources
 	for i, object := range []runtime.Object{
-		new(corev1.Service), new(corev1.Service), new(corev1.Service), // 3 distinct services ;)
+		new(corev1.Service), new(corev1.Service), new(corev1.Service), new(corev1.Service), // 4 distinct services ;)
 		new(corev1.Secret),
 		new(corev1.ConfigMap),
 		new(policyv1beta1.PodDisruptionBudget),
 		new(appsv1.StatefulSet),
+		new(networkingv1.NetworkPolicy),
+		newServiceMonitor(),
 	} {
 		switch object.(type) {
-		case *corev1.ConfigMap, *policyv1beta1.PodDisruptionBudget, *appsv1.StatefulSet:
+		case *corev1.ConfigMap, *policyv1beta1.PodDisruptionBudget:
 		// nothing special to do here
+		case *appsv1.StatefulSet:
+			// the images downgrading the running instances or skipping a major version are not rolled out
+			if err := reconciler.guardVersionChange(ctx, redisObject, options, tlsConfig); err != nil {
+				if e, ok := err.(*versionChangeError); ok {
+					return configInvalid(e.reason, err)
+				}
+				return reconcile.Result{}, err
+			}
+			// the data volumes are expanded ahead of the update, the volume claim templates are immutable
+			if result, err := reconciler.expandDataVolumes(ctx, redisObject); err != nil {
+				if _, ok := err.(*volumeExpansionError); ok {
+					return configInvalid(k8sv1alpha1.ReasonVolumeExpansionNotAllowed, err)
+				}
+				return reconcile.Result{}, err
+			} else if result.Requeue {
+				return result, nil
+			}
+			// the StatefulSet is not updated with the images failing the verification
+			if redisObject.Spec.ImageVerification == nil {
+				break
+			}
+			keys, err := cosign.ParsePublicKeys(redisObject.Spec.ImageVerification.PublicKeys...)
+			if err != nil {
+				return configInvalid(k8sv1alpha1.ReasonConfigInvalid, fmt.Errorf("invalid image verification keys: %s", err))
+			}
+			// the failed verification holds the StatefulSet only, the running Pods are still failed over
+			reason, err := reconciler.verifyImages(ctx, redisObject, options, keys)
+			if err != nil {
+				imagesVerified, verificationErr = reconciler.reportUnverifiedImages(fetchedRedis, reason, err), err
+			} else {
+				condition := newCondition(k8sv1alpha1.ConditionImagesVerified, corev1.ConditionTrue,
+					k8sv1alpha1.ReasonImagesVerified, "signatures of all the images are verified")
+				imagesVerified = &condition
+			}
+		case *networkingv1.NetworkPolicy:
+			if redisObject.Spec.NetworkPolicy == nil {
+				if err := reconciler.deleteNetworkPolicy(ctx, redisObject); err != nil {
+					return reconcile.Result{}, err
+				}
+				continue
+			}
+		case *unstructured.Unstructured:
+			// the ServiceMonitor is managed only if the Prometheus Operator is installed
+			if !reconciler.options.ServiceMonitors || !reconciler.discovery.serves(serviceMonitorGVK) {
+				continue
+			}
+			if !exporterEnabled(redisObject) {
+				if err := reconciler.deleteServiceMonitor(ctx, redisObject); err != nil {
+					return reconcile.Result{}, err
+				}
+				continue
+			}
 		case *corev1.Secret:
-			if len(options.password) == 0 {
+			if !authConfigured(redisObject) {
 				continue
 			}
 		case *corev1.Service:
-			// a bit hacky way to create three different instances of *v1.Service
+			// a bit hacky way to create four different instances of *v1.Service
 			// without copy-pasting and introducing all the corresponding risks
 			options.serviceType = serviceTypeAll + i
 		default:
 			// unknown type
 			continue
 		}
+		if heldByImageVerification(object, imagesVerified) {
+			continue
+		}
 
 		if result, err := reconciler.createOrUpdate(ctx, object, redisObject, options); err != nil {
 			return reconcile.Result{}, err
@@ -196,6 +711,53 @@ func (reconciler *ReconcileRedis) Reconcile(request reconcile.Request) (reconcil
 		}
 	}
 
+	// the connection info follows the Services and the port
+	if redisObject.Spec.ConnectionInfo {
+		connectionOptions := options
+		connectionOptions.connectionInfo = true
+		if result, err := reconciler.createOrUpdate(ctx, new(corev1.ConfigMap), redisObject, connectionOptions); err != nil {
+			return reconcile.Result{}, err
+		} else if result.Requeue {
+			logger.Info("Applied connection info ConfigMap")
+			return result, nil
+		}
+	} else if err := reconciler.deleteControlled(ctx, redisObject, new(corev1.ConfigMap),
+		generateConnectionInfoName(redisObject)); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if redisObject.Spec.ServiceBinding {
+		bindingOptions := options
+		bindingOptions.serviceBinding = true
+		if result, err := reconciler.createOrUpdate(ctx, new(corev1.Secret), redisObject, bindingOptions); err != nil {
+			return reconcile.Result{}, err
+		} else if result.Requeue {
+			logger.Info("Applied service binding Secret")
+			return result, nil
+		}
+	} else if err := reconciler.deleteControlled(ctx, redisObject, new(corev1.Secret),
+		generateServiceBindingName(redisObject)); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// the scheduled backups are taken by the CronJob of the version served by the cluster
+	if result, err := reconciler.reconcileBackupCronJob(ctx, redisObject, options); err != nil {
+		return reconcile.Result{}, err
+	} else if result.Requeue {
+		logger.Info("Applied backup CronJob")
+		return result, nil
+	}
+
+	// the node-local caches run the configuration and the images of the instances
+	if heldByImageVerification(new(appsv1.DaemonSet), imagesVerified) {
+		loggerDebug("Node-local cache is not updated with the unverified images")
+	} else if result, err := reconciler.reconcileNodeLocalCache(ctx, redisObject, options); err != nil {
+		return reconcile.Result{}, err
+	} else if result.Requeue {
+		logger.Info("Applied node-local cache")
+		return result, nil
+	}
+
 	// all the kubernetes resources are OK.
 	// Redis failover state should be checked and reconfigured if needed.
 	podList := new(corev1.PodList)
@@ -206,57 +768,256 @@ func (reconciler *ReconcileRedis) Reconcile(request reconcile.Request) (reconcil
 	if err := reconciler.client.List(ctx, podList, listOpts...); err != nil {
 		return reconcile.Result{}, fmt.Errorf("failed to list Pods: %s", err)
 	}
+	// the Pods matching the labels that are not the instances are left out and lose their roles
+	var others []corev1.Pod
+	podList.Items, others = partitionMembers(redisObject, podList.Items)
+	if stripped, err := reconciler.stripRoleLabels(ctx, others); err != nil {
+		return reconcile.Result{}, err
+	} else if len(stripped) > 0 {
+		logger.Info("Removed the role labels of the Pods not being the instances", "pods", stripped)
+	}
 
 	var addresses []redis.Address
+	// podNames maps Pod IPs to Pod names
+	podNames := make(map[string]string)
+	// knownMaster is the address of the master recorded in the status
+	var knownMaster redis.Address
 
-podIter:
 	// filter out pods without assigned IP addresses and not having all containers ready
 	for i := range podList.Items {
-		if podList.Items[i].Status.Phase != corev1.PodRunning || podList.Items[i].Status.PodIP == "" {
+		if !podReady(&podList.Items[i]) {
 			continue
 		}
 
-		for _, status := range podList.Items[i].Status.ContainerStatuses {
-			if !status.Ready {
-				continue podIter
-			}
+		address := redis.Address{Host: podList.Items[i].Status.PodIP, Port: strconv.Itoa(redisPort(redisObject))}
+		addresses = append(addresses, address)
+		podNames[podList.Items[i].Status.PodIP] = podList.Items[i].Name
+		if podList.Items[i].Name == fetchedRedis.Status.Master {
+			knownMaster = address
 		}
+	}
+
+	// the node-local caches are left out of the replication
+	cachePods, err := reconciler.nodeLocalCachePods(ctx, redisObject)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	var caches []redis.Address
+	for i := range cachePods {
+		caches = append(caches, cacheAddress(redisObject, &cachePods[i]))
+	}
 
-		addresses = append(addresses, redis.Address{Host: podList.Items[i].Status.PodIP, Port: strconv.Itoa(redis.Port)})
+	// the instances exposed outside of the cluster announce their external addresses
+	announced, externalAddresses, err := reconciler.reconcileExternalAccess(ctx, redisObject, podList.Items, options)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// the operator user is not created yet on the instances running before the default user is disabled,
+	// hence the connections fall back to the default user
+	var username string
+	if defaultUserDisabled(redisObject) || fetchedRedis.Status.DefaultUserDisabled {
+		username = redis.OperatorUser
 	}
 
 	// Run Redis Replication Reconfiguration
-	replication, err := redis.New(options.password, addresses...)
+	redisOptions := redis.Options{
+		Password:   options.password,
+		Username:   username,
+		TLSConfig:  tlsConfig,
+		ClientName: reconciler.options.RedisClientName,
+		Protocol:   reconciler.options.RedisProtocol,
+		Master:     knownMaster,
+		Announced:  announced,
+		Hostnames:  hostnames(redisObject, podList.Items, reconciler.options.ClusterDomain),
+		Caches:     caches,
+		// the replicas stuck in the full synchronization have not got the dataset of the master
+		Unpromotable: reconciler.unpromotableReplicas(redisObject, addresses, podNames),
+
+		NotReadyBackoff: notReadyBackoff,
+	}
+	// the clients of the instances are reused by the next reconciliation, the ones of the Pods gone are closed
+	settings := connectionSettings(redisOptions, options.tlsCertificate)
+	reconciler.connections.prune(request.NamespacedName, podList.Items, settings)
+	redisOptions.NewClient = reconciler.connections.newClient(request.NamespacedName, podList.Items, settings)
+
+	// the blue/green peers reconfigure the instances of each other, so their replication is never reconciled concurrently
+	locked := []types.NamespacedName{request.NamespacedName}
+	if peer != nil {
+		locked = append(locked, types.NamespacedName{Namespace: peer.GetNamespace(), Name: peer.GetName()})
+	}
+	unlock, err := reconciler.replicationLocks.lock(ctx, locked...)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	defer unlock()
+
+	// the green Redis replicates from the blue master until the cutover and the demoted blue Redis from the green master
+	if result, done, err := reconciler.reconcileBlueGreen(ctx, fetchedRedis, redisObject, peer, podList.Items, addresses,
+		options, redisOptions); err != nil {
+		err = fmt.Errorf("error replicating from the blue/green peer: %s", err)
+		failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonBlueGreenFailed, err)
+		return reconcile.Result{}, err
+	} else if done {
+		return result, nil
+	}
+	// the instances of the peer are left out of the replication
+	if peer != nil {
+		peerAddresses, err := reconciler.peerAddresses(ctx, peer)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		redisOptions.Caches = append(redisOptions.Caches, peerAddresses...)
+	}
+
+	replication, err := redis.New(ctx, redisOptions, addresses...)
+	if e, ok := err.(*redis.NotReadyError); ok {
+		// the instances loading the dataset or busy are not lost, none is promoted in their place
+		logger.Info("Waiting for the instances to load the dataset", "Pods", podNamesOf(e.NotReady, podNames))
+		recordQuorum(request.NamespacedName, e.Ready, false)
+		quorumMet = []k8sv1alpha1.Condition{quorumMetCondition(e.Ready, len(e.NotReady))}
+		failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonInstancesNotReady, err)
+		return reconcile.Result{RequeueAfter: notReadyRequeueDelay}, nil
+	}
+	if e, ok := err.(*redis.QuorumError); ok {
+		// the Event carries the PING results by Pod, the ReplicationConfigured condition is set along
+		recordQuorum(request.NamespacedName, len(e.Healthy), true)
+		reconciler.reportFailure(ctx, fetchedRedis, quorumLostCondition(e, podNames), newCondition(k8sv1alpha1.ConditionReplicationConfigured,
+			corev1.ConditionFalse, k8sv1alpha1.ReasonQuorumNotMet, err.Error()))
+		return reconcile.Result{Requeue: true}, nil
+	}
+	if e, ok := err.(*redis.RefreshError); ok {
+		logger.Info("Error refreshing Redis replication, requeue", "error", err)
+		recordQuorum(request.NamespacedName, e.Healthy, false)
+		quorumMet = []k8sv1alpha1.Condition{quorumMetCondition(e.Healthy, 0)}
+		failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonReplicationFailed, err)
+		return reconcile.Result{Requeue: true}, nil
+	}
 	if err != nil {
 		// This is considered part of normal operation - return and requeue
 		logger.Info("Error creating Redis replication, requeue", "error", err)
+		failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonQuorumNotMet, err)
 		return reconcile.Result{Requeue: true}, nil
 	}
+	recordQuorum(request.NamespacedName, replication.Size(), false)
+	quorumMet = []k8sv1alpha1.Condition{quorumMetCondition(replication.Size(), len(replication.NotReady()))}
 	defer replication.Disconnect()
+	if err := ctx.Err(); err != nil {
+		return reconcile.Result{}, err
+	}
 
-	if err := replication.Reconfigure(); err != nil {
-		return reconcile.Result{}, fmt.Errorf("error reconfiguring replication: %s", err)
+	// the announced addresses are reset once the external access is disabled
+	if redisObject.Spec.ExternalAccess != nil || len(fetchedRedis.Status.ExternalAddresses) > 0 {
+		if err := replication.Announce(); err != nil {
+			err = fmt.Errorf("error announcing external addresses: %s", err)
+			failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonReplicationFailed, err)
+			return reconcile.Result{}, err
+		}
 	}
 
-	// Select master and assign the master and replica labels to the corresponding Pods.
-	// Wrapping it with the exponential backoff timer in order to wait for the updated info replication.
-	var master redis.Address
-	exponentialBackOff := backoff.NewExponentialBackOff()
-	exponentialBackOff.MaxElapsedTime = redis.DefaultFailoverTimeout
+	reconfiguration, err := replication.Reconfigure(ctx)
+	if reconfiguration.Promoted != (redis.Address{}) {
+		reconciler.recorder.Eventf(fetchedRedis, corev1.EventTypeWarning, k8sv1alpha1.ReasonMasterPromoted,
+			"Promoted %s to master after the master has been lost",
+			podNamesOf([]redis.Address{reconfiguration.Promoted}, podNames))
+	}
+	if len(reconfiguration.Replicas) > 0 {
+		reconciler.recorder.Eventf(fetchedRedis, corev1.EventTypeNormal, k8sv1alpha1.ReasonReplicasReconfigured,
+			"Reconfigured %s as replicas of the master", podNamesOf(reconfiguration.Replicas, podNames))
+	}
+	if _, ok := err.(*redis.PromotionError); ok {
+		failed(k8sv1alpha1.ConditionMasterElected, corev1.ConditionFalse, k8sv1alpha1.ReasonPromotionFailed, err)
+		return reconcile.Result{}, err
+	}
+	if err != nil {
+		err = fmt.Errorf("error reconfiguring replication: %s", err)
+		failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonReplicationFailed, err)
+		return reconcile.Result{}, err
+	}
 
-	if err := backoff.Retry(func() error {
-		if err := replication.Refresh(); err != nil {
-			return err
+	// ACL users are not replicated, they are applied to every instance
+	if err := replication.ApplyUsers(options.aclUsers...); err != nil {
+		err = fmt.Errorf("error applying ACL users: %s", err)
+		failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonReplicationFailed, err)
+		return reconcile.Result{}, err
+	}
+	// the users removed from the spec would keep working on the running instances until restarted
+	if err := replication.DeleteUsers(options.removedACLUsers...); err != nil {
+		err = fmt.Errorf("error deleting ACL users: %s", err)
+		failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonReplicationFailed, err)
+		return reconcile.Result{}, err
+	}
+
+	// the default user is disabled once the probes and the exporter of all the Pods authenticate as the operator user
+	// and stays disabled during the later rollouts. It is enabled back before the Pods are rolled out without the operator user.
+	disableDefaultUser := defaultUserDisabled(redisObject) &&
+		(fetchedRedis.Status.DefaultUserDisabled || operatorUserRolledOut(redisObject, podList.Items))
+	var defaultUserEnabled *bool
+	if disableDefaultUser || fetchedRedis.Status.DefaultUserDisabled {
+		defaultUserEnabled = &[]bool{!disableDefaultUser}[0]
+		if err := replication.SetDefaultUser(!disableDefaultUser); err != nil {
+			err = fmt.Errorf("error configuring the default user: %s", err)
+			failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonReplicationFailed, err)
+			return reconcile.Result{}, err
 		}
+	}
 
-		if master = replication.GetMasterAddress(); master == (redis.Address{}) {
-			return fmt.Errorf("no master discovered")
+	// the master is handed over ahead of the scale-down, the StatefulSet is scaled down once the status is updated
+	kept := func(address redis.Address) bool {
+		ordinal, ok := podOrdinal(redisObject, podNames[address.Host])
+		return ok && ordinal < desiredReplicas
+	}
+	if from := replication.GetMasterAddress(); replicas > desiredReplicas && from != (redis.Address{}) && !kept(from) {
+		to, err := replication.Handover(ctx, kept, syncPolicy(redisObject))
+		if err == redis.ErrNotInSync {
+			logger.Info("Waiting for the replica to catch up with the master before the scale-down", "error", err)
+			return reconcile.Result{RequeueAfter: handoverRequeueDelay}, nil
 		}
-		return nil
-	}, exponentialBackOff); err != nil {
-		logger.Info("no master discovered, requeue", "error", err, "replication", replication)
+		if err != nil {
+			err = fmt.Errorf("error handing the master role over ahead of the scale-down: %s", err)
+			failed(k8sv1alpha1.ConditionMasterElected, corev1.ConditionFalse, k8sv1alpha1.ReasonHandoverFailed, err)
+			return reconcile.Result{}, err
+		}
+		reconciler.recorder.Eventf(fetchedRedis, corev1.EventTypeNormal, k8sv1alpha1.ReasonMasterHandedOver,
+			"Handed the master role over from %s to %s ahead of the scale-down",
+			podNamesOf([]redis.Address{from}, podNames), podNamesOf([]redis.Address{to}, podNames))
+	}
+
+	// the Pods are rolled out by the operator, the replicas first and the master last, once the replication is settled
+	var rolloutRequeue time.Duration
+	if redisObject.Spec.OrchestratedUpdate != nil && replicas == desiredReplicas &&
+		reconfiguration.Promoted == (redis.Address{}) && len(reconfiguration.Replicas) == 0 {
+		if rolloutRequeue, err = reconciler.rollOut(ctx, redisObject, replication, podList.Items, podNames); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	// Select master and assign the master and replica labels to the corresponding Pods.
+	// The worker is not blocked waiting for the updated info replication: the request is requeued
+	// with the per-object exponential backoff of the work queue instead.
+	if err := replication.Refresh(ctx); err != nil {
+		logger.Info("Error refreshing Redis replication, requeue", "error", err)
 		return reconcile.Result{Requeue: true}, nil
 	}
+	if err := ctx.Err(); err != nil {
+		return reconcile.Result{}, err
+	}
+	// the lags are exported out of the refreshed replication info ahead of any failover
+	reconciler.replicationLag.record(request.NamespacedName, replication.GetReplicationLags(), podNames)
+	if err := reconciler.restartStuckReplicas(ctx, redisObject, replication.GetSyncing(), podList.Items, podNames); err != nil {
+		return reconcile.Result{}, err
+	}
+	master := replication.GetMasterAddress()
+	if master == (redis.Address{}) {
+		logger.Info("no master discovered, requeue", "replication", replication)
+		failed(k8sv1alpha1.ConditionMasterElected, corev1.ConditionFalse, k8sv1alpha1.ReasonNoMaster,
+			fmt.Errorf("no master discovered among %d instances", replication.Size()))
+		return reconcile.Result{Requeue: true}, nil
+	}
+	// the promoted master inherits the runtime configuration of the former one ahead of taking the writes
+	if err := reconciler.replayRuntimeConfig(ctx, redisObject, redisOptions, master, podNames); err != nil {
+		return reconcile.Result{}, err
+	}
 
 	// update Pod labels asynchronously and fetch the master Pod's name
 	var wg sync.WaitGroup
@@ -309,8 +1070,10 @@ podIter:
 		return reconcile.Result{Requeue: true}, nil
 	}
 
-	// update configmap with the current master's IP address
-	options.master = master
+	// update configmap with the current master's address, the DNS name of its Pod if addressed by hostname,
+	// and the external addresses announced by the instances
+	options.master = replicationTarget(redisOptions.Hostnames, master)
+	options.announced = announcedAddresses(externalAddresses)
 	if result, err := reconciler.createOrUpdate(ctx, new(corev1.ConfigMap), redisObject, options); err != nil {
 		return result, err
 	} else if result.Requeue {
@@ -318,14 +1081,99 @@ podIter:
 		return result, nil
 	}
 
-	masterPodName := <-masterChan
-	if fetchedRedis.Status.Replicas == replication.Size() && fetchedRedis.Status.Master == masterPodName {
-		// Everything is OK - don't requeue
-		return reconcile.Result{}, nil
+	// the caches follow the master once the restarted ones read it from the configuration
+	if err := reconciler.replicateNodeLocalCaches(ctx, redisObject, redisOptions, master, cachePods,
+		options.aclUsers, options.removedACLUsers, defaultUserEnabled); err != nil {
+		err = fmt.Errorf("error configuring node-local caches: %s", err)
+		failed(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionFalse, k8sv1alpha1.ReasonReplicationFailed, err)
+		return reconcile.Result{}, err
 	}
 
-	fetchedRedis.Status.Replicas = replication.Size()
-	fetchedRedis.Status.Master = masterPodName
+	status := fetchedRedis.Status.DeepCopy()
+	status.Replicas = replication.Size()
+	status.Master = <-masterChan
+	status.Images = podImages(podList.Items, status.Master)
+	status.ImageUpdate = imageUpdate
+	status.ExternalAddresses = externalAddresses
+	status.DefaultUserDisabled = disableDefaultUser
+	status.ACLUsers = aclUserNames(options.aclUsers)
+	status.Instances = instanceStatuses(redisObject, podList.Items, status.Master, reconciler.options.ClusterDomain)
+	// the partially rolled out Pods are told apart by the revisions they run
+	if status.UpdateRevision, err = reconciler.updateRevision(ctx, redisObject); err != nil {
+		return reconcile.Result{}, err
+	}
+	status.ConfigRevision = options.configRevision
+	setRevisions(status.Instances, podList.Items, status.UpdateRevision, status.ConfigRevision)
+	status.Binding = serviceBindingStatus(redisObject)
+	status.Outputs = generateOutputs(redisObject, reconciler.options.ClusterDomain)
+	if imageUpdate != nil {
+		imageUpdate.RunningVersion = runningVersion(podList.Items, status.Master)
+	}
+	if restoreErr != nil {
+		status.SetCondition(newCondition(k8sv1alpha1.ConditionConfigInvalid, corev1.ConditionTrue,
+			k8sv1alpha1.ReasonRestoreSourceUnavailable, restoreErr.Error()))
+	} else {
+		status.SetCondition(newCondition(k8sv1alpha1.ConditionConfigInvalid, corev1.ConditionFalse, k8sv1alpha1.ReasonConfigValid,
+			"spec and referenced Secrets are valid"))
+	}
+	status.SetCondition(newCondition(k8sv1alpha1.ConditionReplicationConfigured, corev1.ConditionTrue,
+		k8sv1alpha1.ReasonReplicationConfigured, fmt.Sprintf("%d instances are replicating from the master", status.Replicas-1)))
+	status.SetCondition(newCondition(k8sv1alpha1.ConditionMasterElected, corev1.ConditionTrue, k8sv1alpha1.ReasonMasterElected,
+		fmt.Sprintf("%s is the master", status.Master)))
+	status.SetCondition(quorumMetCondition(status.Replicas, len(replication.NotReady())))
+	status.SetCondition(degradedCondition(status.Replicas, replication.NotReady(), podNames, redisObject.Spec.Replicas))
+	status.SetCondition(readyCondition(status))
+	status.SetCondition(newCondition(k8sv1alpha1.ConditionReconcileTimedOut, corev1.ConditionFalse,
+		k8sv1alpha1.ReasonReconcileCompleted, "reconciliation completed in time"))
+	status.SetCondition(persistenceCondition(replication.GetPersistenceFailures(), podNames))
+	reconciler.checkAOFFsync(redisObject, status, replication.GetAOFDelayedFsyncs(), podNames)
+	reconciler.checkHostSettings(redisObject, status, podList.Items)
+	reconciler.checkPubSub(ctx, redisObject, status, replication, podNames)
+	reconciler.checkClass(redisObject, status, classConflicts)
+	if err := reconciler.checkEvictions(ctx, redisObject, status, replication, podNames); err != nil {
+		logger.Info("Error sampling keyspace", "error", err)
+	}
+	if imagesVerified != nil {
+		status.SetCondition(*imagesVerified)
+	} else {
+		status.RemoveCondition(k8sv1alpha1.ConditionImagesVerified)
+	}
+	if status.RemoveCondition(k8sv1alpha1.ConditionPaused) {
+		logger.Info("Reconciliation resumed")
+		reconciler.recorder.Event(fetchedRedis, corev1.EventTypeNormal, k8sv1alpha1.ReasonResumed, "reconciliation is resumed")
+	}
+	if redisObject.Spec.DataVolumeUsageThreshold != nil &&
+		!reflect.DeepEqual(redisObject.Spec.DataVolumeClaimTemplate, corev1.PersistentVolumeClaim{}) {
+		reconciler.checkDataVolumeUsage(ctx, redisObject, status, podList.Items)
+	}
+
+	var result reconcile.Result
+	if result.Requeue, err = reconciler.checkScheduledBackup(ctx, redisObject, status); err != nil {
+		return reconcile.Result{}, err
+	}
+	if redisObject.Spec.Backup != nil && redisObject.Spec.Backup.RestoreDrill != nil {
+		if result.RequeueAfter, err = reconciler.runRestoreDrill(ctx, redisObject, status); err != nil {
+			return reconcile.Result{}, fmt.Errorf("error running the restore drill: %s", err)
+		}
+	}
+	if err := reconciler.checkDataProtection(ctx, redisObject, status,
+		fetchedRedis.Status.Master == "" && status.Master != "",
+	); err != nil {
+		return reconcile.Result{}, err
+	}
+	if imageUpdateRequeue > 0 && (result.RequeueAfter == 0 || imageUpdateRequeue < result.RequeueAfter) {
+		result.RequeueAfter = imageUpdateRequeue
+	}
+	if rolloutRequeue > 0 && (result.RequeueAfter == 0 || rolloutRequeue < result.RequeueAfter) {
+		result.RequeueAfter = rolloutRequeue
+	}
+
+	if reflect.DeepEqual(status, &fetchedRedis.Status) {
+		// Everything is OK - don't requeue unless the restore drill is scheduled or the images are not verified
+		return result, verificationErr
+	}
+
+	fetchedRedis.Status = *status
 	if err := reconciler.client.Status().Update(ctx, fetchedRedis); err != nil {
 		if errors.IsConflict(err) {
 			loggerDebug("Conflict updating Redis status, requeue")
@@ -334,12 +1182,31 @@ podIter:
 		return reconcile.Result{}, fmt.Errorf("failed to update Redis status: %s", err)
 	}
 	logger.Info("Updated Redis status")
-	return reconcile.Result{}, nil
+	return result, verificationErr
+}
+
+// readSecretKey returns the value of the key of the Secret in the namespace
+func (reconciler *ReconcileRedis) readSecretKey(
+	ctx context.Context,
+	namespace string,
+	selector *corev1.SecretKeySelector,
+) (string, error) {
+	secret := new(corev1.Secret)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name}, secret); err != nil {
+		return "", err
+	}
+
+	value, ok := secret.Data[selector.Key]
+	if !ok && (selector.Optional == nil || !*selector.Optional) {
+		return "", fmt.Errorf("key %s is not found in Secret %s", selector.Key, selector.Name)
+	}
+	return string(value), nil
 }
 
 // createOrUpdate abstracts away keeping in sync the desired and actual state of Kubernetes objects.
 // passing an empty instance implementing runtime.Object will generate the appropriate ``expected'' object,
-// create an object if it does not exist, compare the existing object with the generated one and update if needed.
+// compare the existing object with the generated one and apply the generated one with server-side apply if needed.
+// Only the generated fields are claimed by the fieldManager, the fields set by the other controllers are kept.
 // the Result.Requeue will be true if the object was successfully created or updated or in case there was a conflict updating the object.
 func (reconciler *ReconcileRedis) createOrUpdate(
 	ctx context.Context,
@@ -348,35 +1215,144 @@ func (reconciler *ReconcileRedis) createOrUpdate(
 	options objectGeneratorOptions,
 ) (result reconcile.Result, err error) {
 	generatedObject := generateObject(redis, object, options)
+	if err = reconciler.options.Hooks.apply(generatedObject, redis); err != nil {
+		return reconcile.Result{}, err
+	}
 	objectMeta := generatedObject.(metav1.Object)
+	// Set Redis instance as the owner and controller
+	if err = controllerutil.SetControllerReference(redis, objectMeta, reconciler.scheme); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to set owner for Object: %s", err)
+	}
 
+	found := true
 	if err = reconciler.client.Get(ctx, types.NamespacedName{
 		Namespace: redis.GetNamespace(),
 		Name:      objectMeta.GetName(),
 	}, object); err != nil {
-		if errors.IsNotFound(err) {
-			// Set Redis instance as the owner and controller
-			if err = controllerutil.SetControllerReference(redis, objectMeta, reconciler.scheme); err != nil {
-				return reconcile.Result{}, fmt.Errorf("failed to set owner for Object: %s", err)
+		if !errors.IsNotFound(err) {
+			return reconcile.Result{}, fmt.Errorf("failed to fetch Object: %s", err)
+		}
+		found = false
+	}
+
+	class := loadBalancerClass(redis, options)
+	var changes string
+	var adopted bool
+	if found {
+		// the objects of the generated name without a controller are adopted along with the update
+		if adopted, err = adoptable(object.(metav1.Object), redis); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to adopt %s: %s", objectKind(generatedObject), err)
+		}
+		original := object.DeepCopyObject()
+		var gvk schema.GroupVersionKind
+		if gvk, err = apiutil.GVKForObject(generatedObject, reconciler.scheme); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to get the kind of Object: %s", err)
+		}
+		if upgradeManagedFields(object.(metav1.Object), gvk.GroupVersion().String()) {
+			if err = reconciler.client.Patch(ctx, object, client.MergeFrom(original)); err != nil {
+				if errors.IsConflict(err) {
+					return reconcile.Result{Requeue: true}, nil
+				}
+				return reconcile.Result{}, fmt.Errorf("failed to upgrade the managed fields of Object: %s", err)
 			}
-			if err = reconciler.client.Create(ctx, generatedObject); err != nil && !errors.IsAlreadyExists(err) {
-				return reconcile.Result{}, fmt.Errorf("failed to create Object: %s", err)
+			original = object.DeepCopyObject()
+		}
+
+		if !objectUpdateNeeded(object, generatedObject) && !adopted {
+			return
+		}
+		// the master and the announced addresses kept in the configuration are applied along with it
+		if configMap, ok := object.(*corev1.ConfigMap); ok {
+			if _, ok := configMap.Data[configFileName]; ok {
+				generatedObject.(*corev1.ConfigMap).Data = configMap.Data
+			}
+		}
+		if changes, err = objectChanges(original, object); err != nil {
+			changes = fmt.Sprintf("error comparing the object: %s", err)
+		}
+		if service, ok := original.(*corev1.Service); ok {
+			if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+				if class, err = serverLoadBalancerClass(ctx, reconciler.client, service); err != nil {
+					return reconcile.Result{}, fmt.Errorf("failed to fetch the load balancer class: %s", err)
+				}
+			}
+			// the apply does not revert the fields of the Service spec claimed by the other managers
+			var patch client.Patch
+			if patch, err = serviceSpecPatch(service, object.(*corev1.Service), class); err != nil {
+				return reconcile.Result{}, fmt.Errorf("failed to compare the Service spec: %s", err)
+			}
+			if patch != nil {
+				if err = reconciler.client.Patch(ctx, object, patch); err != nil {
+					if errors.IsConflict(err) {
+						return reconcile.Result{Requeue: true}, nil
+					}
+					if errors.IsInvalid(err) {
+						if changed, _ := serviceChanged(ctx, reconciler.apiReader, service); changed {
+							return reconcile.Result{Requeue: true}, nil
+						}
+					}
+					return reconcile.Result{}, fmt.Errorf("failed to patch Object: %s", err)
+				}
 			}
-			return reconcile.Result{Requeue: true}, nil
 		}
-		return reconcile.Result{}, fmt.Errorf("failed to fetch Object: %s", err)
 	}
 
-	if !objectUpdateNeeded(object, generatedObject) {
-		return
+	var policy map[string]interface{}
+	if _, ok := generatedObject.(*appsv1.StatefulSet); ok {
+		policy = retentionPolicy(redis)
 	}
-
-	if err = reconciler.client.Update(ctx, object); err != nil {
+	appliedObject, err := applyConfiguration(reconciler.scheme, generatedObject, class, policy)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to generate the applied Object: %s", err)
+	}
+	if err = reconciler.client.Patch(ctx, appliedObject, client.Apply, applyOptions...); err != nil {
 		if errors.IsConflict(err) {
 			// conflicts can be common, consider it part of normal operation
 			return reconcile.Result{Requeue: true}, nil
 		}
-		return reconcile.Result{}, fmt.Errorf("failed to update Object: %s", err)
+		return reconcile.Result{}, fmt.Errorf("failed to apply Object: %s", err)
+	}
+
+	switch {
+	case !found:
+		reconciler.recorder.Eventf(redis, corev1.EventTypeNormal, k8sv1alpha1.ReasonCreated,
+			"Created %s %s", objectKind(generatedObject), objectMeta.GetName())
+		return reconcile.Result{Requeue: true}, nil
+	case adopted:
+		reconciler.recorder.Eventf(redis, corev1.EventTypeNormal, k8sv1alpha1.ReasonAdopted,
+			"Adopted %s %s", objectKind(generatedObject), objectMeta.GetName())
+	default:
+		reconciler.recorder.Eventf(redis, corev1.EventTypeNormal, k8sv1alpha1.ReasonUpdated,
+			"Updated %s %s", objectKind(generatedObject), objectMeta.GetName())
 	}
+	reconciler.decisions.recordDiff(types.NamespacedName{Namespace: redis.GetNamespace(), Name: redis.GetName()}, objectDiff{
+		at:      time.Now(),
+		object:  fmt.Sprintf("%s %s", objectKind(generatedObject), objectMeta.GetName()),
+		changes: changes,
+	})
 	return reconcile.Result{Requeue: true}, nil
 }
+
+// deleteControlled deletes the object of the name in the namespace of the Redis once it is not generated anymore.
+// The objects not controlled by the Redis are left intact.
+func (reconciler *ReconcileRedis) deleteControlled(
+	ctx context.Context,
+	redis *k8sv1alpha1.Redis,
+	object runtime.Object,
+	name string,
+) error {
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: redis.GetNamespace(), Name: name}, object); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to fetch %s: %s", objectKind(object), err)
+	}
+	if !metav1.IsControlledBy(object.(metav1.Object), redis) {
+		return nil
+	}
+
+	if err := reconciler.client.Delete(ctx, object); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete %s: %s", objectKind(object), err)
+	}
+	return nil
+}
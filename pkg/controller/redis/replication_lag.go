@@ -0,0 +1,95 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"sync"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	replicationLagLabels = []string{"namespace", "redis", "pod"}
+
+	replicationOffsetLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_operator_replication_offset_lag_bytes",
+		Help: "Number of bytes of the replication stream the replica is behind the master by",
+	}, replicationLagLabels)
+	replicationMasterLastIO = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_operator_replication_master_last_io_seconds",
+		Help: "Number of seconds since the replica last interacted with the master, -1 while disconnected from it",
+	}, replicationLagLabels)
+)
+
+func init() {
+	metrics.Registry.MustRegister(replicationOffsetLag, replicationMasterLastIO)
+}
+
+// replicationLagTracker keeps the Pods the replication lag metrics are exported for by Redis, so the metrics
+// of the Pods that are no longer replicas, e.g. the promoted or deleted ones, are deleted. It is safe for concurrent use.
+type replicationLagTracker struct {
+	mu   sync.Mutex
+	pods map[types.NamespacedName]map[string]bool
+}
+
+func newReplicationLagTracker() *replicationLagTracker {
+	return &replicationLagTracker{pods: make(map[types.NamespacedName]map[string]bool)}
+}
+
+// record exports the replication lags of the replicas by Pod and deletes the metrics of the other Pods
+func (t *replicationLagTracker) record(key types.NamespacedName, lags map[redis.Address]redis.ReplicationLag,
+	podNames map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pods := make(map[string]bool, len(lags))
+	for address, lag := range lags {
+		name, ok := podNames[address.Host]
+		if !ok {
+			name = address.String()
+		}
+		pods[name] = true
+		replicationOffsetLag.WithLabelValues(key.Namespace, key.Name, name).Set(float64(lag.Offset))
+		replicationMasterLastIO.WithLabelValues(key.Namespace, key.Name, name).Set(float64(lag.MasterLastIOSecondsAgo))
+	}
+	for pod := range t.pods[key] {
+		if !pods[pod] {
+			deleteReplicationLagMetrics(key, pod)
+		}
+	}
+	t.pods[key] = pods
+}
+
+// forget deletes the replication lag metrics of the Pods of the Redis
+func (t *replicationLagTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for pod := range t.pods[key] {
+		deleteReplicationLagMetrics(key, pod)
+	}
+	delete(t.pods, key)
+}
+
+func deleteReplicationLagMetrics(key types.NamespacedName, pod string) {
+	replicationOffsetLag.DeleteLabelValues(key.Namespace, key.Name, pod)
+	replicationMasterLastIO.DeleteLabelValues(key.Namespace, key.Name, pod)
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_replicationLagTracker(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	podNames := map[string]string{"10.0.0.2": "redis-test-1", "10.0.0.3": "redis-test-2"}
+	tracker := newReplicationLagTracker()
+	defer tracker.forget(key)
+
+	tracker.record(key, map[redis.Address]redis.ReplicationLag{
+		{Host: "10.0.0.2", Port: "6379"}: {Offset: 100, MasterLastIOSecondsAgo: 1},
+		{Host: "10.0.0.3", Port: "6379"}: {MasterLastIOSecondsAgo: -1},
+	}, podNames)
+	if want := map[string]bool{"redis-test-1": true, "redis-test-2": true}; !reflect.DeepEqual(tracker.pods[key], want) {
+		t.Errorf("record() pods = %v, want %v", tracker.pods[key], want)
+	}
+
+	// redis-test-1 is promoted, its metrics are deleted
+	tracker.record(key, map[redis.Address]redis.ReplicationLag{
+		{Host: "10.0.0.3", Port: "6379"}: {MasterLastIOSecondsAgo: 0},
+	}, podNames)
+	if want := map[string]bool{"redis-test-2": true}; !reflect.DeepEqual(tracker.pods[key], want) {
+		t.Errorf("record() pods = %v, want %v", tracker.pods[key], want)
+	}
+	if replicationOffsetLag.DeleteLabelValues(key.Namespace, key.Name, "redis-test-1") {
+		t.Errorf("record() kept the metrics of the promoted Pod")
+	}
+
+	tracker.forget(key)
+	if len(tracker.pods) > 0 {
+		t.Errorf("forget() pods = %v, want none", tracker.pods)
+	}
+	if replicationMasterLastIO.DeleteLabelValues(key.Namespace, key.Name, "redis-test-2") {
+		t.Errorf("forget() kept the metrics of the replica")
+	}
+}
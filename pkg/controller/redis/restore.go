@@ -0,0 +1,219 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+const (
+	// defaultRestoreDrillTimeout is used when the drill timeout is not set
+	defaultRestoreDrillTimeout = 30 * time.Minute
+	// restoreDrillCheckInterval is the delay between checks of the temporary Redis
+	restoreDrillCheckInterval = 10 * time.Second
+)
+
+// fetchRestoreSource resolves the file referred by spec.restore
+func (reconciler *ReconcileRedis) fetchRestoreSource(ctx context.Context, r *k8sv1alpha1.Redis) (*restoreSource, error) {
+	restore := r.Spec.Restore
+	if (restore.BackupName == "") == (restore.Artifact == nil) {
+		return nil, fmt.Errorf("exactly one of backupName and artifact must be set")
+	}
+
+	if artifact := restore.Artifact; artifact != nil {
+		if err := validateBackupStorage(artifact.Storage); err != nil {
+			return nil, err
+		}
+		return &restoreSource{
+			storage: artifact.Storage,
+			agent:   artifact.Agent,
+			path:    path.Join(backupBucket(artifact.Storage), artifact.Path),
+		}, nil
+	}
+
+	backup := new(k8sv1alpha1.RedisBackup)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{
+		Namespace: r.GetNamespace(),
+		Name:      r.Spec.Restore.BackupName,
+	}, backup); err != nil {
+		return nil, fmt.Errorf("failed to fetch RedisBackup %s: %s", r.Spec.Restore.BackupName, err)
+	}
+	if backup.Status.Phase != k8sv1alpha1.BackupPhaseSucceeded {
+		return nil, fmt.Errorf("RedisBackup %s has not succeeded", backup.GetName())
+	}
+
+	// the storage settings are taken from the backed up Redis
+	backedUp := new(k8sv1alpha1.Redis)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{
+		Namespace: r.GetNamespace(),
+		Name:      backup.Spec.RedisName,
+	}, backedUp); err != nil {
+		return nil, fmt.Errorf("failed to fetch Redis %s: %s", backup.Spec.RedisName, err)
+	}
+	if _, err := applyClass(ctx, reconciler.apiReader, backedUp); err != nil {
+		return nil, fmt.Errorf("failed to fetch RedisClass %s: %s", backedUp.Spec.ClassName, err)
+	}
+	if backedUp.Spec.Backup == nil {
+		return nil, fmt.Errorf("backup is not configured in Redis %s", backedUp.GetName())
+	}
+
+	return &restoreSource{
+		storage: backedUp.Spec.Backup.Storage,
+		agent:   backedUp.Spec.Backup.Agent,
+		path: path.Join(
+			backupBucket(backedUp.Spec.Backup.Storage), backupDirectory(backedUp), backupObjectName(backup),
+		),
+	}, nil
+}
+
+// fetchKeptRestore returns the restore part of the Pod template of the StatefulSet, nil if there is none
+func (reconciler *ReconcileRedis) fetchKeptRestore(ctx context.Context, r *k8sv1alpha1.Redis) (*keptRestore, error) {
+	statefulSet := new(appsv1.StatefulSet)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateName(r)}, statefulSet); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch StatefulSet: %s", err)
+	}
+	return keptRestoreOf(statefulSet), nil
+}
+
+// latestBackup returns the most recently completed succeeded RedisBackup of the Redis or nil if there is none
+func (reconciler *ReconcileRedis) latestBackup(ctx context.Context, r *k8sv1alpha1.Redis) (*k8sv1alpha1.RedisBackup, error) {
+	backupList := new(k8sv1alpha1.RedisBackupList)
+	if err := reconciler.client.List(ctx, backupList, client.InNamespace(r.GetNamespace())); err != nil {
+		return nil, fmt.Errorf("failed to list RedisBackups: %s", err)
+	}
+
+	var latest *k8sv1alpha1.RedisBackup
+	for i := range backupList.Items {
+		backup := &backupList.Items[i]
+		if backup.Spec.RedisName != r.GetName() ||
+			backup.Status.Phase != k8sv1alpha1.BackupPhaseSucceeded ||
+			backup.Status.CompletionTime == nil {
+			continue
+		}
+		if latest == nil || latest.Status.CompletionTime.Before(backup.Status.CompletionTime) {
+			latest = backup
+		}
+	}
+	return latest, nil
+}
+
+// runRestoreDrill advances the restore drill and records its state in the status.
+// Returns the delay before the drill should be checked again.
+func (reconciler *ReconcileRedis) runRestoreDrill(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	status *k8sv1alpha1.RedisStatus,
+) (time.Duration, error) {
+	drill := r.Spec.Backup.RestoreDrill
+	now := time.Now()
+
+	if status.RestoreDrill == nil || status.RestoreDrill.Phase != k8sv1alpha1.RestoreDrillRunning {
+		if status.RestoreDrill != nil {
+			if elapsed := now.Sub(status.RestoreDrill.StartTime.Time); elapsed < drill.Interval.Duration {
+				return drill.Interval.Duration - elapsed, nil
+			}
+		}
+
+		backup, err := reconciler.latestBackup(ctx, r)
+		if err != nil {
+			return 0, err
+		}
+		if backup == nil {
+			// nothing to restore yet
+			return drill.Interval.Duration, nil
+		}
+
+		drillRedis := generateRestoreDrill(r, backup)
+		if err := controllerutil.SetControllerReference(r, drillRedis, reconciler.scheme); err != nil {
+			return 0, fmt.Errorf("failed to set owner for the restore drill: %s", err)
+		}
+		if err := reconciler.client.Create(ctx, drillRedis); err != nil {
+			if errors.IsAlreadyExists(err) {
+				// a leftover of an interrupted drill
+				return restoreDrillCheckInterval, reconciler.deleteRestoreDrill(ctx, r)
+			}
+			return 0, fmt.Errorf("failed to create the restore drill: %s", err)
+		}
+
+		status.RestoreDrill = &k8sv1alpha1.RestoreDrillStatus{
+			Phase:      k8sv1alpha1.RestoreDrillRunning,
+			BackupName: backup.GetName(),
+			StartTime:  metav1.NewTime(now),
+			RPO:        &metav1.Duration{Duration: now.Sub(backup.Status.CompletionTime.Time).Round(time.Second)},
+		}
+		return restoreDrillCheckInterval, nil
+	}
+
+	timeout := defaultRestoreDrillTimeout
+	if drill.Timeout != nil {
+		timeout = drill.Timeout.Duration
+	}
+
+	drillRedis := new(k8sv1alpha1.Redis)
+	err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateRestoreDrillName(r)}, drillRedis)
+	elapsed := now.Sub(status.RestoreDrill.StartTime.Time)
+	switch {
+	case err != nil && !errors.IsNotFound(err):
+		return 0, fmt.Errorf("failed to fetch the restore drill: %s", err)
+	case errors.IsNotFound(err):
+		status.RestoreDrill.Phase = k8sv1alpha1.RestoreDrillFailed
+		status.RestoreDrill.Message = "the temporary Redis has been deleted"
+	case drillRedis.Status.Master != "":
+		// the snapshot has been checked by redis-check-rdb and loaded by the instances
+		status.RestoreDrill.Phase = k8sv1alpha1.RestoreDrillSucceeded
+		status.RestoreDrill.RTO = &metav1.Duration{Duration: elapsed.Round(time.Second)}
+	case elapsed > timeout:
+		status.RestoreDrill.Phase = k8sv1alpha1.RestoreDrillFailed
+		status.RestoreDrill.Message = fmt.Sprintf("the temporary Redis has not elected a master in %s", timeout)
+	default:
+		return restoreDrillCheckInterval, nil
+	}
+
+	completionTime := metav1.NewTime(now)
+	status.RestoreDrill.CompletionTime = &completionTime
+	next := drill.Interval.Duration - elapsed
+	if next < restoreDrillCheckInterval {
+		next = restoreDrillCheckInterval
+	}
+	return next, reconciler.deleteRestoreDrill(ctx, r)
+}
+
+// deleteRestoreDrill deletes the temporary Redis
+func (reconciler *ReconcileRedis) deleteRestoreDrill(ctx context.Context, r *k8sv1alpha1.Redis) error {
+	drillRedis := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{
+		Namespace: r.GetNamespace(),
+		Name:      generateRestoreDrillName(r),
+	}}
+	if err := reconciler.client.Delete(ctx, drillRedis, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil &&
+		!errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the restore drill: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+// retentionPolicyAnnotationKey records the retention policy applied to the StatefulSet.
+// The typed StatefulSet can not read the field back, the annotation reveals the changes of the policy instead.
+const retentionPolicyAnnotationKey = "persistent-volume-claim-retention-policy"
+
+// retentionPolicyField is the path of the StatefulSet field introduced in Kubernetes 1.23.
+// The vendored apps/v1 API predates it, hence it is written bypassing the typed StatefulSet.
+var retentionPolicyField = []string{"spec", "persistentVolumeClaimRetentionPolicy"}
+
+// retentionPolicy returns the persistentVolumeClaimRetentionPolicy of the StatefulSet with the defaults set,
+// nil if the policy is not configured
+func retentionPolicy(r *k8sv1alpha1.Redis) map[string]interface{} {
+	policy := r.Spec.PersistentVolumeClaimRetentionPolicy
+	if policy == nil {
+		return nil
+	}
+	withDefault := func(t k8sv1alpha1.PersistentVolumeClaimRetentionPolicyType) string {
+		if t == "" {
+			return string(k8sv1alpha1.RetainPersistentVolumeClaimRetentionPolicyType)
+		}
+		return string(t)
+	}
+	return map[string]interface{}{
+		"whenDeleted": withDefault(policy.WhenDeleted),
+		"whenScaled":  withDefault(policy.WhenScaled),
+	}
+}
+
+// retentionPolicyAnnotation returns the value of the retention policy annotation, e.g. whenDeleted=Retain,whenScaled=Delete
+func retentionPolicyAnnotation(policy map[string]interface{}) string {
+	return fmt.Sprintf("whenDeleted=%s,whenScaled=%s", policy["whenDeleted"], policy["whenScaled"])
+}
@@ -0,0 +1,53 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_retentionPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *k8sv1alpha1.PersistentVolumeClaimRetentionPolicy
+		want   map[string]interface{}
+	}{
+		{"none", nil, nil},
+		{"defaults", &k8sv1alpha1.PersistentVolumeClaimRetentionPolicy{}, map[string]interface{}{
+			"whenDeleted": "Retain", "whenScaled": "Retain",
+		}},
+		{"delete when scaled", &k8sv1alpha1.PersistentVolumeClaimRetentionPolicy{
+			WhenScaled: k8sv1alpha1.DeletePersistentVolumeClaimRetentionPolicyType,
+		}, map[string]interface{}{
+			"whenDeleted": "Retain", "whenScaled": "Delete",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{PersistentVolumeClaimRetentionPolicy: tt.policy}}
+			got := retentionPolicy(r)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("retentionPolicy() = %v, want %v", got, tt.want)
+			}
+			annotation, ok := generateStatefulSet(r, objectGeneratorOptions{}).Annotations[retentionPolicyAnnotationKey]
+			if ok != (tt.want != nil) || ok && annotation != retentionPolicyAnnotation(tt.want) {
+				t.Errorf("generateStatefulSet() annotation = %q", annotation)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// updateRevision returns the revision the StatefulSet updates the Pods to, empty until the StatefulSet is observed
+func (reconciler *ReconcileRedis) updateRevision(ctx context.Context, r *k8sv1alpha1.Redis) (string, error) {
+	statefulSet := new(appsv1.StatefulSet)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateName(r)},
+		statefulSet); err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to fetch StatefulSet: %s", err)
+	}
+	return statefulSet.Status.UpdateRevision, nil
+}
+
+// setRevisions sets the StatefulSet and the configuration revisions the instances run, read from the Pod labels
+// and annotations. The instances are updated once they run both the updateRevision and the configRevision.
+func setRevisions(statuses []k8sv1alpha1.InstanceStatus, pods []corev1.Pod, updateRevision, configRevision string) {
+	byName := make(map[string]*corev1.Pod, len(pods))
+	for i := range pods {
+		byName[pods[i].Name] = &pods[i]
+	}
+	for i := range statuses {
+		pod, ok := byName[statuses[i].Pod]
+		if !ok {
+			continue
+		}
+		statuses[i].Revision = pod.Labels[appsv1.ControllerRevisionHashLabelKey]
+		statuses[i].ConfigRevision = pod.Annotations[configRevisionAnnotationKey]
+		statuses[i].Updated = updateRevision != "" && statuses[i].Revision == updateRevision &&
+			statuses[i].ConfigRevision == configRevision
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_setRevisions(t *testing.T) {
+	pod := func(name, revision, configRevision string) corev1.Pod {
+		pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{appsv1.ControllerRevisionHashLabelKey: revision},
+		}}
+		if configRevision != "" {
+			pod.Annotations = map[string]string{configRevisionAnnotationKey: configRevision}
+		}
+		return pod
+	}
+	pods := []corev1.Pod{
+		pod("redis-example-0", "redis-example-1", "1"),
+		pod("redis-example-1", "redis-example-2", "1"),
+		pod("redis-example-2", "redis-example-2", "2"),
+		pod("redis-example-3", "redis-example-2", ""),
+	}
+	tests := []struct {
+		name           string
+		updateRevision string
+		configRevision string
+		want           []bool
+	}{
+		{"config restarted", "redis-example-2", "2", []bool{false, false, true, false}},
+		{"config not restarted", "redis-example-2", "", []bool{false, false, false, true}},
+		{"statefulset not observed", "", "", []bool{false, false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statuses := []k8sv1alpha1.InstanceStatus{
+				{Pod: "redis-example-0"}, {Pod: "redis-example-1"}, {Pod: "redis-example-2"}, {Pod: "redis-example-3"},
+			}
+			setRevisions(statuses, pods, tt.updateRevision, tt.configRevision)
+			for i := range statuses {
+				if statuses[i].Updated != tt.want[i] {
+					t.Errorf("%s updated = %v, want %v", statuses[i].Pod, statuses[i].Updated, tt.want[i])
+				}
+				if got, want := statuses[i].Revision, pods[i].Labels[appsv1.ControllerRevisionHashLabelKey]; got != want {
+					t.Errorf("%s revision = %q, want %q", statuses[i].Pod, got, want)
+				}
+			}
+			if got := statuses[1].ConfigRevision; got != "1" {
+				t.Errorf("config revision = %q, want 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// rolloutRequeueDelay is the delay before the next step of the rollout orchestrated by the operator
+const rolloutRequeueDelay = 5 * time.Second
+
+// rolloutStep is the next step of the rollout orchestrated by the operator
+type rolloutStep struct {
+	// restart is the name of the Pod to be deleted to be recreated with the update revision
+	restart string
+	// handover is set if the master has to hand its role over to an updated replica before it is restarted
+	handover bool
+}
+
+// planRollout returns the next step of the rollout of the StatefulSet Pods to the update revision and whether
+// the rollout is in progress. The outdated replicas are restarted one by one, the highest ordinal first,
+// and the master is restarted last once it has handed its role over to an updated replica.
+// The zero step is returned while any of the Pods is missing, terminating or not ready.
+func planRollout(r *k8sv1alpha1.Redis, pods []corev1.Pod, replicas int32, revision, master string) (rolloutStep, bool) {
+	var outdated []int32
+	names := make(map[int32]string)
+	ready := int32(0)
+	for i := range pods {
+		ordinal, ok := podOrdinal(r, pods[i].Name)
+		if !ok || ordinal >= replicas {
+			continue
+		}
+		if pods[i].DeletionTimestamp == nil && podReady(&pods[i]) {
+			ready++
+		}
+		if pods[i].Labels[appsv1.ControllerRevisionHashLabelKey] != revision {
+			outdated = append(outdated, ordinal)
+			names[ordinal] = pods[i].Name
+		}
+	}
+	if len(outdated) == 0 {
+		return rolloutStep{}, false
+	}
+	if ready < replicas {
+		return rolloutStep{}, true
+	}
+
+	sort.Slice(outdated, func(i, j int) bool { return outdated[i] > outdated[j] })
+	for _, ordinal := range outdated {
+		if names[ordinal] != master {
+			return rolloutStep{restart: names[ordinal]}, true
+		}
+	}
+	// a single instance can only be restarted
+	if replicas == 1 {
+		return rolloutStep{restart: master}, true
+	}
+	return rolloutStep{handover: true}, true
+}
+
+// rollOut carries out the next step of the rollout orchestrated by the operator once the replicas have caught up
+// with the master. The delay before the next step is returned, zero once all the Pods run the update revision.
+func (reconciler *ReconcileRedis) rollOut(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	replication redis.Replication,
+	pods []corev1.Pod,
+	podNames map[string]string,
+) (time.Duration, error) {
+	statefulSet := new(appsv1.StatefulSet)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateName(r)},
+		statefulSet); err != nil {
+		if errors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to fetch StatefulSet: %s", err)
+	}
+	revision := statefulSet.Status.UpdateRevision
+	if revision == "" || statefulSet.Spec.Replicas == nil {
+		return 0, nil
+	}
+
+	from := replication.GetMasterAddress()
+	step, pending := planRollout(r, pods, *statefulSet.Spec.Replicas, revision, podNames[from.Host])
+	if !pending {
+		return 0, nil
+	}
+	if step == (rolloutStep{}) || len(replication.Unsynced(r.Spec.OrchestratedUpdate.MaxReplicationLag)) > 0 {
+		return rolloutRequeueDelay, nil
+	}
+
+	if step.handover {
+		revisions := make(map[string]string, len(pods))
+		for i := range pods {
+			revisions[pods[i].Name] = pods[i].Labels[appsv1.ControllerRevisionHashLabelKey]
+		}
+		to, err := replication.Handover(ctx, func(address redis.Address) bool {
+			return revisions[podNames[address.Host]] == revision
+		}, syncPolicy(r))
+		if err == redis.ErrNotInSync {
+			return rolloutRequeueDelay, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error handing the master role over ahead of the rollout: %s", err)
+		}
+		reconciler.recorder.Eventf(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonMasterHandedOver,
+			"Handed the master role over from %s to %s ahead of the rollout",
+			podNamesOf([]redis.Address{from}, podNames), podNamesOf([]redis.Address{to}, podNames))
+		return rolloutRequeueDelay, nil
+	}
+
+	for i := range pods {
+		if pods[i].Name != step.restart {
+			continue
+		}
+		if err := reconciler.client.Delete(ctx, &pods[i], client.Preconditions{UID: &pods[i].UID}); err != nil &&
+			!errors.IsNotFound(err) {
+			return 0, fmt.Errorf("failed to delete Pod %s: %s", pods[i].Name, err)
+		}
+		reconciler.recorder.Eventf(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonPodRolledOut,
+			"Deleted Pod %s to be recreated with revision %s", pods[i].Name, revision)
+	}
+	return rolloutRequeueDelay, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_planRollout(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+	// pods returns the ready Pods of the revisions by ordinal
+	pods := func(revisions ...string) []corev1.Pod {
+		var list []corev1.Pod
+		for i, revision := range revisions {
+			list = append(list, corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   fmt.Sprintf("redis-example-%d", i),
+					Labels: map[string]string{appsv1.ControllerRevisionHashLabelKey: revision},
+				},
+				Status: corev1.PodStatus{
+					Phase:             corev1.PodRunning,
+					PodIP:             fmt.Sprintf("10.0.0.%d", i),
+					ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+				},
+			})
+		}
+		return list
+	}
+	notReady := pods("old", "new", "old")
+	notReady[1].Status.ContainerStatuses[0].Ready = false
+	terminating := pods("old", "old", "old")
+	terminating[2].DeletionTimestamp = &metav1.Time{}
+
+	tests := []struct {
+		name        string
+		pods        []corev1.Pod
+		replicas    int32
+		master      string
+		want        rolloutStep
+		wantPending bool
+	}{
+		{"rolled out", pods("new", "new", "new"), 3, "redis-example-0", rolloutStep{}, false},
+		{"highest replica first", pods("old", "old", "old"), 3, "redis-example-0", rolloutStep{restart: "redis-example-2"}, true},
+		{"master skipped", pods("old", "old", "old"), 3, "redis-example-2", rolloutStep{restart: "redis-example-1"}, true},
+		{"next replica", pods("old", "old", "new"), 3, "redis-example-0", rolloutStep{restart: "redis-example-1"}, true},
+		{"master last", pods("old", "new", "new"), 3, "redis-example-0", rolloutStep{handover: true}, true},
+		{"single instance", pods("old"), 1, "redis-example-0", rolloutStep{restart: "redis-example-0"}, true},
+		{"waiting for readiness", notReady, 3, "redis-example-0", rolloutStep{}, true},
+		{"waiting for termination", terminating, 3, "redis-example-0", rolloutStep{}, true},
+		{"waiting for creation", pods("old", "new"), 3, "redis-example-0", rolloutStep{}, true},
+		{"removed Pods ignored", pods("new", "new", "old"), 2, "redis-example-0", rolloutStep{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, pending := planRollout(r, tt.pods, tt.replicas, "new", tt.master)
+			if got != tt.want || pending != tt.wantPending {
+				t.Errorf("planRollout() = %+v, %v, want %+v, %v", got, pending, tt.want, tt.wantPending)
+			}
+		})
+	}
+}
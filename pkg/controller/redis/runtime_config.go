@@ -0,0 +1,154 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// runtimeConfigDirectives are the directives of the master tracked to be replayed on its successor.
+// They are changed with CONFIG SET at runtime, e.g. by the users resizing the instances in place,
+// and would otherwise reset to the values of the configuration file once another instance is promoted.
+// The client pause is not tracked: the operator pauses the writes for the handover and the cutover only,
+// and lifts the pause once the successor is promoted.
+var runtimeConfigDirectives = []string{
+	"maxmemory",
+	"maxmemory-policy",
+	"min-replicas-max-lag",
+	"min-replicas-to-write",
+}
+
+// runtimeConfig is the runtime configuration last observed on the master
+type runtimeConfig struct {
+	master redis.Address
+	config map[string]string
+}
+
+// runtimeConfigTracker keeps the runtime configuration of the masters by Redis. It is safe for concurrent use.
+type runtimeConfigTracker struct {
+	mu      sync.Mutex
+	configs map[types.NamespacedName]runtimeConfig
+}
+
+func newRuntimeConfigTracker() *runtimeConfigTracker {
+	return &runtimeConfigTracker{configs: make(map[types.NamespacedName]runtimeConfig)}
+}
+
+func (t *runtimeConfigTracker) get(key types.NamespacedName) (runtimeConfig, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recorded, ok := t.configs[key]
+	return recorded, ok
+}
+
+func (t *runtimeConfigTracker) record(key types.NamespacedName, master redis.Address, config map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.configs[key] = runtimeConfig{master: master, config: config}
+}
+
+// update overrides the tracked directives changed in the configuration of the Redis, so the changes applied by
+// the operator are not replaced with the values of the former master. The directives removed from
+// the configuration are no longer replayed, the restarted instances reset them to their defaults.
+func (t *runtimeConfigTracker) update(key types.NamespacedName, applied, wanted map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recorded, ok := t.configs[key]
+	if !ok {
+		return
+	}
+	config := make(map[string]string, len(recorded.config))
+	for name, value := range recorded.config {
+		wantedValue, inWanted := wanted[name]
+		appliedValue, inApplied := applied[name]
+		switch {
+		case inWanted && (!inApplied || wantedValue != appliedValue):
+			value = wantedValue
+		case inApplied && !inWanted:
+			continue
+		}
+		config[name] = value
+	}
+	t.configs[key] = runtimeConfig{master: recorded.master, config: config}
+}
+
+// forget deletes the runtime configuration tracked for the Redis
+func (t *runtimeConfigTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.configs, key)
+}
+
+// runtimeConfigChanges returns the tracked directives of the former master that differ on the new one
+func runtimeConfigChanges(former, current map[string]string) map[string]string {
+	changed := make(map[string]string)
+	for name, value := range former {
+		if current[name] != value {
+			changed[name] = value
+		}
+	}
+	return changed
+}
+
+// replayRuntimeConfig sets the runtime configuration of the former master on the new one once the master changes,
+// whatever promoted it: the failover, the handover or the cutover. The runtime configuration of the master
+// is tracked then, the first one observed after the start of the operator is taken as is.
+func (reconciler *ReconcileRedis) replayRuntimeConfig(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	options redis.Options,
+	master redis.Address,
+	podNames map[string]string,
+) error {
+	key := types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()}
+	current, err := redis.GetConfig(ctx, options, master, runtimeConfigDirectives...)
+	if err != nil {
+		return fmt.Errorf("error getting the runtime configuration of the master: %s", err)
+	}
+
+	if recorded, ok := reconciler.runtimeConfig.get(key); ok && recorded.master != master {
+		changed := runtimeConfigChanges(recorded.config, current)
+		if len(changed) > 0 {
+			if err := redis.ApplyConfig(ctx, options, changed, master); err != nil {
+				return fmt.Errorf("error replaying the runtime configuration on the master: %s", err)
+			}
+			names := make([]string, 0, len(changed))
+			for name, value := range changed {
+				names = append(names, name)
+				current[name] = value
+			}
+			sort.Strings(names)
+			reconciler.recorder.Eventf(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonRuntimeConfigReplayed,
+				"%s of %s set on the new master %s", strings.Join(names, ", "),
+				podNamesOf([]redis.Address{recorded.master}, podNames), podNamesOf([]redis.Address{master}, podNames))
+		}
+	}
+	reconciler.runtimeConfig.record(key, master, current)
+	return nil
+}
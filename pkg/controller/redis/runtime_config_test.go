@@ -0,0 +1,76 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_runtimeConfigChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		former  map[string]string
+		current map[string]string
+		want    map[string]string
+	}{
+		{"reset", map[string]string{"min-replicas-to-write": "1", "maxmemory": "1073741824"},
+			map[string]string{"min-replicas-to-write": "0", "maxmemory": "1073741824"}, map[string]string{"min-replicas-to-write": "1"}},
+		{"inherited", map[string]string{"maxmemory-policy": "allkeys-lru"}, map[string]string{"maxmemory-policy": "allkeys-lru"}, map[string]string{}},
+		{"unknown on the new master", map[string]string{"maxmemory-policy": "allkeys-lru"}, map[string]string{},
+			map[string]string{"maxmemory-policy": "allkeys-lru"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runtimeConfigChanges(tt.former, tt.current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("runtimeConfigChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_runtimeConfigTracker_update(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "example"}
+	master := redis.Address{Host: "172.18.0.2", Port: "6379"}
+	tracker := newRuntimeConfigTracker()
+
+	tracker.update(key, nil, map[string]string{"maxmemory": "1gb"})
+	if _, ok := tracker.get(key); ok {
+		t.Fatalf("update() tracked the Redis with no master observed")
+	}
+
+	tracker.record(key, master, map[string]string{
+		"maxmemory":             "2147483648",
+		"maxmemory-policy":      "allkeys-lru",
+		"min-replicas-to-write": "1",
+	})
+	tracker.update(key,
+		map[string]string{"maxmemory": "2gb", "maxmemory-policy": "allkeys-lru", "appendonly": "yes"},
+		map[string]string{"maxmemory": "1gb", "appendonly": "no"})
+	recorded, ok := tracker.get(key)
+	want := map[string]string{"maxmemory": "1gb", "min-replicas-to-write": "1"}
+	if !ok || recorded.master != master || !reflect.DeepEqual(recorded.config, want) {
+		t.Errorf("get() = %v, %v, want %v", recorded, ok, want)
+	}
+
+	tracker.forget(key)
+	if _, ok := tracker.get(key); ok {
+		t.Errorf("get() returned the forgotten Redis")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// handoverRequeueDelay is the delay before the next attempt to hand the master role over ahead of the scale-down
+const handoverRequeueDelay = 5 * time.Second
+
+// syncPolicy returns the condition the successor has to meet before the master role is handed over to it
+func syncPolicy(r *k8sv1alpha1.Redis) redis.SyncPolicy {
+	if r.Spec.Handover == nil {
+		return redis.SyncPolicy{}
+	}
+	policy := redis.SyncPolicy{MaxLag: r.Spec.Handover.MaxLag}
+	if r.Spec.Handover.SyncTimeout != nil {
+		policy.Timeout = r.Spec.Handover.SyncTimeout.Duration
+	}
+	return policy
+}
+
+// podOrdinal returns the ordinal of the StatefulSet Pod of the Redis, false if the name does not belong to one
+func podOrdinal(r *k8sv1alpha1.Redis, pod string) (int32, bool) {
+	prefix := generateName(r) + "-"
+	if !strings.HasPrefix(pod, prefix) {
+		return 0, false
+	}
+	ordinal, err := strconv.ParseInt(strings.TrimPrefix(pod, prefix), 10, 32)
+	if err != nil || ordinal < 0 {
+		return 0, false
+	}
+	return int32(ordinal), true
+}
+
+// heldReplicas returns the number of replicas the StatefulSet is scaled to. The scale-down removing the master
+// is held at the master Pod until the master role is handed over to one of the kept instances:
+// the StatefulSet removes the Pods with the highest ordinals first.
+func heldReplicas(r *k8sv1alpha1.Redis, current int32, master string) int32 {
+	desired := *r.Spec.Replicas
+	ordinal, ok := podOrdinal(r, master)
+	if !ok || ordinal < desired || ordinal >= current {
+		return desired
+	}
+	return ordinal + 1
+}
+
+// scaleDownReplicas returns the number of replicas the StatefulSet is scaled to, see heldReplicas
+func (reconciler *ReconcileRedis) scaleDownReplicas(ctx context.Context, r *k8sv1alpha1.Redis, master string) (int32, error) {
+	statefulSet := new(appsv1.StatefulSet)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateName(r)},
+		statefulSet); err != nil {
+		if errors.IsNotFound(err) {
+			return *r.Spec.Replicas, nil
+		}
+		return 0, fmt.Errorf("failed to fetch StatefulSet: %s", err)
+	}
+	if statefulSet.Spec.Replicas == nil {
+		return *r.Spec.Replicas, nil
+	}
+	return heldReplicas(r, *statefulSet.Spec.Replicas, master), nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_podOrdinal(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+	tests := []struct {
+		pod    string
+		want   int32
+		wantOk bool
+	}{
+		{"redis-example-0", 0, true},
+		{"redis-example-12", 12, true},
+		{"redis-other-1", 0, false},
+		{"redis-example-", 0, false},
+		{"redis-example-a", 0, false},
+		{"redis-example-backup-1", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pod, func(t *testing.T) {
+			got, ok := podOrdinal(r, tt.pod)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("podOrdinal() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_heldReplicas(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired int32
+		current int32
+		master  string
+		want    int32
+	}{
+		{"scale-up", 5, 3, "redis-example-2", 5},
+		{"master kept", 3, 5, "redis-example-1", 3},
+		{"master removed", 3, 5, "redis-example-3", 4},
+		{"highest ordinal master", 3, 5, "redis-example-4", 5},
+		{"master already gone", 3, 5, "redis-example-6", 3},
+		{"no master", 3, 5, "", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{
+				ObjectMeta: metav1.ObjectMeta{Name: "example"},
+				Spec:       k8sv1alpha1.RedisSpec{Replicas: &tt.desired},
+			}
+			if got := heldReplicas(r, tt.current, tt.master); got != tt.want {
+				t.Errorf("heldReplicas() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// cronJobGVK is the batch/v1 CronJob kind served since Kubernetes 1.21, batch/v1beta1 is not served since 1.25.
+// The vendored batch/v1 API predates the CronJobs, these are managed as unstructured objects.
+var cronJobGVK = batchv1.SchemeGroupVersion.WithKind("CronJob")
+
+// newBackupCronJob returns an empty backup CronJob of the version served by the cluster, batch/v1 where available
+func (reconciler *ReconcileRedis) newBackupCronJob() runtime.Object {
+	if !reconciler.batchV1CronJob {
+		return new(batchv1beta1.CronJob)
+	}
+	cronJob := new(unstructured.Unstructured)
+	cronJob.SetGroupVersionKind(cronJobGVK)
+	return cronJob
+}
+
+// reconcileBackupCronJob creates or updates the backup CronJob, or deletes it once the schedule is removed
+func (reconciler *ReconcileRedis) reconcileBackupCronJob(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	options objectGeneratorOptions,
+) (reconcile.Result, error) {
+	if !backupScheduled(r) {
+		return reconcile.Result{}, reconciler.deleteBackupCronJob(ctx, r)
+	}
+	return reconciler.createOrUpdate(ctx, reconciler.newBackupCronJob(), r, options)
+}
+
+// scheduledBackupJobToRequests maps the Jobs created by the backup CronJob to the Redis.
+// The Jobs are owned by the CronJob, their completion does not change the CronJob otherwise.
+func scheduledBackupJobToRequests(object handler.MapObject) []reconcile.Request {
+	name, ok := object.Meta.GetLabels()[scheduledBackupLabelKey]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Namespace: object.Meta.GetNamespace(),
+		Name:      name,
+	}}}
+}
+
+// deleteBackupCronJob deletes the backup CronJob along with its Jobs once the schedule is removed
+func (reconciler *ReconcileRedis) deleteBackupCronJob(ctx context.Context, r *k8sv1alpha1.Redis) error {
+	cronJob := reconciler.newBackupCronJob()
+	if err := reconciler.client.Get(ctx, types.NamespacedName{
+		Namespace: r.GetNamespace(),
+		Name:      generateBackupCronJobName(r),
+	}, cronJob); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to fetch CronJob: %s", err)
+	}
+
+	if err := reconciler.client.Delete(ctx, cronJob,
+		client.PropagationPolicy(metav1.DeletePropagationBackground),
+	); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete CronJob: %s", err)
+	}
+	return nil
+}
+
+// checkScheduledBackup updates the status with the outcome of the scheduled backup Jobs.
+// Only the Jobs kept in the CronJob history are seen, the times of the older backups are preserved in the status.
+// The CronJob created recently may not be cached yet, requeue is true then.
+func (reconciler *ReconcileRedis) checkScheduledBackup(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	status *k8sv1alpha1.RedisStatus,
+) (requeue bool, err error) {
+	if !backupScheduled(r) {
+		status.ScheduledBackup = nil
+		return false, nil
+	}
+
+	object := reconciler.newBackupCronJob()
+	if err := reconciler.client.Get(ctx, types.NamespacedName{
+		Namespace: r.GetNamespace(),
+		Name:      generateBackupCronJobName(r),
+	}, object); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to fetch CronJob: %s", err)
+	}
+	cronJob, err := typedCronJob(object)
+	if err != nil {
+		return false, err
+	}
+
+	jobList := new(batchv1.JobList)
+	if err := reconciler.client.List(ctx, jobList,
+		client.InNamespace(r.GetNamespace()),
+		client.MatchingLabels{scheduledBackupLabelKey: r.GetName()},
+	); err != nil {
+		return false, fmt.Errorf("failed to list Jobs: %s", err)
+	}
+
+	scheduledBackup := new(k8sv1alpha1.ScheduledBackupStatus)
+	if status.ScheduledBackup != nil {
+		scheduledBackup = status.ScheduledBackup.DeepCopy()
+	}
+	scheduledBackup.LastScheduleTime = cronJob.Status.LastScheduleTime
+	updateScheduledBackupStatus(scheduledBackup, jobList.Items)
+	status.ScheduledBackup = scheduledBackup
+	return false, nil
+}
+
+// typedCronJob returns the CronJob of either version as batch/v1beta1, the fields the operator sets and reads
+// are the same in batch/v1
+func typedCronJob(object runtime.Object) (*batchv1beta1.CronJob, error) {
+	u, ok := object.(*unstructured.Unstructured)
+	if !ok {
+		return object.(*batchv1beta1.CronJob), nil
+	}
+	cronJob := new(batchv1beta1.CronJob)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, cronJob); err != nil {
+		return nil, fmt.Errorf("failed to decode CronJob: %s", err)
+	}
+	return cronJob, nil
+}
+
+// updateScheduledBackupStatus reflects the state of the latest Job and the latest success and failure times in status
+func updateScheduledBackupStatus(status *k8sv1alpha1.ScheduledBackupStatus, jobs []batchv1.Job) {
+	var latest *batchv1.Job
+	for i := range jobs {
+		if latest == nil || latest.CreationTimestamp.Before(&jobs[i].CreationTimestamp) {
+			latest = &jobs[i]
+		}
+
+		phase, completionTime, message := jobPhase(&jobs[i])
+		switch phase {
+		case k8sv1alpha1.BackupPhaseSucceeded:
+			if status.LastSuccessTime == nil || status.LastSuccessTime.Before(completionTime) {
+				status.LastSuccessTime = completionTime
+			}
+		case k8sv1alpha1.BackupPhaseFailed:
+			if status.LastFailureTime == nil || status.LastFailureTime.Before(completionTime) {
+				status.LastFailureTime, status.Message = completionTime, message
+			}
+		}
+	}
+
+	if latest != nil {
+		status.LastJobName = latest.GetName()
+		status.LastPhase, _, _ = jobPhase(latest)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// fieldManager is the manager of the fields the operator applies. It is the manager the operator binary
+// has been recorded as by the API server for the objects created and updated before the server-side apply.
+const fieldManager = "redis-operator"
+
+// applyOptions claim the fields of the generated objects, the values set by the other managers are overridden
+var applyOptions = []client.PatchOption{client.FieldOwner(fieldManager), client.ForceOwnership}
+
+// applyConfiguration returns the generated object to be applied: an unstructured object of the kind registered
+// in the scheme without the fields the operator does not generate, so only the generated fields are claimed.
+// The load balancer class and the retention policy are set bypassing the typed objects of the vendored API.
+func applyConfiguration(
+	scheme *runtime.Scheme,
+	object runtime.Object,
+	loadBalancerClass *string,
+	retentionPolicy map[string]interface{},
+) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(object, scheme)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object.DeepCopyObject())
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	// the zero values of the typed objects would claim the fields set by the API server
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "status")
+
+	if loadBalancerClass != nil {
+		if err := unstructured.SetNestedField(u.Object, *loadBalancerClass, loadBalancerClassField...); err != nil {
+			return nil, err
+		}
+	}
+	if retentionPolicy != nil {
+		if err := unstructured.SetNestedField(u.Object, retentionPolicy, retentionPolicyField...); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// upgradeManagedFields hands the fields the operator has created and updated the object with over to the apply
+// of the fieldManager, otherwise the fields it stops generating would be kept as owned by the former manager.
+// It reports whether the managed fields are changed, the object already applied by the operator is left intact.
+func upgradeManagedFields(object metav1.Object, apiVersion string) bool {
+	managedFields := object.GetManagedFields()
+	for _, entry := range managedFields {
+		if entry.Manager == fieldManager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			return false
+		}
+	}
+	for i, entry := range managedFields {
+		// a manager applies the fields of a single API version
+		if entry.Manager == fieldManager && entry.Operation == metav1.ManagedFieldsOperationUpdate &&
+			entry.APIVersion == apiVersion {
+			managedFields[i].Operation = metav1.ManagedFieldsOperationApply
+			object.SetManagedFields(managedFields)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func Test_applyConfiguration(t *testing.T) {
+	class := "example.com/internal"
+	policy := map[string]interface{}{"whenDeleted": "Delete", "whenScaled": "Retain"}
+	r := new(k8sv1alpha1.Redis)
+
+	service, err := applyConfiguration(scheme.Scheme, generateService(r, serviceTypeMaster), &class, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.GetAPIVersion() != "v1" || service.GetKind() != "Service" || service.GetName() == "" {
+		t.Errorf("applyConfiguration() = %v", service.Object)
+	}
+	if got, _, _ := unstructured.NestedString(service.Object, loadBalancerClassField...); got != class {
+		t.Errorf("applyConfiguration() class = %q, want %q", got, class)
+	}
+	for _, field := range [][]string{{"status"}, {"metadata", "creationTimestamp"}} {
+		if _, ok, _ := unstructured.NestedFieldNoCopy(service.Object, field...); ok {
+			t.Errorf("applyConfiguration() sets %v", field)
+		}
+	}
+
+	statefulSet, err := applyConfiguration(scheme.Scheme, generateStatefulSet(r, objectGeneratorOptions{}), nil, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statefulSet.GetAPIVersion() != "apps/v1" || statefulSet.GetKind() != "StatefulSet" {
+		t.Errorf("applyConfiguration() = %v", statefulSet.Object)
+	}
+	if got, _, _ := unstructured.NestedMap(statefulSet.Object, retentionPolicyField...); !reflect.DeepEqual(got, policy) {
+		t.Errorf("applyConfiguration() policy = %v, want %v", got, policy)
+	}
+
+	// the unstructured objects are applied as a copy
+	generated := generateNodeLocalCacheService(r)
+	if _, err := applyConfiguration(scheme.Scheme, generated, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if generated.GetKind() != "Service" {
+		t.Errorf("applyConfiguration() modified the generated object: %v", generated.Object)
+	}
+}
+
+func Test_upgradeManagedFields(t *testing.T) {
+	updated := metav1.ManagedFieldsEntry{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1"}
+	applied := metav1.ManagedFieldsEntry{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply, APIVersion: "v1"}
+	other := metav1.ManagedFieldsEntry{Manager: "istio", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1"}
+	legacy := metav1.ManagedFieldsEntry{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1beta1"}
+
+	tests := []struct {
+		name          string
+		managedFields []metav1.ManagedFieldsEntry
+		want          bool
+		wantFields    []metav1.ManagedFieldsEntry
+	}{
+		{"created before apply", []metav1.ManagedFieldsEntry{updated, other}, true, []metav1.ManagedFieldsEntry{applied, other}},
+		{"applied", []metav1.ManagedFieldsEntry{applied, other}, false, []metav1.ManagedFieldsEntry{applied, other}},
+		{"updated after apply", []metav1.ManagedFieldsEntry{applied, updated}, false, []metav1.ManagedFieldsEntry{applied, updated}},
+		{"other API version", []metav1.ManagedFieldsEntry{legacy}, false, []metav1.ManagedFieldsEntry{legacy}},
+		{"other managers", []metav1.ManagedFieldsEntry{other}, false, []metav1.ManagedFieldsEntry{other}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object := &metav1.ObjectMeta{ManagedFields: append([]metav1.ManagedFieldsEntry(nil), tt.managedFields...)}
+			if got := upgradeManagedFields(object, "v1"); got != tt.want {
+				t.Errorf("upgradeManagedFields() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(object.ManagedFields, tt.wantFields) {
+				t.Errorf("upgradeManagedFields() managed fields = %v, want %v", object.ManagedFields, tt.wantFields)
+			}
+		})
+	}
+}
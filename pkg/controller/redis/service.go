@@ -0,0 +1,200 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// loadBalancerClassField is the path of the Service field introduced in Kubernetes 1.21.
+// The vendored core/v1 API predates it, hence it is written bypassing the typed Service.
+var loadBalancerClassField = []string{"spec", "loadBalancerClass"}
+
+// resourceVersionPath is the JSON pointer of the resource version tested by the Service spec patch
+const resourceVersionPath = "/metadata/resourceVersion"
+
+// loadBalancerClass returns the load balancer class of the master Service or nil if it is not a classed LoadBalancer
+func loadBalancerClass(r *k8sv1alpha1.Redis, options objectGeneratorOptions) *string {
+	if options.serviceType != serviceTypeMaster || r.Spec.Service == nil ||
+		r.Spec.Service.Type != corev1.ServiceTypeLoadBalancer {
+		return nil
+	}
+	return r.Spec.Service.LoadBalancerClass
+}
+
+// serverLoadBalancerClass reads the load balancer class of the Service from the API server, the typed Service drops it.
+// The class is immutable while the Service is a LoadBalancer, hence the class set on the server is applied as is.
+func serverLoadBalancerClass(ctx context.Context, reader client.Reader, service *corev1.Service) (*string, error) {
+	u := new(unstructured.Unstructured)
+	u.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: service.Namespace, Name: service.Name}, u); err != nil {
+		return nil, err
+	}
+	class, ok, err := unstructured.NestedString(u.Object, loadBalancerClassField...)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &class, nil
+}
+
+// desiredServiceSpec returns the generated Service spec along with the fields assigned by the cluster to the existing
+// Service: the cluster IP, the IP family, the node ports and the health check node port unless generated,
+// and the defaults set by the API server. The fields neither generated nor assigned are not desired.
+func desiredServiceSpec(got, want corev1.ServiceSpec) corev1.ServiceSpec {
+	spec := *want.DeepCopy()
+	if spec.Type == "" {
+		spec.Type = corev1.ServiceTypeClusterIP
+	}
+	if spec.ClusterIP == "" {
+		spec.ClusterIP = got.ClusterIP
+	}
+	if spec.IPFamily == nil {
+		spec.IPFamily = got.IPFamily
+	}
+	if spec.SessionAffinity == "" {
+		spec.SessionAffinity = corev1.ServiceAffinityNone
+	}
+	if spec.SessionAffinity == corev1.ServiceAffinityClientIP && spec.SessionAffinityConfig == nil {
+		spec.SessionAffinityConfig = got.SessionAffinityConfig
+	}
+
+	// the node ports and the external traffic policy are allocated to NodePort and LoadBalancer Services only
+	nodePorts := spec.Type == corev1.ServiceTypeNodePort || spec.Type == corev1.ServiceTypeLoadBalancer
+	if nodePorts && spec.ExternalTrafficPolicy == "" {
+		spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+	}
+	if spec.Type == corev1.ServiceTypeLoadBalancer && spec.HealthCheckNodePort == 0 &&
+		spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal {
+		spec.HealthCheckNodePort = got.HealthCheckNodePort
+	}
+	for i := range spec.Ports {
+		port := &spec.Ports[i]
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		if port.TargetPort == (intstr.IntOrString{}) {
+			port.TargetPort = intstr.FromInt(int(port.Port))
+		}
+		if !nodePorts || port.NodePort != 0 {
+			continue
+		}
+		for _, allocated := range got.Ports {
+			if allocated.Port == port.Port && allocated.Protocol == port.Protocol {
+				port.NodePort = allocated.NodePort
+			}
+		}
+	}
+	return spec
+}
+
+// serviceSpecPatch returns the JSON patch replacing the fields of the Service spec that differ from the desired spec
+// and removing the fields not desired, nil if the spec has not drifted. Unlike the apply it reverts the changes
+// of the fields claimed by the other managers, e.g. the ports added manually. The fields unknown to the vendored API,
+// e.g. clusterIPs, ipFamilies and ipFamilyPolicy of Kubernetes 1.20, are left intact, except for the load balancer
+// class added once the Service becomes a LoadBalancer. The patch tests the resource version of the Service first,
+// so the Service changed since it was read is not patched, see serviceChanged.
+func serviceSpecPatch(got, want *corev1.Service, loadBalancerClass *string) (client.Patch, error) {
+	gotSpec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&got.Spec)
+	if err != nil {
+		return nil, err
+	}
+	wantSpec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&want.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []jsonPatchOperation
+	for _, field := range sortedKeys(gotSpec, wantSpec) {
+		value, desired := wantSpec[field]
+		current, set := gotSpec[field]
+		switch {
+		case !desired:
+			operations = append(operations, jsonPatchOperation{Op: "remove", Path: "/spec/" + field})
+		case !set:
+			operations = append(operations, jsonPatchOperation{Op: "add", Path: "/spec/" + field, Value: value})
+		case !equality.Semantic.DeepEqual(current, value):
+			operations = append(operations, jsonPatchOperation{Op: "replace", Path: "/spec/" + field, Value: value})
+		}
+	}
+	if len(operations) == 0 {
+		return nil, nil
+	}
+	operations = append([]jsonPatchOperation{{
+		Op:    "test",
+		Path:  resourceVersionPath,
+		Value: got.ResourceVersion,
+	}}, operations...)
+	if loadBalancerClass != nil && got.Spec.Type != corev1.ServiceTypeLoadBalancer &&
+		want.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		operations = append(operations, jsonPatchOperation{
+			Op:    "add",
+			Path:  "/" + strings.Join(loadBalancerClassField, "/"),
+			Value: *loadBalancerClass,
+		})
+	}
+
+	data, err := json.Marshal(operations)
+	if err != nil {
+		return nil, err
+	}
+	return client.RawPatch(types.JSONPatchType, data), nil
+}
+
+// serviceChanged reports whether the Service has changed on the server since it was read.
+// The patch of serviceSpecPatch failing the resource version test is rejected as invalid rather than conflicting.
+func serviceChanged(ctx context.Context, reader client.Reader, service *corev1.Service) (bool, error) {
+	current := new(corev1.Service)
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: service.Namespace, Name: service.Name}, current); err != nil {
+		return false, err
+	}
+	return current.ResourceVersion != service.ResourceVersion, nil
+}
+
+// jsonPatchOperation is an operation of the JSON patch, RFC 6902
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// sortedKeys returns the keys of the maps in order
+func sortedKeys(maps ...map[string]interface{}) []string {
+	set := make(map[string]bool)
+	for _, m := range maps {
+		for k := range m {
+			set[k] = true
+		}
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// serviceBindingSecretType is the type of the binding Secret as recommended by the Service Binding specification
+const serviceBindingSecretType corev1.SecretType = "servicebinding.io/redis"
+
+// the well-known entries of the binding Secret, ssl is read by Spring Cloud Bindings
+const (
+	serviceBindingTypeKey     = "type"
+	serviceBindingProviderKey = "provider"
+	serviceBindingHostKey     = "host"
+	serviceBindingPortKey     = "port"
+	serviceBindingPasswordKey = "password"
+	serviceBindingURIKey      = "uri"
+	serviceBindingSSLKey      = "ssl"
+)
+
+// generateServiceBindingName returns the name of the binding Secret
+func generateServiceBindingName(r *k8sv1alpha1.Redis) string {
+	return fmt.Sprintf("%s-binding", generateName(r))
+}
+
+// generateServiceBinding returns the Secret the workloads bind to with the Service Binding Operator
+// or the frameworks implementing the specification. The master Service is bound to.
+func generateServiceBinding(r *k8sv1alpha1.Redis, password, clusterDomain string) *corev1.Secret {
+	host := serviceFQDN(r, generateMasterServiceName(r), clusterDomain)
+	port := strconv.Itoa(redisPort(r))
+
+	uri := url.URL{Scheme: "redis", Host: net.JoinHostPort(host, port)}
+	if r.Spec.TLS != nil {
+		uri.Scheme = "rediss"
+	}
+	data := map[string][]byte{
+		serviceBindingTypeKey:     []byte("redis"),
+		serviceBindingProviderKey: []byte("redis-operator"),
+		serviceBindingHostKey:     []byte(host),
+		serviceBindingPortKey:     []byte(port),
+		serviceBindingSSLKey:      []byte(strconv.FormatBool(r.Spec.TLS != nil)),
+	}
+	if password != "" {
+		data[serviceBindingPasswordKey] = []byte(password)
+		uri.User = url.UserPassword("", password)
+	}
+	data[serviceBindingURIKey] = []byte(uri.String())
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generateServiceBindingName(r),
+			Namespace: r.GetNamespace(),
+			Labels:    r.GetLabels(),
+		},
+		Type: serviceBindingSecretType,
+		Data: data,
+	}
+}
+
+// serviceBindingStatus returns the binding Secret reference exposed in the status,
+// nil if the binding Secret is not generated
+func serviceBindingStatus(r *k8sv1alpha1.Redis) *corev1.LocalObjectReference {
+	if !r.Spec.ServiceBinding {
+		return nil
+	}
+	return &corev1.LocalObjectReference{Name: generateServiceBindingName(r)}
+}
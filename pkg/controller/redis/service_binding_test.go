@@ -0,0 +1,89 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_generateServiceBinding(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     k8sv1alpha1.RedisSpec
+		password string
+		want     map[string]string
+	}{
+		{
+			"no password",
+			k8sv1alpha1.RedisSpec{},
+			"",
+			map[string]string{
+				"type":     "redis",
+				"provider": "redis-operator",
+				"host":     "redis-example-master.default.svc.cluster.local",
+				"port":     "6379",
+				"ssl":      "false",
+				"uri":      "redis://redis-example-master.default.svc.cluster.local:6379",
+			},
+		},
+		{
+			"TLS with password",
+			k8sv1alpha1.RedisSpec{Port: 7000, TLS: &k8sv1alpha1.TLS{SecretName: "redis-tls"}},
+			"p@ss:w/rd",
+			map[string]string{
+				"type":     "redis",
+				"provider": "redis-operator",
+				"host":     "redis-example-master.default.svc.cluster.local",
+				"port":     "7000",
+				"ssl":      "true",
+				"password": "p@ss:w/rd",
+				"uri":      "rediss://:p%40ss%3Aw%2Frd@redis-example-master.default.svc.cluster.local:7000",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}, Spec: tt.spec}
+			got := generateServiceBinding(r, tt.password, defaultClusterDomain)
+			if got.Name != "redis-example-binding" || got.Type != serviceBindingSecretType {
+				t.Errorf("generateServiceBinding() = %s of type %s, want redis-example-binding of type %s",
+					got.Name, got.Type, serviceBindingSecretType)
+			}
+			if len(got.Data) != len(tt.want) {
+				t.Errorf("generateServiceBinding() keys = %d, want %d", len(got.Data), len(tt.want))
+			}
+			for k, v := range tt.want {
+				if string(got.Data[k]) != v {
+					t.Errorf("generateServiceBinding() %s = %q, want %q", k, got.Data[k], v)
+				}
+			}
+		})
+	}
+}
+
+func Test_serviceBindingStatus(t *testing.T) {
+	r := &k8sv1alpha1.Redis{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+	if got := serviceBindingStatus(r); got != nil {
+		t.Errorf("serviceBindingStatus() = %v, want nil", got)
+	}
+	r.Spec.ServiceBinding = true
+	if got := serviceBindingStatus(r); got == nil || got.Name != "redis-example-binding" {
+		t.Errorf("serviceBindingStatus() = %v, want redis-example-binding", got)
+	}
+}
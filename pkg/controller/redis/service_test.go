@@ -0,0 +1,230 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_generateService_type(t *testing.T) {
+	class := "example.com/internal"
+	r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{
+		Service: &k8sv1alpha1.Service{Type: corev1.ServiceTypeLoadBalancer, LoadBalancerClass: &class},
+	}}
+	tests := []struct {
+		name        string
+		serviceType int
+		want        corev1.ServiceType
+		wantClass   bool
+	}{
+		{"all", serviceTypeAll, corev1.ServiceTypeClusterIP, false},
+		{"headless", serviceTypeHeadless, corev1.ServiceTypeClusterIP, false},
+		{"master", serviceTypeMaster, corev1.ServiceTypeLoadBalancer, true},
+		{"replica", serviceTypeReplica, corev1.ServiceTypeClusterIP, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generateService(r, tt.serviceType).Spec.Type; got != tt.want {
+				t.Errorf("generateService() type = %v, want %v", got, tt.want)
+			}
+			if got := loadBalancerClass(r, objectGeneratorOptions{serviceType: tt.serviceType}); (got != nil) != tt.wantClass {
+				t.Errorf("loadBalancerClass() = %v, want class %v", got, tt.wantClass)
+			}
+		})
+	}
+}
+
+func Test_generateService_replica(t *testing.T) {
+	exclude, include := true, false
+	tests := []struct {
+		name           string
+		replicaService *k8sv1alpha1.ReplicaService
+		want           map[string]string
+	}{
+		{"default", nil, map[string]string{redisName: "example", roleLabelKey: replicaLabel}},
+		{"master excluded", &k8sv1alpha1.ReplicaService{ExcludeMaster: &exclude}, map[string]string{redisName: "example", roleLabelKey: replicaLabel}},
+		{"master included", &k8sv1alpha1.ReplicaService{ExcludeMaster: &include}, map[string]string{redisName: "example"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &k8sv1alpha1.Redis{
+				ObjectMeta: metav1.ObjectMeta{Name: "example", Labels: map[string]string{redisName: "example"}},
+				Spec:       k8sv1alpha1.RedisSpec{ReplicaService: tt.replicaService},
+			}
+			service := generateService(r, serviceTypeReplica)
+			if service.Name != "redis-example-replica" {
+				t.Errorf("generateService() name = %s", service.Name)
+			}
+			if !mapsEqual(service.Spec.Selector, tt.want) {
+				t.Errorf("generateService() selector = %v, want %v", service.Spec.Selector, tt.want)
+			}
+			// the ServiceMonitor does not select the replica Service
+			if service.Labels[roleLabelKey] != replicaLabel {
+				t.Errorf("generateService() labels = %v", service.Labels)
+			}
+		})
+	}
+}
+
+func Test_serviceUpdateNeeded_type(t *testing.T) {
+	got := generateService(&k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{
+		Service: &k8sv1alpha1.Service{Type: corev1.ServiceTypeLoadBalancer},
+	}}, serviceTypeMaster)
+	// allocated by the API server
+	got.Spec.Ports[0].NodePort = 30379
+	got.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+	want := generateService(new(k8sv1alpha1.Redis), serviceTypeMaster)
+
+	if !serviceUpdateNeeded(got, want) {
+		t.Fatal("serviceUpdateNeeded() = false, want true")
+	}
+	if got.Spec.Type != corev1.ServiceTypeClusterIP || got.Spec.Ports[0].NodePort != 0 || got.Spec.ExternalTrafficPolicy != "" {
+		t.Errorf("serviceUpdateNeeded() left LoadBalancer fields: %+v", got.Spec)
+	}
+	if serviceUpdateNeeded(got, want) {
+		t.Error("serviceUpdateNeeded() = true after update, want false")
+	}
+}
+
+func Test_serviceUpdateNeeded_drift(t *testing.T) {
+	r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{Service: &k8sv1alpha1.Service{Type: corev1.ServiceTypeNodePort}}}
+	ipv4 := corev1.IPv4Protocol
+	// assigned by the cluster
+	assigned := func() *corev1.Service {
+		service := generateService(r, serviceTypeMaster)
+		service.Spec.ClusterIP = "10.0.0.1"
+		service.Spec.IPFamily = &ipv4
+		service.Spec.SessionAffinity = corev1.ServiceAffinityNone
+		service.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+		service.Spec.Ports[0].NodePort = 30379
+		return service
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*corev1.Service)
+		want   bool
+		// the node port of the removed port is allocated anew
+		reallocated bool
+	}{
+		{"assigned fields", func(*corev1.Service) {}, false, false},
+		{"session affinity", func(s *corev1.Service) {
+			s.Spec.SessionAffinity = corev1.ServiceAffinityClientIP
+		}, true, false},
+		{"external traffic policy", func(s *corev1.Service) {
+			s.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
+		}, true, false},
+		{"added port", func(s *corev1.Service) {
+			s.Spec.Ports = append(s.Spec.Ports, corev1.ServicePort{Name: "debug", Protocol: corev1.ProtocolTCP, Port: 6380})
+		}, true, false},
+		{"removed port", func(s *corev1.Service) {
+			s.Spec.Ports = nil
+		}, true, true},
+		{"target port", func(s *corev1.Service) {
+			s.Spec.Ports[0].TargetPort.IntVal = 6380
+		}, true, false},
+		{"selector", func(s *corev1.Service) {
+			s.Spec.Selector = map[string]string{"app": "other"}
+		}, true, false},
+		{"load balancer source ranges", func(s *corev1.Service) {
+			s.Spec.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+		}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assigned()
+			tt.modify(got)
+			if needed := serviceUpdateNeeded(got, generateService(r, serviceTypeMaster)); needed != tt.want {
+				t.Fatalf("serviceUpdateNeeded() = %v, want %v", needed, tt.want)
+			}
+			want := assigned()
+			if tt.reallocated {
+				want.Spec.Ports[0].NodePort = 0
+			}
+			if !reflect.DeepEqual(got.Spec, want.Spec) {
+				t.Errorf("serviceUpdateNeeded() spec = %+v, want %+v", got.Spec, want.Spec)
+			}
+		})
+	}
+}
+
+func Test_serviceSpecPatch(t *testing.T) {
+	class := "example.com/internal"
+	r := &k8sv1alpha1.Redis{Spec: k8sv1alpha1.RedisSpec{Service: &k8sv1alpha1.Service{Type: corev1.ServiceTypeLoadBalancer}}}
+	clusterIP := generateService(new(k8sv1alpha1.Redis), serviceTypeMaster)
+	clusterIP.Spec.SessionAffinity = corev1.ServiceAffinityNone
+	loadBalancer := generateService(r, serviceTypeMaster)
+	loadBalancer.Spec.SessionAffinity = corev1.ServiceAffinityNone
+	loadBalancer.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+	loadBalancer.ResourceVersion = "42"
+	withSourceRanges := loadBalancer.DeepCopy()
+	withSourceRanges.Spec.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+
+	tests := []struct {
+		name     string
+		got      *corev1.Service
+		want     *corev1.Service
+		class    *string
+		wantOps  []string
+		wantNone bool
+	}{
+		{"no drift", loadBalancer, loadBalancer, &class, nil, true},
+		{"removed field", withSourceRanges, loadBalancer, nil, []string{
+			"test /metadata/resourceVersion", "remove /spec/loadBalancerSourceRanges",
+		}, false},
+		{"becomes load balancer", clusterIP, loadBalancer, &class, []string{
+			"test /metadata/resourceVersion", "add /spec/externalTrafficPolicy", "replace /spec/type", "add /spec/loadBalancerClass",
+		}, false},
+		{"becomes cluster IP", loadBalancer, clusterIP, nil, []string{
+			"test /metadata/resourceVersion", "remove /spec/externalTrafficPolicy", "replace /spec/type",
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := serviceSpecPatch(tt.got, tt.want, tt.class)
+			if err != nil {
+				t.Fatalf("serviceSpecPatch() error = %v", err)
+			}
+			if (patch == nil) != tt.wantNone {
+				t.Fatalf("serviceSpecPatch() = %v, want none %v", patch, tt.wantNone)
+			}
+			if patch == nil {
+				return
+			}
+			data, _ := patch.Data(tt.got)
+			var operations []jsonPatchOperation
+			if err := json.Unmarshal(data, &operations); err != nil {
+				t.Fatalf("invalid patch %s: %s", data, err)
+			}
+			var got []string
+			for _, operation := range operations {
+				got = append(got, operation.Op+" "+operation.Path)
+			}
+			if !reflect.DeepEqual(got, tt.wantOps) {
+				t.Errorf("serviceSpecPatch() = %v, want %v", got, tt.wantOps)
+			}
+			if operations[0].Value != tt.got.ResourceVersion {
+				t.Errorf("serviceSpecPatch() tests the resource version %v, want %v", operations[0].Value, tt.got.ResourceVersion)
+			}
+		})
+	}
+}
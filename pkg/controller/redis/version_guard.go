@@ -0,0 +1,132 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strconv"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/amaizfinance/redis-operator/pkg/redis"
+	"github.com/amaizfinance/redis-operator/pkg/registry"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// versionChangeError means that the image would downgrade the running instances or skip a major version
+type versionChangeError struct {
+	reason  string
+	message string
+}
+
+func (e *versionChangeError) Error() string {
+	return e.message
+}
+
+// checkVersionChange refuses the version change from the running versions to the version of the image:
+// the downgrades, since the newer RDB and AOF formats are not read by the older versions, and the jumps
+// over a major version. A *versionChangeError is returned if the change is refused. The running versions
+// are keyed by the instance, the instances with unknown versions are ignored.
+func checkVersionChange(running map[string]string, image string) error {
+	reference, err := registry.ParseReference(image)
+	if err != nil {
+		return nil
+	}
+	wanted, ok := registry.ParseVersion(reference.Tag)
+	if !ok {
+		return nil
+	}
+
+	instances := make([]string, 0, len(running))
+	for instance := range running {
+		instances = append(instances, instance)
+	}
+	sort.Strings(instances)
+	for _, instance := range instances {
+		version, ok := registry.ParseVersion(running[instance])
+		if !ok {
+			continue
+		}
+		if wanted.Less(version) {
+			return &versionChangeError{reason: k8sv1alpha1.ReasonDowngradeRefused, message: fmt.Sprintf(
+				"image %s would downgrade %s running Redis %s, set the %s annotation to true to allow it",
+				image, instance, running[instance], k8sv1alpha1.AllowVersionChangeAnnotation)}
+		}
+		if wanted.Major > version.Major+1 {
+			return &versionChangeError{reason: k8sv1alpha1.ReasonMajorVersionJumpRefused, message: fmt.Sprintf(
+				"image %s would skip a major version of %s running Redis %s, upgrade to Redis %d first "+
+					"or set the %s annotation to true", image, instance, running[instance], version.Major+1,
+				k8sv1alpha1.AllowVersionChangeAnnotation)}
+		}
+	}
+	return nil
+}
+
+// guardVersionChange checks the version change before the StatefulSet is updated to another Redis image.
+// The versions are read from the ready instances, so the check is skipped unless the image changes.
+// A *versionChangeError is returned if the change is refused and not allowed by the AllowVersionChangeAnnotation.
+func (reconciler *ReconcileRedis) guardVersionChange(
+	ctx context.Context,
+	r *k8sv1alpha1.Redis,
+	options objectGeneratorOptions,
+	tlsConfig *tls.Config,
+) error {
+	if allowed, err := strconv.ParseBool(r.GetAnnotations()[k8sv1alpha1.AllowVersionChangeAnnotation]); err == nil && allowed {
+		return nil
+	}
+
+	statefulSet := new(appsv1.StatefulSet)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateName(r)}, statefulSet); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to fetch StatefulSet: %s", err)
+	}
+	image := r.Spec.Redis.ImageReference()
+	for _, container := range statefulSet.Spec.Template.Spec.Containers {
+		if container.Name == redisName && container.Image == image {
+			return nil
+		}
+	}
+
+	addresses, err := reconciler.readyAddresses(ctx, r)
+	if err != nil {
+		return err
+	}
+	var username string
+	if defaultUserDisabled(r) || options.defaultUserDisabled {
+		username = redis.OperatorUser
+	}
+	versions, err := redis.Versions(ctx, redis.Options{
+		Password:   options.password,
+		Username:   username,
+		TLSConfig:  tlsConfig,
+		ClientName: reconciler.options.RedisClientName,
+		Protocol:   reconciler.options.RedisProtocol,
+	}, addresses...)
+	if err != nil {
+		return err
+	}
+	running := make(map[string]string, len(versions))
+	for address, version := range versions {
+		running[address.String()] = version
+	}
+	return checkVersionChange(running, r.Spec.Redis.Image)
+}
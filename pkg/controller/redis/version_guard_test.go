@@ -0,0 +1,53 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_checkVersionChange(t *testing.T) {
+	running := map[string]string{"172.18.0.2:6379": "6.0.9", "172.18.0.3:6379": "6.0.9"}
+	tests := []struct {
+		name       string
+		image      string
+		wantReason string
+	}{
+		{"same", "redis:6.0.9-alpine", ""},
+		{"patch", "redis:6.0.10", ""},
+		{"next major", "redis:7.0.0", ""},
+		{"not a version", "redis:latest", ""},
+		{"pinned", "redis:6.2.1@sha256:0000000000000000000000000000000000000000000000000000000000000000", ""},
+		{"downgrade", "redis:5.0.10", k8sv1alpha1.ReasonDowngradeRefused},
+		{"patch downgrade", "redis:6.0.8", k8sv1alpha1.ReasonDowngradeRefused},
+		{"major jump", "redis:8.0.0", k8sv1alpha1.ReasonMajorVersionJumpRefused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkVersionChange(running, tt.image)
+			var reason string
+			if e, ok := err.(*versionChangeError); ok {
+				reason = e.reason
+			} else if err != nil {
+				t.Fatalf("checkVersionChange() error = %v", err)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("checkVersionChange() reason = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// volumeExpansionError means that the StorageClass of the claim does not allow the volume expansion
+type volumeExpansionError struct {
+	claim string
+	class string
+}
+
+func (e *volumeExpansionError) Error() string {
+	return fmt.Sprintf("StorageClass %q of PersistentVolumeClaim %s does not allow volume expansion", e.class, e.claim)
+}
+
+// storageRequest returns the storage request of the claim, zero if not set
+func storageRequest(claim *corev1.PersistentVolumeClaim) resource.Quantity {
+	return claim.Spec.Resources.Requests[corev1.ResourceStorage]
+}
+
+// claimTemplateStorage returns the storage request of the StatefulSet volume claim template, zero if not found
+func claimTemplateStorage(s *appsv1.StatefulSet, name string) resource.Quantity {
+	for i := range s.Spec.VolumeClaimTemplates {
+		if s.Spec.VolumeClaimTemplates[i].Name == name {
+			return storageRequest(&s.Spec.VolumeClaimTemplates[i])
+		}
+	}
+	return resource.Quantity{}
+}
+
+// dataVolumeClaimName returns the name of the claim the StatefulSet creates for the Pod out of the data volume claim template
+func dataVolumeClaimName(r *k8sv1alpha1.Redis, ordinal int32) string {
+	return fmt.Sprintf("%s-%s-%d", r.Spec.DataVolumeClaimTemplate.Name, generateName(r), ordinal)
+}
+
+// volumeExpansionAllowed reports whether the volumes of the class can be expanded.
+// The claims bound without a class can not be expanded.
+func volumeExpansionAllowed(class *storagev1.StorageClass) bool {
+	return class != nil && class.AllowVolumeExpansion != nil && *class.AllowVolumeExpansion
+}
+
+// expandDataVolumes expands the data volume claims of the StatefulSet Pods to the storage request of
+// the data volume claim template once it grows, the volume claim templates of the StatefulSet are immutable.
+// The StatefulSet is deleted orphaning the Pods and the claims to be recreated with the grown template afterwards
+// if configured so. A *volumeExpansionError is returned if a StorageClass does not allow the expansion.
+func (reconciler *ReconcileRedis) expandDataVolumes(ctx context.Context, r *k8sv1alpha1.Redis) (reconcile.Result, error) {
+	template := &r.Spec.DataVolumeClaimTemplate
+	want := storageRequest(template)
+	if template.Name == "" || want.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	statefulSet := new(appsv1.StatefulSet)
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: generateName(r)},
+		statefulSet); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to fetch StatefulSet: %s", err)
+	}
+	if current := claimTemplateStorage(statefulSet, template.Name); current.IsZero() || current.Cmp(want) >= 0 {
+		return reconcile.Result{}, nil
+	}
+
+	classes := make(map[string]*storagev1.StorageClass)
+	for ordinal := int32(0); ordinal < *statefulSet.Spec.Replicas; ordinal++ {
+		claim := new(corev1.PersistentVolumeClaim)
+		if err := reconciler.client.Get(ctx, types.NamespacedName{
+			Namespace: r.GetNamespace(),
+			Name:      dataVolumeClaimName(r, ordinal),
+		}, claim); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return reconcile.Result{}, fmt.Errorf("failed to fetch PersistentVolumeClaim: %s", err)
+		}
+		if current := storageRequest(claim); current.Cmp(want) >= 0 {
+			continue
+		}
+
+		var className string
+		if claim.Spec.StorageClassName != nil {
+			className = *claim.Spec.StorageClassName
+		}
+		class, ok := classes[className]
+		if !ok && className != "" {
+			// the StorageClasses are cluster-scoped, the cache is restricted to the watched namespaces
+			var err error
+			if class, err = reconciler.kubeClient.StorageV1().StorageClasses().Get(ctx, className, metav1.GetOptions{}); err != nil {
+				if !errors.IsNotFound(err) {
+					return reconcile.Result{}, fmt.Errorf("failed to fetch StorageClass: %s", err)
+				}
+				class = nil
+			}
+			classes[className] = class
+		}
+		if !volumeExpansionAllowed(class) {
+			return reconcile.Result{}, &volumeExpansionError{claim: claim.Name, class: className}
+		}
+
+		patch := client.MergeFrom(claim.DeepCopy())
+		claim.Spec.Resources.Requests[corev1.ResourceStorage] = want
+		if err := reconciler.client.Patch(ctx, claim, patch); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to expand PersistentVolumeClaim %s: %s", claim.Name, err)
+		}
+		reconciler.recorder.Eventf(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonVolumeExpanded,
+			"Expanded PersistentVolumeClaim %s to %s", claim.Name, want.String())
+	}
+
+	if r.Spec.VolumeExpansion == nil || !r.Spec.VolumeExpansion.RecreateStatefulSet {
+		return reconcile.Result{}, nil
+	}
+	// the Pods and the claims are adopted by the recreated StatefulSet
+	if err := reconciler.client.Delete(ctx, statefulSet, client.PropagationPolicy(metav1.DeletePropagationOrphan),
+		client.Preconditions{UID: &statefulSet.UID}); err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, fmt.Errorf("failed to delete StatefulSet: %s", err)
+	}
+	reconciler.recorder.Eventf(r, corev1.EventTypeNormal, k8sv1alpha1.ReasonVolumeExpanded,
+		"Deleted StatefulSet %s orphaning the Pods to recreate it with the expanded volume claim template", statefulSet.Name)
+	return reconcile.Result{Requeue: true}, nil
+}
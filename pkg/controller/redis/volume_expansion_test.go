@@ -0,0 +1,88 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+func Test_claimTemplateStorage(t *testing.T) {
+	claim := func(name, storage string) corev1.PersistentVolumeClaim {
+		return corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(storage)},
+			}},
+		}
+	}
+	tests := []struct {
+		name      string
+		templates []corev1.PersistentVolumeClaim
+		want      string
+	}{
+		{"no templates", nil, "0"},
+		{"other template", []corev1.PersistentVolumeClaim{claim("other", "1Gi")}, "0"},
+		{"found", []corev1.PersistentVolumeClaim{claim("other", "1Gi"), claim("data", "2Gi")}, "2Gi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{VolumeClaimTemplates: tt.templates}}
+			if got := claimTemplateStorage(s, "data"); got.Cmp(resource.MustParse(tt.want)) != 0 {
+				t.Errorf("claimTemplateStorage() = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_dataVolumeClaimName(t *testing.T) {
+	r := &k8sv1alpha1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "example"},
+		Spec: k8sv1alpha1.RedisSpec{
+			DataVolumeClaimTemplate: corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data"}},
+		},
+	}
+	if got, want := dataVolumeClaimName(r, 2), "data-redis-example-2"; got != want {
+		t.Errorf("dataVolumeClaimName() = %s, want %s", got, want)
+	}
+}
+
+func Test_volumeExpansionAllowed(t *testing.T) {
+	allowed, denied := true, false
+	tests := []struct {
+		name  string
+		class *storagev1.StorageClass
+		want  bool
+	}{
+		{"no class", nil, false},
+		{"not set", &storagev1.StorageClass{}, false},
+		{"denied", &storagev1.StorageClass{AllowVolumeExpansion: &denied}, false},
+		{"allowed", &storagev1.StorageClass{AllowVolumeExpansion: &allowed}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := volumeExpansionAllowed(tt.class); got != tt.want {
+				t.Errorf("volumeExpansionAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
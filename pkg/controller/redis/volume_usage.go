@@ -0,0 +1,159 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+// statsSummary is an extract of the kubelet stats summary API response
+type statsSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		VolumeStats []struct {
+			PVCRef *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef,omitempty"`
+			CapacityBytes *uint64 `json:"capacityBytes,omitempty"`
+			UsedBytes     *uint64 `json:"usedBytes,omitempty"`
+		} `json:"volume,omitempty"`
+	} `json:"pods"`
+}
+
+// volumeUsage is the usage of a data volume in percents
+type volumeUsage struct {
+	pod     string
+	percent uint64
+}
+
+// dataVolumeUsages extracts the usage of the data volumes of the pods from the kubelet stats summary.
+// Data volumes are the PVCs created by the StatefulSet out of the claim template, named <template>-<pod>.
+func dataVolumeUsages(summary []byte, namespace, claimTemplateName string, pods map[string]struct{}) ([]volumeUsage, error) {
+	stats := new(statsSummary)
+	if err := json.Unmarshal(summary, stats); err != nil {
+		return nil, fmt.Errorf("failed to decode stats summary: %s", err)
+	}
+
+	var usages []volumeUsage
+	for _, pod := range stats.Pods {
+		if _, ok := pods[pod.PodRef.Name]; !ok || pod.PodRef.Namespace != namespace {
+			continue
+		}
+		for _, volume := range pod.VolumeStats {
+			if volume.PVCRef == nil || volume.PVCRef.Name != fmt.Sprintf("%s-%s", claimTemplateName, pod.PodRef.Name) ||
+				volume.CapacityBytes == nil || volume.UsedBytes == nil || *volume.CapacityBytes == 0 {
+				continue
+			}
+			usages = append(usages, volumeUsage{pod: pod.PodRef.Name, percent: *volume.UsedBytes * 100 / *volume.CapacityBytes})
+		}
+	}
+	return usages, nil
+}
+
+// dataVolumeUsageCondition builds the DataVolumeUsageHigh condition
+func dataVolumeUsageCondition(usages []volumeUsage, threshold int32) k8sv1alpha1.Condition {
+	var exceeded []string
+	for _, usage := range usages {
+		if usage.percent >= uint64(threshold) {
+			exceeded = append(exceeded, fmt.Sprintf("%s: %d%%", usage.pod, usage.percent))
+		}
+	}
+	sort.Strings(exceeded)
+
+	if len(exceeded) == 0 {
+		return k8sv1alpha1.Condition{
+			Type:    k8sv1alpha1.ConditionDataVolumeUsageHigh,
+			Status:  corev1.ConditionFalse,
+			Reason:  k8sv1alpha1.ReasonDataVolumeUsageOK,
+			Message: fmt.Sprintf("data volume usage is below %d%%", threshold),
+		}
+	}
+	return k8sv1alpha1.Condition{
+		Type:    k8sv1alpha1.ConditionDataVolumeUsageHigh,
+		Status:  corev1.ConditionTrue,
+		Reason:  k8sv1alpha1.ReasonDataVolumeUsageHigh,
+		Message: fmt.Sprintf("data volume usage exceeds %d%%: %s", threshold, strings.Join(exceeded, ", ")),
+	}
+}
+
+// checkDataVolumeUsage sets the DataVolumeUsageHigh condition and warns with an Event once the usage
+// exceeds the threshold
+func (reconciler *ReconcileRedis) checkDataVolumeUsage(
+	ctx context.Context,
+	redis *k8sv1alpha1.Redis,
+	status *k8sv1alpha1.RedisStatus,
+	pods []corev1.Pod,
+) {
+	condition := reconciler.dataVolumeUsage(ctx, redis, pods)
+	if previous := status.GetCondition(condition.Type); condition.Status == corev1.ConditionTrue &&
+		(previous == nil || previous.Status != corev1.ConditionTrue) {
+		reconciler.recorder.Event(redis, corev1.EventTypeWarning, condition.Reason, condition.Message)
+	}
+	status.SetCondition(condition)
+}
+
+// dataVolumeUsage collects the data volume usage of the pods from kubelets running them
+func (reconciler *ReconcileRedis) dataVolumeUsage(
+	ctx context.Context,
+	redis *k8sv1alpha1.Redis,
+	pods []corev1.Pod,
+) k8sv1alpha1.Condition {
+	threshold := *redis.Spec.DataVolumeUsageThreshold
+
+	// group pods by nodes in order to request stats from every node only once
+	podsByNode := make(map[string]map[string]struct{})
+	for i := range pods {
+		if pods[i].Spec.NodeName == "" {
+			continue
+		}
+		if _, ok := podsByNode[pods[i].Spec.NodeName]; !ok {
+			podsByNode[pods[i].Spec.NodeName] = make(map[string]struct{})
+		}
+		podsByNode[pods[i].Spec.NodeName][pods[i].Name] = struct{}{}
+	}
+
+	var usages []volumeUsage
+	for node, nodePods := range podsByNode {
+		summary, err := reconciler.kubeClient.CoreV1().RESTClient().Get().
+			Resource("nodes").Name(node).SubResource("proxy").Suffix("stats/summary").DoRaw(ctx)
+		if err == nil {
+			var nodeUsages []volumeUsage
+			nodeUsages, err = dataVolumeUsages(summary, redis.GetNamespace(), redis.Spec.DataVolumeClaimTemplate.Name, nodePods)
+			usages = append(usages, nodeUsages...)
+		}
+		if err != nil {
+			return k8sv1alpha1.Condition{
+				Type:    k8sv1alpha1.ConditionDataVolumeUsageHigh,
+				Status:  corev1.ConditionUnknown,
+				Reason:  k8sv1alpha1.ReasonDataVolumeStatsUnavailable,
+				Message: fmt.Sprintf("failed to get stats from node %s: %s", node, err),
+			}
+		}
+	}
+
+	return dataVolumeUsageCondition(usages, threshold)
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const statsSummaryJSON = `{
+  "node": {"nodeName": "node-1"},
+  "pods": [
+    {
+      "podRef": {"name": "redis-example-0", "namespace": "default"},
+      "volume": [
+        {"name": "redis-data", "pvcRef": {"name": "redis-data-redis-example-0", "namespace": "default"}, "capacityBytes": 1000, "usedBytes": 910},
+        {"name": "redis-example-config", "capacityBytes": 1000, "usedBytes": 1000}
+      ]
+    },
+    {
+      "podRef": {"name": "redis-example-1", "namespace": "default"},
+      "volume": [
+        {"name": "redis-data", "pvcRef": {"name": "redis-data-redis-example-1", "namespace": "default"}, "capacityBytes": 1000, "usedBytes": 100}
+      ]
+    },
+    {
+      "podRef": {"name": "redis-example-2", "namespace": "other"},
+      "volume": [
+        {"name": "redis-data", "pvcRef": {"name": "redis-data-redis-example-2", "namespace": "other"}, "capacityBytes": 1000, "usedBytes": 1000}
+      ]
+    }
+  ]
+}`
+
+func Test_dataVolumeUsages(t *testing.T) {
+	pods := map[string]struct{}{"redis-example-0": {}, "redis-example-1": {}, "redis-example-2": {}}
+
+	got, err := dataVolumeUsages([]byte(statsSummaryJSON), "default", "redis-data", pods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []volumeUsage{{"redis-example-0", 91}, {"redis-example-1", 10}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dataVolumeUsages()\nhave: %v\nwant: %v", got, want)
+	}
+
+	if _, err := dataVolumeUsages([]byte("<html>"), "default", "redis-data", pods); err == nil {
+		t.Error("dataVolumeUsages() expected error on invalid summary")
+	}
+}
+
+func Test_dataVolumeUsageCondition(t *testing.T) {
+	usages := []volumeUsage{{"redis-example-1", 10}, {"redis-example-0", 91}}
+	tests := []struct {
+		name        string
+		threshold   int32
+		wantStatus  corev1.ConditionStatus
+		wantMessage string
+	}{
+		{"below", 95, corev1.ConditionFalse, "data volume usage is below 95%"},
+		{"exceeded", 90, corev1.ConditionTrue, "data volume usage exceeds 90%: redis-example-0: 91%"},
+		{"all exceeded", 10, corev1.ConditionTrue, "data volume usage exceeds 10%: redis-example-0: 91%, redis-example-1: 10%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dataVolumeUsageCondition(usages, tt.threshold)
+			if got.Status != tt.wantStatus || got.Message != tt.wantMessage {
+				t.Errorf("dataVolumeUsageCondition() = %+v, want %s %q", got, tt.wantStatus, tt.wantMessage)
+			}
+		})
+	}
+}
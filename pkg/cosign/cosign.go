@@ -0,0 +1,247 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cosign verifies the cosign signatures of container images against public keys.
+// The signatures are read from the registry where cosign stores them next to the image,
+// as the layers of the sha256-<digest>.sig tag annotated with the signature of the layer payload.
+// Keyless signatures and the transparency log are not supported.
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amaizfinance/redis-operator/pkg/fips"
+	"github.com/amaizfinance/redis-operator/pkg/registry"
+)
+
+const (
+	// SignatureAnnotation is the annotation of the signature layer holding the base64 encoded signature
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// DefaultCacheTTL is the period a successful verification is cached for
+	DefaultCacheTTL = 24 * time.Hour
+)
+
+// VerificationError means that the image is not signed by any of the keys,
+// as opposed to the failure to read the signatures from the registry
+type VerificationError struct {
+	Image  string
+	Reason string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("signature verification of %s failed: %s", e.Image, e.Reason)
+}
+
+// PublicKey is a parsed public key along with its PEM encoding the verifications are cached by
+type PublicKey struct {
+	key crypto.PublicKey
+	pem string
+}
+
+// ParsePublicKeys parses the PEM encoded ECDSA, RSA or Ed25519 public keys, e.g. cosign.pub.
+// The Ed25519 keys and the RSA keys shorter than 2048 bits are rejected in the FIPS mode.
+func ParsePublicKeys(keys ...string) ([]PublicKey, error) {
+	publicKeys := make([]PublicKey, 0, len(keys))
+	for i, key := range keys {
+		block, _ := pem.Decode([]byte(key))
+		if block == nil {
+			return nil, fmt.Errorf("public key %d is not PEM encoded", i)
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %d: %s", i, err)
+		}
+		switch parsed.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		default:
+			return nil, fmt.Errorf("unsupported public key %d type %T", i, parsed)
+		}
+		if fips.Enabled {
+			if err := fips.CheckPublicKey(parsed); err != nil {
+				return nil, fmt.Errorf("public key %d is not allowed in the FIPS mode: %s", i, err)
+			}
+		}
+		publicKeys = append(publicKeys, PublicKey{key: parsed, pem: strings.TrimSpace(key)})
+	}
+	return publicKeys, nil
+}
+
+// verify checks the signature of the payload
+func (k PublicKey) verify(payload, signature []byte) bool {
+	digest := sha256.Sum256(payload)
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, signature)
+	}
+	return false
+}
+
+// manifest is the manifest of the signature tag
+type manifest struct {
+	Layers []struct {
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// payload is the simple signing payload signed by cosign
+type payload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// Verifier verifies the image signatures. Successful verifications are cached, hence the Verifier
+// should be reused. It is safe for concurrent use.
+type Verifier struct {
+	// Client is the HTTP client the registries are accessed with, http.DefaultClient if nil
+	Client *http.Client
+	// CacheTTL defaults to DefaultCacheTTL
+	CacheTTL time.Duration
+
+	mu       sync.Mutex
+	verified map[string]time.Time
+}
+
+// Verify checks that the image pinned by digest is signed by any of the keys.
+// credentials are the registry credentials by the registry host, anonymous access is used if missing.
+// A *VerificationError is returned if the signatures are missing or invalid.
+func (v *Verifier) Verify(ctx context.Context, image string, keys []PublicKey, credentials map[string]registry.Credentials) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("no public keys to verify %s with", image)
+	}
+	reference, err := registry.ParseReference(image)
+	if err != nil {
+		return &VerificationError{Image: image, Reason: err.Error()}
+	}
+	if reference.Digest == "" {
+		return &VerificationError{Image: image, Reason: "image is not pinned by digest"}
+	}
+
+	cacheKey := v.cacheKey(reference, keys)
+	if v.cached(cacheKey) {
+		return nil
+	}
+
+	client := registry.NewClient(v.Client, reference, credentials)
+	body, err := client.Manifest(ctx, signatureTag(reference.Digest))
+	if err == registry.ErrNotFound {
+		return &VerificationError{Image: image, Reason: "no signatures found"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch signatures of %s: %s", image, err)
+	}
+	signatures := new(manifest)
+	if err := json.Unmarshal(body, signatures); err != nil {
+		return fmt.Errorf("invalid signatures manifest of %s: %s", image, err)
+	}
+
+	for _, layer := range signatures.Layers {
+		encoded, ok := layer.Annotations[SignatureAnnotation]
+		if !ok {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+
+		blob, err := client.Blob(ctx, layer.Digest)
+		if err != nil {
+			return fmt.Errorf("failed to fetch signature payload of %s: %s", image, err)
+		}
+		sum := sha256.Sum256(blob)
+		if layer.Digest != "sha256:"+hex.EncodeToString(sum[:]) {
+			continue
+		}
+		// the signature of another digest might have been copied to the tag
+		p := new(payload)
+		if err := json.Unmarshal(blob, p); err != nil || p.Critical.Image.DockerManifestDigest != reference.Digest {
+			continue
+		}
+
+		for _, key := range keys {
+			if key.verify(blob, signature) {
+				v.cache(cacheKey)
+				return nil
+			}
+		}
+	}
+	return &VerificationError{Image: image, Reason: "no valid signature by the configured keys"}
+}
+
+// signatureTag returns the tag cosign stores the signatures of the digest at, e.g. sha256-0123....sig
+func signatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+// cacheKey identifies the verification of the image by the key set
+func (v *Verifier) cacheKey(reference registry.Reference, keys []PublicKey) string {
+	pems := make([]string, len(keys))
+	for i := range keys {
+		pems[i] = keys[i].pem
+	}
+	sort.Strings(pems)
+	// the tag does not matter once the digest is verified
+	reference.Tag = ""
+	return reference.String() + "\n" + strings.Join(pems, "\n")
+}
+
+func (v *Verifier) cached(key string) bool {
+	ttl := v.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	verifiedAt, ok := v.verified[key]
+	if ok && time.Since(verifiedAt) >= ttl {
+		delete(v.verified, key)
+		return false
+	}
+	return ok
+}
+
+func (v *Verifier) cache(key string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.verified == nil {
+		v.verified = make(map[string]time.Time)
+	}
+	v.verified[key] = time.Now()
+}
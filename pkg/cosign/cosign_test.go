@@ -0,0 +1,145 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// newKey returns the ECDSA key and its PEM encoded public key
+func newKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// newRegistry serves the signature of the digest signed by the key in the test/redis repository.
+// The registry requires the token authentication if token is set.
+func newRegistry(t *testing.T, key *ecdsa.PrivateKey, signedDigest, token string) *httptest.Server {
+	blob := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"test/redis"},`+
+		`"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, signedDigest))
+	blobSum := sha256.Sum256(blob)
+	blobDigest := "sha256:" + hex.EncodeToString(blobSum[:])
+	signature, err := ecdsa.SignASN1(rand.Reader, key, blobSum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"layers": []interface{}{map[string]interface{}{
+			"mediaType":   "application/vnd.dev.cosign.simplesigning.v1+json",
+			"digest":      blobDigest,
+			"annotations": map[string]string{SignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+		}},
+	})
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			_, _ = fmt.Fprintf(w, `{"token":%q}`, token)
+			return
+		}
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/test/redis/manifests/" + strings.Replace(testDigest, ":", "-", 1) + ".sig":
+			_, _ = w.Write(manifest)
+		case "/v2/test/redis/blobs/" + blobDigest:
+			_, _ = w.Write(blob)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	key, publicKey := newKey(t)
+	_, otherPublicKey := newKey(t)
+
+	tests := []struct {
+		name             string
+		signedDigest     string
+		token            string
+		verifiedDigest   string
+		publicKey        string
+		wantErr          bool
+		wantVerification bool
+	}{
+		{"signed", testDigest, "", testDigest, publicKey, false, false},
+		{"token authentication", testDigest, "secret", testDigest, publicKey, false, false},
+		{"other key", testDigest, "", testDigest, otherPublicKey, true, true},
+		{"other digest signed", strings.Replace(testDigest, "0", "f", 1), "", testDigest, publicKey, true, true},
+		{"not signed", testDigest, "", strings.Replace(testDigest, "0", "f", 1), publicKey, true, true},
+		{"not pinned", testDigest, "", "", publicKey, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRegistry(t, key, tt.signedDigest, tt.token)
+			defer server.Close()
+
+			keys, err := ParsePublicKeys(tt.publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := &Verifier{Client: server.Client()}
+			image := strings.TrimPrefix(server.URL, "https://") + "/test/redis:6.0"
+			if tt.verifiedDigest != "" {
+				image += "@" + tt.verifiedDigest
+			}
+
+			err = v.Verify(context.Background(), image, keys, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := err.(*VerificationError); ok != tt.wantVerification {
+				t.Errorf("Verify() error = %v, want VerificationError %v", err, tt.wantVerification)
+			}
+			if err != nil {
+				return
+			}
+
+			// the verification is cached
+			server.Close()
+			if err := v.Verify(context.Background(), image, keys, nil); err != nil {
+				t.Errorf("Verify() cached error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package features implements the feature gates the risky behaviors of the operator are shipped disabled behind.
+// The gates are set for all the Redis resources with the --feature-gates flag, e.g. --feature-gates=Name=true,
+// and are overridden for a single Redis with the Annotation of the same format, so a feature can be enabled
+// progressively. The GA features are always enabled and can not be disabled.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Annotation of the Redis overriding the feature gates for the resource, e.g. Name=true,Other=false
+const Annotation = "k8s.amaiz.com/feature-gates"
+
+// Feature is the name of a feature gate
+type Feature string
+
+// Stage is the maturity of a feature
+type Stage string
+
+// feature stages
+const (
+	Alpha Stage = "Alpha"
+	Beta  Stage = "Beta"
+	GA    Stage = "GA"
+)
+
+// Spec describes the feature gate
+type Spec struct {
+	// Default tells whether the feature is enabled unless set otherwise
+	Default bool
+	Stage   Stage
+}
+
+// known are the feature gates of the operator
+var known = map[Feature]Spec{}
+
+// Gates tells the enabled features. It is safe for concurrent use.
+type Gates struct {
+	known map[Feature]Spec
+
+	mu      sync.RWMutex
+	enabled map[Feature]bool
+}
+
+// DefaultGates are the feature gates set by the --feature-gates flag
+var DefaultGates = NewGates(known)
+
+// NewGates returns the gates of the known features set to the defaults
+func NewGates(known map[Feature]Spec) *Gates {
+	return &Gates{known: known, enabled: make(map[Feature]bool)}
+}
+
+// Parse parses the comma separated Name=bool pairs, the GA features can not be disabled
+func (g *Gates) Parse(value string) (map[Feature]bool, error) {
+	enabled := make(map[Feature]bool)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("missing bool value for %s", pair)
+		}
+		feature := Feature(strings.TrimSpace(kv[0]))
+		spec, ok := g.known[feature]
+		if !ok {
+			return nil, fmt.Errorf("unknown feature gate %s", feature)
+		}
+		value, err := strconv.ParseBool(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %s", feature, err)
+		}
+		if spec.Stage == GA && !value {
+			return nil, fmt.Errorf("feature gate %s is GA and can not be disabled", feature)
+		}
+		enabled[feature] = value
+	}
+	return enabled, nil
+}
+
+// Set sets the gates from the comma separated Name=bool pairs, it implements flag.Value
+func (g *Gates) Set(value string) error {
+	enabled, err := g.Parse(value)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for feature, value := range enabled {
+		g.enabled[feature] = value
+	}
+	return nil
+}
+
+// String returns the gates set explicitly as the comma separated Name=bool pairs, it implements flag.Value
+func (g *Gates) String() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	pairs := make([]string, 0, len(g.enabled))
+	for feature, value := range g.enabled {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", feature, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Type implements pflag.Value
+func (g *Gates) Type() string {
+	return "mapStringBool"
+}
+
+// Enabled tells whether the feature is enabled for all the resources
+func (g *Gates) Enabled(feature Feature) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if value, ok := g.enabled[feature]; ok {
+		return value
+	}
+	return g.known[feature].Default
+}
+
+// EnabledFor tells whether the feature is enabled for the resource with the annotations.
+// The invalid annotation is ignored, it is rejected by the validating webhook.
+func (g *Gates) EnabledFor(feature Feature, annotations map[string]string) bool {
+	if value, ok := annotations[Annotation]; ok {
+		if enabled, err := g.Parse(value); err == nil {
+			if value, ok := enabled[feature]; ok {
+				return value
+			}
+		}
+	}
+	return g.Enabled(feature)
+}
+
+// Known returns the descriptions of the known feature gates, e.g. for the flag usage
+func (g *Gates) Known() []string {
+	descriptions := make([]string, 0, len(g.known))
+	for feature, spec := range g.known {
+		descriptions = append(descriptions, fmt.Sprintf("%s=true|false (%s - default=%t)", feature, spec.Stage, spec.Default))
+	}
+	sort.Strings(descriptions)
+	return descriptions
+}
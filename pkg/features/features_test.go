@@ -0,0 +1,100 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package features
+
+import (
+	"testing"
+)
+
+const (
+	alphaFeature Feature = "AlphaFeature"
+	betaFeature  Feature = "BetaFeature"
+	gaFeature    Feature = "GAFeature"
+)
+
+var testFeatures = map[Feature]Spec{
+	alphaFeature: {Default: false, Stage: Alpha},
+	betaFeature:  {Default: true, Stage: Beta},
+	gaFeature:    {Default: true, Stage: GA},
+}
+
+func TestGates_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[Feature]bool
+		wantErr bool
+	}{
+		{"empty", "", map[Feature]bool{alphaFeature: false, betaFeature: true, gaFeature: true}, false},
+		{"enable alpha", "AlphaFeature=true", map[Feature]bool{alphaFeature: true, betaFeature: true, gaFeature: true}, false},
+		{"disable beta", "AlphaFeature=true, BetaFeature=false",
+			map[Feature]bool{alphaFeature: true, betaFeature: false, gaFeature: true}, false},
+		{"unknown", "Unknown=true", nil, true},
+		{"missing value", "AlphaFeature", nil, true},
+		{"invalid value", "AlphaFeature=yes", nil, true},
+		{"disable GA", "GAFeature=false", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gates := NewGates(testFeatures)
+			if err := gates.Set(tt.value); (err != nil) != tt.wantErr {
+				t.Fatalf("Gates.Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for feature, want := range tt.want {
+				if got := gates.Enabled(feature); got != want {
+					t.Errorf("Gates.Enabled(%s) = %v, want %v", feature, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGates_EnabledFor(t *testing.T) {
+	gates := NewGates(testFeatures)
+	if err := gates.Set("BetaFeature=false"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		feature     Feature
+		want        bool
+	}{
+		{"flag", nil, betaFeature, false},
+		{"default", nil, alphaFeature, false},
+		{"enabled by annotation", map[string]string{Annotation: "AlphaFeature=true"}, alphaFeature, true},
+		{"other feature", map[string]string{Annotation: "AlphaFeature=true"}, betaFeature, false},
+		{"re-enabled by annotation", map[string]string{Annotation: "BetaFeature=true"}, betaFeature, true},
+		{"invalid annotation", map[string]string{Annotation: "AlphaFeature=true,Unknown=true"}, alphaFeature, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gates.EnabledFor(tt.feature, tt.annotations); got != tt.want {
+				t.Errorf("Gates.EnabledFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGates_String(t *testing.T) {
+	gates := NewGates(testFeatures)
+	if err := gates.Set("BetaFeature=false,AlphaFeature=true"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gates.String(), "AlphaFeature=true,BetaFeature=false"; got != want {
+		t.Errorf("Gates.String() = %s, want %s", got, want)
+	}
+}
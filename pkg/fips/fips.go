@@ -0,0 +1,68 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fips restricts the cryptography of the operator to the FIPS 140 approved algorithms.
+// The FIPS mode is enabled with the --fips flag, or by default in the binaries built with the fips tag.
+// In the FIPS mode:
+//   - the operator connects to Redis with TLS 1.2, the AES-GCM cipher suites and the NIST curves,
+//   - the image signatures are verified with the ECDSA and RSA keys of 2048 bits or more,
+//     the Ed25519 keys are rejected.
+//
+// The mode selects the algorithms only. The implementations are those of the Go standard library,
+// unless the binary is built with a validated module, e.g. GOEXPERIMENT=boringcrypto.
+// The TLS of the webhook server is set up by controller-runtime and is not restricted.
+package fips
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"fmt"
+)
+
+// MinRSAKeySize is the minimum size of the RSA keys in bits
+const MinRSAKeySize = 2048
+
+// Enabled restricts the operator to the approved algorithms. Defaults to true in the binaries built with the fips tag.
+var Enabled = buildEnabled
+
+// ConfigureTLS restricts the TLS configuration to TLS 1.2 with the AES-GCM cipher suites and the NIST curves.
+// TLS 1.3 is disabled: its cipher suites are not configurable and include ChaCha20-Poly1305.
+func ConfigureTLS(config *tls.Config) {
+	config.MinVersion = tls.VersionTLS12
+	config.MaxVersion = tls.VersionTLS12
+	config.CipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	}
+	config.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+}
+
+// CheckPublicKey returns an error if the signature verification key is not approved
+func CheckPublicKey(key crypto.PublicKey) error {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		return nil
+	case *rsa.PublicKey:
+		if size := key.N.BitLen(); size < MinRSAKeySize {
+			return fmt.Errorf("RSA key size %d is less than %d bits", size, MinRSAKeySize)
+		}
+		return nil
+	default:
+		return fmt.Errorf("key type %T is not approved", key)
+	}
+}
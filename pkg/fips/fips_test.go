@@ -0,0 +1,65 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fips
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"testing"
+)
+
+func TestCheckPublicKey(t *testing.T) {
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, MinRSAKeySize)
+	weakRSAKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	ed25519Key, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name    string
+		key     interface{}
+		wantErr bool
+	}{
+		{"ECDSA", &ecdsaKey.PublicKey, false},
+		{"RSA", &rsaKey.PublicKey, false},
+		{"weak RSA", &weakRSAKey.PublicKey, true},
+		{"Ed25519", ed25519Key, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckPublicKey(tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("CheckPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigureTLS(t *testing.T) {
+	config := &tls.Config{MinVersion: tls.VersionTLS13}
+	ConfigureTLS(config)
+	if config.MinVersion != tls.VersionTLS12 || config.MaxVersion != tls.VersionTLS12 {
+		t.Errorf("ConfigureTLS() versions = %x-%x, want TLS 1.2", config.MinVersion, config.MaxVersion)
+	}
+	for _, suite := range tls.CipherSuites() {
+		for _, id := range config.CipherSuites {
+			if suite.ID == id && suite.Insecure {
+				t.Errorf("ConfigureTLS() cipher suite %s is insecure", suite.Name)
+			}
+		}
+	}
+}
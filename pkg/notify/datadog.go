@@ -0,0 +1,101 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultDatadogSite is the Datadog site the Events are sent to if omitted
+const DefaultDatadogSite = "datadoghq.com"
+
+// Datadog posts the Events to the Datadog Events API
+type Datadog struct {
+	// APIKey authenticates the requests
+	APIKey string
+	// Site is the Datadog site, e.g. datadoghq.eu. Defaults to DefaultDatadogSite.
+	Site string
+	// Endpoint overrides the Events API URL of the Site, e.g. for a proxy
+	Endpoint string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// strict implementation check
+var _ Sink = (*Datadog)(nil)
+
+// Name implements Sink
+func (d *Datadog) Name() string { return "datadog" }
+
+// datadogEvent is the request of the Events API
+type datadogEvent struct {
+	Title          string   `json:"title"`
+	Text           string   `json:"text"`
+	DateHappened   int64    `json:"date_happened"`
+	AlertType      string   `json:"alert_type"`
+	AggregationKey string   `json:"aggregation_key"`
+	Tags           []string `json:"tags"`
+}
+
+// Send implements Sink. The Warning Events are posted as errors, the Normal ones as info.
+// The Events of a Redis are aggregated and tagged with its namespace and name.
+func (d *Datadog) Send(ctx context.Context, event Event) error {
+	if d.APIKey == "" {
+		return fmt.Errorf("no Datadog API key")
+	}
+	endpoint := d.Endpoint
+	if endpoint == "" {
+		site := d.Site
+		if site == "" {
+			site = DefaultDatadogSite
+		}
+		endpoint = fmt.Sprintf("https://api.%s/api/v1/events", strings.TrimSpace(site))
+	}
+
+	alertType := "info"
+	if event.Type == "Warning" {
+		alertType = "error"
+	}
+	body, err := json.Marshal(datadogEvent{
+		Title:          fmt.Sprintf("Redis %s/%s: %s", event.Namespace, event.Name, event.Reason),
+		Text:           event.Message,
+		DateHappened:   event.Time.Unix(),
+		AlertType:      alertType,
+		AggregationKey: event.Namespace + "/" + event.Name,
+		Tags: []string{
+			"kube_namespace:" + event.Namespace,
+			"redis:" + event.Name,
+			"reason:" + event.Reason,
+			"source:redis-operator",
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("DD-API-KEY", d.APIKey)
+	_, err = post(ctx, d.Client, request)
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDatadog_Send(t *testing.T) {
+	var got datadogEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("DD-API-KEY") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	event := Event{
+		Time:      time.Unix(1600000000, 0),
+		Namespace: "default",
+		Name:      "example",
+		Type:      "Warning",
+		Reason:    "QuorumNotMet",
+		Message:   "1 of 3 instances reply to PING",
+	}
+	if err := (&Datadog{APIKey: "key", Endpoint: server.URL}).Send(context.Background(), event); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	want := datadogEvent{
+		Title:          "Redis default/example: QuorumNotMet",
+		Text:           "1 of 3 instances reply to PING",
+		DateHappened:   1600000000,
+		AlertType:      "error",
+		AggregationKey: "default/example",
+		Tags:           []string{"kube_namespace:default", "redis:example", "reason:QuorumNotMet", "source:redis-operator"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Send() posted %+v, want %+v", got, want)
+	}
+
+	if err := (&Datadog{APIKey: "wrong", Endpoint: server.URL}).Send(context.Background(), event); err == nil {
+		t.Errorf("Send() with the wrong API key succeeded")
+	}
+	if err := (&Datadog{Endpoint: server.URL}).Send(context.Background(), event); err == nil {
+		t.Errorf("Send() without the API key succeeded")
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultEventBus is the event bus the Events are put on if omitted
+	DefaultEventBus = "default"
+	// EventSource is the source of the EventBridge events the rules match on
+	EventSource = "redis-operator"
+	// EventDetailType is the detail type of the EventBridge events
+	EventDetailType = "Redis Event"
+
+	// eventBridgeService is the service name of the signature
+	eventBridgeService = "events"
+)
+
+// EventBridge puts the Events on an Amazon EventBridge event bus, formerly CloudWatch Events,
+// so the rules route them to SNS, Lambda or the CloudWatch alarms. The requests are signed
+// with the Signature Version 4 of the static credentials.
+type EventBridge struct {
+	// Region of the event bus, e.g. eu-west-1
+	Region string
+	// EventBus is the name or the ARN of the event bus. Defaults to DefaultEventBus.
+	EventBus string
+	// AccessKeyID, SecretAccessKey and the optional SessionToken are the AWS credentials
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Endpoint overrides the EventBridge URL of the Region, e.g. for a VPC endpoint
+	Endpoint string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// strict implementation check
+var _ Sink = (*EventBridge)(nil)
+
+// Name implements Sink
+func (b *EventBridge) Name() string { return "eventbridge" }
+
+// eventBridgeDetail is the detail of the EventBridge event
+type eventBridgeDetail struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message"`
+}
+
+// Send implements Sink with PutEvents. The Events are put with the EventSource source and the EventDetailType
+// detail type, the detail holds the namespace and the name of the Redis along with the Event.
+func (b *EventBridge) Send(ctx context.Context, event Event) error {
+	if b.Region == "" || b.AccessKeyID == "" || b.SecretAccessKey == "" {
+		return fmt.Errorf("no EventBridge region or AWS credentials")
+	}
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com/", eventBridgeService, b.Region)
+	}
+	bus := b.EventBus
+	if bus == "" {
+		bus = DefaultEventBus
+	}
+
+	detail, err := json.Marshal(eventBridgeDetail{
+		Namespace: event.Namespace,
+		Name:      event.Name,
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Message:   event.Message,
+	})
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{"Entries": []map[string]interface{}{{
+		"Source":       EventSource,
+		"DetailType":   EventDetailType,
+		"Detail":       string(detail),
+		"EventBusName": bus,
+		"Time":         event.Time.Unix(),
+	}}})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", "AWSEvents.PutEvents")
+	if b.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", b.SessionToken)
+	}
+	signV4(request, body, b.Region, eventBridgeService, b.AccessKeyID, b.SecretAccessKey, time.Now())
+	response, err := post(ctx, b.Client, request)
+	if err != nil {
+		return err
+	}
+	// the entries failing to be put are reported in the successful response
+	var result struct {
+		FailedEntryCount int
+		Entries          []struct {
+			ErrorCode    string
+			ErrorMessage string
+		}
+	}
+	if err := json.Unmarshal(response, &result); err != nil {
+		return fmt.Errorf("invalid PutEvents response: %s", err)
+	}
+	if result.FailedEntryCount > 0 {
+		for _, entry := range result.Entries {
+			if entry.ErrorCode != "" {
+				return fmt.Errorf("failed to put the event: %s: %s", entry.ErrorCode, entry.ErrorMessage)
+			}
+		}
+		return fmt.Errorf("failed to put the event")
+	}
+	return nil
+}
+
+// signV4 signs the request with the AWS Signature Version 4. All the headers set on the request are signed
+// along with the host, so the headers must not be changed after signing.
+func signV4(request *http.Request, body []byte, region, service, accessKeyID, secretAccessKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	request.Header.Set("X-Amz-Date", amzDate)
+
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		_, _ = fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		path,
+		request.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(canonicalHash[:])}, "\n")
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
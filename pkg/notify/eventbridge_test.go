@@ -0,0 +1,96 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test_signV4 checks the signature against the get-vanilla case of the AWS Signature Version 4 test suite
+func Test_signV4(t *testing.T) {
+	request, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signV4(request, nil, "us-east-1", "service", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := request.Header.Get("Authorization"); got != want {
+		t.Errorf("signV4() Authorization = %s, want %s", got, want)
+	}
+}
+
+func TestEventBridge_Send(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  bool
+	}{
+		{"put", `{"FailedEntryCount":0,"Entries":[{"EventId":"1"}]}`, false},
+		{"failed entry", `{"FailedEntryCount":1,"Entries":[{"ErrorCode":"AccessDeniedException","ErrorMessage":"denied"}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var detail eventBridgeDetail
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Amz-Target") != "AWSEvents.PutEvents" ||
+					!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				var body struct {
+					Entries []struct {
+						Source       string
+						DetailType   string
+						Detail       string
+						EventBusName string
+					}
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Entries) != 1 ||
+					body.Entries[0].Source != EventSource || body.Entries[0].EventBusName != DefaultEventBus ||
+					json.Unmarshal([]byte(body.Entries[0].Detail), &detail) != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			sink := &EventBridge{Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Endpoint: server.URL}
+			err := sink.Send(context.Background(), Event{
+				Time:      time.Now(),
+				Namespace: "default",
+				Name:      "example",
+				Type:      "Normal",
+				Reason:    "MasterPromoted",
+				Message:   "Promoted redis-example-1 to master",
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if detail.Name != "example" || detail.Reason != "MasterPromoted" {
+				t.Errorf("Send() put detail %+v", detail)
+			}
+		})
+	}
+}
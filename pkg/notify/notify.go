@@ -0,0 +1,68 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify sends the Events of the Redis resources, e.g. the failovers and the health changes,
+// to the external event services for the alerting that does not run through Prometheus.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// maxResponseSize limits the size of the responses read from the services
+const maxResponseSize = 64 << 10
+
+// Event is an Event emitted on a Redis
+type Event struct {
+	Time time.Time
+	// Namespace and Name of the Redis
+	Namespace string
+	Name      string
+	// Type is the type of the Kubernetes Event, Normal or Warning
+	Type    string
+	Reason  string
+	Message string
+}
+
+// Sink sends the Events to an external service
+type Sink interface {
+	// Name identifies the sink in the logs and the metrics
+	Name() string
+	// Send delivers the Event, the failed deliveries are not retried
+	Send(ctx context.Context, event Event) error
+}
+
+// post sends the request to the service and returns the response body, http.DefaultClient is used if client is nil
+func post(ctx context.Context, client *http.Client, request *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to post to %s: %s: %s", request.URL, response.Status, bytes.TrimSpace(body))
+	}
+	return body, err
+}
@@ -0,0 +1,224 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// DefaultUser is the name of the user authenticated by requirepass
+	DefaultUser = "default"
+	// OperatorUser is the user the operator, the probes, the exporter and the replicas authenticate as
+	// when the default user is disabled. It is allowed all the commands and keys.
+	OperatorUser = "redis-operator"
+)
+
+// masterUser is the configuration parameter of the user the replica authenticates to the master as
+const masterUser = "masteruser"
+
+// User is a Redis 6+ ACL user
+type User struct {
+	Name      string
+	Rules     []string
+	Passwords []string
+}
+
+// Args returns the ACL SETUSER arguments following the user name.
+// The user is reset first so that the resulting state does not depend on the previous one.
+// The passwords are added by their SHA-256 hashes, hence the arguments are safe to be written
+// to the configuration and they do not reveal the passwords in the command arguments.
+func (u User) Args() []string {
+	args := make([]string, 0, 1+len(u.Rules)+len(u.Passwords))
+	args = append(args, "reset")
+	args = append(args, u.Rules...)
+	for _, password := range u.Passwords {
+		args = append(args, "#"+PasswordHash(password))
+	}
+	return args
+}
+
+// PasswordHash returns the hex encoded SHA-256 hash of the password as accepted by ACL SETUSER
+func PasswordHash(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewOperatorUser returns the OperatorUser authenticated with the password
+func NewOperatorUser(password string) User {
+	return User{Name: OperatorUser, Rules: []string{"on", "~*", "+@all"}, Passwords: []string{password}}
+}
+
+// NewDefaultUser returns the DefaultUser allowed all the commands, keys and channels as it is by default.
+// The user is authenticated with the password or with no password if empty. Requires Redis 6.2+.
+func NewDefaultUser(password string) User {
+	user := User{Name: DefaultUser, Rules: []string{"on", "~*", "allchannels", "+@all"}}
+	if password == "" {
+		user.Rules = append(user.Rules, "nopass")
+	} else {
+		user.Passwords = []string{password}
+	}
+	return user
+}
+
+// Validate checks that the user can be safely applied and written to the configuration file
+func (u User) Validate() error {
+	if u.Name == "" || u.Name == DefaultUser {
+		return fmt.Errorf("invalid ACL user name %q", u.Name)
+	}
+	if u.Name == OperatorUser {
+		return fmt.Errorf("ACL user name %q is reserved by the operator", u.Name)
+	}
+	for _, rule := range u.Rules {
+		switch {
+		case rule == "" || strings.IndexFunc(rule, unicode.IsSpace) >= 0:
+			return fmt.Errorf("ACL user %s: invalid rule %q", u.Name, rule)
+		case strings.ContainsAny(rule[:1], "><#!") || rule == "nopass" || rule == "resetpass":
+			return fmt.Errorf("ACL user %s: passwords can not be set by rules", u.Name)
+		}
+	}
+	for _, password := range u.Passwords {
+		if password == "" || strings.IndexFunc(password, unicode.IsSpace) >= 0 {
+			return fmt.Errorf("ACL user %s: passwords must not be empty or contain whitespace", u.Name)
+		}
+	}
+	return nil
+}
+
+// setUser creates or updates the ACL user
+func (i *instance) setUser(user User) error {
+	args := []interface{}{"acl", "setuser", user.Name}
+	for _, arg := range user.Args() {
+		args = append(args, arg)
+	}
+	return i.client.Do(args...).Err()
+}
+
+// ApplyUsers creates or updates ACL users on all instances
+func (ins instances) ApplyUsers(users ...User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	ch := make(chan string, len(ins))
+	wg.Add(len(ins))
+
+	for i := range ins {
+		go func(i *instance, wg *sync.WaitGroup) {
+			defer wg.Done()
+			for _, user := range users {
+				if err := i.setUser(user); err != nil {
+					ch <- fmt.Sprintf("error applying ACL user %s to %s: %s", user.Name, i.Address, err)
+					return
+				}
+			}
+		}(&ins[i], &wg)
+	}
+	wg.Wait()
+	close(ch)
+
+	if len(ch) > 0 {
+		var b strings.Builder
+		defer b.Reset()
+		for e := range ch {
+			_, _ = fmt.Fprintf(&b, "%s;", e)
+		}
+		return errors.New(b.String())
+	}
+	return nil
+}
+
+// DeleteUsers deletes the ACL users from all instances, e.g. the ones no longer declared. The missing users
+// are ignored and the connections authenticated as the deleted users are closed by Redis.
+// DefaultUser and OperatorUser are never deleted.
+func (ins instances) DeleteUsers(names ...string) error {
+	args := []interface{}{"acl", "deluser"}
+	for _, name := range names {
+		if name != DefaultUser && name != OperatorUser {
+			args = append(args, name)
+		}
+	}
+	if len(args) == 2 {
+		return nil
+	}
+	return ins.each("deleting ACL users", func(i *instance) error { return i.client.Do(args...).Err() })
+}
+
+// setMasterUser sets the user the replica authenticates to the master as, the empty user is the default one
+func (i *instance) setMasterUser(user string) error {
+	return i.client.Do("CONFIG", "SET", masterUser, user).Err()
+}
+
+// setDefaultUserEnabled turns the default user on or off
+func (i *instance) setDefaultUserEnabled(enabled bool) error {
+	rule := "off"
+	if enabled {
+		rule = "on"
+	}
+	return i.client.Do("ACL", "SETUSER", DefaultUser, rule).Err()
+}
+
+// SetDefaultUser enables or disables the default user on all instances.
+// OperatorUser must be applied beforehand: the replicas authenticate to the master as OperatorUser
+// while the default user is disabled. The replicas are switched to OperatorUser before the default user
+// is disabled anywhere and back to the default user only once it is enabled everywhere,
+// hence the replicas are able to reconnect to the master at any moment.
+func (ins instances) SetDefaultUser(enabled bool) error {
+	if enabled {
+		if err := ins.each("enabling the default user", func(i *instance) error { return i.setDefaultUserEnabled(true) }); err != nil {
+			return err
+		}
+		return ins.each("resetting "+masterUser, func(i *instance) error { return i.setMasterUser("") })
+	}
+	if err := ins.each("setting "+masterUser, func(i *instance) error { return i.setMasterUser(OperatorUser) }); err != nil {
+		return err
+	}
+	return ins.each("disabling the default user", func(i *instance) error { return i.setDefaultUserEnabled(false) })
+}
+
+// each runs fn on all instances concurrently and joins the errors prefixed with the action
+func (ins instances) each(action string, fn func(i *instance) error) error {
+	var wg sync.WaitGroup
+	ch := make(chan string, len(ins))
+	wg.Add(len(ins))
+
+	for i := range ins {
+		go func(i *instance, wg *sync.WaitGroup) {
+			defer wg.Done()
+			if err := fn(i); err != nil {
+				ch <- fmt.Sprintf("error %s on %s: %s", action, i.Address, err)
+			}
+		}(&ins[i], &wg)
+	}
+	wg.Wait()
+	close(ch)
+
+	if len(ch) > 0 {
+		var b strings.Builder
+		defer b.Reset()
+		for e := range ch {
+			_, _ = fmt.Fprintf(&b, "%s;", e)
+		}
+		return errors.New(b.String())
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUser_Args(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want []string
+	}{
+		{"empty", User{Name: "app"}, []string{"reset"}},
+		{
+			"rules and passwords",
+			User{Name: "app", Rules: []string{"on", "~cache:*", "+@read"}, Passwords: []string{"old", "new"}},
+			[]string{"reset", "on", "~cache:*", "+@read", "#" + PasswordHash("old"), "#" + PasswordHash("new")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.Args(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("User.Args() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewOperatorUser(t *testing.T) {
+	want := []string{"reset", "on", "~*", "+@all", "#" + PasswordHash("secret")}
+	if got := NewOperatorUser("secret").Args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewOperatorUser().Args() = %v, want %v", got, want)
+	}
+}
+
+func TestPasswordHash(t *testing.T) {
+	// echo -n secret | sha256sum
+	want := "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+	if got := PasswordHash("secret"); got != want {
+		t.Errorf("PasswordHash() = %s, want %s", got, want)
+	}
+}
+
+func TestNewDefaultUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"password", "secret", []string{"reset", "on", "~*", "allchannels", "+@all", "#" + PasswordHash("secret")}},
+		{"no password", "", []string{"reset", "on", "~*", "allchannels", "+@all", "nopass"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewDefaultUser(tt.password).Args(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewDefaultUser().Args() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUser_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    User
+		wantErr bool
+	}{
+		{"valid", User{Name: "app", Rules: []string{"on", "+@all"}, Passwords: []string{"secret"}}, false},
+		{"empty name", User{}, true},
+		{"default user", User{Name: DefaultUser}, true},
+		{"operator user", User{Name: OperatorUser}, true},
+		{"password rule", User{Name: "app", Rules: []string{">secret"}}, true},
+		{"password hash rule", User{Name: "app", Rules: []string{"#5e88"}}, true},
+		{"nopass", User{Name: "app", Rules: []string{"nopass"}}, true},
+		{"rule with whitespace", User{Name: "app", Rules: []string{"on\nuser evil on >x"}}, true},
+		{"empty rule", User{Name: "app", Rules: []string{""}}, true},
+		{"password with whitespace", User{Name: "app", Passwords: []string{"pass word"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.user.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("User.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedises_DeleteUsers(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  [][]interface{}
+	}{
+		{"none", nil, nil},
+		{"removed", []string{"app", "reports"}, [][]interface{}{{"acl", "deluser", "app", "reports"}}},
+		{"reserved", []string{DefaultUser, OperatorUser}, nil},
+		{"reserved skipped", []string{DefaultUser, "app"}, [][]interface{}{{"acl", "deluser", "app"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := []*fakeClient{{}, {}}
+			ins := instances{
+				{Address: Address{"10.0.0.1", "6379"}, client: clients[0]},
+				{Address: Address{"10.0.0.2", "6379"}, client: clients[1]},
+			}
+			if err := ins.DeleteUsers(tt.names...); err != nil {
+				t.Fatalf("instances.DeleteUsers() error = %v", err)
+			}
+			for _, client := range clients {
+				if !reflect.DeepEqual(client.commands, tt.want) {
+					t.Errorf("instances.DeleteUsers() commands = %v, want %v", client.commands, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	replicaAnnounceIP   = "replica-announce-ip"
+	replicaAnnouncePort = "replica-announce-port"
+)
+
+// parseAnnouncedAddress parses the CONFIG GET replica-announce-* reply.
+// The zero port means the instance announces its own port and is returned as an empty Port.
+func parseAnnouncedAddress(reply interface{}) (Address, error) {
+	values, ok := reply.([]interface{})
+	if !ok || len(values)%2 != 0 {
+		return Address{}, fmt.Errorf("unexpected CONFIG GET reply %v", reply)
+	}
+
+	var address Address
+	for i := 0; i < len(values); i += 2 {
+		name, _ := values[i].(string)
+		value, _ := values[i+1].(string)
+		switch name {
+		case replicaAnnounceIP:
+			address.Host = value
+		case replicaAnnouncePort:
+			if value != "0" {
+				address.Port = value
+			}
+		}
+	}
+	return address, nil
+}
+
+// announce sets the address the instance announces to the master, the empty address resets it.
+// A replica sends the address when it connects to the master, hence it is disconnected from the master
+// once the address changes. It reconnects right away and continues with a partial resynchronization.
+func (i *instance) announce(address Address) error {
+	reply, err := i.client.Do("CONFIG", "GET", "replica-announce-*").Result()
+	if err != nil {
+		return err
+	}
+	current, err := parseAnnouncedAddress(reply)
+	if err != nil {
+		return err
+	}
+	if current == address {
+		return nil
+	}
+
+	port := address.Port
+	if port == "" {
+		port = "0"
+	}
+	if err := i.client.Do("CONFIG", "SET", replicaAnnounceIP, address.Host).Err(); err != nil {
+		return err
+	}
+	if err := i.client.Do("CONFIG", "SET", replicaAnnouncePort, port).Err(); err != nil {
+		return err
+	}
+	if i.role != RoleReplica {
+		return nil
+	}
+	return i.client.Do("CLIENT", "KILL", "TYPE", "master").Err()
+}
+
+// Announce sets the addresses the instances announce to the master as configured by Options.Announced
+func (ins instances) Announce() error {
+	var wg sync.WaitGroup
+	ch := make(chan string, len(ins))
+	wg.Add(len(ins))
+
+	for i := range ins {
+		go func(i *instance, wg *sync.WaitGroup) {
+			defer wg.Done()
+			if err := i.announce(i.announcedAddress); err != nil {
+				ch <- fmt.Sprintf("error announcing %s as %s: %s", i.Address, i.announcedAddress, err)
+			}
+		}(&ins[i], &wg)
+	}
+	wg.Wait()
+	close(ch)
+
+	if len(ch) > 0 {
+		var b strings.Builder
+		defer b.Reset()
+		for e := range ch {
+			_, _ = fmt.Fprintf(&b, "%s;", e)
+		}
+		return errors.New(b.String())
+	}
+	return nil
+}
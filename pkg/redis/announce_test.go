@@ -0,0 +1,74 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseAnnouncedAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   interface{}
+		want    Address
+		wantErr bool
+	}{
+		{
+			"not announced",
+			[]interface{}{replicaAnnounceIP, "", replicaAnnouncePort, "0"},
+			Address{},
+			false,
+		},
+		{
+			"announced",
+			[]interface{}{replicaAnnouncePort, "30379", replicaAnnounceIP, "198.51.100.7"},
+			Address{"198.51.100.7", "30379"},
+			false,
+		},
+		{"odd reply", []interface{}{replicaAnnounceIP}, Address{}, true},
+		{"not an array", "OK", Address{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAnnouncedAddress(tt.reply)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAnnouncedAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseAnnouncedAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedis_refresh_announced(t *testing.T) {
+	r := &instance{announced: map[Address]Address{
+		{"172.18.0.5", "6379"}: {"10.0.0.5", "6379"},
+	}}
+	if err := r.refresh(masterInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Address
+	for _, replica := range r.replicas {
+		got = append(got, replica.Address)
+	}
+	// the replicas not announcing their external addresses are kept as is
+	want := []Address{{"10.0.0.5", "6379"}, {"172.18.0.4", "6379"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("instance.refresh() replicas = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+// Backoff is the policy the failed operations are retried with
+type Backoff struct {
+	// Steps is the number of attempts, a single attempt is made if not positive
+	Steps int
+	// Duration is the delay before the first retry
+	Duration time.Duration
+	// Factor multiplies the delay after every retry, the delay is constant if not greater than 1
+	Factor float64
+	// Cap limits the delay if positive
+	Cap time.Duration
+}
+
+// delay returns the delay before the retry following the attempt numbered from 1
+func (b Backoff) delay(attempt int) time.Duration {
+	delay := b.Duration
+	for i := 1; i < attempt && b.Factor > 1; i++ {
+		delay = time.Duration(float64(delay) * b.Factor)
+		if b.Cap > 0 && delay >= b.Cap {
+			break
+		}
+	}
+	if b.Cap > 0 && delay > b.Cap {
+		return b.Cap
+	}
+	return delay
+}
+
+// retry runs fn until it succeeds, the attempts run out or ctx is done, and returns the last error
+func (b Backoff) retry(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := withContext(ctx, fn)
+		if err == nil || attempt >= b.Steps || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-time.After(b.delay(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff_delay(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff Backoff
+		attempt int
+		want    time.Duration
+	}{
+		{"zero", Backoff{}, 1, 0},
+		{"first", Backoff{Duration: time.Second, Factor: 2}, 1, time.Second},
+		{"third", Backoff{Duration: time.Second, Factor: 2}, 3, 4 * time.Second},
+		{"constant", Backoff{Duration: time.Second}, 3, time.Second},
+		{"capped", Backoff{Duration: time.Second, Factor: 2, Cap: 3 * time.Second}, 5, 3 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.backoff.delay(tt.attempt); got != tt.want {
+				t.Errorf("Backoff.delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Caches are the replicas of the master kept outside of the replication, e.g. the node-local caches.
+// They are never promoted and are left out of the failover decisions, see Options.Caches.
+type Caches interface {
+	// ReplicateFrom makes the caches replicas of the master and returns the reconfigured ones
+	ReplicateFrom(ctx context.Context, master Address) ([]Address, error)
+	// Linked returns the caches replicating from the master with the link up
+	Linked(master Address) []Address
+	// ApplyUsers creates or updates ACL users on all caches
+	ApplyUsers(users ...User) error
+	// DeleteUsers deletes ACL users from all caches
+	DeleteUsers(names ...string) error
+	// SetDefaultUser enables or disables the default user on all caches
+	SetDefaultUser(enabled bool) error
+	// Disconnect closes connections to all caches
+	Disconnect()
+}
+
+// NewCaches connects to the caches. The unreachable caches are skipped, they are configured once reachable.
+func NewCaches(ctx context.Context, options Options, addresses ...Address) (Caches, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	ins := connectReachable(ctx, options, addresses...)
+	for i := range ins {
+		ins[i].clientName = options.ClientName
+	}
+	return ins, nil
+}
+
+// replicates reports whether the instance is a replica of the master
+func (i *instance) replicates(master Address) bool {
+	return i.role == RoleReplica && i.masterHost == master.Host && i.masterPort == master.Port
+}
+
+// Linked returns the instances replicating from the master with the link up
+func (ins instances) Linked(master Address) []Address {
+	var linked []Address
+	for i := range ins {
+		if ins[i].replicates(ins[i].replicationTarget(master)) && ins[i].masterLinkStatus == StatusUp {
+			linked = append(linked, ins[i].Address)
+		}
+	}
+	return linked
+}
+
+// ReplicateFrom makes the instances not replicating from the master yet its replicas
+func (ins instances) ReplicateFrom(ctx context.Context, master Address) ([]Address, error) {
+	var reconfigured []Address
+	var errs []string
+	for i := range ins {
+		target := ins[i].replicationTarget(master)
+		if ins[i].replicates(target) {
+			continue
+		}
+		if err := ins[i].replicaOf(ctx, target); err != nil {
+			errs = append(errs, fmt.Sprintf("error replicating %s from %s: %s", ins[i].Address, master, err))
+			continue
+		}
+		reconfigured = append(reconfigured, ins[i].Address)
+	}
+	if len(errs) > 0 {
+		return reconfigured, errors.New(strings.Join(errs, ";"))
+	}
+	return reconfigured, nil
+}
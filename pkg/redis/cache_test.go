@@ -0,0 +1,38 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import "testing"
+
+func TestRedis_replicates(t *testing.T) {
+	master := Address{"172.18.0.2", "6379"}
+	tests := []struct {
+		name     string
+		instance instance
+		want     bool
+	}{
+		{"replica of the master", instance{role: RoleReplica, masterHost: "172.18.0.2", masterPort: "6379"}, true},
+		{"replica of another master", instance{role: RoleReplica, masterHost: "172.18.0.3", masterPort: "6379"}, false},
+		{"another port", instance{role: RoleReplica, masterHost: "172.18.0.2", masterPort: "6380"}, false},
+		{"master", instance{role: RoleMaster}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.instance.replicates(master); got != tt.want {
+				t.Errorf("instance.replicates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// setConfig sets the configuration directives of the running instance in the order of their names.
+// The values are passed as CONFIG SET takes them, e.g. the arguments of save separated by spaces.
+func (i *instance) setConfig(config map[string]string) error {
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := i.client.Do("CONFIG", "SET", name, config[name]).Err(); err != nil {
+			return fmt.Errorf("error setting %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// ApplyConfig sets the configuration directives of the running instances with CONFIG SET, so the instances
+// need no restart. The configuration file is not rewritten, the instances read the directives from
+// the updated configuration once restarted. The unreachable instances are skipped.
+func ApplyConfig(ctx context.Context, options Options, config map[string]string, addresses ...Address) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	ins := connectReachable(ctx, options, addresses...)
+	defer func() { ins.Disconnect() }()
+
+	var errs []string
+	for i := range ins {
+		target := &ins[i]
+		if err := withContext(ctx, func() error { return target.setConfig(config) }); err != nil {
+			errs = append(errs, fmt.Sprintf("error applying config to %s: %s", ins[i].Address, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ";"))
+	}
+	return nil
+}
+
+// GetConfig returns the values of the configuration directives of the running instance by name.
+// The directives are fetched one by one, CONFIG GET takes multiple names since Redis 7.0 only.
+// The unknown directives are omitted, the secrets are redacted the way Diagnose does.
+func GetConfig(ctx context.Context, options Options, address Address, names ...string) (map[string]string, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	i := instance{Address: address, client: options.newClient(address)}
+	defer func() { _ = i.client.Close() }()
+
+	config := make(map[string]string, len(names))
+	err := withContext(ctx, func() error {
+		for _, name := range names {
+			reply, err := i.client.Do("CONFIG", "GET", name).Result()
+			if err != nil {
+				return fmt.Errorf("error getting %s: %s", name, err)
+			}
+			values, err := parseConfig(reply)
+			if err != nil {
+				return err
+			}
+			for name, value := range values {
+				config[name] = value
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
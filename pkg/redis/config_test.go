@@ -0,0 +1,55 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-redis/redis"
+)
+
+// configClient replies to CONFIG GET with the directive from config, the unknown ones are replied with none
+type configClient struct {
+	fakeClient
+	config map[string]string
+}
+
+func (c *configClient) Do(args ...interface{}) *redis.Cmd {
+	if len(args) == 3 && args[0] == "CONFIG" && args[1] == "GET" {
+		name, _ := args[2].(string)
+		if value, ok := c.config[name]; ok {
+			return redis.NewCmdResult([]interface{}{name, value}, nil)
+		}
+		return redis.NewCmdResult([]interface{}{}, nil)
+	}
+	return c.fakeClient.Do(args...)
+}
+
+func TestGetConfig(t *testing.T) {
+	client := &configClient{config: map[string]string{"maxmemory-policy": "allkeys-lru", "min-replicas-to-write": "1"}}
+	options := Options{NewClient: func(*redis.Options) Client { return client }}
+
+	got, err := GetConfig(context.Background(), options, Address{"172.18.0.2", "6379"},
+		"maxmemory-policy", "min-replicas-to-write", "unknown")
+	if err != nil {
+		t.Fatalf("GetConfig() error = %v", err)
+	}
+	want := map[string]string{"maxmemory-policy": "allkeys-lru", "min-replicas-to-write": "1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetConfig() = %v, want %v", got, want)
+	}
+}
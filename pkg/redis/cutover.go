@@ -0,0 +1,94 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// cutoverSuccessor returns the index of the instance to take the master role over from the blue master:
+// the one replicating from it with the link up and the greatest replication offset, -1 if none
+func (ins instances) cutoverSuccessor(blue Address) int {
+	s := -1
+	for i := range ins {
+		if !ins[i].replicates(ins[i].replicationTarget(blue)) || ins[i].masterLinkStatus != StatusUp {
+			continue
+		}
+		if s < 0 || ins[i].replicationOffset > ins[s].replicationOffset {
+			s = i
+		}
+	}
+	return s
+}
+
+// Cutover hands the master role over from the blue master to the best of the green instances replicating from it,
+// the same way as Handover: the writes to the blue master are paused with CLIENT PAUSE WRITE until the successor
+// catches up, then the successor is promoted and the rest of the green instances along with the blue master are
+// reconfigured as its replicas. The blue master already demoted is left intact and the empty address is returned.
+// ErrNotInSync is returned if the successor lags behind.
+func Cutover(ctx context.Context, options Options, blue Address, green ...Address) (Address, error) {
+	if err := options.Validate(); err != nil {
+		return Address{}, err
+	}
+
+	ins := connectReachable(ctx, options, append([]Address{blue}, green...)...)
+	defer func() { ins.Disconnect() }()
+
+	b := ins.index(blue.String())
+	if b < 0 {
+		return Address{}, fmt.Errorf("blue master %s is unreachable", blue)
+	}
+	if ins[b].role != RoleMaster {
+		return Address{}, nil
+	}
+	s := ins.cutoverSuccessor(blue)
+	if s < 0 {
+		return Address{}, errors.New("no green instance is replicating from the blue master")
+	}
+	master, successor := &ins[b], &ins[s]
+
+	// the pause is lifted once the blue master is a replica: the paused writes are rejected rather than lost
+	if err := master.client.Do("CLIENT", "PAUSE",
+		strconv.FormatInt(int64(SyncPolicy{}.pause()/time.Millisecond), 10), "WRITE").Err(); err == nil {
+		defer master.client.Do("CLIENT", "UNPAUSE")
+	}
+
+	synced, err := inSync(ctx, master, successor, SyncPolicy{})
+	if err != nil {
+		return Address{}, err
+	}
+	if !synced {
+		return Address{}, ErrNotInSync
+	}
+
+	if err := successor.promote(ctx); err != nil {
+		return Address{}, err
+	}
+
+	var replicas instances
+	for i := range ins {
+		if i != s {
+			replicas = append(replicas, ins[i])
+		}
+	}
+	if err := replicas.reconfigureAsReplicasOf(ctx, successor.Address); err != nil {
+		return successor.Address, err
+	}
+	return successor.Address, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import "testing"
+
+func TestRedises_cutoverSuccessor(t *testing.T) {
+	blue := Address{"172.18.0.2", "6379"}
+	replica := func(host string, offset int, link string) instance {
+		return instance{
+			Address:           Address{host, "6379"},
+			role:              RoleReplica,
+			masterHost:        blue.Host,
+			masterPort:        blue.Port,
+			masterLinkStatus:  link,
+			replicationOffset: offset,
+		}
+	}
+	tests := []struct {
+		name      string
+		instances instances
+		want      int
+	}{
+		{"none", instances{{Address: blue, role: RoleMaster}}, -1},
+		{"greatest offset", instances{
+			{Address: blue, role: RoleMaster},
+			replica("172.18.1.2", 100, StatusUp),
+			replica("172.18.1.3", 120, StatusUp),
+		}, 2},
+		{"link down", instances{
+			replica("172.18.1.2", 100, StatusUp),
+			replica("172.18.1.3", 120, "down"),
+		}, 0},
+		{"another master", instances{
+			{Address: Address{"172.18.1.2", "6379"}, role: RoleReplica, masterHost: "172.18.0.3", masterPort: "6379",
+				masterLinkStatus: StatusUp, replicationOffset: 200},
+			replica("172.18.1.3", 120, StatusUp),
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.instances.cutoverSuccessor(blue); got != tt.want {
+				t.Errorf("instances.cutoverSuccessor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
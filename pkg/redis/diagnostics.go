@@ -0,0 +1,96 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+)
+
+// redacted replaces the values of the directives holding the secrets
+const redacted = "<redacted>"
+
+// secretDirectives are the directives whose values are never collected
+var secretDirectives = map[string]bool{
+	"requirepass":              true,
+	"masterauth":               true,
+	"tls-key-file-pass":        true,
+	"tls-client-key-file-pass": true,
+}
+
+// Diagnostics is the state of an instance collected to be attached to the bug reports
+type Diagnostics struct {
+	// Info is the reply to INFO all
+	Info string
+	// Config are the directives by name as CONFIG GET * replies, the secrets are redacted
+	Config map[string]string
+	// Err is the error of collecting the diagnostics, the other fields hold what has been collected before it
+	Err error
+}
+
+// parseConfig parses the CONFIG GET reply into the directives by name redacting the secrets
+func parseConfig(reply interface{}) (map[string]string, error) {
+	values, ok := reply.([]interface{})
+	if !ok || len(values)%2 != 0 {
+		return nil, fmt.Errorf("unexpected CONFIG GET reply %v", reply)
+	}
+
+	config := make(map[string]string, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		name, _ := values[i].(string)
+		value, _ := values[i+1].(string)
+		if secretDirectives[name] && value != "" {
+			value = redacted
+		}
+		config[name] = value
+	}
+	return config, nil
+}
+
+// diagnose collects the diagnostics of the instance
+func (i *instance) diagnose(ctx context.Context) Diagnostics {
+	var diagnostics Diagnostics
+	diagnostics.Err = withContext(ctx, func() error {
+		info, err := i.client.Info("all").Result()
+		if err != nil {
+			return fmt.Errorf("error getting info: %s", err)
+		}
+		diagnostics.Info = info
+		reply, err := i.client.Do("CONFIG", "GET", "*").Result()
+		if err != nil {
+			return fmt.Errorf("error getting config: %s", err)
+		}
+		diagnostics.Config, err = parseConfig(reply)
+		return err
+	})
+	return diagnostics
+}
+
+// Diagnose collects the diagnostics of the instances one by one. Unlike New it connects to every instance
+// regardless of the quorum, so the instances are diagnosed when the replication can not be created.
+// The unreachable instances are reported with the error.
+func Diagnose(ctx context.Context, options Options, addresses ...Address) (map[Address]Diagnostics, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	diagnostics := make(map[Address]Diagnostics, len(addresses))
+	for _, address := range addresses {
+		i := instance{Address: address, client: options.newClient(address)}
+		diagnostics[address] = i.diagnose(ctx)
+		_ = i.client.Close()
+	}
+	return diagnostics, nil
+}
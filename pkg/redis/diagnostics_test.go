@@ -0,0 +1,55 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   interface{}
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			"redacted",
+			[]interface{}{"requirepass", "secret", "masterauth", "secret", "maxmemory", "0"},
+			map[string]string{"requirepass": redacted, "masterauth": redacted, "maxmemory": "0"},
+			false,
+		},
+		{
+			"no password",
+			[]interface{}{"requirepass", "", "save", "3600 1 300 100"},
+			map[string]string{"requirepass": "", "save": "3600 1 300 100"},
+			false,
+		},
+		{"odd reply", []interface{}{"maxmemory"}, nil, true},
+		{"not an array", "OK", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConfig(tt.reply)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
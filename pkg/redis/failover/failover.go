@@ -0,0 +1,288 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package failover holds the failover decision logic of the Redis replication.
+// The decisions are pure functions of the observed topology: they neither talk to Redis nor depend on
+// the order of the observations beyond the order of the nodes, hence they are deterministic and can be
+// simulated exhaustively. The redis package observes the instances, decides and carries out the decisions.
+package failover
+
+import "errors"
+
+// MinimumSize is the quorum of the replication: the minimum number of reachable instances
+// the replication is reconfigured with. It reflects a simple master - replica pair.
+const MinimumSize = 2
+
+// ErrNoCandidates means that the master has been lost and none of the replicas is eligible for promotion
+var ErrNoCandidates = errors.New("no replicas eligible for promotion")
+
+// Role is the replication role of a node
+type Role int
+
+// Roles as reported by INFO REPLICATION
+const (
+	Master Role = iota
+	Replica
+)
+
+func (r Role) String() string {
+	if r == Master {
+		return "master"
+	}
+	return "replica"
+}
+
+// Node is the observed replication state of an instance
+type Node struct {
+	// ID identifies the node, e.g. its address
+	ID   string
+	Role Role
+	// Offset is the replication offset: master_repl_offset of a master, slave_repl_offset of a replica
+	Offset int
+
+	// ConnectedReplicas is the number of replicas connected to a master
+	ConnectedReplicas int
+	// Replicas are the IDs of the replicas a master reports connected
+	Replicas []string
+	// Known is set for the master elected previously, it is kept as the master until its replicas reconnect
+	// unless it has lost the data the replicas hold, see Working
+	Known bool
+
+	// Priority is the replica priority, the replicas with the zero priority are never promoted
+	Priority int
+	// Excluded replicas are never promoted either, e.g. the ones stuck in the full synchronization
+	Excluded bool
+}
+
+// Topology is the observed state of the replication
+type Topology []Node
+
+// QuorumMet reports whether enough instances are reachable for the replication to be reconfigured
+func QuorumMet(reachable int) bool {
+	return reachable >= MinimumSize
+}
+
+// Eligible reports whether the node can be promoted to master
+func Eligible(n Node) bool {
+	return n.Role == Replica && n.Priority != 0 && !n.Excluded
+}
+
+// Better reports whether a is preferred over b for promotion: the lower replica priority wins,
+// the higher replication offset, the least data to be lost, breaks the tie
+func Better(a, b Node) bool {
+	if a.Priority == b.Priority {
+		return a.Offset > b.Offset
+	}
+	return a.Priority < b.Priority
+}
+
+// Working reports whether the node i is a working master: one with replicas connected, or the master elected
+// previously as long as no eligible replica is ahead of it. A replica ahead of the known master holds the data
+// the master has lost, e.g. the master restarted without persistence under the same name.
+func Working(t Topology, i int) bool {
+	if t[i].Role != Master {
+		return false
+	}
+	if t[i].ConnectedReplicas > 0 {
+		return true
+	}
+	if !t[i].Known {
+		return false
+	}
+	for j := range t {
+		if Eligible(t[j]) && t[j].Offset > t[i].Offset {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectMaster returns the index of the current master, or -1 if it has been lost and a replica has to be promoted.
+// A working master is the source of truth, see Working.
+// Otherwise the master is considered lost as long as any eligible replica remains: its data must not be
+// discarded by making it a replica of an empty standalone instance, e.g. the restarted master without persistence.
+// The topology of standalone instances only is the initial state, the first node is chosen then.
+// A standalone master is preferred over the replicas not eligible for promotion, otherwise
+// it would become an eligible replica and the next decision would promote it.
+func SelectMaster(t Topology) int {
+	for i := range t {
+		// replicas can also have their own replicas
+		if Working(t, i) {
+			return i
+		}
+	}
+
+	for i := range t {
+		if Eligible(t[i]) {
+			return -1
+		}
+	}
+
+	for i := range t {
+		if t[i].Role == Master {
+			return i
+		}
+	}
+	if len(t) > 0 {
+		return 0
+	}
+	return -1
+}
+
+// Candidate returns the index of the replica to be promoted to master, ErrNoCandidates if none is eligible
+func Candidate(t Topology) (int, error) {
+	candidate := -1
+	for i := range t {
+		if Eligible(t[i]) && (candidate < 0 || Better(t[i], t[candidate])) {
+			candidate = i
+		}
+	}
+	if candidate < 0 {
+		return -1, ErrNoCandidates
+	}
+	return candidate, nil
+}
+
+// Orphans returns the indexes of the nodes to be reconfigured as replicas of the master:
+// all the nodes but the master itself and the replicas it reports connected
+func Orphans(t Topology, master int) []int {
+	connected := make(map[string]bool, len(t[master].Replicas))
+	for _, id := range t[master].Replicas {
+		connected[id] = true
+	}
+
+	var orphans []int
+	for i := range t {
+		if i != master && !connected[t[i].ID] {
+			orphans = append(orphans, i)
+		}
+	}
+	return orphans
+}
+
+// Successor returns the index of the replica the master role is handed over to before the master goes away,
+// e.g. removed by a scale-down: the best eligible replica among the kept ones the master reports connected.
+// ErrNoCandidates is returned if none of them qualifies.
+func Successor(t Topology, master int, kept func(Node) bool) (int, error) {
+	connected := make(map[string]bool, len(t[master].Replicas))
+	for _, id := range t[master].Replicas {
+		connected[id] = true
+	}
+
+	successor := -1
+	for i := range t {
+		if i == master || !connected[t[i].ID] || !Eligible(t[i]) || !kept(t[i]) {
+			continue
+		}
+		if successor < 0 || Better(t[i], t[successor]) {
+			successor = i
+		}
+	}
+	if successor < 0 {
+		return -1, ErrNoCandidates
+	}
+	return successor, nil
+}
+
+// InSync reports whether the replica has caught up with the master, i.e. the master role can be handed over
+// to it without losing the writes. The offsets are expected to be observed while the writes are paused.
+func InSync(master, replica Node) bool {
+	return WithinLag(master, replica, 0)
+}
+
+// WithinLag reports whether the replica lags behind the master by at most maxLag bytes,
+// i.e. at most maxLag bytes of the writes are lost once the master role is handed over to it
+func WithinLag(master, replica Node, maxLag int) bool {
+	return replica.Offset+maxLag >= master.Offset
+}
+
+// Decision is the outcome of Decide
+type Decision struct {
+	// Master is the ID of the master, either the current one or the promoted replica
+	Master string
+	// Promote is set if the master has been lost and the replica Master has to be promoted
+	Promote bool
+	// Reconfigure are the IDs of the nodes to be reconfigured as replicas of Master
+	Reconfigure []string
+}
+
+// Decide decides on the reconfiguration of the topology. The replicas are reconfigured based on the state
+// of the promoted replica observed before the promotion, the caller carrying out the promotion is expected
+// to call Orphans once the promoted master is observed again. Nothing is decided for the empty topology.
+func Decide(t Topology) (Decision, error) {
+	if len(t) == 0 {
+		return Decision{}, nil
+	}
+
+	var decision Decision
+	master := SelectMaster(t)
+	if master < 0 {
+		var err error
+		if master, err = Candidate(t); err != nil {
+			return Decision{}, err
+		}
+		decision.Promote = true
+	}
+	decision.Master = t[master].ID
+
+	for _, i := range Orphans(t, master) {
+		decision.Reconfigure = append(decision.Reconfigure, t[i].ID)
+	}
+	return decision, nil
+}
+
+// Apply returns the topology the decision results in, provided that every command succeeds and the replicas
+// synchronize instantly. The topology is not modified. It allows to simulate the consecutive reconfigurations.
+func Apply(t Topology, d Decision) Topology {
+	applied := make(Topology, len(t))
+	copy(applied, t)
+
+	master := -1
+	for i := range applied {
+		if applied[i].ID == d.Master {
+			master = i
+		}
+	}
+	if master < 0 {
+		return applied
+	}
+
+	reconfigured := make(map[string]bool, len(d.Reconfigure))
+	for _, id := range d.Reconfigure {
+		reconfigured[id] = true
+	}
+
+	m := &applied[master]
+	if d.Promote {
+		m.Role, m.Priority, m.Known, m.Replicas, m.ConnectedReplicas = Master, 0, true, nil, 0
+	}
+	m.Replicas = append([]string(nil), m.Replicas...)
+	for i := range applied {
+		if !reconfigured[applied[i].ID] {
+			continue
+		}
+		if applied[i].Role == Master {
+			// the standalone masters keep the default priority once they become replicas
+			applied[i].Priority = defaultPriority
+		}
+		applied[i].Role, applied[i].Offset, applied[i].Replicas, applied[i].ConnectedReplicas, applied[i].Known =
+			Replica, m.Offset, nil, 0, false
+		m.Replicas = append(m.Replicas, applied[i].ID)
+	}
+	m.ConnectedReplicas = len(m.Replicas)
+	return applied
+}
+
+// defaultPriority is the default replica-priority of Redis
+const defaultPriority = 100
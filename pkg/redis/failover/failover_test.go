@@ -0,0 +1,417 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failover
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name     string
+		topology Topology
+		want     Decision
+		wantErr  bool
+	}{
+		{"empty", Topology{}, Decision{}, false},
+		{
+			"initial setup",
+			Topology{
+				{ID: "a", Role: Master},
+				{ID: "b", Role: Master},
+				{ID: "c", Role: Master},
+			},
+			Decision{Master: "a", Reconfigure: []string{"b", "c"}},
+			false,
+		},
+		{
+			"standalone master preferred over zero priority replicas",
+			Topology{
+				{ID: "a", Role: Replica, Offset: 10},
+				{ID: "b", Role: Master},
+			},
+			Decision{Master: "b", Reconfigure: []string{"a"}},
+			false,
+		},
+		{
+			"normally working",
+			Topology{
+				{ID: "a", Role: Replica, Priority: 100, Offset: 10},
+				{ID: "b", Role: Master, Offset: 10, ConnectedReplicas: 2, Replicas: []string{"a", "c"}},
+				{ID: "c", Role: Replica, Priority: 100, Offset: 10},
+			},
+			Decision{Master: "b"},
+			false,
+		},
+		{
+			"new replica discovered",
+			Topology{
+				{ID: "a", Role: Master, Offset: 10, ConnectedReplicas: 1, Replicas: []string{"b"}},
+				{ID: "b", Role: Replica, Priority: 100, Offset: 10},
+				{ID: "c", Role: Master},
+			},
+			Decision{Master: "a", Reconfigure: []string{"c"}},
+			false,
+		},
+		{
+			"known master waiting for its replicas",
+			Topology{
+				{ID: "a", Role: Replica, Priority: 100, Offset: 10},
+				{ID: "b", Role: Master, Offset: 10, Known: true},
+			},
+			Decision{Master: "b", Reconfigure: []string{"a"}},
+			false,
+		},
+		{
+			"known master restarted empty",
+			Topology{
+				{ID: "a", Role: Master, Known: true},
+				{ID: "b", Role: Replica, Priority: 100, Offset: 10},
+			},
+			Decision{Master: "b", Promote: true, Reconfigure: []string{"a"}},
+			false,
+		},
+		{
+			"known master ahead of its replicas",
+			Topology{
+				{ID: "a", Role: Master, Offset: 12, Known: true},
+				{ID: "b", Role: Replica, Priority: 100, Offset: 10},
+			},
+			Decision{Master: "a", Reconfigure: []string{"b"}},
+			false,
+		},
+		{
+			"master lost, the restarted master is empty",
+			Topology{
+				{ID: "a", Role: Master},
+				{ID: "b", Role: Replica, Priority: 100, Offset: 10},
+				{ID: "c", Role: Replica, Priority: 100, Offset: 12},
+			},
+			Decision{Master: "c", Promote: true, Reconfigure: []string{"a", "b"}},
+			false,
+		},
+		{
+			"master lost, the lower priority wins",
+			Topology{
+				{ID: "a", Role: Replica, Priority: 100, Offset: 12},
+				{ID: "b", Role: Replica, Priority: 10, Offset: 10},
+			},
+			Decision{Master: "b", Promote: true, Reconfigure: []string{"a"}},
+			false,
+		},
+		{
+			"master lost, the zero priority is never promoted",
+			Topology{
+				{ID: "a", Role: Replica, Priority: 0, Offset: 12},
+				{ID: "b", Role: Replica, Priority: 100, Offset: 10},
+			},
+			Decision{Master: "b", Promote: true, Reconfigure: []string{"a"}},
+			false,
+		},
+		{
+			"master lost, the first of the equal candidates is promoted",
+			Topology{
+				{ID: "a", Role: Replica, Priority: 100, Offset: 10},
+				{ID: "b", Role: Replica, Priority: 100, Offset: 10},
+			},
+			Decision{Master: "a", Promote: true, Reconfigure: []string{"b"}},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decide(tt.topology)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Decide() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decide() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCandidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		topology Topology
+		want     int
+		wantErr  bool
+	}{
+		{"empty", Topology{}, -1, true},
+		{"masters only", Topology{{Role: Master}, {Role: Master}}, -1, true},
+		{"zero priority only", Topology{{Role: Replica}, {Role: Replica, Offset: 10}}, -1, true},
+		{
+			"excluded",
+			Topology{{Role: Replica, Priority: 100, Offset: 1}, {Role: Replica, Priority: 100, Offset: 2, Excluded: true}},
+			0,
+			false,
+		},
+		{
+			"by offset",
+			Topology{{Role: Replica, Priority: 100, Offset: 1}, {Role: Replica, Priority: 100, Offset: 2}},
+			1,
+			false,
+		},
+		{
+			"by priority",
+			Topology{{Role: Replica, Priority: 10, Offset: 1}, {Role: Replica, Priority: 100, Offset: 2}},
+			0,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Candidate(tt.topology)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Candidate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Candidate() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuccessor(t *testing.T) {
+	master := Node{ID: "a", Role: Master, ConnectedReplicas: 3, Replicas: []string{"b", "c", "d"}}
+	keep := func(ids ...string) func(Node) bool {
+		return func(n Node) bool {
+			for _, id := range ids {
+				if n.ID == id {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	tests := []struct {
+		name     string
+		topology Topology
+		kept     func(Node) bool
+		want     int
+		wantErr  bool
+	}{
+		{
+			"best kept replica",
+			Topology{
+				master,
+				{ID: "b", Role: Replica, Priority: 100, Offset: 10},
+				{ID: "c", Role: Replica, Priority: 100, Offset: 20},
+				{ID: "d", Role: Replica, Priority: 100, Offset: 30},
+			},
+			keep("b", "c"),
+			2,
+			false,
+		},
+		{
+			"by priority",
+			Topology{
+				master,
+				{ID: "b", Role: Replica, Priority: 10, Offset: 10},
+				{ID: "c", Role: Replica, Priority: 100, Offset: 20},
+			},
+			keep("b", "c"),
+			1,
+			false,
+		},
+		{
+			"disconnected replica",
+			Topology{
+				master,
+				{ID: "b", Role: Replica, Priority: 100, Offset: 10},
+				{ID: "e", Role: Replica, Priority: 100, Offset: 20},
+			},
+			keep("b", "e"),
+			1,
+			false,
+		},
+		{
+			"zero priority",
+			Topology{master, {ID: "b", Role: Replica, Offset: 10}},
+			keep("b"),
+			-1,
+			true,
+		},
+		{
+			"nothing kept",
+			Topology{master, {ID: "b", Role: Replica, Priority: 100}},
+			keep(),
+			-1,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Successor(tt.topology, 0, tt.kept)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Successor() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Successor() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInSync(t *testing.T) {
+	tests := []struct {
+		name    string
+		master  Node
+		replica Node
+		want    bool
+	}{
+		{"caught up", Node{Offset: 100}, Node{Offset: 100}, true},
+		{"lagging", Node{Offset: 100}, Node{Offset: 90}, false},
+		{"ahead", Node{Offset: 100}, Node{Offset: 110}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InSync(tt.master, tt.replica); got != tt.want {
+				t.Errorf("InSync() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithinLag(t *testing.T) {
+	tests := []struct {
+		name    string
+		master  Node
+		replica Node
+		maxLag  int
+		want    bool
+	}{
+		{"caught up", Node{Offset: 100}, Node{Offset: 100}, 0, true},
+		{"lagging", Node{Offset: 100}, Node{Offset: 90}, 0, false},
+		{"lagging within", Node{Offset: 100}, Node{Offset: 90}, 10, true},
+		{"lagging beyond", Node{Offset: 100}, Node{Offset: 89}, 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WithinLag(tt.master, tt.replica, tt.maxLag); got != tt.want {
+				t.Errorf("WithinLag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// topologies enumerates all the topologies of up to size nodes built of the node states
+func topologies(size int, states []Node) []Topology {
+	all := []Topology{{}}
+	for previous := all; size > 0; size-- {
+		var next []Topology
+		for _, t := range previous {
+			for _, state := range states {
+				n := state
+				n.ID = fmt.Sprintf("%d", len(t))
+				next = append(next, append(append(Topology(nil), t...), n))
+			}
+		}
+		all = append(all, next...)
+		previous = next
+	}
+
+	// the connected masters report all the replicas
+	for _, t := range all {
+		for i := range t {
+			if t[i].Role != Master || t[i].ConnectedReplicas == 0 {
+				continue
+			}
+			for j := range t {
+				if t[j].Role == Replica {
+					t[i].Replicas = append(t[i].Replicas, t[j].ID)
+				}
+			}
+		}
+	}
+	return all
+}
+
+// TestSimulate runs the decisions on all the small topologies and checks the failover invariants
+func TestSimulate(t *testing.T) {
+	var states []Node
+	for _, offset := range []int{0, 10} {
+		for _, connected := range []int{0, 1} {
+			for _, known := range []bool{false, true} {
+				states = append(states, Node{Role: Master, Offset: offset, ConnectedReplicas: connected, Known: known})
+			}
+		}
+		for _, priority := range []int{0, 10, 100} {
+			states = append(states, Node{Role: Replica, Offset: offset, Priority: priority})
+		}
+	}
+
+	for _, topology := range topologies(3, states) {
+		decision, err := Decide(topology)
+		if err != nil {
+			t.Fatalf("Decide(%+v) error = %v", topology, err)
+		}
+		if len(topology) == 0 {
+			continue
+		}
+
+		working := -1
+		for i := range topology {
+			if Working(topology, i) && working < 0 {
+				working = i
+			}
+		}
+		switch {
+		case working >= 0 && (decision.Promote || decision.Master != topology[working].ID):
+			t.Errorf("%+v: the working master %s is replaced: %+v", topology, topology[working].ID, decision)
+		case decision.Promote:
+			promoted := topology[indexOf(topology, decision.Master)]
+			if !Eligible(promoted) {
+				t.Errorf("%+v: ineligible %s is promoted", topology, promoted.ID)
+			}
+			for _, n := range topology {
+				if Eligible(n) && Better(n, promoted) {
+					t.Errorf("%+v: %s is promoted over the better %s", topology, promoted.ID, n.ID)
+				}
+			}
+		}
+		for _, id := range decision.Reconfigure {
+			if id == decision.Master {
+				t.Errorf("%+v: the master %s is reconfigured as a replica of itself", topology, id)
+			}
+		}
+
+		// the reconfiguration converges at once
+		applied := Apply(topology, decision)
+		next, err := Decide(applied)
+		if err != nil {
+			t.Fatalf("Decide(%+v) error = %v", applied, err)
+		}
+		if want := (Decision{Master: decision.Master}); !reflect.DeepEqual(next, want) {
+			t.Errorf("%+v: %+v is followed by %+v, want %+v", topology, decision, next, want)
+		}
+	}
+}
+
+// indexOf returns the index of the node by ID
+func indexOf(t Topology, id string) int {
+	for i := range t {
+		if t[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
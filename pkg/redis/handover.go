@@ -0,0 +1,192 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis/failover"
+)
+
+const (
+	// handoverSyncTimeout is the time the successor is given to catch up with the paused master by default
+	handoverSyncTimeout = 2 * time.Second
+	// handoverPromotionTime bounds the time the promotion of the successor takes once it is in sync
+	handoverPromotionTime = 3 * time.Second
+	// handoverSyncInterval is the interval the replication offsets are compared at
+	handoverSyncInterval = 100 * time.Millisecond
+)
+
+// SyncPolicy is the condition the successor has to meet before the master role is handed over to it
+type SyncPolicy struct {
+	// MaxLag is the replication lag in bytes the successor is allowed to fall behind the paused master.
+	// The successor has to catch up with the master if zero.
+	MaxLag int64
+	// Timeout is the time the successor is given to meet the condition, 2s if zero
+	Timeout time.Duration
+}
+
+func (p SyncPolicy) timeout() time.Duration {
+	if p.Timeout <= 0 {
+		return handoverSyncTimeout
+	}
+	return p.Timeout
+}
+
+// pause returns the time the writes to the master are paused for during the handover
+func (p SyncPolicy) pause() time.Duration {
+	return p.timeout() + handoverPromotionTime
+}
+
+// ErrNotInSync is returned by Handover if the successor has not caught up with the master in time.
+// The master is left intact, the handover is expected to be retried later.
+var ErrNotInSync = errors.New("the successor has not caught up with the master")
+
+// offset returns the current replication offset of the instance
+func (i *instance) offset(ctx context.Context) (int, error) {
+	info, err := i.getInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// the fields are parsed into a copy, refresh accumulates the replicas of a master
+	observed := instance{Address: i.Address}
+	if err := observed.refresh(info); err != nil {
+		return 0, err
+	}
+	return observed.replicationOffset, nil
+}
+
+// inSync waits for the replica to meet the policy for up to its timeout
+func inSync(ctx context.Context, master, replica *instance, policy SyncPolicy) (bool, error) {
+	for deadline := time.Now().Add(policy.timeout()); ; {
+		masterOffset, err := master.offset(ctx)
+		if err != nil {
+			return false, err
+		}
+		replicaOffset, err := replica.offset(ctx)
+		if err != nil {
+			return false, err
+		}
+		if failover.WithinLag(failover.Node{Offset: masterOffset}, failover.Node{Offset: replicaOffset}, int(policy.MaxLag)) {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		select {
+		case <-time.After(handoverSyncInterval):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+// observeOffsets updates the offsets of the replicas in the topology with the current ones.
+// The offsets observed by Refresh are outdated by the time the writes to the master are paused.
+func (ins instances) observeOffsets(ctx context.Context, t failover.Topology, replicas []int) error {
+	offsets := make([]int, len(replicas))
+	errs := make([]error, len(replicas))
+	var wg sync.WaitGroup
+	wg.Add(len(replicas))
+	for j, i := range replicas {
+		go func(j, i int) {
+			defer wg.Done()
+			offsets[j], errs[j] = ins[i].offset(ctx)
+		}(j, i)
+	}
+	wg.Wait()
+
+	for j, i := range replicas {
+		if errs[j] != nil {
+			return fmt.Errorf("error observing the replication offset of %s: %s", ins[i].Address, errs[j])
+		}
+		t[i].Offset = offsets[j]
+	}
+	return nil
+}
+
+// Handover hands the master role over to the best replica among the kept ones before the master goes away,
+// e.g. removed by a scale-down. The writes to the master are paused with CLIENT PAUSE WRITE first,
+// then the kept replicas are compared by their current offsets and the most caught-up one is chosen as the successor.
+// It is promoted once it meets the policy and the rest of the instances, the former master included, are
+// reconfigured as its replicas. Redis prior to 6.2 can not pause the writes only, the successor is promoted
+// once it is observed in sync then, the writes accepted meanwhile are lost.
+// WAIT is not used: it is blocked by the pause and only counts the acknowledgements of the writes of the caller.
+// The address of the new master is returned. ErrNotInSync is returned if the successor lags behind.
+func (ins instances) Handover(ctx context.Context, kept func(Address) bool, policy SyncPolicy) (Address, error) {
+	selected := ins.selectMaster()
+	if selected == nil {
+		return Address{}, errors.New("no master to hand over from")
+	}
+	m := ins.index(selected.Address.String())
+	t := ins.topology()
+	isKept := func(n failover.Node) bool {
+		return kept(ins[ins.index(n.ID)].Address)
+	}
+	if _, err := failover.Successor(t, m, isKept); err != nil {
+		return Address{}, fmt.Errorf("no replica to hand the master role over to: %s", err)
+	}
+	master := &ins[m]
+
+	// the pause is lifted once the former master is a replica: the paused writes are rejected rather than lost
+	if err := master.client.Do("CLIENT", "PAUSE",
+		strconv.FormatInt(int64(policy.pause()/time.Millisecond), 10), "WRITE").Err(); err == nil {
+		defer master.client.Do("CLIENT", "UNPAUSE")
+	}
+
+	var candidates []int
+	for i := range t {
+		if i != m && failover.Eligible(t[i]) && isKept(t[i]) {
+			candidates = append(candidates, i)
+		}
+	}
+	if err := ins.observeOffsets(ctx, t, candidates); err != nil {
+		return Address{}, err
+	}
+	s, err := failover.Successor(t, m, isKept)
+	if err != nil {
+		return Address{}, fmt.Errorf("no replica to hand the master role over to: %s", err)
+	}
+	successor := &ins[s]
+
+	synced, err := inSync(ctx, master, successor, policy)
+	if err != nil {
+		return Address{}, err
+	}
+	if !synced {
+		return Address{}, ErrNotInSync
+	}
+
+	if err := successor.promote(ctx); err != nil {
+		return Address{}, err
+	}
+	master.knownMaster = false
+
+	var replicas instances
+	for i := range ins {
+		if i != s {
+			replicas = append(replicas, ins[i])
+		}
+	}
+	if err := replicas.reconfigureAsReplicasOf(ctx, successor.Address); err != nil {
+		return successor.Address, err
+	}
+	return successor.Address, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis/failover"
+)
+
+func TestSyncPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      SyncPolicy
+		wantTimeout time.Duration
+		wantPause   time.Duration
+	}{
+		{"default", SyncPolicy{}, 2 * time.Second, 5 * time.Second},
+		{"timeout", SyncPolicy{MaxLag: 1024, Timeout: 10 * time.Second}, 10 * time.Second, 13 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.timeout(); got != tt.wantTimeout {
+				t.Errorf("timeout() = %v, want %v", got, tt.wantTimeout)
+			}
+			if got := tt.policy.pause(); got != tt.wantPause {
+				t.Errorf("pause() = %v, want %v", got, tt.wantPause)
+			}
+		})
+	}
+}
+
+func TestInstances_observeOffsets(t *testing.T) {
+	withOffset := func(offset string) *fakeClient {
+		return &fakeClient{info: strings.Replace(replicaInfo, "slave_repl_offset:47054", "slave_repl_offset:"+offset, 1)}
+	}
+	ins := instances{
+		{Address: Address{"172.18.0.2", "6379"}, client: &fakeClient{info: masterInfo}},
+		{Address: Address{"172.18.0.4", "6379"}, client: withOffset("47100")},
+		{Address: Address{"172.18.0.5", "6379"}, client: withOffset("47200")},
+	}
+	// the offsets observed before the writes were paused
+	topology := failover.Topology{{Offset: 47300}, {Offset: 47054}, {Offset: 47054}}
+
+	if err := ins.observeOffsets(context.Background(), topology, []int{1, 2}); err != nil {
+		t.Fatalf("observeOffsets() error = %v", err)
+	}
+	for i, want := range []int{47300, 47100, 47200} {
+		if got := topology[i].Offset; got != want {
+			t.Errorf("offset of %s = %d, want %d", ins[i].Address, got, want)
+		}
+	}
+}
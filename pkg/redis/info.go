@@ -0,0 +1,342 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// the role field values of INFO replication
+const (
+	infoRoleMaster  = "master"
+	infoRoleReplica = "slave"
+)
+
+// Info is the decoded INFO reply. Only the sections present in the reply are decoded, the fields missing
+// from the reply are left zero. All the fields are kept in Sections, the ones not decoded into the struct included.
+type Info struct {
+	Server      ServerInfo
+	Clients     ClientsInfo
+	Memory      MemoryInfo
+	Persistence PersistenceInfo
+	Stats       StatsInfo
+	Replication ReplicationInfo
+	// Keyspace are the databases by number, e.g. 0 for db0
+	Keyspace map[int]DatabaseInfo
+
+	// Sections are the raw fields by the section name in lower case, e.g. replication.
+	// The fields preceding any section header are kept under the empty name.
+	Sections map[string]map[string]string
+}
+
+// ServerInfo is the decoded INFO server section
+type ServerInfo struct {
+	RedisVersion  string
+	RedisMode     string
+	RunID         string
+	TCPPort       int
+	UptimeSeconds int64
+}
+
+// ClientsInfo is the decoded INFO clients section
+type ClientsInfo struct {
+	ConnectedClients int
+	BlockedClients   int
+	MaxClients       int
+}
+
+// MemoryInfo is the decoded INFO memory section
+type MemoryInfo struct {
+	UsedMemory       int64
+	UsedMemoryRSS    int64
+	UsedMemoryPeak   int64
+	MaxMemory        int64
+	MaxMemoryPolicy  string
+	MemFragmentation float64
+}
+
+// PersistenceInfo is the decoded INFO persistence section
+type PersistenceInfo struct {
+	// Loading is set while the dataset is being loaded from the disk
+	Loading             bool
+	RDBBgsaveInProgress bool
+	RDBLastBgsaveStatus string
+	AOFEnabled          bool
+	AOFLastWriteStatus  string
+	// AOFDelayedFsync counts the writes delayed by the pending fsync with appendfsync everysec
+	AOFDelayedFsync int
+}
+
+// StatsInfo is the decoded INFO stats section
+type StatsInfo struct {
+	ExpiredKeys int64
+	EvictedKeys int64
+}
+
+// ReplicationInfo is the decoded INFO replication section
+type ReplicationInfo struct {
+	// Role is master or slave
+	Role string
+
+	// master-specific fields
+	ConnectedReplicas int
+	Replicas          []ReplicaInfo
+	// MasterFailoverState is the state of the coordinated FAILOVER of Redis 6.2+, e.g. no-failover
+	MasterFailoverState string
+	// MasterReplOffset is reported by the replicas as well, the offset of their own replication stream
+	MasterReplOffset int64
+
+	// replica-specific fields
+	MasterHost       string
+	MasterPort       string
+	MasterLinkStatus string
+	// MasterLastIOSecondsAgo is -1 while the replica is disconnected from the master
+	MasterLastIOSecondsAgo int
+	MasterSyncInProgress   bool
+	ReplicaReplOffset      int64
+	ReplicaPriority        int
+}
+
+// ReplicaInfo is a replica connected to the master as listed by INFO replication, e.g.
+// slave0:ip=10.0.0.2,port=6379,state=online,offset=47054,lag=1
+type ReplicaInfo struct {
+	Address
+	// State is online once the replica is synchronized, e.g. wait_bgsave or send_bulk before
+	State  string
+	Offset int64
+	Lag    int
+}
+
+// DatabaseInfo is a database as listed by INFO keyspace, e.g. db0:keys=1,expires=0,avg_ttl=0
+type DatabaseInfo struct {
+	Keys    int64
+	Expires int64
+	AvgTTL  int64
+}
+
+// ParseInfo decodes the INFO reply. The lines are terminated either by CRLF or by LF.
+// The fields with the values not matching their types are left zero, the malformed replica lines are skipped.
+// An error is returned only if the role is present but neither master nor slave.
+func ParseInfo(reply string) (Info, error) {
+	info := Info{Sections: make(map[string]map[string]string)}
+	section := ""
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			section = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(line, "#")))
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if info.Sections[section] == nil {
+			info.Sections[section] = make(map[string]string)
+		}
+		info.Sections[section][kv[0]] = kv[1]
+		info.decode(kv[0], kv[1])
+	}
+
+	if role := info.Replication.Role; role != "" && role != infoRoleMaster && role != infoRoleReplica {
+		return Info{}, errors.New("the role is wrong")
+	}
+	return info, nil
+}
+
+// Field returns the raw value of the field of any section, empty if it is missing
+func (info Info) Field(name string) string {
+	for _, fields := range info.Sections {
+		if value, ok := fields[name]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// decode sets the struct field of the INFO field. The field names are unique across the sections.
+func (info *Info) decode(name, value string) {
+	switch name {
+	// server
+	case "redis_version":
+		info.Server.RedisVersion = value
+	case "redis_mode":
+		info.Server.RedisMode = value
+	case "run_id":
+		info.Server.RunID = value
+	case "tcp_port":
+		info.Server.TCPPort = parseInt(value)
+	case "uptime_in_seconds":
+		info.Server.UptimeSeconds = parseInt64(value)
+
+	// clients
+	case "connected_clients":
+		info.Clients.ConnectedClients = parseInt(value)
+	case "blocked_clients":
+		info.Clients.BlockedClients = parseInt(value)
+	case "maxclients":
+		info.Clients.MaxClients = parseInt(value)
+
+	// memory
+	case "used_memory":
+		info.Memory.UsedMemory = parseInt64(value)
+	case "used_memory_rss":
+		info.Memory.UsedMemoryRSS = parseInt64(value)
+	case "used_memory_peak":
+		info.Memory.UsedMemoryPeak = parseInt64(value)
+	case "maxmemory":
+		info.Memory.MaxMemory = parseInt64(value)
+	case "maxmemory_policy":
+		info.Memory.MaxMemoryPolicy = value
+	case "mem_fragmentation_ratio":
+		info.Memory.MemFragmentation, _ = strconv.ParseFloat(value, 64)
+
+	// persistence
+	case "loading":
+		info.Persistence.Loading = value == "1"
+	case "rdb_bgsave_in_progress":
+		info.Persistence.RDBBgsaveInProgress = value == "1"
+	case rdbLastBgsaveStatus:
+		info.Persistence.RDBLastBgsaveStatus = value
+	case aofEnabled:
+		info.Persistence.AOFEnabled = value == "1"
+	case aofLastWriteStatus:
+		info.Persistence.AOFLastWriteStatus = value
+	case aofDelayedFsync:
+		info.Persistence.AOFDelayedFsync = parseInt(value)
+
+	// stats
+	case expiredKeys:
+		info.Stats.ExpiredKeys = parseInt64(value)
+	case evictedKeys:
+		info.Stats.EvictedKeys = parseInt64(value)
+
+	// replication
+	case "role":
+		info.Replication.Role = value
+	case connectedReplicas:
+		info.Replication.ConnectedReplicas = parseInt(value)
+	case "master_failover_state":
+		info.Replication.MasterFailoverState = value
+	case masterReplOffset:
+		info.Replication.MasterReplOffset = parseInt64(value)
+	case masterHost:
+		info.Replication.MasterHost = parseHost(value)
+	case masterPort:
+		info.Replication.MasterPort = value
+	case masterLinkStatus:
+		info.Replication.MasterLinkStatus = value
+	case masterLastIOSecondsAgo:
+		info.Replication.MasterLastIOSecondsAgo = parseInt(value)
+	case "master_sync_in_progress":
+		info.Replication.MasterSyncInProgress = value == "1"
+	case replicationOffset:
+		info.Replication.ReplicaReplOffset = parseInt64(value)
+	case replicaPriority, "replica_priority":
+		info.Replication.ReplicaPriority = parseInt(value)
+
+	default:
+		if replica, ok := parseReplicaInfo(name, value); ok {
+			info.Replication.Replicas = append(info.Replication.Replicas, replica)
+		} else if number, database, ok := parseDatabaseInfo(name, value); ok {
+			if info.Keyspace == nil {
+				info.Keyspace = make(map[int]DatabaseInfo)
+			}
+			info.Keyspace[number] = database
+		}
+	}
+}
+
+// parseReplicaInfo parses the slaveN field of a replica connected to the master.
+// The replicas without a valid IP address or port are not listed.
+func parseReplicaInfo(name, value string) (ReplicaInfo, bool) {
+	if !strings.HasPrefix(name, "slave") || !isNumber(strings.TrimPrefix(name, "slave")) {
+		return ReplicaInfo{}, false
+	}
+
+	var replica ReplicaInfo
+	for _, field := range strings.Split(value, ",") {
+		switch kv := strings.SplitN(field, "=", 2); {
+		case len(kv) != 2:
+		case kv[0] == "ip":
+			replica.Host = parseHost(kv[1])
+		case kv[0] == "port":
+			replica.Port = kv[1]
+		case kv[0] == "state":
+			replica.State = kv[1]
+		case kv[0] == "offset":
+			replica.Offset = parseInt64(kv[1])
+		case kv[0] == "lag":
+			replica.Lag = parseInt(kv[1])
+		}
+	}
+	if net.ParseIP(replica.Host) == nil {
+		return ReplicaInfo{}, false
+	}
+	if port, err := strconv.Atoi(replica.Port); err != nil || port <= 0 || port > 65535 {
+		return ReplicaInfo{}, false
+	}
+	return replica, true
+}
+
+// parseDatabaseInfo parses the dbN field of INFO keyspace
+func parseDatabaseInfo(name, value string) (int, DatabaseInfo, bool) {
+	if !strings.HasPrefix(name, "db") || !isNumber(strings.TrimPrefix(name, "db")) {
+		return 0, DatabaseInfo{}, false
+	}
+	number, _ := strconv.Atoi(strings.TrimPrefix(name, "db"))
+
+	var database DatabaseInfo
+	for _, field := range strings.Split(value, ",") {
+		switch kv := strings.SplitN(field, "=", 2); {
+		case len(kv) != 2:
+		case kv[0] == "keys":
+			database.Keys = parseInt64(kv[1])
+		case kv[0] == "expires":
+			database.Expires = parseInt64(kv[1])
+		case kv[0] == "avg_ttl":
+			database.AvgTTL = parseInt64(kv[1])
+		}
+	}
+	return number, database, true
+}
+
+// isNumber reports whether s is a non-empty string of decimal digits
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func parseInt(value string) int {
+	i, _ := strconv.Atoi(value)
+	return i
+}
+
+func parseInt64(value string) int64 {
+	i, _ := strconv.ParseInt(value, 10, 64)
+	return i
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    Info
+		wantErr bool
+	}{
+		{
+			name:  "master",
+			reply: masterInfo,
+			want: Info{Replication: ReplicationInfo{
+				Role:              infoRoleMaster,
+				ConnectedReplicas: 2,
+				Replicas: []ReplicaInfo{
+					{Address: Address{Host: "172.18.0.5", Port: "6379"}, State: "online", Offset: 47054, Lag: 1},
+					{Address: Address{Host: "172.18.0.4", Port: "6379"}, State: "online", Offset: 47040, Lag: 1},
+				},
+				MasterReplOffset: 47054,
+			}},
+		},
+		{
+			name:  "replica",
+			reply: replicaInfo,
+			want: Info{Replication: ReplicationInfo{
+				Role:                   infoRoleReplica,
+				MasterReplOffset:       47054,
+				MasterHost:             "172.18.0.2",
+				MasterPort:             "6379",
+				MasterLinkStatus:       "up",
+				MasterLastIOSecondsAgo: 4,
+				ReplicaReplOffset:      47054,
+				ReplicaPriority:        100,
+			}},
+		},
+		{
+			name: "IPv6",
+			reply: "role:master\nmaster_host:fd00:10:244::2\n" +
+				"slave0:ip=fd00:10:244::5,port=6379,state=online,offset=47054,lag=1\n" +
+				"slave1:ip=::ffff:172.18.0.4,port=6379,state=online,offset=47040,lag=1\n",
+			want: Info{Replication: ReplicationInfo{
+				Role:       infoRoleMaster,
+				MasterHost: "fd00:10:244::2",
+				Replicas: []ReplicaInfo{
+					{Address: Address{Host: "fd00:10:244::5", Port: "6379"}, State: "online", Offset: 47054, Lag: 1},
+					{Address: Address{Host: "172.18.0.4", Port: "6379"}, State: "online", Offset: 47040, Lag: 1},
+				},
+			}},
+		},
+		{
+			name:  "replica is synchronizing",
+			reply: "# Replication\r\nrole:master\r\nslave0:ip=10.0.0.2,port=6379,state=wait_bgsave,offset=0,lag=0\r\n",
+			want: Info{Replication: ReplicationInfo{
+				Role:     infoRoleMaster,
+				Replicas: []ReplicaInfo{{Address: Address{Host: "10.0.0.2", Port: "6379"}, State: "wait_bgsave"}},
+			}},
+		},
+		{
+			name:  "malformed replicas are skipped",
+			reply: "role:master\nslave0:ip=redis-0,port=6379,state=online\nslave1:ip=10.0.0.2,port=0,state=online\nslavex:ip=10.0.0.3,port=6379\n",
+			want:  Info{Replication: ReplicationInfo{Role: infoRoleMaster}},
+		},
+		{
+			name:  "replica disconnected",
+			reply: "role:slave\nmaster_link_status:down\nmaster_last_io_seconds_ago:-1\nmaster_sync_in_progress:1\n",
+			want: Info{Replication: ReplicationInfo{
+				Role:                   infoRoleReplica,
+				MasterLinkStatus:       "down",
+				MasterLastIOSecondsAgo: -1,
+				MasterSyncInProgress:   true,
+			}},
+		},
+		{
+			name:  "replica of hostname",
+			reply: "role:slave\nmaster_host:redis-example-0.redis-example-headless.default.svc.cluster.local\nmaster_port:6379\n",
+			want: Info{Replication: ReplicationInfo{
+				Role:       infoRoleReplica,
+				MasterHost: "redis-example-0.redis-example-headless.default.svc.cluster.local",
+				MasterPort: "6379",
+			}},
+		},
+		{
+			name:  "coordinated failover",
+			reply: "role:master\nmaster_failover_state:waiting-for-sync\n",
+			want:  Info{Replication: ReplicationInfo{Role: infoRoleMaster, MasterFailoverState: "waiting-for-sync"}},
+		},
+		{
+			name:  "persistence",
+			reply: persistenceInfo,
+			want: Info{Persistence: PersistenceInfo{
+				RDBLastBgsaveStatus: "err",
+				AOFEnabled:          true,
+				AOFLastWriteStatus:  "ok",
+				AOFDelayedFsync:     12,
+			}},
+		},
+		{
+			name: "server, clients, memory, stats and keyspace",
+			reply: "# Server\r\nredis_version:6.2.6\r\nredis_mode:standalone\r\ntcp_port:6379\r\nuptime_in_seconds:3600\r\n\r\n" +
+				"# Clients\r\nconnected_clients:5\r\nblocked_clients:1\r\nmaxclients:10000\r\n\r\n" +
+				"# Memory\r\nused_memory:1048576\r\nmaxmemory:0\r\nmaxmemory_policy:noeviction\r\nmem_fragmentation_ratio:1.50\r\n\r\n" +
+				"# Stats\r\nexpired_keys:7\r\nevicted_keys:2\r\n\r\n" +
+				"# Keyspace\r\ndb0:keys=10,expires=3,avg_ttl=1000\r\ndb2:keys=5,expires=0,avg_ttl=0\r\n",
+			want: Info{
+				Server:  ServerInfo{RedisVersion: "6.2.6", RedisMode: "standalone", TCPPort: 6379, UptimeSeconds: 3600},
+				Clients: ClientsInfo{ConnectedClients: 5, BlockedClients: 1, MaxClients: 10000},
+				Memory:  MemoryInfo{UsedMemory: 1048576, MaxMemoryPolicy: "noeviction", MemFragmentation: 1.5},
+				Stats:   StatsInfo{ExpiredKeys: 7, EvictedKeys: 2},
+				Keyspace: map[int]DatabaseInfo{
+					0: {Keys: 10, Expires: 3, AvgTTL: 1000},
+					2: {Keys: 5},
+				},
+			},
+		},
+		{
+			name:    "wrong role",
+			reply:   "role:sentinel\n",
+			wantErr: true,
+		},
+		{
+			name:  "empty",
+			reply: "",
+			want:  Info{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseInfo(tt.reply)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			// the raw fields are covered by TestInfo_Field
+			got.Sections = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInfo_sections(t *testing.T) {
+	info, err := ParseInfo(strings.Join([]string{persistenceInfo, masterInfo}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseInfo() error = %v", err)
+	}
+	if got := info.Sections["replication"]["repl_backlog_size"]; got != "1048576" {
+		t.Errorf("Sections[replication][repl_backlog_size] = %q, want %q", got, "1048576")
+	}
+	if got := info.Sections["persistence"]["rdb_last_save_time"]; got != "1580000000" {
+		t.Errorf("Sections[persistence][rdb_last_save_time] = %q, want %q", got, "1580000000")
+	}
+	if _, ok := info.Sections["persistence"]["role"]; ok {
+		t.Error("Sections[persistence] contains the field of the replication section")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	expiredKeys = "expired_keys"
+	evictedKeys = "evicted_keys"
+)
+
+// KeyspaceStats are the keyspace figures of an instance reported by INFO keyspace and INFO stats
+type KeyspaceStats struct {
+	// Keys is the number of keys in all the databases
+	Keys int64
+	// Expires is the number of keys with a TTL in all the databases
+	Expires int64
+	// ExpiredKeys counts the keys expired since the instance has started
+	ExpiredKeys int64
+	// EvictedKeys counts the keys evicted to stay within maxmemory since the instance has started
+	EvictedKeys int64
+}
+
+// parseKeyspaceStats decodes the INFO stats and INFO keyspace output with ParseInfo
+// and sums the keys up across the databases
+func parseKeyspaceStats(info string) KeyspaceStats {
+	parsed, _ := ParseInfo(info)
+	stats := KeyspaceStats{ExpiredKeys: parsed.Stats.ExpiredKeys, EvictedKeys: parsed.Stats.EvictedKeys}
+	for _, database := range parsed.Keyspace {
+		stats.Keys += database.Keys
+		stats.Expires += database.Expires
+	}
+	return stats
+}
+
+// GetKeyspaceStats returns the keyspace figures of the instances
+func (ins instances) GetKeyspaceStats(ctx context.Context) (map[Address]KeyspaceStats, error) {
+	stats := make(map[Address]KeyspaceStats, len(ins))
+	for i := range ins {
+		var info string
+		client := ins[i].client
+		if err := withContext(ctx, func() error {
+			statsInfo, err := client.Info("stats").Result()
+			if err != nil {
+				return err
+			}
+			keyspaceInfo, err := client.Info("keyspace").Result()
+			if err != nil {
+				return err
+			}
+			info = statsInfo + "\n" + keyspaceInfo
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("getting keyspace stats failed for %s: %s", ins[i].Address, err)
+		}
+		stats[ins[i].Address] = parseKeyspaceStats(info)
+	}
+	return stats, nil
+}
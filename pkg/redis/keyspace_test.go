@@ -0,0 +1,38 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import "testing"
+
+func TestRedis_parseKeyspaceStats(t *testing.T) {
+	info := `# Stats
+total_connections_received:12
+expired_keys:1500
+expired_stale_perc:0.00
+evicted_keys:42
+keyspace_hits:100
+
+# Keyspace
+db0:keys=1000,expires=250,avg_ttl=86400
+db3:keys=20,expires=20,avg_ttl=0`
+
+	want := KeyspaceStats{Keys: 1020, Expires: 270, ExpiredKeys: 1500, EvictedKeys: 42}
+	if got := parseKeyspaceStats(info); got != want {
+		t.Errorf("parseKeyspaceStats() = %+v, want %+v", got, want)
+	}
+	if got := parseKeyspaceStats("# Keyspace\r\n"); got != (KeyspaceStats{}) {
+		t.Errorf("parseKeyspaceStats() = %+v, want empty", got)
+	}
+}
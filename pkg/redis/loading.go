@@ -0,0 +1,86 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// NotReadyError is returned by New if the minimum replication size is met only along with the instances
+// loading the dataset or busy running a script. Such instances are alive and are expected to become ready,
+// unlike the unreachable ones.
+type NotReadyError struct {
+	// Ready is the number of the instances ready to be replicated
+	Ready int
+	// NotReady are the addresses of the instances loading the dataset or busy
+	NotReady []Address
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("minimum replication size is not met, only %d are healthy and %d are loading the dataset or busy",
+		e.Ready, len(e.NotReady))
+}
+
+// notReady reports whether the error is the -LOADING or -BUSY reply of an instance loading the dataset
+// or running a script exceeding busy-reply-threshold
+func notReady(err error) bool {
+	if err == nil {
+		return false
+	}
+	return strings.HasPrefix(err.Error(), "LOADING ") || strings.HasPrefix(err.Error(), "BUSY ")
+}
+
+// awaitReady pings the instances replying -LOADING or -BUSY with the backoff in parallel and returns the instances
+// that became ready along with the addresses of the instances still not ready. The clients of the latter are closed.
+func awaitReady(ctx context.Context, backoff Backoff, pending []instance) (instances, []Address) {
+	errs := make([]error, len(pending))
+	var wg sync.WaitGroup
+	wg.Add(len(pending))
+	for i := range pending {
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = backoff.retry(ctx, func() error { return pending[i].client.Ping().Err() })
+		}(i)
+	}
+	wg.Wait()
+
+	var ready instances
+	var addresses []Address
+	for i := range pending {
+		if errs[i] == nil {
+			ready = append(ready, pending[i])
+			continue
+		}
+		_ = pending[i].client.Close()
+		if notReady(errs[i]) {
+			addresses = append(addresses, pending[i].Address)
+		}
+	}
+	return ready, addresses
+}
+
+// replication is the Replication of the ready instances aware of the instances left out while not ready
+type replication struct {
+	instances
+	notReady []Address
+}
+
+// NotReady returns the instances left out of the replication while loading the dataset or busy
+func (r replication) NotReady() []Address {
+	return r.notReady
+}
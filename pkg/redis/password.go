@@ -0,0 +1,106 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// replicasFirst orders the instances the password is rotated on: the replicas, then the masters
+func replicasFirst(ins instances) {
+	sort.SliceStable(ins, func(i, j int) bool { return ins[i].role != RoleMaster && ins[j].role == RoleMaster })
+}
+
+// setPassword changes the password the instance requires and authenticates to its master with.
+// The default user and the user are defined by both passwords if the previous one is kept.
+// The established connections, the replication links included, stay authenticated.
+func (i *instance) setPassword(password, previous, username string) error {
+	if err := i.client.Do("CONFIG", "SET", "masterauth", password).Err(); err != nil {
+		return err
+	}
+	passwords := []interface{}{"resetpass", ">" + password}
+	if previous != "" {
+		passwords = append(passwords, ">"+previous)
+	}
+	// requirepass defines the single password of the default user, it is not enabled if disabled
+	if previous == "" {
+		if err := i.client.Do("CONFIG", "SET", "requirepass", password).Err(); err != nil {
+			return err
+		}
+	} else if err := i.client.Do(append([]interface{}{"ACL", "SETUSER", DefaultUser}, passwords...)...).Err(); err != nil {
+		return err
+	}
+	if username == "" {
+		return nil
+	}
+	// the other passwords and the rules of the user are applied by ApplyUsers
+	return i.client.Do(append([]interface{}{"ACL", "SETUSER", username}, passwords...)...).Err()
+}
+
+// connectReachable connects to the instances authenticated with the options, the unreachable ones are skipped
+func connectReachable(ctx context.Context, options Options, addresses ...Address) instances {
+	ins := make(instances, 0, len(addresses))
+	for _, address := range addresses {
+		i := instance{
+			Address:   address,
+			client:    options.newClient(address),
+			hostnames: options.Hostnames,
+		}
+		info, err := i.getInfo(ctx)
+		if err == nil {
+			err = i.refresh(info)
+		}
+		if err != nil {
+			_ = i.client.Close()
+			continue
+		}
+		ins = append(ins, i)
+	}
+	return ins
+}
+
+// RotatePassword changes the password of the instances still authenticated with options.Password to password
+// without restarting them: the replicas first, then the master. The replicas keep the established replication
+// links and authenticate with the new password once they reconnect. The instances already rotated and
+// the unreachable ones are skipped, so the rotation is retried with the same options until it succeeds.
+// The user the operator authenticates as is rotated as well. The previous password, if not empty,
+// is accepted along with the new one, so the clients are switched to the new password at their own pace.
+func RotatePassword(ctx context.Context, options Options, password, previous string, addresses ...Address) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	ins := connectReachable(ctx, options, addresses...)
+	defer func() { ins.Disconnect() }()
+	replicasFirst(ins)
+
+	var errs []string
+	for i := range ins {
+		target := &ins[i]
+		if err := withContext(ctx, func() error {
+			return target.setPassword(password, previous, options.Username)
+		}); err != nil {
+			errs = append(errs, fmt.Sprintf("error rotating password of %s: %s", ins[i].Address, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ";"))
+	}
+	return nil
+}
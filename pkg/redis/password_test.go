@@ -0,0 +1,39 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_replicasFirst(t *testing.T) {
+	ins := instances{
+		{Address: Address{"172.18.0.2", "6379"}, role: RoleMaster},
+		{Address: Address{"172.18.0.3", "6379"}, role: RoleReplica},
+		{Address: Address{"172.18.0.4", "6379"}, role: RoleMaster},
+		{Address: Address{"172.18.0.5", "6379"}, role: RoleReplica},
+	}
+	replicasFirst(ins)
+
+	var got []string
+	for _, i := range ins {
+		got = append(got, i.Host)
+	}
+	want := []string{"172.18.0.3", "172.18.0.5", "172.18.0.2", "172.18.0.4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replicasFirst() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// CanaryChannel is the channel reserved for the Pub/Sub canary messages published by the operator
+	CanaryChannel = "__redis-operator:canary"
+
+	// canaryTimeout is how long the subscriptions are confirmed and the canary message is awaited for
+	canaryTimeout = 2 * time.Second
+)
+
+// canaryReceived reports whether the message received by the subscription is the canary carrying the payload
+func canaryReceived(message interface{}, payload string) bool {
+	m, ok := message.(*redis.Message)
+	return ok && m.Channel == CanaryChannel && m.Payload == payload
+}
+
+// subscribe subscribes to CanaryChannel and waits for the confirmation,
+// e.g. the subscription is refused with NOPERM if the user is not allowed the channel
+func (i *instance) subscribe() (*redis.PubSub, error) {
+	pubsub := i.client.Subscribe(CanaryChannel)
+	reply, err := pubsub.ReceiveTimeout(canaryTimeout)
+	if err == nil {
+		if _, ok := reply.(*redis.Subscription); !ok {
+			err = fmt.Errorf("unexpected reply %v", reply)
+		}
+	}
+	if err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+	return pubsub, nil
+}
+
+// awaitCanary reports whether the canary message carrying the payload is received before the deadline
+func awaitCanary(pubsub *redis.PubSub, payload string, deadline time.Time) bool {
+	for {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return false
+		}
+		message, err := pubsub.ReceiveTimeout(timeout)
+		if err != nil {
+			return false
+		}
+		if canaryReceived(message, payload) {
+			return true
+		}
+	}
+}
+
+// CheckPubSub publishes a canary message to CanaryChannel on the master and returns the replicas whose
+// subscribers have not received it within canaryTimeout. PUBLISH is propagated with the replication stream,
+// so the check detects the replicas not delivering the messages to their subscribers while INFO reports
+// the link up. An error is returned if the check could not be run, e.g. the subscription is refused.
+func (ins instances) CheckPubSub(ctx context.Context) ([]Address, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	master := ins.selectMaster()
+	if master == nil {
+		return nil, errors.New("no master to publish the canary message on")
+	}
+
+	var replicas []Address
+	var subscriptions []*redis.PubSub
+	defer func() {
+		for _, pubsub := range subscriptions {
+			_ = pubsub.Close()
+		}
+	}()
+	var errs []string
+	for i := range ins {
+		if ins[i].role != RoleReplica {
+			continue
+		}
+		pubsub, err := ins[i].subscribe()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("error subscribing on %s: %s", ins[i].Address, err))
+			continue
+		}
+		replicas = append(replicas, ins[i].Address)
+		subscriptions = append(subscriptions, pubsub)
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	if len(subscriptions) == 0 {
+		return nil, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	payload := master.Address.String() + "/" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := master.client.Publish(CanaryChannel, payload).Err(); err != nil {
+		return nil, fmt.Errorf("error publishing on %s: %s", master.Address, err)
+	}
+
+	deadline := time.Now().Add(canaryTimeout)
+	received := make([]bool, len(subscriptions))
+	var wg sync.WaitGroup
+	wg.Add(len(subscriptions))
+	for i := range subscriptions {
+		go func(i int) {
+			defer wg.Done()
+			received[i] = awaitCanary(subscriptions[i], payload, deadline)
+		}(i)
+	}
+	wg.Wait()
+
+	var missed []Address
+	for i := range replicas {
+		if !received[i] {
+			missed = append(missed, replicas[i])
+		}
+	}
+	return missed, nil
+}
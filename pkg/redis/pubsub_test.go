@@ -0,0 +1,43 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis"
+)
+
+func TestRedis_canaryReceived(t *testing.T) {
+	const payload = "172.18.0.2:6379/1"
+	tests := []struct {
+		name    string
+		message interface{}
+		want    bool
+	}{
+		{"canary", &redis.Message{Channel: CanaryChannel, Payload: payload}, true},
+		{"previous canary", &redis.Message{Channel: CanaryChannel, Payload: "172.18.0.2:6379/0"}, false},
+		{"another channel", &redis.Message{Channel: "events", Payload: payload}, false},
+		{"subscription", &redis.Subscription{Kind: "subscribe", Channel: CanaryChannel, Count: 1}, false},
+		{"pong", &redis.Pong{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canaryReceived(tt.message, payload); got != tt.want {
+				t.Errorf("canaryReceived() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -15,22 +15,23 @@
 package redis
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
-	"regexp"
+	"net"
 	"sort"
 	"strings"
 	"sync"
-	"time"
 
-	"github.com/cenkalti/backoff/v3"
 	"github.com/go-redis/redis"
-	"github.com/spf13/cast"
+
+	"github.com/amaizfinance/redis-operator/pkg/redis/failover"
 )
 
 const (
-	// Port is a standard Redis port. Is not meant to change
-	Port = 6379
+	// DefaultPort is the standard Redis port, the instances listen on it unless configured otherwise
+	DefaultPort = 6379
 
 	// MinimumFailoverSize sets the minimum desired size of Redis replication.
 	// It reflects a simple master - replica pair.
@@ -38,7 +39,7 @@ const (
 	// it is better to keep at least 3 instances and feel free to lose one instance for whatever reason.
 	// It is especially useful for scenarios when there is no need or permission to use persistent storage.
 	// In such cases it is safe to run Redis replication failover and the risk of losing data is minimal.
-	MinimumFailoverSize = 2
+	MinimumFailoverSize = failover.MinimumSize
 
 	// Roles as seen in the info replication output
 	RoleMaster  = "role:master"
@@ -54,81 +55,126 @@ const (
 	masterHost        = "master_host"
 	masterPort        = "master_port"
 	masterLinkStatus  = "master_link_status"
-
-	// DefaultFailoverTimeout sets the maximum timeout for the exponential backoff timer
-	DefaultFailoverTimeout = 5 * time.Second
-)
-
-var (
-	infoReplicationRe = buildInfoReplicationRe()
+	// masterLastIOSecondsAgo is -1 while the replica is disconnected from the master
+	masterLastIOSecondsAgo = "master_last_io_seconds_ago"
+
+	// persistence fields
+	rdbLastBgsaveStatus = "rdb_last_bgsave_status"
+	aofLastWriteStatus  = "aof_last_write_status"
+	aofEnabled          = "aof_enabled"
+	aofDelayedFsync     = "aof_delayed_fsync"
+
+	// StatusOK is the status of a successful persistence operation as seen in the info persistence output
+	StatusOK = "ok"
+	// StatusUp is the master_link_status of a replica connected to the master
+	StatusUp = "up"
+
+	// DefaultClientName is the name set by CLIENT SETNAME on the operator connections.
+	// It allows to identify the operator in CLIENT LIST output and to exclude it in CLIENT KILL filters.
+	DefaultClientName = "redis-operator"
+
+	// exporterClientName is the name redis_exporter sets on its connections
+	exporterClientName = "redis_exporter"
+
+	// ProtocolRESP2 and ProtocolRESP3 are the RESP protocol versions negotiated with HELLO
+	ProtocolRESP2 = 2
+	ProtocolRESP3 = 3
 )
 
-// buildInfoReplicationRe is a helper function to build a regexp for parsing INFO REPLICATION output
-func buildInfoReplicationRe() *regexp.Regexp {
-	var b strings.Builder
-	defer b.Reset()
-	// start from setting the multi-line flag
-	b.WriteString(`(?m)`)
-
-	// IPv4 address regexp
-	addrRe := `((25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)`
-
-	// templates for simple fields
-	numTmpl := `^%s:\d+\s*?$`
-	strTmpl := `^%s:\w+\s*?$`
-
-	// build them all up
-	for name, tmpl := range map[string]string{
-		// master-specific fields
-		connectedReplicas: numTmpl,
-		masterReplOffset:  numTmpl,
-
-		// replica-specific fields
-		replicaPriority:   numTmpl,
-		replicationOffset: numTmpl,
-		masterHost:        fmt.Sprintf(`^%%s:%s\s*?$`, addrRe),
-		masterPort:        numTmpl,
-		masterLinkStatus:  strTmpl,
-	} {
-		_, _ = fmt.Fprintf(&b, tmpl, name)
-		_, _ = fmt.Fprint(&b, "|")
-	}
-	// replica regexp is the most complex of all
-	_, _ = fmt.Fprintf(&b, `^slave\d+:ip=%s,port=\d{1,5},state=\w+,offset=\d+,lag=\d+\s*?$`, addrRe)
-	return regexp.MustCompile(b.String())
-}
-
-// client is an extract of redis.Cmdable
-type client interface {
+// Client is the extract of redis.Cmdable the instances are managed with.
+// It is implemented by *redis.Client, Options.NewClient allows to substitute it, e.g. with a fake in the tests.
+type Client interface {
 	Ping() *redis.StatusCmd
 	Info(section ...string) *redis.StringCmd
 	TxPipelined(fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+	Do(args ...interface{}) *redis.Cmd
+	Publish(channel string, message interface{}) *redis.IntCmd
+	Subscribe(channels ...string) *redis.PubSub
 	Close() error
 }
 
 // rediser defines the instance methods
 type rediser interface {
-	replicaOf(master Address) error
-	getInfo() (string, error)
+	replicaOf(ctx context.Context, master Address) error
+	getInfo(ctx context.Context) (string, error)
 	refresh(info string) error
+	promote(ctx context.Context) error
+}
+
+// withContext runs fn until it returns or the context is done. The commands of go-redis v6 do not take a context,
+// the command left running is failed by the read timeout or once the client is closed.
+func withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Replication is the interface for checking the status of replication
 type Replication interface {
 	// Reconfigure checks the state of replication and reconfigures instances if needed
-	Reconfigure() error
+	Reconfigure(ctx context.Context) (Reconfiguration, error)
 	// Size returns the total number of replicas
 	Size() int
 	// GetMasterAddress returns the current master address
 	GetMasterAddress() Address
 	// Refresh refreshes replication info for every instance
-	Refresh() error
+	Refresh(ctx context.Context) error
 	// Disconnect closes connections to all instances
 	Disconnect()
+	// ApplyUsers creates or updates ACL users on all instances
+	ApplyUsers(users ...User) error
+	// DeleteUsers deletes ACL users from all instances
+	DeleteUsers(names ...string) error
+	// Announce sets the addresses the instances announce to the master as configured by Options.Announced
+	Announce() error
+	// SetDefaultUser enables or disables the default user on all instances
+	SetDefaultUser(enabled bool) error
+	// GetPersistenceFailures returns the failed persistence statuses of instances, e.g. "rdb_last_bgsave_status:err"
+	GetPersistenceFailures() map[Address][]string
+	// GetAOFDelayedFsyncs returns the aof_delayed_fsync counters of the instances with AOF enabled
+	GetAOFDelayedFsyncs() map[Address]int
+	// GetReplicationLags returns how far the replicas are behind the master as of the latest refresh
+	GetReplicationLags() map[Address]ReplicationLag
+	// GetKeyspaceStats returns the number of keys and the counters of the expired and evicted keys of the instances
+	GetKeyspaceStats(ctx context.Context) (map[Address]KeyspaceStats, error)
+	// Handover hands the master role over to the best of the kept replicas before the master goes away
+	Handover(ctx context.Context, kept func(Address) bool, policy SyncPolicy) (Address, error)
+	// Unsynced returns the instances not replicating from the master or lagging behind it by more than maxLag bytes
+	Unsynced(maxLag int64) []Address
+	// CheckPubSub returns the replicas not delivering the canary message published on the master to the subscribers
+	CheckPubSub(ctx context.Context) ([]Address, error)
+	// NotReady returns the instances left out of the replication while loading the dataset or busy
+	NotReady() []Address
+	// GetSyncing returns the replicas in the full synchronization with the master as of the latest refresh
+	GetSyncing() []Address
 
 	selectMaster() *instance
-	promoteReplicaToMaster() (*instance, error)
-	reconfigureAsReplicasOf(master Address) error
+	reconfigureAsReplicasOf(ctx context.Context, master Address) error
+}
+
+// Reconfiguration describes the changes made by Reconfigure
+type Reconfiguration struct {
+	// Promoted is the address of the replica promoted to master, empty if the master has not been lost
+	Promoted Address
+	// Replicas are the addresses of the instances reconfigured as replicas of the master
+	Replicas []Address
+}
+
+// PromotionError is returned by Reconfigure if no replica could be promoted after the master has been lost
+type PromotionError struct {
+	err error
+}
+
+func (e *PromotionError) Error() string {
+	return fmt.Sprintf("failed to promote a replica to master: %s", e.err)
 }
 
 // Address represents the Host:Port pair of a instance instance
@@ -137,8 +183,23 @@ type Address struct {
 	Port string
 }
 
+// String returns the host and the port joined the way they are dialed, the IPv6 hosts are enclosed in brackets
 func (a Address) String() string {
-	return fmt.Sprintf("%s:%s", a.Host, a.Port)
+	return net.JoinHostPort(a.Host, a.Port)
+}
+
+// parseHost returns the host reported by INFO the way the instances are addressed: without the brackets,
+// with the IPv6 addresses in the canonical form and the IPv4-mapped ones as IPv4, e.g. reported by the master
+// listening on the dual-stack socket
+func parseHost(host string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if !strings.Contains(host, ":") {
+		return host
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
 }
 
 // strict implementation check
@@ -162,12 +223,50 @@ type instance struct {
 	masterHost       string
 	masterPort       string
 	masterLinkStatus string
+	// masterLastIOSecondsAgo is the number of seconds since the last interaction with the master
+	masterLastIOSecondsAgo int
+	// masterSyncInProgress is set while the replica is in the full synchronization with the master
+	masterSyncInProgress bool
+
+	// persistence fields
+	rdbLastBgsaveStatus string
+	aofLastWriteStatus  string
+	aofEnabled          bool
+	// aofDelayedFsync counts the writes delayed by the pending fsync with appendfsync everysec
+	aofDelayedFsync int
+
+	client Client
+	// clientName is the name of the operator connections, they are never killed on reconfiguration
+	clientName string
+	// knownMaster is set for the master elected previously, it is kept as the master until its replicas reconnect
+	knownMaster bool
+	// announcedAddress is the address the instance announces to the master, empty if it announces its own
+	announcedAddress Address
+	// announced maps the addresses announced by the replicas to the instance addresses
+	announced map[Address]Address
+	// hostnames maps the instance addresses to the addresses the replicas replicate from them at
+	hostnames map[Address]Address
+	// caches are the replicas outside of the replication left out of the replicas of the master
+	caches map[Address]bool
+	// unpromotable are the replicas never promoted to master
+	unpromotable map[Address]bool
+}
 
-	client client
+// replicationTarget returns the address the instance replicates from the master at, see Options.Hostnames
+func (i *instance) replicationTarget(master Address) Address {
+	if hostname, ok := i.hostnames[master]; ok {
+		return hostname
+	}
+	return master
 }
 
 // replicaOf changes the replication settings of a replica on the fly
-func (i *instance) replicaOf(master Address) (err error) {
+func (i *instance) replicaOf(ctx context.Context, master Address) error {
+	return withContext(ctx, func() error { return i.setReplicaOf(master) })
+}
+
+// setReplicaOf sends REPLICAOF to the instance, see replicaOf
+func (i *instance) setReplicaOf(master Address) (err error) {
 	// promote replica to master
 	if master == (Address{}) {
 		master.Host = "NO"
@@ -177,76 +276,130 @@ func (i *instance) replicaOf(master Address) (err error) {
 	/* In order to send REPLICAOF in a safe way, we send a transaction performing
 	 * the following tasks:
 	 * 1) Reconfigure the instance according to the specified host/port params.
-	 * 2) Disconnect all clients (but this one sending the command) in order
-	 *    to trigger the ask-master-on-reconnection protocol for connected
-	 *    clients.
+	 * 2) Disconnect all clients (but this one sending the command, the other
+	 *    operator connections and the exporter) in order to trigger the
+	 *    ask-master-on-reconnection protocol for connected clients.
 	 *
 	 * Note that we don't check the replies returned by commands, since we
 	 * will observe instead the effects in the next INFO output. */
+	clientList, err := i.client.Do("CLIENT", "LIST", "TYPE", "NORMAL").String()
+	if err != nil {
+		return fmt.Errorf("listing clients failed for %s: %s", i.Address, err)
+	}
+	// the clients are killed one by one to spare the operator and exporter connections:
+	// CLIENT KILL filters can not exclude clients by name or address
+	ids := killableClients(clientList, i.clientName, exporterClientName)
+
 	_, err = i.client.TxPipelined(func(pipe redis.Pipeliner) error {
 		pipe.SlaveOf(master.Host, master.Port)
-		pipe.ClientKillByFilter("TYPE", "NORMAL")
+		for _, id := range ids {
+			pipe.ClientKillByFilter("ID", id)
+		}
 		return nil
 	})
 
 	return err
 }
 
-func (i *instance) getInfo() (info string, err error) {
-	info, err = i.client.Info("replication").Result()
-	if err != nil {
-		return "", fmt.Errorf("getting info replication failed for %s: %s", i.Address, err)
+// killableClients parses the CLIENT LIST output and returns the IDs of the clients to disconnect on reconfiguration.
+// The clients named as one of exemptNames and the clients connected from the loopback interface, e.g. the exporter,
+// are exempted.
+func killableClients(clientList string, exemptNames ...string) []string {
+	exempt := make(map[string]struct{}, len(exemptNames))
+	for _, name := range exemptNames {
+		if name != "" {
+			exempt[name] = struct{}{}
+		}
+	}
+
+	var ids []string
+	for _, line := range strings.Split(clientList, "\n") {
+		fields := make(map[string]string)
+		for _, field := range strings.Fields(line) {
+			if kv := strings.SplitN(field, "=", 2); len(kv) == 2 {
+				fields[kv[0]] = kv[1]
+			}
+		}
+		if fields["id"] == "" {
+			continue
+		}
+		if _, ok := exempt[fields["name"]]; ok {
+			continue
+		}
+		if host, _, err := net.SplitHostPort(fields["addr"]); err == nil && net.ParseIP(host).IsLoopback() {
+			continue
+		}
+		ids = append(ids, fields["id"])
+	}
+	return ids
+}
+
+// getInfo returns the replication and persistence sections of INFO
+func (i *instance) getInfo(ctx context.Context) (string, error) {
+	var info string
+	if err := withContext(ctx, func() error {
+		replication, err := i.client.Info("replication").Result()
+		if err != nil {
+			return fmt.Errorf("getting info replication failed for %s: %s", i.Address, err)
+		}
+		persistence, err := i.client.Info("persistence").Result()
+		if err != nil {
+			return fmt.Errorf("getting info persistence failed for %s: %s", i.Address, err)
+		}
+		info = replication + "\n" + persistence
+		return nil
+	}); err != nil {
+		return "", err
 	}
-	return
+	return info, nil
 }
 
-// refresh parses the instance info and updates the instance fields appropriately
+// refresh decodes the instance info with ParseInfo and updates the instance fields appropriately
 func (i *instance) refresh(info string) error {
-	// parse info replication answer
-	switch {
-	case strings.Contains(info, RoleMaster):
+	parsed, err := ParseInfo(info)
+	if err != nil {
+		return err
+	}
+	replication := parsed.Replication
+	switch replication.Role {
+	case infoRoleMaster:
 		i.role = RoleMaster
-	case strings.Contains(info, RoleReplica):
+	case infoRoleReplica:
 		i.role = RoleReplica
 	default:
 		return errors.New("the role is wrong")
 	}
 
-	// parse all other attributes
-	for _, parsed := range infoReplicationRe.FindAllString(info, -1) {
-		switch s := strings.TrimSpace(parsed); {
-		// master-specific
-		case i.role == RoleMaster && strings.HasPrefix(s, connectedReplicas):
-			i.connectedReplicas = cast.ToInt(strings.Split(s, ":")[1])
-		case i.role == RoleMaster && strings.HasPrefix(s, masterReplOffset):
-			i.replicationOffset = cast.ToInt(strings.Split(s, ":")[1])
-		case i.role == RoleMaster && strings.HasPrefix(s, "slave"):
-			replica := instance{}
-			for _, field := range strings.Split(strings.Split(s, ":")[1], ",") {
-				switch {
-				case strings.HasPrefix(field, "ip="):
-					replica.Host = strings.Split(field, "=")[1]
-				case strings.HasPrefix(field, "port="):
-					replica.Port = strings.Split(field, "=")[1]
-				case strings.HasPrefix(field, "offset="):
-					replica.replicationOffset = cast.ToInt(strings.Split(field, "=")[1])
-				}
+	switch i.role {
+	case RoleMaster:
+		i.connectedReplicas = replication.ConnectedReplicas
+		i.replicationOffset = int(replication.MasterReplOffset)
+		i.replicas = nil
+		for _, replicaInfo := range replication.Replicas {
+			replica := instance{Address: replicaInfo.Address, replicationOffset: int(replicaInfo.Offset)}
+			if address, ok := i.announced[replica.Address]; ok {
+				replica.Address = address
+			}
+			if i.caches[replica.Address] {
+				i.connectedReplicas--
+				continue
 			}
 			i.replicas = append(i.replicas, replica)
-
-		// replica-specific
-		case i.role == RoleReplica && strings.HasPrefix(s, replicaPriority):
-			i.replicaPriority = cast.ToInt(strings.Split(s, ":")[1])
-		case i.role == RoleReplica && strings.HasPrefix(s, replicationOffset):
-			i.replicationOffset = cast.ToInt(strings.Split(s, ":")[1])
-		case i.role == RoleReplica && strings.HasPrefix(s, masterHost):
-			i.masterHost = strings.Split(s, ":")[1]
-		case i.role == RoleReplica && strings.HasPrefix(s, masterLinkStatus):
-			i.masterLinkStatus = strings.Split(s, ":")[1]
-		case i.role == RoleReplica && strings.HasPrefix(s, masterPort):
-			i.masterPort = strings.Split(s, ":")[1]
 		}
+	case RoleReplica:
+		i.replicaPriority = replication.ReplicaPriority
+		i.replicationOffset = int(replication.ReplicaReplOffset)
+		i.masterHost = replication.MasterHost
+		i.masterPort = replication.MasterPort
+		i.masterLinkStatus = replication.MasterLinkStatus
+		i.masterLastIOSecondsAgo = replication.MasterLastIOSecondsAgo
+		i.masterSyncInProgress = replication.MasterSyncInProgress
 	}
+
+	i.rdbLastBgsaveStatus = parsed.Persistence.RDBLastBgsaveStatus
+	i.aofLastWriteStatus = parsed.Persistence.AOFLastWriteStatus
+	i.aofEnabled = parsed.Persistence.AOFEnabled
+	i.aofDelayedFsync = parsed.Persistence.AOFDelayedFsync
 	return nil
 }
 
@@ -262,56 +415,92 @@ func (ins instances) Swap(i, j int) { ins[i], ins[j] = ins[j], ins[i] }
 // Less chooses an instance with a lesser priority and higher replication offset.
 // Note that this assumes that instances don't have replicas with replicaPriority == 0
 func (ins instances) Less(i, j int) bool {
-	// choose a replica with less replica priority
-	// choose a bigger replication offset otherwise
-	if ins[i].replicaPriority == ins[j].replicaPriority {
-		return ins[i].replicationOffset > ins[j].replicationOffset
+	return failover.Better(ins[i].node(), ins[j].node())
+}
+
+// node returns the replication state of the instance the failover decisions are made on
+func (i *instance) node() failover.Node {
+	n := failover.Node{
+		ID:                i.Address.String(),
+		Role:              failover.Master,
+		Offset:            i.replicationOffset,
+		ConnectedReplicas: i.connectedReplicas,
+		Known:             i.knownMaster,
+		Priority:          i.replicaPriority,
+		Excluded:          i.unpromotable[i.Address],
+	}
+	if i.role == RoleReplica {
+		n.Role = failover.Replica
 	}
-	return ins[i].replicaPriority < ins[j].replicaPriority
+	for _, replica := range i.replicas {
+		n.Replicas = append(n.Replicas, replica.Address.String())
+	}
+	return n
+}
+
+// topology returns the replication state of the instances in order
+func (ins instances) topology() failover.Topology {
+	t := make(failover.Topology, len(ins))
+	for i := range ins {
+		t[i] = ins[i].node()
+	}
+	return t
 }
 
 // Reconfigure checks the state of the instance replication and tries to fix/initially set the state.
 // There should be only one master. All other instances should report the same master.
 // Working master serves as a source of truth. It means that only those replicas who are not reported by master
-// as its replicas will be reconfigured.
-func (ins instances) Reconfigure() (err error) {
+// as its replicas will be reconfigured. The decisions are made by the failover package.
+// The changes made are returned even if the replicas have failed to be reconfigured.
+func (ins instances) Reconfigure(ctx context.Context) (reconfiguration Reconfiguration, err error) {
+	decision, err := failover.Decide(ins.topology())
+	if err != nil {
+		return reconfiguration, &PromotionError{err: err}
+	}
 	// nothing to do here
 	if len(ins) == 0 {
-		return nil
+		return reconfiguration, nil
 	}
 
-	master := ins.selectMaster()
-
+	master := ins.index(decision.Master)
+	orphans := decision.Reconfigure
 	// we've lost the master, promote a replica to master role
-	if master == nil {
-		var candidates instances
-		// filter out non-replicas
-		for i := range ins {
-			if ins[i].role == RoleReplica && ins[i].replicaPriority != 0 {
-				candidates = append(candidates, ins[i])
-			}
+	if decision.Promote {
+		if err := ins[master].promote(ctx); err != nil {
+			return reconfiguration, &PromotionError{err: err}
 		}
-		master, err = candidates.promoteReplicaToMaster()
-		if err != nil {
-			return err
+		reconfiguration.Promoted = ins[master].Address
+
+		// the replicas are reconfigured based on the state of the promoted master
+		orphans = nil
+		for _, i := range failover.Orphans(ins.topology(), master) {
+			orphans = append(orphans, ins[i].Address.String())
 		}
 	}
 
-	// connectedReplicas will be needed to compile a slice of orphaned(not connected to current master) ins
-	connectedReplicas := make(map[Address]struct{})
-	for _, replica := range master.replicas {
-		connectedReplicas[replica.Address] = struct{}{}
+	var replicas instances
+	for _, id := range orphans {
+		replicas = append(replicas, ins[ins.index(id)])
 	}
 
-	var replicas instances
+	// configure replicas
+	if err := replicas.reconfigureAsReplicasOf(ctx, ins[master].Address); err != nil {
+		return reconfiguration, err
+	}
+	for i := range replicas {
+		reconfiguration.Replicas = append(reconfiguration.Replicas, replicas[i].Address)
+	}
+	return reconfiguration, nil
+}
+
+// index returns the index of the instance identified by the failover node ID
+func (ins instances) index(id string) int {
 	for i := range ins {
-		if _, there := connectedReplicas[ins[i].Address]; ins[i].Address != master.Address && !there {
-			replicas = append(replicas, ins[i])
+		if ins[i].Address.String() == id {
+			return i
 		}
 	}
-
-	// configure replicas
-	return replicas.reconfigureAsReplicasOf(master.Address)
+	return -1
 }
 
 // Size returns the number of redis instances
@@ -325,7 +514,7 @@ func (ins instances) GetMasterAddress() Address {
 }
 
 // Refresh fetches and refreshes info for all instances
-func (ins instances) Refresh() error {
+func (ins instances) Refresh(ctx context.Context) error {
 	var wg sync.WaitGroup
 	instanceCount := len(ins)
 	ch := make(chan string, instanceCount)
@@ -334,7 +523,7 @@ func (ins instances) Refresh() error {
 	for i := range ins {
 		go func(i *instance, wg *sync.WaitGroup) {
 			defer wg.Done()
-			info, err := i.getInfo()
+			info, err := i.getInfo(ctx)
 			if err != nil {
 				ch <- fmt.Sprintf("%s: %s", i.Address, err)
 				return
@@ -359,76 +548,145 @@ func (ins instances) Refresh() error {
 	return nil
 }
 
-// Disconnect closes the connections and releases the resources
-func (ins instances) Disconnect() {
+// GetPersistenceFailures returns the failed persistence statuses per instance.
+// Instances with healthy persistence are omitted.
+func (ins instances) GetPersistenceFailures() map[Address][]string {
+	failures := make(map[Address][]string)
 	for i := range ins {
-		_ = ins[i].client.Close()
+		for field, status := range map[string]string{
+			rdbLastBgsaveStatus: ins[i].rdbLastBgsaveStatus,
+			aofLastWriteStatus:  ins[i].aofLastWriteStatus,
+		} {
+			if status != "" && status != StatusOK {
+				failures[ins[i].Address] = append(failures[ins[i].Address], fmt.Sprintf("%s:%s", field, status))
+			}
+		}
+		sort.Strings(failures[ins[i].Address])
 	}
+	return failures
 }
 
-// selectMaster chooses any working master in case of a working replication or any other master otherwise.
-// Working master in this case is a master with at least one replica connected.
-func (ins instances) selectMaster() *instance {
-	// normal state. we have a working replication with the master being online
-	for _, i := range ins {
-		// filter out replicas since they can also have their own replicas...
-		if i.role == RoleReplica {
+// GetAOFDelayedFsyncs returns the aof_delayed_fsync counters of the instances with AOF enabled.
+// The counter is reset once the instance is restarted.
+func (ins instances) GetAOFDelayedFsyncs() map[Address]int {
+	counters := make(map[Address]int)
+	for i := range ins {
+		if ins[i].aofEnabled {
+			counters[ins[i].Address] = ins[i].aofDelayedFsync
+		}
+	}
+	return counters
+}
+
+// ReplicationLag is how far a replica is behind the master as of the latest refresh
+type ReplicationLag struct {
+	// Offset is the number of bytes of the replication stream the replica is behind the master by
+	Offset int64
+	// MasterLastIOSecondsAgo is the number of seconds since the last interaction with the master,
+	// -1 while the replica is disconnected from it
+	MasterLastIOSecondsAgo int
+}
+
+// GetReplicationLags returns the replication lags of the replicas behind the master. The lags are taken from
+// the replication info of the latest refresh, no commands are sent. There are no lags without a master.
+func (ins instances) GetReplicationLags() map[Address]ReplicationLag {
+	lags := make(map[Address]ReplicationLag)
+	master := ins.selectMaster()
+	if master == nil {
+		return lags
+	}
+	for i := range ins {
+		if ins[i].Address == master.Address || ins[i].role != RoleReplica {
 			continue
 		}
+		// the offsets are read one instance after another, the replica may be ahead of the master by then
+		offset := int64(master.replicationOffset - ins[i].replicationOffset)
+		if offset < 0 {
+			offset = 0
+		}
+		lags[ins[i].Address] = ReplicationLag{Offset: offset, MasterLastIOSecondsAgo: ins[i].masterLastIOSecondsAgo}
+	}
+	return lags
+}
 
-		// we've found a working master
-		if i.connectedReplicas > 0 {
-			return &i
+// Unsynced returns the instances other than the master that are not its connected replicas with the link up
+// or lag behind its replication offset by more than maxLag bytes. All the instances are unsynced without a master.
+func (ins instances) Unsynced(maxLag int64) []Address {
+	master := ins.selectMaster()
+	connected := make(map[Address]bool)
+	if master != nil {
+		for _, replica := range master.replicas {
+			connected[replica.Address] = true
 		}
 	}
 
-	// If we have at least one replica it means
-	// we've lost the current master and need to promote a replica to a master
-	for _, i := range ins {
-		if i.role == RoleReplica && i.replicaPriority != 0 {
-			return nil
+	var unsynced []Address
+	for i := range ins {
+		if master != nil && ins[i].Address == master.Address {
+			continue
+		}
+		if !connected[ins[i].Address] || ins[i].role != RoleReplica || ins[i].masterLinkStatus != StatusUp ||
+			int64(master.replicationOffset-ins[i].replicationOffset) > maxLag {
+			unsynced = append(unsynced, ins[i].Address)
 		}
 	}
+	return unsynced
+}
 
-	// This is supposed to be an initial state.
-	// When you roll out a bunch of Redis instances initially they are all standalone masters.
-	// In this case we are free to choose the first one.
-	if len(ins) > 0 {
-		return &ins[0]
+// GetSyncing returns the replicas reporting master_sync_in_progress as of the latest refresh. No commands are sent.
+func (ins instances) GetSyncing() []Address {
+	var syncing []Address
+	for i := range ins {
+		if ins[i].role == RoleReplica && ins[i].masterSyncInProgress {
+			syncing = append(syncing, ins[i].Address)
+		}
 	}
+	return syncing
+}
 
-	return nil
+// Disconnect closes the connections and releases the resources
+func (ins instances) Disconnect() {
+	for i := range ins {
+		_ = ins[i].client.Close()
+	}
 }
 
-// promoteReplicaToMaster selects a replica for promotion and promotes it to master role
-func (ins instances) promoteReplicaToMaster() (*instance, error) {
-	sort.Sort(ins)
-	promoted := &ins[0]
-	exponentialBackOff := backoff.NewExponentialBackOff()
-	exponentialBackOff.MaxElapsedTime = DefaultFailoverTimeout
+// selectMaster chooses any working master in case of a working replication or any other master otherwise.
+// Working master in this case is a master with at least one replica connected.
+// nil is returned if the master has been lost and a replica needs to be promoted.
+func (ins instances) selectMaster() *instance {
+	i := failover.SelectMaster(ins.topology())
+	if i < 0 {
+		return nil
+	}
+	master := ins[i]
+	return &master
+}
 
-	if err := promoted.replicaOf(Address{}); err != nil {
-		return nil, fmt.Errorf("could not promote replica %s to master: %s", promoted.Address, err)
+// promote promotes the replica to master role.
+// REPLICAOF NO ONE takes effect immediately, the promoted replica is checked once without waiting.
+// An error is returned if it does not report the master role, the caller is expected to retry later.
+func (i *instance) promote(ctx context.Context) error {
+	if err := i.replicaOf(ctx, Address{}); err != nil {
+		return fmt.Errorf("could not promote replica %s to master: %s", i.Address, err)
 	}
 
-	// promote replica to master and wait until it reports itself as a master
-	return promoted, backoff.Retry(func() error {
-		info, err := promoted.getInfo()
-		if err != nil {
-			return err
-		}
-		if err := promoted.refresh(info); err != nil {
-			return err
-		}
-		if promoted.role != RoleMaster {
-			return fmt.Errorf("still waiting for the replica %s to be promoted", promoted.Address)
-		}
-		return nil
-	}, exponentialBackOff)
+	info, err := i.getInfo(ctx)
+	if err != nil {
+		return err
+	}
+	if err := i.refresh(info); err != nil {
+		return err
+	}
+	if i.role != RoleMaster {
+		return fmt.Errorf("still waiting for the replica %s to be promoted", i.Address)
+	}
+	i.knownMaster = true
+	return nil
 }
 
 // reconfigureAsReplicasOf configures instances as replicas of the master
-func (ins instances) reconfigureAsReplicasOf(master Address) error {
+func (ins instances) reconfigureAsReplicasOf(ctx context.Context, master Address) error {
 	// do it simultaneously for all replicas
 	var wg sync.WaitGroup
 	replicasCount := len(ins)
@@ -439,7 +697,7 @@ func (ins instances) reconfigureAsReplicasOf(master Address) error {
 		go func(replica *instance, wg *sync.WaitGroup) {
 			defer wg.Done()
 
-			if err := replica.replicaOf(master); err != nil {
+			if err := replica.replicaOf(ctx, replica.replicationTarget(master)); err != nil {
 				ch <- fmt.Sprintf("error reconfiguring replica %s: %v", replica.Address, err)
 			}
 		}(&ins[i], &wg)
@@ -458,35 +716,212 @@ func (ins instances) reconfigureAsReplicasOf(master Address) error {
 	return nil
 }
 
+// Options configure the connections to Redis instances
+type Options struct {
+	// Password used to AUTH the connections
+	Password string
+	// Username is the ACL user the connections are authenticated as along with Password.
+	// The connections fall back to the default user if the user is rejected, e.g. not created yet.
+	Username string
+	// TLSConfig enables TLS if not nil
+	TLSConfig *tls.Config
+	// ClientName is set with CLIENT SETNAME on every connection. Not set if empty
+	ClientName string
+	// Protocol is the RESP protocol version negotiated with HELLO.
+	// Zero value keeps the server default and does not require HELLO support, i.e. works with Redis prior to 6.0
+	Protocol int
+	// Master is the address of the master elected previously. It is kept as the master as long as it reports
+	// the master role, even if no replica is connected to it yet, e.g. right after the failover, unless
+	// a replica is ahead of it: the master restarted empty is not trusted over the replicas holding the data.
+	Master Address
+	// Announced are the addresses the instances announce to the master by the instance addresses,
+	// e.g. the addresses the instances are reachable at from outside of the cluster. Applied by Announce.
+	Announced map[Address]Address
+	// Hostnames are the addresses the replicas replicate from the instances at by the instance addresses,
+	// e.g. the DNS names of the Pods surviving the Pod IP changes. The instance addresses are used if missing.
+	Hostnames map[Address]Address
+	// Caches are the addresses of the replicas kept outside of the replication, e.g. the node-local caches.
+	// They are left out of the replicas the master reports, so they never count for a working master.
+	Caches []Address
+	// Unpromotable are the addresses of the replicas never promoted to master, e.g. the ones stuck
+	// in the full synchronization. They are still reconfigured as replicas of the master.
+	Unpromotable []Address
+	// NewClient creates the clients of the instances, redis.NewClient if nil
+	NewClient func(*redis.Options) Client
+	// Backoff is the policy the instances failing the initial PING are retried with. Tried once if zero.
+	Backoff Backoff
+	// NotReadyBackoff is the policy the instances still replying -LOADING or -BUSY are waited for with in parallel
+	// after the initial PING. They are left out of the replication and reported by NotReady unless they become ready.
+	NotReadyBackoff Backoff
+}
+
+// Validate checks the Options for unsupported values
+func (o Options) Validate() error {
+	switch o.Protocol {
+	case 0, ProtocolRESP2:
+		return nil
+	case ProtocolRESP3:
+		// the replies are parsed as RESP2 by the client library
+		return fmt.Errorf("protocol version %d is not supported by the client", o.Protocol)
+	default:
+		return fmt.Errorf("unknown protocol version %d", o.Protocol)
+	}
+}
+
+// newClient returns the client of the instance at the address
+func (o Options) newClient(address Address) Client {
+	clientOptions := &redis.Options{
+		Addr:      address.String(),
+		Password:  o.password(),
+		TLSConfig: o.TLSConfig,
+		OnConnect: o.onConnect,
+	}
+	if o.NewClient != nil {
+		return o.NewClient(clientOptions)
+	}
+	return redis.NewClient(clientOptions)
+}
+
+// onConnect authenticates as Username, negotiates the protocol and sets the client name on a new connection
+func (o Options) onConnect(conn *redis.Conn) error {
+	if o.Username != "" {
+		if err := conn.Do("AUTH", o.Username, o.Password).Err(); err != nil {
+			if err := conn.Do("AUTH", o.Password).Err(); err != nil {
+				return fmt.Errorf("failed to authenticate: %s", err)
+			}
+		}
+	}
+	if o.Protocol != 0 {
+		if err := conn.Do("HELLO", o.Protocol).Err(); err != nil {
+			return fmt.Errorf("failed to negotiate protocol version %d: %s", o.Protocol, err)
+		}
+	}
+	if o.ClientName != "" {
+		if err := conn.ClientSetName(o.ClientName).Err(); err != nil {
+			return fmt.Errorf("failed to set client name: %s", err)
+		}
+	}
+	return nil
+}
+
+// password returns the password the client library authenticates the connections with.
+// The connections authenticated as Username are authenticated by onConnect.
+func (o Options) password() string {
+	if o.Username != "" {
+		return ""
+	}
+	return o.Password
+}
+
+// QuorumError is returned by New if fewer instances than the minimum replication size are healthy.
+// It carries the result of pinging every instance so that the lost ones can be told apart.
+type QuorumError struct {
+	// Healthy are the addresses of the instances replying to PING
+	Healthy []Address
+	// NotReady are the addresses of the instances loading the dataset or busy
+	NotReady []Address
+	// Unreachable are the errors of pinging the instances by their addresses
+	Unreachable map[Address]error
+}
+
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf("minimum replication size is not met, only %d are healthy", len(e.Healthy))
+}
+
+// RefreshError is returned by New once the quorum is met but the instances failed to be refreshed
+type RefreshError struct {
+	// Healthy is the number of the instances replying to PING
+	Healthy int
+	err     error
+}
+
+func (e *RefreshError) Error() string {
+	return fmt.Sprintf("refreshing instance instances info failed: %s", e.err)
+}
+
 // New creates a new redis replication.
 // Instances are added on the best effort basis. It means that out of N addresses passed
 // if at least 2 instances are healthy the replication will be created. Otherwise New will return an error.
-func New(password string, addresses ...Address) (Replication, error) {
+// Connections are established using TLS if options.TLSConfig is not nil.
+// The instances are not waited for once the context is done.
+func New(ctx context.Context, options Options, addresses ...Address) (Replication, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// the replicas are listed by the master with the announced addresses
+	announced := make(map[Address]Address, len(options.Announced))
+	for address, announcedAddress := range options.Announced {
+		announced[announcedAddress] = address
+	}
+
+	caches := make(map[Address]bool, len(options.Caches))
+	for _, address := range options.Caches {
+		caches[address] = true
+	}
+	unpromotable := make(map[Address]bool, len(options.Unpromotable))
+	for _, address := range options.Unpromotable {
+		unpromotable[address] = true
+	}
+
 	instances := make(instances, 0, len(addresses))
+	var pending []instance
+	unreachable := make(map[Address]error)
 	for _, address := range addresses {
 		r := instance{
-			Address: address,
-			client:  redis.NewClient(&redis.Options{Addr: address.String(), Password: password}),
+			Address:          address,
+			client:           options.newClient(address),
+			clientName:       options.ClientName,
+			knownMaster:      options.Master != (Address{}) && address == options.Master,
+			announcedAddress: options.Announced[address],
+			announced:        announced,
+			hostnames:        options.Hostnames,
+			caches:           caches,
+			unpromotable:     unpromotable,
 		}
 
 		// check connection and add the instance if Ping succeeds
-		if err := r.client.Ping().Err(); err != nil {
-			// TODO: handle -BUSY status
+		if err := options.Backoff.retry(ctx, func() error { return r.client.Ping().Err() }); err != nil {
+			// the instances loading the dataset or running a busy script are waited for below
+			if notReady(err) {
+				pending = append(pending, r)
+				continue
+			}
+			unreachable[address] = err
 			_ = r.client.Close()
 			continue
 		}
 		instances = append(instances, r)
 	}
 
-	if len(instances) < MinimumFailoverSize {
+	var notReady []Address
+	if len(pending) > 0 {
+		var ready []instance
+		ready, notReady = awaitReady(ctx, options.NotReadyBackoff, pending)
+		instances = append(instances, ready...)
+	}
+
+	if err := ctx.Err(); err != nil {
+		instances.Disconnect()
+		return nil, err
+	}
+	if !failover.QuorumMet(len(instances)) {
 		instances.Disconnect()
-		return nil, fmt.Errorf("minimum replication size is not met, only %d are healthy", len(instances))
+		// the instances not ready yet are not lost, the replication is awaited rather than recovered
+		if failover.QuorumMet(len(instances) + len(notReady)) {
+			return nil, &NotReadyError{Ready: len(instances), NotReady: notReady}
+		}
+		healthy := make([]Address, len(instances))
+		for i := range instances {
+			healthy[i] = instances[i].Address
+		}
+		return nil, &QuorumError{Healthy: healthy, NotReady: notReady, Unreachable: unreachable}
 	}
 
-	if err := instances.Refresh(); err != nil {
+	if err := instances.Refresh(ctx); err != nil {
 		instances.Disconnect()
-		return nil, fmt.Errorf("refreshing instance instances info failed: %s", err)
+		return nil, &RefreshError{Healthy: len(instances), err: err}
 	}
 
-	return instances, nil
+	return replication{instances: instances, notReady: notReady}, nil
 }
@@ -0,0 +1,64 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package redis
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func FuzzRedis_refresh(f *testing.F) {
+	for _, info := range []string{
+		masterInfo,
+		replicaInfo,
+		replicaInfo + "\n" + persistenceInfo,
+		strings.Replace(masterInfo, "port=6379", "port=6380", -1),
+		"role:err",
+		"role:master\nslave0:ip=999.0.0.1,port=6379,state=online,offset=1,lag=0",
+	} {
+		f.Add(info)
+	}
+	f.Fuzz(func(t *testing.T, info string) {
+		r := &instance{}
+		if err := r.refresh(info); err != nil {
+			return
+		}
+		if r.role != RoleMaster && r.role != RoleReplica {
+			t.Errorf("instance.refresh() role = %q", r.role)
+		}
+		for _, replica := range r.replicas {
+			if replica.Host == "" {
+				t.Errorf("instance.refresh() replica host is empty")
+			}
+			if _, err := strconv.Atoi(replica.Port); err != nil {
+				t.Errorf("instance.refresh() replica port %q is not a number", replica.Port)
+			}
+		}
+
+		// INFO lines are terminated by CRLF
+		crlf := &instance{}
+		if err := crlf.refresh(strings.Replace(info, "\n", "\r\n", -1)); err != nil {
+			t.Fatalf("instance.refresh() with CRLF error = %v", err)
+		}
+		if !reflect.DeepEqual(crlf, r) {
+			t.Errorf("instance.refresh() with CRLF\nhave: %+v\nwant: %+v", crlf, r)
+		}
+	})
+}
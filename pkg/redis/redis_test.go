@@ -15,9 +15,14 @@
 package redis
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redis"
 )
@@ -55,46 +60,84 @@ repl_backlog_active:1
 repl_backlog_size:1048576
 repl_backlog_first_byte_offset:1
 repl_backlog_histlen:47054`
+	persistenceInfo = `# Persistence
+loading:0
+rdb_changes_since_last_save:0
+rdb_bgsave_in_progress:0
+rdb_last_save_time:1580000000
+rdb_last_bgsave_status:err
+rdb_last_bgsave_time_sec:0
+rdb_current_bgsave_time_sec:-1
+aof_enabled:1
+aof_rewrite_in_progress:0
+aof_last_bgrewrite_status:ok
+aof_last_write_status:ok
+aof_delayed_fsync:12`
 )
 
-func Test_buildInfoReplicationRe(t *testing.T) {
+func TestAddress_String(t *testing.T) {
 	tests := []struct {
-		name  string
-		input string
-		want  []string
+		address Address
+		want    string
 	}{
-		{
-			"master",
-			masterInfo,
-			[]string{
-				"connected_slaves:2",
-				"slave0:ip=172.18.0.5,port=6379,state=online,offset=47054,lag=1",
-				"slave1:ip=172.18.0.4,port=6379,state=online,offset=47040,lag=1",
-				"master_repl_offset:47054",
-			},
-		},
-		{
-			"replica",
-			replicaInfo,
-			[]string{
-				"master_host:172.18.0.2",
-				"master_port:6379",
-				"master_link_status:up",
-				"slave_repl_offset:47054",
-				"slave_priority:100",
-				"connected_slaves:0",
-				"master_repl_offset:47054",
-			},
-		},
+		{Address{"172.18.0.2", "6379"}, "172.18.0.2:6379"},
+		{Address{"fd00:10:244::2", "6379"}, "[fd00:10:244::2]:6379"},
+		{Address{"redis-example-0.redis-example-headless", "6379"}, "redis-example-0.redis-example-headless:6379"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.address.String(); got != tt.want {
+				t.Errorf("Address.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"172.18.0.2", "172.18.0.2"},
+		{"fd00:10:244::2", "fd00:10:244::2"},
+		{"[fd00:10:244::2]", "fd00:10:244::2"},
+		{"fd00:10:244:0:0:0:0:2", "fd00:10:244::2"},
+		{"::ffff:172.18.0.2", "172.18.0.2"},
+		{"fe80::1%eth0", "fe80::1%eth0"},
+		{"redis-example-0.redis-example-headless", "redis-example-0.redis-example-headless"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := parseHost(tt.host); got != tt.want {
+				t.Errorf("parseHost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedis_replicationTarget(t *testing.T) {
+	master := Address{"172.18.0.2", "6379"}
+	hostname := Address{"redis-example-0.redis-example-headless.default.svc.cluster.local", "6379"}
+	tests := []struct {
+		name      string
+		hostnames map[Address]Address
+		master    Address
+		want      Address
+	}{
+		{"addressed by IP", nil, master, master},
+		{"addressed by hostname", map[Address]Address{master: hostname}, master, hostname},
+		{"hostname unknown", map[Address]Address{master: hostname}, Address{"172.18.0.4", "6379"}, Address{"172.18.0.4", "6379"}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := buildInfoReplicationRe().FindAllString(tt.input, -1); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("buildInfoReplicationRe() = %v, want %v", got, tt.want)
+			i := &instance{hostnames: tt.hostnames}
+			if got := i.replicationTarget(tt.master); got != tt.want {
+				t.Errorf("replicationTarget() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
 func TestRedises_Sort(t *testing.T) {
 	tests := []struct {
 		name string
@@ -190,12 +233,85 @@ func TestRedis_refresh(t *testing.T) {
 			"replica",
 			replicaInfo,
 			&instance{
-				role:              RoleReplica,
+				role:                   RoleReplica,
+				replicationOffset:      47054,
+				replicaPriority:        100,
+				masterHost:             "172.18.0.2",
+				masterPort:             "6379",
+				masterLinkStatus:       "up",
+				masterLastIOSecondsAgo: 4,
+			},
+			false,
+		},
+		{
+			"custom port",
+			strings.Replace(masterInfo, "port=6379", "port=6380", -1),
+			&instance{
+				role:              RoleMaster,
+				replicationOffset: 47054,
+				connectedReplicas: 2,
+				replicas: instances{
+					instance{
+						Address:           Address{"172.18.0.5", "6380"},
+						replicationOffset: 47054,
+					},
+					instance{
+						Address:           Address{"172.18.0.4", "6380"},
+						replicationOffset: 47040,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"IPv6 master",
+			strings.NewReplacer("172.18.0.5", "fd00:10:244:0:0:0:0:5", "172.18.0.4", "::ffff:172.18.0.4").Replace(masterInfo),
+			&instance{
+				role:              RoleMaster,
 				replicationOffset: 47054,
-				replicaPriority:   100,
-				masterHost:        "172.18.0.2",
-				masterPort:        "6379",
-				masterLinkStatus:  "up",
+				connectedReplicas: 2,
+				replicas: instances{
+					instance{
+						Address:           Address{"fd00:10:244::5", "6379"},
+						replicationOffset: 47054,
+					},
+					instance{
+						Address:           Address{"172.18.0.4", "6379"},
+						replicationOffset: 47040,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"IPv6 replica",
+			strings.Replace(replicaInfo, "172.18.0.2", "[fd00:10:244::2]", 1),
+			&instance{
+				role:                   RoleReplica,
+				replicationOffset:      47054,
+				replicaPriority:        100,
+				masterHost:             "fd00:10:244::2",
+				masterPort:             "6379",
+				masterLinkStatus:       "up",
+				masterLastIOSecondsAgo: 4,
+			},
+			false,
+		},
+		{
+			"replica with persistence",
+			replicaInfo + "\n" + persistenceInfo,
+			&instance{
+				role:                   RoleReplica,
+				replicationOffset:      47054,
+				replicaPriority:        100,
+				masterHost:             "172.18.0.2",
+				masterPort:             "6379",
+				masterLinkStatus:       "up",
+				masterLastIOSecondsAgo: 4,
+				rdbLastBgsaveStatus:    "err",
+				aofLastWriteStatus:     StatusOK,
+				aofEnabled:             true,
+				aofDelayedFsync:        12,
 			},
 			false,
 		},
@@ -213,6 +329,18 @@ func TestRedis_refresh(t *testing.T) {
 	}
 }
 
+func TestRedis_refresh_caches(t *testing.T) {
+	r := &instance{caches: map[Address]bool{{"172.18.0.4", "6379"}: true}}
+	if err := r.refresh(masterInfo); err != nil {
+		t.Fatalf("instance.refresh() error = %v", err)
+	}
+	want := instances{{Address: Address{"172.18.0.5", "6379"}, replicationOffset: 47054}}
+	if r.connectedReplicas != 1 || !reflect.DeepEqual(r.replicas, want) {
+		t.Errorf("instance.refresh() connectedReplicas = %d, replicas = %+v, want the cache left out",
+			r.connectedReplicas, r.replicas)
+	}
+}
+
 func TestRedises_SelectMaster(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -314,6 +442,30 @@ func TestRedises_SelectMaster(t *testing.T) {
 				},
 			},
 		},
+		{
+			"known master with replicas reconnecting",
+			instances{
+				instance{
+					Address: Address{"172.18.0.5", "6379"},
+					role:    RoleReplica,
+				},
+				instance{
+					Address:         Address{"172.18.0.6", "6379"},
+					role:            RoleReplica,
+					replicaPriority: 100,
+				},
+				instance{
+					Address:     Address{"172.18.0.7", "6379"},
+					role:        RoleMaster,
+					knownMaster: true,
+				},
+			},
+			&instance{
+				Address:     Address{"172.18.0.7", "6379"},
+				role:        RoleMaster,
+				knownMaster: true,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -434,13 +586,199 @@ func TestRedises_Reconfigure(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.instances.Reconfigure(); (err != nil) != tt.wantErr {
+			if _, err := tt.instances.Reconfigure(context.Background()); (err != nil) != tt.wantErr {
 				t.Errorf("instances.Reconfigure() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
 
+func TestRedises_Unsynced(t *testing.T) {
+	master := instance{
+		Address:           Address{"10.0.0.1", "6379"},
+		role:              RoleMaster,
+		replicationOffset: 1000,
+		connectedReplicas: 2,
+		replicas: instances{
+			{Address: Address{"10.0.0.2", "6379"}},
+			{Address: Address{"10.0.0.3", "6379"}},
+		},
+	}
+	replica := func(host string, offset int, link string) instance {
+		return instance{
+			Address:           Address{host, "6379"},
+			role:              RoleReplica,
+			replicationOffset: offset,
+			replicaPriority:   100,
+			masterLinkStatus:  link,
+		}
+	}
+	tests := []struct {
+		name      string
+		instances instances
+		maxLag    int64
+		want      []Address
+	}{
+		{"synced", instances{master, replica("10.0.0.2", 1000, StatusUp), replica("10.0.0.3", 1000, StatusUp)}, 0, nil},
+		{
+			"lagging",
+			instances{master, replica("10.0.0.2", 1000, StatusUp), replica("10.0.0.3", 900, StatusUp)},
+			0,
+			[]Address{{"10.0.0.3", "6379"}},
+		},
+		{"acceptable lag", instances{master, replica("10.0.0.2", 1000, StatusUp), replica("10.0.0.3", 900, StatusUp)}, 100, nil},
+		{
+			"link down",
+			instances{master, replica("10.0.0.2", 1000, StatusUp), replica("10.0.0.3", 1000, "down")},
+			0,
+			[]Address{{"10.0.0.3", "6379"}},
+		},
+		{
+			"not connected",
+			instances{master, replica("10.0.0.2", 1000, StatusUp), replica("10.0.0.4", 1000, StatusUp)},
+			0,
+			[]Address{{"10.0.0.4", "6379"}},
+		},
+		{
+			"no master",
+			instances{replica("10.0.0.2", 1000, "down"), replica("10.0.0.3", 1000, "down")},
+			0,
+			[]Address{{"10.0.0.2", "6379"}, {"10.0.0.3", "6379"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.instances.Unsynced(tt.maxLag); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("instances.Unsynced() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedises_GetPersistenceFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		instances instances
+		want      map[Address][]string
+	}{
+		{"empty", instances{}, map[Address][]string{}},
+		{
+			"unknown status is not a failure",
+			instances{instance{Address: Address{"172.18.0.5", "6379"}}},
+			map[Address][]string{},
+		},
+		{
+			"failures",
+			instances{
+				instance{
+					Address:             Address{"172.18.0.5", "6379"},
+					rdbLastBgsaveStatus: StatusOK,
+					aofLastWriteStatus:  StatusOK,
+				},
+				instance{
+					Address:             Address{"172.18.0.6", "6379"},
+					rdbLastBgsaveStatus: "err",
+					aofLastWriteStatus:  StatusOK,
+				},
+				instance{
+					Address:             Address{"172.18.0.7", "6379"},
+					rdbLastBgsaveStatus: "err",
+					aofLastWriteStatus:  "err",
+				},
+			},
+			map[Address][]string{
+				{"172.18.0.6", "6379"}: {"rdb_last_bgsave_status:err"},
+				{"172.18.0.7", "6379"}: {"aof_last_write_status:err", "rdb_last_bgsave_status:err"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.instances.GetPersistenceFailures(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("instances.GetPersistenceFailures()\nhave: %v\nwant: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedises_GetAOFDelayedFsyncs(t *testing.T) {
+	ins := instances{
+		instance{Address: Address{"172.18.0.5", "6379"}, aofEnabled: true, aofDelayedFsync: 3},
+		instance{Address: Address{"172.18.0.6", "6379"}, aofEnabled: true},
+		instance{Address: Address{"172.18.0.7", "6379"}, aofDelayedFsync: 5},
+	}
+	want := map[Address]int{{"172.18.0.5", "6379"}: 3, {"172.18.0.6", "6379"}: 0}
+	if got := ins.GetAOFDelayedFsyncs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("instances.GetAOFDelayedFsyncs()\nhave: %v\nwant: %v", got, want)
+	}
+}
+
+func TestRedises_GetReplicationLags(t *testing.T) {
+	master := instance{
+		Address:           Address{"10.0.0.1", "6379"},
+		role:              RoleMaster,
+		replicationOffset: 1000,
+		connectedReplicas: 2,
+	}
+	replica := func(host string, offset, lastIO int) instance {
+		return instance{
+			Address:                Address{host, "6379"},
+			role:                   RoleReplica,
+			replicationOffset:      offset,
+			masterLastIOSecondsAgo: lastIO,
+		}
+	}
+	tests := []struct {
+		name      string
+		instances instances
+		want      map[Address]ReplicationLag
+	}{
+		{
+			"lagging",
+			instances{master, replica("10.0.0.2", 1000, 1), replica("10.0.0.3", 900, -1)},
+			map[Address]ReplicationLag{
+				{"10.0.0.2", "6379"}: {Offset: 0, MasterLastIOSecondsAgo: 1},
+				{"10.0.0.3", "6379"}: {Offset: 100, MasterLastIOSecondsAgo: -1},
+			},
+		},
+		{
+			"replica ahead",
+			instances{master, replica("10.0.0.2", 1010, 0)},
+			map[Address]ReplicationLag{{"10.0.0.2", "6379"}: {Offset: 0}},
+		},
+		{"no master", instances{replica("10.0.0.2", 1000, -1)}, map[Address]ReplicationLag{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.instances.GetReplicationLags(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("instances.GetReplicationLags()\nhave: %v\nwant: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedises_GetSyncing(t *testing.T) {
+	ins := instances{
+		{Address: Address{"10.0.0.1", "6379"}, role: RoleMaster},
+		{Address: Address{"10.0.0.2", "6379"}, role: RoleReplica, masterSyncInProgress: true},
+		{Address: Address{"10.0.0.3", "6379"}, role: RoleReplica},
+		// the flag of the promoted replica is stale
+		{Address: Address{"10.0.0.4", "6379"}, role: RoleMaster, masterSyncInProgress: true},
+	}
+	want := []Address{{"10.0.0.2", "6379"}}
+	if got := ins.GetSyncing(); !reflect.DeepEqual(got, want) {
+		t.Errorf("instances.GetSyncing() = %v, want %v", got, want)
+	}
+}
+
+func TestRedis_node_unpromotable(t *testing.T) {
+	address := Address{"10.0.0.2", "6379"}
+	i := instance{Address: address, role: RoleReplica, replicaPriority: 100, unpromotable: map[Address]bool{address: true}}
+	if !i.node().Excluded {
+		t.Errorf("instance.node() of the unpromotable replica is not excluded from promotion")
+	}
+}
+
 func TestRedises_Disconnect(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -469,3 +807,301 @@ func TestRedises_Disconnect(t *testing.T) {
 		})
 	}
 }
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol int
+		wantErr  bool
+	}{
+		{"server default", 0, false},
+		{"RESP2", ProtocolRESP2, false},
+		{"RESP3", ProtocolRESP3, true},
+		{"unknown", 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (Options{ClientName: DefaultClientName, Protocol: tt.protocol}).Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOptions_password(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+	}{
+		{"default user", Options{Password: "secret"}, "secret"},
+		// authenticated by onConnect
+		{"ACL user", Options{Username: OperatorUser, Password: "secret"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.password(); got != tt.want {
+				t.Errorf("password() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_killableClients(t *testing.T) {
+	clientList := `id=3 addr=10.0.0.5:52555 fd=8 name=redis-operator age=1 idle=0 flags=N db=0 cmd=client
+id=4 addr=127.0.0.1:41234 fd=9 name=redis_exporter age=60 idle=5 flags=N db=0 cmd=info
+id=5 addr=[::1]:41236 fd=10 name= age=2 idle=2 flags=N db=0 cmd=ping
+id=6 addr=10.0.0.7:50000 fd=11 name= age=30 idle=1 flags=N db=0 cmd=get
+id=7 addr=10.0.0.8:50002 fd=12 name=app age=30 idle=1 flags=N db=0 cmd=set
+`
+	tests := []struct {
+		name        string
+		exemptNames []string
+		want        []string
+	}{
+		{"operator and exporter", []string{DefaultClientName, exporterClientName}, []string{"6", "7"}},
+		{"no client name", []string{"", exporterClientName}, []string{"3", "6", "7"}},
+		{"application", []string{DefaultClientName, "app"}, []string{"6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := killableClients(clientList, tt.exemptNames...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("killableClients() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_withContext(t *testing.T) {
+	errFailed := errors.New("failed")
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelExpired()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		fn   func() error
+		want error
+	}{
+		{"returned", context.Background(), func() error { return errFailed }, errFailed},
+		{"canceled", canceled, func() error { return nil }, context.Canceled},
+		{"wedged", expired, func() error { time.Sleep(time.Second); return nil }, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := withContext(tt.ctx, tt.fn); err != tt.want {
+				t.Errorf("withContext() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// fakeClient replies to INFO replication with info and fails the first pingErrors PINGs with pingErr,
+// connection refused by default, the other commands succeed with no reply
+type fakeClient struct {
+	info       string
+	pingErrors int
+	pingErr    error
+
+	mu       sync.Mutex
+	pings    int
+	commands [][]interface{}
+}
+
+func (c *fakeClient) Ping() *redis.StatusCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pings++
+	if c.pings <= c.pingErrors {
+		if c.pingErr != nil {
+			return redis.NewStatusResult("", c.pingErr)
+		}
+		return redis.NewStatusResult("", errors.New("connection refused"))
+	}
+	return redis.NewStatusResult("PONG", nil)
+}
+
+func (c *fakeClient) Info(section ...string) *redis.StringCmd {
+	if len(section) > 0 && section[0] == "replication" {
+		return redis.NewStringResult(c.info, nil)
+	}
+	return redis.NewStringResult("", nil)
+}
+
+func (c *fakeClient) TxPipelined(func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return nil, nil
+}
+
+func (c *fakeClient) Do(args ...interface{}) *redis.Cmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands = append(c.commands, args)
+	return redis.NewCmdResult("OK", nil)
+}
+
+func (c *fakeClient) Publish(string, interface{}) *redis.IntCmd {
+	return redis.NewIntResult(0, nil)
+}
+
+func (c *fakeClient) Subscribe(...string) *redis.PubSub {
+	return nil
+}
+
+func (c *fakeClient) Close() error {
+	return nil
+}
+
+func TestNew_fakeClients(t *testing.T) {
+	master, replica1, replica2 := Address{"172.18.0.2", "6379"}, Address{"172.18.0.4", "6379"}, Address{"172.18.0.5", "6379"}
+	tests := []struct {
+		name       string
+		pingErrors int
+		backoff    Backoff
+		wantErr    bool
+	}{
+		{"reachable", 0, Backoff{}, false},
+		{"unreachable once", 1, Backoff{}, true},
+		{"retried", 2, Backoff{Steps: 3, Duration: time.Millisecond, Factor: 2}, false},
+		{"retries run out", 3, Backoff{Steps: 3, Duration: time.Millisecond}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := map[string]*fakeClient{
+				master.String():   {info: masterInfo},
+				replica1.String(): {info: replicaInfo, pingErrors: tt.pingErrors},
+				replica2.String(): {info: replicaInfo, pingErrors: tt.pingErrors},
+			}
+			options := Options{
+				NewClient: func(options *redis.Options) Client { return clients[options.Addr] },
+				Backoff:   tt.backoff,
+			}
+
+			replication, err := New(context.Background(), options, master, replica1, replica2)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer replication.Disconnect()
+
+			if got := replication.GetMasterAddress(); got != master {
+				t.Errorf("GetMasterAddress() = %v, want %v", got, master)
+			}
+			reconfiguration, err := replication.Reconfigure(context.Background())
+			if err != nil {
+				t.Fatalf("Reconfigure() error = %v", err)
+			}
+			if !reflect.DeepEqual(reconfiguration, Reconfiguration{}) {
+				t.Errorf("Reconfigure() = %+v, want no changes", reconfiguration)
+			}
+			for address, client := range clients {
+				if len(client.commands) > 0 {
+					t.Errorf("commands sent to %s = %v, want none", address, client.commands)
+				}
+			}
+		})
+	}
+}
+
+func TestNew_notReady(t *testing.T) {
+	master, replica1, replica2 := Address{"172.18.0.2", "6379"}, Address{"172.18.0.4", "6379"}, Address{"172.18.0.5", "6379"}
+	loading := errors.New("LOADING Redis is loading the dataset in memory")
+	busy := errors.New("BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.")
+	tests := []struct {
+		name         string
+		replica1     *fakeClient
+		replica2     *fakeClient
+		backoff      Backoff
+		wantNotReady []Address
+		wantErr      bool
+		// the quorum is met along with the instances not ready
+		wantNotReadyErr bool
+		// the instances reported unreachable by QuorumError
+		wantUnreachable []Address
+	}{
+		{
+			name:         "one loading",
+			replica1:     &fakeClient{info: replicaInfo, pingErrors: 10, pingErr: loading},
+			replica2:     &fakeClient{info: replicaInfo},
+			wantNotReady: []Address{replica1},
+		},
+		{
+			name:     "loaded while waited for",
+			replica1: &fakeClient{info: replicaInfo, pingErrors: 2, pingErr: loading},
+			replica2: &fakeClient{info: replicaInfo, pingErrors: 1, pingErr: busy},
+			backoff:  Backoff{Steps: 3, Duration: time.Millisecond},
+		},
+		{
+			name:            "quorum met with not ready",
+			replica1:        &fakeClient{info: replicaInfo, pingErrors: 10, pingErr: loading},
+			replica2:        &fakeClient{info: replicaInfo, pingErrors: 10, pingErr: busy},
+			backoff:         Backoff{Steps: 2, Duration: time.Millisecond},
+			wantErr:         true,
+			wantNotReadyErr: true,
+		},
+		{
+			name:            "unreachable and loading",
+			replica1:        &fakeClient{info: replicaInfo, pingErrors: 10},
+			replica2:        &fakeClient{info: replicaInfo, pingErrors: 10, pingErr: loading},
+			wantErr:         true,
+			wantNotReadyErr: true,
+		},
+		{
+			name:            "unreachable",
+			replica1:        &fakeClient{info: replicaInfo, pingErrors: 10},
+			replica2:        &fakeClient{info: replicaInfo, pingErrors: 10},
+			wantErr:         true,
+			wantUnreachable: []Address{replica1, replica2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := map[string]*fakeClient{
+				master.String():   {info: masterInfo},
+				replica1.String(): tt.replica1,
+				replica2.String(): tt.replica2,
+			}
+			options := Options{
+				NewClient:       func(options *redis.Options) Client { return clients[options.Addr] },
+				NotReadyBackoff: tt.backoff,
+			}
+
+			replication, err := New(context.Background(), options, master, replica1, replica2)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if _, ok := err.(*NotReadyError); ok != tt.wantNotReadyErr {
+					t.Errorf("New() error = %T, want *NotReadyError %v", err, tt.wantNotReadyErr)
+				}
+				if e, ok := err.(*QuorumError); ok {
+					if !reflect.DeepEqual(e.Healthy, []Address{master}) {
+						t.Errorf("QuorumError.Healthy = %v, want %v", e.Healthy, []Address{master})
+					}
+					for _, address := range tt.wantUnreachable {
+						if e.Unreachable[address] == nil {
+							t.Errorf("QuorumError.Unreachable[%s] = nil, want the PING error", address)
+						}
+					}
+					if len(e.Unreachable) != len(tt.wantUnreachable) {
+						t.Errorf("QuorumError.Unreachable = %v, want %v", e.Unreachable, tt.wantUnreachable)
+					}
+				} else if tt.wantUnreachable != nil {
+					t.Errorf("New() error = %T, want *QuorumError", err)
+				}
+				return
+			}
+			defer replication.Disconnect()
+
+			if got := replication.NotReady(); !reflect.DeepEqual(got, tt.wantNotReady) {
+				t.Errorf("NotReady() = %v, want %v", got, tt.wantNotReady)
+			}
+			if got, want := replication.Size(), 3-len(tt.wantNotReady); got != want {
+				t.Errorf("Size() = %d, want %d", got, want)
+			}
+		})
+	}
+}
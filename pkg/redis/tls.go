@@ -0,0 +1,63 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/amaizfinance/redis-operator/pkg/fips"
+)
+
+// NewTLSConfig returns the TLS configuration for connecting to Redis instances.
+// certPEM and keyPEM are presented as the client certificate, caPEM is used to verify instances.
+//
+// Instances are addressed by Pod IPs which are not expected to be present in the certificate SANs.
+// Hence the certificate chain is verified against the CA while the host name verification is skipped.
+func NewTLSConfig(certPEM, keyPEM, caPEM []byte) (*tls.Config, error) {
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the key pair: %s", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("failed to load the CA certificate")
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+		// the default verification is replaced by VerifyConnection below
+		InsecureSkipVerify: true, // nolint:gosec
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("no certificates presented by the instance")
+			}
+			options := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+			for _, intermediate := range state.PeerCertificates[1:] {
+				options.Intermediates.AddCert(intermediate)
+			}
+			_, err := state.PeerCertificates[0].Verify(options)
+			return err
+		},
+	}
+	if fips.Enabled {
+		fips.ConfigureTLS(config)
+	}
+	return config, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// generateCertificate issues a certificate signed by parent. Self-signed CA is generated if parent is nil.
+func generateCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "redis-example-headless"},
+		DNSNames:     []string{"redis-example-headless"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	ca, caKey, caPEM, _ := generateCertificate(t, nil, nil)
+	_, _, certPEM, keyPEM := generateCertificate(t, ca, caKey)
+	_, _, foreignCAPEM, _ := generateCertificate(t, nil, nil)
+
+	tests := []struct {
+		name          string
+		certPEM       []byte
+		keyPEM        []byte
+		caPEM         []byte
+		wantErr       bool
+		wantHandshake bool
+	}{
+		{"empty", nil, nil, nil, true, false},
+		{"no CA", certPEM, keyPEM, nil, true, false},
+		{"valid", certPEM, keyPEM, caPEM, false, true},
+		{"foreign CA", certPEM, keyPEM, foreignCAPEM, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewTLSConfig(tt.certPEM, tt.keyPEM, tt.caPEM)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// the server presents the same certificate as the client does
+			serverConn, clientConn := net.Pipe()
+			defer serverConn.Close()
+			defer clientConn.Close()
+			server := tls.Server(serverConn, &tls.Config{Certificates: config.Certificates})
+			go func() { _ = server.Handshake() }()
+
+			// connecting by IP address must not fail the host name verification
+			config.ServerName = "10.0.0.1"
+			if err := tls.Client(clientConn, config).Handshake(); (err == nil) != tt.wantHandshake {
+				t.Errorf("Handshake() error = %v, wantHandshake %v", err, tt.wantHandshake)
+			}
+		})
+	}
+}
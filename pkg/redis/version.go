@@ -0,0 +1,49 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+)
+
+// Versions returns the versions of the reachable instances as reported by INFO server, e.g. 6.0.9.
+// The unreachable instances are skipped.
+func Versions(ctx context.Context, options Options, addresses ...Address) (map[Address]string, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	ins := connectReachable(ctx, options, addresses...)
+	defer func() { ins.Disconnect() }()
+
+	versions := make(map[Address]string, len(ins))
+	for i := range ins {
+		var info string
+		client := ins[i].client
+		if err := withContext(ctx, func() (err error) {
+			info, err = client.Info("server").Result()
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("getting info server failed for %s: %s", ins[i].Address, err)
+		}
+		parsed, err := ParseInfo(info)
+		if err != nil {
+			return nil, fmt.Errorf("parsing info server failed for %s: %s", ins[i].Address, err)
+		}
+		versions[ins[i].Address] = parsed.Server.RedisVersion
+	}
+	return versions, nil
+}
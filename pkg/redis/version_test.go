@@ -0,0 +1,40 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import "testing"
+
+func TestInfo_Field(t *testing.T) {
+	info := "# Server\r\nredis_version:6.0.9\r\nredis_git_sha1:00000000\r\nredis_mode:standalone\r\n"
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"redis_version", "6.0.9"},
+		{"redis_mode", "standalone"},
+		{"redis_build_id", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseInfo(info)
+			if err != nil {
+				t.Fatalf("ParseInfo() error = %v", err)
+			}
+			if got := parsed.Field(tt.name); got != tt.want {
+				t.Errorf("Info.Field() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxResponseSize limits the size of the responses read from the registry
+	maxResponseSize = 4 << 20
+	// maxTagPages limits the number of the tag list pages followed
+	maxTagPages = 50
+
+	// ManifestMediaTypes are the accepted image manifest media types, both OCI and Docker schema 2
+	ManifestMediaTypes = "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+	// indexMediaTypes are the multi-platform image media types
+	indexMediaTypes = "application/vnd.oci.image.index.v1+json, application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+var (
+	// ErrNotFound is returned if the registry has no such manifest or blob
+	ErrNotFound = errors.New("not found")
+
+	// nextLinkRegexp matches the next page link of the tag list, e.g. </v2/library/redis/tags/list?last=6.0&n=100>; rel="next"
+	nextLinkRegexp = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+)
+
+// Credentials are the registry credentials
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// dockerConfig is the content of the kubernetes.io/dockerconfigjson Secret
+type dockerConfig struct {
+	Auths map[string]struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	} `json:"auths"`
+}
+
+// ParseDockerConfig returns the credentials of the .dockerconfigjson key of the image pull Secret by the registry host
+func ParseDockerConfig(data []byte) (map[string]Credentials, error) {
+	config := new(dockerConfig)
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+
+	credentials := make(map[string]Credentials, len(config.Auths))
+	for registry, auth := range config.Auths {
+		c := Credentials{Username: auth.Username, Password: auth.Password}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth of %s: %s", registry, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid auth of %s", registry)
+			}
+			c.Username, c.Password = parts[0], parts[1]
+		}
+		credentials[Host(registry)] = c
+	}
+	return credentials, nil
+}
+
+// Client reads a repository of the registry API v2. The token authentication is used
+// if the registry challenges for it, the credentials are optional. Client is not safe for concurrent use.
+type Client struct {
+	client      *http.Client
+	reference   Reference
+	credentials *Credentials
+	token       string
+}
+
+// NewClient returns the client of the repository of the reference.
+// credentials are the registry credentials by the registry host, anonymous access is used if missing.
+// http.DefaultClient is used if httpClient is nil.
+func NewClient(httpClient *http.Client, reference Reference, credentials map[string]Credentials) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	c := &Client{client: httpClient, reference: reference}
+	if credential, ok := credentials[Host(reference.Registry)]; ok {
+		c.credentials = &credential
+	}
+	return c
+}
+
+// Manifest fetches the manifest of the tag or digest
+func (c *Client) Manifest(ctx context.Context, tag string) ([]byte, error) {
+	body, _, err := c.get(ctx, "manifests/"+tag, ManifestMediaTypes)
+	return body, err
+}
+
+// Blob fetches the blob by digest
+func (c *Client) Blob(ctx context.Context, digest string) ([]byte, error) {
+	body, _, err := c.get(ctx, "blobs/"+digest, "*/*")
+	return body, err
+}
+
+// Digest resolves the digest of the tag, the digest of the multi-platform index if the image has one
+func (c *Client) Digest(ctx context.Context, tag string) (string, error) {
+	_, header, err := c.get(ctx, "manifests/"+tag, indexMediaTypes+", "+ManifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+	digest := header.Get("Docker-Content-Digest")
+	if !digestRegexp.MatchString(digest) {
+		return "", fmt.Errorf("registry returned no digest of %s", tag)
+	}
+	return digest, nil
+}
+
+// Tags lists the tags of the repository following the pages
+func (c *Client) Tags(ctx context.Context) ([]string, error) {
+	var tags []string
+	resource := "tags/list"
+	for page := 0; page < maxTagPages && resource != ""; page++ {
+		body, header, err := c.get(ctx, resource, "application/json")
+		if err != nil {
+			return nil, err
+		}
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, fmt.Errorf("invalid tag list: %s", err)
+		}
+		tags = append(tags, list.Tags...)
+
+		resource = ""
+		if match := nextLinkRegexp.FindStringSubmatch(header.Get("Link")); match != nil {
+			next, err := url.Parse(match[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid tag list link: %s", err)
+			}
+			resource = "tags/list?" + next.RawQuery
+		}
+	}
+	return tags, nil
+}
+
+// get reads the resource of the repository authenticating on the challenge
+func (c *Client) get(ctx context.Context, resource, accept string) ([]byte, http.Header, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/%s", c.reference.apiHost(), c.reference.Repository, resource)
+
+	response, err := c.do(ctx, u, accept)
+	if err != nil {
+		return nil, nil, err
+	}
+	if response.StatusCode == http.StatusUnauthorized && c.token == "" {
+		challenge := response.Header.Get("WWW-Authenticate")
+		response.Body.Close()
+		if err := c.authenticate(ctx, challenge); err != nil {
+			return nil, nil, fmt.Errorf("failed to authenticate to %s: %s", c.reference.Registry, err)
+		}
+		if response, err = c.do(ctx, u, accept); err != nil {
+			return nil, nil, err
+		}
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+		return body, response.Header, err
+	case http.StatusNotFound:
+		return nil, nil, ErrNotFound
+	default:
+		return nil, nil, fmt.Errorf("failed to fetch %s: %s", u, response.Status)
+	}
+}
+
+func (c *Client) do(ctx context.Context, u, accept string) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", accept)
+	switch {
+	case c.token != "":
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	case c.credentials != nil:
+		request.SetBasicAuth(c.credentials.Username, c.credentials.Password)
+	}
+	return c.client.Do(request.WithContext(ctx))
+}
+
+// authenticate requests the token from the realm of the Bearer challenge,
+// e.g. Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/redis:pull"
+func (c *Client) authenticate(ctx context.Context, challenge string) error {
+	scheme, params := parseChallenge(challenge)
+	if !strings.EqualFold(scheme, "bearer") || params["realm"] == "" {
+		return fmt.Errorf("unsupported challenge %q", challenge)
+	}
+
+	query := url.Values{}
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", c.reference.Repository)
+	}
+	query.Set("scope", scope)
+
+	request, err := http.NewRequest(http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if c.credentials != nil {
+		request.SetBasicAuth(c.credentials.Username, c.credentials.Password)
+	}
+	response, err := c.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request failed: %s", response.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&token); err != nil {
+		return fmt.Errorf("invalid token response: %s", err)
+	}
+	if c.token = token.Token; c.token == "" {
+		c.token = token.AccessToken
+	}
+	if c.token == "" {
+		return fmt.Errorf("empty token")
+	}
+	return nil
+}
+
+// parseChallenge parses the WWW-Authenticate header into the scheme and the parameters
+func parseChallenge(challenge string) (scheme string, params map[string]string) {
+	params = make(map[string]string)
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], params
+	}
+
+	rest := parts[1]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(rest[:eq])
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[strings.ToLower(key)] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return parts[0], params
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/test/redis/tags/list":
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", `</v2/test/redis/tags/list?last=6.0.9&n=2>; rel="next"`)
+				_, _ = w.Write([]byte(`{"name":"test/redis","tags":["6.0.8","6.0.9"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"name":"test/redis","tags":["6.0.10"]}`))
+		case "/v2/test/redis/manifests/6.0.10":
+			w.Header().Set("Docker-Content-Digest", testDigest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	reference, err := ParseReference(strings.TrimPrefix(server.URL, "https://") + "/test/redis:6.0.8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(server.Client(), reference, map[string]Credentials{reference.Registry: {"user", "pass"}})
+
+	tags, err := c.Tags(context.Background())
+	if err != nil {
+		t.Fatalf("Tags() error = %v", err)
+	}
+	if want := []string{"6.0.8", "6.0.9", "6.0.10"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Tags() = %v, want %v", tags, want)
+	}
+
+	if digest, err := c.Digest(context.Background(), "6.0.10"); err != nil || digest != testDigest {
+		t.Errorf("Digest() = %v, %v, want %v", digest, err, testDigest)
+	}
+	if _, err := c.Digest(context.Background(), "7.0.0"); err != ErrNotFound {
+		t.Errorf("Digest() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestParseDockerConfig(t *testing.T) {
+	config := `{"auths":{"https://index.docker.io/v1/":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("user:pass")) +
+		`"},"ghcr.io":{"username":"bot","password":"token"}}}`
+	got, err := ParseDockerConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := got[DockerHub]; c != (Credentials{"user", "pass"}) {
+		t.Errorf("ParseDockerConfig() docker.io = %v", c)
+	}
+	if c := got["ghcr.io"]; c != (Credentials{"bot", "token"}) {
+		t.Errorf("ParseDockerConfig() ghcr.io = %v", c)
+	}
+}
+
+func Test_parseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/redis:pull"`)
+	if scheme != "Bearer" {
+		t.Errorf("parseChallenge() scheme = %v", scheme)
+	}
+	for key, want := range map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/redis:pull",
+	} {
+		if params[key] != want {
+			t.Errorf("parseChallenge() %s = %v, want %v", key, params[key], want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DockerHub is the registry of the images without the registry host
+	DockerHub = "docker.io"
+	// dockerHubAPI is the API host of Docker Hub
+	dockerHubAPI = "registry-1.docker.io"
+)
+
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// Reference is a container image reference
+type Reference struct {
+	// Registry is the registry host, docker.io for Docker Hub
+	Registry string
+	// Repository is the repository path in the registry, e.g. library/redis
+	Repository string
+	// Tag is the image tag, empty if omitted
+	Tag string
+	// Digest is the manifest digest, e.g. sha256:0123..., empty if the reference is not pinned
+	Digest string
+}
+
+// ParseReference parses the image reference, e.g. redis:6.0@sha256:0123...
+func ParseReference(image string) (Reference, error) {
+	name, digest := image, ""
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		name, digest = image[:i], image[i+1:]
+		if !digestRegexp.MatchString(digest) {
+			return Reference{}, fmt.Errorf("image %s has an invalid digest", image)
+		}
+	}
+
+	reference := Reference{Registry: DockerHub, Digest: digest}
+	// the tag follows the last path component
+	if slash, colon := strings.LastIndex(name, "/"), strings.LastIndex(name, ":"); colon > slash {
+		name, reference.Tag = name[:colon], name[colon+1:]
+	}
+
+	reference.Repository = name
+	if parts := strings.SplitN(name, "/", 2); len(parts) == 2 &&
+		(strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		reference.Registry, reference.Repository = parts[0], parts[1]
+	}
+	if reference.Repository == "" {
+		return Reference{}, fmt.Errorf("image %s has no repository", image)
+	}
+	if Host(reference.Registry) == DockerHub && !strings.Contains(reference.Repository, "/") {
+		reference.Repository = "library/" + reference.Repository
+	}
+	return reference, nil
+}
+
+// String returns the canonical reference
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// apiHost returns the host the registry API is served at
+func (r Reference) apiHost() string {
+	if Host(r.Registry) == DockerHub {
+		return dockerHubAPI
+	}
+	return r.Registry
+}
+
+// Host normalizes the registry host or the Docker config key, e.g. https://index.docker.io/v1/
+func Host(registry string) string {
+	registry = strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	registry = strings.SplitN(registry, "/", 2)[0]
+	switch registry {
+	case "index.docker.io", dockerHubAPI:
+		return DockerHub
+	}
+	return registry
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import "testing"
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   string
+		want    Reference
+		wantErr bool
+	}{
+		{"official", "redis@" + testDigest, Reference{DockerHub, "library/redis", "", testDigest}, false},
+		{"tag", "bitnami/redis:6.0@" + testDigest, Reference{DockerHub, "bitnami/redis", "6.0", testDigest}, false},
+		{"not pinned", "redis:6.0.9-alpine", Reference{DockerHub, "library/redis", "6.0.9-alpine", ""}, false},
+		{"registry", "ghcr.io/org/redis:6.0@" + testDigest, Reference{"ghcr.io", "org/redis", "6.0", testDigest}, false},
+		{"port", "localhost:5000/redis@" + testDigest, Reference{"localhost:5000", "redis", "", testDigest}, false},
+		{"invalid digest", "redis@sha256:00", Reference{}, true},
+		{"empty", "", Reference{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReference(tt.image)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReference() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// versionRegexp matches the version tags like 6.0.9, v6.0.9 or 6.0.9-alpine
+var versionRegexp = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)(.*)$`)
+
+// Version is the version of an image tag. Prefix and Suffix tell the image variants apart,
+// e.g. 6.0.9-alpine has the -alpine suffix.
+type Version struct {
+	Tag                 string
+	Prefix              string
+	Major, Minor, Patch int
+	Suffix              string
+}
+
+// ParseVersion parses the version tag, false is returned if the tag is not a version
+func ParseVersion(tag string) (Version, bool) {
+	match := versionRegexp.FindStringSubmatch(tag)
+	if match == nil {
+		return Version{}, false
+	}
+	v := Version{Tag: tag, Prefix: match[1], Suffix: match[5]}
+	var err error
+	for i, number := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		if *number, err = strconv.Atoi(match[i+2]); err != nil {
+			return Version{}, false
+		}
+	}
+	return v, true
+}
+
+// Less reports whether the version precedes the other one
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+// Newest returns the newest version among the tags of the same variant and major version as current,
+// and of the same minor version if sameMinor is set. The current version is returned if there is no newer one.
+func Newest(current Version, tags []string, sameMinor bool) Version {
+	newest := current
+	for _, tag := range tags {
+		v, ok := ParseVersion(tag)
+		if !ok || v.Prefix != current.Prefix || v.Suffix != current.Suffix || v.Major != current.Major {
+			continue
+		}
+		if sameMinor && v.Minor != current.Minor {
+			continue
+		}
+		if newest.Less(v) {
+			newest = v
+		}
+	}
+	return newest
+}
@@ -0,0 +1,54 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import "testing"
+
+func TestNewest(t *testing.T) {
+	tags := []string{"latest", "6", "6.0", "6.0.8", "6.0.9", "6.0.10", "6.0.10-alpine", "6.2.1", "6.2.1-alpine", "7.0.0", "v6.0.11"}
+
+	tests := []struct {
+		name      string
+		current   string
+		sameMinor bool
+		want      string
+	}{
+		{"patch", "6.0.9", true, "6.0.10"},
+		{"minor", "6.0.9", false, "6.2.1"},
+		{"suffix", "6.0.9-alpine", true, "6.0.10-alpine"},
+		{"suffix minor", "6.0.9-alpine", false, "6.2.1-alpine"},
+		{"newest", "6.2.1", false, "6.2.1"},
+		{"prefix", "v6.0.1", true, "v6.0.11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, ok := ParseVersion(tt.current)
+			if !ok {
+				t.Fatalf("ParseVersion(%s) failed", tt.current)
+			}
+			if got := Newest(current, tags, tt.sameMinor); got.Tag != tt.want {
+				t.Errorf("Newest() = %v, want %v", got.Tag, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for tag, want := range map[string]bool{"6.0.9": true, "v6.0.9-alpine3.12": true, "6.0": false, "latest": false} {
+		if _, ok := ParseVersion(tag); ok != want {
+			t.Errorf("ParseVersion(%s) = %v, want %v", tag, ok, want)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// CertName, KeyName and CAName are the file names of the serving certificate, its key and the CA in the CertDir
+	CertName = "tls.crt"
+	KeyName  = "tls.key"
+	CAName   = "ca.crt"
+
+	// DefaultCertValidity is the validity period of the generated CA and serving certificate
+	DefaultCertValidity = 365 * 24 * time.Hour
+	// DefaultCertRotateBefore is the remaining validity period the certificate is rotated at
+	DefaultCertRotateBefore = 30 * 24 * time.Hour
+
+	// certCheckInterval is the interval the certificate expiry is checked at
+	certCheckInterval = time.Hour
+)
+
+var log = logf.Log.WithName("webhook")
+
+// CABundleName is the key of the Secret holding the CA bundle the webhook configurations trust
+const CABundleName = "ca-bundle.crt"
+
+// certSyncInterval is the interval the replicas read the shared certificate at
+const certSyncInterval = time.Minute
+
+// CertRotator generates the self-signed CA and the webhook serving certificate signed by it and keeps them in
+// the Secret shared by the replicas of the operator, every replica writes them to its CertDir. The leader rotates
+// the certificate before it expires and patches the CA bundle of the webhook configurations, the webhook servers
+// reload the certificate from CertDir once the replicas read the rotated one. The CA bundle keeps the previous CA
+// until the next rotation so the API server trusts both the old and the new certificate during the rotation.
+type CertRotator struct {
+	// Client patches the webhook configurations and writes the Secret
+	Client client.Client
+	// Reader reads the webhook configurations and the Secret bypassing the cache
+	Reader client.Reader
+	// SecretNamespace and SecretName name the Secret the certificate is shared in by the replicas
+	SecretNamespace string
+	SecretName      string
+	// CertDir is the directory the certificate is written to
+	CertDir string
+	// DNSNames are the names of the webhook Service the certificate is issued for
+	DNSNames []string
+	// MutatingWebhookConfigurations and ValidatingWebhookConfigurations are the names
+	// of the configurations to patch with the CA bundle
+	MutatingWebhookConfigurations   []string
+	ValidatingWebhookConfigurations []string
+	// Validity and RotateBefore default to DefaultCertValidity and DefaultCertRotateBefore
+	Validity     time.Duration
+	RotateBefore time.Duration
+}
+
+// strict implementation check
+var (
+	_ manager.Runnable               = (*CertRotator)(nil)
+	_ manager.LeaderElectionRunnable = (*CertRotator)(nil)
+	_ manager.Runnable               = certSyncer{}
+	_ manager.LeaderElectionRunnable = certSyncer{}
+)
+
+// Start implements manager.Runnable and rotates the certificate periodically until stop is closed.
+// Ensure must be called before the Manager is started: the webhook server requires the certificate on start.
+func (c *CertRotator) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.rotate(context.Background()); err != nil {
+			log.Error(err, "Failed to rotate webhook certificate")
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: the certificate is rotated by the leader only,
+// see Syncer for the rest of the replicas
+func (c *CertRotator) NeedLeaderElection() bool {
+	return true
+}
+
+// Syncer returns the Runnable every replica reads the certificate rotated by the leader with
+func (c *CertRotator) Syncer() manager.Runnable {
+	return certSyncer{c}
+}
+
+// certSyncer writes the shared certificate to the CertDir of the replica periodically
+type certSyncer struct {
+	rotator *CertRotator
+}
+
+// Start implements manager.Runnable
+func (s certSyncer) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(certSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			secret, err := s.rotator.readSecret(context.Background())
+			if err == nil {
+				err = s.rotator.writeFiles(secret.Data)
+			}
+			if err != nil {
+				log.Error(err, "Failed to read webhook certificate")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: every replica serves the webhooks
+func (certSyncer) NeedLeaderElection() bool {
+	return false
+}
+
+// Ensure issues the shared certificate unless it is present already and writes it to CertDir.
+// It is called by every replica on start, the leader rotates the certificate once it is about to expire.
+func (c *CertRotator) Ensure(ctx context.Context) error {
+	if len(c.DNSNames) == 0 {
+		return fmt.Errorf("no DNS names to issue the webhook certificate for")
+	}
+	secret, err := c.readSecret(ctx)
+	if errors.IsNotFound(err) {
+		secret, err = c.createSecret(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	// the configurations might have been recreated
+	if err := c.patchCABundle(ctx, secret.Data[CABundleName]); err != nil {
+		return err
+	}
+	return c.writeFiles(secret.Data)
+}
+
+// rotate issues the certificate anew if it is invalid or about to expire and makes sure the webhook
+// configurations trust its CA. The CA bundle is patched ahead of the Secret update, so the replicas
+// reading the rotated certificate are trusted. A concurrent update of the Secret fails the rotation.
+func (c *CertRotator) rotate(ctx context.Context) error {
+	secret, err := c.readSecret(ctx)
+	if errors.IsNotFound(err) {
+		secret, err = c.createSecret(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, rotated, err := rotatedCertificate(secret.Data, c.DNSNames, c.validity(), c.rotateBefore(), time.Now())
+	if err != nil {
+		return err
+	}
+	if err := c.patchCABundle(ctx, data[CABundleName]); err != nil {
+		return err
+	}
+	if rotated {
+		secret.Data = data
+		if err := c.Client.Update(ctx, secret); err != nil {
+			return fmt.Errorf("failed to update Secret %s: %s", c.SecretName, err)
+		}
+		log.Info("Rotated webhook certificate", "DNSNames", c.DNSNames)
+	}
+	return c.writeFiles(secret.Data)
+}
+
+// readSecret reads the shared certificate
+func (c *CertRotator) readSecret(ctx context.Context) (*corev1.Secret, error) {
+	secret := new(corev1.Secret)
+	if err := c.Reader.Get(ctx, types.NamespacedName{Namespace: c.SecretNamespace, Name: c.SecretName}, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to fetch Secret %s: %s", c.SecretName, err)
+	}
+	return secret, nil
+}
+
+// createSecret issues the shared certificate, the one created by another replica first is read instead
+func (c *CertRotator) createSecret(ctx context.Context) (*corev1.Secret, error) {
+	data, _, err := rotatedCertificate(nil, c.DNSNames, c.validity(), c.rotateBefore(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: c.SecretNamespace, Name: c.SecretName},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       data,
+	}
+	if err := c.Client.Create(ctx, secret); err != nil {
+		if errors.IsAlreadyExists(err) {
+			return c.readSecret(ctx)
+		}
+		return nil, fmt.Errorf("failed to create Secret %s: %s", c.SecretName, err)
+	}
+	log.Info("Issued webhook certificate", "DNSNames", c.DNSNames)
+	return secret, nil
+}
+
+func (c *CertRotator) validity() time.Duration {
+	if c.Validity <= 0 {
+		return DefaultCertValidity
+	}
+	return c.Validity
+}
+
+func (c *CertRotator) rotateBefore() time.Duration {
+	if c.RotateBefore <= 0 {
+		return DefaultCertRotateBefore
+	}
+	return c.RotateBefore
+}
+
+// rotatedCertificate returns the Secret data with the certificate issued anew along with the CA bundle
+// trusting the new and the previous CA, if the certificate is invalid or expires within rotateBefore.
+// The data is returned as is otherwise.
+func rotatedCertificate(data map[string][]byte, dnsNames []string, validity, rotateBefore time.Duration,
+	now time.Time) (map[string][]byte, bool, error) {
+	if certValid(data[CertName], data[CAName], dnsNames, now.Add(rotateBefore)) {
+		return data, false, nil
+	}
+	caPEM, certPEM, keyPEM, err := generateCertificate(dnsNames, validity)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate certificate: %s", err)
+	}
+	return map[string][]byte{
+		CAName:   caPEM,
+		CertName: certPEM,
+		KeyName:  keyPEM,
+		// the previous CA is trusted until the next rotation
+		CABundleName: append(append([]byte{}, caPEM...), data[CAName]...),
+	}, true, nil
+}
+
+// writeFiles writes the certificate to CertDir unless the files are up to date already
+func (c *CertRotator) writeFiles(data map[string][]byte) error {
+	if err := os.MkdirAll(c.CertDir, 0700); err != nil {
+		return fmt.Errorf("failed to create certificate directory: %s", err)
+	}
+	// the key is written before the certificate: the webhook server reloads the pair on the certificate change
+	for _, name := range []string{CAName, KeyName, CertName} {
+		path := filepath.Join(c.CertDir, name)
+		if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, data[name]) {
+			continue
+		}
+		if err := ioutil.WriteFile(path, data[name], 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// patchCABundle sets the CA bundle of all the webhooks of the configurations, unless they contain the bundle already
+func (c *CertRotator) patchCABundle(ctx context.Context, caBundle []byte) error {
+	for _, name := range c.MutatingWebhookConfigurations {
+		configuration := new(admissionregistrationv1beta1.MutatingWebhookConfiguration)
+		if err := c.Reader.Get(ctx, types.NamespacedName{Name: name}, configuration); err != nil {
+			return fmt.Errorf("failed to fetch MutatingWebhookConfiguration %s: %s", name, err)
+		}
+
+		patch := client.MergeFrom(configuration.DeepCopy())
+		changed := false
+		for i := range configuration.Webhooks {
+			changed = setCABundle(&configuration.Webhooks[i].ClientConfig, caBundle) || changed
+		}
+		if !changed {
+			continue
+		}
+
+		if err := c.Client.Patch(ctx, configuration, patch); err != nil {
+			return fmt.Errorf("failed to patch MutatingWebhookConfiguration %s: %s", name, err)
+		}
+		log.Info("Patched CA bundle", "MutatingWebhookConfiguration", name)
+	}
+
+	for _, name := range c.ValidatingWebhookConfigurations {
+		configuration := new(admissionregistrationv1beta1.ValidatingWebhookConfiguration)
+		if err := c.Reader.Get(ctx, types.NamespacedName{Name: name}, configuration); err != nil {
+			return fmt.Errorf("failed to fetch ValidatingWebhookConfiguration %s: %s", name, err)
+		}
+
+		patch := client.MergeFrom(configuration.DeepCopy())
+		changed := false
+		for i := range configuration.Webhooks {
+			changed = setCABundle(&configuration.Webhooks[i].ClientConfig, caBundle) || changed
+		}
+		if !changed {
+			continue
+		}
+
+		if err := c.Client.Patch(ctx, configuration, patch); err != nil {
+			return fmt.Errorf("failed to patch ValidatingWebhookConfiguration %s: %s", name, err)
+		}
+		log.Info("Patched CA bundle", "ValidatingWebhookConfiguration", name)
+	}
+	return nil
+}
+
+// setCABundle sets the CA bundle of the webhook client unless it contains the bundle already and reports the change.
+// The bundle patched by the leader ahead of the Secret update is not replaced by a replica reading the Secret meanwhile.
+func setCABundle(config *admissionregistrationv1beta1.WebhookClientConfig, caBundle []byte) bool {
+	if bytes.Contains(config.CABundle, caBundle) {
+		return false
+	}
+	config.CABundle = caBundle
+	return true
+}
+
+// certValid checks that the PEM encoded certificate is signed by the CA,
+// is issued for all the DNS names and is valid at the given time
+func certValid(certPEM, caPEM []byte, dnsNames []string, at time.Time) bool {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return false
+	}
+	for _, name := range dnsNames {
+		if _, err := cert.Verify(x509.VerifyOptions{
+			DNSName:     name,
+			Roots:       roots,
+			CurrentTime: at,
+		}); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// generateCertificate generates the self-signed CA and the serving certificate for the DNS names
+// and returns them PEM encoded along with the certificate key
+func generateCertificate(dnsNames []string, validity time.Duration) (caPEM, certPEM, keyPEM []byte, err error) {
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := notBefore.Add(validity)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(notBefore.UnixNano()),
+		Subject:               pkix.Name{CommonName: "redis-operator-webhook-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(notBefore.UnixNano() + 1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+)
+
+func Test_certValid(t *testing.T) {
+	dnsNames := []string{"redis-operator-webhook.redis-operator.svc", "redis-operator-webhook.redis-operator.svc.cluster.local"}
+	caPEM, certPEM, _, err := generateCertificate(dnsNames, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("generateCertificate() error = %v", err)
+	}
+	otherCAPEM, _, _, err := generateCertificate(dnsNames, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("generateCertificate() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		certPEM  []byte
+		caPEM    []byte
+		dnsNames []string
+		at       time.Time
+		want     bool
+	}{
+		{"valid", certPEM, caPEM, dnsNames, time.Now(), true},
+		{"bundle with previous CA", certPEM, append(append([]byte{}, otherCAPEM...), caPEM...), dnsNames, time.Now(), true},
+		{"missing", nil, caPEM, dnsNames, time.Now(), false},
+		{"other CA", certPEM, otherCAPEM, dnsNames, time.Now(), false},
+		{"other name", certPEM, caPEM, []string{"redis-operator.default.svc"}, time.Now(), false},
+		{"expiring", certPEM, caPEM, dnsNames, time.Now().Add(48 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := certValid(tt.certPEM, tt.caPEM, tt.dnsNames, tt.at); got != tt.want {
+				t.Errorf("certValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rotatedCertificate(t *testing.T) {
+	dnsNames := []string{"redis-operator-webhook.redis-operator.svc"}
+	now := time.Now()
+
+	issued, rotated, err := rotatedCertificate(nil, dnsNames, 24*time.Hour, time.Hour, now)
+	if err != nil || !rotated {
+		t.Fatalf("rotatedCertificate() rotated = %v, error = %v, want issued", rotated, err)
+	}
+	if !bytes.Equal(issued[CABundleName], issued[CAName]) {
+		t.Errorf("rotatedCertificate() bundle = %s, want the CA only", issued[CABundleName])
+	}
+
+	if got, rotated, err := rotatedCertificate(issued, dnsNames, 24*time.Hour, time.Hour, now); err != nil || rotated ||
+		!bytes.Equal(got[CertName], issued[CertName]) {
+		t.Errorf("rotatedCertificate() rotated = %v, error = %v, want the valid certificate kept", rotated, err)
+	}
+
+	// the certificate expiring within rotateBefore is rotated, the previous CA is kept in the bundle
+	got, rotated, err := rotatedCertificate(issued, dnsNames, 24*time.Hour, time.Hour, now.Add(23*time.Hour+time.Minute))
+	if err != nil || !rotated {
+		t.Fatalf("rotatedCertificate() rotated = %v, error = %v, want rotated", rotated, err)
+	}
+	if want := append(append([]byte{}, got[CAName]...), issued[CAName]...); !bytes.Equal(got[CABundleName], want) {
+		t.Errorf("rotatedCertificate() bundle = %s, want the new and the previous CA", got[CABundleName])
+	}
+	if !certValid(got[CertName], got[CABundleName], dnsNames, now) || !certValid(issued[CertName], got[CABundleName], dnsNames, now) {
+		t.Error("rotatedCertificate() bundle does not trust both the new and the previous certificate")
+	}
+}
+
+func Test_writeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &CertRotator{CertDir: filepath.Join(dir, "certs")}
+	data := map[string][]byte{CAName: []byte("ca"), CertName: []byte("cert"), KeyName: []byte("key"), CABundleName: []byte("ca")}
+	for i := 0; i < 2; i++ {
+		if err := c.writeFiles(data); err != nil {
+			t.Fatalf("writeFiles() error = %v", err)
+		}
+	}
+	for _, name := range []string{CAName, CertName, KeyName} {
+		if got, err := ioutil.ReadFile(filepath.Join(c.CertDir, name)); err != nil || !bytes.Equal(got, data[name]) {
+			t.Errorf("writeFiles() %s = %q, error = %v, want %q", name, got, err, data[name])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(c.CertDir, CABundleName)); !os.IsNotExist(err) {
+		t.Errorf("writeFiles() wrote %s, error = %v", CABundleName, err)
+	}
+}
+
+func Test_setCABundle(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  string
+		caBundle string
+		want     string
+		changed  bool
+	}{
+		{"empty", "", "new", "new", true},
+		{"same", "new", "new", "new", false},
+		{"rotated", "old", "new+old", "new+old", true},
+		// a replica reading the Secret ahead of the rotation does not drop the new CA
+		{"contained", "new+old", "old", "new+old", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &admissionregistrationv1beta1.WebhookClientConfig{CABundle: []byte(tt.current)}
+			if got := setCABundle(config, []byte(tt.caBundle)); got != tt.changed || string(config.CABundle) != tt.want {
+				t.Errorf("setCABundle() = %v, bundle = %s, want %v, %s", got, config.CABundle, tt.changed, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2019 The redis-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	k8sv1alpha1 "github.com/amaizfinance/redis-operator/pkg/apis/k8s/v1alpha1"
+)
+
+const (
+	// MutateRedisPath is the path the Redis defaulting webhook is served at
+	MutateRedisPath = "/mutate-k8s-amaiz-com-v1alpha1-redis"
+	// ValidateRedisPath is the path the Redis validating webhook is served at
+	ValidateRedisPath = "/validate-k8s-amaiz-com-v1alpha1-redis"
+)
+
+// AddToManager registers all the webhooks with the webhook server of the Manager.
+// The server is started along with the Manager and requires the serving certificate in the Manager CertDir.
+func AddToManager(m manager.Manager) error {
+	m.GetWebhookServer().Register(MutateRedisPath, admission.DefaultingWebhookFor(new(k8sv1alpha1.Redis)))
+	m.GetWebhookServer().Register(ValidateRedisPath, admission.ValidatingWebhookFor(new(k8sv1alpha1.Redis)))
+	return nil
+}